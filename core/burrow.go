@@ -18,10 +18,15 @@
 package core
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"sort"
+	"syscall"
 
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 
 	"github.com/linkedin/Burrow/core/internal/cluster"
 	"github.com/linkedin/Burrow/core/internal/consumer"
@@ -126,6 +131,63 @@ func configureCoordinators(app *protocol.ApplicationContext, coordinators []prot
 	app.ConfigurationValid = true
 }
 
+// setupApplicationContext creates the channels and registries in the application context that the coordinators use to
+// communicate with each other
+func setupApplicationContext(app *protocol.ApplicationContext) {
+	app.EvaluatorChannel = make(chan *protocol.EvaluatorRequest)
+	app.StorageChannel = make(chan *protocol.StorageRequest)
+	app.StatusFeed = &protocol.StatusFeed{}
+	app.Health = &protocol.HealthChecks{}
+	app.NotifierPauses = &protocol.NotifierPauses{}
+	app.OffsetLookups = &protocol.OffsetLookups{}
+	app.DiscoveredClusters = &protocol.DiscoveredClusters{}
+	app.DiscoveredConsumers = &protocol.DiscoveredConsumers{}
+}
+
+// configureCoordinator calls Configure on the coordinator, and returns the panic from it, if there is one, as an error
+func configureCoordinator(coordinator protocol.Coordinator) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	coordinator.Configure()
+	return nil
+}
+
+// reloadFilters reads the configuration file again, if there is one, into a separate configuration, and calls
+// ReloadFilters with it on each coordinator that supports it. The global configuration is not changed, as it is read
+// without locking while Burrow runs. If there is no configuration file, the filters are compiled again from the global
+// configuration. If the configuration cannot be parsed, nothing is reloaded. Errors from a coordinator are logged, and
+// the modules that failed keep their current filters.
+func reloadFilters(log *zap.Logger, coordinators []protocol.Coordinator) {
+	log.Info("Reloading filters")
+	config := viper.GetViper()
+	if configFile := viper.ConfigFileUsed(); configFile != "" {
+		var err error
+		config, err = helpers.ReadConfigFile(configFile)
+		if err != nil {
+			log.Error("failed to read configuration, filters not reloaded", zap.Error(err))
+			return
+		}
+	}
+
+	for _, coordinator := range coordinators {
+		if reloader, ok := coordinator.(protocol.FilterReloader); ok {
+			if err := reloader.ReloadFilters(config); err != nil {
+				log.Error("failed to reload filters", zap.Error(err))
+			}
+		}
+	}
+}
+
+// ExpandConfigEnv replaces the ${VAR} and ${VAR:-default} references to environment variables in the string values of
+// the configuration file that viper has read with the values of those variables. It must be called after reading the
+// configuration, and before calling Start.
+func ExpandConfigEnv() error {
+	return helpers.ExpandConfigEnv()
+}
+
 // Start is called to start the Burrow application. This is exposed so that it is possible to use Burrow as a library
 // from within another application. Prior to calling this func, the configuration must have been loaded by viper from
 // some underlying source (e.g. a TOML configuration file, or explicitly set in code after reading from another source).
@@ -136,8 +198,9 @@ func configureCoordinators(app *protocol.ApplicationContext, coordinators []prot
 // logger based on configurations in viper.
 //
 // exitChannel is a signal channel that is provided by the calling application in order to signal Burrow to shut down.
-// Burrow does not currently check the signal type: if any message is received on the channel, or if the channel is
-// closed, Burrow will exit and Start will return 0.
+// If a SIGHUP is received on the channel, the configuration is read again and the group and topic filters of the
+// modules are reloaded, without restarting anything. If any other message is received on the channel, or if the
+// channel is closed, Burrow will exit and Start will return 0.
 //
 // Start will return a 1 on any failure, including invalid configurations or a failure to start Burrow modules.
 func Start(app *protocol.ApplicationContext, exitChannel chan os.Signal) int {
@@ -154,7 +217,14 @@ func Start(app *protocol.ApplicationContext, exitChannel chan os.Signal) int {
 	log := app.Logger.With(zap.String("type", "main"), zap.String("name", "burrow"))
 
 	// send sarama logs to zap
-	helpers.InitSaramaLogging(app.Logger)
+	saramaLevel := zapcore.DebugLevel
+	if viper.IsSet("logging.sarama-level") {
+		if err := saramaLevel.UnmarshalText([]byte(viper.GetString("logging.sarama-level"))); err != nil {
+			log.Warn("invalid logging.sarama-level, defaulting to debug", zap.String("level", viper.GetString("logging.sarama-level")))
+			saramaLevel = zapcore.DebugLevel
+		}
+	}
+	helpers.InitSaramaLoggingAt(app.Logger, saramaLevel)
 
 	// Set up an array of coordinators in the order they are to be loaded (and closed)
 	coordinators := newCoordinators(app)
@@ -165,8 +235,11 @@ func Start(app *protocol.ApplicationContext, exitChannel chan os.Signal) int {
 	//   * The Notifiers send evaluation requests to the evaluator coordinator to check group status
 	//   * The Evaluators send requests to the storage coordinator for group offset and lag information
 	//   * The HTTP server sends requests to both the evaluator and storage coordinators to fulfill API requests
-	app.EvaluatorChannel = make(chan *protocol.EvaluatorRequest)
-	app.StorageChannel = make(chan *protocol.StorageRequest)
+	// The evaluators also publish the changes of group status to a feed, which the HTTP server streams to clients. The
+	// modules register health checks, which the HTTP server reports on, and the HTTP server pauses notifiers. The
+	// clusters register lookups of topic offsets at a time, which the HTTP server calls on request. The cluster
+	// coordinator adds the configurations of discovered clusters, and their consumers, which the consumer coordinator starts
+	setupApplicationContext(app)
 
 	// Configure coordinators and exit if anything fails
 	configureCoordinators(app, coordinators)
@@ -186,11 +259,18 @@ func Start(app *protocol.ApplicationContext, exitChannel chan os.Signal) int {
 		}
 	}
 
-	// Wait until we're told to exit
-	<-exitChannel
+	// Wait until we're told to exit, reloading filters on SIGHUP
+	for {
+		sig, ok := <-exitChannel
+		if !ok || sig != syscall.SIGHUP {
+			break
+		}
+		reloadFilters(log, coordinators)
+	}
 	log.Info("Shutdown triggered")
 
-	// Stop the coordinators in the reverse order. This assures that request senders are stopped before request servers
+	// Stop the coordinators in the reverse order. This assures that request senders are stopped before request servers.
+	// The notifier is stopped first, and waits for the notifications being sent before the Kafka clients are closed
 	for i := len(coordinators) - 1; i >= 0; i-- {
 		coordinators[i].Stop()
 	}
@@ -198,3 +278,57 @@ func Start(app *protocol.ApplicationContext, exitChannel chan os.Signal) int {
 	// Exit cleanly
 	return 0
 }
+
+// Validate checks the configuration that has been loaded by viper, without starting Burrow. Every client profile is
+// built, including its TLS, SASL, and IAM settings, and every coordinator is configured. If there are no problems with
+// the configuration, each coordinator that supports it connects to its Kafka clusters and fetches their metadata. The
+// problems that are found are returned, and none are returned if Burrow can be started with the configuration. As with
+// Start, the configuration must have been loaded by viper before calling this func.
+//
+// If the calling application would like to control logging, it can pass a pointer to an instantiated
+// protocol.ApplicationContext struct that has the Logger and LogLevel fields set. Otherwise, Validate will create a
+// logger based on configurations in viper.
+func Validate(app *protocol.ApplicationContext) []error {
+	if (app == nil) || (app.Logger == nil) || (app.LogLevel == nil) {
+		app = &protocol.ApplicationContext{}
+		app.Logger, app.LogLevel = ConfigureLogger()
+		defer app.Logger.Sync()
+	}
+	app.Logger.Info("Validating configuration")
+	setupApplicationContext(app)
+
+	// A module fails to configure with the same error as its client profile, which is only reported once
+	var problems []error
+	profileErrors := make(map[string]bool)
+
+	profiles := make([]string, 0)
+	for name := range viper.GetStringMap("client-profile") {
+		profiles = append(profiles, name)
+	}
+	sort.Strings(profiles)
+	for _, name := range profiles {
+		if _, err := helpers.GetSaramaConfigFromClientProfileE(name); err != nil {
+			profileErrors[err.Error()] = true
+			problems = append(problems, errors.New("client-profile."+name+": "+err.Error()))
+		}
+	}
+
+	coordinators := newCoordinators(app)
+	for _, coordinator := range coordinators {
+		if err := configureCoordinator(coordinator); err != nil && !profileErrors[err.Error()] {
+			problems = append(problems, err)
+		}
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+
+	for _, coordinator := range coordinators {
+		if checker, ok := coordinator.(protocol.ConnectionChecker); ok {
+			if err := checker.CheckConnection(); err != nil {
+				problems = append(problems, err)
+			}
+		}
+	}
+	return problems
+}
@@ -17,10 +17,19 @@
 // Currently, the following modules are provided:
 //
 // * kafka - Fetch topic, partition, and offset information from a Kafka cluster
+//
+// # Discovery
+//
+// In addition to the clusters in the configuration, clusters can be discovered from a file or an HTTP endpoint that
+// is set in the cluster-discovery section. The source is read periodically, and modules are started and stopped as
+// clusters appear and disappear. If cluster-discovery has consumer-defaults, a consumer module is also added for each
+// discovered cluster, which the consumer coordinator starts and stops with it.
 package cluster
 
 import (
 	"errors"
+	"sync"
+	"time"
 
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
@@ -44,7 +53,42 @@ type Coordinator struct {
 	// fields that are appropriate to identify this coordinator
 	Log *zap.Logger
 
-	modules map[string]protocol.Module
+	modules     map[string]protocol.Module
+	modulesLock sync.RWMutex
+
+	// Set to getModuleForClass in Configure, if not set (configurable to enable testing)
+	newModuleFunc func(*protocol.ApplicationContext, string, string) protocol.Module
+
+	// The clusters from the configuration, which discovery does not replace
+	staticClusters map[string]bool
+
+	// The clusters from the discovery source, with the settings that their modules were configured with
+	discovered        discoveredClusters
+	discoveryRefresh  int
+	loadDiscoveryFunc func() (discoveredClusters, error)
+	discoveryTicker   *time.Ticker
+	quitChannel       chan struct{}
+	running           sync.WaitGroup
+
+	// The configurations that the modules for the discovered clusters, and their consumer modules, are configured from.
+	// They are kept here, rather than in the global configuration, as that is read without locking while Burrow runs
+	discoveredConfigs map[string]*viper.Viper
+
+	// The settings from the cluster-discovery section, which are read once when it is configured
+	discoveryDefaults map[string]interface{}
+	consumerDefaults  map[string]interface{}
+
+	// The discovered clusters that have a consumer module from the consumer-defaults, and the consumers from the
+	// configuration, which discovery does not replace
+	discoveredConsumers map[string]bool
+	staticConsumers     map[string]bool
+}
+
+// discoverableModule is a cluster module that can be configured from a viper instance other than the global
+// configuration, which the modules for discovered clusters must be
+type discoverableModule interface {
+	protocol.Module
+	configureFrom(config *viper.Viper, name, configRoot string)
 }
 
 // getModuleForClass returns the correct module based on the passed className. As part of the Configure steps, if there
@@ -68,43 +112,102 @@ func getModuleForClass(app *protocol.ApplicationContext, moduleName, className s
 
 // Configure is called to create each of the configured cluster modules and call their Configure funcs to validate
 // their individual configurations and set them up. If there are any problems, it is expected that these funcs will
-// panic with a descriptive error message, as configuration failures are not recoverable errors.
+// panic with a descriptive error message, as configuration failures are not recoverable errors. If cluster-discovery is
+// configured, the modules for the discovered clusters are then set up as well, each from its own configuration.
 func (bc *Coordinator) Configure() {
 	bc.Log.Info("configuring")
 
 	bc.modules = make(map[string]protocol.Module)
+	bc.staticClusters = make(map[string]bool)
+	bc.quitChannel = make(chan struct{})
+	bc.running = sync.WaitGroup{}
+	if bc.newModuleFunc == nil {
+		bc.newModuleFunc = getModuleForClass
+	}
 
 	// Create all configured cluster modules, add to list of clusters
 	modules := viper.GetStringMap("cluster")
 	for name := range modules {
 		configRoot := "cluster." + name
-		module := getModuleForClass(bc.App, name, viper.GetString(configRoot+".class-name"))
+		module := bc.newModuleFunc(bc.App, name, viper.GetString(configRoot+".class-name"))
 		module.Configure(name, configRoot)
 		bc.modules[name] = module
+		bc.staticClusters[name] = true
+	}
+
+	if viper.IsSet("cluster-discovery") {
+		bc.configureDiscovery()
 	}
 }
 
-// Start calls each of the configured cluster modules' underlying Start funcs. As the coordinator itself has no ongoing
-// work to do, it does not start any other goroutines. If any module Start returns an error, this func stops immediately
-// and returns that error to the caller. No further modules will be loaded after that.
+// Start calls each of the configured cluster modules' underlying Start funcs. If any module Start returns an error, this
+// func stops immediately and returns that error to the caller. No further modules will be loaded after that. If
+// cluster-discovery is configured, the clusters that were discovered when configuring are added to storage first, and a
+// goroutine is then started to read the discovery source periodically.
 func (bc *Coordinator) Start() error {
 	bc.Log.Info("starting")
 
+	// Storage only knows about the clusters in the configuration when it starts
+	bc.modulesLock.RLock()
+	for name := range bc.discovered {
+		helpers.TimeoutSendStorageRequest(bc.App.StorageChannel, &protocol.StorageRequest{
+			RequestType: protocol.StorageSetAddCluster,
+			Cluster:     name,
+		}, 1)
+	}
+
 	// Start Cluster modules
 	err := helpers.StartCoordinatorModules(bc.modules)
+	bc.modulesLock.RUnlock()
 	if err != nil {
 		return errors.New("Error starting cluster module: " + err.Error())
 	}
+
+	if bc.loadDiscoveryFunc != nil {
+		bc.discoveryTicker = time.NewTicker(time.Duration(bc.discoveryRefresh) * time.Second)
+		bc.running.Add(1)
+		go bc.discoveryLoop()
+	}
 	return nil
 }
 
+// ReloadFilters calls each of the configured cluster modules' underlying ReloadFilters funcs with the provided
+// configuration, for the modules that support it. The modules for discovered clusters are reloaded from their own
+// configurations, as they are not in the configuration file. All modules are reloaded even if one fails, and the last
+// error is returned to the caller.
+func (bc *Coordinator) ReloadFilters(config *viper.Viper) error {
+	bc.Log.Info("reloading filters")
+
+	bc.modulesLock.RLock()
+	defer bc.modulesLock.RUnlock()
+	return helpers.ReloadCoordinatorModuleFilters(bc.modules, config, bc.discoveredConfigs)
+}
+
+// CheckConnection calls each of the configured cluster modules' underlying CheckConnection funcs, for the modules that
+// support it, instead of starting them. All modules are checked even if one fails, and the errors are returned to the
+// caller.
+func (bc *Coordinator) CheckConnection() error {
+	bc.modulesLock.RLock()
+	defer bc.modulesLock.RUnlock()
+	return helpers.CheckCoordinatorModuleConnections("cluster", bc.modules)
+}
+
 // Stop calls each of the configured cluster modules' underlying Stop funcs. It is expected that the module Stop will
 // not return until the module has been completely stopped. While an error can be returned, this func always returns no
 // error, as a failure during stopping is not a critical failure
 func (bc *Coordinator) Stop() error {
 	bc.Log.Info("stopping")
 
+	// Stop discovery first, so that no modules are started or stopped while the modules are stopping
+	if bc.discoveryTicker != nil {
+		bc.discoveryTicker.Stop()
+	}
+	close(bc.quitChannel)
+	bc.running.Wait()
+
 	// The individual cluster modules can choose whether or not to implement a wait in the Stop routine
+	bc.modulesLock.RLock()
 	helpers.StopCoordinatorModules(bc.modules)
+	bc.modulesLock.RUnlock()
 	return nil
 }
@@ -0,0 +1,328 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package cluster
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/linkedin/Burrow/core/internal/helpers"
+	"github.com/linkedin/Burrow/core/protocol"
+)
+
+// discoveredClusters is the list of clusters returned by a discovery source. It is a JSON object, keyed by the cluster
+// name, where each value holds the settings for the cluster, using the same names as a cluster section in the
+// configuration, such as:
+//
+//	{"east": {"servers": ["kafka-east-1.example.com:9092"]}, "west": {"servers-srv": "_kafka._tcp.west.example.com"}}
+type discoveredClusters map[string]map[string]interface{}
+
+// configureDiscovery sets up the cluster-discovery source, which is either a file or an HTTP endpoint that returns the
+// clusters as JSON, and is read every refresh seconds (default 60). The settings in the defaults table are used for any
+// setting that a discovered cluster does not have, and the class-name defaults to kafka. If the consumer-defaults table
+// is set, a consumer module of the kafka class, with the same name as the cluster, is also set up for each discovered
+// cluster, using those settings. Its servers default to those of the cluster. The discovered clusters are not put in
+// the global configuration, and are not listed in its cluster and consumer sections. The clusters that are discovered
+// at startup are configured now, and are started with the statically configured clusters. If the source is invalid,
+// this func will panic. Failing to read the source, or an invalid cluster, is only logged, as the source is read again
+// later.
+func (bc *Coordinator) configureDiscovery() {
+	file := viper.GetString("cluster-discovery.file")
+	url := viper.GetString("cluster-discovery.url")
+	if (file == "") == (url == "") {
+		panic("cluster-discovery must have exactly one of file or url")
+	}
+
+	viper.SetDefault("cluster-discovery.refresh", 60)
+	viper.SetDefault("cluster-discovery.timeout", 10)
+	bc.discoveryRefresh = viper.GetInt("cluster-discovery.refresh")
+	if bc.discoveryRefresh <= 0 {
+		panic("cluster-discovery must have a refresh greater than zero")
+	}
+
+	if bc.loadDiscoveryFunc == nil {
+		if file != "" {
+			bc.loadDiscoveryFunc = func() (discoveredClusters, error) {
+				return loadDiscoveryFile(file)
+			}
+		} else {
+			client := &http.Client{
+				Timeout: time.Duration(viper.GetInt("cluster-discovery.timeout")) * time.Second,
+			}
+			bc.loadDiscoveryFunc = func() (discoveredClusters, error) {
+				return loadDiscoveryURL(client, url)
+			}
+		}
+	}
+
+	bc.discovered = make(discoveredClusters)
+	bc.discoveredConfigs = make(map[string]*viper.Viper)
+	bc.discoveredConsumers = make(map[string]bool)
+	bc.discoveryDefaults = viper.GetStringMap("cluster-discovery.defaults")
+	if viper.IsSet("cluster-discovery.consumer-defaults") {
+		bc.consumerDefaults = viper.GetStringMap("cluster-discovery.consumer-defaults")
+	}
+	bc.staticConsumers = make(map[string]bool)
+	for name := range viper.GetStringMap("consumer") {
+		bc.staticConsumers[name] = true
+	}
+	clusters, err := bc.loadDiscoveryFunc()
+	if err != nil {
+		bc.Log.Warn("failed to discover clusters", zap.Error(err))
+		return
+	}
+	for name, settings := range clusters {
+		if err := bc.addDiscoveredCluster(name, settings); err != nil {
+			bc.Log.Error("failed to configure discovered cluster", zap.String("cluster", name), zap.Error(err))
+			continue
+		}
+		if bc.discoveredConsumers[name] {
+			bc.App.DiscoveredConsumers.Add(name, bc.discoveredConfigs[name])
+		}
+	}
+}
+
+func loadDiscoveryFile(file string) (discoveredClusters, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	clusters := make(discoveredClusters)
+	if err := json.Unmarshal(data, &clusters); err != nil {
+		return nil, err
+	}
+	return clusters, nil
+}
+
+func loadDiscoveryURL(client *http.Client, url string) (discoveredClusters, error) {
+	req, err := http.NewRequest(http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("unexpected response status " + strconv.Itoa(resp.StatusCode))
+	}
+
+	clusters := make(discoveredClusters)
+	if err := json.NewDecoder(resp.Body).Decode(&clusters); err != nil {
+		return nil, err
+	}
+	return clusters, nil
+}
+
+func (bc *Coordinator) discoveryLoop() {
+	defer bc.running.Done()
+
+	for {
+		select {
+		case <-bc.discoveryTicker.C:
+			bc.reconcileDiscovery()
+		case <-bc.quitChannel:
+			return
+		}
+	}
+}
+
+// reconcileDiscovery reads the discovery source, and starts the modules for clusters that have appeared, stops the
+// modules for clusters that have gone, and restarts the modules for clusters whose settings changed. The consumer
+// module of a cluster is added after the cluster module is started, and removed before it is stopped. If the source
+// cannot be read, the running clusters are left as they are.
+func (bc *Coordinator) reconcileDiscovery() {
+	clusters, err := bc.loadDiscoveryFunc()
+	if err != nil {
+		bc.Log.Warn("failed to discover clusters", zap.Error(err))
+		return
+	}
+
+	bc.modulesLock.Lock()
+	defer bc.modulesLock.Unlock()
+
+	for name, settings := range bc.discovered {
+		newSettings, ok := clusters[name]
+		if !ok {
+			bc.Log.Info("discovered cluster removed", zap.String("cluster", name))
+			bc.stopDiscoveredCluster(name)
+			helpers.TimeoutSendStorageRequest(bc.App.StorageChannel, &protocol.StorageRequest{
+				RequestType: protocol.StorageSetDeleteCluster,
+				Cluster:     name,
+			}, 1)
+		} else if !reflect.DeepEqual(settings, newSettings) {
+			// The stored offsets are kept, as it is still the same cluster
+			bc.Log.Info("discovered cluster changed", zap.String("cluster", name))
+			bc.stopDiscoveredCluster(name)
+		}
+	}
+
+	for name, settings := range clusters {
+		if _, ok := bc.discovered[name]; ok {
+			continue
+		}
+		if err := bc.addDiscoveredCluster(name, settings); err != nil {
+			bc.Log.Error("failed to configure discovered cluster", zap.String("cluster", name), zap.Error(err))
+			continue
+		}
+		if _, ok := bc.discovered[name]; !ok {
+			continue
+		}
+
+		helpers.TimeoutSendStorageRequest(bc.App.StorageChannel, &protocol.StorageRequest{
+			RequestType: protocol.StorageSetAddCluster,
+			Cluster:     name,
+		}, 1)
+		if err := bc.modules[name].Start(); err != nil {
+			// Forget the cluster, so that it is started again the next time the source is read
+			bc.Log.Error("failed to start discovered cluster", zap.String("cluster", name), zap.Error(err))
+			bc.forgetDiscoveredCluster(name)
+			continue
+		}
+		bc.Log.Info("discovered cluster started", zap.String("cluster", name))
+		if bc.discoveredConsumers[name] {
+			bc.App.DiscoveredConsumers.Add(name, bc.discoveredConfigs[name])
+		}
+	}
+}
+
+// addDiscoveredCluster creates the configuration for the cluster from its settings, on top of the discovery defaults, and
+// creates and configures its module from that configuration. If there are consumer-defaults, the settings for its
+// consumer module are in the same configuration, so that the cluster module sees the offsets topic of the consumer. A
+// cluster that is configured statically is skipped. Any panic from configuring the module is returned as an error. It
+// is assumed that a write lock on the modules is already held, if needed.
+func (bc *Coordinator) addDiscoveredCluster(name string, settings map[string]interface{}) (err error) {
+	if bc.staticClusters[name] {
+		bc.Log.Warn("ignoring discovered cluster with the same name as a configured one", zap.String("cluster", name))
+		return nil
+	}
+
+	clusterConfig := map[string]interface{}{"class-name": "kafka"}
+	for key, value := range bc.discoveryDefaults {
+		clusterConfig[key] = value
+	}
+	for key, value := range settings {
+		clusterConfig[key] = value
+	}
+	consumerConfig := bc.discoveredConsumerConfig(name, clusterConfig)
+	config := newDiscoveredConfig(name, clusterConfig, consumerConfig)
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	configRoot := "cluster." + name
+	className := config.GetString(configRoot + ".class-name")
+	module, ok := bc.newModuleFunc(bc.App, name, className).(discoverableModule)
+	if !ok {
+		return errors.New("the " + className + " class cannot be used for a discovered cluster")
+	}
+	module.configureFrom(config, name, configRoot)
+	bc.modules[name] = module
+	bc.discovered[name] = settings
+	bc.discoveredConfigs[name] = config
+	bc.discoveredConsumers[name] = consumerConfig != nil
+	bc.App.DiscoveredClusters.Add(name, config)
+	return nil
+}
+
+// discoveredConsumerConfig returns the settings for the consumer module of a discovered cluster, from the
+// consumer-defaults, or nil if they are not set or there is a consumer with the same name in the configuration
+func (bc *Coordinator) discoveredConsumerConfig(name string, clusterConfig map[string]interface{}) map[string]interface{} {
+	if bc.consumerDefaults == nil {
+		return nil
+	}
+	if bc.staticConsumers[name] {
+		bc.Log.Warn("not adding a consumer for discovered cluster with the same name as a configured one", zap.String("cluster", name))
+		return nil
+	}
+
+	config := map[string]interface{}{"class-name": "kafka"}
+	if servers, ok := clusterConfig["servers"]; ok {
+		config["servers"] = servers
+	}
+	for key, value := range bc.consumerDefaults {
+		config[key] = value
+	}
+	config["cluster"] = name
+	return config
+}
+
+// newDiscoveredConfig returns a new viper instance that holds a copy of the global configuration, with the settings of
+// the discovered cluster and its consumer module, if it has one, merged in under the cluster and consumer sections. The
+// modules for the cluster are configured from it, and set their defaults on it, without changing the global
+// configuration, which other modules are reading. Everything is copied, as viper changes the maps that it merges.
+func newDiscoveredConfig(name string, clusterConfig, consumerConfig map[string]interface{}) *viper.Viper {
+	discovered := map[string]interface{}{
+		"cluster": map[string]interface{}{name: clusterConfig},
+	}
+	if consumerConfig != nil {
+		discovered["consumer"] = map[string]interface{}{name: consumerConfig}
+	}
+
+	config := viper.New()
+	config.MergeConfigMap(copyConfigValue(viper.AllSettings()).(map[string]interface{}))
+	config.MergeConfigMap(copyConfigValue(discovered).(map[string]interface{}))
+	return config
+}
+
+// copyConfigValue returns a deep copy of a value from a configuration, copying the maps and lists in it
+func copyConfigValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			copied[key] = copyConfigValue(item)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = copyConfigValue(item)
+		}
+		return copied
+	default:
+		return v
+	}
+}
+
+// stopDiscoveredCluster removes the consumer module for a discovered cluster, if it has one, then stops the module for
+// the cluster and forgets it. It is assumed that a write lock on the modules is already held.
+func (bc *Coordinator) stopDiscoveredCluster(name string) {
+	bc.App.DiscoveredConsumers.Remove(name)
+	if err := bc.modules[name].Stop(); err != nil {
+		bc.Log.Warn("failed to stop discovered cluster", zap.String("cluster", name), zap.Error(err))
+	}
+	bc.forgetDiscoveredCluster(name)
+}
+
+// forgetDiscoveredCluster removes the module and the configuration for a discovered cluster, so that it is added again
+// the next time it is in the discovery source. It is assumed that a write lock on the modules is already held.
+func (bc *Coordinator) forgetDiscoveredCluster(name string) {
+	bc.App.DiscoveredClusters.Remove(name)
+	delete(bc.modules, name)
+	delete(bc.discovered, name)
+	delete(bc.discoveredConfigs, name)
+	delete(bc.discoveredConsumers, name)
+}
@@ -0,0 +1,295 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package cluster
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/linkedin/Burrow/core/internal/helpers"
+	"github.com/linkedin/Burrow/core/protocol"
+)
+
+func fixtureDiscoveryCoordinator(clusters *discoveredClusters) *Coordinator {
+	coordinator := fixtureCoordinator()
+	coordinator.App.StorageChannel = make(chan *protocol.StorageRequest, 10)
+	coordinator.App.DiscoveredClusters = &protocol.DiscoveredClusters{}
+	viper.Set("cluster-discovery.file", "clusters.json")
+	viper.Set("cluster-discovery.defaults.offset-refresh", 30)
+	coordinator.loadDiscoveryFunc = func() (discoveredClusters, error) {
+		if *clusters == nil {
+			return nil, errors.New("source unavailable")
+		}
+		return *clusters, nil
+	}
+	return coordinator
+}
+
+// mockDiscoverableModule is a MockModule that can also be configured for a discovered cluster
+type mockDiscoverableModule struct {
+	helpers.MockModule
+}
+
+func (m *mockDiscoverableModule) configureFrom(config *viper.Viper, name, configRoot string) {
+	m.Called(name, configRoot)
+}
+
+func newMockDiscoverableModule(name string, startErr error) *mockDiscoverableModule {
+	mockModule := &mockDiscoverableModule{}
+	mockModule.On("Configure", name, "cluster."+name).Return()
+	mockModule.On("configureFrom", name, "cluster."+name).Return()
+	mockModule.On("Start").Return(startErr)
+	mockModule.On("Stop").Return(nil)
+	return mockModule
+}
+
+func TestCoordinator_Configure_Discovery(t *testing.T) {
+	clusters := discoveredClusters{
+		"east": {"servers": []interface{}{"broker1.example.com:1234"}},
+		"test": {"servers": []interface{}{"broker2.example.com:1234"}},
+	}
+	coordinator := fixtureDiscoveryCoordinator(&clusters)
+	coordinator.Configure()
+
+	assert.Lenf(t, coordinator.modules, 2, "Expected 2 modules configured, not %v", len(coordinator.modules))
+	assert.IsType(t, &KafkaCluster{}, coordinator.modules["east"], "Expected the discovered cluster to default to the kafka class")
+	assert.False(t, viper.IsSet("cluster.east"), "Expected the discovered cluster to not be in the global configuration")
+	config, ok := coordinator.App.DiscoveredClusters.Get("east")
+	assert.True(t, ok, "Expected the configuration of the discovered cluster to be recorded")
+	assert.Equal(t, []string{"broker1.example.com:1234"}, config.GetStringSlice("cluster.east.servers"), "Expected the discovered cluster to be in its configuration")
+	assert.Equal(t, []string{"broker1.example.com:1234"}, config.GetStringSlice("cluster.test.servers"), "Expected the global configuration to be in the configuration of the discovered cluster")
+	assert.Equalf(t, 30, coordinator.modules["east"].(*KafkaCluster).offsetRefresh, "Expected the default offset-refresh of 30 to be used, not %v", coordinator.modules["east"].(*KafkaCluster).offsetRefresh)
+	assert.NotContains(t, coordinator.discovered, "test", "Expected the discovered cluster with the name of a configured one to be ignored")
+	assert.Equal(t, []string{"broker1.example.com:1234"}, viper.GetStringSlice("cluster.test.servers"), "Expected the configured cluster to be unchanged")
+}
+
+func TestCoordinator_Configure_DiscoveryBadCluster(t *testing.T) {
+	clusters := discoveredClusters{
+		"east": {"servers": []interface{}{"notahostport"}},
+	}
+	coordinator := fixtureDiscoveryCoordinator(&clusters)
+	assert.NotPanics(t, func() { coordinator.Configure() }, "Expected a bad discovered cluster to not panic")
+	assert.NotContains(t, coordinator.modules, "east", "Expected the bad discovered cluster to be skipped")
+	_, ok := coordinator.App.DiscoveredClusters.Get("east")
+	assert.False(t, ok, "Expected the bad discovered cluster to not be recorded")
+	assert.False(t, viper.IsSet("cluster.east"), "Expected the bad discovered cluster to not be in the global configuration")
+}
+
+func TestCoordinator_Configure_DiscoveryNotDiscoverable(t *testing.T) {
+	clusters := discoveredClusters{
+		"east": {"servers": []interface{}{"broker1.example.com:1234"}},
+	}
+	coordinator := fixtureDiscoveryCoordinator(&clusters)
+	coordinator.newModuleFunc = func(app *protocol.ApplicationContext, name, className string) protocol.Module {
+		if name == "test" {
+			return getModuleForClass(app, name, className)
+		}
+		return &helpers.MockModule{}
+	}
+	assert.NotPanics(t, func() { coordinator.Configure() }, "Expected a module that cannot be discovered to not panic")
+	assert.NotContains(t, coordinator.modules, "east", "Expected the module that cannot be discovered to be skipped")
+}
+
+func TestCoordinator_Start_Discovery(t *testing.T) {
+	clusters := discoveredClusters{
+		"east": {"servers": []interface{}{"broker1.example.com:1234"}},
+	}
+	coordinator := fixtureDiscoveryCoordinator(&clusters)
+	coordinator.newModuleFunc = func(app *protocol.ApplicationContext, name, className string) protocol.Module {
+		return newMockDiscoverableModule(name, nil)
+	}
+	coordinator.Configure()
+
+	// The cluster discovered when configuring is not in the global configuration, so it is added to storage
+	assert.Nil(t, coordinator.Start(), "Expected Start to not return an error")
+	request := <-coordinator.App.StorageChannel
+	assert.Equalf(t, protocol.StorageSetAddCluster, request.RequestType, "Expected request sent with type StorageSetAddCluster, not %v", request.RequestType)
+	assert.Equalf(t, "east", request.Cluster, "Expected request sent with cluster east, not %v", request.Cluster)
+	coordinator.modules["east"].(*mockDiscoverableModule).AssertCalled(t, "Start")
+	coordinator.Stop()
+}
+
+func TestCoordinator_Configure_DiscoveryBadSource(t *testing.T) {
+	var clusters discoveredClusters
+	coordinator := fixtureDiscoveryCoordinator(&clusters)
+	viper.Set("cluster-discovery.url", "http://discovery.example.com/clusters")
+	assert.Panics(t, func() { coordinator.Configure() }, "Expected a panic with both file and url set")
+
+	coordinator = fixtureDiscoveryCoordinator(&clusters)
+	viper.Set("cluster-discovery.refresh", 0)
+	assert.Panics(t, func() { coordinator.Configure() }, "Expected a panic with a refresh of 0")
+}
+
+func TestCoordinator_reconcileDiscovery(t *testing.T) {
+	var clusters discoveredClusters
+	coordinator := fixtureDiscoveryCoordinator(&clusters)
+
+	// Discovered clusters get mock modules, so they can be started
+	mockModules := make(map[string]*mockDiscoverableModule)
+	coordinator.newModuleFunc = func(app *protocol.ApplicationContext, name, className string) protocol.Module {
+		if name == "test" {
+			return getModuleForClass(app, name, className)
+		}
+		mockModules[name] = newMockDiscoverableModule(name, nil)
+		return mockModules[name]
+	}
+	coordinator.Configure()
+	assert.Lenf(t, coordinator.modules, 1, "Expected 1 module configured, not %v", len(coordinator.modules))
+
+	// A new cluster is added to storage and started
+	clusters = discoveredClusters{"east": {"servers": []interface{}{"broker1.example.com:1234"}}}
+	coordinator.reconcileDiscovery()
+	request := <-coordinator.App.StorageChannel
+	assert.Equalf(t, protocol.StorageSetAddCluster, request.RequestType, "Expected request sent with type StorageSetAddCluster, not %v", request.RequestType)
+	assert.Equalf(t, "east", request.Cluster, "Expected request sent with cluster east, not %v", request.Cluster)
+	mockModules["east"].AssertCalled(t, "Start")
+	firstModule := mockModules["east"]
+
+	// A cluster with changed settings is restarted with a new module
+	clusters = discoveredClusters{"east": {"servers": []interface{}{"broker2.example.com:1234"}}}
+	coordinator.reconcileDiscovery()
+	<-coordinator.App.StorageChannel
+	firstModule.AssertCalled(t, "Stop")
+	assert.NotEqual(t, firstModule, mockModules["east"], "Expected a new module for the changed cluster")
+	mockModules["east"].AssertCalled(t, "Start")
+	config, _ := coordinator.App.DiscoveredClusters.Get("east")
+	assert.Equal(t, []string{"broker2.example.com:1234"}, config.GetStringSlice("cluster.east.servers"), "Expected the changed servers to be in the configuration")
+
+	// Nothing changes if the source cannot be read
+	clusters = nil
+	coordinator.reconcileDiscovery()
+	assert.Contains(t, coordinator.modules, "east", "Expected the cluster to be kept when the source cannot be read")
+
+	// A cluster that has gone is stopped and removed from storage
+	clusters = discoveredClusters{}
+	coordinator.reconcileDiscovery()
+	request = <-coordinator.App.StorageChannel
+	assert.Equalf(t, protocol.StorageSetDeleteCluster, request.RequestType, "Expected request sent with type StorageSetDeleteCluster, not %v", request.RequestType)
+	mockModules["east"].AssertCalled(t, "Stop")
+	assert.NotContains(t, coordinator.modules, "east", "Expected the module for the removed cluster to be gone")
+	assert.Contains(t, coordinator.modules, "test", "Expected the configured cluster to be kept")
+	_, ok := coordinator.App.DiscoveredClusters.Get("east")
+	assert.False(t, ok, "Expected the configuration of the removed cluster to be forgotten")
+	assert.False(t, viper.IsSet("cluster.east"), "Expected the global configuration to be unchanged")
+}
+
+func TestCoordinator_reconcileDiscovery_StartFailed(t *testing.T) {
+	clusters := discoveredClusters{}
+	coordinator := fixtureDiscoveryCoordinator(&clusters)
+	coordinator.newModuleFunc = func(app *protocol.ApplicationContext, name, className string) protocol.Module {
+		if name == "test" {
+			return getModuleForClass(app, name, className)
+		}
+		return newMockDiscoverableModule(name, errors.New("connection failed"))
+	}
+	coordinator.Configure()
+
+	clusters = discoveredClusters{"east": {"servers": []interface{}{"broker1.example.com:1234"}}}
+	coordinator.reconcileDiscovery()
+	<-coordinator.App.StorageChannel
+	assert.NotContains(t, coordinator.modules, "east", "Expected the cluster that failed to start to be forgotten")
+	assert.NotContains(t, coordinator.discovered, "east", "Expected the cluster that failed to start to be retried")
+}
+
+func TestCoordinator_reconcileDiscovery_Consumer(t *testing.T) {
+	clusters := discoveredClusters{
+		"east": {"servers": []interface{}{"broker1.example.com:1234"}},
+	}
+	coordinator := fixtureDiscoveryCoordinator(&clusters)
+	viper.Set("cluster-discovery.consumer-defaults.offsets-topic", "__east_offsets")
+	viper.Set("consumer.tested.class-name", "kafka")
+	viper.Set("consumer.tested.cluster", "test")
+	coordinator.App.DiscoveredConsumers = &protocol.DiscoveredConsumers{}
+	var changes []string
+	coordinator.App.DiscoveredConsumers.Watch(func(name string, config *viper.Viper) {
+		if config != nil {
+			changes = append(changes, "add "+name)
+		} else {
+			changes = append(changes, "remove "+name)
+		}
+	})
+	coordinator.newModuleFunc = func(app *protocol.ApplicationContext, name, className string) protocol.Module {
+		if name == "test" {
+			return getModuleForClass(app, name, className)
+		}
+		return newMockDiscoverableModule(name, nil)
+	}
+	coordinator.Configure()
+
+	assert.Equal(t, []string{"add east"}, changes, "Expected the consumer for the discovered cluster to be added")
+	config, ok := coordinator.App.DiscoveredConsumers.Get("east")
+	assert.True(t, ok, "Expected the consumer for the discovered cluster to be recorded")
+	clusterConfig, _ := coordinator.App.DiscoveredClusters.Get("east")
+	assert.Same(t, clusterConfig, config, "Expected the consumer to have the configuration of its cluster")
+	assert.Equal(t, "kafka", config.GetString("consumer.east.class-name"), "Expected the consumer class-name to default to kafka")
+	assert.Equal(t, "east", config.GetString("consumer.east.cluster"), "Expected the consumer to belong to the discovered cluster")
+	assert.Equal(t, "__east_offsets", config.GetString("consumer.east.offsets-topic"), "Expected the consumer-defaults to be used")
+	assert.Equal(t, []string{"broker1.example.com:1234"}, config.GetStringSlice("consumer.east.servers"), "Expected the servers of the cluster to be used")
+	assert.Equal(t, "test", config.GetString("consumer.tested.cluster"), "Expected the configured consumers to be in the configuration")
+	assert.False(t, viper.IsSet("consumer.east"), "Expected the consumer to not be in the global configuration")
+
+	// A discovered cluster with the name of a configured consumer does not replace it
+	clusters = discoveredClusters{
+		"east":   {"servers": []interface{}{"broker1.example.com:1234"}},
+		"tested": {"servers": []interface{}{"broker3.example.com:1234"}},
+	}
+	coordinator.reconcileDiscovery()
+	<-coordinator.App.StorageChannel
+	assert.Equal(t, []string{"add east"}, changes, "Expected no consumer for the cluster with the name of a configured consumer")
+	assert.Equal(t, "test", viper.GetString("consumer.tested.cluster"), "Expected the configured consumer to be unchanged")
+
+	// The consumer is removed before its cluster is stopped, and removed from the configuration
+	clusters = discoveredClusters{}
+	coordinator.reconcileDiscovery()
+	<-coordinator.App.StorageChannel
+	<-coordinator.App.StorageChannel
+	assert.Equal(t, []string{"add east", "remove east"}, changes, "Expected the consumer for the removed cluster to be removed")
+	_, ok = coordinator.App.DiscoveredConsumers.Get("east")
+	assert.False(t, ok, "Expected the consumer for the removed cluster to be forgotten")
+	assert.Equal(t, "test", viper.GetString("consumer.tested.cluster"), "Expected the configured consumer to be kept")
+}
+
+func TestCoordinator_loadDiscoveryFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "clusters.json")
+	err := os.WriteFile(file, []byte(`{"east": {"servers": ["broker1.example.com:1234"]}}`), 0o600)
+	assert.Nil(t, err, "Expected the test file to be written")
+
+	clusters, err := loadDiscoveryFile(file)
+	assert.Nil(t, err, "Expected no error loading the file")
+	assert.Equal(t, discoveredClusters{"east": {"servers": []interface{}{"broker1.example.com:1234"}}}, clusters)
+
+	_, err = loadDiscoveryFile(filepath.Join(t.TempDir(), "missing.json"))
+	assert.NotNil(t, err, "Expected an error for a missing file")
+}
+
+func TestCoordinator_loadDiscoveryURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/clusters" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"west": {"servers-srv": "_kafka._tcp.west.example.com"}}`))
+	}))
+	defer server.Close()
+
+	clusters, err := loadDiscoveryURL(server.Client(), server.URL+"/clusters")
+	assert.Nil(t, err, "Expected no error loading the URL")
+	assert.Equal(t, discoveredClusters{"west": {"servers-srv": "_kafka._tcp.west.example.com"}}, clusters)
+
+	_, err = loadDiscoveryURL(server.Client(), server.URL+"/missing")
+	assert.NotNil(t, err, "Expected an error for a 404 response")
+}
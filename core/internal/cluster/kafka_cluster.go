@@ -10,7 +10,10 @@
 package cluster
 
 import (
+	"errors"
 	"fmt"
+	"regexp"
+	"strings"
 	"sync"
 	"time"
 
@@ -36,49 +39,176 @@ type KafkaCluster struct {
 	Log *zap.Logger
 
 	name                string
+	configRoot          string
+	clientProfile       string
 	saramaConfig        *sarama.Config
 	servers             []string
+	staticServers       []string
+	serversSRV          string
+	serversSRVRefresh   int
+	lookupSRVFunc       func(string) ([]string, error)
 	offsetRefresh       int
 	topicRefresh        int
 	groupsReaperRefresh int
+	groupMembersRefresh int
+	timeLagRefresh      int
+	healthThreshold     int
+	fetchOldestOffsets  bool
+	fetchConcurrency    int
+	consumedTopicsOnly  bool
+	topicFilter         *regexp.Regexp
+	topicExclude        *regexp.Regexp
+	ignoreInternal      bool
+	internalTopics      *regexp.Regexp
+	offsetsTopics       map[string]bool
+	filterLock          sync.RWMutex
 
 	offsetTicker       *time.Ticker
 	metadataTicker     *time.Ticker
 	groupsReaperTicker *time.Ticker
+	groupMembersTicker *time.Ticker
+	timeLagTicker      *time.Ticker
+	serversSRVTicker   *time.Ticker
 	quitChannel        chan struct{}
 	running            sync.WaitGroup
 
 	fetchMetadata   bool
 	topicPartitions map[string][]int32
+
+	// The topics that consumer groups have committed offsets for, when consumed-topics-only is set. This is nil until
+	// the topics have been fetched once
+	consumedTopics map[string]struct{}
+
+	// Beats at the end of each fetch of the broker offsets
+	heartbeat protocol.Heartbeat
+
+	// The highest broker offset seen for each partition, used to detect offsets going backwards
+	highWaterOffsets map[string]map[int32]*partitionHighWater
+	highWaterLock    sync.Mutex
+
+	// The client that the main loop is using, for the offset lookups from the HTTP server. It is replaced when the
+	// servers-srv record resolves to different brokers. A lookup holds the read lock while it uses the client, so that
+	// the old client is not closed under it
+	client     helpers.SaramaClient
+	clientLock sync.RWMutex
+}
+
+type partitionHighWater struct {
+	offset    int64
+	regressed bool
 }
 
 // Configure validates the configuration for the cluster. At minimum, there must be a list of servers provided for the
-// Kafka cluster, of the form host:port. Default values will be set for the intervals to use for refreshing offsets
-// (10 seconds) and topics (60 seconds). A missing, or bad, list of servers will cause this func to panic.
+// Kafka cluster, of the form host:port. Default values will be set for the intervals to use for refreshing offsets (10
+// seconds), topics (60 seconds), and the members of consumer groups (60 seconds). If topic-filter or topic-exclude are
+// set, only topics that match the filter and do not match the exclusion are tracked. If time-lag-refresh is set to a
+// number of seconds, the time lag of each consumer partition is fetched at that interval, by reading the timestamps of
+// the message at the committed offset and the newest message from the brokers, separately from the offset fetches, with
+// offset-fetch-concurrency groups (8 if it is not set) read from storage at once. This is off by default, as it fetches
+// a message for every partition of every group. Instead of the list of servers, servers-srv can be set to a DNS SRV
+// record (such as _kafka._tcp.example.com) that resolves to the brokers. If the record cannot be resolved, the list of
+// servers is used, if there is one. If servers-srv-refresh is set to a number of seconds, the record is resolved again
+// at that interval, and the client is reconnected if the brokers have changed. The cluster reports as unhealthy if the
+// broker offsets have not been fetched for health-threshold seconds, which defaults to three times the offset-refresh
+// (0 to disable). If fetch-oldest-offsets is true, the oldest offset retained for each partition is also fetched with
+// every offset refresh, so that consumers with commits that have fallen off the start of the log can be flagged. By
+// default, one offset request is sent to each broker for all the partitions that it leads, and all the brokers are
+// queried in parallel. If offset-fetch-concurrency is set, the partitions of each leader are split over as many
+// requests as the client profile allows to be in flight to a broker (Net.MaxOpenRequests), and that many workers send
+// the requests. If consumed-topics-only is true, only the topics that a consumer group in the cluster has committed
+// offsets for are tracked, and the set of topics is fetched from the group coordinators on every topic refresh, using
+// offset-fetch-concurrency workers (8 if it is not set). Internal topics, which are those with names that start with
+// two underscores (such as __consumer_offsets and __transaction_state), or that match internal-topic-pattern, are not
+// tracked unless ignore-internal-topics is set to false. The offsets topic that a kafka consumer module for the cluster
+// reads is always tracked, as the consumer stores its own position in that topic as the burrow-<name> group, which
+// storage drops without the broker offsets for the topic. A missing, or bad, list of servers, or an invalid regular
+// expression, will cause this func to panic.
 func (module *KafkaCluster) Configure(name, configRoot string) {
+	module.configureFrom(viper.GetViper(), name, configRoot)
+}
+
+// configureFrom is the same as Configure, except that the configuration is read from the provided viper instance, and
+// the defaults are set on it. The modules for discovered clusters are configured this way, as the global configuration
+// is not changed once Burrow is running.
+func (module *KafkaCluster) configureFrom(config *viper.Viper, name, configRoot string) {
 	module.Log.Info("configuring")
 
 	module.name = name
 	module.quitChannel = make(chan struct{})
 	module.running = sync.WaitGroup{}
+	module.highWaterOffsets = make(map[string]map[int32]*partitionHighWater)
 
-	profile := viper.GetString(configRoot + ".client-profile")
-	module.saramaConfig = helpers.GetSaramaConfigFromClientProfile(profile)
+	module.clientProfile = config.GetString(configRoot + ".client-profile")
+	module.saramaConfig = helpers.GetSaramaConfigFromClientProfileIn(config, module.clientProfile)
+	helpers.LogSaramaConfig(module.Log, module.clientProfile, module.saramaConfig)
 
-	module.servers = viper.GetStringSlice(configRoot + ".servers")
-	if len(module.servers) == 0 {
+	module.staticServers = config.GetStringSlice(configRoot + ".servers")
+	module.serversSRV = config.GetString(configRoot + ".servers-srv")
+	if (len(module.staticServers) == 0) && (module.serversSRV == "") {
 		panic("No Kafka brokers specified for cluster " + module.name)
-	} else if !helpers.ValidateHostList(module.servers) {
+	} else if !helpers.ValidateHostList(module.staticServers) {
 		panic("Cluster '" + name + "' has one or more improperly formatted servers (must be host:port)")
 	}
 
+	module.servers = module.staticServers
+	if module.serversSRV != "" {
+		if module.lookupSRVFunc == nil {
+			module.lookupSRVFunc = helpers.LookupSRVServers
+		}
+		servers, err := module.lookupSRVFunc(module.serversSRV)
+		if err != nil {
+			if len(module.staticServers) == 0 {
+				panic("Cluster '" + name + "' cannot resolve servers-srv and has no servers: " + err.Error())
+			}
+			module.Log.Warn("failed to resolve servers-srv, using servers", zap.String("record", module.serversSRV), zap.Error(err))
+		} else {
+			module.Log.Info("resolved servers", zap.String("record", module.serversSRV), zap.Strings("servers", servers))
+			module.servers = servers
+		}
+	}
+
 	// Set defaults for configs if needed
-	viper.SetDefault(configRoot+".offset-refresh", 10)
-	viper.SetDefault(configRoot+".topic-refresh", 60)
-	viper.SetDefault(configRoot+".groups-reaper-refresh", 0)
-	module.offsetRefresh = viper.GetInt(configRoot + ".offset-refresh")
-	module.topicRefresh = viper.GetInt(configRoot + ".topic-refresh")
-	module.groupsReaperRefresh = viper.GetInt(configRoot + ".groups-reaper-refresh")
+	config.SetDefault(configRoot+".offset-refresh", 10)
+	config.SetDefault(configRoot+".topic-refresh", 60)
+	config.SetDefault(configRoot+".groups-reaper-refresh", 0)
+	config.SetDefault(configRoot+".group-members-refresh", 60)
+	config.SetDefault(configRoot+".time-lag-refresh", 0)
+	config.SetDefault(configRoot+".servers-srv-refresh", 0)
+	config.SetDefault(configRoot+".fetch-oldest-offsets", false)
+	config.SetDefault(configRoot+".offset-fetch-concurrency", 0)
+	config.SetDefault(configRoot+".consumed-topics-only", false)
+	module.offsetRefresh = config.GetInt(configRoot + ".offset-refresh")
+	module.topicRefresh = config.GetInt(configRoot + ".topic-refresh")
+	module.groupsReaperRefresh = config.GetInt(configRoot + ".groups-reaper-refresh")
+	module.groupMembersRefresh = config.GetInt(configRoot + ".group-members-refresh")
+	module.timeLagRefresh = config.GetInt(configRoot + ".time-lag-refresh")
+	module.serversSRVRefresh = config.GetInt(configRoot + ".servers-srv-refresh")
+	module.fetchOldestOffsets = config.GetBool(configRoot + ".fetch-oldest-offsets")
+	module.fetchConcurrency = config.GetInt(configRoot + ".offset-fetch-concurrency")
+	module.consumedTopicsOnly = config.GetBool(configRoot + ".consumed-topics-only")
+	if module.fetchConcurrency < 0 {
+		panic("Cluster '" + name + "' must not have a negative offset-fetch-concurrency")
+	}
+	if module.serversSRVRefresh < 0 {
+		panic("Cluster '" + name + "' must not have a negative servers-srv-refresh")
+	}
+	config.SetDefault(configRoot+".health-threshold", 3*module.offsetRefresh)
+	module.healthThreshold = config.GetInt(configRoot + ".health-threshold")
+	if module.healthThreshold < 0 {
+		panic("Cluster '" + name + "' must not have a negative health-threshold")
+	}
+
+	module.configRoot = configRoot
+	if err := module.ReloadFilters(config); err != nil {
+		module.Log.Panic(err.Error())
+		panic(err)
+	}
+}
+
+// CheckConnection connects to the Kafka cluster with the configured servers and client profile, fetches the metadata
+// for the cluster, and disconnects again. Any error is returned to the caller.
+func (module *KafkaCluster) CheckConnection() error {
+	return helpers.CheckKafkaConnection(module.Log, module.clientProfile, module.servers, module.saramaConfig)
 }
 
 // Start connects to the Kafka cluster using the Shopify/sarama client. Any error connecting to the cluster is returned
@@ -87,11 +217,19 @@ func (module *KafkaCluster) Start() error {
 	module.Log.Info("starting")
 
 	// Connect Kafka client
+	helpers.DetectKafkaVersion(module.Log, module.clientProfile, module.servers, module.saramaConfig)
 	client, err := sarama.NewClient(module.servers, module.saramaConfig)
 	if err != nil {
 		module.Log.Error("failed to start client", zap.Error(err))
 		return err
 	}
+	httpserver.RegisterSaramaMetrics(module.name, "cluster", module.saramaConfig.MetricRegistry)
+
+	// Listing the consumer groups needs the same version as the groups reaper
+	if module.consumedTopicsOnly && !module.saramaConfig.Version.IsAtLeast(sarama.V0_11_0_0) {
+		module.consumedTopicsOnly = false
+		module.Log.Warn("consumed-topics-only disabled, it needs at least kafka v0.11.0.0 to get the list of consumer groups")
+	}
 
 	// Fire off the offset requests once, before we start the ticker, to make sure we start with good data for consumers
 	helperClient := &helpers.BurrowSaramaClient{
@@ -99,6 +237,12 @@ func (module *KafkaCluster) Start() error {
 	}
 	module.fetchMetadata = true
 	module.getOffsets(helperClient)
+	module.updateClusterBrokers(helperClient)
+	if module.healthThreshold != 0 {
+		module.App.Health.Register("cluster."+module.name, module.heartbeat.Check(time.Duration(module.healthThreshold)*time.Second))
+	}
+	module.setClient(helperClient)
+	module.App.OffsetLookups.Register(module.name, module.lookupOffsets)
 
 	// Start main loop that has a timer for offset and topic fetches
 	module.offsetTicker = time.NewTicker(time.Duration(module.offsetRefresh) * time.Second)
@@ -116,6 +260,42 @@ func (module *KafkaCluster) Start() error {
 		module.groupsReaperTicker = time.NewTicker(1 * time.Minute)
 		module.groupsReaperTicker.Stop()
 	}
+
+	// The group members are described in the same way, and this can also be disabled by setting the refresh to 0
+	if module.groupMembersRefresh != 0 {
+		module.groupMembersTicker = time.NewTicker(time.Duration(module.groupMembersRefresh) * time.Second)
+		if !module.saramaConfig.Version.IsAtLeast(sarama.V0_9_0_0) {
+			module.groupMembersTicker.Stop()
+			module.Log.Warn("group members disabled, it needs at least kafka v0.9.0.0 to describe consumer groups")
+		}
+	} else {
+		module.groupMembersTicker = time.NewTicker(1 * time.Minute)
+		module.groupMembersTicker.Stop()
+	}
+
+	// Time lag is only fetched if it is enabled, and the messages must have timestamps for it to be calculated. As it
+	// reads a message for every lagging partition, it has its own loop, so that it does not hold up the offset fetches
+	if module.timeLagRefresh != 0 {
+		module.timeLagTicker = time.NewTicker(time.Duration(module.timeLagRefresh) * time.Second)
+		if module.saramaConfig.Version.IsAtLeast(sarama.V0_10_0_0) {
+			module.running.Add(1)
+			go module.timeLagLoop()
+		} else {
+			module.timeLagTicker.Stop()
+			module.Log.Warn("time lag disabled, it needs at least kafka v0.10.0.0 for message timestamps")
+		}
+	} else {
+		module.timeLagTicker = time.NewTicker(1 * time.Minute)
+		module.timeLagTicker.Stop()
+	}
+
+	// The SRV record is only resolved again if it is set, and a refresh interval is configured
+	if (module.serversSRV != "") && (module.serversSRVRefresh != 0) {
+		module.serversSRVTicker = time.NewTicker(time.Duration(module.serversSRVRefresh) * time.Second)
+	} else {
+		module.serversSRVTicker = time.NewTicker(1 * time.Minute)
+		module.serversSRVTicker.Stop()
+	}
 	go module.mainLoop(helperClient)
 
 	return nil
@@ -125,11 +305,17 @@ func (module *KafkaCluster) Start() error {
 func (module *KafkaCluster) Stop() error {
 	module.Log.Info("stopping")
 
+	module.App.Health.Unregister("cluster." + module.name)
+	module.App.OffsetLookups.Unregister(module.name)
 	module.metadataTicker.Stop()
 	module.offsetTicker.Stop()
 	module.groupsReaperTicker.Stop()
+	module.groupMembersTicker.Stop()
+	module.timeLagTicker.Stop()
+	module.serversSRVTicker.Stop()
 	close(module.quitChannel)
 	module.running.Wait()
+	httpserver.UnregisterSaramaMetrics(module.name, "cluster")
 
 	return nil
 }
@@ -145,14 +331,192 @@ func (module *KafkaCluster) mainLoop(client helpers.SaramaClient) {
 		case <-module.metadataTicker.C:
 			// Update metadata on next offset fetch
 			module.fetchMetadata = true
+			module.updateClusterBrokers(client)
 		case <-module.groupsReaperTicker.C:
 			module.reapNonExistingGroups(client)
+		case <-module.groupMembersTicker.C:
+			module.describeGroupMembers(client)
+		case <-module.serversSRVTicker.C:
+			client = module.refreshServers(client)
+		case <-module.quitChannel:
+			return
+		}
+	}
+}
+
+// timeLagLoop fetches the time lags on every tick of the time lag ticker, until the module is stopped. It uses the
+// current client of the main loop, holding the read lock while it does, so that the client is not closed under it.
+func (module *KafkaCluster) timeLagLoop() {
+	defer module.running.Done()
+
+	for {
+		select {
+		case <-module.timeLagTicker.C:
+			module.clientLock.RLock()
+			module.fetchTimeLags(module.client)
+			module.clientLock.RUnlock()
 		case <-module.quitChannel:
 			return
 		}
 	}
 }
 
+// refreshServers resolves the servers-srv record again, and if the brokers have changed, connects a new client to them
+// and closes the current one. The client to use from now on is returned. If the record cannot be resolved, or the new
+// client cannot connect, the current client is kept, and the brokers are tried again on the next refresh.
+func (module *KafkaCluster) refreshServers(client helpers.SaramaClient) helpers.SaramaClient {
+	servers, err := module.lookupSRVFunc(module.serversSRV)
+	if err != nil {
+		module.Log.Warn("failed to resolve servers-srv, keeping current servers", zap.String("record", module.serversSRV), zap.Error(err))
+		return client
+	}
+	if sameServers(servers, module.servers) {
+		return client
+	}
+
+	module.Log.Info("servers changed, reconnecting", zap.String("record", module.serversSRV), zap.Strings("servers", servers))
+	newClient, err := sarama.NewClient(servers, module.saramaConfig)
+	if err != nil {
+		module.Log.Error("failed to start client for new servers", zap.Error(err))
+		return client
+	}
+	helperClient := &helpers.BurrowSaramaClient{
+		Client: newClient,
+	}
+	module.replaceClient(helperClient, client)
+	module.servers = servers
+	module.fetchMetadata = true
+	return helperClient
+}
+
+func (module *KafkaCluster) setClient(client helpers.SaramaClient) {
+	module.clientLock.Lock()
+	defer module.clientLock.Unlock()
+	module.client = client
+}
+
+// replaceClient swaps in the new client, then closes the old one. Taking the write lock for the swap waits for any
+// lookup that is using the old client to finish, so once it is swapped, nothing else can be using the old client.
+func (module *KafkaCluster) replaceClient(client, oldClient helpers.SaramaClient) {
+	module.setClient(client)
+	oldClient.Close()
+}
+
+// lookupOffsets is the protocol.OffsetLookup for the cluster. It sends a request for the offsets of all partitions of
+// the topic at the time to the leader of each partition, using the same client as the main loop. If some leaders fail,
+// the offsets from the others are still returned, along with the error. If the client is set up for a Kafka version
+// before 0.10.1.0, protocol.ErrOffsetLookupUnsupported is returned without sending any requests.
+func (module *KafkaCluster) lookupOffsets(topic string, timestamp int64) (map[int32]int64, error) {
+	module.clientLock.RLock()
+	defer module.clientLock.RUnlock()
+	client := module.client
+	if client == nil {
+		return nil, errors.New("cluster is not connected")
+	}
+	if helpers.OffsetRequestVersion(client.Config().Version) == 0 {
+		return nil, protocol.ErrOffsetLookupUnsupported
+	}
+
+	partitions, err := client.Partitions(topic)
+	if err != nil {
+		return nil, err
+	}
+	requests := map[string]map[int32]int64{topic: make(map[int32]int64, len(partitions))}
+	for _, partition := range partitions {
+		requests[topic][partition] = timestamp
+	}
+	offsets, err := client.GetOffsets(requests)
+	return offsets[topic], err
+}
+
+// sameServers returns true if the two lists have the same servers, in any order
+func sameServers(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	counts := make(map[string]int, len(a))
+	for _, server := range a {
+		counts[server]++
+	}
+	for _, server := range b {
+		if counts[server] == 0 {
+			return false
+		}
+		counts[server]--
+	}
+	return true
+}
+
+// ReloadFilters compiles the topic-filter, topic-exclude, and internal-topic-pattern configurations for the cluster, and
+// replaces the current filters with them, along with the ignore-internal-topics setting and the offsets topics of the
+// consumer modules for the cluster. If any of them is invalid, an error is returned and the current filters are kept.
+// The new filters are used on the next topic refresh, and topics that no longer pass are removed from storage then.
+func (module *KafkaCluster) ReloadFilters(config *viper.Viper) error {
+	topicFilter, err := helpers.CompileConfigRegexp(config, module.configRoot+".topic-filter")
+	if err != nil {
+		return err
+	}
+	topicExclude, err := helpers.CompileConfigRegexp(config, module.configRoot+".topic-exclude")
+	if err != nil {
+		return err
+	}
+	internalTopics, err := helpers.CompileConfigRegexp(config, module.configRoot+".internal-topic-pattern")
+	if err != nil {
+		return err
+	}
+	ignoreInternal := true
+	if config.IsSet(module.configRoot + ".ignore-internal-topics") {
+		ignoreInternal = config.GetBool(module.configRoot + ".ignore-internal-topics")
+	}
+
+	module.filterLock.Lock()
+	defer module.filterLock.Unlock()
+	module.topicFilter = topicFilter
+	module.topicExclude = topicExclude
+	module.ignoreInternal = ignoreInternal
+	module.internalTopics = internalTopics
+	module.offsetsTopics = consumerOffsetsTopics(config, module.name)
+	return nil
+}
+
+// consumerOffsetsTopics returns the offsets topics that are read by the kafka consumer modules for the cluster
+func consumerOffsetsTopics(config *viper.Viper, cluster string) map[string]bool {
+	topics := make(map[string]bool)
+	for name := range config.GetStringMap("consumer") {
+		configRoot := "consumer." + name
+		if (config.GetString(configRoot+".class-name") != "kafka") || !strings.EqualFold(config.GetString(configRoot+".cluster"), cluster) {
+			continue
+		}
+		topic := config.GetString(configRoot + ".offsets-topic")
+		if topic == "" {
+			topic = "__consumer_offsets"
+		}
+		topics[topic] = true
+	}
+	return topics
+}
+
+func (module *KafkaCluster) acceptTopic(topic string) bool {
+	module.filterLock.RLock()
+	defer module.filterLock.RUnlock()
+
+	if (module.topicFilter != nil) && (!module.topicFilter.MatchString(topic)) {
+		return false
+	}
+	if (module.topicExclude != nil) && module.topicExclude.MatchString(topic) {
+		return false
+	}
+	if module.ignoreInternal && !module.offsetsTopics[topic] {
+		if strings.HasPrefix(topic, "__") {
+			return false
+		}
+		if (module.internalTopics != nil) && module.internalTopics.MatchString(topic) {
+			return false
+		}
+	}
+	return true
+}
+
 func (module *KafkaCluster) maybeUpdateMetadataAndDeleteTopics(client helpers.SaramaClient) {
 	if module.fetchMetadata {
 		module.fetchMetadata = false
@@ -165,9 +529,22 @@ func (module *KafkaCluster) maybeUpdateMetadataAndDeleteTopics(client helpers.Sa
 			return
 		}
 
+		var consumedTopics map[string]struct{}
+		if module.consumedTopicsOnly {
+			consumedTopics = module.refreshConsumedTopics(client)
+		}
+
 		// We'll use topicPartitions later
 		topicPartitions := make(map[string][]int32)
 		for _, topic := range topicList {
+			if !module.acceptTopic(topic) {
+				continue
+			}
+			if consumedTopics != nil {
+				if _, ok := consumedTopics[topic]; !ok {
+					continue
+				}
+			}
 			partitions, err := client.Partitions(topic)
 			if err != nil {
 				module.Log.Error("failed to fetch partition list", zap.String("sarama_error", err.Error()))
@@ -200,6 +577,10 @@ func (module *KafkaCluster) maybeUpdateMetadataAndDeleteTopics(client helpers.Sa
 						Topic:       topic,
 					}
 					httpserver.DeleteTopicMetrics(module.name, topic)
+
+					module.highWaterLock.Lock()
+					delete(module.highWaterOffsets, topic)
+					module.highWaterLock.Unlock()
 				}
 			}
 		}
@@ -209,9 +590,89 @@ func (module *KafkaCluster) maybeUpdateMetadataAndDeleteTopics(client helpers.Sa
 	}
 }
 
-func (module *KafkaCluster) generateOffsetRequests(client helpers.SaramaClient) (map[int32]*sarama.OffsetRequest, map[int32]helpers.SaramaBroker) {
-	requests := make(map[int32]*sarama.OffsetRequest)
+// consumedTopicsWorkers is the number of consumer groups that refreshConsumedTopics fetches the offsets of at once, if
+// offset-fetch-concurrency is not set
+const consumedTopicsWorkers = 8
+
+// refreshConsumedTopics fetches the committed offsets of every consumer group in the cluster from the group
+// coordinators, and saves the set of topics that they are for. If the groups cannot all be listed, or the offsets of
+// any group cannot be fetched, the topics from the previous refresh are kept in the set as well, so that topics are not
+// dropped because of a failed broker. The set is returned, and is nil if it has never been fetched successfully, in which
+// case all topics should be tracked. The offsets are fetched by a pool of offset-fetch-concurrency workers, or of
+// consumedTopicsWorkers if that is not set.
+func (module *KafkaCluster) refreshConsumedTopics(client helpers.SaramaClient) map[string]struct{} {
+	groups, err := client.ListConsumerGroups()
+	if (err != nil) && (len(groups) == 0) {
+		module.Log.Warn("failed to get the list of consumer groups, keeping the consumed topics", zap.Error(err))
+		return module.consumedTopics
+	}
+	failed := err != nil
+
+	workers := consumedTopicsWorkers
+	if module.fetchConcurrency > 0 {
+		workers = module.fetchConcurrency
+	}
+	if workers > len(groups) {
+		workers = len(groups)
+	}
+	groupChannel := make(chan string, len(groups))
+	for group := range groups {
+		groupChannel <- group
+	}
+	close(groupChannel)
+
+	consumedTopics := make(map[string]struct{})
+	resultLock := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for group := range groupChannel {
+				offsets, err := client.FetchConsumerOffsets(group, nil)
+
+				resultLock.Lock()
+				if err != nil {
+					module.Log.Debug("failed to fetch consumer offsets", zap.String("group", group), zap.Error(err))
+					failed = true
+				}
+				for topic := range offsets {
+					consumedTopics[topic] = struct{}{}
+				}
+				resultLock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if failed {
+		if module.consumedTopics == nil {
+			module.Log.Warn("failed to fetch the topics for all consumer groups, tracking all topics")
+			return nil
+		}
+		module.Log.Warn("failed to fetch the topics for all consumer groups, keeping the consumed topics")
+		for topic := range module.consumedTopics {
+			consumedTopics[topic] = struct{}{}
+		}
+	}
+	module.consumedTopics = consumedTopics
+	module.Log.Debug("fetched consumed topics", zap.Int("groups", len(groups)), zap.Int("topics", len(consumedTopics)))
+	return consumedTopics
+}
+
+// generateOffsetRequests builds the OffsetRequests for each leader broker, covering all the partitions that it leads.
+// If offset-fetch-concurrency is set, the partitions are spread over up to Net.MaxOpenRequests requests per broker, so
+// that they can be in flight at the same time. Otherwise, there is a single request per broker. The offsetTime is
+// either sarama.OffsetNewest or sarama.OffsetOldest.
+func (module *KafkaCluster) generateOffsetRequests(client helpers.SaramaClient, offsetTime int64) (map[int32][]*sarama.OffsetRequest, map[int32]helpers.SaramaBroker) {
+	requests := make(map[int32][]*sarama.OffsetRequest)
 	brokers := make(map[int32]helpers.SaramaBroker)
+	partitionCount := make(map[int32]int)
+
+	requestsPerBroker := 1
+	if (module.fetchConcurrency > 0) && (client.Config().Net.MaxOpenRequests > 1) {
+		requestsPerBroker = client.Config().Net.MaxOpenRequests
+	}
 
 	// Generate an OffsetRequest for each topic:partition and bucket it to the leader broker
 	for topic, partitions := range module.topicPartitions {
@@ -225,53 +686,49 @@ func (module *KafkaCluster) generateOffsetRequests(client helpers.SaramaClient)
 				module.fetchMetadata = true
 				continue
 			}
-			if _, ok := requests[broker.ID()]; !ok {
-				requests[broker.ID()] = &sarama.OffsetRequest{}
-				// Match the version of the client as sarama's getOffset function does
-				// https://github.com/IBM/sarama/blob/main/client.go#L863-L876
-				if client.Config().Version.IsAtLeast(sarama.V2_1_0_0) {
-					// Version 4 adds the current leader epoch, which is used for fencing.
-					requests[broker.ID()].Version = 4
-				} else if client.Config().Version.IsAtLeast(sarama.V2_0_0_0) {
-					// Version 3 is the same as version 2.
-					requests[broker.ID()].Version = 3
-				} else if client.Config().Version.IsAtLeast(sarama.V0_11_0_0) {
-					// Version 2 adds the isolation level, which is used for transactional reads.
-					requests[broker.ID()].Version = 2
-				} else if client.Config().Version.IsAtLeast(sarama.V0_10_1_0) {
-					// Version 1 removes MaxNumOffsets.  From this version forward, only a single
-					// offset can be returned.
-					requests[broker.ID()].Version = 1
-				}
+			// Partitions are assigned to the broker's requests round robin, adding requests until there are enough
+			brokerID := broker.ID()
+			index := partitionCount[brokerID] % requestsPerBroker
+			if index == len(requests[brokerID]) {
+				requests[brokerID] = append(requests[brokerID], &sarama.OffsetRequest{Version: helpers.OffsetRequestVersion(client.Config().Version)})
 			}
-			brokers[broker.ID()] = broker
-			requests[broker.ID()].AddBlock(topic, partitionID, sarama.OffsetNewest, 1)
+			partitionCount[brokerID]++
+			brokers[brokerID] = broker
+			requests[brokerID][index].AddBlock(topic, partitionID, offsetTime, 1)
 		}
 	}
 
 	return requests, brokers
 }
 
+// offsetFetch is a single OffsetRequest to send to a broker, for either the newest or the oldest offsets
+type offsetFetch struct {
+	broker  helpers.SaramaBroker
+	request *sarama.OffsetRequest
+	oldest  bool
+}
+
 // This function performs massively parallel OffsetRequests, which is better than Sarama's internal implementation,
 // which does one at a time. Several orders of magnitude faster.
 func (module *KafkaCluster) getOffsets(client helpers.SaramaClient) {
+	start := time.Now()
 	module.maybeUpdateMetadataAndDeleteTopics(client)
-	requests, brokers := module.generateOffsetRequests(client)
+	requests, brokers := module.generateOffsetRequests(client, sarama.OffsetNewest)
 
 	// Send out the OffsetRequest to each broker for all the partitions it is leader for
 	// The results go to the offset storage module
 	var wg = sync.WaitGroup{}
 	var errorTopics = sync.Map{}
 
-	getBrokerOffsets := func(brokerID int32, request *sarama.OffsetRequest) {
-		defer wg.Done()
-		response, err := brokers[brokerID].GetAvailableOffsets(request)
+	getBrokerOffsets := func(fetch *offsetFetch) {
+		brokerID := fetch.broker.ID()
+		response, err := fetch.broker.GetAvailableOffsets(fetch.request)
 		if err != nil {
 			module.Log.Error("failed to fetch offsets from broker",
 				zap.String("sarama_error", err.Error()),
 				zap.Int32("broker", brokerID),
 			)
-			brokers[brokerID].Close()
+			fetch.broker.Close()
 			return
 		}
 		ts := time.Now().Unix() * 1000
@@ -289,6 +746,16 @@ func (module *KafkaCluster) getOffsets(client helpers.SaramaClient) {
 					errorTopics.Store(topic, true)
 					continue
 				}
+				if fetch.oldest {
+					helpers.TimeoutSendStorageRequest(module.App.StorageChannel, &protocol.StorageRequest{
+						RequestType: protocol.StorageSetBrokerLogStart,
+						Cluster:     module.name,
+						Topic:       topic,
+						Partition:   partition,
+						Offset:      offsetResponse.Offsets[0],
+					}, 1)
+					continue
+				}
 				offset := &protocol.StorageRequest{
 					RequestType:         protocol.StorageSetBrokerOffset,
 					Cluster:             module.name,
@@ -297,18 +764,54 @@ func (module *KafkaCluster) getOffsets(client helpers.SaramaClient) {
 					Offset:              offsetResponse.Offsets[0],
 					Timestamp:           ts,
 					TopicPartitionCount: int32(cap(module.topicPartitions[topic])),
+					Regressed:           module.checkOffsetRegression(topic, partition, offsetResponse.Offsets[0]),
 				}
 				helpers.TimeoutSendStorageRequest(module.App.StorageChannel, offset, 1)
 			}
 		}
 	}
 
-	for brokerID, request := range requests {
+	fetches := make([]*offsetFetch, 0, len(requests))
+	for brokerID, brokerRequests := range requests {
+		for _, request := range brokerRequests {
+			fetches = append(fetches, &offsetFetch{broker: brokers[brokerID], request: request})
+		}
+	}
+
+	// The oldest offsets are only used to flag commits that are below the start of the log, so they are a separate set
+	// of requests, and a failure to fetch them does not affect the newest offsets
+	if module.fetchOldestOffsets {
+		oldestRequests, oldestBrokers := module.generateOffsetRequests(client, sarama.OffsetOldest)
+		for brokerID, brokerRequests := range oldestRequests {
+			for _, request := range brokerRequests {
+				fetches = append(fetches, &offsetFetch{broker: oldestBrokers[brokerID], request: request, oldest: true})
+			}
+		}
+	}
+
+	// Without a concurrency limit, every request gets its own goroutine. Otherwise, a pool of workers sends them
+	workers := len(fetches)
+	if (module.fetchConcurrency > 0) && (module.fetchConcurrency < workers) {
+		workers = module.fetchConcurrency
+	}
+	fetchChannel := make(chan *offsetFetch, len(fetches))
+	for _, fetch := range fetches {
+		fetchChannel <- fetch
+	}
+	close(fetchChannel)
+	for i := 0; i < workers; i++ {
 		wg.Add(1)
-		go getBrokerOffsets(brokerID, request)
+		go func() {
+			defer wg.Done()
+			for fetch := range fetchChannel {
+				getBrokerOffsets(fetch)
+			}
+		}()
 	}
 
 	wg.Wait()
+	module.heartbeat.Beat()
+	httpserver.ObserveOffsetFetchDuration(module.name, time.Since(start))
 
 	// If there are any topics that had errors, force a metadata refresh on the next run
 	errorTopics.Range(func(key, value interface{}) bool {
@@ -317,7 +820,66 @@ func (module *KafkaCluster) getOffsets(client helpers.SaramaClient) {
 	})
 }
 
+// checkOffsetRegression records the broker offset for the partition, and returns true if it is lower than the highest
+// offset seen before. This happens after an unclean leader election, or if the partition was restored from a backup. The
+// partition stays regressed until the offset gets back to where it was, but the warning is only logged once.
+func (module *KafkaCluster) checkOffsetRegression(topic string, partition int32, offset int64) bool {
+	module.highWaterLock.Lock()
+	defer module.highWaterLock.Unlock()
+
+	partitions, ok := module.highWaterOffsets[topic]
+	if !ok {
+		partitions = make(map[int32]*partitionHighWater)
+		module.highWaterOffsets[topic] = partitions
+	}
+
+	highWater, ok := partitions[partition]
+	if !ok || (offset >= highWater.offset) {
+		if ok && highWater.regressed {
+			module.Log.Info("broker offset recovered",
+				zap.String("topic", topic),
+				zap.Int32("partition", partition),
+				zap.Int64("offset", offset),
+			)
+		}
+		partitions[partition] = &partitionHighWater{offset: offset}
+		return false
+	}
+
+	if !highWater.regressed {
+		highWater.regressed = true
+		module.Log.Warn("broker offset regressed",
+			zap.String("topic", topic),
+			zap.Int32("partition", partition),
+			zap.Int64("offset", offset),
+			zap.Int64("previous_offset", highWater.offset),
+		)
+	}
+	return true
+}
+
+// updateClusterBrokers sends the controller and the number of brokers from the cluster metadata to storage. If the
+// cluster has no controller, this is logged, and the controller ID is sent as -1.
+func (module *KafkaCluster) updateClusterBrokers(client helpers.SaramaClient) {
+	controllerID := int32(-1)
+	controller, err := client.Controller()
+	if err != nil {
+		module.Log.Warn("failed to fetch the controller", zap.String("sarama_error", err.Error()))
+	} else {
+		controllerID = controller.ID()
+	}
+
+	helpers.TimeoutSendStorageRequest(module.App.StorageChannel, &protocol.StorageRequest{
+		RequestType:  protocol.StorageSetClusterBrokers,
+		Cluster:      module.name,
+		ControllerID: controllerID,
+		BrokerCount:  int32(len(client.Brokers())),
+		Timestamp:    time.Now().Unix() * 1000,
+	}, 1)
+}
+
 func (module *KafkaCluster) reapNonExistingGroups(client helpers.SaramaClient) {
+	// A partial list of groups is not good enough here, as the groups coordinated by a failed broker would be removed
 	kafkaGroups, err := client.ListConsumerGroups()
 	if err != nil {
 		module.Log.Error("failed to get the list of available consumer groups", zap.Error(err))
@@ -357,3 +919,348 @@ func (module *KafkaCluster) reapNonExistingGroups(client helpers.SaramaClient) {
 		}
 	}
 }
+
+// describeGroupMembers fetches the list of consumer groups that are in storage for the cluster, and asks the group
+// coordinators to describe them. The number of members in each group is sent to storage, so that the evaluator can
+// tell groups with connected consumers from groups that have been stopped or abandoned.
+func (module *KafkaCluster) describeGroupMembers(client helpers.SaramaClient) {
+	req := &protocol.StorageRequest{
+		RequestType: protocol.StorageFetchConsumers,
+		Reply:       make(chan interface{}),
+		Cluster:     module.name,
+	}
+	helpers.TimeoutSendStorageRequest(module.App.StorageChannel, req, 20)
+
+	res := <-req.Reply
+	groups, _ := res.([]string)
+	if len(groups) == 0 {
+		return
+	}
+
+	memberCounts, err := client.DescribeConsumerGroups(groups)
+	if err != nil {
+		module.Log.Warn("failed to describe consumer groups", zap.Error(err))
+		return
+	}
+
+	now := time.Now().Unix() * 1000
+	for group, memberCount := range memberCounts {
+		helpers.TimeoutSendStorageRequest(module.App.StorageChannel, &protocol.StorageRequest{
+			RequestType: protocol.StorageSetConsumerMembers,
+			Cluster:     module.name,
+			Group:       group,
+			MemberCount: memberCount,
+			Timestamp:   now,
+		}, 1)
+	}
+	module.Log.Debug("described consumer groups", zap.Int("count", len(memberCounts)))
+}
+
+// timeLagWorkers is the number of consumer groups that fetchTimeLags fetches from storage at once, if
+// offset-fetch-concurrency is not set
+const timeLagWorkers = 8
+
+// fetchTimeLags calculates the time lag for every partition of the consumer groups that are in storage for the
+// cluster, and sends it to storage. The time lag is the difference between the timestamp of the newest message in the
+// partition (at the highest broker offset that has been fetched) and the timestamp of the message at the last committed
+// offset. Partitions where the message timestamps cannot be fetched are skipped. The groups are fetched from storage by
+// a pool of offset-fetch-concurrency workers, or of timeLagWorkers if that is not set.
+func (module *KafkaCluster) fetchTimeLags(client helpers.SaramaClient) {
+	req := &protocol.StorageRequest{
+		RequestType: protocol.StorageFetchConsumers,
+		Reply:       make(chan interface{}),
+		Cluster:     module.name,
+	}
+	helpers.TimeoutSendStorageRequest(module.App.StorageChannel, req, 20)
+
+	res := <-req.Reply
+	groups, _ := res.([]string)
+	if len(groups) == 0 {
+		return
+	}
+
+	endOffsets := make(map[string]map[int32]int64)
+	module.highWaterLock.Lock()
+	for topic, partitions := range module.highWaterOffsets {
+		endOffsets[topic] = make(map[int32]int64, len(partitions))
+		for partition, highWater := range partitions {
+			endOffsets[topic][partition] = highWater.offset
+		}
+	}
+	module.highWaterLock.Unlock()
+
+	workers := timeLagWorkers
+	if module.fetchConcurrency > 0 {
+		workers = module.fetchConcurrency
+	}
+	if workers > len(groups) {
+		workers = len(groups)
+	}
+	groupChannel := make(chan string, len(groups))
+	for _, group := range groups {
+		groupChannel <- group
+	}
+	close(groupChannel)
+
+	// Collect the committed offset for each partition of each group, and the offsets that need a message timestamp
+	committed := make(map[string]map[string]map[int32]int64)
+	wanted := make(map[string]map[int32]map[int64]struct{})
+	resultLock := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for group := range groupChannel {
+				req := &protocol.StorageRequest{
+					RequestType: protocol.StorageFetchConsumer,
+					Reply:       make(chan interface{}),
+					Cluster:     module.name,
+					Group:       group,
+				}
+				helpers.TimeoutSendStorageRequest(module.App.StorageChannel, req, 20)
+				topics, ok := (<-req.Reply).(protocol.ConsumerTopics)
+				if !ok {
+					continue
+				}
+
+				resultLock.Lock()
+				addTimeLagOffsets(group, topics, endOffsets, committed, wanted)
+				resultLock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	timestamps := module.fetchMessageTimestamps(client, wanted)
+
+	count := 0
+	for group, topics := range committed {
+		for topic, partitions := range topics {
+			for partition, offset := range partitions {
+				timeLag := int64(0)
+				if endOffset := endOffsets[topic][partition]; offset < endOffset {
+					committedTime, ok := timestamps[topic][partition][offset]
+					if !ok {
+						continue
+					}
+					newestTime, ok := timestamps[topic][partition][endOffset-1]
+					if !ok {
+						continue
+					}
+					if newestTime > committedTime {
+						timeLag = newestTime - committedTime
+					}
+				}
+				helpers.TimeoutSendStorageRequest(module.App.StorageChannel, &protocol.StorageRequest{
+					RequestType: protocol.StorageSetConsumerTimeLag,
+					Cluster:     module.name,
+					Group:       group,
+					Topic:       topic,
+					Partition:   partition,
+					Offset:      offset,
+					TimeLag:     timeLag,
+				}, 1)
+				count++
+			}
+		}
+	}
+	module.Log.Debug("fetched time lag", zap.Int("partitions", count))
+}
+
+// addTimeLagOffsets adds the last committed offset of each partition of the group to committed, along with the
+// offsets that a message timestamp is needed for to wanted. Partitions that do not have a broker offset are skipped.
+func addTimeLagOffsets(group string, topics protocol.ConsumerTopics, endOffsets map[string]map[int32]int64, committed map[string]map[string]map[int32]int64, wanted map[string]map[int32]map[int64]struct{}) {
+	committed[group] = make(map[string]map[int32]int64)
+	for topic, partitions := range topics {
+		for partitionID, partition := range partitions {
+			if (len(partition.Offsets) == 0) || (partition.Offsets[len(partition.Offsets)-1] == nil) {
+				continue
+			}
+			endOffset, ok := endOffsets[topic][int32(partitionID)]
+			if !ok {
+				continue
+			}
+			offset := partition.Offsets[len(partition.Offsets)-1].Offset
+			if _, ok := committed[group][topic]; !ok {
+				committed[group][topic] = make(map[int32]int64)
+			}
+			committed[group][topic][int32(partitionID)] = offset
+
+			if offset < endOffset {
+				if _, ok := wanted[topic]; !ok {
+					wanted[topic] = make(map[int32]map[int64]struct{})
+				}
+				if _, ok := wanted[topic][int32(partitionID)]; !ok {
+					wanted[topic][int32(partitionID)] = make(map[int64]struct{})
+				}
+				wanted[topic][int32(partitionID)][offset] = struct{}{}
+				wanted[topic][int32(partitionID)][endOffset-1] = struct{}{}
+			}
+		}
+	}
+}
+
+// The maximum number of bytes to fetch for each partition when reading a message timestamp. From Kafka 0.10.1, the
+// broker returns the first message even if it is larger than this
+const timeLagFetchBytes = 65536
+
+// fetchMessageTimestamps reads the message at each of the requested offsets from the leader of the partition, and
+// returns the timestamps of the messages in milliseconds, as a map of topic to partition ID to offset. As a fetch
+// request can only ask for one offset for each partition, the offsets are fetched in rounds, with the requests to each
+// broker sent in parallel. Messages that cannot be fetched, or that have no timestamp, are left out of the result.
+func (module *KafkaCluster) fetchMessageTimestamps(client helpers.SaramaClient, offsets map[string]map[int32]map[int64]struct{}) map[string]map[int32]map[int64]int64 {
+	type timestampRequest struct {
+		topic     string
+		partition int32
+		offsets   []int64
+	}
+
+	brokers := make(map[int32]helpers.SaramaBroker)
+	brokerPartitions := make(map[int32][]*timestampRequest)
+	for topic, partitions := range offsets {
+		for partitionID, partitionWanted := range partitions {
+			broker, err := client.Leader(topic, partitionID)
+			if err != nil {
+				module.Log.Warn("failed to fetch leader for partition",
+					zap.String("topic", topic),
+					zap.Int32("partition", partitionID),
+					zap.String("sarama_error", err.Error()))
+				continue
+			}
+			brokers[broker.ID()] = broker
+
+			entry := &timestampRequest{topic: topic, partition: partitionID, offsets: make([]int64, 0, len(partitionWanted))}
+			for offset := range partitionWanted {
+				entry.offsets = append(entry.offsets, offset)
+			}
+			brokerPartitions[broker.ID()] = append(brokerPartitions[broker.ID()], entry)
+		}
+	}
+
+	timestamps := make(map[string]map[int32]map[int64]int64)
+	var timestampsLock sync.Mutex
+	var wg = sync.WaitGroup{}
+
+	fetchBrokerTimestamps := func(brokerID int32, partitions []*timestampRequest) {
+		defer wg.Done()
+		for round := 0; ; round++ {
+			request := newTimeLagFetchRequest(client.Config().Version)
+			inRound := make([]*timestampRequest, 0, len(partitions))
+			for _, entry := range partitions {
+				if round < len(entry.offsets) {
+					request.AddBlock(entry.topic, entry.partition, entry.offsets[round], timeLagFetchBytes, -1)
+					inRound = append(inRound, entry)
+				}
+			}
+			if len(inRound) == 0 {
+				return
+			}
+
+			response, err := brokers[brokerID].Fetch(request)
+			if err != nil {
+				module.Log.Error("failed to fetch messages from broker",
+					zap.String("sarama_error", err.Error()),
+					zap.Int32("broker", brokerID),
+				)
+				brokers[brokerID].Close()
+				return
+			}
+
+			timestampsLock.Lock()
+			for _, entry := range inRound {
+				block := response.GetBlock(entry.topic, entry.partition)
+				if (block == nil) || (block.Err != sarama.ErrNoError) {
+					continue
+				}
+				timestamp, ok := getMessageTimestamp(block, entry.offsets[round])
+				if !ok {
+					continue
+				}
+				if _, ok := timestamps[entry.topic]; !ok {
+					timestamps[entry.topic] = make(map[int32]map[int64]int64)
+				}
+				if _, ok := timestamps[entry.topic][entry.partition]; !ok {
+					timestamps[entry.topic][entry.partition] = make(map[int64]int64)
+				}
+				timestamps[entry.topic][entry.partition][entry.offsets[round]] = timestamp
+			}
+			timestampsLock.Unlock()
+		}
+	}
+
+	for brokerID, partitions := range brokerPartitions {
+		wg.Add(1)
+		go fetchBrokerTimestamps(brokerID, partitions)
+	}
+	wg.Wait()
+
+	return timestamps
+}
+
+// newTimeLagFetchRequest returns an empty FetchRequest with the highest version that is needed for the client version.
+// Version 4 is needed to read record batches (Kafka 0.11 and later)
+func newTimeLagFetchRequest(version sarama.KafkaVersion) *sarama.FetchRequest {
+	request := &sarama.FetchRequest{
+		MinBytes: 1,
+		MaxBytes: sarama.MaxResponseSize,
+	}
+	if version.IsAtLeast(sarama.V0_11_0_0) {
+		request.Version = 4
+		request.Isolation = sarama.ReadUncommitted
+	} else if version.IsAtLeast(sarama.V0_10_1_0) {
+		request.Version = 3
+	} else {
+		request.Version = 2
+	}
+	return request
+}
+
+// getMessageTimestamp returns the timestamp, in milliseconds, of the first message in the fetch response block at or
+// after the offset. If the broker sets the timestamps when messages are appended to the log, that time is used. False
+// is returned if there is no such message, or if it has no timestamp.
+func getMessageTimestamp(block *sarama.FetchResponseBlock, offset int64) (int64, bool) {
+	for _, records := range block.RecordsSet {
+		if batch := records.RecordBatch; batch != nil {
+			for _, record := range batch.Records {
+				if batch.FirstOffset+record.OffsetDelta < offset {
+					continue
+				}
+				if batch.LogAppendTime {
+					return toMilliseconds(batch.MaxTimestamp)
+				}
+				return toMilliseconds(batch.FirstTimestamp.Add(record.TimestampDelta))
+			}
+		}
+
+		if records.MsgSet == nil {
+			continue
+		}
+		for _, wrapper := range records.MsgSet.Messages {
+			messages := wrapper.Messages()
+			for _, message := range messages {
+				// The offsets of compressed messages with a version of 1 or higher are relative to the wrapper, which
+				// has the offset of the last message in the set
+				messageOffset := message.Offset
+				if (wrapper.Msg.Set != nil) && (wrapper.Msg.Version >= 1) {
+					messageOffset = wrapper.Offset - messages[len(messages)-1].Offset + message.Offset
+				}
+				if messageOffset < offset {
+					continue
+				}
+				if wrapper.Msg.LogAppendTime {
+					return toMilliseconds(wrapper.Msg.Timestamp)
+				}
+				return toMilliseconds(message.Msg.Timestamp)
+			}
+		}
+	}
+	return 0, false
+}
+
+func toMilliseconds(timestamp time.Time) (int64, bool) {
+	if timestamp.IsZero() || (timestamp.UnixNano() <= 0) {
+		return 0, false
+	}
+	return timestamp.UnixNano() / int64(time.Millisecond), true
+}
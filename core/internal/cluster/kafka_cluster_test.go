@@ -11,6 +11,8 @@ package cluster
 
 import (
 	"errors"
+	"strconv"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -24,6 +26,7 @@ import (
 	"sync"
 
 	"github.com/linkedin/Burrow/core/internal/helpers"
+	"github.com/linkedin/Burrow/core/internal/storage"
 	"github.com/linkedin/Burrow/core/protocol"
 )
 
@@ -54,6 +57,20 @@ func TestKafkaCluster_Configure(t *testing.T) {
 	assert.NotNil(t, module.saramaConfig, "Expected saramaConfig to be populated")
 }
 
+func TestKafkaCluster_Configure_IPv6Servers(t *testing.T) {
+	module := fixtureModule()
+	servers := []string{"[2001:db8::1]:9092", "[::ffff:10.0.0.1]:9092", "[fe80::1%eth0]:9092"}
+	viper.Set("cluster.test.servers", servers)
+	module.Configure("test", "cluster.test")
+	assert.Equal(t, servers, module.servers, "Expected the IPv6 servers to be kept as they are")
+}
+
+func TestKafkaCluster_Configure_BadIPv6Server(t *testing.T) {
+	module := fixtureModule()
+	viper.Set("cluster.test.servers", []string{"2001:db8::1:9092"})
+	assert.Panics(t, func() { module.Configure("test", "cluster.test") }, "The code did not panic")
+}
+
 func TestKafkaCluster_Configure_DefaultIntervals(t *testing.T) {
 	module := fixtureModule()
 	module.Configure("test", "cluster.test")
@@ -61,6 +78,16 @@ func TestKafkaCluster_Configure_DefaultIntervals(t *testing.T) {
 	assert.Equal(t, int(10), module.offsetRefresh, "Default OffsetRefresh value of 10 did not get set")
 	assert.Equal(t, int(60), module.topicRefresh, "Default TopicRefresh value of 60 did not get set")
 	assert.Equal(t, int(0), module.groupsReaperRefresh, "Default GroupsReaperRefresh value of 0 did not get set")
+	assert.Equal(t, int(0), module.timeLagRefresh, "Default TimeLagRefresh value of 0 did not get set")
+	assert.Equal(t, int(30), module.healthThreshold, "Default HealthThreshold value of three times OffsetRefresh did not get set")
+	assert.False(t, module.fetchOldestOffsets, "Default FetchOldestOffsets value of false did not get set")
+	assert.Equal(t, int(0), module.fetchConcurrency, "Default FetchConcurrency value of 0 did not get set")
+}
+
+func TestKafkaCluster_Configure_BadFetchConcurrency(t *testing.T) {
+	module := fixtureModule()
+	viper.Set("cluster.test.offset-fetch-concurrency", -1)
+	assert.Panics(t, func() { module.Configure("test", "cluster.test") }, "The code did not panic")
 }
 
 func TestKafkaCluster_maybeUpdateMetadataAndDeleteTopics_NoUpdate(t *testing.T) {
@@ -94,6 +121,333 @@ func TestKafkaCluster_maybeUpdateMetadataAndDeleteTopics_NoDelete(t *testing.T)
 	assert.Equalf(t, 1, len(topic), "Expected testtopic to be recorded with 1 partition, not %v", len(topic))
 }
 
+func TestKafkaCluster_maybeUpdateMetadataAndDeleteTopics_TopicFilter(t *testing.T) {
+	module := fixtureModule()
+	viper.Set("cluster.test.topic-filter", "^test")
+	viper.Set("cluster.test.topic-exclude", "-dropped$")
+	module.Configure("test", "cluster.test")
+
+	// Only testtopic should have its partitions fetched
+	client := &helpers.MockSaramaClient{}
+	client.On("RefreshMetadata").Return(nil)
+	client.On("Topics").Return([]string{"testtopic", "othertopic", "testtopic-dropped"}, nil)
+	client.On("Partitions", "testtopic").Return([]int32{0}, nil)
+	client.On("Leader", "testtopic", int32(0)).Return(&helpers.MockSaramaBroker{}, nil)
+
+	module.fetchMetadata = true
+	module.maybeUpdateMetadataAndDeleteTopics(client)
+
+	client.AssertExpectations(t)
+	client.AssertNotCalled(t, "Partitions", "othertopic")
+	client.AssertNotCalled(t, "Partitions", "testtopic-dropped")
+	assert.Lenf(t, module.topicPartitions, 1, "Expected 1 topic entry, not %v", len(module.topicPartitions))
+	_, ok := module.topicPartitions["testtopic"]
+	assert.True(t, ok, "Expected to find testtopic in topicPartitions")
+}
+
+var acceptInternalTopicTests = []struct {
+	ignore   bool
+	pattern  string
+	topic    string
+	accepted bool
+}{
+	/*0*/ {true, "", "testtopic", true},
+	/*1*/ {true, "", "__consumer_offsets", false},
+	/*2*/ {true, "", "__transaction_state", false},
+	/*3*/ {true, "", "_schemas", true},
+	/*4*/ {true, "-changelog$", "app-store-changelog", false},
+	/*5*/ {true, "-changelog$", "testtopic", true},
+	/*6*/ {false, "-changelog$", "__consumer_offsets", true},
+	/*7*/ {false, "-changelog$", "app-store-changelog", true},
+}
+
+func TestKafkaCluster_acceptTopic_Internal(t *testing.T) {
+	for i, testSet := range acceptInternalTopicTests {
+		module := fixtureModule()
+		viper.Set("cluster.test.ignore-internal-topics", testSet.ignore)
+		viper.Set("cluster.test.internal-topic-pattern", testSet.pattern)
+		module.Configure("test", "cluster.test")
+
+		result := module.acceptTopic(testSet.topic)
+		assert.Equalf(t, testSet.accepted, result, "TEST %v: Expected acceptTopic for %v to return %v, not %v", i, testSet.topic, testSet.accepted, result)
+	}
+}
+
+func TestKafkaCluster_Configure_DefaultIgnoreInternalTopics(t *testing.T) {
+	module := fixtureModule()
+	module.Configure("test", "cluster.test")
+	assert.True(t, module.ignoreInternal, "Default IgnoreInternalTopics value of true did not get set")
+	assert.False(t, module.acceptTopic("__consumer_offsets"), "Expected __consumer_offsets to not be accepted")
+}
+
+func TestKafkaCluster_acceptTopic_ConsumerOffsetsTopic(t *testing.T) {
+	module := fixtureModule()
+	viper.Set("consumer.local.class-name", "kafka")
+	viper.Set("consumer.local.cluster", "test")
+	viper.Set("consumer.mirrored.class-name", "kafka")
+	viper.Set("consumer.mirrored.cluster", "test")
+	viper.Set("consumer.mirrored.offsets-topic", "__mirrored_offsets")
+	viper.Set("consumer.other.class-name", "kafka")
+	viper.Set("consumer.other.cluster", "othercluster")
+	viper.Set("consumer.other.offsets-topic", "__other_offsets")
+	module.Configure("test", "cluster.test")
+
+	assert.True(t, module.acceptTopic("__consumer_offsets"), "Expected the default offsets topic to be accepted")
+	assert.True(t, module.acceptTopic("__mirrored_offsets"), "Expected a configured offsets topic to be accepted")
+	assert.False(t, module.acceptTopic("__other_offsets"), "Expected the offsets topic of another cluster to not be accepted")
+	assert.False(t, module.acceptTopic("__transaction_state"), "Expected other internal topics to not be accepted")
+}
+
+// The consumer module resumes from the position it stored for the burrow-<name> group, which storage only keeps if the
+// cluster module sends the broker offsets for the offsets topic
+func TestKafkaCluster_ResumeFromStorage_DefaultSettings(t *testing.T) {
+	module := fixtureModule()
+	viper.Set("consumer.local.class-name", "kafka")
+	viper.Set("consumer.local.cluster", "test")
+	viper.Set("consumer.local.resume-from-storage", true)
+	viper.Set("storage.test.class-name", "inmemory")
+	viper.Set("storage.test.workers", 1)
+	module.Configure("test", "cluster.test")
+
+	storageModule := &storage.InMemoryStorage{App: module.App, Log: zap.NewNop()}
+	storageModule.Configure("test", "storage.test")
+	assert.NoError(t, storageModule.Start(), "Expected storage to start")
+	defer storageModule.Stop()
+	module.App.StorageChannel = storageModule.GetCommunicationChannel()
+
+	offsetResponse := &sarama.OffsetResponse{Version: 1}
+	offsetResponse.AddTopicPartition("__consumer_offsets", 0, 100)
+	broker := &helpers.MockSaramaBroker{}
+	broker.On("ID").Return(int32(13))
+	broker.On("GetAvailableOffsets", mock.MatchedBy(func(request *sarama.OffsetRequest) bool { return request != nil })).Return(offsetResponse, nil)
+	client := &helpers.MockSaramaClient{}
+	client.On("RefreshMetadata").Return(nil)
+	client.On("Topics").Return([]string{"__consumer_offsets", "__transaction_state"}, nil)
+	client.On("Partitions", "__consumer_offsets").Return([]int32{0}, nil)
+	client.On("Leader", "__consumer_offsets", int32(0)).Return(broker, nil)
+	client.On("Config").Return(sarama.NewConfig())
+
+	module.fetchMetadata = true
+	module.getOffsets(client)
+	client.AssertNotCalled(t, "Partitions", "__transaction_state")
+
+	// The position that the consumer module stores for itself is kept
+	module.App.StorageChannel <- &protocol.StorageRequest{
+		RequestType: protocol.StorageSetConsumerOffset,
+		Cluster:     "test",
+		Topic:       "__consumer_offsets",
+		Partition:   0,
+		Group:       "burrow-local",
+		Timestamp:   time.Now().Unix() * 1000,
+		Offset:      50,
+		Order:       49,
+	}
+	request := &protocol.StorageRequest{
+		RequestType: protocol.StorageFetchConsumer,
+		Cluster:     "test",
+		Group:       "burrow-local",
+		Reply:       make(chan interface{}),
+	}
+	module.App.StorageChannel <- request
+	response, ok := (<-request.Reply).(protocol.ConsumerTopics)
+	assert.True(t, ok, "Expected the burrow-local group to be stored")
+	if assert.Len(t, response["__consumer_offsets"], 1, "Expected the stored position for __consumer_offsets") {
+		offsets := response["__consumer_offsets"][0].Offsets
+		assert.Equal(t, int64(50), offsets[len(offsets)-1].Offset, "Expected the stored position to be 50")
+	}
+}
+
+func TestKafkaCluster_Configure_BadInternalTopicPattern(t *testing.T) {
+	module := fixtureModule()
+	viper.Set("cluster.test.internal-topic-pattern", "[")
+	assert.Panics(t, func() { module.Configure("test", "cluster.test") }, "The code did not panic")
+}
+
+func TestKafkaCluster_maybeUpdateMetadataAndDeleteTopics_ConsumedTopicsOnly(t *testing.T) {
+	module := fixtureModule()
+	viper.Set("cluster.test.consumed-topics-only", true)
+	module.Configure("test", "cluster.test")
+
+	// Only testtopic has a consumer group committing to it
+	client := &helpers.MockSaramaClient{}
+	client.On("RefreshMetadata").Return(nil)
+	client.On("Topics").Return([]string{"testtopic", "othertopic"}, nil)
+	client.On("ListConsumerGroups").Return(map[string]string{"group1": "consumer", "group2": "consumer"}, nil)
+	client.On("FetchConsumerOffsets", "group1", map[string][]int32(nil)).Return(map[string]map[int32]int64{"testtopic": {0: 100}}, nil)
+	client.On("FetchConsumerOffsets", "group2", map[string][]int32(nil)).Return(map[string]map[int32]int64{}, nil)
+	client.On("Partitions", "testtopic").Return([]int32{0}, nil)
+	client.On("Leader", "testtopic", int32(0)).Return(&helpers.MockSaramaBroker{}, nil)
+
+	module.fetchMetadata = true
+	module.maybeUpdateMetadataAndDeleteTopics(client)
+
+	client.AssertExpectations(t)
+	client.AssertNotCalled(t, "Partitions", "othertopic")
+	assert.Lenf(t, module.topicPartitions, 1, "Expected 1 topic entry, not %v", len(module.topicPartitions))
+	_, ok := module.topicPartitions["testtopic"]
+	assert.True(t, ok, "Expected to find testtopic in topicPartitions")
+}
+
+func TestKafkaCluster_refreshConsumedTopics_Failed(t *testing.T) {
+	module := fixtureModule()
+	viper.Set("cluster.test.consumed-topics-only", true)
+	module.Configure("test", "cluster.test")
+
+	client := &helpers.MockSaramaClient{}
+	client.On("ListConsumerGroups").Return(map[string]string{"group1": "consumer", "group2": "consumer"}, nil)
+	client.On("FetchConsumerOffsets", "group1", map[string][]int32(nil)).Return(map[string]map[int32]int64{"testtopic": {0: 100}}, nil)
+	client.On("FetchConsumerOffsets", "group2", map[string][]int32(nil)).Return(map[string]map[int32]int64(nil), errors.New("no coordinator"))
+
+	// Before the topics have been fetched once, a failure means all topics are tracked
+	assert.Nil(t, module.refreshConsumedTopics(client), "Expected all topics to be tracked")
+
+	// After that, the topics from the previous refresh are kept
+	module.consumedTopics = map[string]struct{}{"othertopic": {}}
+	consumedTopics := module.refreshConsumedTopics(client)
+	assert.Equal(t, map[string]struct{}{"testtopic": {}, "othertopic": {}}, consumedTopics, "Expected the previous topics to be kept")
+	assert.Equal(t, consumedTopics, module.consumedTopics, "Expected the consumed topics to be saved")
+	client.AssertExpectations(t)
+}
+
+func TestKafkaCluster_refreshConsumedTopics_Concurrency(t *testing.T) {
+	module := fixtureModule()
+	viper.Set("cluster.test.consumed-topics-only", true)
+	viper.Set("cluster.test.offset-fetch-concurrency", 2)
+	module.Configure("test", "cluster.test")
+
+	// Each fetch waits a little, so that the number of fetches in flight at once can be seen
+	var inFlight, maxInFlight atomic.Int32
+	groups := make(map[string]string)
+	client := &helpers.MockSaramaClient{}
+	for i := 0; i < 6; i++ {
+		group := "group" + strconv.Itoa(i)
+		groups[group] = "consumer"
+		client.On("FetchConsumerOffsets", group, map[string][]int32(nil)).Run(func(args mock.Arguments) {
+			current := inFlight.Add(1)
+			for {
+				max := maxInFlight.Load()
+				if (current <= max) || maxInFlight.CompareAndSwap(max, current) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			inFlight.Add(-1)
+		}).Return(map[string]map[int32]int64{"topic" + strconv.Itoa(i%3): {0: 100}}, nil)
+	}
+	client.On("ListConsumerGroups").Return(groups, nil)
+
+	consumedTopics := module.refreshConsumedTopics(client)
+	assert.Equal(t, map[string]struct{}{"topic0": {}, "topic1": {}, "topic2": {}}, consumedTopics, "Expected the topics of every group")
+	assert.LessOrEqualf(t, maxInFlight.Load(), int32(2), "Expected at most 2 fetches at once, not %v", maxInFlight.Load())
+	client.AssertExpectations(t)
+}
+
+func TestKafkaCluster_Configure_BadTopicRegexp(t *testing.T) {
+	module := fixtureModule()
+	viper.Set("cluster.test.topic-filter", "[")
+	assert.Panics(t, func() { module.Configure("test", "cluster.test") }, "The code did not panic")
+}
+
+func TestKafkaCluster_Configure_ServersSRV(t *testing.T) {
+	module := fixtureModule()
+	viper.Set("cluster.test.servers", []string{})
+	viper.Set("cluster.test.servers-srv", "_kafka._tcp.example.com")
+	module.lookupSRVFunc = func(record string) ([]string, error) {
+		assert.Equalf(t, "_kafka._tcp.example.com", record, "Unexpected record looked up: %v", record)
+		return []string{"broker1.example.com:9092", "broker2.example.com:9092"}, nil
+	}
+	module.Configure("test", "cluster.test")
+
+	assert.Equalf(t, []string{"broker1.example.com:9092", "broker2.example.com:9092"}, module.servers, "Unexpected servers: %v", module.servers)
+	assert.Equal(t, int(0), module.serversSRVRefresh, "Default ServersSRVRefresh value of 0 did not get set")
+}
+
+func TestKafkaCluster_Configure_ServersSRVFallback(t *testing.T) {
+	module := fixtureModule()
+	viper.Set("cluster.test.servers-srv", "_kafka._tcp.example.com")
+	module.lookupSRVFunc = func(record string) ([]string, error) {
+		return nil, errors.New("lookup failed")
+	}
+	module.Configure("test", "cluster.test")
+
+	assert.Equalf(t, []string{"broker1.example.com:1234"}, module.servers, "Expected fallback to servers, not %v", module.servers)
+}
+
+func TestKafkaCluster_Configure_ServersSRVNoFallback(t *testing.T) {
+	module := fixtureModule()
+	viper.Set("cluster.test.servers", []string{})
+	viper.Set("cluster.test.servers-srv", "_kafka._tcp.example.com")
+	module.lookupSRVFunc = func(record string) ([]string, error) {
+		return nil, errors.New("lookup failed")
+	}
+	assert.Panics(t, func() { module.Configure("test", "cluster.test") }, "The code did not panic")
+}
+
+func TestKafkaCluster_refreshServers_Unchanged(t *testing.T) {
+	module := fixtureModule()
+	viper.Set("cluster.test.servers-srv", "_kafka._tcp.example.com")
+	lookupErr := errors.New("lookup failed")
+	module.lookupSRVFunc = func(record string) ([]string, error) {
+		return []string{"broker2.example.com:9092", "broker1.example.com:9092"}, lookupErr
+	}
+	module.Configure("test", "cluster.test")
+	module.servers = []string{"broker1.example.com:9092", "broker2.example.com:9092"}
+
+	// Neither a failed lookup nor the same servers in a different order reconnect the client
+	client := &helpers.MockSaramaClient{}
+	assert.Equal(t, client, module.refreshServers(client), "Expected the client to be kept after a failed lookup")
+	lookupErr = nil
+	assert.Equal(t, client, module.refreshServers(client), "Expected the client to be kept for the same servers")
+	client.AssertNotCalled(t, "Close")
+}
+
+func TestKafkaCluster_replaceClient(t *testing.T) {
+	module := fixtureModule()
+	module.Configure("test", "cluster.test")
+
+	// The lookup on the old client is still in flight when the client is replaced
+	inLookup := make(chan struct{})
+	finishLookup := make(chan struct{})
+	var lookupDone atomic.Bool
+	oldClient := &helpers.MockSaramaClient{}
+	oldClient.On("Config").Return(sarama.NewConfig())
+	oldClient.On("Partitions", "testtopic").Return([]int32{0}, nil)
+	oldClient.On("GetOffsets", mock.Anything).Run(func(args mock.Arguments) {
+		close(inLookup)
+		<-finishLookup
+		lookupDone.Store(true)
+	}).Return(map[string]map[int32]int64{"testtopic": {0: 120}}, nil)
+	oldClient.On("Close").Run(func(args mock.Arguments) {
+		assert.True(t, lookupDone.Load(), "Expected the old client to be closed after the lookup that uses it")
+	}).Return(nil)
+	module.setClient(oldClient)
+
+	lookup := make(chan map[int32]int64)
+	go func() {
+		offsets, _ := module.lookupOffsets("testtopic", 1000)
+		lookup <- offsets
+	}()
+	<-inLookup
+
+	newClient := &helpers.MockSaramaClient{}
+	replaced := make(chan struct{})
+	go func() {
+		module.replaceClient(newClient, oldClient)
+		close(replaced)
+	}()
+	select {
+	case <-replaced:
+		t.Fatal("Expected the client to not be replaced while a lookup is using it")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(finishLookup)
+	assert.Equal(t, map[int32]int64{0: 120}, <-lookup, "Expected the lookup on the old client to complete")
+	<-replaced
+	oldClient.AssertCalled(t, "Close")
+	assert.Equal(t, newClient, module.client, "Expected the new client to be used")
+}
+
 func TestKafkaCluster_maybeUpdateMetadataAndDeleteTopics_PartialUpdate(t *testing.T) {
 	module := fixtureModule()
 	module.Configure("test", "cluster.test")
@@ -137,6 +491,7 @@ func TestKafkaCluster_maybeUpdateMetadataAndDeleteTopics_Delete(t *testing.T) {
 	for i := 0; i < cap(module.topicPartitions["topictodelete"]); i++ {
 		module.topicPartitions["topictodelete"] = append(module.topicPartitions["topictodelete"], int32(i))
 	}
+	module.checkOffsetRegression("topictodelete", 0, 1000)
 
 	// Need to wait for this request to come in and finish, which happens when we call maybeUpdate...
 	wg := &sync.WaitGroup{}
@@ -157,6 +512,7 @@ func TestKafkaCluster_maybeUpdateMetadataAndDeleteTopics_Delete(t *testing.T) {
 	topic, ok := module.topicPartitions["testtopic"]
 	assert.True(t, ok, "Expected to find testtopic in topicPartitions")
 	assert.Equalf(t, 1, len(topic), "Expected testtopic to be recorded with 1 partition, not %v", len(topic))
+	assert.NotContains(t, module.highWaterOffsets, "topictodelete", "Expected the offsets seen for topictodelete to be removed")
 }
 
 func TestKafkaCluster_generateOffsetRequests(t *testing.T) {
@@ -174,7 +530,7 @@ func TestKafkaCluster_generateOffsetRequests(t *testing.T) {
 	client.On("Leader", "testtopic", int32(0)).Return(broker, nil)
 	client.On("Config").Return(sarama.NewConfig())
 
-	requests, brokers := module.generateOffsetRequests(client)
+	requests, brokers := module.generateOffsetRequests(client, sarama.OffsetNewest)
 
 	broker.AssertExpectations(t)
 	client.AssertExpectations(t)
@@ -185,6 +541,31 @@ func TestKafkaCluster_generateOffsetRequests(t *testing.T) {
 	assert.Lenf(t, requests, 1, "Expected 1 request, not %v", len(requests))
 }
 
+func TestKafkaCluster_generateOffsetRequests_Concurrency(t *testing.T) {
+	module := fixtureModule()
+	viper.Set("cluster.test.offset-fetch-concurrency", 4)
+	module.Configure("test", "cluster.test")
+	module.topicPartitions = make(map[string][]int32)
+	module.topicPartitions["testtopic"] = []int32{0, 1, 2}
+
+	broker := &helpers.MockSaramaBroker{}
+	broker.On("ID").Return(int32(13))
+
+	// Allow two requests in flight to a broker, so the three partitions get split over two requests
+	config := sarama.NewConfig()
+	config.Net.MaxOpenRequests = 2
+	client := &helpers.MockSaramaClient{}
+	client.On("Leader", "testtopic", mock.AnythingOfType("int32")).Return(broker, nil)
+	client.On("Config").Return(config)
+
+	requests, brokers := module.generateOffsetRequests(client, sarama.OffsetNewest)
+
+	broker.AssertExpectations(t)
+	client.AssertExpectations(t)
+	assert.Lenf(t, brokers, 1, "Expected 1 broker entry, not %v", len(brokers))
+	assert.Lenf(t, requests[13], 2, "Expected 2 requests for the broker, not %v", len(requests[13]))
+}
+
 func TestKafkaCluster_generateOffsetRequests_NoLeader(t *testing.T) {
 	module := fixtureModule()
 	module.Configure("test", "cluster.test")
@@ -202,7 +583,7 @@ func TestKafkaCluster_generateOffsetRequests_NoLeader(t *testing.T) {
 	client.On("Leader", "testtopic", int32(1)).Return(broker, nil)
 	client.On("Config").Return(sarama.NewConfig())
 
-	requests, brokers := module.generateOffsetRequests(client)
+	requests, brokers := module.generateOffsetRequests(client, sarama.OffsetNewest)
 
 	broker.AssertExpectations(t)
 	client.AssertExpectations(t)
@@ -260,6 +641,83 @@ func TestKafkaCluster_getOffsets(t *testing.T) {
 	}
 }
 
+func TestKafkaCluster_getOffsets_Regressed(t *testing.T) {
+	module := fixtureModule()
+	module.Configure("test", "cluster.test")
+	module.topicPartitions = make(map[string][]int32)
+	module.topicPartitions["testtopic"] = []int32{0}
+	module.fetchMetadata = false
+
+	// The broker offset goes backwards on the second fetch, and recovers on the third
+	broker := &helpers.MockSaramaBroker{}
+	broker.On("ID").Return(int32(13))
+	for _, offset := range []int64{8374, 100, 9000} {
+		offsetResponse := &sarama.OffsetResponse{Version: 1}
+		offsetResponse.AddTopicPartition("testtopic", 0, offset)
+		broker.On("GetAvailableOffsets", mock.MatchedBy(func(request *sarama.OffsetRequest) bool { return request != nil })).Return(offsetResponse, nil).Once()
+	}
+
+	client := &helpers.MockSaramaClient{}
+	client.On("Leader", "testtopic", int32(0)).Return(broker, nil)
+	client.On("Config").Return(sarama.NewConfig())
+
+	for i, expected := range []bool{false, true, false} {
+		go module.getOffsets(client)
+		request := <-module.App.StorageChannel
+		assert.Equalf(t, expected, request.Regressed, "Fetch %v: Expected request sent with Regressed %v, not %v", i, expected, request.Regressed)
+	}
+
+	broker.AssertExpectations(t)
+	client.AssertExpectations(t)
+}
+
+func TestKafkaCluster_getOffsets_Oldest(t *testing.T) {
+	module := fixtureModule()
+	viper.Set("cluster.test.fetch-oldest-offsets", true)
+	module.Configure("test", "cluster.test")
+	module.topicPartitions = make(map[string][]int32)
+	module.topicPartitions["testtopic"] = []int32{0}
+	module.fetchMetadata = false
+
+	offsetResponse := &sarama.OffsetResponse{Version: 1}
+	offsetResponse.AddTopicPartition("testtopic", 0, 8374)
+
+	// The same response is returned for the newest and the oldest offset requests
+	broker := &helpers.MockSaramaBroker{}
+	broker.On("ID").Return(int32(13))
+	broker.On("GetAvailableOffsets", mock.MatchedBy(func(request *sarama.OffsetRequest) bool { return request != nil })).Return(offsetResponse, nil).Twice()
+
+	client := &helpers.MockSaramaClient{}
+	client.On("Leader", "testtopic", int32(0)).Return(broker, nil)
+	client.On("Config").Return(sarama.NewConfig())
+
+	go module.getOffsets(client)
+	requestTypes := make(map[protocol.StorageRequestConstant]int64)
+	for i := 0; i < 2; i++ {
+		request := <-module.App.StorageChannel
+		assert.Equalf(t, "testtopic", request.Topic, "Expected request sent with topic testtopic, not %v", request.Topic)
+		requestTypes[request.RequestType] = request.Offset
+	}
+
+	broker.AssertExpectations(t)
+	client.AssertExpectations(t)
+	assert.Contains(t, requestTypes, protocol.StorageSetBrokerOffset, "Expected a StorageSetBrokerOffset request")
+	assert.Contains(t, requestTypes, protocol.StorageSetBrokerLogStart, "Expected a StorageSetBrokerLogStart request")
+	assert.Equalf(t, int64(8374), requestTypes[protocol.StorageSetBrokerLogStart], "Expected log start sent with offset 8374, not %v", requestTypes[protocol.StorageSetBrokerLogStart])
+}
+
+func TestKafkaCluster_checkOffsetRegression(t *testing.T) {
+	module := fixtureModule()
+	module.Configure("test", "cluster.test")
+
+	assert.False(t, module.checkOffsetRegression("testtopic", 0, 1000), "Expected the first offset not to be regressed")
+	assert.False(t, module.checkOffsetRegression("testtopic", 0, 1000), "Expected the same offset not to be regressed")
+	assert.False(t, module.checkOffsetRegression("testtopic", 1, 10), "Expected other partitions to be tracked separately")
+	assert.True(t, module.checkOffsetRegression("testtopic", 0, 500), "Expected a lower offset to be regressed")
+	assert.True(t, module.checkOffsetRegression("testtopic", 0, 900), "Expected the partition to stay regressed below the previous offset")
+	assert.False(t, module.checkOffsetRegression("testtopic", 0, 1000), "Expected the partition to recover at the previous offset")
+}
+
 func TestKafkaCluster_getOffsets_BrokerFailed(t *testing.T) {
 	module := fixtureModule()
 	module.Configure("test", "cluster.test")
@@ -284,6 +742,42 @@ func TestKafkaCluster_getOffsets_BrokerFailed(t *testing.T) {
 	client.AssertExpectations(t)
 }
 
+func TestKafkaCluster_updateClusterBrokers(t *testing.T) {
+	module := fixtureModule()
+	module.Configure("test", "cluster.test")
+
+	controller := &helpers.MockSaramaBroker{}
+	controller.On("ID").Return(int32(2))
+	client := &helpers.MockSaramaClient{}
+	client.On("Controller").Return(controller, nil)
+	client.On("Brokers").Return([]helpers.SaramaBroker{controller, &helpers.MockSaramaBroker{}, &helpers.MockSaramaBroker{}})
+
+	go module.updateClusterBrokers(client)
+	request := <-module.App.StorageChannel
+	assert.Equalf(t, protocol.StorageSetClusterBrokers, request.RequestType, "Expected request sent with type StorageSetClusterBrokers, not %v", request.RequestType)
+	assert.Equalf(t, "test", request.Cluster, "Expected request sent with cluster test, not %v", request.Cluster)
+	assert.Equalf(t, int32(2), request.ControllerID, "Expected request sent with controller 2, not %v", request.ControllerID)
+	assert.Equalf(t, int32(3), request.BrokerCount, "Expected request sent with broker count 3, not %v", request.BrokerCount)
+	assert.NotZero(t, request.Timestamp, "Expected request sent with a timestamp")
+	client.AssertExpectations(t)
+}
+
+func TestKafkaCluster_updateClusterBrokers_NoController(t *testing.T) {
+	module := fixtureModule()
+	module.Configure("test", "cluster.test")
+
+	var nilBroker *helpers.BurrowSaramaBroker
+	client := &helpers.MockSaramaClient{}
+	client.On("Controller").Return(nilBroker, sarama.ErrControllerNotAvailable)
+	client.On("Brokers").Return([]helpers.SaramaBroker{&helpers.MockSaramaBroker{}})
+
+	go module.updateClusterBrokers(client)
+	request := <-module.App.StorageChannel
+	assert.Equalf(t, int32(-1), request.ControllerID, "Expected request sent with controller -1, not %v", request.ControllerID)
+	assert.Equalf(t, int32(1), request.BrokerCount, "Expected request sent with broker count 1, not %v", request.BrokerCount)
+	client.AssertExpectations(t)
+}
+
 func TestKafkaCluster_reapNonExistingGroups(t *testing.T) {
 	module := fixtureModule()
 	module.Configure("test", "cluster.test")
@@ -307,3 +801,263 @@ func TestKafkaCluster_reapNonExistingGroups(t *testing.T) {
 	assert.Equalf(t, "test", request.Cluster, "Expected request sent with cluster test, not %v", request.Cluster)
 	assert.Equalf(t, "group2", request.Group, "Expected request sent with group group2, not %v", request.Group)
 }
+
+func TestKafkaCluster_describeGroupMembers(t *testing.T) {
+	module := fixtureModule()
+	module.Configure("test", "cluster.test")
+
+	client := &helpers.MockSaramaClient{}
+	client.On("DescribeConsumerGroups", []string{"group1", "group2"}).Return(map[string]int32{"group1": 3, "group2": 0}, nil)
+
+	go module.describeGroupMembers(client)
+	request := <-module.App.StorageChannel
+	assert.Equalf(t, protocol.StorageFetchConsumers, request.RequestType, "Expected request sent with type StorageFetchConsumers, not %v", request.RequestType)
+	assert.Equalf(t, "test", request.Cluster, "Expected request sent with cluster test, not %v", request.Cluster)
+	request.Reply <- []string{"group1", "group2"}
+
+	memberCounts := make(map[string]int32)
+	for i := 0; i < 2; i++ {
+		request = <-module.App.StorageChannel
+		assert.Equalf(t, protocol.StorageSetConsumerMembers, request.RequestType, "Expected request sent with type StorageSetConsumerMembers, not %v", request.RequestType)
+		assert.Equalf(t, "test", request.Cluster, "Expected request sent with cluster test, not %v", request.Cluster)
+		assert.NotZero(t, request.Timestamp, "Expected request sent with a timestamp")
+		memberCounts[request.Group] = request.MemberCount
+	}
+	assert.Equal(t, map[string]int32{"group1": 3, "group2": 0}, memberCounts, "Expected member counts for both groups")
+	client.AssertExpectations(t)
+}
+
+func TestKafkaCluster_describeGroupMembers_Error(t *testing.T) {
+	module := fixtureModule()
+	module.Configure("test", "cluster.test")
+
+	client := &helpers.MockSaramaClient{}
+	client.On("DescribeConsumerGroups", []string{"group1"}).Return(map[string]int32(nil), errors.New("no coordinator"))
+
+	go module.describeGroupMembers(client)
+	request := <-module.App.StorageChannel
+	request.Reply <- []string{"group1"}
+
+	// Nothing else is sent to storage
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case request := <-module.App.StorageChannel:
+		t.Fatalf("Expected no more storage requests, got %v", request.RequestType)
+	default:
+	}
+	client.AssertExpectations(t)
+}
+
+func TestKafkaCluster_getMessageTimestamp_RecordBatch(t *testing.T) {
+	firstTime := time.Unix(1500000000, 0)
+	response := &sarama.FetchResponse{Version: 4}
+	response.AddRecordWithTimestamp("testtopic", 0, nil, sarama.StringEncoder("one"), 10, firstTime)
+	response.AddRecordWithTimestamp("testtopic", 0, nil, sarama.StringEncoder("two"), 11, firstTime.Add(5*time.Second))
+	block := response.GetBlock("testtopic", 0)
+
+	timestamp, ok := getMessageTimestamp(block, 11)
+	assert.True(t, ok, "Expected a timestamp for offset 11")
+	assert.Equal(t, int64(1500000005000), timestamp, "Expected the timestamp of the second record")
+
+	// The first record at or after the offset is used
+	timestamp, ok = getMessageTimestamp(block, 3)
+	assert.True(t, ok, "Expected a timestamp for offset 3")
+	assert.Equal(t, int64(1500000000000), timestamp, "Expected the timestamp of the first record")
+
+	_, ok = getMessageTimestamp(block, 12)
+	assert.False(t, ok, "Expected no timestamp after the last record")
+}
+
+func TestKafkaCluster_getMessageTimestamp_MessageSet(t *testing.T) {
+	firstTime := time.Unix(1500000000, 0)
+	response := &sarama.FetchResponse{Version: 2}
+	response.AddMessageWithTimestamp("testtopic", 0, nil, sarama.StringEncoder("one"), 5, firstTime, 1)
+	response.AddMessageWithTimestamp("testtopic", 0, nil, sarama.StringEncoder("two"), 6, firstTime.Add(time.Second), 1)
+	response.AddMessage("testtopic", 1, nil, sarama.StringEncoder("old"), 5)
+
+	timestamp, ok := getMessageTimestamp(response.GetBlock("testtopic", 0), 6)
+	assert.True(t, ok, "Expected a timestamp for offset 6")
+	assert.Equal(t, int64(1500000001000), timestamp, "Expected the timestamp of the second message")
+
+	// Messages from before Kafka 0.10 have no timestamp
+	_, ok = getMessageTimestamp(response.GetBlock("testtopic", 1), 5)
+	assert.False(t, ok, "Expected no timestamp for a version 0 message")
+}
+
+func TestKafkaCluster_getMessageTimestamp_LogAppendTime(t *testing.T) {
+	response := &sarama.FetchResponse{Version: 4, LogAppendTime: true, Timestamp: time.Unix(1600000000, 0)}
+	response.AddRecordWithTimestamp("testtopic", 0, nil, sarama.StringEncoder("one"), 10, time.Unix(1500000000, 0))
+
+	timestamp, ok := getMessageTimestamp(response.GetBlock("testtopic", 0), 10)
+	assert.True(t, ok, "Expected a timestamp for offset 10")
+	assert.Equal(t, int64(1600000000000), timestamp, "Expected the log append time to be used")
+}
+
+func TestKafkaCluster_fetchTimeLags(t *testing.T) {
+	module := fixtureModule()
+	module.Configure("test", "cluster.test")
+	module.checkOffsetRegression("testtopic", 0, 2000)
+
+	// A single batch holds the message at the committed offset of group1 and the newest message
+	firstTime := time.Unix(1500000000, 0)
+	response := &sarama.FetchResponse{Version: 4}
+	response.AddRecordWithTimestamp("testtopic", 0, nil, sarama.StringEncoder("committed"), 1500, firstTime)
+	response.AddRecordWithTimestamp("testtopic", 0, nil, sarama.StringEncoder("newest"), 1999, firstTime.Add(90*time.Second))
+
+	broker := &helpers.MockSaramaBroker{}
+	broker.On("ID").Return(int32(13))
+	broker.On("Fetch", mock.MatchedBy(func(request *sarama.FetchRequest) bool { return request != nil })).Return(response, nil).Times(2)
+
+	client := &helpers.MockSaramaClient{}
+	client.On("Leader", "testtopic", int32(0)).Return(broker, nil)
+	client.On("Config").Return(sarama.NewConfig())
+
+	go module.fetchTimeLags(client)
+	request := <-module.App.StorageChannel
+	assert.Equalf(t, protocol.StorageFetchConsumers, request.RequestType, "Expected request sent with type StorageFetchConsumers, not %v", request.RequestType)
+	request.Reply <- []string{"group1", "group2"}
+
+	// group1 is lagging, and group2 has caught up. The groups are fetched from storage in parallel
+	offsets := map[string]int64{"group1": 1500, "group2": 2000}
+	for i := 0; i < 2; i++ {
+		request = <-module.App.StorageChannel
+		assert.Equalf(t, protocol.StorageFetchConsumer, request.RequestType, "Expected request sent with type StorageFetchConsumer, not %v", request.RequestType)
+		request.Reply <- protocol.ConsumerTopics{
+			"testtopic": protocol.ConsumerPartitions{
+				&protocol.ConsumerPartition{Offsets: []*protocol.ConsumerOffset{nil, {Offset: offsets[request.Group]}}},
+			},
+		}
+	}
+
+	timeLags := make(map[string]int64)
+	for i := 0; i < 2; i++ {
+		request = <-module.App.StorageChannel
+		assert.Equalf(t, protocol.StorageSetConsumerTimeLag, request.RequestType, "Expected request sent with type StorageSetConsumerTimeLag, not %v", request.RequestType)
+		assert.Equalf(t, "testtopic", request.Topic, "Expected request sent with topic testtopic, not %v", request.Topic)
+		timeLags[request.Group] = request.TimeLag
+	}
+	assert.Equal(t, map[string]int64{"group1": 90000, "group2": 0}, timeLags, "Expected time lags for both groups")
+	broker.AssertExpectations(t)
+	client.AssertExpectations(t)
+}
+
+func TestKafkaCluster_fetchTimeLags_FetchError(t *testing.T) {
+	module := fixtureModule()
+	module.Configure("test", "cluster.test")
+	module.checkOffsetRegression("testtopic", 0, 2000)
+
+	broker := &helpers.MockSaramaBroker{}
+	broker.On("ID").Return(int32(13))
+	broker.On("Fetch", mock.MatchedBy(func(request *sarama.FetchRequest) bool { return request != nil })).Return(&sarama.FetchResponse{}, errors.New("fetch failed")).Once()
+	broker.On("Close").Return(nil)
+
+	client := &helpers.MockSaramaClient{}
+	client.On("Leader", "testtopic", int32(0)).Return(broker, nil)
+	client.On("Config").Return(sarama.NewConfig())
+
+	go module.fetchTimeLags(client)
+	request := <-module.App.StorageChannel
+	request.Reply <- []string{"group1"}
+	request = <-module.App.StorageChannel
+	request.Reply <- protocol.ConsumerTopics{
+		"testtopic": protocol.ConsumerPartitions{
+			&protocol.ConsumerPartition{Offsets: []*protocol.ConsumerOffset{{Offset: 1500}}},
+		},
+	}
+
+	// No time lag is sent, as the timestamps could not be fetched
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case request := <-module.App.StorageChannel:
+		t.Fatalf("Expected no more storage requests, got %v", request.RequestType)
+	default:
+	}
+	broker.AssertExpectations(t)
+}
+
+func TestKafkaCluster_timeLagLoop(t *testing.T) {
+	module := fixtureModule()
+	module.Configure("test", "cluster.test")
+	module.setClient(&helpers.MockSaramaClient{})
+	module.timeLagTicker = time.NewTicker(10 * time.Millisecond)
+
+	module.running.Add(1)
+	go module.timeLagLoop()
+
+	// The time lags are fetched on a tick, without the main loop running
+	request := <-module.App.StorageChannel
+	assert.Equalf(t, protocol.StorageFetchConsumers, request.RequestType, "Expected request sent with type StorageFetchConsumers, not %v", request.RequestType)
+	request.Reply <- []string{}
+
+	module.timeLagTicker.Stop()
+	close(module.quitChannel)
+	module.running.Wait()
+}
+
+func TestKafkaCluster_lookupOffsets(t *testing.T) {
+	module := fixtureModule()
+	module.Configure("test", "cluster.test")
+
+	_, err := module.lookupOffsets("testtopic", 1636859338280)
+	assert.Error(t, err, "Expected an error before the client is set")
+
+	client := &helpers.MockSaramaClient{}
+	client.On("Config").Return(sarama.NewConfig())
+	client.On("Partitions", "testtopic").Return([]int32{0, 1}, nil)
+	client.On("GetOffsets", map[string]map[int32]int64{"testtopic": {0: 1636859338280, 1: 1636859338280}}).Return(
+		map[string]map[int32]int64{"testtopic": {0: 120, 1: -1}}, nil)
+	module.setClient(client)
+
+	offsets, err := module.lookupOffsets("testtopic", 1636859338280)
+	assert.NoError(t, err, "Expected lookupOffsets to return no error")
+	assert.Equal(t, map[int32]int64{0: 120, 1: -1}, offsets, "Expected the offsets for the topic")
+	client.AssertExpectations(t)
+}
+
+func TestKafkaCluster_lookupOffsets_Error(t *testing.T) {
+	module := fixtureModule()
+	module.Configure("test", "cluster.test")
+
+	client := &helpers.MockSaramaClient{}
+	client.On("Config").Return(sarama.NewConfig())
+	client.On("Partitions", "testtopic").Return([]int32{0}, nil)
+	client.On("GetOffsets", map[string]map[int32]int64{"testtopic": {0: 1000}}).Return(
+		map[string]map[int32]int64{}, sarama.ErrNotLeaderForPartition)
+	module.setClient(client)
+
+	_, err := module.lookupOffsets("testtopic", 1000)
+	assert.Equal(t, sarama.ErrNotLeaderForPartition, err, "Expected the error from the client")
+}
+
+func TestKafkaCluster_lookupOffsets_OldVersion(t *testing.T) {
+	module := fixtureModule()
+	module.Configure("test", "cluster.test")
+
+	// Before 0.10.1.0, the offsets are looked up by log segment rather than by message timestamp
+	config := sarama.NewConfig()
+	config.Version = sarama.V0_10_0_0
+	client := &helpers.MockSaramaClient{}
+	client.On("Config").Return(config)
+	module.setClient(client)
+
+	_, err := module.lookupOffsets("testtopic", 1000)
+	assert.Equal(t, protocol.ErrOffsetLookupUnsupported, err, "Expected offsets by timestamp to be unsupported")
+	client.AssertExpectations(t)
+}
+
+func TestKafkaCluster_lookupOffsets_LeaderFailed(t *testing.T) {
+	module := fixtureModule()
+	module.Configure("test", "cluster.test")
+
+	// The leader of partition 1 fails, and partitions 0 and 2 have different leaders that respond
+	client := &helpers.MockSaramaClient{}
+	client.On("Config").Return(sarama.NewConfig())
+	client.On("Partitions", "testtopic").Return([]int32{0, 1, 2}, nil)
+	client.On("GetOffsets", map[string]map[int32]int64{"testtopic": {0: 1000, 1: 1000, 2: 1000}}).Return(
+		map[string]map[int32]int64{"testtopic": {0: 120, 2: 340}}, sarama.ErrBrokerNotAvailable)
+	module.setClient(client)
+
+	offsets, err := module.lookupOffsets("testtopic", 1000)
+	assert.Equal(t, sarama.ErrBrokerNotAvailable, err, "Expected the error from the failed leader")
+	assert.Equal(t, map[int32]int64{0: 120, 2: 340}, offsets, "Expected the offsets from the other leaders")
+}
@@ -0,0 +1,387 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package consumer
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/linkedin/Burrow/core/internal/helpers"
+	"github.com/linkedin/Burrow/core/protocol"
+)
+
+// BurrowClient is a consumer module which runs Burrow as a read-only replica of another (primary) Burrow. It
+// periodically fetches the consumer groups and their offsets from the HTTP API of the primary and sends them to
+// storage, so the replica serves the same state without consuming the offsets from Kafka itself. The primary is
+// checked with the /burrow/admin endpoint on every interval. If it fails heartbeat-failures times in a row, the
+// replica takes over: it stops polling, removes the groups it copied from the primary, and starts the standby
+// consumer modules, which then consume the offsets directly. The replica does not hand back to the primary.
+type BurrowClient struct {
+	// App is a pointer to the application context. This stores the channel to the storage subsystem
+	App *protocol.ApplicationContext
+
+	// Log is a logger that has been configured for this module to use. Normally, this means it has been set up with
+	// fields that are appropriate to identify this coordinator
+	Log *zap.Logger
+
+	name              string
+	configRoot        string
+	cluster           string
+	primaryCluster    string
+	url               string
+	interval          int
+	heartbeatFailures int
+	authToken         string
+	httpClient        *http.Client
+	groupAllowlist    *regexp.Regexp
+	groupDenylist     *regexp.Regexp
+	filterLock        sync.RWMutex
+
+	// The consumer modules that are started on takeover. These are set up by the coordinator, which no longer starts
+	// or stops them itself
+	standbyNames []string
+	standby      map[string]protocol.Module
+
+	failures int
+	active   bool
+	groups   map[string]struct{}
+
+	quitChannel chan struct{}
+	running     sync.WaitGroup
+}
+
+type burrowConsumerList struct {
+	Consumers []string `json:"consumers"`
+}
+
+type burrowConsumerDetail struct {
+	Topics protocol.ConsumerTopics `json:"topics"`
+}
+
+// Configure validates the configuration for the consumer. At minimum, there must be a cluster name to which these
+// consumers belong, as well as the url of the primary Burrow (such as "http://burrow-primary:8000"). The groups are
+// read from the cluster of the same name on the primary, unless primary-cluster is set. If not explicitly configured,
+// the primary is polled every 30 seconds with a timeout of 5 seconds, and the replica takes over after 3 failed
+// heartbeats. The standby list names the consumer modules (normally the kafka consumer for the same cluster) that are
+// started on takeover. If the listener of the primary requires a bearer token, it is set with auth-token, and
+// tls-profile names the tls profile to verify an https primary with, and to present a client certificate from if the
+// profile has one. If the cluster name is unknown, if the url is missing, if the tls profile is unknown or cannot be
+// loaded, or if the group allowlist or denylist is invalid, this func will panic.
+func (module *BurrowClient) Configure(name, configRoot string) {
+	module.Log.Info("configuring")
+
+	module.name = name
+	module.quitChannel = make(chan struct{})
+	module.running = sync.WaitGroup{}
+	module.standby = make(map[string]protocol.Module)
+	module.groups = make(map[string]struct{})
+
+	module.cluster = viper.GetString(configRoot + ".cluster")
+	if !viper.IsSet("cluster." + module.cluster) {
+		panic("Consumer '" + name + "' references an unknown cluster '" + module.cluster + "'")
+	}
+
+	module.url = strings.TrimSuffix(viper.GetString(configRoot+".url"), "/")
+	if module.url == "" {
+		panic("No url specified for consumer " + module.name)
+	}
+
+	// Set defaults for configs if needed, and get them
+	viper.SetDefault(configRoot+".primary-cluster", module.cluster)
+	viper.SetDefault(configRoot+".interval", 30)
+	viper.SetDefault(configRoot+".timeout", 5)
+	viper.SetDefault(configRoot+".heartbeat-failures", 3)
+	module.primaryCluster = viper.GetString(configRoot + ".primary-cluster")
+	module.interval = viper.GetInt(configRoot + ".interval")
+	if module.interval <= 0 {
+		panic("Consumer '" + name + "' must have an interval greater than zero")
+	}
+	module.heartbeatFailures = viper.GetInt(configRoot + ".heartbeat-failures")
+	if module.heartbeatFailures <= 0 {
+		panic("Consumer '" + name + "' must have heartbeat-failures greater than zero")
+	}
+	module.standbyNames = viper.GetStringSlice(configRoot + ".standby")
+	module.authToken = viper.GetString(configRoot + ".auth-token")
+	module.httpClient = &http.Client{
+		Timeout: time.Duration(viper.GetInt(configRoot+".timeout")) * time.Second,
+	}
+	if tlsName := viper.GetString(configRoot + ".tls-profile"); tlsName != "" {
+		if !viper.IsSet("tls." + tlsName) {
+			panic("Consumer '" + name + "' references an unknown tls profile '" + tlsName + "'")
+		}
+		tlsConfig, err := helpers.NewTLSProfileConfig(viper.GetViper(), tlsName)
+		if err != nil {
+			panic("Consumer '" + name + "' cannot load tls profile '" + tlsName + "': " + err.Error())
+		}
+		module.httpClient.Transport = &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+		}
+	}
+
+	module.configRoot = configRoot
+	if err := module.ReloadFilters(viper.GetViper()); err != nil {
+		module.Log.Panic(err.Error())
+		panic(err)
+	}
+}
+
+// Start starts a goroutine that checks and polls the primary every interval, starting immediately. Errors from the
+// primary are logged and counted as failed heartbeats, so this func always returns no error.
+func (module *BurrowClient) Start() error {
+	module.Log.Info("starting")
+
+	module.running.Add(1)
+	go module.mainLoop()
+	return nil
+}
+
+// Stop stops the polling goroutine, and waits for it to exit. If the replica has taken over, the standby consumer
+// modules are stopped as well.
+func (module *BurrowClient) Stop() error {
+	module.Log.Info("stopping")
+
+	close(module.quitChannel)
+	module.running.Wait()
+	if module.active {
+		helpers.StopCoordinatorModules(module.standby)
+	}
+	return nil
+}
+
+// ReloadFilters compiles the group-allowlist and group-denylist configurations for the consumer, and replaces the
+// current filters with them. If either of them is invalid, an error is returned and the current filters are kept. The
+// filters of the standby consumer modules are reloaded as well.
+func (module *BurrowClient) ReloadFilters(config *viper.Viper) error {
+	groupAllowlist, err := helpers.CompileConfigRegexp(config, module.configRoot+".group-allowlist")
+	if err != nil {
+		return err
+	}
+	groupDenylist, err := helpers.CompileConfigRegexp(config, module.configRoot+".group-denylist")
+	if err != nil {
+		return err
+	}
+
+	module.filterLock.Lock()
+	module.groupAllowlist = groupAllowlist
+	module.groupDenylist = groupDenylist
+	module.filterLock.Unlock()
+
+	return helpers.ReloadCoordinatorModuleFilters(module.standby, config, nil)
+}
+
+// addStandby sets a consumer module to be started when the replica takes over
+func (module *BurrowClient) addStandby(name string, standby protocol.Module) {
+	module.standby[name] = standby
+}
+
+func (module *BurrowClient) acceptConsumerGroup(group string) bool {
+	module.filterLock.RLock()
+	defer module.filterLock.RUnlock()
+
+	if (module.groupAllowlist != nil) && (!module.groupAllowlist.MatchString(group)) {
+		return false
+	}
+	if (module.groupDenylist != nil) && module.groupDenylist.MatchString(group) {
+		return false
+	}
+	return true
+}
+
+func (module *BurrowClient) mainLoop() {
+	defer module.running.Done()
+
+	ticker := time.NewTicker(time.Duration(module.interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		if !module.sync() {
+			// The replica has taken over, so there is nothing more to poll
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-module.quitChannel:
+			return
+		}
+	}
+}
+
+// sync checks the primary, and if it is up, copies the consumer groups from it to storage. If the primary has failed
+// too many heartbeats in a row, the replica takes over and this func returns false.
+func (module *BurrowClient) sync() bool {
+	if err := module.heartbeat(); err != nil {
+		module.failures++
+		module.Log.Warn("primary heartbeat failed",
+			zap.String("url", module.url),
+			zap.Int("failures", module.failures),
+			zap.Error(err),
+		)
+		if module.failures >= module.heartbeatFailures {
+			module.takeOver()
+			return false
+		}
+		return true
+	}
+	module.failures = 0
+
+	if err := module.poll(); err != nil {
+		module.Log.Error("failed to fetch consumers from primary", zap.String("url", module.url), zap.Error(err))
+	}
+	return true
+}
+
+// takeOver removes the groups that were copied from the primary, as their commits are ordered by timestamp rather
+// than by the offsets of the commits in Kafka, and starts the standby consumer modules
+func (module *BurrowClient) takeOver() {
+	module.Log.Warn("primary is down, taking over", zap.Int("groups", len(module.groups)))
+
+	for group := range module.groups {
+		helpers.TimeoutSendStorageRequest(module.App.StorageChannel, &protocol.StorageRequest{
+			RequestType: protocol.StorageSetDeleteGroup,
+			Cluster:     module.cluster,
+			Group:       group,
+		}, 1)
+	}
+	module.groups = make(map[string]struct{})
+
+	module.active = true
+	for name, standby := range module.standby {
+		if err := standby.Start(); err != nil {
+			module.Log.Error("failed to start standby consumer", zap.String("consumer", name), zap.Error(err))
+		}
+	}
+}
+
+func (module *BurrowClient) heartbeat() error {
+	req, err := module.newRequest("/burrow/admin")
+	if err != nil {
+		return err
+	}
+	resp, err := module.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("unexpected response status " + strconv.Itoa(resp.StatusCode))
+	}
+	return nil
+}
+
+// poll fetches the list of groups from the primary, and then the offsets for each accepted group, and sends them to
+// storage. The timestamp of each commit is used as the commit order, so the offsets that are already stored are
+// dropped by storage.
+func (module *BurrowClient) poll() error {
+	clusterPath := "/v3/kafka/" + url.PathEscape(module.primaryCluster) + "/consumer"
+	list := &burrowConsumerList{}
+	if err := module.get(clusterPath, list); err != nil {
+		return err
+	}
+
+	count := 0
+	for _, group := range list.Consumers {
+		if !module.acceptConsumerGroup(group) {
+			module.Log.Debug("dropped", zap.String("group", group), zap.String("reason", "allowlist"))
+			continue
+		}
+
+		detail := &burrowConsumerDetail{}
+		if err := module.get(clusterPath+"/"+url.PathEscape(group), detail); err != nil {
+			// The group may have been removed on the primary since the list was fetched
+			module.Log.Debug("failed to fetch group from primary", zap.String("group", group), zap.Error(err))
+			continue
+		}
+		module.groups[group] = struct{}{}
+		module.sendConsumer(group, detail.Topics)
+		count++
+	}
+	module.Log.Debug("polled primary", zap.Int("groups", count))
+	return nil
+}
+
+func (module *BurrowClient) sendConsumer(group string, topics protocol.ConsumerTopics) {
+	for topic, partitions := range topics {
+		for partitionID, partition := range partitions {
+			if partition == nil {
+				continue
+			}
+			for _, offset := range partition.Offsets {
+				if offset == nil {
+					continue
+				}
+				helpers.TimeoutSendStorageRequest(module.App.StorageChannel, &protocol.StorageRequest{
+					RequestType: protocol.StorageSetConsumerOffset,
+					Cluster:     module.cluster,
+					Topic:       topic,
+					Partition:   int32(partitionID),
+					Group:       group,
+					Timestamp:   offset.Timestamp,
+					Offset:      offset.Offset,
+					Order:       offset.Timestamp,
+				}, 1)
+			}
+			if (partition.Owner != "") || (partition.ClientID != "") {
+				helpers.TimeoutSendStorageRequest(module.App.StorageChannel, &protocol.StorageRequest{
+					RequestType: protocol.StorageSetConsumerOwner,
+					Cluster:     module.cluster,
+					Topic:       topic,
+					Partition:   int32(partitionID),
+					Group:       group,
+					Owner:       partition.Owner,
+					ClientID:    partition.ClientID,
+				}, 1)
+			}
+		}
+	}
+}
+
+// newRequest creates a GET request for a path on the primary, with the auth-token as a bearer token if it is set
+func (module *BurrowClient) newRequest(path string) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodGet, module.url+path, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	if module.authToken != "" {
+		req.Header.Set("Authorization", "Bearer "+module.authToken)
+	}
+	return req, nil
+}
+
+// get fetches a path from the HTTP API of the primary, and decodes the JSON response into result
+func (module *BurrowClient) get(path string, result interface{}) error {
+	req, err := module.newRequest(path)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := module.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("unexpected response status " + strconv.Itoa(resp.StatusCode))
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
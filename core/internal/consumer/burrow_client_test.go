@@ -0,0 +1,279 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package consumer
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/linkedin/Burrow/core/internal/helpers"
+	"github.com/linkedin/Burrow/core/protocol"
+)
+
+const burrowClientTestDetail = `{"error": false, "topics": {"testtopic": [
+	{"offsets": [null, {"offset": 1000, "timestamp": 1500000000000, "lag": 10}, {"offset": 1100, "timestamp": 1500000010000, "lag": 5}],
+	 "owner": "/1.2.3.4", "client_id": "testclient", "current-lag": 5}
+]}}`
+
+func fixtureBurrowModule(url string) *BurrowClient {
+	module := BurrowClient{
+		Log: zap.NewNop(),
+	}
+	module.App = &protocol.ApplicationContext{
+		StorageChannel: make(chan *protocol.StorageRequest),
+	}
+
+	viper.Reset()
+	viper.Set("cluster.test.class-name", "kafka")
+	viper.Set("cluster.test.servers", []string{"broker1.example.com:1234"})
+	viper.Set("consumer.test.class-name", "burrow")
+	viper.Set("consumer.test.cluster", "test")
+	viper.Set("consumer.test.url", url)
+
+	return &module
+}
+
+// startPrimary starts a fake primary Burrow that serves a single group, testgroup, in the cluster primary
+func startPrimary(t *testing.T, up *bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !*up {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		switch r.URL.Path {
+		case "/burrow/admin":
+			w.Write([]byte("GOOD"))
+		case "/v3/kafka/primary/consumer":
+			w.Write([]byte(`{"error": false, "consumers": ["testgroup", "othergroup"]}`))
+		case "/v3/kafka/primary/consumer/testgroup":
+			w.Write([]byte(burrowClientTestDetail))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestBurrowClient_ImplementsModule(t *testing.T) {
+	assert.Implements(t, (*protocol.Module)(nil), new(BurrowClient))
+	assert.Implements(t, (*protocol.FilterReloader)(nil), new(BurrowClient))
+}
+
+func TestBurrowClient_Configure(t *testing.T) {
+	module := fixtureBurrowModule("http://primary.example.com:8000/")
+	module.Configure("test", "consumer.test")
+	assert.Equal(t, "http://primary.example.com:8000", module.url, "Expected the trailing slash to be removed from the url")
+	assert.Equal(t, "test", module.primaryCluster, "Default primary-cluster of the cluster name did not get set")
+	assert.Equal(t, 30, module.interval, "Default interval of 30 did not get set")
+	assert.Equal(t, 3, module.heartbeatFailures, "Default heartbeat-failures of 3 did not get set")
+	assert.Equal(t, 5*time.Second, module.httpClient.Timeout, "Default timeout of 5 seconds did not get set")
+}
+
+func TestBurrowClient_Configure_BadCluster(t *testing.T) {
+	module := fixtureBurrowModule("http://primary.example.com:8000")
+	viper.Set("consumer.test.cluster", "nocluster")
+	assert.Panics(t, func() { module.Configure("test", "consumer.test") }, "The code did not panic")
+}
+
+func TestBurrowClient_Configure_NoURL(t *testing.T) {
+	module := fixtureBurrowModule("")
+	assert.Panics(t, func() { module.Configure("test", "consumer.test") }, "The code did not panic")
+}
+
+func TestBurrowClient_Configure_BadHeartbeatFailures(t *testing.T) {
+	module := fixtureBurrowModule("http://primary.example.com:8000")
+	viper.Set("consumer.test.heartbeat-failures", 0)
+	assert.Panics(t, func() { module.Configure("test", "consumer.test") }, "The code did not panic")
+}
+
+func TestBurrowClient_Configure_BadTLSProfile(t *testing.T) {
+	module := fixtureBurrowModule("https://primary.example.com:8000")
+	viper.Set("consumer.test.tls-profile", "notls")
+	assert.Panics(t, func() { module.Configure("test", "consumer.test") }, "The code did not panic")
+}
+
+func TestBurrowClient_heartbeat_AuthToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer testtoken" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("GOOD"))
+	}))
+	defer server.Close()
+
+	module := fixtureBurrowModule(server.URL)
+	module.Configure("test", "consumer.test")
+	assert.Error(t, module.heartbeat(), "Expected the heartbeat to fail without the auth-token")
+
+	viper.Set("consumer.test.auth-token", "testtoken")
+	module.Configure("test", "consumer.test")
+	assert.NoError(t, module.heartbeat(), "Expected the heartbeat to send the auth-token as a bearer token")
+}
+
+func TestBurrowClient_heartbeat_TLSProfile(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("GOOD"))
+	}))
+	defer server.Close()
+
+	module := fixtureBurrowModule(server.URL)
+	module.Configure("test", "consumer.test")
+	assert.Error(t, module.heartbeat(), "Expected the heartbeat to fail without the CA of the primary")
+
+	viper.Set("tls.primary.capem", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})))
+	viper.Set("consumer.test.tls-profile", "primary")
+	module.Configure("test", "consumer.test")
+	assert.NoError(t, module.heartbeat(), "Expected the heartbeat to verify the primary with the tls profile")
+}
+
+func TestBurrowClient_sync(t *testing.T) {
+	up := true
+	server := startPrimary(t, &up)
+	defer server.Close()
+
+	module := fixtureBurrowModule(server.URL)
+	viper.Set("consumer.test.primary-cluster", "primary")
+	module.Configure("test", "consumer.test")
+
+	// othergroup is in the list, but can't be fetched, so only testgroup is sent
+	done := make(chan struct{})
+	go func() {
+		assert.True(t, module.sync(), "Expected the replica not to take over")
+		close(done)
+	}()
+	for _, expected := range []struct {
+		offset    int64
+		timestamp int64
+	}{{1000, 1500000000000}, {1100, 1500000010000}} {
+		request := <-module.App.StorageChannel
+		assert.Equalf(t, protocol.StorageSetConsumerOffset, request.RequestType, "Expected request sent with type StorageSetConsumerOffset, not %v", request.RequestType)
+		assert.Equalf(t, "test", request.Cluster, "Expected request sent with cluster test, not %v", request.Cluster)
+		assert.Equalf(t, "testgroup", request.Group, "Expected request sent with Group testgroup, not %v", request.Group)
+		assert.Equalf(t, "testtopic", request.Topic, "Expected request sent with topic testtopic, not %v", request.Topic)
+		assert.Equalf(t, int32(0), request.Partition, "Expected request sent with partition 0, not %v", request.Partition)
+		assert.Equalf(t, expected.offset, request.Offset, "Expected request sent with offset %v, not %v", expected.offset, request.Offset)
+		assert.Equalf(t, expected.timestamp, request.Timestamp, "Expected request sent with timestamp %v, not %v", expected.timestamp, request.Timestamp)
+		assert.Equalf(t, request.Timestamp, request.Order, "Expected request sent with the timestamp as the order, not %v", request.Order)
+	}
+	request := <-module.App.StorageChannel
+	assert.Equalf(t, protocol.StorageSetConsumerOwner, request.RequestType, "Expected request sent with type StorageSetConsumerOwner, not %v", request.RequestType)
+	assert.Equalf(t, "/1.2.3.4", request.Owner, "Expected request sent with owner /1.2.3.4, not %v", request.Owner)
+	assert.Equalf(t, "testclient", request.ClientID, "Expected request sent with client ID testclient, not %v", request.ClientID)
+
+	<-done
+	assert.Contains(t, module.groups, "testgroup", "Expected testgroup to be recorded as copied from the primary")
+}
+
+func TestBurrowClient_sync_Allowlist(t *testing.T) {
+	up := true
+	server := startPrimary(t, &up)
+	defer server.Close()
+
+	module := fixtureBurrowModule(server.URL)
+	viper.Set("consumer.test.primary-cluster", "primary")
+	viper.Set("consumer.test.group-allowlist", "^other")
+	module.Configure("test", "consumer.test")
+
+	// Nothing is sent for othergroup, as the primary does not have it
+	assert.True(t, module.sync(), "Expected the replica not to take over")
+	assert.Empty(t, module.groups, "Expected no groups to be copied")
+}
+
+func TestBurrowClient_sync_TakeOver(t *testing.T) {
+	up := true
+	server := startPrimary(t, &up)
+	defer server.Close()
+
+	module := fixtureBurrowModule(server.URL)
+	viper.Set("consumer.test.primary-cluster", "primary")
+	viper.Set("consumer.test.heartbeat-failures", 2)
+	module.Configure("test", "consumer.test")
+
+	standby := &helpers.MockModule{}
+	standby.On("Start").Return(nil)
+	standby.On("Stop").Return(nil)
+	module.addStandby("standby", standby)
+
+	module.groups["testgroup"] = struct{}{}
+	up = false
+
+	// The first failure does not take over
+	assert.True(t, module.sync(), "Expected the replica not to take over after one failure")
+	standby.AssertNotCalled(t, "Start")
+
+	// The second one does, and the copied group is removed before the standby is started
+	done := make(chan struct{})
+	go func() {
+		assert.False(t, module.sync(), "Expected the replica to take over")
+		close(done)
+	}()
+	request := <-module.App.StorageChannel
+	assert.Equalf(t, protocol.StorageSetDeleteGroup, request.RequestType, "Expected request sent with type StorageSetDeleteGroup, not %v", request.RequestType)
+	assert.Equalf(t, "test", request.Cluster, "Expected request sent with cluster test, not %v", request.Cluster)
+	assert.Equalf(t, "testgroup", request.Group, "Expected request sent with Group testgroup, not %v", request.Group)
+
+	<-done
+	assert.True(t, module.active, "Expected the replica to be active")
+	standby.AssertCalled(t, "Start")
+
+	// Stopping the replica stops the standby
+	module.running.Add(1)
+	go module.mainLoop()
+	module.Stop()
+	standby.AssertCalled(t, "Stop")
+}
+
+func TestBurrowClient_sync_Recover(t *testing.T) {
+	up := false
+	server := startPrimary(t, &up)
+	defer server.Close()
+
+	module := fixtureBurrowModule(server.URL)
+	viper.Set("consumer.test.primary-cluster", "primary")
+	viper.Set("consumer.test.group-allowlist", "^other")
+	module.Configure("test", "consumer.test")
+
+	// A successful heartbeat resets the failure count
+	module.sync()
+	module.sync()
+	assert.Equal(t, 2, module.failures, "Expected 2 failures")
+	up = true
+	module.sync()
+	assert.Equal(t, 0, module.failures, "Expected the failures to be reset")
+	assert.False(t, module.active, "Expected the replica not to be active")
+}
+
+func TestBurrowClient_StartStop(t *testing.T) {
+	up := true
+	server := startPrimary(t, &up)
+	defer server.Close()
+
+	module := fixtureBurrowModule(server.URL)
+	viper.Set("consumer.test.primary-cluster", "primary")
+	viper.Set("consumer.test.group-allowlist", "^other")
+	module.Configure("test", "consumer.test")
+
+	standby := &helpers.MockModule{}
+	module.addStandby("standby", standby)
+
+	assert.Nil(t, module.Start(), "Expected Start to return no error")
+	time.Sleep(100 * time.Millisecond)
+	assert.Nil(t, module.Stop(), "Expected Stop to return no error")
+
+	// The standby was never started, so it is not stopped
+	standby.AssertNotCalled(t, "Stop")
+}
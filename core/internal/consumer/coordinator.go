@@ -19,10 +19,18 @@
 // * kafka - Consume a Kafka cluster's __consumer_offsets topic to get consumer information (new consumer)
 //
 // * kafka_zk - Parse the /consumers tree of a Kafka cluster's metadata to get consumer information (old consumer)
+//
+// * kafka_connect - Consume a Kafka Connect cluster's offsets topic to get source connector offsets as consumer groups
+//
+// * http - Poll an HTTP endpoint of an external offset store to get consumer information
+//
+// * burrow - Copy consumer information from the HTTP API of a primary Burrow, and start standby modules if it fails
 package consumer
 
 import (
 	"errors"
+	"fmt"
+	"sync"
 
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
@@ -44,7 +52,21 @@ type Coordinator struct {
 	// fields that are appropriate to identify this coordinator
 	Log *zap.Logger
 
-	modules map[string]protocol.Module
+	modules     map[string]protocol.Module
+	modulesLock sync.RWMutex
+
+	// Set to getModuleForClass in Configure, if not set (configurable to enable testing)
+	newModuleFunc func(*protocol.ApplicationContext, string, string) protocol.Module
+
+	// The configurations that the running consumer modules for discovered clusters were configured from
+	discoveredConfigs map[string]*viper.Viper
+}
+
+// discoverableModule is a consumer module that can be configured from a viper instance other than the global
+// configuration, which the consumer modules for discovered clusters must be
+type discoverableModule interface {
+	protocol.Module
+	configureFrom(config *viper.Viper, name, configRoot string)
 }
 
 // getModuleForClass returns the correct module based on the passed className. As part of the Configure steps, if there
@@ -68,6 +90,21 @@ func getModuleForClass(app *protocol.ApplicationContext, moduleName, className s
 			App: app,
 			Log: logger,
 		}
+	case "kafka_connect":
+		return &KafkaConnectClient{
+			App: app,
+			Log: logger,
+		}
+	case "http":
+		return &HTTPClient{
+			App: app,
+			Log: logger,
+		}
+	case "burrow":
+		return &BurrowClient{
+			App: app,
+			Log: logger,
+		}
 	default:
 		panic("Unknown consumer className provided: " + className)
 	}
@@ -76,47 +113,186 @@ func getModuleForClass(app *protocol.ApplicationContext, moduleName, className s
 // Configure is called to create each of the configured consumer modules and call their Configure funcs to validate
 // their individual configurations and set them up. If there are any problems, it is expected that these funcs will
 // panic with a descriptive error message, as configuration failures are not recoverable errors.
+//
+// A consumer module that does not set a client-profile uses the client-profile of the cluster that it belongs to. The
+// client-profile that a consumer module uses, whether set or inherited, must be configured.
+//
+// The standby modules of a burrow consumer are handed to that consumer, and are not started or stopped by the
+// coordinator. A standby module must be configured, must not be a burrow consumer itself, and can only be the standby
+// of a single burrow consumer.
+//
+// The consumer modules for discovered clusters are not in the configuration, and are configured when they are added,
+// once the coordinator is started.
 func (cc *Coordinator) Configure() {
 	cc.Log.Info("configuring")
 
 	cc.modules = make(map[string]protocol.Module)
+	cc.discoveredConfigs = make(map[string]*viper.Viper)
+	if cc.newModuleFunc == nil {
+		cc.newModuleFunc = getModuleForClass
+	}
 
 	// Create all configured cluster modules, add to list of clusters
 	modules := viper.GetStringMap("consumer")
 	for name := range modules {
-		configRoot := "consumer." + name
-		if !viper.IsSet("cluster." + viper.GetString(configRoot+".cluster")) {
-			panic("Consumer '" + name + "' references an unknown cluster '" + viper.GetString(configRoot+".cluster") + "'")
-		}
-		module := getModuleForClass(cc.App, name, viper.GetString(configRoot+".class-name"))
-		module.Configure(name, configRoot)
+		module := cc.newModule(viper.GetViper(), name)
+		module.Configure(name, "consumer."+name)
 		cc.modules[name] = module
 	}
+
+	replicas := make(map[string]*BurrowClient)
+	for name, module := range cc.modules {
+		if replica, ok := module.(*BurrowClient); ok {
+			replicas[name] = replica
+		}
+	}
+	for name, replica := range replicas {
+		for _, standbyName := range replica.standbyNames {
+			standby, ok := cc.modules[standbyName]
+			if !ok {
+				panic("Consumer '" + name + "' references an unknown or already used standby consumer '" + standbyName + "'")
+			}
+			if _, ok := standby.(*BurrowClient); ok {
+				panic("Consumer '" + name + "' cannot use the burrow consumer '" + standbyName + "' as a standby")
+			}
+			replica.addStandby(standbyName, standby)
+			delete(cc.modules, standbyName)
+		}
+	}
+}
+
+// newModule creates the consumer module from the provided configuration, after checking the cluster and client-profile
+// that it references, and setting the client-profile default on the configuration. If there are any problems, it will
+// panic.
+func (cc *Coordinator) newModule(config *viper.Viper, name string) protocol.Module {
+	configRoot := "consumer." + name
+	cluster := config.GetString(configRoot + ".cluster")
+	if !config.IsSet("cluster." + cluster) {
+		panic("Consumer '" + name + "' references an unknown cluster '" + cluster + "'")
+	}
+	config.SetDefault(configRoot+".client-profile", config.GetString("cluster."+cluster+".client-profile"))
+	profile := config.GetString(configRoot + ".client-profile")
+	if profile != "" && !config.IsSet("client-profile."+profile) {
+		panic("Consumer '" + name + "' references an unknown client-profile '" + profile + "'")
+	}
+	return cc.newModuleFunc(cc.App, name, config.GetString(configRoot+".class-name"))
 }
 
-// Start calls each of the configured consumer modules' underlying Start funcs. As the coordinator itself has no ongoing
-// work to do, it does not start any other goroutines. If any module Start returns an error, this func stops immediately
-// and returns that error to the caller. No further modules will be loaded after that.
+// Start calls each of the configured consumer modules' underlying Start funcs. If any module Start returns an error,
+// this func stops immediately and returns that error to the caller. No further modules will be loaded after that.
+//
+// Once the configured modules are started, the consumer modules for discovered clusters are started, and the
+// coordinator watches for them to be added and removed as the discovered clusters change. A discovered consumer module
+// that fails to configure or start is logged and skipped, as the cluster that it belongs to is still running.
 func (cc *Coordinator) Start() error {
 	cc.Log.Info("starting")
 
 	// Start Consumer modules
+	cc.modulesLock.Lock()
 	err := helpers.StartCoordinatorModules(cc.modules)
+	cc.modulesLock.Unlock()
 	if err != nil {
 		return errors.New("Error starting consumer module: " + err.Error())
 	}
+
+	// The watch is called with the discovered consumers that were added before now
+	cc.App.DiscoveredConsumers.Watch(cc.watchDiscovered)
+
 	// All consumers started, Burrow is ready to serve requests
 	// set the readiness probe
 	cc.App.AppReady = true
 	return nil
 }
 
+// watchDiscovered is the DiscoveredConsumerWatch for the coordinator, which starts and stops the consumer modules for
+// discovered clusters
+func (cc *Coordinator) watchDiscovered(name string, config *viper.Viper) {
+	cc.modulesLock.Lock()
+	defer cc.modulesLock.Unlock()
+
+	if config != nil {
+		cc.startDiscovered(name, config)
+	} else if module, ok := cc.modules[name]; ok {
+		if err := module.Stop(); err != nil {
+			cc.Log.Warn("failed to stop discovered consumer", zap.String("name", name), zap.Error(err))
+		}
+		delete(cc.modules, name)
+		delete(cc.discoveredConfigs, name)
+		cc.Log.Info("discovered consumer stopped", zap.String("name", name))
+	}
+}
+
+// startDiscovered configures and starts the consumer module for a discovered cluster from its configuration, if it is
+// not already running. It is assumed that a write lock on the modules is already held.
+func (cc *Coordinator) startDiscovered(name string, config *viper.Viper) {
+	if _, ok := cc.modules[name]; ok {
+		return
+	}
+
+	module, err := cc.configureDiscovered(name, config)
+	if err != nil {
+		cc.Log.Error("failed to configure discovered consumer", zap.String("name", name), zap.Error(err))
+		return
+	}
+	if err := module.Start(); err != nil {
+		cc.Log.Error("failed to start discovered consumer", zap.String("name", name), zap.Error(err))
+		return
+	}
+	cc.modules[name] = module
+	cc.discoveredConfigs[name] = config
+	cc.Log.Info("discovered consumer started", zap.String("name", name))
+}
+
+// configureDiscovered creates the consumer module for a discovered cluster, and configures it from the provided
+// configuration, which the module must support. Any panic from configuring the module is returned as an error.
+func (cc *Coordinator) configureDiscovered(name string, config *viper.Viper) (module protocol.Module, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	className := config.GetString("consumer." + name + ".class-name")
+	discoverable, ok := cc.newModule(config, name).(discoverableModule)
+	if !ok {
+		return nil, errors.New("the " + className + " class cannot be used for a discovered consumer")
+	}
+	discoverable.configureFrom(config, name, "consumer."+name)
+	return discoverable, nil
+}
+
+// ReloadFilters calls each of the configured consumer modules' underlying ReloadFilters funcs with the provided
+// configuration, for the modules that support it. The consumer modules for discovered clusters are reloaded from their
+// own configurations, as they are not in the configuration file. All modules are reloaded even if one fails, and the
+// last error is returned to the caller.
+func (cc *Coordinator) ReloadFilters(config *viper.Viper) error {
+	cc.Log.Info("reloading filters")
+
+	cc.modulesLock.RLock()
+	defer cc.modulesLock.RUnlock()
+	return helpers.ReloadCoordinatorModuleFilters(cc.modules, config, cc.discoveredConfigs)
+}
+
+// CheckConnection calls each of the configured consumer modules' underlying CheckConnection funcs, for the modules that
+// support it, instead of starting them. All modules are checked even if one fails, and the errors are returned to the
+// caller. The standby modules of burrow consumers are not checked.
+func (cc *Coordinator) CheckConnection() error {
+	cc.modulesLock.RLock()
+	defer cc.modulesLock.RUnlock()
+	return helpers.CheckCoordinatorModuleConnections("consumer", cc.modules)
+}
+
 // Stop calls each of the configured consumer modules' underlying Stop funcs. It is expected that the module Stop will
 // not return until the module has been completely stopped. While an error can be returned, this func always returns no
 // error, as a failure during stopping is not a critical failure
 func (cc *Coordinator) Stop() error {
 	cc.Log.Info("stopping")
 
+	// Stop watching first, so that no discovered consumer is started while the modules are stopped
+	cc.App.DiscoveredConsumers.Unwatch()
+
+	cc.modulesLock.Lock()
+	defer cc.modulesLock.Unlock()
+
 	// The individual consumer modules can choose whether or not to implement a wait in the Stop routine
 	helpers.StopCoordinatorModules(cc.modules)
 	return nil
@@ -59,6 +59,29 @@ func TestCoordinator_Configure_BadCluster(t *testing.T) {
 	assert.Panics(t, coordinator.Configure, "Expected panic")
 }
 
+func TestCoordinator_Configure_ClusterClientProfile(t *testing.T) {
+	coordinator := fixtureCoordinator()
+	viper.Set("client-profile.clusterprofile.client-id", "clusterid")
+	viper.Set("client-profile.consumerprofile.client-id", "consumerid")
+	viper.Set("cluster.test.client-profile", "clusterprofile")
+	viper.Set("consumer.anothertest.class-name", "kafka")
+	viper.Set("consumer.anothertest.servers", []string{"broker1.example.com:1234"})
+	viper.Set("consumer.anothertest.cluster", "test")
+	viper.Set("consumer.anothertest.client-profile", "consumerprofile")
+	coordinator.Configure()
+
+	assert.Equal(t, "clusterprofile", coordinator.modules["test"].(*KafkaClient).clientProfile, "Expected the client-profile of the cluster")
+	assert.Equal(t, "clusterid", coordinator.modules["test"].(*KafkaClient).saramaConfig.ClientID)
+	assert.Equal(t, "consumerprofile", coordinator.modules["anothertest"].(*KafkaClient).clientProfile, "Expected the client-profile of the consumer")
+}
+
+func TestCoordinator_Configure_BadClientProfile(t *testing.T) {
+	coordinator := fixtureCoordinator()
+	viper.Set("cluster.test.client-profile", "noprofile")
+
+	assert.Panics(t, coordinator.Configure, "Expected panic")
+}
+
 func TestCoordinator_Configure_TwoModules(t *testing.T) {
 	coordinator := fixtureCoordinator()
 	viper.Set("consumer.anothertest.class-name", "kafka")
@@ -83,3 +106,106 @@ func TestCoordinator_StartStop(t *testing.T) {
 	coordinator.Stop()
 	mockModule.AssertCalled(t, "Stop")
 }
+
+// mockDiscoverableModule is a MockModule that can also be configured for a discovered cluster
+type mockDiscoverableModule struct {
+	helpers.MockModule
+}
+
+func (m *mockDiscoverableModule) configureFrom(config *viper.Viper, name, configRoot string) {
+	m.Called(name, configRoot)
+}
+
+// fixtureDiscoveredConfig returns the configuration of a discovered cluster with a consumer of the class
+func fixtureDiscoveredConfig(name, className, cluster string) *viper.Viper {
+	config := viper.New()
+	config.Set("cluster."+name+".class-name", "kafka")
+	config.Set("consumer."+name+".class-name", className)
+	config.Set("consumer."+name+".cluster", cluster)
+	return config
+}
+
+func TestCoordinator_DiscoveredConsumers(t *testing.T) {
+	coordinator := fixtureCoordinator()
+	coordinator.App.DiscoveredConsumers = &protocol.DiscoveredConsumers{}
+	discoveredModules := make(map[string]*mockDiscoverableModule)
+	coordinator.newModuleFunc = func(app *protocol.ApplicationContext, name, className string) protocol.Module {
+		if className != "kafka" || name == "test" {
+			return getModuleForClass(app, name, className)
+		}
+		mockModule := &mockDiscoverableModule{}
+		mockModule.On("configureFrom", name, "consumer."+name).Return()
+		mockModule.On("Start").Return(nil)
+		mockModule.On("Stop").Return(nil)
+		discoveredModules[name] = mockModule
+		return mockModule
+	}
+	eastConfig := fixtureDiscoveredConfig("east", "kafka", "east")
+	eastConfig.Set("cluster.east.client-profile", "eastprofile")
+	eastConfig.Set("client-profile.eastprofile.client-id", "eastid")
+	coordinator.App.DiscoveredConsumers.Add("east", eastConfig)
+	coordinator.Configure()
+	assert.NotContains(t, coordinator.modules, "east", "Expected the discovered consumer to not be configured with the others")
+
+	// Swap out the configured module with a mock for testing
+	mockModule := &helpers.MockModule{}
+	mockModule.On("Start").Return(nil)
+	mockModule.On("Stop").Return(nil)
+	coordinator.modules["test"] = mockModule
+
+	// Consumers that were added before the coordinator started are started with it, from their own configuration
+	assert.Nil(t, coordinator.Start(), "Expected Start to not return an error")
+	assert.Contains(t, coordinator.modules, "east", "Expected the discovered consumer to be started")
+	discoveredModules["east"].AssertCalled(t, "configureFrom", "east", "consumer.east")
+	discoveredModules["east"].AssertCalled(t, "Start")
+	assert.Equal(t, "eastprofile", eastConfig.GetString("consumer.east.client-profile"), "Expected the client-profile default to be set on the configuration of the consumer")
+	assert.False(t, viper.IsSet("consumer.east"), "Expected the global configuration to be unchanged")
+
+	// Consumers added later are started, and bad ones are skipped
+	assert.NotPanics(t, func() {
+		coordinator.App.DiscoveredConsumers.Add("west", fixtureDiscoveredConfig("west", "kafka", "nocluster"))
+	}, "Expected a bad discovered consumer to not panic")
+	assert.NotContains(t, coordinator.modules, "west", "Expected the bad discovered consumer to be skipped")
+	coordinator.App.DiscoveredConsumers.Add("north", fixtureDiscoveredConfig("north", "http", "north"))
+	assert.NotContains(t, coordinator.modules, "north", "Expected the discovered consumer of a class that cannot be discovered to be skipped")
+
+	coordinator.App.DiscoveredConsumers.Remove("east")
+	assert.NotContains(t, coordinator.modules, "east", "Expected the removed consumer to be stopped")
+	discoveredModules["east"].AssertCalled(t, "Stop")
+
+	coordinator.Stop()
+	mockModule.AssertCalled(t, "Stop")
+	coordinator.App.DiscoveredConsumers.Add("east", eastConfig)
+	assert.NotContains(t, coordinator.modules, "east", "Expected no consumer to be started once the coordinator is stopped")
+}
+
+func TestCoordinator_Configure_Standby(t *testing.T) {
+	coordinator := fixtureCoordinator()
+	viper.Set("consumer.replica.class-name", "burrow")
+	viper.Set("consumer.replica.cluster", "test")
+	viper.Set("consumer.replica.url", "http://primary.example.com:8000")
+	viper.Set("consumer.replica.standby", []string{"test"})
+	coordinator.Configure()
+
+	// The standby module is held by the replica, rather than the coordinator
+	assert.Lenf(t, coordinator.modules, 1, "Expected 1 module configured, not %v", len(coordinator.modules))
+	replica, ok := coordinator.modules["replica"].(*BurrowClient)
+	assert.True(t, ok, "Expected the replica module to be a BurrowClient")
+	assert.Contains(t, replica.standby, "test", "Expected the test module to be a standby of the replica")
+}
+
+func TestCoordinator_Configure_BadStandby(t *testing.T) {
+	coordinator := fixtureCoordinator()
+	viper.Set("consumer.replica.class-name", "burrow")
+	viper.Set("consumer.replica.cluster", "test")
+	viper.Set("consumer.replica.url", "http://primary.example.com:8000")
+	viper.Set("consumer.replica.standby", []string{"nomodule"})
+	assert.Panics(t, coordinator.Configure, "Expected panic")
+
+	coordinator = fixtureCoordinator()
+	viper.Set("consumer.replica.class-name", "burrow")
+	viper.Set("consumer.replica.cluster", "test")
+	viper.Set("consumer.replica.url", "http://primary.example.com:8000")
+	viper.Set("consumer.replica.standby", []string{"replica"})
+	assert.Panics(t, coordinator.Configure, "Expected panic")
+}
@@ -0,0 +1,263 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package consumer
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/linkedin/Burrow/core/internal/helpers"
+	"github.com/linkedin/Burrow/core/protocol"
+)
+
+// HTTPClient is a consumer module which periodically polls an HTTP endpoint for consumer offsets. This is for
+// consumers that commit their offsets to an external offset store, rather than to Kafka. The endpoint must return a
+// JSON object with an "offsets" list, where each entry has the "group", "topic", "partition", and "offset" fields, and
+// optionally a "timestamp" field with the time of the commit in milliseconds. An entry without a timestamp is given the
+// time of the poll, and is only sent to storage when its offset is not the same as in the last poll, so that an offset
+// that has not moved is not stored as a new commit each poll.
+type HTTPClient struct {
+	// App is a pointer to the application context. This stores the channel to the storage subsystem
+	App *protocol.ApplicationContext
+
+	// Log is a logger that has been configured for this module to use. Normally, this means it has been set up with
+	// fields that are appropriate to identify this coordinator
+	Log *zap.Logger
+
+	name           string
+	configRoot     string
+	cluster        string
+	url            string
+	interval       int
+	authHeaderName string
+	authHeader     string
+	httpClient     *http.Client
+	groupAllowlist *regexp.Regexp
+	groupDenylist  *regexp.Regexp
+	filterLock     sync.RWMutex
+
+	quitChannel chan struct{}
+	running     sync.WaitGroup
+
+	// The offsets without a timestamp from the last poll. This is only used by the poll, so it needs no lock
+	lastOffsets map[httpOffsetKey]int64
+}
+
+type httpOffsetKey struct {
+	group     string
+	topic     string
+	partition int32
+}
+
+type httpOffsetsResponse struct {
+	Offsets []*httpOffset `json:"offsets"`
+}
+
+type httpOffset struct {
+	Group     string `json:"group"`
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// Configure validates the configuration for the consumer. At minimum, there must be a cluster name to which these
+// consumers belong, as well as the url to poll. If not explicitly configured, the endpoint is polled every 30 seconds,
+// with a timeout of 5 seconds. If auth-header is set, it is sent as the value of the Authorization header, or of the
+// header named by auth-header-name. If the cluster name is unknown, if the url is missing, or if the group allowlist or
+// denylist is invalid, this func will panic.
+func (module *HTTPClient) Configure(name, configRoot string) {
+	module.Log.Info("configuring")
+
+	module.name = name
+	module.quitChannel = make(chan struct{})
+	module.running = sync.WaitGroup{}
+	module.lastOffsets = make(map[httpOffsetKey]int64)
+
+	module.cluster = viper.GetString(configRoot + ".cluster")
+	if !viper.IsSet("cluster." + module.cluster) {
+		panic("Consumer '" + name + "' references an unknown cluster '" + module.cluster + "'")
+	}
+
+	module.url = viper.GetString(configRoot + ".url")
+	if module.url == "" {
+		panic("No url specified for consumer " + module.name)
+	}
+
+	// Set defaults for configs if needed, and get them
+	viper.SetDefault(configRoot+".interval", 30)
+	viper.SetDefault(configRoot+".timeout", 5)
+	viper.SetDefault(configRoot+".auth-header-name", "Authorization")
+	module.interval = viper.GetInt(configRoot + ".interval")
+	if module.interval <= 0 {
+		panic("Consumer '" + name + "' must have an interval greater than zero")
+	}
+	module.authHeaderName = viper.GetString(configRoot + ".auth-header-name")
+	module.authHeader = viper.GetString(configRoot + ".auth-header")
+	module.httpClient = &http.Client{
+		Timeout: time.Duration(viper.GetInt(configRoot+".timeout")) * time.Second,
+	}
+
+	module.configRoot = configRoot
+	if err := module.ReloadFilters(viper.GetViper()); err != nil {
+		module.Log.Panic(err.Error())
+		panic(err)
+	}
+}
+
+// Start polls the endpoint once, and then starts a goroutine that polls it every interval. Errors from the endpoint
+// are logged and retried on the next interval, so this func always returns no error.
+func (module *HTTPClient) Start() error {
+	module.Log.Info("starting")
+
+	module.running.Add(1)
+	go module.mainLoop()
+	return nil
+}
+
+// Stop stops the polling goroutine, and waits for it to exit.
+func (module *HTTPClient) Stop() error {
+	module.Log.Info("stopping")
+
+	close(module.quitChannel)
+	module.running.Wait()
+	return nil
+}
+
+// ReloadFilters compiles the group-allowlist and group-denylist configurations for the consumer, and replaces the
+// current filters with them. If either of them is invalid, an error is returned and the current filters are kept. The
+// new filters apply from the next poll.
+func (module *HTTPClient) ReloadFilters(config *viper.Viper) error {
+	groupAllowlist, err := helpers.CompileConfigRegexp(config, module.configRoot+".group-allowlist")
+	if err != nil {
+		return err
+	}
+	groupDenylist, err := helpers.CompileConfigRegexp(config, module.configRoot+".group-denylist")
+	if err != nil {
+		return err
+	}
+
+	module.filterLock.Lock()
+	defer module.filterLock.Unlock()
+	module.groupAllowlist = groupAllowlist
+	module.groupDenylist = groupDenylist
+	return nil
+}
+
+func (module *HTTPClient) acceptConsumerGroup(group string) bool {
+	module.filterLock.RLock()
+	defer module.filterLock.RUnlock()
+
+	if (module.groupAllowlist != nil) && (!module.groupAllowlist.MatchString(group)) {
+		return false
+	}
+	if (module.groupDenylist != nil) && module.groupDenylist.MatchString(group) {
+		return false
+	}
+	return true
+}
+
+func (module *HTTPClient) mainLoop() {
+	defer module.running.Done()
+
+	ticker := time.NewTicker(time.Duration(module.interval) * time.Second)
+	defer ticker.Stop()
+
+	module.poll()
+	for {
+		select {
+		case <-ticker.C:
+			module.poll()
+		case <-module.quitChannel:
+			return
+		}
+	}
+}
+
+// poll fetches the offsets from the endpoint and sends them to storage. Offsets without a timestamp are given the time
+// of the poll. The timestamp is also used as the commit order, so an offset that has not been committed again since
+// the last poll is dropped by storage, as it would be for Kafka.
+func (module *HTTPClient) poll() {
+	offsets, err := module.fetchOffsets()
+	if err != nil {
+		module.Log.Error("failed to fetch offsets", zap.String("url", module.url), zap.Error(err))
+		return
+	}
+
+	pollTime := time.Now().Unix() * 1000
+	lastOffsets := make(map[httpOffsetKey]int64)
+	for _, offset := range offsets {
+		if (offset == nil) || (offset.Group == "") || (offset.Topic == "") {
+			module.Log.Warn("failed to decode", zap.String("reason", "missing group or topic"))
+			continue
+		}
+		if !module.acceptConsumerGroup(offset.Group) {
+			module.Log.Debug("dropped", zap.String("group", offset.Group), zap.String("reason", "allowlist"))
+			continue
+		}
+
+		timestamp := offset.Timestamp
+		if timestamp <= 0 {
+			key := httpOffsetKey{group: offset.Group, topic: offset.Topic, partition: offset.Partition}
+			lastOffsets[key] = offset.Offset
+			if last, ok := module.lastOffsets[key]; ok && (last == offset.Offset) {
+				continue
+			}
+			timestamp = pollTime
+		}
+		helpers.TimeoutSendStorageRequest(module.App.StorageChannel, &protocol.StorageRequest{
+			RequestType: protocol.StorageSetConsumerOffset,
+			Cluster:     module.cluster,
+			Topic:       offset.Topic,
+			Partition:   offset.Partition,
+			Group:       offset.Group,
+			Timestamp:   timestamp,
+			Offset:      offset.Offset,
+			Order:       timestamp,
+		}, 1)
+	}
+	module.lastOffsets = lastOffsets
+	module.Log.Debug("polled offsets", zap.Int("count", len(offsets)))
+}
+
+func (module *HTTPClient) fetchOffsets() ([]*httpOffset, error) {
+	req, err := http.NewRequest(http.MethodGet, module.url, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if module.authHeader != "" {
+		req.Header.Set(module.authHeaderName, module.authHeader)
+	}
+
+	resp, err := module.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("unexpected response status " + strconv.Itoa(resp.StatusCode))
+	}
+
+	response := &httpOffsetsResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return nil, err
+	}
+	return response.Offsets, nil
+}
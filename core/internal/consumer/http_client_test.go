@@ -0,0 +1,218 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package consumer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/linkedin/Burrow/core/protocol"
+)
+
+const httpClientTestResponse = `{"offsets": [
+	{"group": "testgroup", "topic": "testtopic", "partition": 0, "offset": 9223372036854775806, "timestamp": 1500000000000},
+	{"group": "othergroup", "topic": "testtopic", "partition": 1, "offset": 1234},
+	{"group": "", "topic": "testtopic", "partition": 1, "offset": 1234}
+]}`
+
+func fixtureHTTPModule(url string) *HTTPClient {
+	module := HTTPClient{
+		Log: zap.NewNop(),
+	}
+	module.App = &protocol.ApplicationContext{
+		StorageChannel: make(chan *protocol.StorageRequest),
+	}
+
+	viper.Reset()
+	viper.Set("cluster.test.class-name", "kafka")
+	viper.Set("cluster.test.servers", []string{"broker1.example.com:1234"})
+	viper.Set("consumer.test.class-name", "http")
+	viper.Set("consumer.test.cluster", "test")
+	viper.Set("consumer.test.url", url)
+
+	return &module
+}
+
+func TestHTTPClient_ImplementsModule(t *testing.T) {
+	assert.Implements(t, (*protocol.Module)(nil), new(HTTPClient))
+	assert.Implements(t, (*protocol.FilterReloader)(nil), new(HTTPClient))
+}
+
+func TestHTTPClient_Configure(t *testing.T) {
+	module := fixtureHTTPModule("http://offsets.example.com/offsets")
+	module.Configure("test", "consumer.test")
+	assert.Equal(t, 30, module.interval, "Default interval of 30 did not get set")
+	assert.Equal(t, 5*time.Second, module.httpClient.Timeout, "Default timeout of 5 seconds did not get set")
+	assert.Equal(t, "Authorization", module.authHeaderName, "Default auth header name of Authorization did not get set")
+}
+
+func TestHTTPClient_Configure_BadCluster(t *testing.T) {
+	module := fixtureHTTPModule("http://offsets.example.com/offsets")
+	viper.Set("consumer.test.cluster", "nocluster")
+	assert.Panics(t, func() { module.Configure("test", "consumer.test") }, "The code did not panic")
+}
+
+func TestHTTPClient_Configure_NoURL(t *testing.T) {
+	module := fixtureHTTPModule("")
+	assert.Panics(t, func() { module.Configure("test", "consumer.test") }, "The code did not panic")
+}
+
+func TestHTTPClient_Configure_BadInterval(t *testing.T) {
+	module := fixtureHTTPModule("http://offsets.example.com/offsets")
+	viper.Set("consumer.test.interval", -1)
+	assert.Panics(t, func() { module.Configure("test", "consumer.test") }, "The code did not panic")
+}
+
+func TestHTTPClient_Configure_BadRegexp(t *testing.T) {
+	module := fixtureHTTPModule("http://offsets.example.com/offsets")
+	viper.Set("consumer.test.group-allowlist", "[")
+	assert.Panics(t, func() { module.Configure("test", "consumer.test") }, "The code did not panic")
+}
+
+func TestHTTPClient_poll(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer testtoken", r.Header.Get("X-Offsets-Auth"), "Expected the auth header to be sent")
+		w.Write([]byte(httpClientTestResponse))
+	}))
+	defer server.Close()
+
+	module := fixtureHTTPModule(server.URL)
+	viper.Set("consumer.test.auth-header-name", "X-Offsets-Auth")
+	viper.Set("consumer.test.auth-header", "Bearer testtoken")
+	module.Configure("test", "consumer.test")
+
+	go module.poll()
+	request := <-module.App.StorageChannel
+	assert.Equalf(t, protocol.StorageSetConsumerOffset, request.RequestType, "Expected request sent with type StorageSetConsumerOffset, not %v", request.RequestType)
+	assert.Equalf(t, "test", request.Cluster, "Expected request sent with cluster test, not %v", request.Cluster)
+	assert.Equalf(t, "testgroup", request.Group, "Expected request sent with Group testgroup, not %v", request.Group)
+	assert.Equalf(t, "testtopic", request.Topic, "Expected request sent with topic testtopic, not %v", request.Topic)
+	assert.Equalf(t, int32(0), request.Partition, "Expected request sent with partition 0, not %v", request.Partition)
+	assert.Equalf(t, int64(9223372036854775806), request.Offset, "Expected request sent with offset 9223372036854775806, not %v", request.Offset)
+	assert.Equalf(t, int64(1500000000000), request.Timestamp, "Expected request sent with timestamp 1500000000000, not %v", request.Timestamp)
+	assert.Equalf(t, request.Timestamp, request.Order, "Expected request sent with the timestamp as the order, not %v", request.Order)
+
+	// An offset without a timestamp is given the time of the poll
+	request = <-module.App.StorageChannel
+	assert.Equalf(t, "othergroup", request.Group, "Expected request sent with Group othergroup, not %v", request.Group)
+	assert.Equalf(t, int32(1), request.Partition, "Expected request sent with partition 1, not %v", request.Partition)
+	assert.InDelta(t, time.Now().Unix()*1000, request.Timestamp, 10000, "Expected request sent with the time of the poll")
+
+	// The entry without a group is skipped
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case <-module.App.StorageChannel:
+		t.Fatal("Expected no additional value waiting on storage channel")
+	default:
+		break
+	}
+}
+
+func TestHTTPClient_poll_UnchangedOffset(t *testing.T) {
+	response := httpClientTestResponse
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(response))
+	}))
+	defer server.Close()
+
+	module := fixtureHTTPModule(server.URL)
+	module.App.StorageChannel = make(chan *protocol.StorageRequest, 10)
+	module.Configure("test", "consumer.test")
+
+	module.poll()
+	assert.Lenf(t, module.App.StorageChannel, 2, "Expected 2 requests sent, not %v", len(module.App.StorageChannel))
+	<-module.App.StorageChannel
+	<-module.App.StorageChannel
+
+	// The offset without a timestamp has not changed, so only the offset with a timestamp is sent again
+	module.poll()
+	if assert.Lenf(t, module.App.StorageChannel, 1, "Expected 1 request sent, not %v", len(module.App.StorageChannel)) {
+		request := <-module.App.StorageChannel
+		assert.Equalf(t, "testgroup", request.Group, "Expected request sent with Group testgroup, not %v", request.Group)
+	}
+
+	// Once it changes, it is sent again
+	response = `{"offsets": [{"group": "othergroup", "topic": "testtopic", "partition": 1, "offset": 1300}]}`
+	module.poll()
+	if assert.Lenf(t, module.App.StorageChannel, 1, "Expected 1 request sent, not %v", len(module.App.StorageChannel)) {
+		request := <-module.App.StorageChannel
+		assert.Equalf(t, "othergroup", request.Group, "Expected request sent with Group othergroup, not %v", request.Group)
+		assert.Equalf(t, int64(1300), request.Offset, "Expected request sent with offset 1300, not %v", request.Offset)
+	}
+}
+
+func TestHTTPClient_poll_Allowlist(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(httpClientTestResponse))
+	}))
+	defer server.Close()
+
+	module := fixtureHTTPModule(server.URL)
+	viper.Set("consumer.test.group-allowlist", "^other")
+	module.Configure("test", "consumer.test")
+
+	go module.poll()
+	request := <-module.App.StorageChannel
+	assert.Equalf(t, "othergroup", request.Group, "Expected only othergroup to be sent, not %v", request.Group)
+}
+
+var httpClientErrorResponses = []struct {
+	status int
+	body   string
+}{
+	{http.StatusInternalServerError, httpClientTestResponse},
+	{http.StatusOK, "not json"},
+	{http.StatusOK, `{"offsets": [{"group": "testgroup", "partition": "zero"}]}`},
+}
+
+func TestHTTPClient_poll_Errors(t *testing.T) {
+	for _, response := range httpClientErrorResponses {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(response.status)
+			w.Write([]byte(response.body))
+		}))
+
+		module := fixtureHTTPModule(server.URL)
+		module.Configure("test", "consumer.test")
+
+		// Should not send anything, or timeout
+		module.poll()
+		server.Close()
+	}
+}
+
+func TestHTTPClient_StartStop(t *testing.T) {
+	polled := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"offsets": []}`))
+		select {
+		case polled <- struct{}{}:
+		default:
+		}
+	}))
+	defer server.Close()
+
+	module := fixtureHTTPModule(server.URL)
+	module.Configure("test", "consumer.test")
+	assert.Nil(t, module.Start(), "Expected Start to return no error")
+
+	select {
+	case <-polled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the endpoint to be polled on start")
+	}
+	assert.Nil(t, module.Stop(), "Expected Stop to return no error")
+}
@@ -38,15 +38,31 @@ type KafkaClient struct {
 	Log *zap.Logger
 
 	name                  string
+	configRoot            string
 	cluster               string
 	servers               []string
 	offsetsTopic          string
 	startLatest           bool
 	backfillEarliest      bool
+	startLookback         int64
+	resumeFromStorage     bool
+	lookback              *lookbackState
+	reconcileStartup      bool
+	reconcileTimeout      int
+	reconcile             *reconcileState
+	offsetsLag            *offsetsLagState
 	reportedConsumerGroup string
+	clientProfile         string
 	saramaConfig          *sarama.Config
 	groupAllowlist        *regexp.Regexp
 	groupDenylist         *regexp.Regexp
+	topicFilter           *regexp.Regexp
+	topicExclude          *regexp.Regexp
+	filterLock            sync.RWMutex
+	healthThreshold       int
+
+	// Beats for each message that is read from the offsets topic
+	heartbeat protocol.Heartbeat
 
 	quitChannel chan struct{}
 	running     sync.WaitGroup
@@ -83,26 +99,258 @@ type backfillEndOffset struct {
 	Value int64
 }
 
+// lookbackState tracks the consumption of the offsets topic when starting from the start-lookback position. It records
+// which groups committed offsets since the start position, and closes done once every partition has reached the offset
+// that was the newest one at startup.
+type lookbackState struct {
+	startTime int64
+	targets   map[int32]int64
+	done      chan struct{}
+
+	lock      sync.Mutex
+	remaining int
+	seen      map[string]bool
+}
+
+func newLookbackState(startTime int64, targets map[int32]int64) *lookbackState {
+	state := &lookbackState{
+		startTime: startTime,
+		targets:   targets,
+		done:      make(chan struct{}),
+		remaining: len(targets),
+		seen:      make(map[string]bool),
+	}
+	if state.remaining == 0 {
+		close(state.done)
+	}
+	return state
+}
+
+func (state *lookbackState) markSeen(group string) {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	if state.seen != nil {
+		state.seen[group] = true
+	}
+}
+
+func (state *lookbackState) markOffset(partition int32, offset int64) {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	target, ok := state.targets[partition]
+	if !ok || offset < target {
+		return
+	}
+	delete(state.targets, partition)
+	state.remaining--
+	if state.remaining == 0 {
+		close(state.done)
+	}
+}
+
+// unseenGroups returns the groups from the list that have not committed offsets since the start position. Groups are no
+// longer tracked after this is called.
+func (state *lookbackState) unseenGroups(groups map[string]string) []string {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	unseen := make([]string, 0)
+	for group := range groups {
+		if !state.seen[group] {
+			unseen = append(unseen, group)
+		}
+	}
+	state.seen = nil
+	return unseen
+}
+
+// reconcileState holds back the consumer offsets that are read from the offsets topic at startup, until every partition
+// has been consumed up to the offset that was the newest one when the consumer started. Only the newest offset that is
+// read for each group, topic, and partition is held. Once the partitions have caught up, the held offsets are sent to
+// storage and the offsets that are read after that are sent as they arrive. This keeps storage, and the evaluations of
+// the groups, from seeing the stale offsets that are read early in the catch-up.
+type reconcileState struct {
+	targets map[int32]int64
+	done    chan struct{}
+
+	lock      sync.Mutex
+	remaining int
+	held      map[reconcileKey]*protocol.StorageRequest
+}
+
+type reconcileKey struct {
+	group     string
+	topic     string
+	partition int32
+}
+
+func newReconcileState(targets map[int32]int64) *reconcileState {
+	state := &reconcileState{
+		targets:   targets,
+		done:      make(chan struct{}),
+		remaining: len(targets),
+		held:      make(map[reconcileKey]*protocol.StorageRequest),
+	}
+	if state.remaining == 0 {
+		close(state.done)
+	}
+	return state
+}
+
+// hold keeps the offset request until the partitions have caught up, replacing any that is held for the same group,
+// topic, and partition and that was read before it. It returns false if the offsets are no longer being held, in which
+// case the request should be sent to storage.
+func (state *reconcileState) hold(request *protocol.StorageRequest) bool {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	if state.held == nil {
+		return false
+	}
+	key := reconcileKey{group: request.Group, topic: request.Topic, partition: request.Partition}
+	if current, ok := state.held[key]; !ok || (current.Order <= request.Order) {
+		state.held[key] = request
+	}
+	return true
+}
+
+// dropGroup removes the offsets that are held for the group, as it has been deleted
+func (state *reconcileState) dropGroup(group string) {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	for key := range state.held {
+		if key.group == group {
+			delete(state.held, key)
+		}
+	}
+}
+
+func (state *reconcileState) markOffset(partition int32, offset int64) {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	target, ok := state.targets[partition]
+	if !ok || offset < target {
+		return
+	}
+	delete(state.targets, partition)
+	state.remaining--
+	if state.remaining == 0 {
+		close(state.done)
+	}
+}
+
+// release returns the offsets that are held, and stops holding them.
+func (state *reconcileState) release() []*protocol.StorageRequest {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	released := make([]*protocol.StorageRequest, 0, len(state.held))
+	for _, request := range state.held {
+		released = append(released, request)
+	}
+	state.held = nil
+	return released
+}
+
+// offsetsLagState tracks how far the consumer is behind on each partition of the offsets topic, which is the high-water
+// mark of the partition minus the next offset to be read. The position of a partition that is started from the oldest or
+// newest offset is not known until the first message is read from it, and the lag of that partition is 0 until then.
+type offsetsLagState struct {
+	lock      sync.RWMutex
+	consumers map[int32]sarama.PartitionConsumer
+	positions map[int32]int64
+}
+
+func newOffsetsLagState() *offsetsLagState {
+	return &offsetsLagState{
+		consumers: make(map[int32]sarama.PartitionConsumer),
+		positions: make(map[int32]int64),
+	}
+}
+
+// addPartition tracks the lag of the partition, which is being consumed from the start offset
+func (state *offsetsLagState) addPartition(partition int32, consumer sarama.PartitionConsumer, start int64) {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	state.consumers[partition] = consumer
+	if start >= 0 {
+		state.positions[partition] = start
+	}
+}
+
+func (state *offsetsLagState) markOffset(partition int32, offset int64) {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	if offset+1 > state.positions[partition] {
+		state.positions[partition] = offset + 1
+	}
+}
+
+// lag returns the lag of each partition that is tracked, indexed by partition ID
+func (state *offsetsLagState) lag() []int64 {
+	state.lock.RLock()
+	defer state.lock.RUnlock()
+
+	var size int32
+	for partition := range state.consumers {
+		if partition >= size {
+			size = partition + 1
+		}
+	}
+	lag := make([]int64, size)
+	for partition, consumer := range state.consumers {
+		position, ok := state.positions[partition]
+		if !ok {
+			continue
+		}
+		if partitionLag := consumer.HighWaterMarkOffset() - position; partitionLag > 0 {
+			lag[partition] = partitionLag
+		}
+	}
+	return lag
+}
+
 // Configure validates the configuration for the consumer. At minimum, there must be a cluster name to which these
 // consumers belong, as well as a list of servers provided for the Kafka cluster, of the form host:port. If not
-// explicitly configured, the offsets topic is set to the default for Kafka, which is __consumer_offsets. If the
-// cluster name is unknown, or if the server list is missing or invalid, this func will panic.
+// explicitly configured, the offsets topic is set to the default for Kafka, which is __consumer_offsets. It can be set
+// to another topic with the same format, such as one that offsets are mirrored into from another cluster. If
+// topic-filter or topic-exclude are set, offsets and owners are only sent to storage for topics that match the filter
+// and do not match the exclusion. The consumer reports as unhealthy if no message has been read from the offsets topic
+// for health-threshold seconds, which defaults to 600 (0 to disable, such as for a cluster that rarely has commits).
+// If resume-from-storage is true, the offsets topic is consumed from the position that was last stored for the
+// burrow-<name> group, which is restored by a persistent storage module (such as redis) after a restart, instead of
+// from the beginning. Once the consumer has caught up, the committed offsets are fetched once for any group that the
+// storage module does not know about. This cannot be combined with start-latest or start-lookback. Both start-lookback
+// and resume-from-storage need a kafka-version of at least 0.10.2.0. If reconcile-startup is true, the consumer offsets
+// that are read while the offsets topic is caught up at startup are held back, and only the newest one for each group,
+// topic, and partition is sent to storage once every partition of the topic has reached the offset that was the newest
+// one at startup, or once reconcile-timeout seconds (default 600) have passed. This cannot be combined with
+// start-latest.
+// The lag of the consumer on the offsets topic is exported as a metric and from the HTTP server, as the consumer offsets
+// in storage for the cluster are stale if it grows.
+// If the cluster name is unknown, if the server list is missing or invalid, or if a regular expression is invalid, this
+// func will panic.
 func (module *KafkaClient) Configure(name, configRoot string) {
+	module.configureFrom(viper.GetViper(), name, configRoot)
+}
+
+// configureFrom is the same as Configure, except that the configuration is read from the provided viper instance, and
+// the defaults are set on it. The consumer modules for discovered clusters are configured this way, as the global
+// configuration is not changed once Burrow is running.
+func (module *KafkaClient) configureFrom(config *viper.Viper, name, configRoot string) {
 	module.Log.Info("configuring")
 
 	module.name = name
 	module.quitChannel = make(chan struct{})
 	module.running = sync.WaitGroup{}
 
-	module.cluster = viper.GetString(configRoot + ".cluster")
-	if !viper.IsSet("cluster." + module.cluster) {
+	module.cluster = config.GetString(configRoot + ".cluster")
+	if !config.IsSet("cluster." + module.cluster) {
 		panic("Consumer '" + name + "' references an unknown cluster '" + module.cluster + "'")
 	}
 
-	profile := viper.GetString(configRoot + ".client-profile")
-	module.saramaConfig = helpers.GetSaramaConfigFromClientProfile(profile)
+	module.clientProfile = config.GetString(configRoot + ".client-profile")
+	module.saramaConfig = helpers.GetSaramaConfigFromClientProfileIn(config, module.clientProfile)
+	helpers.LogSaramaConfig(module.Log, module.clientProfile, module.saramaConfig)
 
-	module.servers = viper.GetStringSlice(configRoot + ".servers")
+	module.servers = config.GetStringSlice(configRoot + ".servers")
 	if len(module.servers) == 0 {
 		panic("No Kafka brokers specified for consumer " + module.name)
 	} else if !helpers.ValidateHostList(module.servers) {
@@ -110,37 +358,63 @@ func (module *KafkaClient) Configure(name, configRoot string) {
 	}
 
 	// Set defaults for configs if needed, and get them
-	viper.SetDefault(configRoot+".offsets-topic", "__consumer_offsets")
-	module.offsetsTopic = viper.GetString(configRoot + ".offsets-topic")
-	module.startLatest = viper.GetBool(configRoot + ".start-latest")
-	module.backfillEarliest = module.startLatest && viper.GetBool(configRoot+".backfill-earliest")
+	config.SetDefault(configRoot+".offsets-topic", "__consumer_offsets")
+	module.offsetsTopic = config.GetString(configRoot + ".offsets-topic")
+	if module.offsetsTopic == "" {
+		panic("Consumer '" + name + "' has an empty offsets-topic")
+	}
+	module.startLatest = config.GetBool(configRoot + ".start-latest")
+	module.backfillEarliest = module.startLatest && config.GetBool(configRoot+".backfill-earliest")
+	module.startLookback = config.GetInt64(configRoot + ".start-lookback")
+	if module.startLookback < 0 {
+		panic("Consumer '" + name + "' has a negative start-lookback")
+	}
+	if module.startLookback > 0 && module.startLatest {
+		panic("Consumer '" + name + "' cannot have both start-latest and start-lookback set")
+	}
+	module.resumeFromStorage = config.GetBool(configRoot + ".resume-from-storage")
+	if module.resumeFromStorage && (module.startLatest || module.startLookback > 0) {
+		panic("Consumer '" + name + "' cannot have resume-from-storage set with start-latest or start-lookback")
+	}
+
+	// Both fetch the committed offsets of every topic for the groups that are not seen, which needs OffsetFetch v2
+	if (module.startLookback > 0 || module.resumeFromStorage) && !module.saramaConfig.Version.IsAtLeast(sarama.V0_10_2_0) {
+		panic("Consumer '" + name + "' cannot have start-lookback or resume-from-storage set, as they need at least kafka v0.10.2.0 to fetch the offsets of a group")
+	}
+	module.reconcileStartup = config.GetBool(configRoot + ".reconcile-startup")
+	if module.reconcileStartup && module.startLatest {
+		panic("Consumer '" + name + "' cannot have reconcile-startup set with start-latest")
+	}
+	config.SetDefault(configRoot+".reconcile-timeout", 600)
+	module.reconcileTimeout = config.GetInt(configRoot + ".reconcile-timeout")
+	if module.reconcileTimeout <= 0 {
+		panic("Consumer '" + name + "' must have a positive reconcile-timeout")
+	}
 	module.reportedConsumerGroup = "burrow-" + module.name
+	config.SetDefault(configRoot+".health-threshold", 600)
+	module.healthThreshold = config.GetInt(configRoot + ".health-threshold")
+	if module.healthThreshold < 0 {
+		panic("Consumer '" + name + "' must not have a negative health-threshold")
+	}
 
 	// Check for disallowed config values
-	if viper.IsSet(configRoot+".group-whitelist") || viper.IsSet(configRoot+".group-blacklist") {
+	if config.IsSet(configRoot+".group-whitelist") || config.IsSet(configRoot+".group-blacklist") {
 		module.Log.Panic("Please change configurations to allowlist and denylist")
 		panic("Please change configurations to allowlist and denylist")
 	}
 
-	allowlist := viper.GetString(configRoot + ".group-allowlist")
-	if allowlist != "" {
-		re, err := regexp.Compile(allowlist)
-		if err != nil {
-			module.Log.Panic("Failed to compile group allowlist")
-			panic(err)
-		}
-		module.groupAllowlist = re
+	module.configRoot = configRoot
+	if err := module.ReloadFilters(config); err != nil {
+		module.Log.Panic(err.Error())
+		panic(err)
 	}
+}
 
-	denylist := viper.GetString(configRoot + ".group-denylist")
-	if denylist != "" {
-		re, err := regexp.Compile(denylist)
-		if err != nil {
-			module.Log.Panic("Failed to compile group denylist")
-			panic(err)
-		}
-		module.groupDenylist = re
-	}
+// CheckConnection connects to the Kafka cluster with the configured servers and client profile, fetches the metadata
+// for the cluster, and makes sure that the offsets topic exists, then disconnects again. Any error is returned to the
+// caller.
+func (module *KafkaClient) CheckConnection() error {
+	return helpers.CheckKafkaConnection(module.Log, module.clientProfile, module.servers, module.saramaConfig, module.offsetsTopic)
 }
 
 // Start connects to the Kafka cluster using the Shopify/sarama client. Any error connecting to the cluster is returned
@@ -149,20 +423,29 @@ func (module *KafkaClient) Start() error {
 	module.Log.Info("starting")
 
 	// Connect Kafka client
+	helpers.DetectKafkaVersion(module.Log, module.clientProfile, module.servers, module.saramaConfig)
 	client, err := sarama.NewClient(module.servers, module.saramaConfig)
 	if err != nil {
 		module.Log.Error("failed to start client", zap.Error(err))
 		return err
 	}
+	httpserver.RegisterSaramaMetrics(module.cluster, "consumer."+module.name, module.saramaConfig.MetricRegistry)
 
 	// Start the consumers
 	err = module.startKafkaConsumer(&helpers.BurrowSaramaClient{Client: client})
 	if err != nil {
 		module.Log.Error("failed to start consumer", zap.Error(err))
+		httpserver.UnregisterSaramaMetrics(module.cluster, "consumer."+module.name)
 		client.Close()
 		return err
 	}
+	httpserver.RegisterOffsetsTopicLag(module.cluster, "consumer."+module.name, module.offsetsLag.lag)
 
+	// The threshold starts now, as there may be no messages to read until a group commits
+	if module.healthThreshold != 0 {
+		module.heartbeat.Beat()
+		module.App.Health.Register("consumer."+module.name, module.heartbeat.Check(time.Duration(module.healthThreshold)*time.Second))
+	}
 	return nil
 }
 
@@ -170,8 +453,11 @@ func (module *KafkaClient) Start() error {
 func (module *KafkaClient) Stop() error {
 	module.Log.Info("stopping")
 
+	module.App.Health.Unregister("consumer." + module.name)
 	close(module.quitChannel)
 	module.running.Wait()
+	httpserver.UnregisterOffsetsTopicLag(module.cluster, "consumer."+module.name)
+	httpserver.UnregisterSaramaMetrics(module.cluster, "consumer."+module.name)
 
 	return nil
 }
@@ -245,6 +531,7 @@ func (module *KafkaClient) partitionConsumer(consumer sarama.PartitionConsumer,
 			if msg == nil {
 				continue
 			}
+			module.heartbeat.Beat()
 			if module.reportedConsumerGroup != "" {
 				burrowOffset := &protocol.StorageRequest{
 					RequestType: protocol.StorageSetConsumerOffset,
@@ -260,6 +547,15 @@ func (module *KafkaClient) partitionConsumer(consumer sarama.PartitionConsumer,
 			}
 
 			module.processConsumerOffsetsMessage(msg)
+			if module.lookback != nil {
+				module.lookback.markOffset(msg.Partition, msg.Offset)
+			}
+			if module.reconcile != nil {
+				module.reconcile.markOffset(msg.Partition, msg.Offset)
+			}
+			if stopAtOffset == nil && module.offsetsLag != nil {
+				module.offsetsLag.markOffset(msg.Partition, msg.Offset)
+			}
 
 			if stopAtOffset != nil && msg.Offset >= stopAtOffset.Value {
 				module.Log.Debug("backfill consumer reached target offset, terminating",
@@ -309,13 +605,29 @@ func (module *KafkaClient) startKafkaConsumer(client helpers.SaramaClient) error
 		startFrom = sarama.OffsetNewest
 	}
 
+	var startOffsets map[int32]int64
+	if module.startLookback > 0 {
+		startOffsets = module.getLookbackOffsets(client, partitions)
+	} else if module.resumeFromStorage {
+		startOffsets = module.getStoredOffsets(client, partitions)
+	}
+	if module.reconcileStartup {
+		module.reconcile = newReconcileState(module.getReconcileTargets(client, partitions, startOffsets))
+	}
+
+	module.offsetsLag = newOffsetsLagState()
+
 	// Start consumers for each partition with fan in
 	module.Log.Info("starting consumers",
 		zap.String("topic", module.offsetsTopic),
 		zap.Int("count", len(partitions)),
 	)
 	for _, partition := range partitions {
-		pconsumer, err := consumer.ConsumePartition(module.offsetsTopic, partition, startFrom)
+		partitionStart := startFrom
+		if offset, ok := startOffsets[partition]; ok {
+			partitionStart = offset
+		}
+		pconsumer, err := consumer.ConsumePartition(module.offsetsTopic, partition, partitionStart)
 		if err != nil {
 			module.Log.Error("failed to consume partition",
 				zap.String("topic", module.offsetsTopic),
@@ -324,9 +636,18 @@ func (module *KafkaClient) startKafkaConsumer(client helpers.SaramaClient) error
 			)
 			return err
 		}
+		module.offsetsLag.addPartition(partition, pconsumer, partitionStart)
 		module.running.Add(1)
 		go module.partitionConsumer(pconsumer, nil)
 	}
+	if module.lookback != nil {
+		module.running.Add(1)
+		go module.fetchUnseenGroups(client)
+	}
+	if module.reconcile != nil {
+		module.running.Add(1)
+		go module.releaseReconciledOffsets()
+	}
 
 	if module.backfillEarliest {
 		module.Log.Debug("backfilling consumer offsets")
@@ -362,6 +683,286 @@ func (module *KafkaClient) startKafkaConsumer(client helpers.SaramaClient) error
 	return nil
 }
 
+// getReconcileTargets returns the offset that each partition of the offsets topic must be consumed up to before the
+// offsets that are held at startup are sent to storage, which is the newest offset in the partition. Partitions that
+// have nothing to consume from where they start, or for which the offsets cannot be fetched, are left out.
+func (module *KafkaClient) getReconcileTargets(client helpers.SaramaClient, partitions []int32, startOffsets map[int32]int64) map[int32]int64 {
+	targets := make(map[int32]int64)
+	for _, partition := range partitions {
+		newestOffset, err := client.GetOffset(module.offsetsTopic, partition, sarama.OffsetNewest)
+		if err != nil {
+			module.Log.Warn("failed to get newest offset, not reconciling partition",
+				zap.String("topic", module.offsetsTopic),
+				zap.Int32("partition", partition),
+				zap.String("error", err.Error()),
+			)
+			continue
+		}
+		startOffset, ok := startOffsets[partition]
+		if !ok {
+			startOffset, err = client.GetOffset(module.offsetsTopic, partition, sarama.OffsetOldest)
+			if err != nil {
+				module.Log.Warn("failed to get oldest offset, not reconciling partition",
+					zap.String("topic", module.offsetsTopic),
+					zap.Int32("partition", partition),
+					zap.String("error", err.Error()),
+				)
+				continue
+			}
+		}
+
+		// A start offset of OffsetNewest means that nothing was written within the lookback
+		if (startOffset != sarama.OffsetNewest) && (startOffset < newestOffset) {
+			targets[partition] = newestOffset - 1
+		}
+	}
+	module.Log.Info("reconciling offsets at startup", zap.Int("partitions", len(targets)))
+	return targets
+}
+
+// releaseReconciledOffsets waits for the offsets topic consumers to reach the offsets that were the newest at startup,
+// or for the reconcile timeout, and then sends the consumer offsets that were held back to storage.
+func (module *KafkaClient) releaseReconciledOffsets() {
+	defer module.running.Done()
+
+	timeout := time.NewTimer(time.Duration(module.reconcileTimeout) * time.Second)
+	defer timeout.Stop()
+	select {
+	case <-module.reconcile.done:
+	case <-timeout.C:
+		module.Log.Warn("timed out reconciling offsets at startup, releasing the held offsets")
+	case <-module.quitChannel:
+		return
+	}
+
+	released := module.reconcile.release()
+	module.Log.Info("releasing reconciled offsets", zap.Int("count", len(released)))
+	for _, request := range released {
+		helpers.TimeoutSendStorageRequest(module.App.StorageChannel, request, 1)
+	}
+}
+
+// getLookbackOffsets returns the offset to start consuming each partition of the offsets topic from, which is the first
+// offset written within start-lookback seconds. It also sets up the lookback state with the newest offset of each
+// partition, so that the unseen groups can be fetched once they have been reached. Partitions for which the offsets
+// cannot be fetched are left out, and are consumed from the earliest offset.
+func (module *KafkaClient) getLookbackOffsets(client helpers.SaramaClient, partitions []int32) map[int32]int64 {
+	startTime := time.Now().Unix()*1000 - module.startLookback*1000
+	startOffsets := make(map[int32]int64)
+	targets := make(map[int32]int64)
+	for _, partition := range partitions {
+		newestOffset, err := client.GetOffset(module.offsetsTopic, partition, sarama.OffsetNewest)
+		if err != nil {
+			module.Log.Warn("failed to get newest offset, consuming from the earliest offset",
+				zap.String("topic", module.offsetsTopic),
+				zap.Int32("partition", partition),
+				zap.String("error", err.Error()),
+			)
+			continue
+		}
+		offset, err := client.GetOffset(module.offsetsTopic, partition, startTime)
+		if err != nil {
+			module.Log.Warn("failed to get lookback offset, consuming from the earliest offset",
+				zap.String("topic", module.offsetsTopic),
+				zap.Int32("partition", partition),
+				zap.String("error", err.Error()),
+			)
+			continue
+		}
+
+		// If nothing was written since the start time, the broker returns -1, which is OffsetNewest
+		startOffsets[partition] = offset
+		if offset != sarama.OffsetNewest && offset < newestOffset {
+			// GetOffset returns the next (not yet published) offset, and we wait for the latest published offset
+			targets[partition] = newestOffset - 1
+		}
+	}
+
+	module.Log.Info("starting from lookback",
+		zap.String("topic", module.offsetsTopic),
+		zap.Int64("lookback", module.startLookback),
+		zap.Int("partitions", len(startOffsets)),
+	)
+	module.lookback = newLookbackState(startTime, targets)
+	return startOffsets
+}
+
+// getStoredOffsets returns the offset to start consuming each partition of the offsets topic from, which is the next
+// offset after the last one that was stored for the reported consumer group. Partitions with no stored offset, or with
+// one that is no longer in the topic, are left out, and are consumed from the earliest offset. If any offset was
+// stored, the lookback state is set up with the newest offset of each partition, and with the groups that the storage
+// module already has marked as seen, so that only the groups it is missing are fetched once the consumer catches up.
+func (module *KafkaClient) getStoredOffsets(client helpers.SaramaClient, partitions []int32) map[int32]int64 {
+	stored, storedTime := module.fetchStoredPosition()
+	if len(stored) == 0 {
+		module.Log.Info("no stored position, consuming from the earliest offset", zap.String("topic", module.offsetsTopic))
+		return nil
+	}
+
+	startOffsets := make(map[int32]int64)
+	targets := make(map[int32]int64)
+	for _, partition := range partitions {
+		oldestOffset, err := client.GetOffset(module.offsetsTopic, partition, sarama.OffsetOldest)
+		if err != nil {
+			module.Log.Warn("failed to get oldest offset, consuming from the earliest offset",
+				zap.String("topic", module.offsetsTopic),
+				zap.Int32("partition", partition),
+				zap.String("error", err.Error()),
+			)
+			continue
+		}
+		newestOffset, err := client.GetOffset(module.offsetsTopic, partition, sarama.OffsetNewest)
+		if err != nil {
+			module.Log.Warn("failed to get newest offset, consuming from the earliest offset",
+				zap.String("topic", module.offsetsTopic),
+				zap.Int32("partition", partition),
+				zap.String("error", err.Error()),
+			)
+			continue
+		}
+
+		startOffset := oldestOffset
+		if offset, ok := stored[partition]; ok {
+			if (offset >= oldestOffset) && (offset <= newestOffset) {
+				startOffset = offset
+				startOffsets[partition] = offset
+			} else {
+				module.Log.Warn("stored position is out of range, consuming from the earliest offset",
+					zap.String("topic", module.offsetsTopic),
+					zap.Int32("partition", partition),
+					zap.Int64("offset", offset),
+				)
+			}
+		}
+		if startOffset < newestOffset {
+			// GetOffset returns the next (not yet published) offset, and we wait for the latest published offset
+			targets[partition] = newestOffset - 1
+		}
+	}
+
+	module.Log.Info("resuming from stored position",
+		zap.String("topic", module.offsetsTopic),
+		zap.Int("partitions", len(startOffsets)),
+	)
+	module.lookback = newLookbackState(storedTime, targets)
+	for _, group := range module.fetchStoredGroups() {
+		module.lookback.markSeen(group)
+	}
+	return startOffsets
+}
+
+// fetchStoredPosition returns the next offset to read for each partition of the offsets topic, from the offsets that
+// were stored for the reported consumer group, as well as the oldest timestamp of them. Groups that have not committed
+// since then are fetched with that timestamp.
+func (module *KafkaClient) fetchStoredPosition() (map[int32]int64, int64) {
+	request := &protocol.StorageRequest{
+		RequestType: protocol.StorageFetchConsumer,
+		Cluster:     module.cluster,
+		Group:       module.reportedConsumerGroup,
+		Reply:       make(chan interface{}),
+	}
+	stored := make(map[int32]int64)
+	storedTime := int64(0)
+	if !helpers.TimeoutSendStorageRequest(module.App.StorageChannel, request, 1) {
+		module.Log.Warn("timed out fetching stored position")
+		return stored, storedTime
+	}
+	response, ok := <-request.Reply
+	if !ok {
+		return stored, storedTime
+	}
+	for partition, consumerPartition := range response.(protocol.ConsumerTopics)[module.offsetsTopic] {
+		var lastOffset *protocol.ConsumerOffset
+		for _, offset := range consumerPartition.Offsets {
+			if offset != nil {
+				lastOffset = offset
+			}
+		}
+		if lastOffset == nil {
+			continue
+		}
+		stored[int32(partition)] = lastOffset.Offset
+		if (storedTime == 0) || (lastOffset.Timestamp < storedTime) {
+			storedTime = lastOffset.Timestamp
+		}
+	}
+	return stored, storedTime
+}
+
+// fetchStoredGroups returns the consumer groups that the storage module has for the cluster.
+func (module *KafkaClient) fetchStoredGroups() []string {
+	request := &protocol.StorageRequest{
+		RequestType: protocol.StorageFetchConsumers,
+		Cluster:     module.cluster,
+		Reply:       make(chan interface{}),
+	}
+	if !helpers.TimeoutSendStorageRequest(module.App.StorageChannel, request, 1) {
+		module.Log.Warn("timed out fetching stored groups")
+		return nil
+	}
+	response, ok := <-request.Reply
+	if !ok {
+		return nil
+	}
+	return response.([]string)
+}
+
+// fetchUnseenGroups waits for the offsets topic consumers to reach the offsets that were the newest at startup, and
+// then fetches the committed offsets for every group that did not commit within the lookback. The offsets are stored
+// with a timestamp of the lookback start time, as they were committed before it, and are ordered before any offset
+// that is read from the offsets topic.
+func (module *KafkaClient) fetchUnseenGroups(client helpers.SaramaClient) {
+	defer module.running.Done()
+
+	select {
+	case <-module.lookback.done:
+	case <-module.quitChannel:
+		return
+	}
+
+	groups, err := client.ListConsumerGroups()
+	if err != nil {
+		// Fetch what we can for the groups that were returned
+		module.Log.Warn("failed to list all consumer groups for lookback", zap.Error(err))
+	}
+	unseen := module.lookback.unseenGroups(groups)
+	module.Log.Info("fetching offsets for groups not seen in lookback", zap.Int("count", len(unseen)))
+
+	for _, group := range unseen {
+		if group == module.reportedConsumerGroup || !module.acceptConsumerGroup(group) {
+			continue
+		}
+
+		offsets, err := client.FetchConsumerOffsets(group, nil)
+		if err != nil {
+			module.Log.Warn("failed to fetch offsets for group", zap.String("group", group), zap.Error(err))
+		}
+		for topic, partitions := range offsets {
+			if !module.acceptTopic(topic) {
+				continue
+			}
+			for partition, offset := range partitions {
+				helpers.TimeoutSendStorageRequest(module.App.StorageChannel, &protocol.StorageRequest{
+					RequestType: protocol.StorageSetConsumerOffset,
+					Cluster:     module.cluster,
+					Topic:       topic,
+					Partition:   partition,
+					Group:       group,
+					Timestamp:   module.lookback.startTime,
+					Offset:      offset,
+					Order:       -1,
+				}, 1)
+			}
+		}
+
+		select {
+		case <-module.quitChannel:
+			return
+		default:
+		}
+	}
+}
+
 func (module *KafkaClient) processConsumerOffsetsMessage(msg *sarama.ConsumerMessage) {
 	logger := module.Log.With(
 		zap.String("offset_topic", msg.Topic),
@@ -392,6 +993,28 @@ func (module *KafkaClient) processConsumerOffsetsMessage(msg *sarama.ConsumerMes
 	}
 }
 
+// readCompactString reads a string that is encoded with its length plus one as an unsigned varint, as in the flexible
+// versions of the Kafka schemas. A null string is returned as an empty string.
+func readCompactString(buf *bytes.Buffer) (string, error) { // nolint:interfacer
+	strlen, err := binary.ReadUvarint(buf)
+	if err != nil {
+		return "", err
+	}
+	if strlen == 0 {
+		return "", nil
+	}
+	if strlen-1 > uint64(buf.Len()) {
+		return "", errors.New("string underflow")
+	}
+
+	strbytes := make([]byte, strlen-1)
+	n, err := buf.Read(strbytes)
+	if (err != nil) || (n != int(strlen-1)) {
+		return "", errors.New("string underflow")
+	}
+	return string(strbytes), nil
+}
+
 func readString(buf *bytes.Buffer) (string, error) { // nolint:interfacer
 	var strlen int16
 	err := binary.Read(buf, binary.BigEndian, &strlen)
@@ -410,7 +1033,40 @@ func readString(buf *bytes.Buffer) (string, error) { // nolint:interfacer
 	return string(strbytes), nil
 }
 
+// ReloadFilters compiles the group-allowlist, group-denylist, topic-filter, and topic-exclude configurations for the
+// consumer, and replaces the current filters with them. If any of them is invalid, an error is returned and the current
+// filters are kept. Offsets that were dropped by the previous filters are not fetched again.
+func (module *KafkaClient) ReloadFilters(config *viper.Viper) error {
+	groupAllowlist, err := helpers.CompileConfigRegexp(config, module.configRoot+".group-allowlist")
+	if err != nil {
+		return err
+	}
+	groupDenylist, err := helpers.CompileConfigRegexp(config, module.configRoot+".group-denylist")
+	if err != nil {
+		return err
+	}
+	topicFilter, err := helpers.CompileConfigRegexp(config, module.configRoot+".topic-filter")
+	if err != nil {
+		return err
+	}
+	topicExclude, err := helpers.CompileConfigRegexp(config, module.configRoot+".topic-exclude")
+	if err != nil {
+		return err
+	}
+
+	module.filterLock.Lock()
+	defer module.filterLock.Unlock()
+	module.groupAllowlist = groupAllowlist
+	module.groupDenylist = groupDenylist
+	module.topicFilter = topicFilter
+	module.topicExclude = topicExclude
+	return nil
+}
+
 func (module *KafkaClient) acceptConsumerGroup(group string) bool {
+	module.filterLock.RLock()
+	defer module.filterLock.RUnlock()
+
 	if (module.groupAllowlist != nil) && (!module.groupAllowlist.MatchString(group)) {
 		return false
 	}
@@ -420,6 +1076,19 @@ func (module *KafkaClient) acceptConsumerGroup(group string) bool {
 	return true
 }
 
+func (module *KafkaClient) acceptTopic(topic string) bool {
+	module.filterLock.RLock()
+	defer module.filterLock.RUnlock()
+
+	if (module.topicFilter != nil) && (!module.topicFilter.MatchString(topic)) {
+		return false
+	}
+	if (module.topicExclude != nil) && module.topicExclude.MatchString(topic) {
+		return false
+	}
+	return true
+}
+
 func (module *KafkaClient) decodeKeyAndOffset(offsetOrder int64, keyBuffer *bytes.Buffer, value []byte, logger *zap.Logger) {
 	// Version 0 and 1 keys are decoded the same way
 	offsetKey, errorAt := decodeOffsetKeyV0(keyBuffer)
@@ -445,6 +1114,13 @@ func (module *KafkaClient) decodeKeyAndOffset(offsetOrder int64, keyBuffer *byte
 		offsetLogger.Debug("dropped", zap.String("reason", "allowlist"))
 		return
 	}
+	if !module.acceptTopic(offsetKey.Topic) {
+		offsetLogger.Debug("dropped", zap.String("reason", "topic filter"))
+		return
+	}
+	if module.lookback != nil {
+		module.lookback.markSeen(offsetKey.Group)
+	}
 
 	if len(value) == 0 {
 		// Tombstone message - we don't handle them for now
@@ -462,11 +1138,15 @@ func (module *KafkaClient) decodeKeyAndOffset(offsetOrder int64, keyBuffer *byte
 		return
 	}
 
+	// Version 1 adds an expire timestamp after the commit timestamp, and version 2 removes it again, so they are decoded
+	// the same way as version 0. Version 4 is the flexible version of version 3.
 	switch valueVersion {
-	case 0, 1:
+	case 0, 1, 2:
 		module.decodeAndSendOffset(offsetOrder, offsetKey, valueBuffer, offsetLogger, decodeOffsetValueV0)
 	case 3:
 		module.decodeAndSendOffset(offsetOrder, offsetKey, valueBuffer, offsetLogger, decodeOffsetValueV3)
+	case 4:
+		module.decodeAndSendOffset(offsetOrder, offsetKey, valueBuffer, offsetLogger, decodeOffsetValueV4)
 	default:
 		offsetLogger.Warn("failed to decode",
 			zap.String("reason", "value version"),
@@ -500,6 +1180,9 @@ func (module *KafkaClient) decodeAndSendOffset(offsetOrder int64, offsetKey offs
 		zap.Int64("offset", offsetValue.Offset),
 		zap.Int64("timestamp", offsetValue.Timestamp),
 	)
+	if (module.reconcile != nil) && module.reconcile.hold(partitionOffset) {
+		return
+	}
 	helpers.TimeoutSendStorageRequest(module.App.StorageChannel, partitionOffset, 1)
 }
 
@@ -516,6 +1199,9 @@ func (module *KafkaClient) decodeGroupMetadata(keyBuffer *bytes.Buffer, value []
 	if len(value) == 0 {
 		// Tombstone message - group deleted
 		logger.Debug("removing consumer group due to tombstone")
+		if module.reconcile != nil {
+			module.reconcile.dropGroup(group)
+		}
 		deleteMessage := &protocol.StorageRequest{
 			RequestType: protocol.StorageSetDeleteGroup,
 			Cluster:     module.cluster,
@@ -592,6 +1278,20 @@ func (module *KafkaClient) decodeAndSendGroupMetadata(valueVersion int16, group
 		return
 	}
 
+	// Every rebalance moves the group to a new generation. Older metadata versions do not carry the time of the
+	// state change, so the time the record is processed is used instead
+	generationTimestamp := metadataHeader.CurrentStateTimestamp
+	if generationTimestamp <= 0 {
+		generationTimestamp = time.Now().Unix() * 1000
+	}
+	helpers.TimeoutSendStorageRequest(module.App.StorageChannel, &protocol.StorageRequest{
+		RequestType: protocol.StorageSetConsumerGeneration,
+		Cluster:     module.cluster,
+		Group:       group,
+		Generation:  metadataHeader.Generation,
+		Timestamp:   generationTimestamp,
+	}, 1)
+
 	// If memberCount is zero, clear all ownership
 	if memberCount == 0 {
 		metadataLogger.Debug("clear owners")
@@ -614,6 +1314,9 @@ func (module *KafkaClient) decodeAndSendGroupMetadata(valueVersion int16, group
 		}
 
 		for topic, partitions := range member.Assignment {
+			if !module.acceptTopic(topic) {
+				continue
+			}
 			for _, partition := range partitions {
 				helpers.TimeoutSendStorageRequest(module.App.StorageChannel, &protocol.StorageRequest{
 					RequestType: protocol.StorageSetConsumerOwner,
@@ -858,3 +1561,29 @@ func decodeOffsetValueV3(valueBuffer *bytes.Buffer) (offsetValue, string) {
 	}
 	return offsetValue, ""
 }
+
+// decodeOffsetValueV4 decodes the flexible version of the version 3 value. The tagged fields that follow the commit
+// timestamp are not needed, so they are not read.
+func decodeOffsetValueV4(valueBuffer *bytes.Buffer) (offsetValue, string) {
+	var err error
+	offsetValue := offsetValue{}
+
+	err = binary.Read(valueBuffer, binary.BigEndian, &offsetValue.Offset)
+	if err != nil {
+		return offsetValue, "offset"
+	}
+	var leaderEpoch int32
+	err = binary.Read(valueBuffer, binary.BigEndian, &leaderEpoch)
+	if err != nil {
+		return offsetValue, "leaderEpoch"
+	}
+	_, err = readCompactString(valueBuffer)
+	if err != nil {
+		return offsetValue, "metadata"
+	}
+	err = binary.Read(valueBuffer, binary.BigEndian, &offsetValue.Timestamp)
+	if err != nil {
+		return offsetValue, "timestamp"
+	}
+	return offsetValue, ""
+}
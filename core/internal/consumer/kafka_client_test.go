@@ -18,6 +18,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 
 	"github.com/IBM/sarama"
 	"github.com/spf13/viper"
@@ -69,6 +70,38 @@ func TestKafkaClient_Configure(t *testing.T) {
 	module.Configure("test", "consumer.test")
 	assert.NotNil(t, module.saramaConfig, "Expected saramaConfig to be populated")
 	assert.Equal(t, "__consumer_offsets", module.offsetsTopic, "Default OffsetTopic value of __consumer_offsets did not get set")
+	assert.Equal(t, 600, module.healthThreshold, "Default HealthThreshold value of 600 did not get set")
+}
+
+func TestKafkaClient_Configure_OffsetsTopic(t *testing.T) {
+	module := fixtureModule()
+	viper.Set("consumer.test.offsets-topic", "mm2.__consumer_offsets")
+	module.Configure("test", "consumer.test")
+	assert.Equal(t, "mm2.__consumer_offsets", module.offsetsTopic, "Expected the configured offsets topic to be used")
+
+	module = fixtureModule()
+	viper.Set("consumer.test.offsets-topic", "")
+	assert.Panics(t, func() { module.Configure("test", "consumer.test") }, "The code did not panic")
+}
+
+func TestKafkaClient_configureFrom(t *testing.T) {
+	module := fixtureModule()
+	config := viper.New()
+	config.Set("client-profile.east.client-id", "eastid")
+	config.Set("cluster.east.class-name", "kafka")
+	config.Set("consumer.east.class-name", "kafka")
+	config.Set("consumer.east.servers", []string{"broker2.example.com:1234"})
+	config.Set("consumer.east.cluster", "east")
+	config.Set("consumer.east.client-profile", "east")
+	config.Set("consumer.east.group-allowlist", "^east-")
+	module.configureFrom(config, "east", "consumer.east")
+
+	assert.Equal(t, []string{"broker2.example.com:1234"}, module.servers, "Expected the servers to be read from the configuration")
+	assert.Equal(t, "eastid", module.saramaConfig.ClientID, "Expected the client-profile to be read from the configuration")
+	assert.True(t, module.acceptConsumerGroup("east-group"), "Expected the group-allowlist to be read from the configuration")
+	assert.False(t, module.acceptConsumerGroup("west-group"), "Expected the group-allowlist to be read from the configuration")
+	assert.Equal(t, 600, config.GetInt("consumer.east.health-threshold"), "Expected the defaults to be set on the configuration")
+	assert.False(t, viper.IsSet("consumer.east.health-threshold"), "Expected the global configuration to be unchanged")
 }
 
 func TestKafkaClient_Configure_BadCluster(t *testing.T) {
@@ -217,6 +250,301 @@ func TestKafkaClient_startKafkaConsumerWithBackfill(t *testing.T) {
 	client.AssertExpectations(t)
 }
 
+func TestKafkaClient_Configure_BadStartLookback(t *testing.T) {
+	module := fixtureModule()
+	viper.Set("consumer.test-backfill.start-lookback", 3600)
+	assert.Panics(t, func() { module.Configure("test", "consumer.test-backfill") }, "The code did not panic")
+}
+
+func TestKafkaClient_startKafkaConsumerWithLookback(t *testing.T) {
+	module := fixtureModule()
+	viper.Set("consumer.test.start-lookback", 3600)
+	module.Configure("test", "consumer.test")
+
+	// Channels for testing
+	messageChan := make(chan *sarama.ConsumerMessage)
+	errorChan := make(chan *sarama.ConsumerError)
+
+	// Don't assert expectations on this - the way it goes down, they're called but don't show up
+	mockPartitionConsumer := &helpers.MockSaramaPartitionConsumer{}
+	mockPartitionConsumer.On("AsyncClose").Return()
+	mockPartitionConsumer.On("Messages").Return(func() <-chan *sarama.ConsumerMessage { return messageChan }())
+	mockPartitionConsumer.On("Errors").Return(func() <-chan *sarama.ConsumerError { return errorChan }())
+
+	// Partition 0 starts from the lookback, and partition 1 has nothing in the lookback so starts from the newest
+	consumer := &helpers.MockSaramaConsumer{}
+	consumer.On("ConsumePartition", "__consumer_offsets", int32(0), int64(400)).Return(mockPartitionConsumer, nil)
+	consumer.On("ConsumePartition", "__consumer_offsets", int32(1), sarama.OffsetNewest).Return(mockPartitionConsumer, nil)
+
+	client := &helpers.MockSaramaClient{}
+	client.On("NewConsumerFromClient").Return(consumer, nil)
+	client.On("Partitions", "__consumer_offsets").Return([]int32{0, 1}, nil)
+	client.On("GetOffset", "__consumer_offsets", int32(0), sarama.OffsetNewest).Return(int64(456), nil)
+	client.On("GetOffset", "__consumer_offsets", int32(1), sarama.OffsetNewest).Return(int64(789), nil)
+	client.On("GetOffset", "__consumer_offsets", int32(0), mock.AnythingOfType("int64")).Return(int64(400), nil)
+	client.On("GetOffset", "__consumer_offsets", int32(1), mock.AnythingOfType("int64")).Return(sarama.OffsetNewest, nil)
+
+	err := module.startKafkaConsumer(client)
+	assert.Nil(t, err, "Expected startKafkaConsumer to return no error")
+	if assert.NotNil(t, module.lookback, "Expected lookback state to be set up") {
+		assert.Equal(t, map[int32]int64{0: 455}, module.lookback.targets, "Expected to wait for the newest offset of partition 0 only")
+	}
+
+	close(module.quitChannel)
+	module.running.Wait()
+
+	consumer.AssertExpectations(t)
+	client.AssertExpectations(t)
+}
+
+func TestKafkaClient_Configure_BadResumeFromStorage(t *testing.T) {
+	module := fixtureModule()
+	viper.Set("consumer.test-backfill.resume-from-storage", true)
+	assert.Panics(t, func() { module.Configure("test", "consumer.test-backfill") }, "The code did not panic")
+}
+
+func TestKafkaClient_Configure_FetchOffsetsVersion(t *testing.T) {
+	for _, setting := range []string{"start-lookback", "resume-from-storage"} {
+		module := fixtureModule()
+		viper.Set("client-profile.old.kafka-version", "0.10.1.0")
+		viper.Set("consumer.test.client-profile", "old")
+		if setting == "start-lookback" {
+			viper.Set("consumer.test.start-lookback", 3600)
+		} else {
+			viper.Set("consumer.test.resume-from-storage", true)
+		}
+		assert.Panicsf(t, func() { module.Configure("test", "consumer.test") }, "Expected %v to need kafka 0.10.2.0", setting)
+	}
+}
+
+func TestKafkaClient_startKafkaConsumerWithResume(t *testing.T) {
+	module := fixtureModule()
+	viper.Set("consumer.test.resume-from-storage", true)
+	module.Configure("test", "consumer.test")
+
+	// Answer the requests for the stored position and the stored groups
+	go func() {
+		request := <-module.App.StorageChannel
+		assert.Equalf(t, protocol.StorageFetchConsumer, request.RequestType, "Expected request sent with type StorageFetchConsumer, not %v", request.RequestType)
+		assert.Equalf(t, "burrow-test", request.Group, "Expected request sent with Group burrow-test, not %v", request.Group)
+		request.Reply <- protocol.ConsumerTopics{
+			"__consumer_offsets": {
+				{Offsets: []*protocol.ConsumerOffset{{Offset: 410, Timestamp: 2000}, {Offset: 420, Timestamp: 3000}}},
+				{Offsets: []*protocol.ConsumerOffset{{Offset: 50, Timestamp: 1000}}},
+			},
+		}
+		close(request.Reply)
+
+		request = <-module.App.StorageChannel
+		assert.Equalf(t, protocol.StorageFetchConsumers, request.RequestType, "Expected request sent with type StorageFetchConsumers, not %v", request.RequestType)
+		request.Reply <- []string{"knowngroup"}
+		close(request.Reply)
+	}()
+
+	messageChan := make(chan *sarama.ConsumerMessage)
+	errorChan := make(chan *sarama.ConsumerError)
+
+	mockPartitionConsumer := &helpers.MockSaramaPartitionConsumer{}
+	mockPartitionConsumer.On("AsyncClose").Return()
+	mockPartitionConsumer.On("Messages").Return(func() <-chan *sarama.ConsumerMessage { return messageChan }())
+	mockPartitionConsumer.On("Errors").Return(func() <-chan *sarama.ConsumerError { return errorChan }())
+
+	// Partition 0 resumes from the stored position, and the stored position of partition 1 is no longer in the topic
+	consumer := &helpers.MockSaramaConsumer{}
+	consumer.On("ConsumePartition", "__consumer_offsets", int32(0), int64(420)).Return(mockPartitionConsumer, nil)
+	consumer.On("ConsumePartition", "__consumer_offsets", int32(1), sarama.OffsetOldest).Return(mockPartitionConsumer, nil)
+
+	client := &helpers.MockSaramaClient{}
+	client.On("NewConsumerFromClient").Return(consumer, nil)
+	client.On("Partitions", "__consumer_offsets").Return([]int32{0, 1}, nil)
+	client.On("GetOffset", "__consumer_offsets", int32(0), sarama.OffsetOldest).Return(int64(100), nil)
+	client.On("GetOffset", "__consumer_offsets", int32(0), sarama.OffsetNewest).Return(int64(456), nil)
+	client.On("GetOffset", "__consumer_offsets", int32(1), sarama.OffsetOldest).Return(int64(100), nil)
+	client.On("GetOffset", "__consumer_offsets", int32(1), sarama.OffsetNewest).Return(int64(789), nil)
+
+	err := module.startKafkaConsumer(client)
+	assert.Nil(t, err, "Expected startKafkaConsumer to return no error")
+	if assert.NotNil(t, module.lookback, "Expected lookback state to be set up") {
+		assert.Equal(t, map[int32]int64{0: 455, 1: 788}, module.lookback.targets, "Expected to wait for the newest offset of both partitions")
+		assert.Equalf(t, int64(1000), module.lookback.startTime, "Expected start time to be the oldest stored timestamp, not %v", module.lookback.startTime)
+		assert.True(t, module.lookback.seen["knowngroup"], "Expected the stored group to be marked as seen")
+	}
+
+	close(module.quitChannel)
+	module.running.Wait()
+
+	consumer.AssertExpectations(t)
+	client.AssertExpectations(t)
+}
+
+func TestKafkaClient_getStoredOffsets_NoPosition(t *testing.T) {
+	module := fixtureModule()
+	viper.Set("consumer.test.resume-from-storage", true)
+	module.Configure("test", "consumer.test")
+
+	// The storage module does not know the group
+	go func() {
+		request := <-module.App.StorageChannel
+		close(request.Reply)
+	}()
+
+	client := &helpers.MockSaramaClient{}
+	startOffsets := module.getStoredOffsets(client, []int32{0})
+	assert.Nil(t, startOffsets, "Expected no start offsets")
+	assert.Nil(t, module.lookback, "Expected no lookback state")
+	client.AssertExpectations(t)
+}
+
+func TestKafkaClient_lookbackState(t *testing.T) {
+	state := newLookbackState(1000, map[int32]int64{0: 10, 1: 20})
+	state.markOffset(0, 10)
+	state.markOffset(1, 19)
+	select {
+	case <-state.done:
+		t.Fatal("Expected lookback to not be done before all partitions reached their target")
+	default:
+	}
+	state.markOffset(1, 20)
+	<-state.done
+
+	state.markSeen("seengroup")
+	unseen := state.unseenGroups(map[string]string{"seengroup": "consumer", "unseengroup": "consumer"})
+	assert.Equal(t, []string{"unseengroup"}, unseen)
+
+	// Tracking stops after the unseen groups are returned
+	assert.NotPanics(t, func() { state.markSeen("othergroup") })
+}
+
+func TestKafkaClient_offsetsLagState(t *testing.T) {
+	started := &helpers.MockSaramaPartitionConsumer{}
+	started.On("HighWaterMarkOffset").Return(int64(500))
+	oldest := &helpers.MockSaramaPartitionConsumer{}
+	oldest.On("HighWaterMarkOffset").Return(int64(300))
+
+	state := newOffsetsLagState()
+	state.addPartition(0, started, 400)
+	state.addPartition(2, oldest, sarama.OffsetOldest)
+	assert.Equal(t, []int64{100, 0, 0}, state.lag(), "Expected no lag for a partition without a known position")
+
+	state.markOffset(0, 449)
+	state.markOffset(2, 99)
+	assert.Equal(t, []int64{50, 0, 200}, state.lag())
+
+	// The position does not move backwards, and is never past the high-water mark
+	state.markOffset(0, 10)
+	state.markOffset(2, 349)
+	assert.Equal(t, []int64{50, 0, 0}, state.lag())
+}
+
+func TestKafkaClient_Configure_BadReconcileStartup(t *testing.T) {
+	module := fixtureModule()
+	viper.Set("consumer.test-backfill.reconcile-startup", true)
+	assert.Panics(t, func() { module.Configure("test", "consumer.test-backfill") }, "The code did not panic")
+
+	module = fixtureModule()
+	viper.Set("consumer.test.reconcile-startup", true)
+	viper.Set("consumer.test.reconcile-timeout", 0)
+	assert.Panics(t, func() { module.Configure("test", "consumer.test") }, "The code did not panic")
+}
+
+func TestKafkaClient_reconcileState(t *testing.T) {
+	state := newReconcileState(map[int32]int64{0: 10, 1: 20})
+	assert.True(t, state.hold(&protocol.StorageRequest{Group: "testgroup", Topic: "testtopic", Partition: 0, Offset: 100, Order: 5}))
+	assert.True(t, state.hold(&protocol.StorageRequest{Group: "testgroup", Topic: "testtopic", Partition: 0, Offset: 200, Order: 8}))
+	assert.True(t, state.hold(&protocol.StorageRequest{Group: "testgroup", Topic: "testtopic", Partition: 0, Offset: 150, Order: 7}))
+	assert.True(t, state.hold(&protocol.StorageRequest{Group: "deletedgroup", Topic: "testtopic", Partition: 0, Offset: 300, Order: 9}))
+	state.dropGroup("deletedgroup")
+
+	state.markOffset(0, 10)
+	state.markOffset(1, 19)
+	select {
+	case <-state.done:
+		t.Fatal("Expected reconcile to not be done before all partitions reached their target")
+	default:
+	}
+	state.markOffset(1, 20)
+	<-state.done
+
+	// Only the newest offset read for the group is released
+	released := state.release()
+	if assert.Lenf(t, released, 1, "Expected 1 released offset, not %v", len(released)) {
+		assert.Equalf(t, int64(200), released[0].Offset, "Expected the newest offset to be released, not %v", released[0].Offset)
+	}
+	assert.False(t, state.hold(&protocol.StorageRequest{Group: "testgroup", Topic: "testtopic", Offset: 250, Order: 10}),
+		"Expected offsets to not be held after the release")
+}
+
+func TestKafkaClient_getReconcileTargets(t *testing.T) {
+	module := fixtureModule()
+	module.Configure("test", "consumer.test")
+
+	// Partition 0 is consumed from the oldest offset, and partition 1 starts from its newest offset
+	client := &helpers.MockSaramaClient{}
+	client.On("GetOffset", "__consumer_offsets", int32(0), sarama.OffsetNewest).Return(int64(456), nil)
+	client.On("GetOffset", "__consumer_offsets", int32(0), sarama.OffsetOldest).Return(int64(100), nil)
+	client.On("GetOffset", "__consumer_offsets", int32(1), sarama.OffsetNewest).Return(int64(789), nil)
+
+	targets := module.getReconcileTargets(client, []int32{0, 1}, map[int32]int64{1: 789})
+	client.AssertExpectations(t)
+	assert.Equal(t, map[int32]int64{0: 455}, targets, "Expected to wait for the newest offset of partition 0 only")
+}
+
+func TestKafkaClient_releaseReconciledOffsets(t *testing.T) {
+	module := fixtureModule()
+	viper.Set("consumer.test.reconcile-startup", true)
+	module.Configure("test", "consumer.test")
+	module.reconcile = newReconcileState(map[int32]int64{0: 10})
+
+	// The offset is held until the partition has caught up
+	valueBuf := bytes.NewBuffer([]byte("\x00\x00\x00\x00\x00\x00\x20\xb4\x00\x08testdata\x00\x00\x00\x00\x00\x00\x06\x65"))
+	module.decodeAndSendOffset(5, offsetKey{Group: "testgroup", Topic: "testtopic", Partition: 11}, valueBuf, zap.NewNop(), decodeOffsetValueV0)
+	assert.Lenf(t, module.reconcile.held, 1, "Expected the offset to be held, not %v offsets", len(module.reconcile.held))
+
+	module.running.Add(1)
+	go module.releaseReconciledOffsets()
+	module.reconcile.markOffset(0, 10)
+	request := <-module.App.StorageChannel
+	module.running.Wait()
+
+	assert.Equalf(t, protocol.StorageSetConsumerOffset, request.RequestType, "Expected request sent with type StorageSetConsumerOffset, not %v", request.RequestType)
+	assert.Equalf(t, "testgroup", request.Group, "Expected request sent with Group testgroup, not %v", request.Group)
+	assert.Equalf(t, int64(8372), request.Offset, "Expected Offset to be 8372, not %v", request.Offset)
+	assert.Equalf(t, int64(5), request.Order, "Expected Order to be 5, not %v", request.Order)
+}
+
+func TestKafkaClient_fetchUnseenGroups(t *testing.T) {
+	module := fixtureModule()
+	viper.Set("consumer.test.group-denylist", "^dropped")
+	module.Configure("test", "consumer.test")
+	module.lookback = newLookbackState(1637000, map[int32]int64{})
+	module.lookback.markSeen("seengroup")
+
+	client := &helpers.MockSaramaClient{}
+	client.On("ListConsumerGroups").Return(map[string]string{
+		"seengroup":    "consumer",
+		"unseengroup":  "consumer",
+		"droppedgroup": "consumer",
+		"burrow-test":  "consumer",
+	}, nil)
+	client.On("FetchConsumerOffsets", "unseengroup", map[string][]int32(nil)).Return(map[string]map[int32]int64{
+		"testtopic": {3: 8372},
+	}, nil)
+
+	module.running.Add(1)
+	go module.fetchUnseenGroups(client)
+	request := <-module.App.StorageChannel
+	module.running.Wait()
+
+	client.AssertExpectations(t)
+	assert.Equalf(t, protocol.StorageSetConsumerOffset, request.RequestType, "Expected request sent with type StorageSetConsumerOffset, not %v", request.RequestType)
+	assert.Equalf(t, "unseengroup", request.Group, "Expected request sent with Group unseengroup, not %v", request.Group)
+	assert.Equalf(t, "testtopic", request.Topic, "Expected request sent with topic testtopic, not %v", request.Topic)
+	assert.Equalf(t, int32(3), request.Partition, "Expected request sent with partition 3, not %v", request.Partition)
+	assert.Equalf(t, int64(8372), request.Offset, "Expected Offset to be 8372, not %v", request.Offset)
+	assert.Equalf(t, int64(1637000), request.Timestamp, "Expected Timestamp to be the lookback start, not %v", request.Timestamp)
+	assert.Equalf(t, int64(-1), request.Order, "Expected Order to be before any consumed offset, not %v", request.Order)
+}
+
 func TestKafkaClient_startKafkaConsumer_FailCreateConsumer(t *testing.T) {
 	module := fixtureModule()
 	module.Configure("test", "consumer.test")
@@ -508,6 +836,29 @@ func TestKafkaClient_decodeOffsetValueV3_Errors(t *testing.T) {
 	}
 }
 
+func TestKafkaClient_decodeOffsetValueV4(t *testing.T) {
+	buf := bytes.NewBuffer([]byte("\x00\x00\x00\x00\x00\x00\x20\xb4\x00\x00\x00\x00\x09testdata\x00\x00\x00\x00\x00\x00\x06\x65\x00"))
+	result, errorAt := decodeOffsetValueV4(buf)
+
+	assert.Equalf(t, "", errorAt, "Expected decodeOffsetValueV4 to return empty errorAt, not %v", errorAt)
+	assert.Equalf(t, int64(8372), result.Offset, "Expected Offset to be 8372, not %v", result.Offset)
+	assert.Equalf(t, int64(1637), result.Timestamp, "Expected Timestamp to be 1637, not %v", result.Timestamp)
+}
+
+var decodeOffsetValueV4Errors = []errorTestSetBytesWithString{
+	{[]byte("\x00\x00\x00\x00\x00"), "offset"},
+	{[]byte("\x00\x00\x00\x00\x00\x00\x20\xb4\x00\x00\x00"), "leaderEpoch"},
+	{[]byte("\x00\x00\x00\x00\x00\x00\x20\xb4\x00\x00\x00\x00\x09tes"), "metadata"},
+	{[]byte("\x00\x00\x00\x00\x00\x00\x20\xb4\x00\x00\x00\x00\x09testdata\x00\x00\x00\x00"), "timestamp"},
+}
+
+func TestKafkaClient_decodeOffsetValueV4_Errors(t *testing.T) {
+	for _, values := range decodeOffsetValueV4Errors {
+		_, errorAt := decodeOffsetValueV4(bytes.NewBuffer(values.Bytes))
+		assert.Equalf(t, values.ErrorAt, errorAt, "Expected errorAt to be %v, not %v", values.ErrorAt, errorAt)
+	}
+}
+
 func TestKafkaClient_decodeKeyAndOffset(t *testing.T) {
 	module := fixtureModule()
 	viper.Set("consumer.test.group-allowlist", "test.*")
@@ -532,7 +883,31 @@ func TestKafkaClient_decodeKeyAndOffset(t *testing.T) {
 var decodeKeyAndOffsetErrors = []errorTestSetBytes{
 	{[]byte("\x00\x09testgroup\x00\x09testt"), []byte("\x00\x00\x00\x00\x00\x00\x00\x00\x20\xb4\x00\x08testdata\x00\x00\x00\x00\x00\x00\x06\x65")},
 	{[]byte("\x00\x09testgroup\x00\x09testtopic\x00\x00\x00\x0b"), []byte("\x00")},
-	{[]byte("\x00\x09testgroup\x00\x09testtopic\x00\x00\x00\x0b"), []byte("\x00\x02\x00\x00\x00\x00\x00\x00\x20\xb4\x00\x08testdata\x00\x00\x00\x00\x00\x00\x06\x65")},
+	{[]byte("\x00\x09testgroup\x00\x09testtopic\x00\x00\x00\x0b"), []byte("\x00\x05\x00\x00\x00\x00\x00\x00\x20\xb4\x00\x08testdata\x00\x00\x00\x00\x00\x00\x06\x65")},
+}
+
+// The offset and commit timestamp are the same in every version of the value, to check that they are decoded from all
+var decodeKeyAndOffsetValueVersions = [][]byte{
+	/*0*/ []byte("\x00\x00\x00\x00\x00\x00\x00\x00\x20\xb4\x00\x08testdata\x00\x00\x01\x7d\x1c\x6b\x5e\x28"),
+	/*1*/ []byte("\x00\x01\x00\x00\x00\x00\x00\x00\x20\xb4\x00\x08testdata\x00\x00\x01\x7d\x1c\x6b\x5e\x28\x00\x00\x01\x7d\x21\x91\xba\x28"),
+	/*2*/ []byte("\x00\x02\x00\x00\x00\x00\x00\x00\x20\xb4\x00\x08testdata\x00\x00\x01\x7d\x1c\x6b\x5e\x28"),
+	/*3*/ []byte("\x00\x03\x00\x00\x00\x00\x00\x00\x20\xb4\x00\x00\x00\x05\x00\x08testdata\x00\x00\x01\x7d\x1c\x6b\x5e\x28"),
+	/*4*/ []byte("\x00\x04\x00\x00\x00\x00\x00\x00\x20\xb4\x00\x00\x00\x05\x09testdata\x00\x00\x01\x7d\x1c\x6b\x5e\x28\x00"),
+}
+
+func TestKafkaClient_decodeKeyAndOffset_ValueVersions(t *testing.T) {
+	module := fixtureModule()
+	module.Configure("test", "consumer.test")
+
+	for i, valueBytes := range decodeKeyAndOffsetValueVersions {
+		keyBuf := bytes.NewBuffer([]byte("\x00\x09testgroup\x00\x09testtopic\x00\x00\x00\x0b"))
+		go module.decodeKeyAndOffset(543, keyBuf, valueBytes, zap.NewNop())
+		request := <-module.App.StorageChannel
+
+		assert.Equalf(t, protocol.StorageSetConsumerOffset, request.RequestType, "VERSION %v: Expected request sent with type StorageSetConsumerOffset, not %v", i, request.RequestType)
+		assert.Equalf(t, int64(8372), request.Offset, "VERSION %v: Expected Offset to be 8372, not %v", i, request.Offset)
+		assert.Equalf(t, int64(1636859338280), request.Timestamp, "VERSION %v: Expected Timestamp to be the commit timestamp, not %v", i, request.Timestamp)
+	}
 }
 
 func TestKafkaClient_decodeKeyAndOffset_BadValueVersion(t *testing.T) {
@@ -557,6 +932,50 @@ func TestKafkaClient_decodeKeyAndOffset_Allowlist(t *testing.T) {
 	module.decodeKeyAndOffset(0, keyBuf, valueBytes, zap.NewNop())
 }
 
+func TestKafkaClient_decodeKeyAndOffset_TopicFilter(t *testing.T) {
+	module := fixtureModule()
+	viper.Set("consumer.test.topic-filter", "test.*")
+	viper.Set("consumer.test.topic-exclude", ".*-dropped$")
+	module.Configure("test", "consumer.test")
+
+	valueBytes := []byte("\x00\x00\x00\x00\x00\x00\x00\x00\x20\xb4\x00\x08testdata\x00\x00\x00\x00\x00\x00\x06\x65")
+
+	// Should not timeout as the topics should be dropped by the filter and the exclusion
+	module.decodeKeyAndOffset(0, bytes.NewBuffer([]byte("\x00\x09testgroup\x00\x09othertopic\x00\x00\x00\x0b")), valueBytes, zap.NewNop())
+	module.decodeKeyAndOffset(0, bytes.NewBuffer([]byte("\x00\x09testgroup\x00\x11testtopic-dropped\x00\x00\x00\x0b")), valueBytes, zap.NewNop())
+
+	go module.decodeKeyAndOffset(543, bytes.NewBuffer([]byte("\x00\x09testgroup\x00\x09testtopic\x00\x00\x00\x0b")), valueBytes, zap.NewNop())
+	request := <-module.App.StorageChannel
+	assert.Equalf(t, "testtopic", request.Topic, "Expected request sent with topic testtopic, not %v", request.Topic)
+}
+
+func TestKafkaClient_Configure_BadTopicRegexp(t *testing.T) {
+	module := fixtureModule()
+	viper.Set("consumer.test.topic-exclude", "[")
+	assert.Panics(t, func() { module.Configure("test", "consumer.test") }, "The code did not panic")
+}
+
+func TestKafkaClient_ReloadFilters(t *testing.T) {
+	module := fixtureModule()
+	viper.Set("consumer.test.group-allowlist", "test.*")
+	module.Configure("test", "consumer.test")
+	assert.False(t, module.acceptConsumerGroup("othergroup"), "Expected othergroup to be dropped by the allowlist")
+
+	// The filters come from the provided configuration, not the global one
+	config := viper.New()
+	config.Set("consumer.test.group-allowlist", "(test|other).*")
+	config.Set("consumer.test.topic-exclude", "^dropped")
+	assert.NoError(t, module.ReloadFilters(config))
+	assert.True(t, module.acceptConsumerGroup("othergroup"), "Expected othergroup to pass the reloaded allowlist")
+	assert.False(t, module.acceptTopic("droppedtopic"), "Expected droppedtopic to be excluded after the reload")
+
+	// An invalid filter keeps the current ones
+	config.Set("consumer.test.group-allowlist", "[")
+	assert.Error(t, module.ReloadFilters(config))
+	assert.True(t, module.acceptConsumerGroup("othergroup"), "Expected the previous allowlist to be kept")
+	assert.False(t, module.acceptTopic("droppedtopic"), "Expected the previous topic exclusion to be kept")
+}
+
 func TestKafkaClient_decodeAndSendOffset_ErrorValue(t *testing.T) {
 	module := fixtureModule()
 	module.Configure("test", "consumer.test")
@@ -582,6 +1001,12 @@ func TestKafkaClient_decodeGroupMetadata(t *testing.T) {
 	go module.decodeGroupMetadata(keyBuf, valueBytes, zap.NewNop())
 	request := <-module.App.StorageChannel
 
+	assert.Equalf(t, protocol.StorageSetConsumerGeneration, request.RequestType, "Expected request sent with type StorageSetConsumerGeneration, not %v", request.RequestType)
+	assert.Equalf(t, "testgroup", request.Group, "Expected request sent with Group testgroup, not %v", request.Group)
+	assert.Equalf(t, int32(1), request.Generation, "Expected request sent with Generation 1, not %v", request.Generation)
+	assert.True(t, request.Timestamp > 0, "Expected request sent with the time the metadata was processed")
+
+	request = <-module.App.StorageChannel
 	assert.Equalf(t, protocol.StorageSetConsumerOwner, request.RequestType, "Expected request sent with type StorageSetConsumerOwner, not %v", request.RequestType)
 	assert.Equalf(t, "test", request.Cluster, "Expected request sent with cluster test, not %v", request.Cluster)
 	assert.Equalf(t, "topic1", request.Topic, "Expected request sent with topic testtopic, not %v", request.Topic)
@@ -640,7 +1065,9 @@ func TestKafkaClient_processConsumerOffsetsMessage_Offset(t *testing.T) {
 
 	go module.processConsumerOffsetsMessage(msg)
 	request := <-module.App.StorageChannel
+	assert.Equalf(t, protocol.StorageSetConsumerGeneration, request.RequestType, "Expected request sent with type StorageSetConsumerGeneration, not %v", request.RequestType)
 
+	request = <-module.App.StorageChannel
 	assert.Equalf(t, protocol.StorageSetConsumerOwner, request.RequestType, "Expected request sent with type StorageSetConsumerOwner, not %v", request.RequestType)
 	assert.Equalf(t, "test", request.Cluster, "Expected request sent with cluster test, not %v", request.Cluster)
 	assert.Equalf(t, "topic1", request.Topic, "Expected request sent with topic testtopic, not %v", request.Topic)
@@ -0,0 +1,300 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package consumer
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/linkedin/Burrow/core/internal/helpers"
+	"github.com/linkedin/Burrow/core/internal/httpserver"
+	"github.com/linkedin/Burrow/core/protocol"
+)
+
+// KafkaConnectClient is a consumer module which reads the offsets topic of a Kafka Connect cluster, which is typically
+// connect-offsets, and reports the source offsets of each connector as a consumer group named for the connector. This
+// works for source connectors that read from Kafka (such as MirrorMaker 2), where the source partition identifies a
+// topic and partition in the cluster that the module is associated with.
+type KafkaConnectClient struct {
+	// App is a pointer to the application context. This stores the channel to the storage subsystem
+	App *protocol.ApplicationContext
+
+	// Log is a logger that has been configured for this module to use. Normally, this means it has been set up with
+	// fields that are appropriate to identify this coordinator
+	Log *zap.Logger
+
+	name           string
+	cluster        string
+	servers        []string
+	offsetsTopic   string
+	groupPrefix    string
+	topicField     string
+	partitionField string
+	offsetField    string
+	clientProfile  string
+	saramaConfig   *sarama.Config
+
+	quitChannel chan struct{}
+	running     sync.WaitGroup
+}
+
+// Configure validates the configuration for the consumer. At minimum, there must be a cluster name to which these
+// connector offsets belong, as well as a list of servers provided for the Kafka cluster that holds the Connect offsets
+// topic, of the form host:port. If not explicitly configured, the offsets topic is set to connect-offsets, and the
+// source partition and offset are read from the "topic", "partition", and "offset" fields. If the cluster name is
+// unknown, or if the server list is missing or invalid, this func will panic.
+func (module *KafkaConnectClient) Configure(name, configRoot string) {
+	module.Log.Info("configuring")
+
+	module.name = name
+	module.quitChannel = make(chan struct{})
+	module.running = sync.WaitGroup{}
+
+	module.cluster = viper.GetString(configRoot + ".cluster")
+	if !viper.IsSet("cluster." + module.cluster) {
+		panic("Consumer '" + name + "' references an unknown cluster '" + module.cluster + "'")
+	}
+
+	module.clientProfile = viper.GetString(configRoot + ".client-profile")
+	module.saramaConfig = helpers.GetSaramaConfigFromClientProfile(module.clientProfile)
+	helpers.LogSaramaConfig(module.Log, module.clientProfile, module.saramaConfig)
+
+	module.servers = viper.GetStringSlice(configRoot + ".servers")
+	if len(module.servers) == 0 {
+		panic("No Kafka brokers specified for consumer " + module.name)
+	} else if !helpers.ValidateHostList(module.servers) {
+		panic("Consumer '" + name + "' has one or more improperly formatted servers (must be host:port)")
+	}
+
+	// Set defaults for configs if needed, and get them
+	viper.SetDefault(configRoot+".offsets-topic", "connect-offsets")
+	viper.SetDefault(configRoot+".group-prefix", "connect-")
+	viper.SetDefault(configRoot+".topic-field", "topic")
+	viper.SetDefault(configRoot+".partition-field", "partition")
+	viper.SetDefault(configRoot+".offset-field", "offset")
+	module.offsetsTopic = viper.GetString(configRoot + ".offsets-topic")
+	module.groupPrefix = viper.GetString(configRoot + ".group-prefix")
+	module.topicField = viper.GetString(configRoot + ".topic-field")
+	module.partitionField = viper.GetString(configRoot + ".partition-field")
+	module.offsetField = viper.GetString(configRoot + ".offset-field")
+}
+
+// CheckConnection connects to the Kafka cluster with the configured servers and client profile, fetches the metadata
+// for the cluster, and makes sure that the offsets topic exists, then disconnects again. Any error is returned to the
+// caller.
+func (module *KafkaConnectClient) CheckConnection() error {
+	return helpers.CheckKafkaConnection(module.Log, module.clientProfile, module.servers, module.saramaConfig, module.offsetsTopic)
+}
+
+// Start connects to the Kafka cluster using the Shopify/sarama client. Any error connecting to the cluster is returned
+// to the caller. Once the client is set up, the consumers for the configured offsets topic are started.
+func (module *KafkaConnectClient) Start() error {
+	module.Log.Info("starting")
+
+	// Connect Kafka client
+	helpers.DetectKafkaVersion(module.Log, module.clientProfile, module.servers, module.saramaConfig)
+	client, err := sarama.NewClient(module.servers, module.saramaConfig)
+	if err != nil {
+		module.Log.Error("failed to start client", zap.Error(err))
+		return err
+	}
+	httpserver.RegisterSaramaMetrics(module.cluster, "consumer."+module.name, module.saramaConfig.MetricRegistry)
+
+	// Start the consumers
+	err = module.startConnectConsumer(&helpers.BurrowSaramaClient{Client: client})
+	if err != nil {
+		module.Log.Error("failed to start consumer", zap.Error(err))
+		httpserver.UnregisterSaramaMetrics(module.cluster, "consumer."+module.name)
+		client.Close()
+		return err
+	}
+
+	return nil
+}
+
+// Stop closes the goroutines that listen to the client consumer.
+func (module *KafkaConnectClient) Stop() error {
+	module.Log.Info("stopping")
+
+	close(module.quitChannel)
+	module.running.Wait()
+	httpserver.UnregisterSaramaMetrics(module.cluster, "consumer."+module.name)
+
+	return nil
+}
+
+func (module *KafkaConnectClient) startConnectConsumer(client helpers.SaramaClient) error {
+	// Create the consumer from the client
+	consumer, err := client.NewConsumerFromClient()
+	if err != nil {
+		module.Log.Error("failed to get new consumer", zap.Error(err))
+		client.Close()
+		return err
+	}
+
+	// Get a partition count for the consumption topic
+	partitions, err := client.Partitions(module.offsetsTopic)
+	if err != nil {
+		module.Log.Error("failed to get partition count",
+			zap.String("topic", module.offsetsTopic),
+			zap.String("error", err.Error()),
+		)
+		client.Close()
+		return err
+	}
+
+	// The offsets topic is compacted, so it is always read from the start to get the latest offset for each connector
+	module.Log.Info("starting consumers",
+		zap.String("topic", module.offsetsTopic),
+		zap.Int("count", len(partitions)),
+	)
+	for _, partition := range partitions {
+		pconsumer, err := consumer.ConsumePartition(module.offsetsTopic, partition, sarama.OffsetOldest)
+		if err != nil {
+			module.Log.Error("failed to consume partition",
+				zap.String("topic", module.offsetsTopic),
+				zap.Int32("partition", partition),
+				zap.String("error", err.Error()),
+			)
+			return err
+		}
+		module.running.Add(1)
+		go module.partitionConsumer(pconsumer)
+	}
+
+	return nil
+}
+
+func (module *KafkaConnectClient) partitionConsumer(consumer sarama.PartitionConsumer) {
+	defer module.running.Done()
+	defer consumer.AsyncClose()
+
+	for {
+		select {
+		case msg := <-consumer.Messages():
+			if msg == nil {
+				continue
+			}
+			module.processConnectOffsetsMessage(msg)
+		case err := <-consumer.Errors():
+			if err == nil {
+				continue
+			}
+			module.Log.Error("consume error",
+				zap.String("topic", err.Topic),
+				zap.Int32("partition", err.Partition),
+				zap.String("error", err.Err.Error()),
+			)
+		case <-module.quitChannel:
+			return
+		}
+	}
+}
+
+// decodeJSON unmarshals data, keeping numbers as json.Number so that large offsets do not lose precision
+func decodeJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+func (module *KafkaConnectClient) processConnectOffsetsMessage(msg *sarama.ConsumerMessage) {
+	logger := module.Log.With(
+		zap.String("offset_topic", msg.Topic),
+		zap.Int32("offset_partition", msg.Partition),
+		zap.Int64("offset_offset", msg.Offset),
+	)
+
+	// The key is a JSON array of the connector name and the source partition
+	var key []json.RawMessage
+	if err := decodeJSON(msg.Key, &key); err != nil || len(key) != 2 {
+		logger.Warn("failed to decode", zap.String("reason", "key"))
+		return
+	}
+	var connector string
+	var sourcePartition map[string]interface{}
+	if err := decodeJSON(key[0], &connector); err != nil {
+		logger.Warn("failed to decode", zap.String("reason", "connector"))
+		return
+	}
+	if err := decodeJSON(key[1], &sourcePartition); err != nil {
+		logger.Warn("failed to decode", zap.String("reason", "source partition"), zap.String("connector", connector))
+		return
+	}
+	logger = logger.With(zap.String("connector", connector))
+
+	topic, ok := sourcePartition[module.topicField].(string)
+	if !ok {
+		logger.Debug("dropped", zap.String("reason", "no source topic"))
+		return
+	}
+	partitionNumber, ok := sourcePartition[module.partitionField].(json.Number)
+	if !ok {
+		logger.Debug("dropped", zap.String("reason", "no source partition"))
+		return
+	}
+	partition, err := partitionNumber.Int64()
+	if err != nil {
+		logger.Warn("failed to decode", zap.String("reason", "source partition"))
+		return
+	}
+
+	if len(msg.Value) == 0 {
+		// Tombstone message - we don't handle them for now
+		logger.Debug("dropped tombstone")
+		return
+	}
+
+	var sourceOffset map[string]interface{}
+	if err := decodeJSON(msg.Value, &sourceOffset); err != nil {
+		logger.Warn("failed to decode", zap.String("reason", "source offset"))
+		return
+	}
+	number, ok := sourceOffset[module.offsetField].(json.Number)
+	if !ok {
+		logger.Warn("failed to decode", zap.String("reason", "no offset"))
+		return
+	}
+	offset, err := number.Int64()
+	if err != nil {
+		logger.Warn("failed to decode", zap.String("reason", "offset"))
+		return
+	}
+
+	timestamp := msg.Timestamp.UnixNano() / int64(time.Millisecond)
+	if msg.Timestamp.IsZero() {
+		timestamp = time.Now().Unix() * 1000
+	}
+
+	// Connect stores the offset of the last record the task processed, while consumer groups commit the offset of the
+	// next record to read, so one is added to make the lag of a connector that is caught up zero
+	partitionOffset := &protocol.StorageRequest{
+		RequestType: protocol.StorageSetConsumerOffset,
+		Cluster:     module.cluster,
+		Topic:       topic,
+		Partition:   int32(partition),
+		Group:       module.groupPrefix + connector,
+		Timestamp:   timestamp,
+		Offset:      offset + 1,
+		Order:       msg.Offset,
+	}
+	logger.Debug("connector offset",
+		zap.String("topic", topic),
+		zap.Int64("partition", partition),
+		zap.Int64("offset", offset),
+	)
+	helpers.TimeoutSendStorageRequest(module.App.StorageChannel, partitionOffset, 1)
+}
@@ -0,0 +1,177 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package consumer
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/linkedin/Burrow/core/internal/helpers"
+	"github.com/linkedin/Burrow/core/protocol"
+)
+
+func fixtureConnectModule() *KafkaConnectClient {
+	module := KafkaConnectClient{
+		Log: zap.NewNop(),
+	}
+	module.App = &protocol.ApplicationContext{
+		StorageChannel: make(chan *protocol.StorageRequest),
+	}
+
+	viper.Reset()
+	viper.Set("client-profile..client-id", "testid")
+	viper.Set("cluster.test.class-name", "kafka")
+	viper.Set("cluster.test.servers", []string{"broker1.example.com:1234"})
+	viper.Set("consumer.test.class-name", "kafka_connect")
+	viper.Set("consumer.test.servers", []string{"broker1.example.com:1234"})
+	viper.Set("consumer.test.cluster", "test")
+
+	return &module
+}
+
+func TestKafkaConnectClient_ImplementsModule(t *testing.T) {
+	assert.Implements(t, (*protocol.Module)(nil), new(KafkaConnectClient))
+}
+
+func TestKafkaConnectClient_Configure(t *testing.T) {
+	module := fixtureConnectModule()
+	module.Configure("test", "consumer.test")
+	assert.NotNil(t, module.saramaConfig, "Expected saramaConfig to be populated")
+	assert.Equal(t, "connect-offsets", module.offsetsTopic, "Default offsets topic of connect-offsets did not get set")
+	assert.Equal(t, "connect-", module.groupPrefix, "Default group prefix of connect- did not get set")
+}
+
+func TestKafkaConnectClient_Configure_BadCluster(t *testing.T) {
+	module := fixtureConnectModule()
+	viper.Set("consumer.test.cluster", "nocluster")
+
+	assert.Panics(t, func() { module.Configure("test", "consumer.test") }, "The code did not panic")
+}
+
+func TestKafkaConnectClient_startConnectConsumer(t *testing.T) {
+	module := fixtureConnectModule()
+	module.Configure("test", "consumer.test")
+
+	// Channels for testing
+	messageChan := make(chan *sarama.ConsumerMessage)
+	errorChan := make(chan *sarama.ConsumerError)
+
+	// Don't assert expectations on this - the way it goes down, they're called but don't show up
+	mockPartitionConsumer := &helpers.MockSaramaPartitionConsumer{}
+	mockPartitionConsumer.On("AsyncClose").Return()
+	mockPartitionConsumer.On("Messages").Return(func() <-chan *sarama.ConsumerMessage { return messageChan }())
+	mockPartitionConsumer.On("Errors").Return(func() <-chan *sarama.ConsumerError { return errorChan }())
+
+	consumer := &helpers.MockSaramaConsumer{}
+	consumer.On("ConsumePartition", "connect-offsets", int32(0), sarama.OffsetOldest).Return(mockPartitionConsumer, nil)
+
+	client := &helpers.MockSaramaClient{}
+	client.On("NewConsumerFromClient").Return(consumer, nil)
+	client.On("Partitions", "connect-offsets").Return([]int32{0}, nil)
+
+	err := module.startConnectConsumer(client)
+	assert.Nil(t, err, "Expected startConnectConsumer to return no error")
+
+	close(module.quitChannel)
+	module.running.Wait()
+
+	consumer.AssertExpectations(t)
+	client.AssertExpectations(t)
+}
+
+func TestKafkaConnectClient_startConnectConsumer_FailGetPartitions(t *testing.T) {
+	module := fixtureConnectModule()
+	module.Configure("test", "consumer.test")
+
+	consumer := &helpers.MockSaramaConsumer{}
+	testError := errors.New("test error")
+	client := &helpers.MockSaramaClient{}
+	client.On("NewConsumerFromClient").Return(consumer, nil)
+	client.On("Partitions", "connect-offsets").Return([]int32{}, testError)
+	client.On("Close").Return(nil)
+
+	err := module.startConnectConsumer(client)
+	client.AssertExpectations(t)
+	assert.Equal(t, testError, err, "Expected startConnectConsumer to return error")
+}
+
+func TestKafkaConnectClient_processConnectOffsetsMessage(t *testing.T) {
+	module := fixtureConnectModule()
+	module.Configure("test", "consumer.test")
+
+	message := &sarama.ConsumerMessage{
+		Key:       []byte(`["mm2-source",{"cluster":"primary","partition":3,"topic":"testtopic"}]`),
+		Value:     []byte(`{"offset":8372}`),
+		Topic:     "connect-offsets",
+		Partition: 0,
+		Offset:    543,
+		Timestamp: time.Unix(1637, 0),
+	}
+	go module.processConnectOffsetsMessage(message)
+	request := <-module.App.StorageChannel
+
+	assert.Equalf(t, protocol.StorageSetConsumerOffset, request.RequestType, "Expected request sent with type StorageSetConsumerOffset, not %v", request.RequestType)
+	assert.Equalf(t, "test", request.Cluster, "Expected request sent with cluster test, not %v", request.Cluster)
+	assert.Equalf(t, "testtopic", request.Topic, "Expected request sent with topic testtopic, not %v", request.Topic)
+	assert.Equalf(t, int32(3), request.Partition, "Expected request sent with partition 3, not %v", request.Partition)
+	assert.Equalf(t, "connect-mm2-source", request.Group, "Expected request sent with Group connect-mm2-source, not %v", request.Group)
+	assert.Equalf(t, int64(8373), request.Offset, "Expected Offset to be 8373, not %v", request.Offset)
+	assert.Equalf(t, int64(543), request.Order, "Expected Order to be 543, not %v", request.Order)
+	assert.Equalf(t, int64(1637000), request.Timestamp, "Expected Timestamp to be 1637000, not %v", request.Timestamp)
+}
+
+func TestKafkaConnectClient_processConnectOffsetsMessage_Fields(t *testing.T) {
+	module := fixtureConnectModule()
+	viper.Set("consumer.test.topic-field", "kafka_topic")
+	viper.Set("consumer.test.partition-field", "kafka_partition")
+	viper.Set("consumer.test.offset-field", "kafka_offset")
+	module.Configure("test", "consumer.test")
+
+	message := &sarama.ConsumerMessage{
+		Key:   []byte(`["replicator",{"kafka_topic":"testtopic","kafka_partition":1}]`),
+		Value: []byte(`{"kafka_offset":9007199254740993}`),
+	}
+	go module.processConnectOffsetsMessage(message)
+	request := <-module.App.StorageChannel
+
+	assert.Equal(t, "testtopic", request.Topic)
+	assert.Equal(t, int32(1), request.Partition)
+	assert.Equal(t, int64(9007199254740994), request.Offset, "Expected large offsets to keep their precision")
+}
+
+var connectOffsetsMessageErrors = []errorTestSetBytes{
+	{[]byte(`not json`), []byte(`{"offset":1}`)},
+	{[]byte(`["connector"]`), []byte(`{"offset":1}`)},
+	{[]byte(`[1,{"topic":"testtopic","partition":0}]`), []byte(`{"offset":1}`)},
+	{[]byte(`["connector","partition"]`), []byte(`{"offset":1}`)},
+	{[]byte(`["connector",{"filename":"/tmp/test.txt"}]`), []byte(`{"position":1}`)},
+	{[]byte(`["connector",{"topic":"testtopic"}]`), []byte(`{"offset":1}`)},
+	{[]byte(`["connector",{"topic":"testtopic","partition":0.5}]`), []byte(`{"offset":1}`)},
+	{[]byte(`["connector",{"topic":"testtopic","partition":0}]`), nil},
+	{[]byte(`["connector",{"topic":"testtopic","partition":0}]`), []byte(`not json`)},
+	{[]byte(`["connector",{"topic":"testtopic","partition":0}]`), []byte(`{"position":1}`)},
+	{[]byte(`["connector",{"topic":"testtopic","partition":0}]`), []byte(`{"offset":"1"}`)},
+}
+
+func TestKafkaConnectClient_processConnectOffsetsMessage_Errors(t *testing.T) {
+	module := fixtureConnectModule()
+	module.Configure("test", "consumer.test")
+
+	for _, values := range connectOffsetsMessageErrors {
+		// Should not timeout
+		module.processConnectOffsetsMessage(&sarama.ConsumerMessage{Key: values.KeyBytes, Value: values.ValueBytes})
+	}
+}
@@ -44,34 +44,51 @@ type KafkaZkClient struct {
 	Log *zap.Logger
 
 	name             string
+	configRoot       string
 	cluster          string
 	servers          []string
 	zookeeperTimeout int
-	zookeeperPath    string
+	zookeeperPaths   []string
 
 	zk             protocol.ZookeeperClient
 	areWatchesSet  bool
 	running        *sync.WaitGroup
 	groupLock      *sync.RWMutex
-	groupList      map[string]*topicList
+	groupList      map[string]map[string]*topicList
 	groupAllowlist *regexp.Regexp
 	groupDenylist  *regexp.Regexp
+	filterLock     *sync.RWMutex
 	connectFunc    func([]string, time.Duration, *zap.Logger) (protocol.ZookeeperClient, <-chan zk.Event, error)
 }
 
 // Configure validates the configuration for the consumer. At minimum, there must be a cluster name to which these
 // consumers belong, as well as a list of servers provided for the Zookeeper ensemble, of the form host:port. If not
-// explicitly configured, it is assumed that the Kafka cluster metadata is present in the ensemble root path. If the
-// cluster name is unknown, or if the server list is missing or invalid, this func will panic.
+// explicitly configured, it is assumed that the Kafka cluster metadata is present in the ensemble root path. The
+// zookeeper-paths config can instead list the full paths of one or more consumers trees (such as /legacy/consumers),
+// and the offsets found in all of them are merged. The zookeeper-tls and zookeeper-digest-auth configs name the tls and
+// sasl profiles to connect to the ensemble with, in the same way as zookeeper.tls and zookeeper.digest-auth. If the
+// cluster name is unknown, if the server list is missing or invalid, if any of the paths is invalid, or if a profile
+// does not exist, this func will panic.
 func (module *KafkaZkClient) Configure(name, configRoot string) {
 	module.Log.Info("configuring")
 
 	module.name = name
 	module.running = &sync.WaitGroup{}
 	module.groupLock = &sync.RWMutex{}
-	module.groupList = make(map[string]*topicList)
+	module.filterLock = &sync.RWMutex{}
 	module.connectFunc = helpers.ZookeeperConnect
 
+	tlsProfile := viper.GetString(configRoot + ".zookeeper-tls")
+	digestAuthProfile := viper.GetString(configRoot + ".zookeeper-digest-auth")
+	if err := helpers.ValidateZookeeperProfiles("Consumer '"+name+"'", tlsProfile, digestAuthProfile); err != nil {
+		panic(err.Error())
+	}
+	if (tlsProfile != "") || (digestAuthProfile != "") {
+		module.connectFunc = func(servers []string, sessionTimeout time.Duration, logger *zap.Logger) (protocol.ZookeeperClient, <-chan zk.Event, error) {
+			return helpers.ZookeeperConnectWithProfiles(servers, sessionTimeout, tlsProfile, digestAuthProfile, logger)
+		}
+	}
+
 	module.servers = viper.GetStringSlice(configRoot + ".servers")
 	if len(module.servers) == 0 {
 		panic("No Zookeeper servers specified for consumer " + module.name)
@@ -82,12 +99,20 @@ func (module *KafkaZkClient) Configure(name, configRoot string) {
 	// Set defaults for configs if needed, and get them
 	viper.SetDefault(configRoot+".zookeeper-timeout", 30)
 	module.zookeeperTimeout = viper.GetInt(configRoot + ".zookeeper-timeout")
-	module.zookeeperPath = viper.GetString(configRoot+".zookeeper-path") + "/consumers"
 	module.cluster = viper.GetString(configRoot + ".cluster")
 
-	if !helpers.ValidateZookeeperPath(module.zookeeperPath) {
-		panic("Consumer '" + name + "' has a bad zookeeper path configuration")
+	// Legacy consumers may keep their offsets in more than one tree in the ensemble. If a list of consumers paths is
+	// given, all of them are read and it replaces the default tree under zookeeper-path
+	module.zookeeperPaths = viper.GetStringSlice(configRoot + ".zookeeper-paths")
+	if len(module.zookeeperPaths) == 0 {
+		module.zookeeperPaths = []string{viper.GetString(configRoot+".zookeeper-path") + "/consumers"}
 	}
+	for _, consumersPath := range module.zookeeperPaths {
+		if !helpers.ValidateZookeeperPath(consumersPath) {
+			panic("Consumer '" + name + "' has a bad zookeeper path configuration")
+		}
+	}
+	module.resetGroupList()
 
 	// Check for disallowed config values
 	if viper.IsSet(configRoot+".group-whitelist") || viper.IsSet(configRoot+".group-blacklist") {
@@ -95,24 +120,10 @@ func (module *KafkaZkClient) Configure(name, configRoot string) {
 		panic("Please change configurations to allowlist and denylist")
 	}
 
-	allowlist := viper.GetString(configRoot + ".group-allowlist")
-	if allowlist != "" {
-		re, err := regexp.Compile(allowlist)
-		if err != nil {
-			module.Log.Panic("Failed to compile group allowlist")
-			panic(err)
-		}
-		module.groupAllowlist = re
-	}
-
-	denylist := viper.GetString(configRoot + ".group-denylist")
-	if denylist != "" {
-		re, err := regexp.Compile(denylist)
-		if err != nil {
-			module.Log.Panic("Failed to compile group denylist")
-			panic(err)
-		}
-		module.groupDenylist = re
+	module.configRoot = configRoot
+	if err := module.ReloadFilters(viper.GetViper()); err != nil {
+		module.Log.Panic(err.Error())
+		panic(err)
 	}
 }
 
@@ -130,8 +141,10 @@ func (module *KafkaZkClient) Start() error {
 	module.zk = zkconn
 
 	// Set up all groups initially (we can't count on catching the first CONNECTED event
-	module.running.Add(1)
-	module.resetGroupListWatchAndAdd(false)
+	for _, consumersPath := range module.zookeeperPaths {
+		module.running.Add(1)
+		module.resetGroupListWatchAndAdd(consumersPath, false)
+	}
 	module.areWatchesSet = true
 
 	// Start up a func to watch for connection state changes and reset all the watches when needed
@@ -164,18 +177,43 @@ func (module *KafkaZkClient) connectionStateWatcher(eventChan <-chan zk.Event) {
 				if !module.areWatchesSet {
 					module.Log.Info("reinitializing watches")
 					module.groupLock.Lock()
-					module.groupList = make(map[string]*topicList)
+					module.resetGroupList()
 					module.groupLock.Unlock()
 
-					module.running.Add(1)
-					go module.resetGroupListWatchAndAdd(false)
+					for _, consumersPath := range module.zookeeperPaths {
+						module.running.Add(1)
+						go module.resetGroupListWatchAndAdd(consumersPath, false)
+					}
 				}
 			}
 		}
 	}
 }
 
+// ReloadFilters compiles the group-allowlist and group-denylist configurations for the consumer, and replaces the
+// current filters with them. If either of them is invalid, an error is returned and the current filters are kept. The
+// new filters apply to groups that are found after the reload, when the consumer group list changes.
+func (module *KafkaZkClient) ReloadFilters(config *viper.Viper) error {
+	groupAllowlist, err := helpers.CompileConfigRegexp(config, module.configRoot+".group-allowlist")
+	if err != nil {
+		return err
+	}
+	groupDenylist, err := helpers.CompileConfigRegexp(config, module.configRoot+".group-denylist")
+	if err != nil {
+		return err
+	}
+
+	module.filterLock.Lock()
+	defer module.filterLock.Unlock()
+	module.groupAllowlist = groupAllowlist
+	module.groupDenylist = groupDenylist
+	return nil
+}
+
 func (module *KafkaZkClient) acceptConsumerGroup(group string) bool {
+	module.filterLock.RLock()
+	defer module.filterLock.RUnlock()
+
 	if (module.groupAllowlist != nil) && (!module.groupAllowlist.MatchString(group)) {
 		return false
 	}
@@ -185,6 +223,15 @@ func (module *KafkaZkClient) acceptConsumerGroup(group string) bool {
 	return true
 }
 
+// resetGroupList clears the groups that are being watched in each of the consumers paths. The caller must hold the
+// group lock, if watches may be running.
+func (module *KafkaZkClient) resetGroupList() {
+	module.groupList = make(map[string]map[string]*topicList, len(module.zookeeperPaths))
+	for _, consumersPath := range module.zookeeperPaths {
+		module.groupList[consumersPath] = make(map[string]*topicList)
+	}
+}
+
 // This is a simple goroutine that will wait for an event on a watch channnel and then exit. It's here so that when
 // we set a watch that we don't care about (from an ExistsW on a node that already exists), we can drain it properly.
 func drainEventChannel(eventChan <-chan zk.Event) {
@@ -220,7 +267,7 @@ func (module *KafkaZkClient) waitForNodeToExist(zkPath string, logger *zap.Logge
 	return true
 }
 
-func (module *KafkaZkClient) watchGroupList(eventChan <-chan zk.Event) {
+func (module *KafkaZkClient) watchGroupList(consumersPath string, eventChan <-chan zk.Event) {
 	defer module.running.Done()
 
 	event := <-eventChan
@@ -231,21 +278,24 @@ func (module *KafkaZkClient) watchGroupList(eventChan <-chan zk.Event) {
 	}
 	module.Log.Debug("group list watch fired", zap.Int("event_type", int(event.Type)))
 	module.running.Add(1)
-	go module.resetGroupListWatchAndAdd(event.Type != zk.EventNodeChildrenChanged)
+	go module.resetGroupListWatchAndAdd(consumersPath, event.Type != zk.EventNodeChildrenChanged)
 }
 
-func (module *KafkaZkClient) resetGroupListWatchAndAdd(resetOnly bool) {
+func (module *KafkaZkClient) resetGroupListWatchAndAdd(consumersPath string, resetOnly bool) {
 	defer module.running.Done()
 
 	// Get the current group list and reset our watch
-	consumerGroups, _, groupListEventChan, err := module.zk.ChildrenW(module.zookeeperPath)
+	consumerGroups, _, groupListEventChan, err := module.zk.ChildrenW(consumersPath)
 	if err != nil {
 		// Can't read the consumers path. Bail for now
-		module.Log.Error("failed to list groups", zap.String("error", err.Error()))
+		module.Log.Error("failed to list groups",
+			zap.String("path", consumersPath),
+			zap.String("error", err.Error()),
+		)
 		return
 	}
 	module.running.Add(1)
-	go module.watchGroupList(groupListEventChan)
+	go module.watchGroupList(consumersPath, groupListEventChan)
 
 	if !resetOnly {
 		// Check for any new groups and create the watches for them
@@ -260,22 +310,23 @@ func (module *KafkaZkClient) resetGroupListWatchAndAdd(resetOnly bool) {
 				continue
 			}
 
-			if module.groupList[group] == nil {
-				module.groupList[group] = &topicList{
+			if module.groupList[consumersPath][group] == nil {
+				module.groupList[consumersPath][group] = &topicList{
 					topics: make(map[string]*partitionCount),
 					lock:   &sync.RWMutex{},
 				}
 				module.Log.Debug("add group",
+					zap.String("path", consumersPath),
 					zap.String("group", group),
 				)
 				module.running.Add(1)
-				go module.resetTopicListWatchAndAdd(group, false)
+				go module.resetTopicListWatchAndAdd(consumersPath, group, false)
 			}
 		}
 	}
 }
 
-func (module *KafkaZkClient) watchTopicList(group string, eventChan <-chan zk.Event) {
+func (module *KafkaZkClient) watchTopicList(consumersPath string, group string, eventChan <-chan zk.Event) {
 	defer module.running.Done()
 
 	event := <-eventChan
@@ -289,16 +340,16 @@ func (module *KafkaZkClient) watchTopicList(group string, eventChan <-chan zk.Ev
 		zap.Int("event_type", int(event.Type)),
 	)
 	module.running.Add(1)
-	go module.resetTopicListWatchAndAdd(group, event.Type != zk.EventNodeChildrenChanged)
+	go module.resetTopicListWatchAndAdd(consumersPath, group, event.Type != zk.EventNodeChildrenChanged)
 }
 
-func (module *KafkaZkClient) resetTopicListWatchAndAdd(group string, resetOnly bool) {
+func (module *KafkaZkClient) resetTopicListWatchAndAdd(consumersPath string, group string, resetOnly bool) {
 	defer module.running.Done()
 
 	// Wait for the offsets znode for this group to exist. We need to do this because the previous child watch
 	// fires on /consumers/(group) existing, but here we try to read /consumers/(group)/offsets (which might not exist
 	// yet)
-	zkPath := module.zookeeperPath + "/" + group + "/offsets"
+	zkPath := consumersPath + "/" + group + "/offsets"
 	logger := module.Log.With(zap.String("group", group))
 	if !module.waitForNodeToExist(zkPath, logger) {
 		// There was an error checking node existence, so we can't continue
@@ -312,30 +363,30 @@ func (module *KafkaZkClient) resetTopicListWatchAndAdd(group string, resetOnly b
 		return
 	}
 	module.running.Add(1)
-	go module.watchTopicList(group, topicListEventChan)
+	go module.watchTopicList(consumersPath, group, topicListEventChan)
 
 	if !resetOnly {
 		// Check for any new topics and create the watches for them
 		module.groupLock.RLock()
 		defer module.groupLock.RUnlock()
 
-		module.groupList[group].lock.Lock()
-		defer module.groupList[group].lock.Unlock()
+		module.groupList[consumersPath][group].lock.Lock()
+		defer module.groupList[consumersPath][group].lock.Unlock()
 		for _, topic := range groupTopics {
-			if module.groupList[group].topics[topic] == nil {
-				module.groupList[group].topics[topic] = &partitionCount{
+			if module.groupList[consumersPath][group].topics[topic] == nil {
+				module.groupList[consumersPath][group].topics[topic] = &partitionCount{
 					count: 0,
 					lock:  &sync.Mutex{},
 				}
 				logger.Debug("add topic", zap.String("topic", topic))
 				module.running.Add(1)
-				go module.resetPartitionListWatchAndAdd(group, topic, false)
+				go module.resetPartitionListWatchAndAdd(consumersPath, group, topic, false)
 			}
 		}
 	}
 }
 
-func (module *KafkaZkClient) watchPartitionList(group, topic string, eventChan <-chan zk.Event) {
+func (module *KafkaZkClient) watchPartitionList(consumersPath string, group, topic string, eventChan <-chan zk.Event) {
 	defer module.running.Done()
 
 	event := <-eventChan
@@ -353,14 +404,14 @@ func (module *KafkaZkClient) watchPartitionList(group, topic string, eventChan <
 		zap.Int("event_type", int(event.Type)),
 	)
 	module.running.Add(1)
-	go module.resetPartitionListWatchAndAdd(group, topic, event.Type != zk.EventNodeChildrenChanged)
+	go module.resetPartitionListWatchAndAdd(consumersPath, group, topic, event.Type != zk.EventNodeChildrenChanged)
 }
 
-func (module *KafkaZkClient) resetPartitionListWatchAndAdd(group, topic string, resetOnly bool) {
+func (module *KafkaZkClient) resetPartitionListWatchAndAdd(consumersPath string, group, topic string, resetOnly bool) {
 	defer module.running.Done()
 
 	// Get the current topic partition list and reset our watch
-	topicPartitions, _, partitionListEventChan, err := module.zk.ChildrenW(module.zookeeperPath + "/" + group + "/offsets/" + topic)
+	topicPartitions, _, partitionListEventChan, err := module.zk.ChildrenW(consumersPath + "/" + group + "/offsets/" + topic)
 	if err != nil {
 		// Can't read the partition list path. Bail for now
 		module.Log.Warn("failed to read partitions",
@@ -371,34 +422,34 @@ func (module *KafkaZkClient) resetPartitionListWatchAndAdd(group, topic string,
 		return
 	}
 	module.running.Add(1)
-	go module.watchPartitionList(group, topic, partitionListEventChan)
+	go module.watchPartitionList(consumersPath, group, topic, partitionListEventChan)
 
 	if !resetOnly {
 		// Check for any new partitions and create the watches for them
 		module.groupLock.RLock()
 		defer module.groupLock.RUnlock()
 
-		module.groupList[group].lock.RLock()
-		defer module.groupList[group].lock.RUnlock()
+		module.groupList[consumersPath][group].lock.RLock()
+		defer module.groupList[consumersPath][group].lock.RUnlock()
 
-		module.groupList[group].topics[topic].lock.Lock()
-		defer module.groupList[group].topics[topic].lock.Unlock()
-		if int32(len(topicPartitions)) >= module.groupList[group].topics[topic].count {
-			for i := module.groupList[group].topics[topic].count; i < int32(len(topicPartitions)); i++ {
+		module.groupList[consumersPath][group].topics[topic].lock.Lock()
+		defer module.groupList[consumersPath][group].topics[topic].lock.Unlock()
+		if int32(len(topicPartitions)) >= module.groupList[consumersPath][group].topics[topic].count {
+			for i := module.groupList[consumersPath][group].topics[topic].count; i < int32(len(topicPartitions)); i++ {
 				module.Log.Debug("add partition",
 					zap.String("group", group),
 					zap.String("topic", topic),
 					zap.Int32("partition", i),
 				)
 				module.running.Add(1)
-				module.resetOffsetWatchAndSend(group, topic, i, false)
+				module.resetOffsetWatchAndSend(consumersPath, group, topic, i, false)
 			}
-			module.groupList[group].topics[topic].count = int32(len(topicPartitions))
+			module.groupList[consumersPath][group].topics[topic].count = int32(len(topicPartitions))
 		}
 	}
 }
 
-func (module *KafkaZkClient) watchOffset(group, topic string, partition int32, eventChan <-chan zk.Event) {
+func (module *KafkaZkClient) watchOffset(consumersPath string, group, topic string, partition int32, eventChan <-chan zk.Event) {
 	defer module.running.Done()
 
 	event := <-eventChan
@@ -418,17 +469,17 @@ func (module *KafkaZkClient) watchOffset(group, topic string, partition int32, e
 		zap.Int("event_type", int(event.Type)),
 	)
 	module.running.Add(1)
-	go module.resetOffsetWatchAndSend(group, topic, partition, event.Type != zk.EventNodeDataChanged)
+	go module.resetOffsetWatchAndSend(consumersPath, group, topic, partition, event.Type != zk.EventNodeDataChanged)
 }
 
-func (module *KafkaZkClient) resetOffsetWatchAndSend(group, topic string, partition int32, resetOnly bool) {
+func (module *KafkaZkClient) resetOffsetWatchAndSend(consumersPath string, group, topic string, partition int32, resetOnly bool) {
 	defer module.running.Done()
 
 	// Get the current offset and reset our watch
-	offsetString, offsetStat, offsetEventChan, err := module.zk.GetW(module.zookeeperPath + "/" + group + "/offsets/" + topic + "/" + strconv.FormatInt(int64(partition), 10))
+	offsetString, offsetStat, offsetEventChan, err := module.zk.GetW(consumersPath + "/" + group + "/offsets/" + topic + "/" + strconv.FormatInt(int64(partition), 10))
 
 	// Get the current owner of the partition
-	consumerID, _, _, _ := module.zk.GetW(module.zookeeperPath + "/" + group + "/owners/" + topic + "/" + strconv.FormatInt(int64(partition), 10)) // nolint:dogsled
+	consumerID, _, _, _ := module.zk.GetW(consumersPath + "/" + group + "/owners/" + topic + "/" + strconv.FormatInt(int64(partition), 10)) // nolint:dogsled
 
 	if err != nil {
 		// Can't read the partition offset path. Bail for now
@@ -441,7 +492,7 @@ func (module *KafkaZkClient) resetOffsetWatchAndSend(group, topic string, partit
 		return
 	}
 	module.running.Add(1)
-	go module.watchOffset(group, topic, partition, offsetEventChan)
+	go module.watchOffset(consumersPath, group, topic, partition, offsetEventChan)
 
 	if !resetOnly {
 		offset, err := strconv.ParseInt(string(offsetString), 10, 64)
@@ -54,10 +54,41 @@ func TestKafkaZkClient_ImplementsModule(t *testing.T) {
 func TestKafkaZkClient_Configure(t *testing.T) {
 	module := fixtureKafkaZkModule()
 	module.Configure("test", "consumer.test")
-	assert.Equal(t, "/consumers", module.zookeeperPath, "Expected ZookeeperPath to get set to '/consumers', not %v", module.zookeeperPath)
+	assert.Equal(t, []string{"/consumers"}, module.zookeeperPaths, "Expected ZookeeperPaths to get set to '/consumers', not %v", module.zookeeperPaths)
 	assert.Equal(t, int(30), module.zookeeperTimeout, "Default ZookeeperTimeout value of 30 did not get set")
 }
 
+func TestKafkaZkClient_Configure_ZookeeperPaths(t *testing.T) {
+	module := fixtureKafkaZkModule()
+	viper.Set("consumer.test.zookeeper-path", "/ignored")
+	viper.Set("consumer.test.zookeeper-paths", []string{"/consumers", "/legacy/consumers"})
+	module.Configure("test", "consumer.test")
+	assert.Equal(t, []string{"/consumers", "/legacy/consumers"}, module.zookeeperPaths, "Expected ZookeeperPaths to be set from zookeeper-paths, not %v", module.zookeeperPaths)
+	assert.Lenf(t, module.groupList, 2, "Expected a group list for each path, not %v", len(module.groupList))
+}
+
+func TestKafkaZkClient_Configure_BadZookeeperPaths(t *testing.T) {
+	module := fixtureKafkaZkModule()
+	viper.Set("consumer.test.zookeeper-paths", []string{"/consumers", "legacy/consumers"})
+	assert.Panics(t, func() { module.Configure("test", "consumer.test") }, "The code did not panic")
+}
+
+func TestKafkaZkClient_Configure_Profiles(t *testing.T) {
+	module := fixtureKafkaZkModule()
+	viper.Set("tls.zktls.cafile", "/etc/ca.pem")
+	viper.Set("sasl.zkauth.username", "burrow")
+	viper.Set("consumer.test.zookeeper-tls", "zktls")
+	viper.Set("consumer.test.zookeeper-digest-auth", "zkauth")
+	assert.NotPanics(t, func() { module.Configure("test", "consumer.test") }, "The code panicked")
+	assert.NotNil(t, module.connectFunc, "Expected connectFunc to get set")
+}
+
+func TestKafkaZkClient_Configure_UnknownProfile(t *testing.T) {
+	module := fixtureKafkaZkModule()
+	viper.Set("consumer.test.zookeeper-digest-auth", "noauth")
+	assert.Panics(t, func() { module.Configure("test", "consumer.test") }, "The code did not panic")
+}
+
 func TestKafkaZkClient_Configure_BadRegexp(t *testing.T) {
 	module := fixtureModule()
 	viper.Set("consumer.test.group-allowlist", "[")
@@ -74,7 +105,7 @@ func TestKafkaZkClient_Start(t *testing.T) {
 	module.connectFunc = mockZookeeper.MockZookeeperConnect
 
 	watchEventChan := make(chan zk.Event)
-	mockZookeeper.On("ChildrenW", module.zookeeperPath).Return([]string{}, &zk.Stat{}, func() <-chan zk.Event { return watchEventChan }(), nil)
+	mockZookeeper.On("ChildrenW", "/consumers").Return([]string{}, &zk.Stat{}, func() <-chan zk.Event { return watchEventChan }(), nil)
 	mockZookeeper.On("Close").Return().Run(func(args mock.Arguments) {
 		watchEventChan <- zk.Event{Type: zk.EventNotWatching}
 		close(watchEventChan)
@@ -168,11 +199,11 @@ func TestKafkaZkClient_watchGroupList(t *testing.T) {
 	}()
 
 	module.running.Add(1)
-	module.watchGroupList(watchEventChan)
+	module.watchGroupList("/consumers", watchEventChan)
 	module.running.Wait()
 
 	mockZookeeper.AssertExpectations(t)
-	assert.Equalf(t, int32(1), module.groupList["testgroup"].topics["testtopic"].count, "Expected partition count to be 1, not %v", module.groupList["testgroup"].topics["testtopic"].count)
+	assert.Equalf(t, int32(1), module.groupList["/consumers"]["testgroup"].topics["testtopic"].count, "Expected partition count to be 1, not %v", module.groupList["/consumers"]["testgroup"].topics["testtopic"].count)
 }
 
 func TestKafkaZkClient_resetOffsetWatchAndSend_BadPath(t *testing.T) {
@@ -185,7 +216,7 @@ func TestKafkaZkClient_resetOffsetWatchAndSend_BadPath(t *testing.T) {
 	module.zk = &mockZookeeper
 
 	module.running.Add(1)
-	module.resetOffsetWatchAndSend("testgroup", "testtopic", 0, false)
+	module.resetOffsetWatchAndSend("/consumers", "testgroup", "testtopic", 0, false)
 	mockZookeeper.AssertExpectations(t)
 }
 
@@ -203,7 +234,7 @@ func TestKafkaZkClient_resetOffsetWatchAndSend_BadOffset(t *testing.T) {
 
 	// This will block if a storage request is sent, as nothing is watching that channel
 	module.running.Add(1)
-	module.resetOffsetWatchAndSend("testgroup", "testtopic", 0, false)
+	module.resetOffsetWatchAndSend("/consumers", "testgroup", "testtopic", 0, false)
 
 	// This should not block because the watcher would have been started
 	newWatchEventChan <- zk.Event{
@@ -224,7 +255,7 @@ func TestKafkaZkClient_resetPartitionListWatchAndAdd_BadPath(t *testing.T) {
 	module.zk = &mockZookeeper
 
 	module.running.Add(1)
-	module.resetPartitionListWatchAndAdd("testgroup", "testtopic", false)
+	module.resetPartitionListWatchAndAdd("/consumers", "testgroup", "testtopic", false)
 	mockZookeeper.AssertExpectations(t)
 }
 
@@ -245,7 +276,7 @@ func TestKafkaZkClient_resetTopicListWatchAndAdd_BadPath(t *testing.T) {
 		}
 	}()
 	module.running.Add(1)
-	module.resetTopicListWatchAndAdd("testgroup", false)
+	module.resetTopicListWatchAndAdd("/consumers", "testgroup", false)
 	mockZookeeper.AssertExpectations(t)
 }
 
@@ -258,7 +289,7 @@ func TestKafkaZkClient_resetGroupListWatchAndAdd_BadPath(t *testing.T) {
 	module.zk = &mockZookeeper
 
 	module.running.Add(1)
-	module.resetGroupListWatchAndAdd(false)
+	module.resetGroupListWatchAndAdd("/consumers", false)
 	mockZookeeper.AssertExpectations(t)
 }
 
@@ -275,7 +306,7 @@ func TestKafkaZkClient_resetGroupListWatchAndAdd_AllowList(t *testing.T) {
 	mockZookeeper.On("ChildrenW", "/consumers").Return([]string{"dropthisgroup"}, offsetStat, func() <-chan zk.Event { return newGroupChan }(), nil)
 
 	module.running.Add(1)
-	module.resetGroupListWatchAndAdd(false)
+	module.resetGroupListWatchAndAdd("/consumers", false)
 
 	newGroupChan <- zk.Event{
 		Type:  zk.EventNotWatching,
@@ -284,6 +315,43 @@ func TestKafkaZkClient_resetGroupListWatchAndAdd_AllowList(t *testing.T) {
 	}
 
 	mockZookeeper.AssertExpectations(t)
-	_, ok := module.groupList["dropthisgroup"]
+	_, ok := module.groupList["/consumers"]["dropthisgroup"]
 	assert.False(t, ok, "Expected group to be dropped due to allowlist")
 }
+
+func TestKafkaZkClient_resetOffsetWatchAndSend_ZookeeperPaths(t *testing.T) {
+	mockZookeeper := helpers.MockZookeeperClient{}
+
+	module := fixtureKafkaZkModule()
+	viper.Set("consumer.test.zookeeper-paths", []string{"/consumers", "/legacy/consumers"})
+	module.Configure("test", "consumer.test")
+	module.zk = &mockZookeeper
+
+	offsetStat := &zk.Stat{Mtime: 894859, Mzxid: 1234}
+	newWatchEventChan := make(chan zk.Event)
+	mockZookeeper.On("GetW", "/legacy/consumers/testgroup/offsets/testtopic/0").Return([]byte("81234"), offsetStat, func() <-chan zk.Event { return newWatchEventChan }(), nil)
+	mockZookeeper.On("GetW", "/legacy/consumers/testgroup/owners/testtopic/0").Return([]byte("testowner"), offsetStat, func() <-chan zk.Event { return newWatchEventChan }(), nil)
+
+	module.running.Add(1)
+	go module.resetOffsetWatchAndSend("/legacy/consumers", "testgroup", "testtopic", 0, false)
+
+	// Offsets from any of the paths are sent to storage for the same cluster and group
+	request := <-module.App.StorageChannel
+	assert.Equalf(t, protocol.StorageSetConsumerOffset, request.RequestType, "Expected request sent with type StorageSetConsumerOffset, not %v", request.RequestType)
+	assert.Equalf(t, "test", request.Cluster, "Expected request sent with cluster test, not %v", request.Cluster)
+	assert.Equalf(t, "testgroup", request.Group, "Expected request sent with Group testgroup, not %v", request.Group)
+	assert.Equalf(t, int64(81234), request.Offset, "Expected request sent with offset 81234, not %v", request.Offset)
+	assert.Equalf(t, int64(1234), request.Order, "Expected request sent with order 1234, not %v", request.Order)
+
+	request = <-module.App.StorageChannel
+	assert.Equalf(t, protocol.StorageSetConsumerOwner, request.RequestType, "Expected request sent with type StorageSetConsumerOwner, not %v", request.RequestType)
+	assert.Equalf(t, "testowner", request.Owner, "Expected request sent with owner testowner, not %v", request.Owner)
+
+	newWatchEventChan <- zk.Event{
+		Type:  zk.EventNotWatching,
+		State: zk.StateConnected,
+		Path:  "/legacy/consumers/testgroup/offsets/testtopic/0",
+	}
+	module.running.Wait()
+	mockZookeeper.AssertExpectations(t)
+}
@@ -15,9 +15,11 @@ import (
 	"time"
 
 	"github.com/karrick/goswarm"
+	"github.com/spf13/cast"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 
+	"github.com/linkedin/Burrow/core/internal/httpserver"
 	"github.com/linkedin/Burrow/core/protocol"
 )
 
@@ -36,10 +38,27 @@ type CachingEvaluator struct {
 	expireCache     int
 	minimumComplete float32
 	allowedLag      uint64
+	staleCommit     int64
+	rebalanceWindow int64
+	maxLag          uint64
+	maxLagStatus    protocol.StatusConstant
+	maxLagClusters  map[string]uint64
+	maxLagGroups    map[string]uint64
+	maxTimeLag      int64
+	topicStatus     bool
+
+	offsetResetWindow    int64
+	offsetResetThreshold int64
+	offsetResetSuppress  bool
 
 	RequestChannel chan *protocol.EvaluatorRequest
 	running        sync.WaitGroup
 	cache          *goswarm.Simple
+
+	// The status of the last evaluation of each group, keyed by cluster and group, to publish the changes to the
+	// StatusFeed and to reply to summary requests
+	lastStatus     map[string]protocol.GroupStatusSummary
+	lastStatusLock sync.Mutex
 }
 
 type cacheError struct {
@@ -52,23 +71,75 @@ func (e *cacheError) Error() string {
 }
 
 // Configure validates the configuration for the module, creates a channel to receive requests on, and sets up the
-// cache. If no expiration time for cache entries is set, a default value of 10 seconds is used. If there is any problem
-// starting the goswarm cache, this func panics.
+// cache. If no expiration time for cache entries is set, a default value of 10 seconds is used. If stale-commit is set
+// to a number of seconds, partitions with no commit in that time are marked as stalled even when they have no lag,
+// unless the partition has had no new messages since the last commit. A group is reported as rebalancing if its
+// generation changed within the last rebalance-window seconds (default 300).
+//
+// The max-lag setting enables an absolute lag threshold: a partition with a current lag above it is marked with
+// max-lag-status (either "warning", the default, or "error"), regardless of the sliding window rules. The threshold can
+// be set for a cluster in the max-lag-clusters table, or for a group in the max-lag-groups table, which takes precedence.
+// Names in these tables are matched without regard to case. If the cluster module fetches the time lag of partitions,
+// max-time-lag can be set to a number of seconds, and a partition with a time lag above it is marked with max-lag-status
+// in the same way.
+//
+// If offset-reset-window is set to a number of seconds, a partition whose committed offset moved backwards by at least
+// offset-reset-threshold offsets (default 1) within that window is marked as having had its offsets reset, such as by
+// an operator using kafka-consumer-groups --reset-offsets. If offset-reset-suppress is true, those partitions are
+// reported as OK until the window passes, rather than alerting on the lag that the reset caused. If any of these
+// settings are invalid, or if there is any problem starting the goswarm cache, this func panics.
+//
+// If topic-status is true, the status of each topic that a group consumes is also calculated from its partitions, in the
+// same way as the group status, so that a stalled topic in a group that consumes many topics can be seen on its own.
+//
+// Each time a group is evaluated with a different status than its last evaluation, the change is published to the
+// StatusFeed in the application context. The last status of each group is also kept to answer summary requests, which
+// do not evaluate any group.
 func (module *CachingEvaluator) Configure(name, configRoot string) {
 	module.Log.Info("configuring")
 
 	module.name = name
 	module.RequestChannel = make(chan *protocol.EvaluatorRequest)
 	module.running = sync.WaitGroup{}
+	module.lastStatus = make(map[string]protocol.GroupStatusSummary)
 
 	// Set defaults for configs if needed
 	viper.SetDefault(configRoot+".expire-cache", 10)
 	viper.SetDefault(configRoot+".allowed-lag", 0)
+	viper.SetDefault(configRoot+".stale-commit", 0)
+	viper.SetDefault(configRoot+".rebalance-window", 300)
 	module.expireCache = viper.GetInt(configRoot + ".expire-cache")
 	module.minimumComplete = float32(viper.GetFloat64(configRoot + ".minimum-complete"))
 	module.allowedLag = viper.GetUint64(configRoot + ".allowed-lag")
+	module.staleCommit = viper.GetInt64(configRoot + ".stale-commit")
+	module.rebalanceWindow = viper.GetInt64(configRoot + ".rebalance-window")
+
+	viper.SetDefault(configRoot+".offset-reset-threshold", 1)
+	module.offsetResetWindow = viper.GetInt64(configRoot + ".offset-reset-window")
+	module.offsetResetThreshold = viper.GetInt64(configRoot + ".offset-reset-threshold")
+	module.offsetResetSuppress = viper.GetBool(configRoot + ".offset-reset-suppress")
+	if (module.offsetResetWindow < 0) || (module.offsetResetThreshold < 1) {
+		panic("Evaluator '" + name + "' has an invalid offset-reset-window or offset-reset-threshold")
+	}
 	cacheExpire := time.Duration(module.expireCache) * time.Second
 
+	module.maxLag = viper.GetUint64(configRoot + ".max-lag")
+	switch strings.ToLower(viper.GetString(configRoot + ".max-lag-status")) {
+	case "", "warning":
+		module.maxLagStatus = protocol.StatusWarning
+	case "error":
+		module.maxLagStatus = protocol.StatusError
+	default:
+		panic("Evaluator '" + name + "' has an invalid max-lag-status (must be warning or error)")
+	}
+	module.maxLagClusters = getMaxLagThresholds(name, configRoot+".max-lag-clusters")
+	module.maxLagGroups = getMaxLagThresholds(name, configRoot+".max-lag-groups")
+	module.maxTimeLag = viper.GetInt64(configRoot + ".max-time-lag")
+	if module.maxTimeLag < 0 {
+		panic("Evaluator '" + name + "' has an invalid max-time-lag")
+	}
+	module.topicStatus = viper.GetBool(configRoot + ".topic-status")
+
 	newCache, err := goswarm.NewSimple(&goswarm.Config{
 		GoodExpiryDuration: cacheExpire,
 		BadExpiryDuration:  cacheExpire,
@@ -81,6 +152,18 @@ func (module *CachingEvaluator) Configure(name, configRoot string) {
 	module.cache = newCache
 }
 
+func getMaxLagThresholds(name, configKey string) map[string]uint64 {
+	thresholds := make(map[string]uint64)
+	for key, value := range viper.GetStringMap(configKey) {
+		threshold, err := cast.ToUint64E(value)
+		if err != nil {
+			panic("Evaluator '" + name + "' has an invalid threshold for '" + key + "' in " + configKey)
+		}
+		thresholds[strings.ToLower(key)] = threshold
+	}
+	return thresholds
+}
+
 // GetCommunicationChannel returns the RequestChannel that has been setup for this module.
 func (module *CachingEvaluator) GetCommunicationChannel() chan *protocol.EvaluatorRequest {
 	return module.RequestChannel
@@ -108,7 +191,14 @@ func (module *CachingEvaluator) mainLoop() {
 	defer module.running.Done()
 
 	for request := range module.RequestChannel {
-		if request != nil {
+		if request == nil {
+			continue
+		}
+		if request.ParametersReply != nil {
+			go module.getParameters(request)
+		} else if request.SummaryReply != nil {
+			go module.getSummary(request)
+		} else {
 			go module.getConsumerStatus(request)
 		}
 	}
@@ -120,9 +210,20 @@ func (module *CachingEvaluator) getConsumerStatus(request *protocol.EvaluatorReq
 		zap.String("cluster", request.Cluster),
 		zap.String("consumer", request.Group),
 		zap.Bool("showall", request.ShowAll),
+		zap.Bool("refresh", request.Refresh),
 	)
 
-	result, err := module.cache.Query(request.Cluster + " " + request.Group)
+	var result interface{}
+	var err error
+	if request.Refresh {
+		// Evaluate now, rather than waiting for the cached status to expire, and cache the new status
+		result, err = module.evaluateConsumerStatus(request.Cluster + " " + request.Group)
+		if err == nil {
+			module.cache.Store(request.Cluster+" "+request.Group, result)
+		}
+	} else {
+		result, err = module.cache.Query(request.Cluster + " " + request.Group)
+	}
 	if err != nil {
 		requestLogger.Info(err.Error())
 
@@ -150,7 +251,14 @@ func (module *CachingEvaluator) getConsumerStatus(request *protocol.EvaluatorReq
 				Complete:        cachedStatus.Complete,
 				Maxlag:          cachedStatus.Maxlag,
 				TotalLag:        cachedStatus.TotalLag,
+				MaxTimeLag:      cachedStatus.MaxTimeLag,
 				TotalPartitions: cachedStatus.TotalPartitions,
+				Rebalancing:     cachedStatus.Rebalancing,
+				Rebalances:      cachedStatus.Rebalances,
+				Active:          cachedStatus.Active,
+				MemberCount:     cachedStatus.MemberCount,
+				OffsetReset:     cachedStatus.OffsetReset,
+				Topics:          cachedStatus.Topics,
 				Partitions:      make([]*protocol.PartitionStatus, cachedStatus.TotalPartitions),
 			}
 
@@ -179,6 +287,10 @@ func (module *CachingEvaluator) evaluateConsumerStatus(clusterAndConsumer string
 	}
 	cluster := parts[0]
 	consumer := parts[1]
+	start := time.Now()
+	defer func() {
+		httpserver.ObserveEvaluationDuration(cluster, time.Since(start))
+	}()
 
 	// Fetch all the consumer offset and lag information from storage
 	storageRequest := &protocol.StorageRequest{
@@ -197,6 +309,11 @@ func (module *CachingEvaluator) evaluateConsumerStatus(clusterAndConsumer string
 			zap.String("consumer", consumer),
 			zap.String("status", protocol.StatusNotFound.String()),
 		)
+		module.publishStatusChange(clusterAndConsumer, &protocol.ConsumerGroupStatus{
+			Cluster: cluster,
+			Group:   consumer,
+			Status:  protocol.StatusNotFound,
+		})
 		return nil, &cacheError{StatusCode: 404, Reason: "cluster or consumer not found"}
 	}
 
@@ -223,9 +340,17 @@ func (module *CachingEvaluator) evaluateConsumerStatus(clusterAndConsumer string
 
 	count := 0
 	completePartitions := 0
+	maxLag := module.getMaxLag(cluster, consumer)
+	timeNow := time.Now().Unix()
 	for topic, partitions := range topics {
 		for partitionID, partition := range partitions {
-			partitionStatus := evaluatePartitionStatus(partition, module.minimumComplete, module.allowedLag)
+			partitionStatus := evaluatePartitionStatus(partition, module.minimumComplete, module.allowedLag, module.staleCommit)
+			applyMaxLag(partitionStatus, maxLag, module.maxLagStatus)
+			applyMaxTimeLag(partitionStatus, module.maxTimeLag, module.maxLagStatus)
+			module.applyOffsetReset(partitionStatus, partition.Offsets, timeNow)
+			if partitionStatus.OffsetReset {
+				status.OffsetReset = true
+			}
 			partitionStatus.Topic = topic
 			partitionStatus.Partition = int32(partitionID)
 			partitionStatus.Owner = partition.Owner
@@ -240,6 +365,9 @@ func (module *CachingEvaluator) evaluateConsumerStatus(clusterAndConsumer string
 				}
 			}
 
+			if partitionStatus.TimeLag > status.MaxTimeLag {
+				status.MaxTimeLag = partitionStatus.TimeLag
+			}
 			if (status.Maxlag == nil) || (partitionStatus.CurrentLag > status.Maxlag.CurrentLag) {
 				status.Maxlag = partitionStatus
 			}
@@ -251,6 +379,12 @@ func (module *CachingEvaluator) evaluateConsumerStatus(clusterAndConsumer string
 		}
 	}
 
+	module.evaluateRebalances(status)
+	module.evaluateMembers(status)
+	if module.topicStatus {
+		status.Topics = evaluateTopicStatus(status.Partitions)
+	}
+
 	// Calculate completeness as a percentage of the number of partitions that are complete
 	if status.TotalPartitions > 0 {
 		status.Complete = float32(completePartitions) / float32(status.TotalPartitions)
@@ -266,13 +400,255 @@ func (module *CachingEvaluator) evaluateConsumerStatus(clusterAndConsumer string
 		zap.Uint64("total_lag", status.TotalLag),
 		zap.Int("total_partitions", status.TotalPartitions),
 	)
+	module.publishStatusChange(clusterAndConsumer, status)
 	return status, nil
 }
 
-func evaluatePartitionStatus(partition *protocol.ConsumerPartition, minimumComplete float32, allowedLag uint64) *protocol.PartitionStatus {
+// publishStatusChange records the status of the group, and sends a change to the StatusFeed if it is not the same as
+// it was for the last evaluation. Groups that are not found are forgotten, so that the status of groups that have been
+// removed is not kept forever.
+func (module *CachingEvaluator) publishStatusChange(clusterAndConsumer string, status *protocol.ConsumerGroupStatus) {
+	module.lastStatusLock.Lock()
+	previous := protocol.StatusNotFound
+	if last, ok := module.lastStatus[clusterAndConsumer]; ok {
+		previous = last.Status
+	}
+	if status.Status == protocol.StatusNotFound {
+		delete(module.lastStatus, clusterAndConsumer)
+	} else {
+		summary := protocol.GroupStatusSummary{Status: status.Status}
+		for _, partition := range status.Partitions {
+			if partition.Status == protocol.StatusStall {
+				summary.Stalled = true
+				break
+			}
+		}
+		module.lastStatus[clusterAndConsumer] = summary
+	}
+	module.lastStatusLock.Unlock()
+
+	if previous == status.Status {
+		return
+	}
+	skipped := module.App.StatusFeed.Publish(&protocol.ConsumerStatusChange{
+		Cluster:        status.Cluster,
+		Group:          status.Group,
+		Status:         status.Status,
+		PreviousStatus: previous,
+		Complete:       status.Complete,
+		TotalLag:       status.TotalLag,
+		Maxlag:         status.Maxlag,
+		Timestamp:      time.Now().UnixNano() / int64(time.Millisecond),
+	})
+	if skipped > 0 {
+		module.Log.Warn("status change not sent to slow subscribers",
+			zap.String("cluster", status.Cluster),
+			zap.String("consumer", status.Group),
+			zap.Int("subscribers", skipped),
+		)
+	}
+}
+
+// getMaxLag returns the absolute lag threshold for the group, which is the group setting if there is one, then the
+// cluster setting, then the module default. Zero means that there is no threshold.
+func (module *CachingEvaluator) getMaxLag(cluster, group string) uint64 {
+	threshold, _ := module.resolveMaxLag(cluster, group)
+	return threshold
+}
+
+// resolveMaxLag returns the absolute lag threshold for the group, as getMaxLag does, as well as which setting it came
+// from: "group", "cluster", or "default"
+func (module *CachingEvaluator) resolveMaxLag(cluster, group string) (uint64, string) {
+	if threshold, ok := module.maxLagGroups[strings.ToLower(group)]; ok {
+		return threshold, "group"
+	}
+	if threshold, ok := module.maxLagClusters[strings.ToLower(cluster)]; ok {
+		return threshold, "cluster"
+	}
+	return module.maxLag, "default"
+}
+
+// getSummary replies to the request with the result of the last evaluation of each group in the cluster
+func (module *CachingEvaluator) getSummary(request *protocol.EvaluatorRequest) {
+	prefix := request.Cluster + " "
+	summaries := make(map[string]*protocol.GroupStatusSummary)
+
+	module.lastStatusLock.Lock()
+	for clusterAndConsumer, last := range module.lastStatus {
+		if group, ok := strings.CutPrefix(clusterAndConsumer, prefix); ok {
+			summary := last
+			summaries[group] = &summary
+		}
+	}
+	module.lastStatusLock.Unlock()
+
+	request.SummaryReply <- summaries
+}
+
+// getParameters replies to the request with the parameters that are used to evaluate the group in the cluster
+func (module *CachingEvaluator) getParameters(request *protocol.EvaluatorRequest) {
+	maxLag, maxLagSource := module.resolveMaxLag(request.Cluster, request.Group)
+	request.ParametersReply <- &protocol.EvaluatorParameters{
+		Cluster:              request.Cluster,
+		Group:                request.Group,
+		ExpireCache:          module.expireCache,
+		MinimumComplete:      module.minimumComplete,
+		AllowedLag:           module.allowedLag,
+		StaleCommit:          module.staleCommit,
+		RebalanceWindow:      module.rebalanceWindow,
+		MaxLag:               maxLag,
+		MaxLagStatus:         module.maxLagStatus,
+		MaxLagSource:         maxLagSource,
+		MaxTimeLag:           module.maxTimeLag,
+		OffsetResetWindow:    module.offsetResetWindow,
+		OffsetResetThreshold: module.offsetResetThreshold,
+		OffsetResetSuppress:  module.offsetResetSuppress,
+		TopicStatus:          module.topicStatus,
+	}
+}
+
+// applyMaxLag raises the partition status to maxLagStatus if the current lag is over the threshold. A worse status
+// from the sliding window rules is kept.
+func applyMaxLag(status *protocol.PartitionStatus, maxLag uint64, maxLagStatus protocol.StatusConstant) {
+	if (maxLag == 0) || (status.CurrentLag <= maxLag) {
+		return
+	}
+	if status.Status < maxLagStatus {
+		status.Status = maxLagStatus
+		status.Rule = "max-lag"
+	}
+}
+
+// applyMaxTimeLag raises the partition status to maxLagStatus if the time lag is over the threshold, which is in
+// seconds. As with applyMaxLag, a worse status is kept.
+func applyMaxTimeLag(status *protocol.PartitionStatus, maxTimeLag int64, maxLagStatus protocol.StatusConstant) {
+	if (maxTimeLag == 0) || (status.TimeLag <= maxTimeLag*1000) {
+		return
+	}
+	if status.Status < maxLagStatus {
+		status.Status = maxLagStatus
+		status.Rule = "max-time-lag"
+	}
+}
+
+// applyOffsetReset marks the partition if its offsets were reset within the offset reset window. If resets are
+// suppressed, the status of the partition is set to OK, as the lag (or rewind) is the expected result of the reset.
+func (module *CachingEvaluator) applyOffsetReset(status *protocol.PartitionStatus, offsets []*protocol.ConsumerOffset, timeNow int64) {
+	if module.offsetResetWindow == 0 {
+		return
+	}
+	resetTime := findOffsetReset(offsets, module.offsetResetThreshold)
+	if (resetTime == 0) || (resetTime < (timeNow-module.offsetResetWindow)*1000) {
+		return
+	}
+
+	status.OffsetReset = true
+	if module.offsetResetSuppress {
+		status.Status = protocol.StatusOK
+		status.Rule = ""
+	}
+}
+
+// findOffsetReset returns the timestamp of the most recent commit that moved the offset backwards by at least
+// threshold offsets from the commit before it, or zero if there is no such commit in the offsets.
+func findOffsetReset(offsets []*protocol.ConsumerOffset, threshold int64) int64 {
+	for i := len(offsets) - 1; i > 0; i-- {
+		if (offsets[i] == nil) || (offsets[i-1] == nil) {
+			continue
+		}
+		if offsets[i-1].Offset-offsets[i].Offset >= threshold {
+			return offsets[i].Timestamp
+		}
+	}
+	return 0
+}
+
+// evaluateRebalances fills in the rebalance information for the group status from the generation history in storage.
+// If the group metadata has not been seen, the group is not considered to be rebalancing.
+func (module *CachingEvaluator) evaluateRebalances(status *protocol.ConsumerGroupStatus) {
+	storageRequest := &protocol.StorageRequest{
+		RequestType: protocol.StorageFetchConsumerGeneration,
+		Cluster:     status.Cluster,
+		Group:       status.Group,
+		Reply:       make(chan interface{}),
+	}
+	module.App.StorageChannel <- storageRequest
+	response := <-storageRequest.Reply
+	if response == nil {
+		return
+	}
+
+	generation := response.(*protocol.ConsumerGeneration)
+	status.Rebalances = generation.Rebalances
+	status.Rebalancing = (generation.Rebalances > 0) && (generation.Timestamp > (time.Now().Unix()-module.rebalanceWindow)*1000)
+}
+
+// evaluateMembers fills in whether the group is active, and the number of members, from the last time the group was
+// described. If the group has not been described, it is not considered to be active.
+func (module *CachingEvaluator) evaluateMembers(status *protocol.ConsumerGroupStatus) {
+	storageRequest := &protocol.StorageRequest{
+		RequestType: protocol.StorageFetchConsumerMembers,
+		Cluster:     status.Cluster,
+		Group:       status.Group,
+		Reply:       make(chan interface{}),
+	}
+	module.App.StorageChannel <- storageRequest
+	response := <-storageRequest.Reply
+	if response == nil {
+		return
+	}
+
+	members := response.(*protocol.ConsumerMembers)
+	status.MemberCount = members.MemberCount
+	status.Active = members.MemberCount > 0
+}
+
+// evaluateTopicStatus groups the partition statuses by topic, and calculates the status of each topic the same way as
+// the status of the group
+func evaluateTopicStatus(partitions []*protocol.PartitionStatus) map[string]*protocol.TopicStatus {
+	topics := make(map[string]*protocol.TopicStatus)
+	completePartitions := make(map[string]int)
+	for _, partition := range partitions {
+		topicStatus, ok := topics[partition.Topic]
+		if !ok {
+			topicStatus = &protocol.TopicStatus{Status: protocol.StatusOK}
+			topics[partition.Topic] = topicStatus
+		}
+
+		topicStatus.TotalPartitions++
+		topicStatus.TotalLag += partition.CurrentLag
+		if partition.Status > topicStatus.Status {
+			if partition.Status > protocol.StatusError {
+				topicStatus.Status = protocol.StatusError
+			} else {
+				topicStatus.Status = partition.Status
+			}
+		}
+		if partition.TimeLag > topicStatus.MaxTimeLag {
+			topicStatus.MaxTimeLag = partition.TimeLag
+		}
+		if (topicStatus.Maxlag == nil) || (partition.CurrentLag > topicStatus.Maxlag.CurrentLag) {
+			topicStatus.Maxlag = partition
+		}
+		if partition.Complete == 1.0 {
+			completePartitions[partition.Topic]++
+		}
+	}
+
+	for topic, topicStatus := range topics {
+		topicStatus.Complete = float32(completePartitions[topic]) / float32(topicStatus.TotalPartitions)
+	}
+	return topics
+}
+
+func evaluatePartitionStatus(partition *protocol.ConsumerPartition, minimumComplete float32, allowedLag uint64, staleCommit int64) *protocol.PartitionStatus {
 	status := &protocol.PartitionStatus{
 		Status:     protocol.StatusOK,
 		CurrentLag: partition.CurrentLag,
+		TimeLag:    partition.TimeLag,
+
+		LastBrokerOffsetTimestamp: partition.LastBrokerOffsetTimestamp,
+		BelowLogStart:             partition.BelowLogStart,
 	}
 
 	// If there are no offsets, we can't do anything
@@ -306,13 +682,16 @@ func evaluatePartitionStatus(partition *protocol.ConsumerPartition, minimumCompl
 
 	// If the partition does not meet the completeness threshold, just return it as OK
 	if status.Complete >= minimumComplete {
-		status.Status = calculatePartitionStatus(offsets, partition.BrokerOffsets, partition.CurrentLag, time.Now().Unix(), allowedLag)
+		status.Status = calculatePartitionStatus(offsets, partition.BrokerOffsets, partition.CurrentLag, time.Now().Unix(), allowedLag, staleCommit)
+		if status.Status != protocol.StatusOK {
+			status.Rule = "sliding-window"
+		}
 	}
 
 	return status
 }
 
-func calculatePartitionStatus(offsets []*protocol.ConsumerOffset, brokerOffsets []int64, currentLag uint64, timeNow int64, allowedLag uint64) protocol.StatusConstant {
+func calculatePartitionStatus(offsets []*protocol.ConsumerOffset, brokerOffsets []int64, currentLag uint64, timeNow int64, allowedLag uint64, staleCommit int64) protocol.StatusConstant {
 	// If the current lag is zero, the partition is never in error
 	if currentLag > allowedLag {
 		// Check if the partition is stopped first, as this is a problem even if the consumer had zero lag at some
@@ -349,6 +728,14 @@ func calculatePartitionStatus(offsets []*protocol.ConsumerOffset, brokerOffsets
 			}
 		}
 	}
+
+	// A consumer that has stopped committing looks healthy if the topic is idle, as its lag stays flat. This is only
+	// checked when no other rule applies, so it does not hide a more specific status. A partition that has no new
+	// messages since the last commit has nothing to consume, so it is never stale, however old the commit is. This is
+	// common on topics where only a few partitions get traffic, as consumers do not commit for the idle partitions.
+	if checkIfCommitStale(offsets, timeNow, staleCommit) && (!checkIfPartitionIdle(offsets, brokerOffsets)) {
+		return protocol.StatusStall
+	}
 	return protocol.StatusOK
 }
 
@@ -424,6 +811,27 @@ func checkIfLagNotDecreasing(offsets []*protocol.ConsumerOffset) bool {
 	return true
 }
 
+// Rule 6 - If stale-commit is set and the most recent commit is older than that many seconds, the consumer has stopped
+//
+//	committing (stalled), regardless of lag
+func checkIfCommitStale(offsets []*protocol.ConsumerOffset, timeNow, staleCommit int64) bool {
+	if staleCommit <= 0 {
+		return false
+	}
+	lastTimestamp := offsets[len(offsets)-1].Timestamp
+	return ((timeNow * 1000) - lastTimestamp) > (staleCommit * 1000)
+}
+
+// Rule 7 - If the newest broker offset is the most recent committed offset, there are no messages for the consumer
+//
+//	to consume from the partition (it is idle)
+func checkIfPartitionIdle(offsets []*protocol.ConsumerOffset, brokerOffsets []int64) bool {
+	if len(brokerOffsets) == 0 {
+		return false
+	}
+	return brokerOffsets[len(brokerOffsets)-1] == offsets[len(offsets)-1].Offset
+}
+
 // Using the most recent committed offset, return true if there was zero lag at some point in the stored broker
 // LEO offsets. This has the effect of returning true if the consumer was up to date on this partition in recent
 // (minutes) history, so it can be used to delay alerting for a short period of time.
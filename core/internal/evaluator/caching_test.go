@@ -75,6 +75,73 @@ func TestCachingEvaluator_Configure_DefaultExpireCache(t *testing.T) {
 	storageCoordinator.Stop()
 }
 
+func TestCachingEvaluator_Configure_MaxLag(t *testing.T) {
+	storageCoordinator, module := fixtureModule()
+	viper.Set("evaluator.test.max-lag", 1000)
+	viper.Set("evaluator.test.max-lag-status", "error")
+	viper.Set("evaluator.test.max-lag-clusters", map[string]interface{}{"testcluster": 2000})
+	viper.Set("evaluator.test.max-lag-groups", map[string]interface{}{"TestGroup": "3000"})
+
+	module.Configure("test", "evaluator.test")
+	assert.Equal(t, protocol.StatusError, module.maxLagStatus, "Expected max-lag-status to be error")
+	assert.Equal(t, uint64(3000), module.getMaxLag("testcluster", "testgroup"), "Expected the group threshold to take precedence")
+	assert.Equal(t, uint64(2000), module.getMaxLag("testcluster", "othergroup"), "Expected the cluster threshold to be used")
+	assert.Equal(t, uint64(1000), module.getMaxLag("othercluster", "othergroup"), "Expected the default threshold to be used")
+	storageCoordinator.Stop()
+}
+
+func TestCachingEvaluator_getParameters(t *testing.T) {
+	storageCoordinator, module := fixtureModule()
+	viper.Set("evaluator.test.max-lag", 1000)
+	viper.Set("evaluator.test.max-lag-clusters", map[string]interface{}{"testcluster": 2000})
+	viper.Set("evaluator.test.max-lag-groups", map[string]interface{}{"testgroup": 3000})
+	viper.Set("evaluator.test.stale-commit", 600)
+	module.Configure("test", "evaluator.test")
+	module.Start()
+	defer stopTestCluster(storageCoordinator, module)
+
+	parameterTests := []struct {
+		cluster string
+		group   string
+		maxLag  uint64
+		source  string
+	}{
+		{"testcluster", "testgroup", 3000, "group"},
+		{"testcluster", "", 2000, "cluster"},
+		{"othercluster", "othergroup", 1000, "default"},
+	}
+	for i, testSet := range parameterTests {
+		request := &protocol.EvaluatorRequest{
+			Cluster:         testSet.cluster,
+			Group:           testSet.group,
+			Reply:           make(chan *protocol.ConsumerGroupStatus),
+			ParametersReply: make(chan *protocol.EvaluatorParameters),
+		}
+		module.GetCommunicationChannel() <- request
+		parameters := <-request.ParametersReply
+
+		assert.Equalf(t, testSet.cluster, parameters.Cluster, "TEST %v: Expected Cluster to be %v, not %v", i, testSet.cluster, parameters.Cluster)
+		assert.Equalf(t, testSet.group, parameters.Group, "TEST %v: Expected Group to be %v, not %v", i, testSet.group, parameters.Group)
+		assert.Equalf(t, testSet.maxLag, parameters.MaxLag, "TEST %v: Expected MaxLag to be %v, not %v", i, testSet.maxLag, parameters.MaxLag)
+		assert.Equalf(t, testSet.source, parameters.MaxLagSource, "TEST %v: Expected MaxLagSource to be %v, not %v", i, testSet.source, parameters.MaxLagSource)
+		assert.Equalf(t, 30, parameters.ExpireCache, "TEST %v: Expected ExpireCache to be 30, not %v", i, parameters.ExpireCache)
+		assert.Equalf(t, int64(600), parameters.StaleCommit, "TEST %v: Expected StaleCommit to be 600, not %v", i, parameters.StaleCommit)
+		assert.Equalf(t, int64(300), parameters.RebalanceWindow, "TEST %v: Expected the default RebalanceWindow, not %v", i, parameters.RebalanceWindow)
+		assert.Lenf(t, request.Reply, 0, "TEST %v: Expected no status to be sent", i)
+	}
+}
+
+func TestCachingEvaluator_Configure_BadMaxLag(t *testing.T) {
+	storageCoordinator, module := fixtureModule()
+	defer storageCoordinator.Stop()
+	viper.Set("evaluator.test.max-lag-status", "critical")
+	assert.Panics(t, func() { module.Configure("test", "evaluator.test") }, "The code did not panic")
+
+	viper.Set("evaluator.test.max-lag-status", "warning")
+	viper.Set("evaluator.test.max-lag-groups", map[string]interface{}{"testgroup": "lots"})
+	assert.Panics(t, func() { module.Configure("test", "evaluator.test") }, "The code did not panic")
+}
+
 // Also tests Stop
 func TestCachingEvaluator_Start(t *testing.T) {
 	storageCoordinator, module := startWithTestCluster()
@@ -113,10 +180,442 @@ func TestCachingEvaluator_SingleRequest_NoShowAll(t *testing.T) {
 	assert.Equalf(t, "testcluster", response.Cluster, "Expected cluster to be testcluster, not %v", response.Cluster)
 	assert.Equalf(t, "testgroup", response.Group, "Expected group to be testgroup, not %v", response.Group)
 	assert.Lenf(t, response.Partitions, 0, "Expected 0 partition status objects, not %v", len(response.Partitions))
+	assert.False(t, response.Rebalancing, "Expected rebalancing to be false")
+	assert.Equalf(t, uint64(0), response.Rebalances, "Expected rebalances to be 0, not %v", response.Rebalances)
+	assert.False(t, response.Active, "Expected active to be false for a group that has not been described")
+
+	stopTestCluster(storageCoordinator, module)
+}
+
+func TestCachingEvaluator_SingleRequest_Members(t *testing.T) {
+	storageCoordinator, module := startWithTestCluster()
+
+	storageCoordinator.App.StorageChannel <- &protocol.StorageRequest{
+		RequestType: protocol.StorageSetConsumerMembers,
+		Cluster:     "testcluster",
+		Group:       "testgroup",
+		MemberCount: 3,
+		Timestamp:   time.Now().Unix() * 1000,
+	}
+
+	request := &protocol.EvaluatorRequest{
+		Reply:   make(chan *protocol.ConsumerGroupStatus),
+		Cluster: "testcluster",
+		Group:   "testgroup",
+		ShowAll: false,
+	}
+	module.GetCommunicationChannel() <- request
+	response := <-request.Reply
+
+	assert.True(t, response.Active, "Expected active to be true")
+	assert.Equalf(t, int32(3), response.MemberCount, "Expected member count to be 3, not %v", response.MemberCount)
+
+	stopTestCluster(storageCoordinator, module)
+}
+
+func TestCachingEvaluator_SingleRequest_Rebalancing(t *testing.T) {
+	storageCoordinator, module := startWithTestCluster()
+
+	// Requests for the same group are processed in order, so these are stored before the evaluation
+	now := time.Now().Unix() * 1000
+	for generation := int32(1); generation <= 3; generation++ {
+		storageCoordinator.App.StorageChannel <- &protocol.StorageRequest{
+			RequestType: protocol.StorageSetConsumerGeneration,
+			Cluster:     "testcluster",
+			Group:       "testgroup",
+			Generation:  generation,
+			Timestamp:   now,
+		}
+	}
+
+	request := &protocol.EvaluatorRequest{
+		Reply:   make(chan *protocol.ConsumerGroupStatus),
+		Cluster: "testcluster",
+		Group:   "testgroup",
+		ShowAll: false,
+	}
+	module.GetCommunicationChannel() <- request
+	response := <-request.Reply
+
+	assert.Equalf(t, protocol.StatusOK, response.Status, "Expected status to be OK, not %v", response.Status.String())
+	assert.True(t, response.Rebalancing, "Expected rebalancing to be true")
+	assert.Equalf(t, uint64(2), response.Rebalances, "Expected rebalances to be 2, not %v", response.Rebalances)
+
+	stopTestCluster(storageCoordinator, module)
+}
+
+func TestCachingEvaluator_StatusFeed(t *testing.T) {
+	storageCoordinator, module := fixtureModule()
+	module.App.StatusFeed = &protocol.StatusFeed{}
+	module.Configure("test", "evaluator.test")
+	module.Start()
+	changes := module.App.StatusFeed.Subscribe(10)
+
+	evaluate := func(group string) {
+		request := &protocol.EvaluatorRequest{
+			Reply:   make(chan *protocol.ConsumerGroupStatus),
+			Cluster: "testcluster",
+			Group:   group,
+			Refresh: true,
+		}
+		module.GetCommunicationChannel() <- request
+		<-request.Reply
+	}
+
+	// The first evaluation of a group is a change from NOTFOUND, and evaluating it again with the same status is not
+	evaluate("testgroup")
+	evaluate("testgroup")
+	evaluate("nogroup")
+	if assert.Len(t, changes, 1, "Expected exactly one status change") {
+		change := <-changes
+		assert.Equal(t, "testcluster", change.Cluster)
+		assert.Equal(t, "testgroup", change.Group)
+		assert.Equalf(t, protocol.StatusOK, change.Status, "Expected status to be OK, not %v", change.Status.String())
+		assert.Equalf(t, protocol.StatusNotFound, change.PreviousStatus, "Expected previous status to be NOTFOUND, not %v", change.PreviousStatus.String())
+	}
+
+	// A group that is removed changes to NOTFOUND
+	module.publishStatusChange("testcluster testgroup", &protocol.ConsumerGroupStatus{Cluster: "testcluster", Group: "testgroup", Status: protocol.StatusNotFound})
+	if assert.Len(t, changes, 1, "Expected a change for the removed group") {
+		change := <-changes
+		assert.Equal(t, protocol.StatusNotFound, change.Status)
+		assert.Equal(t, protocol.StatusOK, change.PreviousStatus)
+	}
+	assert.NotContains(t, module.lastStatus, "testcluster testgroup", "Expected the removed group to be forgotten")
+
+	module.App.StatusFeed.Unsubscribe(changes)
+	stopTestCluster(storageCoordinator, module)
+}
+
+func TestCachingEvaluator_SummaryRequest(t *testing.T) {
+	storageCoordinator, module := startWithTestCluster()
+
+	request := &protocol.EvaluatorRequest{
+		Reply:   make(chan *protocol.ConsumerGroupStatus),
+		Cluster: "testcluster",
+		Group:   "testgroup",
+	}
+	module.GetCommunicationChannel() <- request
+	<-request.Reply
+	module.publishStatusChange("testcluster stallgroup", &protocol.ConsumerGroupStatus{
+		Cluster:    "testcluster",
+		Group:      "stallgroup",
+		Status:     protocol.StatusError,
+		Partitions: []*protocol.PartitionStatus{{Status: protocol.StatusOK}, {Status: protocol.StatusStall}},
+	})
+	module.publishStatusChange("othercluster othergroup", &protocol.ConsumerGroupStatus{
+		Cluster: "othercluster",
+		Group:   "othergroup",
+		Status:  protocol.StatusWarning,
+	})
+
+	// The summary has the last status of the groups in the cluster, without evaluating them again
+	summaryRequest := &protocol.EvaluatorRequest{
+		Cluster:      "testcluster",
+		SummaryReply: make(chan map[string]*protocol.GroupStatusSummary),
+	}
+	module.GetCommunicationChannel() <- summaryRequest
+	summary := <-summaryRequest.SummaryReply
+	assert.Equal(t, map[string]*protocol.GroupStatusSummary{
+		"testgroup":  {Status: protocol.StatusOK},
+		"stallgroup": {Status: protocol.StatusError, Stalled: true},
+	}, summary, "Expected the last status of each group in the cluster")
+
+	stopTestCluster(storageCoordinator, module)
+}
+
+func TestCachingEvaluator_SingleRequest_Refresh(t *testing.T) {
+	storageCoordinator, module := startWithTestCluster()
+
+	request := &protocol.EvaluatorRequest{
+		Reply:   make(chan *protocol.ConsumerGroupStatus),
+		Cluster: "testcluster",
+		Group:   "testgroup",
+		ShowAll: false,
+	}
+	module.GetCommunicationChannel() <- request
+	response := <-request.Reply
+	assert.False(t, response.Rebalancing, "Expected rebalancing to be false")
+
+	now := time.Now().Unix() * 1000
+	for generation := int32(1); generation <= 2; generation++ {
+		storageCoordinator.App.StorageChannel <- &protocol.StorageRequest{
+			RequestType: protocol.StorageSetConsumerGeneration,
+			Cluster:     "testcluster",
+			Group:       "testgroup",
+			Generation:  generation,
+			Timestamp:   now,
+		}
+	}
+
+	// The cached status is returned until it expires
+	module.GetCommunicationChannel() <- request
+	response = <-request.Reply
+	assert.False(t, response.Rebalancing, "Expected cached rebalancing to be false")
+
+	// Unless the evaluation is forced
+	request.Refresh = true
+	module.GetCommunicationChannel() <- request
+	response = <-request.Reply
+	assert.True(t, response.Rebalancing, "Expected refreshed rebalancing to be true")
+
+	// The refreshed status replaces the cached status
+	request.Refresh = false
+	module.GetCommunicationChannel() <- request
+	response = <-request.Reply
+	assert.True(t, response.Rebalancing, "Expected cached rebalancing to be true")
+
+	stopTestCluster(storageCoordinator, module)
+}
+
+func TestCachingEvaluator_SingleRequest_RebalanceExpired(t *testing.T) {
+	storageCoordinator, module := fixtureModule()
+	viper.Set("evaluator.test.rebalance-window", 60)
+	module.Configure("test", "evaluator.test")
+	module.Start()
+
+	before := (time.Now().Unix() - 120) * 1000
+	for generation := int32(1); generation <= 2; generation++ {
+		storageCoordinator.App.StorageChannel <- &protocol.StorageRequest{
+			RequestType: protocol.StorageSetConsumerGeneration,
+			Cluster:     "testcluster",
+			Group:       "testgroup",
+			Generation:  generation,
+			Timestamp:   before,
+		}
+	}
+
+	request := &protocol.EvaluatorRequest{
+		Reply:   make(chan *protocol.ConsumerGroupStatus),
+		Cluster: "testcluster",
+		Group:   "testgroup",
+		ShowAll: true,
+	}
+	module.GetCommunicationChannel() <- request
+	response := <-request.Reply
+
+	assert.False(t, response.Rebalancing, "Expected rebalancing to be false outside of the rebalance window")
+	assert.Equalf(t, uint64(1), response.Rebalances, "Expected rebalances to be 1, not %v", response.Rebalances)
+
+	stopTestCluster(storageCoordinator, module)
+}
+
+func TestCachingEvaluator_SingleRequest_MaxLag(t *testing.T) {
+	storageCoordinator, module := fixtureModule()
+	viper.Set("evaluator.test.max-lag", 1000)
+	viper.Set("evaluator.test.max-lag-status", "error")
+	module.Configure("test", "evaluator.test")
+	module.Start()
+
+	request := &protocol.EvaluatorRequest{
+		Reply:   make(chan *protocol.ConsumerGroupStatus),
+		Cluster: "testcluster",
+		Group:   "testgroup",
+		ShowAll: false,
+	}
+	module.GetCommunicationChannel() <- request
+	response := <-request.Reply
+
+	assert.Equalf(t, protocol.StatusError, response.Status, "Expected status to be ERR, not %v", response.Status.String())
+	if assert.Lenf(t, response.Partitions, 1, "Expected 1 partition status object, not %v", len(response.Partitions)) {
+		assert.Equalf(t, "max-lag", response.Partitions[0].Rule, "Expected rule to be max-lag, not %v", response.Partitions[0].Rule)
+	}
+
+	stopTestCluster(storageCoordinator, module)
+}
+
+var applyMaxLagTests = []struct {
+	status       protocol.StatusConstant
+	rule         string
+	currentLag   uint64
+	maxLag       uint64
+	maxLagStatus protocol.StatusConstant
+	result       protocol.StatusConstant
+	resultRule   string
+}{
+	/*0*/ {protocol.StatusOK, "", 100, 0, protocol.StatusWarning, protocol.StatusOK, ""},
+	/*1*/ {protocol.StatusOK, "", 100, 100, protocol.StatusWarning, protocol.StatusOK, ""},
+	/*2*/ {protocol.StatusOK, "", 101, 100, protocol.StatusWarning, protocol.StatusWarning, "max-lag"},
+	/*3*/ {protocol.StatusWarning, "sliding-window", 101, 100, protocol.StatusWarning, protocol.StatusWarning, "sliding-window"},
+	/*4*/ {protocol.StatusWarning, "sliding-window", 101, 100, protocol.StatusError, protocol.StatusError, "max-lag"},
+	/*5*/ {protocol.StatusStall, "sliding-window", 101, 100, protocol.StatusError, protocol.StatusStall, "sliding-window"},
+}
+
+func TestCachingEvaluator_SingleRequest_TopicStatus(t *testing.T) {
+	storageCoordinator, module := fixtureModule()
+	viper.Set("evaluator.test.topic-status", true)
+	module.Configure("test", "evaluator.test")
+	module.Start()
+
+	request := &protocol.EvaluatorRequest{
+		Reply:   make(chan *protocol.ConsumerGroupStatus),
+		Cluster: "testcluster",
+		Group:   "testgroup",
+		ShowAll: false,
+	}
+	module.GetCommunicationChannel() <- request
+	response := <-request.Reply
+
+	// The topics are returned even though the partitions are filtered out
+	assert.Lenf(t, response.Partitions, 0, "Expected 0 partition status objects, not %v", len(response.Partitions))
+	if assert.Contains(t, response.Topics, "testtopic", "Expected a status for testtopic") {
+		topicStatus := response.Topics["testtopic"]
+		assert.Equalf(t, protocol.StatusOK, topicStatus.Status, "Expected topic status to be OK, not %v", topicStatus.Status.String())
+		assert.Equalf(t, 1, topicStatus.TotalPartitions, "Expected topic partition_count to be 1, not %v", topicStatus.TotalPartitions)
+		assert.Equalf(t, uint64(2421), topicStatus.TotalLag, "Expected topic total_lag to be 2421, not %v", topicStatus.TotalLag)
+	}
 
 	stopTestCluster(storageCoordinator, module)
 }
 
+func TestCachingEvaluator_evaluateTopicStatus(t *testing.T) {
+	partitions := []*protocol.PartitionStatus{
+		{Topic: "topic1", Partition: 0, Status: protocol.StatusOK, CurrentLag: 10, Complete: 1.0},
+		{Topic: "topic1", Partition: 1, Status: protocol.StatusOK, CurrentLag: 20, Complete: 0.5},
+		{Topic: "topic2", Partition: 0, Status: protocol.StatusStall, CurrentLag: 500, TimeLag: 60000, Complete: 1.0},
+		{Topic: "topic2", Partition: 1, Status: protocol.StatusWarning, CurrentLag: 100, Complete: 1.0},
+	}
+	topics := evaluateTopicStatus(partitions)
+
+	assert.Lenf(t, topics, 2, "Expected 2 topics, not %v", len(topics))
+	assert.Equalf(t, protocol.StatusOK, topics["topic1"].Status, "Expected topic1 status to be OK, not %v", topics["topic1"].Status.String())
+	assert.Equalf(t, uint64(30), topics["topic1"].TotalLag, "Expected topic1 total lag to be 30, not %v", topics["topic1"].TotalLag)
+	assert.Equalf(t, float32(0.5), topics["topic1"].Complete, "Expected topic1 complete to be 0.5, not %v", topics["topic1"].Complete)
+	assert.Equal(t, partitions[1], topics["topic1"].Maxlag, "Expected topic1 maxlag to be partition 1")
+	assert.Equalf(t, protocol.StatusError, topics["topic2"].Status, "Expected topic2 status to be ERR, not %v", topics["topic2"].Status.String())
+	assert.Equalf(t, 2, topics["topic2"].TotalPartitions, "Expected topic2 partition count to be 2, not %v", topics["topic2"].TotalPartitions)
+	assert.Equalf(t, int64(60000), topics["topic2"].MaxTimeLag, "Expected topic2 max time lag to be 60000, not %v", topics["topic2"].MaxTimeLag)
+}
+
+func TestCachingEvaluator_applyMaxLag(t *testing.T) {
+	for i, testSet := range applyMaxLagTests {
+		status := &protocol.PartitionStatus{
+			Status:     testSet.status,
+			Rule:       testSet.rule,
+			CurrentLag: testSet.currentLag,
+		}
+		applyMaxLag(status, testSet.maxLag, testSet.maxLagStatus)
+		assert.Equalf(t, testSet.result, status.Status, "TEST %v: Expected status %v, not %v", i, testSet.result.String(), status.Status.String())
+		assert.Equalf(t, testSet.resultRule, status.Rule, "TEST %v: Expected rule %v, not %v", i, testSet.resultRule, status.Rule)
+	}
+}
+
+func TestCachingEvaluator_Configure_MaxTimeLag(t *testing.T) {
+	storageCoordinator, module := fixtureModule()
+	defer storageCoordinator.Stop()
+	module.Configure("test", "evaluator.test")
+	assert.Equal(t, int64(0), module.maxTimeLag, "Expected max-time-lag to be off by default")
+
+	viper.Set("evaluator.test.max-time-lag", 300)
+	module.Configure("test", "evaluator.test")
+	assert.Equal(t, int64(300), module.maxTimeLag, "Expected max-time-lag to be set")
+
+	viper.Set("evaluator.test.max-time-lag", -1)
+	assert.Panics(t, func() { module.Configure("test", "evaluator.test") }, "The code did not panic")
+}
+
+var applyMaxTimeLagTests = []struct {
+	status     protocol.StatusConstant
+	rule       string
+	timeLag    int64
+	maxTimeLag int64
+	result     protocol.StatusConstant
+	resultRule string
+}{
+	/*0*/ {protocol.StatusOK, "", 600000, 0, protocol.StatusOK, ""},
+	/*1*/ {protocol.StatusOK, "", 300000, 300, protocol.StatusOK, ""},
+	/*2*/ {protocol.StatusOK, "", 300001, 300, protocol.StatusWarning, "max-time-lag"},
+	/*3*/ {protocol.StatusStop, "sliding-window", 600000, 300, protocol.StatusStop, "sliding-window"},
+}
+
+func TestCachingEvaluator_applyMaxTimeLag(t *testing.T) {
+	for i, testSet := range applyMaxTimeLagTests {
+		status := &protocol.PartitionStatus{
+			Status:  testSet.status,
+			Rule:    testSet.rule,
+			TimeLag: testSet.timeLag,
+		}
+		applyMaxTimeLag(status, testSet.maxTimeLag, protocol.StatusWarning)
+		assert.Equalf(t, testSet.result, status.Status, "TEST %v: Expected status %v, not %v", i, testSet.result.String(), status.Status.String())
+		assert.Equalf(t, testSet.resultRule, status.Rule, "TEST %v: Expected rule %v, not %v", i, testSet.resultRule, status.Rule)
+	}
+}
+
+func TestCachingEvaluator_Configure_OffsetReset(t *testing.T) {
+	storageCoordinator, module := fixtureModule()
+	defer storageCoordinator.Stop()
+	viper.Set("evaluator.test.offset-reset-window", 600)
+	viper.Set("evaluator.test.offset-reset-suppress", true)
+
+	module.Configure("test", "evaluator.test")
+	assert.Equal(t, int64(600), module.offsetResetWindow, "Expected offset-reset-window to be set")
+	assert.Equal(t, int64(1), module.offsetResetThreshold, "Default offset-reset-threshold value of 1 did not get set")
+	assert.True(t, module.offsetResetSuppress, "Expected offset-reset-suppress to be set")
+
+	viper.Set("evaluator.test.offset-reset-threshold", 0)
+	assert.Panics(t, func() { module.Configure("test", "evaluator.test") }, "The code did not panic")
+}
+
+var findOffsetResetTests = []struct {
+	offsets   []int64
+	threshold int64
+	result    int64
+}{
+	/*0*/ {[]int64{10, 20, 30, 40}, 1, 0},
+	/*1*/ {[]int64{10, 20, 5, 15}, 1, 3000},
+	/*2*/ {[]int64{10, 20, 5, 15}, 100, 0},
+	/*3*/ {[]int64{1000, 2000, 5, 15, 10}, 100, 3000},
+	/*4*/ {[]int64{1000, 2000, 5, 15, 10}, 5, 5000},
+}
+
+func TestCachingEvaluator_findOffsetReset(t *testing.T) {
+	for i, testSet := range findOffsetResetTests {
+		// Leading nil entries are skipped, as they are in storage
+		offsets := []*protocol.ConsumerOffset{nil}
+		for j, offset := range testSet.offsets {
+			offsets = append(offsets, &protocol.ConsumerOffset{Offset: offset, Timestamp: int64(j+1) * 1000})
+		}
+		result := findOffsetReset(offsets, testSet.threshold)
+		assert.Equalf(t, testSet.result, result, "TEST %v: Expected reset at %v, not %v", i, testSet.result, result)
+	}
+}
+
+func TestCachingEvaluator_applyOffsetReset(t *testing.T) {
+	module := &CachingEvaluator{
+		offsetResetWindow:    600,
+		offsetResetThreshold: 100,
+	}
+	timeNow := time.Now().Unix()
+	offsets := []*protocol.ConsumerOffset{
+		{Offset: 5000, Timestamp: (timeNow - 120) * 1000},
+		{Offset: 10, Timestamp: (timeNow - 60) * 1000},
+	}
+
+	status := &protocol.PartitionStatus{Status: protocol.StatusWarning, Rule: "max-lag"}
+	module.applyOffsetReset(status, offsets, timeNow)
+	assert.True(t, status.OffsetReset, "Expected offset reset to be detected")
+	assert.Equalf(t, protocol.StatusWarning, status.Status, "Expected status to be WARN, not %v", status.Status.String())
+
+	module.offsetResetSuppress = true
+	status = &protocol.PartitionStatus{Status: protocol.StatusWarning, Rule: "max-lag"}
+	module.applyOffsetReset(status, offsets, timeNow)
+	assert.True(t, status.OffsetReset, "Expected offset reset to be detected")
+	assert.Equalf(t, protocol.StatusOK, status.Status, "Expected status to be suppressed to OK, not %v", status.Status.String())
+	assert.Equal(t, "", status.Rule, "Expected rule to be cleared")
+
+	// Outside of the window, the reset is ignored
+	status = &protocol.PartitionStatus{Status: protocol.StatusWarning}
+	module.applyOffsetReset(status, offsets, timeNow+600)
+	assert.False(t, status.OffsetReset, "Expected offset reset outside the window to be ignored")
+	assert.Equalf(t, protocol.StatusWarning, status.Status, "Expected status to be WARN, not %v", status.Status.String())
+
+	// Detection is disabled by default
+	module.offsetResetWindow = 0
+	status = &protocol.PartitionStatus{Status: protocol.StatusWarning}
+	module.applyOffsetReset(status, offsets, timeNow)
+	assert.False(t, status.OffsetReset, "Expected offset reset detection to be disabled")
+}
+
 func TestCachingEvaluator_SingleRequest_ShowAll(t *testing.T) {
 	storageCoordinator, module := startWithTestCluster()
 
@@ -177,12 +676,15 @@ type testset struct {
 	currentLag              uint64
 	timeNow                 int64
 	allowedLag              uint64
+	staleCommit             int64
 	isLagAlwaysNotZero      bool
 	checkIfOffsetsRewind    int
 	checkIfOffsetsStopped   bool
 	checkIfOffsetsStalled   bool
 	checkIfLagNotDecreasing bool
 	checkIfRecentLagZero    bool
+	checkIfCommitStale      bool
+	checkIfPartitionIdle    bool
 	status                  protocol.StatusConstant
 }
 
@@ -456,6 +958,7 @@ var tests = []testset{
 		checkIfOffsetsStalled:   false,
 		checkIfLagNotDecreasing: true,
 		checkIfRecentLagZero:    true,
+		checkIfPartitionIdle:    true,
 		status:                  protocol.StatusOK,
 	},
 
@@ -571,6 +1074,108 @@ var tests = []testset{
 		checkIfRecentLagZero:    false,
 		status:                  protocol.StatusRewind,
 	},
+
+	// 16 - Idle topic with zero lag, and the last commit (1000s ago) is older than the stale-commit threshold (600s), but
+	//      there are no new messages for the consumer, so it is OK
+	{
+		offsets: []*protocol.ConsumerOffset{
+			{Offset: 1000, Order: 1, Timestamp: 100000, Lag: &protocol.Lag{Value: 0}},
+			{Offset: 1000, Order: 2, Timestamp: 200000, Lag: &protocol.Lag{Value: 0}},
+			{Offset: 1000, Order: 3, Timestamp: 300000, Lag: &protocol.Lag{Value: 0}},
+			{Offset: 1000, Order: 4, Timestamp: 400000, Lag: &protocol.Lag{Value: 0}},
+			{Offset: 1000, Order: 5, Timestamp: 500000, Lag: &protocol.Lag{Value: 0}},
+		},
+		brokerOffsets:           []int64{1000},
+		currentLag:              0,
+		timeNow:                 1500,
+		allowedLag:              0,
+		staleCommit:             600,
+		isLagAlwaysNotZero:      false,
+		checkIfOffsetsRewind:    -1,
+		checkIfOffsetsStopped:   true,
+		checkIfOffsetsStalled:   true,
+		checkIfLagNotDecreasing: true,
+		checkIfRecentLagZero:    true,
+		checkIfPartitionIdle:    true,
+		checkIfCommitStale:      true,
+		status:                  protocol.StatusOK,
+	},
+
+	// 17 - same as 16 but with no stale-commit threshold, so the zero lag makes it OK
+	{
+		offsets: []*protocol.ConsumerOffset{
+			{Offset: 1000, Order: 1, Timestamp: 100000, Lag: &protocol.Lag{Value: 0}},
+			{Offset: 1000, Order: 2, Timestamp: 200000, Lag: &protocol.Lag{Value: 0}},
+			{Offset: 1000, Order: 3, Timestamp: 300000, Lag: &protocol.Lag{Value: 0}},
+			{Offset: 1000, Order: 4, Timestamp: 400000, Lag: &protocol.Lag{Value: 0}},
+			{Offset: 1000, Order: 5, Timestamp: 500000, Lag: &protocol.Lag{Value: 0}},
+		},
+		brokerOffsets:           []int64{1000},
+		currentLag:              0,
+		timeNow:                 1500,
+		allowedLag:              0,
+		staleCommit:             0,
+		isLagAlwaysNotZero:      false,
+		checkIfOffsetsRewind:    -1,
+		checkIfOffsetsStopped:   true,
+		checkIfOffsetsStalled:   true,
+		checkIfLagNotDecreasing: true,
+		checkIfRecentLagZero:    true,
+		checkIfPartitionIdle:    true,
+		checkIfCommitStale:      false,
+		status:                  protocol.StatusOK,
+	},
+
+	// 18 - same as 16 but the last commit (500s ago) is within the stale-commit threshold
+	{
+		offsets: []*protocol.ConsumerOffset{
+			{Offset: 1000, Order: 1, Timestamp: 100000, Lag: &protocol.Lag{Value: 0}},
+			{Offset: 1000, Order: 2, Timestamp: 200000, Lag: &protocol.Lag{Value: 0}},
+			{Offset: 1000, Order: 3, Timestamp: 300000, Lag: &protocol.Lag{Value: 0}},
+			{Offset: 1000, Order: 4, Timestamp: 400000, Lag: &protocol.Lag{Value: 0}},
+			{Offset: 1000, Order: 5, Timestamp: 500000, Lag: &protocol.Lag{Value: 0}},
+		},
+		brokerOffsets:           []int64{1000},
+		currentLag:              0,
+		timeNow:                 1000,
+		allowedLag:              0,
+		staleCommit:             600,
+		isLagAlwaysNotZero:      false,
+		checkIfOffsetsRewind:    -1,
+		checkIfOffsetsStopped:   true,
+		checkIfOffsetsStalled:   true,
+		checkIfLagNotDecreasing: true,
+		checkIfRecentLagZero:    true,
+		checkIfPartitionIdle:    true,
+		checkIfCommitStale:      false,
+		status:                  protocol.StatusOK,
+	},
+
+	// 19 - same as 16 but with new messages that are within the allowed lag, so the partition is not idle and the stale
+	//      commit marks it stalled
+	{
+		offsets: []*protocol.ConsumerOffset{
+			{Offset: 1000, Order: 1, Timestamp: 100000, Lag: &protocol.Lag{Value: 0}},
+			{Offset: 1000, Order: 2, Timestamp: 200000, Lag: &protocol.Lag{Value: 0}},
+			{Offset: 1000, Order: 3, Timestamp: 300000, Lag: &protocol.Lag{Value: 0}},
+			{Offset: 1000, Order: 4, Timestamp: 400000, Lag: &protocol.Lag{Value: 0}},
+			{Offset: 1000, Order: 5, Timestamp: 500000, Lag: &protocol.Lag{Value: 0}},
+		},
+		brokerOffsets:           []int64{1000, 1005},
+		currentLag:              5,
+		timeNow:                 1500,
+		allowedLag:              10,
+		staleCommit:             600,
+		isLagAlwaysNotZero:      false,
+		checkIfOffsetsRewind:    -1,
+		checkIfOffsetsStopped:   true,
+		checkIfOffsetsStalled:   true,
+		checkIfLagNotDecreasing: true,
+		checkIfRecentLagZero:    true,
+		checkIfCommitStale:      true,
+		checkIfPartitionIdle:    false,
+		status:                  protocol.StatusStall,
+	},
 }
 
 func TestCachingEvaluator_CheckRules(t *testing.T) {
@@ -595,7 +1200,13 @@ func TestCachingEvaluator_CheckRules(t *testing.T) {
 		result = checkIfRecentLagZero(testSet.offsets, testSet.brokerOffsets)
 		assert.Equalf(t, testSet.checkIfRecentLagZero, result, "TEST %v: Expected checkIfRecentLagZero to return %v, not %v", i, testSet.checkIfRecentLagZero, result)
 
-		status := calculatePartitionStatus(testSet.offsets, testSet.brokerOffsets, testSet.currentLag, testSet.timeNow, testSet.allowedLag)
+		result = checkIfCommitStale(testSet.offsets, testSet.timeNow, testSet.staleCommit)
+		assert.Equalf(t, testSet.checkIfCommitStale, result, "TEST %v: Expected checkIfCommitStale to return %v, not %v", i, testSet.checkIfCommitStale, result)
+
+		result = checkIfPartitionIdle(testSet.offsets, testSet.brokerOffsets)
+		assert.Equalf(t, testSet.checkIfPartitionIdle, result, "TEST %v: Expected checkIfPartitionIdle to return %v, not %v", i, testSet.checkIfPartitionIdle, result)
+
+		status := calculatePartitionStatus(testSet.offsets, testSet.brokerOffsets, testSet.currentLag, testSet.timeNow, testSet.allowedLag, testSet.staleCommit)
 		assert.Equalf(t, testSet.status, status, "TEST %v: Expected calculatePartitionStatus to return %v, not %v", i, testSet.status.String(), status.String())
 	}
 }
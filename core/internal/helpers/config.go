@@ -0,0 +1,98 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package helpers
+
+import (
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+var envReferenceRegexp = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// ExpandEnv replaces each ${VAR} reference in the provided string with the value of the environment variable VAR, or
+// with an empty string if it is not set. A ${VAR:-default} reference is replaced with default if VAR is not set or is
+// empty. Anything else, including a $ that is not followed by a brace, is left as it is.
+func ExpandEnv(value string) string {
+	if !strings.Contains(value, "${") {
+		return value
+	}
+	return envReferenceRegexp.ReplaceAllStringFunc(value, func(ref string) string {
+		match := envReferenceRegexp.FindStringSubmatch(ref)
+		envValue, ok := os.LookupEnv(match[1])
+		if (match[2] != "") && (!ok || (envValue == "")) {
+			return match[3]
+		}
+		return envValue
+	})
+}
+
+// ExpandConfigEnv runs ExpandEnv over every string in the configuration file that viper has read, including the strings
+// in lists and tables, and merges the expanded values back into the configuration. It must be called again after each
+// time the configuration file is read, as reading it replaces the expanded values. If no configuration file has been
+// read, it does nothing.
+func ExpandConfigEnv() error {
+	configFile := viper.ConfigFileUsed()
+	if configFile == "" {
+		return nil
+	}
+
+	// Read the file separately, so that only the values from it are expanded, and not the defaults or overrides
+	fileConfig := viper.New()
+	fileConfig.SetConfigFile(configFile)
+	if err := fileConfig.ReadInConfig(); err != nil {
+		return err
+	}
+	return viper.MergeConfigMap(expandEnvValue(fileConfig.AllSettings()).(map[string]interface{}))
+}
+
+// ReadConfigFile reads the configuration file into a new viper instance, and runs ExpandEnv over every string in it, in
+// the same way as ExpandConfigEnv. It is used to read the configuration file again while Burrow is running, as the
+// global configuration is read by the modules without locking, and cannot be replaced.
+func ReadConfigFile(configFile string) (*viper.Viper, error) {
+	config := viper.New()
+	config.SetConfigFile(configFile)
+	if err := config.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	if err := config.MergeConfigMap(expandEnvValue(config.AllSettings()).(map[string]interface{})); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func expandEnvValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return ExpandEnv(v)
+	case []interface{}:
+		expanded := make([]interface{}, len(v))
+		for i, item := range v {
+			expanded[i] = expandEnvValue(item)
+		}
+		return expanded
+	case []string:
+		expanded := make([]string, len(v))
+		for i, item := range v {
+			expanded[i] = ExpandEnv(item)
+		}
+		return expanded
+	case map[string]interface{}:
+		expanded := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			expanded[key] = expandEnvValue(item)
+		}
+		return expanded
+	default:
+		return value
+	}
+}
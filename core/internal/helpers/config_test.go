@@ -0,0 +1,104 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+var expandEnvTests = []struct {
+	value    string
+	expected string
+}{
+	/*0*/ {"no references", "no references"},
+	/*1*/ {"${BURROW_TEST_SET}", "setvalue"},
+	/*2*/ {"https://${BURROW_TEST_SET}:8080/${BURROW_TEST_SET}", "https://setvalue:8080/setvalue"},
+	/*3*/ {"${BURROW_TEST_UNSET}", ""},
+	/*4*/ {"${BURROW_TEST_UNSET:-fallback}", "fallback"},
+	/*5*/ {"${BURROW_TEST_EMPTY:-fallback}", "fallback"},
+	/*6*/ {"${BURROW_TEST_SET:-fallback}", "setvalue"},
+	/*7*/ {"${BURROW_TEST_UNSET:-}", ""},
+	/*8*/ {"pa$$word$BURROW_TEST_SET", "pa$$word$BURROW_TEST_SET"},
+	/*9*/ {"${not a reference}", "${not a reference}"},
+}
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("BURROW_TEST_SET", "setvalue")
+	t.Setenv("BURROW_TEST_EMPTY", "")
+	os.Unsetenv("BURROW_TEST_UNSET")
+
+	for i, testSet := range expandEnvTests {
+		result := ExpandEnv(testSet.value)
+		assert.Equalf(t, testSet.expected, result, "TEST %v: Expected '%v', not '%v'", i, testSet.expected, result)
+	}
+}
+
+func TestExpandConfigEnv(t *testing.T) {
+	t.Setenv("BURROW_TEST_PASSWORD", "secret")
+	t.Setenv("BURROW_TEST_BROKER", "kafka01.example.com:9092")
+	os.Unsetenv("BURROW_TEST_UNSET")
+
+	configFile := filepath.Join(t.TempDir(), "burrow.toml")
+	err := os.WriteFile(configFile, []byte(`
+[sasl.test]
+password="${BURROW_TEST_PASSWORD}"
+handshake-first=true
+
+[cluster.test]
+servers=[ "${BURROW_TEST_BROKER}", "${BURROW_TEST_UNSET:-kafka02.example.com:9092}" ]
+`), 0600)
+	assert.NoError(t, err, "Expected config file setup to return no error")
+
+	viper.Reset()
+	viper.SetConfigFile(configFile)
+	assert.NoError(t, viper.ReadInConfig(), "Expected the config file to be read")
+	viper.Set("sasl.test.username", "${BURROW_TEST_PASSWORD}")
+
+	err = ExpandConfigEnv()
+	assert.NoError(t, err, "Expected ExpandConfigEnv to return no error")
+	assert.Equal(t, "secret", viper.GetString("sasl.test.password"), "Expected the password to be expanded")
+	assert.True(t, viper.GetBool("sasl.test.handshake-first"), "Expected non-string values to be kept")
+	assert.Equal(t, []string{"kafka01.example.com:9092", "kafka02.example.com:9092"}, viper.GetStringSlice("cluster.test.servers"),
+		"Expected the strings in the list to be expanded")
+	assert.Equal(t, "${BURROW_TEST_PASSWORD}", viper.GetString("sasl.test.username"), "Expected values not from the file to be left alone")
+}
+
+func TestExpandConfigEnv_NoFile(t *testing.T) {
+	viper.Reset()
+	viper.Set("sasl.test.password", "${BURROW_TEST_PASSWORD}")
+	assert.NoError(t, ExpandConfigEnv(), "Expected ExpandConfigEnv to return no error")
+	assert.Equal(t, "${BURROW_TEST_PASSWORD}", viper.GetString("sasl.test.password"), "Expected the value to be left alone")
+}
+
+func TestReadConfigFile(t *testing.T) {
+	t.Setenv("BURROW_TEST_PASSWORD", "secret")
+
+	configFile := filepath.Join(t.TempDir(), "burrow.toml")
+	err := os.WriteFile(configFile, []byte(`
+[sasl.test]
+password="${BURROW_TEST_PASSWORD}"
+`), 0600)
+	assert.NoError(t, err, "Expected config file setup to return no error")
+
+	viper.Reset()
+	viper.Set("sasl.test.password", "global")
+	config, err := ReadConfigFile(configFile)
+	assert.NoError(t, err, "Expected ReadConfigFile to return no error")
+	assert.Equal(t, "secret", config.GetString("sasl.test.password"), "Expected the password to be expanded")
+	assert.Equal(t, "global", viper.GetString("sasl.test.password"), "Expected the global configuration to be left alone")
+
+	_, err = ReadConfigFile(filepath.Join(t.TempDir(), "missing.toml"))
+	assert.Error(t, err, "Expected a missing file to return an error")
+}
@@ -0,0 +1,47 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package helpers
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LookupSRVServers resolves a DNS SRV record, such as _kafka._tcp.example.com, and returns the targets as a sorted
+// list of host:port strings. An error is returned if the lookup fails, or if the record has no targets.
+func LookupSRVServers(record string) ([]string, error) {
+	_, addrs, err := net.LookupSRV("", "", record)
+	if err != nil {
+		return nil, err
+	}
+
+	servers := srvServers(addrs)
+	if len(servers) == 0 {
+		return nil, errors.New("no servers found for SRV record " + record)
+	}
+	return servers, nil
+}
+
+func srvServers(addrs []*net.SRV) []string {
+	servers := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		// A target of "." means that the service is not available at this domain
+		target := strings.TrimSuffix(addr.Target, ".")
+		if target == "" {
+			continue
+		}
+		servers = append(servers, net.JoinHostPort(target, strconv.Itoa(int(addr.Port))))
+	}
+	sort.Strings(servers)
+	return servers
+}
@@ -0,0 +1,33 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package helpers
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSrvServers(t *testing.T) {
+	addrs := []*net.SRV{
+		{Target: "kafka02.example.com.", Port: 9092},
+		{Target: "kafka01.example.com.", Port: 9093},
+		{Target: ".", Port: 0},
+		{Target: "kafka03.example.com", Port: 9092},
+	}
+	servers := srvServers(addrs)
+	assert.Equalf(t, []string{"kafka01.example.com:9093", "kafka02.example.com:9092", "kafka03.example.com:9092"}, servers, "Unexpected servers: %v", servers)
+}
+
+func TestSrvServers_Empty(t *testing.T) {
+	servers := srvServers([]*net.SRV{{Target: ".", Port: 0}})
+	assert.Empty(t, servers, "Expected no servers for an unavailable service")
+}
@@ -0,0 +1,78 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package helpers
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+
+	"github.com/spf13/viper"
+
+	"github.com/linkedin/Burrow/core/protocol"
+)
+
+// CompileConfigRegexp compiles the regular expression that is configured at the provided key of the viper instance. If
+// the key is not set, or is empty, it returns nil with no error. If the expression cannot be compiled, an error naming
+// the key is returned.
+func CompileConfigRegexp(config *viper.Viper, configKey string) (*regexp.Regexp, error) {
+	expr := config.GetString(configKey)
+	if expr == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, errors.New("failed to compile " + configKey + ": " + err.Error())
+	}
+	return re, nil
+}
+
+// ReloadCoordinatorModuleFilters is a helper func for coordinators to reload the filters of a list of modules. Given a
+// map of protocol.Module, it calls the ReloadFilters func on each one that implements protocol.FilterReloader, with the
+// provided configuration. A module that has an entry in moduleConfigs, such as one for a discovered cluster, is
+// reloaded from that configuration instead. All of the modules are reloaded even if one fails, and the last error is
+// returned.
+func ReloadCoordinatorModuleFilters(modules map[string]protocol.Module, config *viper.Viper, moduleConfigs map[string]*viper.Viper) error {
+	var lastErr error
+	for name, module := range modules {
+		if reloader, ok := module.(protocol.FilterReloader); ok {
+			moduleConfig, ok := moduleConfigs[name]
+			if !ok {
+				moduleConfig = config
+			}
+			if err := reloader.ReloadFilters(moduleConfig); err != nil {
+				lastErr = errors.New(name + ": " + err.Error())
+			}
+		}
+	}
+	return lastErr
+}
+
+// CheckCoordinatorModuleConnections is a helper func for coordinators to check the connections of a list of modules.
+// Given the name of the coordinator and a map of protocol.Module, it calls the CheckConnection func on each one that
+// implements protocol.ConnectionChecker, in order of name. All of the modules are checked even if one fails, and the
+// errors are returned joined together, each one prefixed with the config root of the module (such as cluster.local).
+func CheckCoordinatorModuleConnections(coordinator string, modules map[string]protocol.Module) error {
+	names := make([]string, 0, len(modules))
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		if checker, ok := modules[name].(protocol.ConnectionChecker); ok {
+			if err := checker.CheckConnection(); err != nil {
+				errs = append(errs, errors.New(coordinator+"."+name+": "+err.Error()))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,106 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package helpers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/linkedin/Burrow/core/protocol"
+)
+
+func TestCompileConfigRegexp(t *testing.T) {
+	viper.Reset()
+	viper.Set("test.good", "^test.*")
+	viper.Set("test.bad", "[")
+
+	re, err := CompileConfigRegexp(viper.GetViper(), "test.good")
+	assert.NoError(t, err)
+	assert.True(t, re.MatchString("testgroup"))
+
+	re, err = CompileConfigRegexp(viper.GetViper(), "test.unset")
+	assert.NoError(t, err)
+	assert.Nil(t, re, "Expected no regexp for an unset key")
+
+	re, err = CompileConfigRegexp(viper.GetViper(), "test.bad")
+	assert.Nil(t, re)
+	assert.EqualError(t, err, "failed to compile test.bad: error parsing regexp: missing closing ]: `[`")
+}
+
+// mockReloadingModule is a MockModule that also implements protocol.FilterReloader
+type mockReloadingModule struct {
+	MockModule
+}
+
+func (m *mockReloadingModule) ReloadFilters(config *viper.Viper) error {
+	args := m.Called(config)
+	return args.Error(0)
+}
+
+func TestReloadCoordinatorModuleFilters(t *testing.T) {
+	config := viper.New()
+	discoveredConfig := viper.New()
+
+	reloading := &mockReloadingModule{}
+	reloading.On("ReloadFilters", config).Return(nil)
+	failing := &mockReloadingModule{}
+	failing.On("ReloadFilters", config).Return(errors.New("bad filter"))
+	discovered := &mockReloadingModule{}
+	discovered.On("ReloadFilters", discoveredConfig).Return(nil)
+
+	modules := map[string]protocol.Module{
+		"reloading":  reloading,
+		"failing":    failing,
+		"discovered": discovered,
+		"plain":      &MockModule{},
+	}
+	err := ReloadCoordinatorModuleFilters(modules, config, map[string]*viper.Viper{"discovered": discoveredConfig})
+
+	reloading.AssertExpectations(t)
+	failing.AssertExpectations(t)
+	discovered.AssertExpectations(t)
+	assert.EqualError(t, err, "failing: bad filter")
+}
+
+// mockCheckingModule is a MockModule that also implements protocol.ConnectionChecker
+type mockCheckingModule struct {
+	MockModule
+}
+
+func (m *mockCheckingModule) CheckConnection() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func TestCheckCoordinatorModuleConnections(t *testing.T) {
+	connected := &mockCheckingModule{}
+	connected.On("CheckConnection").Return(nil)
+	failing := &mockCheckingModule{}
+	failing.On("CheckConnection").Return(errors.New("no brokers"))
+	alsoFailing := &mockCheckingModule{}
+	alsoFailing.On("CheckConnection").Return(errors.New("bad version"))
+
+	modules := map[string]protocol.Module{
+		"connected":   connected,
+		"failing":     failing,
+		"alsofailing": alsoFailing,
+		"plain":       &MockModule{},
+	}
+	err := CheckCoordinatorModuleConnections("cluster", modules)
+
+	connected.AssertExpectations(t)
+	failing.AssertExpectations(t)
+	alsoFailing.AssertExpectations(t)
+	assert.EqualError(t, err, "cluster.alsofailing: bad version\ncluster.failing: no brokers")
+	assert.NoError(t, CheckCoordinatorModuleConnections("cluster", map[string]protocol.Module{"connected": connected}))
+}
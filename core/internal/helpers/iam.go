@@ -2,41 +2,84 @@ package helpers
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	sarama "github.com/IBM/sarama"
 	"github.com/aws/aws-msk-iam-sasl-signer-go/signer"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 )
 
 var (
-	signerGenerateAuthToken            = signer.GenerateAuthToken
-	signerGenerateAuthTokenFromRole    = signer.GenerateAuthTokenFromRole
-	signerGenerateAuthTokenFromProfile = signer.GenerateAuthTokenFromProfile
+	signerGenerateAuthToken                        = signer.GenerateAuthToken
+	signerGenerateAuthTokenFromRole                = signer.GenerateAuthTokenFromRole
+	signerGenerateAuthTokenFromProfile             = signer.GenerateAuthTokenFromProfile
+	signerGenerateAuthTokenFromCredentialsProvider = signer.GenerateAuthTokenFromCredentialsProvider
 )
 
 type iamTokenProvider struct {
-	region, roleArn, profile string
+	region, roleArn, profile, credentialsSource string
+
+	// Only used with the "static" credentials source
+	accessKeyID, secretAccessKey, sessionToken string
+
+	// A generated token is reused until it is within refreshSkew of its expiry
+	refreshSkew time.Duration
+	lock        sync.Mutex
+	token       string
+	expiry      time.Time
 }
 
+// Token returns the cached token if it is still valid, and generates a new one otherwise. It is safe to call from
+// multiple goroutines.
 func (p *iamTokenProvider) Token() (*sarama.AccessToken, error) {
-	var tok string
-	var err error
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.token != "" && time.Now().Add(p.refreshSkew).Before(p.expiry) {
+		return &sarama.AccessToken{Token: p.token}, nil
+	}
+
+	tok, expiryMs, err := p.generateToken()
+	if err != nil {
+		return nil, err
+	}
+	p.token = tok
+	p.expiry = time.UnixMilli(expiryMs)
+	return &sarama.AccessToken{Token: tok}, nil
+}
 
-	switch {
-	case p.roleArn != "":
-		tok, _, err = signerGenerateAuthTokenFromRole(
+// generateToken calls the signer that matches the configured credentials source, returning the token and its expiry
+// time in milliseconds.
+func (p *iamTokenProvider) generateToken() (string, int64, error) {
+
+	// The "default" credentials source keeps the original behavior of preferring role-arn, then profile, and finally
+	// the standard AWS credential chain
+	source := p.credentialsSource
+	if source == "" || source == "default" {
+		switch {
+		case p.roleArn != "":
+			source = "role"
+		case p.profile != "":
+			source = "profile"
+		}
+	}
+
+	switch source {
+	case "static":
+		return signerGenerateAuthTokenFromCredentialsProvider(
+			context.TODO(), p.region, credentials.NewStaticCredentialsProvider(p.accessKeyID, p.secretAccessKey, p.sessionToken))
+
+	case "role":
+		return signerGenerateAuthTokenFromRole(
 			context.TODO(), p.region, p.roleArn, "burrow-session")
 
-	case p.profile != "":
-		tok, _, err = signerGenerateAuthTokenFromProfile(
+	case "profile":
+		return signerGenerateAuthTokenFromProfile(
 			context.TODO(), p.region, p.profile)
 
 	default:
-		tok, _, err = signerGenerateAuthToken(
+		return signerGenerateAuthToken(
 			context.TODO(), p.region)
 	}
-
-	if err != nil {
-		return nil, err
-	}
-	return &sarama.AccessToken{Token: tok}, nil
 }
@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	sarama "github.com/IBM/sarama"
+	"github.com/aws/aws-sdk-go-v2/aws"
 )
 
 // stubAll replaces the three signer helpers and guarantees they are restored.
@@ -53,14 +55,14 @@ func mustNotCallProf(t *testing.T) func(context.Context, string, string) (string
 func TestIamTokenProvider(t *testing.T) {
 	tests := []struct {
 		name      string
-		provider  iamTokenProvider
+		provider  *iamTokenProvider
 		setupStub func(t *testing.T) (restore func())
 		wantTok   string
 		wantErr   bool
 	}{
 		{
 			name:     "default credential chain",
-			provider: iamTokenProvider{region: "eu-central-1"},
+			provider: &iamTokenProvider{region: "eu-central-1"},
 			setupStub: func(t *testing.T) func() {
 				return stubAll(
 					t,
@@ -78,7 +80,7 @@ func TestIamTokenProvider(t *testing.T) {
 		},
 		{
 			name:     "assume‑role path overrides profile",
-			provider: iamTokenProvider{region: "us-east-1", roleArn: "arn:aws:iam::123456789012:role/test", profile: "ignored"},
+			provider: &iamTokenProvider{region: "us-east-1", roleArn: "arn:aws:iam::123456789012:role/test", profile: "ignored"},
 			setupStub: func(t *testing.T) func() {
 				return stubAll(
 					t,
@@ -96,7 +98,7 @@ func TestIamTokenProvider(t *testing.T) {
 		},
 		{
 			name:     "named profile path",
-			provider: iamTokenProvider{region: "ap-south-1", profile: "burrow"},
+			provider: &iamTokenProvider{region: "ap-south-1", profile: "burrow"},
 			setupStub: func(t *testing.T) func() {
 				return stubAll(
 					t,
@@ -114,7 +116,7 @@ func TestIamTokenProvider(t *testing.T) {
 		},
 		{
 			name:     "signer returns error",
-			provider: iamTokenProvider{region: "eu-west-1"},
+			provider: &iamTokenProvider{region: "eu-west-1"},
 			setupStub: func(t *testing.T) func() {
 				return stubAll(
 					t,
@@ -154,3 +156,116 @@ func TestIamTokenProvider(t *testing.T) {
 		})
 	}
 }
+
+func TestIamTokenProvider_StaticCredentials(t *testing.T) {
+	restore := stubAll(t, mustNotCallAuth(t), mustNotCallRole(t), mustNotCallProf(t))
+	defer restore()
+	origProvider := signerGenerateAuthTokenFromCredentialsProvider
+	defer func() { signerGenerateAuthTokenFromCredentialsProvider = origProvider }()
+
+	signerGenerateAuthTokenFromCredentialsProvider = func(ctx context.Context, region string, provider aws.CredentialsProvider) (string, int64, error) {
+		creds, err := provider.Retrieve(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error retrieving credentials: %v", err)
+		}
+		if region != "eu-west-1" || creds.AccessKeyID != "AKID" || creds.SecretAccessKey != "SECRET" {
+			t.Fatalf("bad args to credentials provider helper")
+		}
+		return "tok-static", 0, nil
+	}
+
+	provider := iamTokenProvider{
+		region:            "eu-west-1",
+		roleArn:           "arn:aws:iam::123456789012:role/ignored",
+		credentialsSource: "static",
+		accessKeyID:       "AKID",
+		secretAccessKey:   "SECRET",
+	}
+	got, err := provider.Token()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Token != "tok-static" {
+		t.Fatalf("token = %s, want tok-static", got.Token)
+	}
+}
+
+func TestIamTokenProvider_ExplicitProfileSource(t *testing.T) {
+	restore := stubAll(
+		t,
+		mustNotCallAuth(t),
+		mustNotCallRole(t),
+		func(_ context.Context, region, profile string) (string, int64, error) {
+			return "tok-profile", 0, nil
+		},
+	)
+	defer restore()
+
+	provider := iamTokenProvider{
+		region:            "us-east-1",
+		roleArn:           "arn:aws:iam::123456789012:role/ignored",
+		profile:           "burrow",
+		credentialsSource: "profile",
+	}
+	got, err := provider.Token()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Token != "tok-profile" {
+		t.Fatalf("token = %s, want tok-profile", got.Token)
+	}
+}
+
+func TestIamTokenProvider_CachesToken(t *testing.T) {
+	calls := 0
+	expiry := time.Now().Add(15 * time.Minute).UnixMilli()
+	restore := stubAll(
+		t,
+		func(context.Context, string) (string, int64, error) {
+			calls++
+			return "tok-cached", expiry, nil
+		},
+		mustNotCallRole(t),
+		mustNotCallProf(t),
+	)
+	defer restore()
+
+	provider := &iamTokenProvider{region: "eu-central-1", refreshSkew: 60 * time.Second}
+	for i := 0; i < 2; i++ {
+		got, err := provider.Token()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.Token != "tok-cached" {
+			t.Fatalf("token = %s, want tok-cached", got.Token)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("signer called %d times, want 1", calls)
+	}
+}
+
+func TestIamTokenProvider_RefreshesWithinSkew(t *testing.T) {
+	calls := 0
+	expiry := time.Now().Add(30 * time.Second).UnixMilli()
+	restore := stubAll(
+		t,
+		func(context.Context, string) (string, int64, error) {
+			calls++
+			return "tok-expiring", expiry, nil
+		},
+		mustNotCallRole(t),
+		mustNotCallProf(t),
+	)
+	defer restore()
+
+	provider := &iamTokenProvider{region: "eu-central-1", refreshSkew: 60 * time.Second}
+	for i := 0; i < 2; i++ {
+		if _, err := provider.Token(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("signer called %d times, want 2", calls)
+	}
+}
@@ -0,0 +1,254 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package helpers
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// Sarama does not implement the OffsetForLeaderEpoch request (API key 23), so it is encoded here and sent to the broker
+// over a connection of its own, instead of the connection that Sarama holds for the broker.
+const offsetForLeaderEpochKey = 23
+
+// The largest response that is read from the broker, which is far more than the end offsets for any request needs
+const offsetForLeaderEpochMaxResponse = 100 * 1024 * 1024
+
+// ErrOffsetForLeaderEpochUnsupported is returned by BurrowSaramaBroker.OffsetForLeaderEpoch if the Kafka version in the
+// client profile is older than 0.11.0, which is the first that has the request, or if the client profile uses SASL,
+// as the SASL handshake is not done on the connection that the request is sent over.
+var ErrOffsetForLeaderEpochUnsupported = errors.New("OffsetForLeaderEpoch needs kafka 0.11.0 or later, and a client profile without SASL")
+
+// offsetForLeaderEpochVersion returns the version of the OffsetForLeaderEpoch request to send for a Kafka version, or
+// -1 if the request is not supported. Version 1 adds the leader epoch to the response, and version 2 adds the current
+// leader epoch to the request and the throttle time to the response.
+func offsetForLeaderEpochVersion(version sarama.KafkaVersion) int16 {
+	switch {
+	case version.IsAtLeast(sarama.V2_1_0_0):
+		return 2
+	case version.IsAtLeast(sarama.V2_0_0_0):
+		return 1
+	case version.IsAtLeast(sarama.V0_11_0_0):
+		return 0
+	default:
+		return -1
+	}
+}
+
+// OffsetForLeaderEpoch sends an OffsetForLeaderEpoch request to the broker, over a new connection that is set up with
+// the network and TLS settings of the client, and returns the end offset of the requested leader epoch for each
+// partition. The connection is closed before returning.
+func (b *BurrowSaramaBroker) OffsetForLeaderEpoch(request *OffsetForLeaderEpochRequest) (*OffsetForLeaderEpochResponse, error) {
+	if b.config == nil {
+		return nil, errors.New("broker has no client config")
+	}
+	version := offsetForLeaderEpochVersion(b.config.Version)
+	if (version < 0) || b.config.Net.SASL.Enable {
+		return nil, ErrOffsetForLeaderEpochUnsupported
+	}
+
+	conn, err := dialBroker(b.broker.Addr(), b.config)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return sendOffsetForLeaderEpoch(conn, b.config, version, request)
+}
+
+// dialBroker connects to the broker address with the network, proxy, and TLS settings of the sarama.Config
+func dialBroker(addr string, config *sarama.Config) (net.Conn, error) {
+	var conn net.Conn
+	var err error
+	if config.Net.Proxy.Enable && (config.Net.Proxy.Dialer != nil) {
+		conn, err = config.Net.Proxy.Dialer.Dial("tcp", addr)
+	} else {
+		dialer := &net.Dialer{
+			Timeout:   config.Net.DialTimeout,
+			KeepAlive: config.Net.KeepAlive,
+			LocalAddr: config.Net.LocalAddr,
+		}
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !config.Net.TLS.Enable {
+		return conn, nil
+	}
+
+	tlsConfig := &tls.Config{}
+	if config.Net.TLS.Config != nil {
+		tlsConfig = config.Net.TLS.Config.Clone()
+	}
+	if tlsConfig.ServerName == "" {
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			tlsConfig.ServerName = host
+		}
+	}
+	tlsConn := tls.Client(conn, tlsConfig)
+	if config.Net.DialTimeout > 0 {
+		tlsConn.SetDeadline(time.Now().Add(config.Net.DialTimeout))
+	}
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	tlsConn.SetDeadline(time.Time{})
+	return tlsConn, nil
+}
+
+// sendOffsetForLeaderEpoch writes the request to the connection and reads the response to it
+func sendOffsetForLeaderEpoch(conn net.Conn, config *sarama.Config, version int16, request *OffsetForLeaderEpochRequest) (*OffsetForLeaderEpochResponse, error) {
+	const correlationID = 1
+	body := encodeOffsetForLeaderEpochRequest(version, correlationID, config.ClientID, request)
+	if config.Net.WriteTimeout > 0 {
+		conn.SetWriteDeadline(time.Now().Add(config.Net.WriteTimeout))
+	}
+	if _, err := conn.Write(body); err != nil {
+		return nil, err
+	}
+
+	if config.Net.ReadTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(config.Net.ReadTimeout))
+	}
+	var size int32
+	if err := binary.Read(conn, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	if (size < 4) || (size > offsetForLeaderEpochMaxResponse) {
+		return nil, errors.New("bad OffsetForLeaderEpoch response size")
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return nil, err
+	}
+	if int32(binary.BigEndian.Uint32(payload)) != correlationID {
+		return nil, errors.New("OffsetForLeaderEpoch response has the wrong correlation ID")
+	}
+	return decodeOffsetForLeaderEpochResponse(version, bytes.NewBuffer(payload[4:]))
+}
+
+// encodeOffsetForLeaderEpochRequest returns the request, with the request header and the size prefix. Topics and
+// partitions are encoded in order, so the request is the same for the same input.
+func encodeOffsetForLeaderEpochRequest(version int16, correlationID int32, clientID string, request *OffsetForLeaderEpochRequest) []byte {
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.BigEndian, int16(offsetForLeaderEpochKey))
+	binary.Write(buf, binary.BigEndian, version)
+	binary.Write(buf, binary.BigEndian, correlationID)
+	writeKafkaString(buf, clientID)
+
+	topics := make([]string, 0, len(request.LeaderEpochs))
+	for topic := range request.LeaderEpochs {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	binary.Write(buf, binary.BigEndian, int32(len(topics)))
+	for _, topic := range topics {
+		writeKafkaString(buf, topic)
+		partitions := make([]int32, 0, len(request.LeaderEpochs[topic]))
+		for partition := range request.LeaderEpochs[topic] {
+			partitions = append(partitions, partition)
+		}
+		sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+		binary.Write(buf, binary.BigEndian, int32(len(partitions)))
+		for _, partition := range partitions {
+			binary.Write(buf, binary.BigEndian, partition)
+			if version >= 2 {
+				// The current leader epoch is not known, so the broker does not fence the request
+				binary.Write(buf, binary.BigEndian, int32(-1))
+			}
+			binary.Write(buf, binary.BigEndian, request.LeaderEpochs[topic][partition])
+		}
+	}
+
+	framed := make([]byte, 4, 4+buf.Len())
+	binary.BigEndian.PutUint32(framed, uint32(buf.Len()))
+	return append(framed, buf.Bytes()...)
+}
+
+// decodeOffsetForLeaderEpochResponse parses the response body, after the correlation ID. The leader epoch of each
+// partition is -1 for version 0, which does not return it.
+func decodeOffsetForLeaderEpochResponse(version int16, buf *bytes.Buffer) (*OffsetForLeaderEpochResponse, error) {
+	if version >= 2 {
+		var throttleTime int32
+		if err := binary.Read(buf, binary.BigEndian, &throttleTime); err != nil {
+			return nil, err
+		}
+	}
+
+	var topicCount int32
+	if err := binary.Read(buf, binary.BigEndian, &topicCount); err != nil {
+		return nil, err
+	}
+	if (topicCount < 0) || (int(topicCount) > buf.Len()) {
+		return nil, errors.New("bad OffsetForLeaderEpoch response topic count")
+	}
+	response := &OffsetForLeaderEpochResponse{EndOffsets: make(map[string]map[int32]*EpochEndOffset, topicCount)}
+	for i := int32(0); i < topicCount; i++ {
+		topic, err := readKafkaString(buf)
+		if err != nil {
+			return nil, err
+		}
+		var partitionCount int32
+		if err := binary.Read(buf, binary.BigEndian, &partitionCount); err != nil {
+			return nil, err
+		}
+		if (partitionCount < 0) || (int(partitionCount) > buf.Len()) {
+			return nil, errors.New("bad OffsetForLeaderEpoch response partition count")
+		}
+		response.EndOffsets[topic] = make(map[int32]*EpochEndOffset, partitionCount)
+		for j := int32(0); j < partitionCount; j++ {
+			var errorCode int16
+			var partition int32
+			endOffset := &EpochEndOffset{LeaderEpoch: -1}
+			if err := binary.Read(buf, binary.BigEndian, &errorCode); err != nil {
+				return nil, err
+			}
+			if err := binary.Read(buf, binary.BigEndian, &partition); err != nil {
+				return nil, err
+			}
+			if version >= 1 {
+				if err := binary.Read(buf, binary.BigEndian, &endOffset.LeaderEpoch); err != nil {
+					return nil, err
+				}
+			}
+			if err := binary.Read(buf, binary.BigEndian, &endOffset.EndOffset); err != nil {
+				return nil, err
+			}
+			endOffset.Err = sarama.KError(errorCode)
+			response.EndOffsets[topic][partition] = endOffset
+		}
+	}
+	return response, nil
+}
+
+func writeKafkaString(buf *bytes.Buffer, value string) {
+	binary.Write(buf, binary.BigEndian, int16(len(value)))
+	buf.WriteString(value)
+}
+
+func readKafkaString(buf *bytes.Buffer) (string, error) {
+	var length int16
+	if err := binary.Read(buf, binary.BigEndian, &length); err != nil {
+		return "", err
+	}
+	if (length < 0) || (int(length) > buf.Len()) {
+		return "", errors.New("bad string length in OffsetForLeaderEpoch response")
+	}
+	return string(buf.Next(int(length))), nil
+}
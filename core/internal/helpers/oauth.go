@@ -0,0 +1,89 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	sarama "github.com/IBM/sarama"
+)
+
+// oauthTokenProvider is a sarama.AccessTokenProvider that fetches tokens from an OAuth 2.0 token endpoint using the
+// client credentials grant. Tokens are cached until they are within refreshSkew of their expiry.
+type oauthTokenProvider struct {
+	tokenURL, clientID, clientSecret string
+	scopes                           []string
+	refreshSkew                      time.Duration
+	httpClient                       *http.Client
+
+	lock   sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// oauthTokenResponse is the subset of the token endpoint response that is used
+type oauthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// Token returns the cached token if it is still valid, and requests a new one from the token endpoint otherwise. It is
+// safe to call from multiple goroutines.
+func (p *oauthTokenProvider) Token() (*sarama.AccessToken, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.token != "" && time.Now().Add(p.refreshSkew).Before(p.expiry) {
+		return &sarama.AccessToken{Token: p.token}, nil
+	}
+
+	response, err := p.requestToken()
+	if err != nil {
+		return nil, err
+	}
+
+	// A token without an expiry is used once and not cached
+	p.token = ""
+	if response.ExpiresIn > 0 {
+		p.token = response.AccessToken
+		p.expiry = time.Now().Add(time.Duration(response.ExpiresIn) * time.Second)
+	}
+	return &sarama.AccessToken{Token: response.AccessToken}, nil
+}
+
+func (p *oauthTokenProvider) requestToken() (*oauthTokenResponse, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(p.scopes) > 0 {
+		form.Set("scope", strings.Join(p.scopes, " "))
+	}
+	req, err := http.NewRequest("POST", p.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(p.clientID), url.QueryEscape(p.clientSecret))
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint %s returned status %d", p.tokenURL, resp.StatusCode)
+	}
+
+	response := &oauthTokenResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return nil, fmt.Errorf("cannot decode response from token endpoint %s: %v", p.tokenURL, err)
+	}
+	if response.AccessToken == "" {
+		return nil, fmt.Errorf("token endpoint %s returned no access_token", p.tokenURL)
+	}
+	return response, nil
+}
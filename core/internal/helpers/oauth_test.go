@@ -0,0 +1,115 @@
+package helpers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sarama "github.com/IBM/sarama"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// fixtureOAuthServer returns a token endpoint that checks the client credentials request and counts how many times it
+// has been called
+func fixtureOAuthServer(t *testing.T, calls *int, expiresIn int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		assert.Equal(t, "POST", r.Method)
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+		assert.Equal(t, "kafka read", r.PostForm.Get("scope"))
+
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "burrow" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"tok-oauth","token_type":"Bearer","expires_in":%d}`, expiresIn)
+	}))
+}
+
+func fixtureOAuthProvider(url string) *oauthTokenProvider {
+	return &oauthTokenProvider{
+		tokenURL:     url,
+		clientID:     "burrow",
+		clientSecret: "secret",
+		scopes:       []string{"kafka", "read"},
+		refreshSkew:  60 * time.Second,
+		httpClient:   &http.Client{Timeout: time.Second},
+	}
+}
+
+func TestOAuthTokenProvider_CachesToken(t *testing.T) {
+	calls := 0
+	server := fixtureOAuthServer(t, &calls, 3600)
+	defer server.Close()
+
+	provider := fixtureOAuthProvider(server.URL)
+	for i := 0; i < 2; i++ {
+		token, err := provider.Token()
+		assert.NoError(t, err)
+		assert.Equal(t, "tok-oauth", token.Token)
+	}
+	assert.Equal(t, 1, calls, "Expected the token endpoint to be called once")
+}
+
+func TestOAuthTokenProvider_RefreshesWithinSkew(t *testing.T) {
+	calls := 0
+	server := fixtureOAuthServer(t, &calls, 30)
+	defer server.Close()
+
+	provider := fixtureOAuthProvider(server.URL)
+	for i := 0; i < 2; i++ {
+		_, err := provider.Token()
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 2, calls, "Expected the token endpoint to be called for each token")
+}
+
+func TestOAuthTokenProvider_BadCredentials(t *testing.T) {
+	calls := 0
+	server := fixtureOAuthServer(t, &calls, 3600)
+	defer server.Close()
+
+	provider := fixtureOAuthProvider(server.URL)
+	provider.clientSecret = "wrong"
+	token, err := provider.Token()
+	assert.Nil(t, token)
+	assert.EqualError(t, err, "token endpoint "+server.URL+" returned status 401")
+}
+
+func TestOAuthTokenProvider_BadResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"token_type":"Bearer"}`))
+	}))
+	defer server.Close()
+
+	token, err := fixtureOAuthProvider(server.URL).Token()
+	assert.Nil(t, token)
+	assert.EqualError(t, err, "token endpoint "+server.URL+" returned no access_token")
+}
+
+func TestGetSaramaConfigFromClientProfile_OAuthBearer(t *testing.T) {
+	viper.Reset()
+	viper.Set("client-profile.test.sasl", "saslprofile")
+	viper.Set("sasl.saslprofile.mechanism", "OAUTHBEARER")
+	assert.PanicsWithValue(t, "sasl.saslprofile: token-url is required for OAUTHBEARER",
+		func() { GetSaramaConfigFromClientProfile("test") })
+
+	viper.Set("sasl.saslprofile.token-url", "https://idp.example.com/token")
+	viper.Set("sasl.saslprofile.client-id", "burrow")
+	viper.Set("sasl.saslprofile.client-secret", "secret")
+	saramaConfig := GetSaramaConfigFromClientProfile("test")
+	assert.True(t, saramaConfig.Net.SASL.Enable)
+	assert.Equal(t, sarama.SASLMechanism(sarama.SASLTypeOAuth), saramaConfig.Net.SASL.Mechanism)
+
+	provider := saramaConfig.Net.SASL.TokenProvider.(*oauthTokenProvider)
+	assert.Equal(t, "https://idp.example.com/token", provider.tokenURL)
+	assert.Equal(t, "burrow", provider.clientID)
+	assert.Equal(t, "secret", provider.clientSecret)
+	assert.Equal(t, 60*time.Second, provider.refreshSkew)
+}
@@ -12,14 +12,21 @@ package helpers
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
 	"github.com/IBM/sarama"
+	"github.com/spf13/cast"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/mock"
 )
@@ -47,18 +54,175 @@ var legacyKafkaVersionFallback = map[string]sarama.KafkaVersion{
 	"0.11":   sarama.V0_11_0_0,
 }
 
-func parseKafkaVersion(kafkaVersion string) sarama.KafkaVersion {
+// getTimeout reads a timeout from the client profile. An integer is a number of seconds, which is how timeouts were
+// configured before duration strings were accepted. Anything else must be a duration such as "500ms" or "3s".
+func getTimeout(config *viper.Viper, configRoot, profileName, key string) (time.Duration, error) {
+	if seconds, err := cast.ToIntE(config.Get(configRoot + "." + key)); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	timeout, err := time.ParseDuration(config.GetString(configRoot + "." + key))
+	if err != nil {
+		return 0, errors.New("client-profile '" + profileName + "' has an invalid " + key + ": " + err.Error())
+	}
+	return timeout, nil
+}
+
+// defaultKafkaVersion is used when a client profile does not set kafka-version, or sets it to "auto" and the version
+// cannot be detected
+const defaultKafkaVersion = "2.8.0"
+
+func parseKafkaVersion(kafkaVersion string) (sarama.KafkaVersion, error) {
 	version, err := sarama.ParseKafkaVersion(kafkaVersion)
 	if err != nil {
 		// try find the version in the legacy matching
 		version1, ok := legacyKafkaVersionFallback[kafkaVersion]
 		if !ok {
-			panic("Unknown Kafka Version: " + kafkaVersion)
+			return sarama.KafkaVersion{}, errors.New("Unknown Kafka Version: " + kafkaVersion)
 		}
 		version = version1
 	}
 
-	return version
+	return version, nil
+}
+
+// loadTLSProfilePEM returns the PEM content for one part (CA, certificate, or key) of the named tls profile, and whether
+// it came from an inline value. The inline key (such as certpem) holds the PEM content directly, or "env:NAME" to read
+// it from the NAME environment variable, and takes precedence over the file key (such as certfile). If neither is set,
+// nil is returned.
+func loadTLSProfilePEM(config *viper.Viper, tlsName, inlineKey, fileKey string) ([]byte, bool, error) {
+	configRoot := "tls." + tlsName
+	if inline := config.GetString(configRoot + "." + inlineKey); inline != "" {
+		if strings.HasPrefix(inline, "env:") {
+			envName := strings.TrimPrefix(inline, "env:")
+			inline = os.Getenv(envName)
+			if inline == "" {
+				return nil, true, errors.New("tls profile '" + tlsName + "' " + inlineKey + " references an empty environment variable " + envName)
+			}
+		}
+		return []byte(inline), true, nil
+	}
+
+	filename := config.GetString(configRoot + "." + fileKey)
+	if filename == "" {
+		return nil, false, nil
+	}
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		if fileKey == "cafile" {
+			return nil, false, errors.New("cannot read TLS CA file: " + err.Error())
+		}
+		return nil, false, errors.New("cannot read TLS certificate or key file: " + err.Error())
+	}
+	return content, false, nil
+}
+
+// NewTLSProfileConfig returns a tls.Config for a client from the named tls profile. The CA certificate (or the system
+// roots, if use-system-roots is set) is used to verify the server, the certificate and key, if both are set, are
+// presented to it, and the protocol versions, cipher suites, and noverify setting of the profile are applied. An error
+// is returned if any of the PEM content cannot be read or is invalid.
+func NewTLSProfileConfig(config *viper.Viper, tlsName string) (*tls.Config, error) {
+	var tlsConfig *tls.Config
+	caCert, caInline, err := loadTLSProfilePEM(config, tlsName, "capem", "cafile")
+	if err != nil {
+		return nil, err
+	}
+	certPEM, certInline, err := loadTLSProfilePEM(config, tlsName, "certpem", "certfile")
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, keyInline, err := loadTLSProfilePEM(config, tlsName, "keypem", "keyfile")
+	if err != nil {
+		return nil, err
+	}
+
+	useSystemRoots := config.GetBool("tls." + tlsName + ".use-system-roots")
+	if caCert == nil && !useSystemRoots {
+		tlsConfig = &tls.Config{}
+	} else {
+		caCertPool := x509.NewCertPool()
+		if useSystemRoots {
+			systemPool, err := x509.SystemCertPool()
+			if err != nil {
+				zap.L().Warn("cannot load system cert pool, using only the configured CA",
+					zap.String("tls", tlsName),
+					zap.Error(err),
+				)
+			} else {
+				caCertPool = systemPool
+			}
+		}
+		if caCert != nil && !caCertPool.AppendCertsFromPEM(caCert) && caInline {
+			return nil, errors.New("tls profile '" + tlsName + "' has an invalid inline CA PEM")
+		}
+		tlsConfig = &tls.Config{
+			RootCAs: caCertPool,
+		}
+	}
+
+	if certPEM != nil && keyPEM != nil {
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			if certInline || keyInline {
+				return nil, errors.New("tls profile '" + tlsName + "' has an invalid inline certificate or key PEM: " + err.Error())
+			}
+			return nil, errors.New("cannot read TLS certificate or key file: " + err.Error())
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if err := applyTLSProfileConstraints(config, tlsConfig, tlsName); err != nil {
+		return nil, err
+	}
+	tlsConfig.InsecureSkipVerify = config.GetBool("tls." + tlsName + ".noverify")
+	return tlsConfig, nil
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// applyTLSProfileConstraints sets the protocol versions and cipher suites from the min-version, max-version, and
+// cipher-suites keys of the named tls profile on the given tls.Config. Keys that are not set leave the Go defaults.
+func applyTLSProfileConstraints(config *viper.Viper, tlsConfig *tls.Config, tlsName string) error {
+	configRoot := "tls." + tlsName
+	for _, key := range []string{"min-version", "max-version"} {
+		versionName := config.GetString(configRoot + "." + key)
+		if versionName == "" {
+			continue
+		}
+		version, ok := tlsVersions[versionName]
+		if !ok {
+			return errors.New("tls profile '" + tlsName + "' has an unknown " + key + " '" + versionName + "'")
+		}
+		if key == "min-version" {
+			tlsConfig.MinVersion = version
+		} else {
+			tlsConfig.MaxVersion = version
+		}
+	}
+	if tlsConfig.MinVersion != 0 && tlsConfig.MaxVersion != 0 && tlsConfig.MinVersion > tlsConfig.MaxVersion {
+		return errors.New("tls profile '" + tlsName + "' has a min-version greater than its max-version")
+	}
+
+	cipherNames := config.GetStringSlice(configRoot + ".cipher-suites")
+	if len(cipherNames) == 0 {
+		return nil
+	}
+	cipherSuites := make(map[string]uint16)
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		cipherSuites[suite.Name] = suite.ID
+	}
+	tlsConfig.CipherSuites = make([]uint16, 0, len(cipherNames))
+	for _, name := range cipherNames {
+		id, ok := cipherSuites[name]
+		if !ok {
+			return errors.New("tls profile '" + tlsName + "' has an unknown cipher suite '" + name + "'")
+		}
+		tlsConfig.CipherSuites = append(tlsConfig.CipherSuites, id)
+	}
+	return nil
 }
 
 // GetSaramaConfigFromClientProfile takes the name of a client-profile configuration entry and returns a sarama.Config
@@ -66,109 +230,377 @@ func parseKafkaVersion(kafkaVersion string) sarama.KafkaVersion {
 // client ID, TLS, and SASL configs. If there is any error in the configuration, such as a bad TLS certificate file,
 // this func will panic as it is normally called when configuring modules.
 func GetSaramaConfigFromClientProfile(profileName string) *sarama.Config {
+	saramaConfig, err := GetSaramaConfigFromClientProfileE(profileName)
+	if err != nil {
+		panic(err.Error())
+	}
+	return saramaConfig
+}
+
+// GetSaramaConfigFromClientProfileE is the same as GetSaramaConfigFromClientProfile, except that any error in the
+// configuration is returned to the caller instead of causing a panic.
+func GetSaramaConfigFromClientProfileE(profileName string) (*sarama.Config, error) {
+	return getSaramaConfig(viper.GetViper(), profileName)
+}
+
+// GetSaramaConfigFromClientProfileIn is the same as GetSaramaConfigFromClientProfile, except that the client profile,
+// and the tls, sasl, and iam profiles that it uses, are read from the provided viper instance instead of the global
+// configuration. The defaults for the profiles are also set on that instance.
+func GetSaramaConfigFromClientProfileIn(config *viper.Viper, profileName string) *sarama.Config {
+	saramaConfig, err := getSaramaConfig(config, profileName)
+	if err != nil {
+		panic(err.Error())
+	}
+	return saramaConfig
+}
+
+func getSaramaConfig(config *viper.Viper, profileName string) (*sarama.Config, error) {
 	// Set config root and defaults
 	configRoot := "client-profile." + profileName
-	if (profileName != "") && (!viper.IsSet("client-profile." + profileName)) {
-		panic("unknown client-profile '" + profileName + "'")
+	if (profileName != "") && (!config.IsSet("client-profile." + profileName)) {
+		return nil, errors.New("unknown client-profile '" + profileName + "'")
 	}
 
-	viper.SetDefault(configRoot+".client-id", "burrow-lagchecker")
-	viper.SetDefault(configRoot+".kafka-version", "2.8.0")
+	config.SetDefault(configRoot+".client-id", "burrow-lagchecker")
+	config.SetDefault(configRoot+".kafka-version", defaultKafkaVersion)
+
+	// An override is handed straight to Sarama, skipping the legacy version fallbacks
+	var version sarama.KafkaVersion
+	var err error
+	if override := config.GetString(configRoot + ".kafka-version-override"); override != "" {
+		version, err = sarama.ParseKafkaVersion(override)
+		if err != nil {
+			return nil, errors.New("invalid kafka-version-override for client-profile '" + profileName + "': " + err.Error())
+		}
+	} else {
+		// With "auto", the default is used until the version is detected from the brokers by DetectKafkaVersion
+		kafkaVersion := config.GetString(configRoot + ".kafka-version")
+		if kafkaVersion == "auto" {
+			kafkaVersion = defaultKafkaVersion
+		}
+		version, err = parseKafkaVersion(kafkaVersion)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	saramaConfig := sarama.NewConfig()
-	saramaConfig.ClientID = viper.GetString(configRoot + ".client-id")
-	saramaConfig.Version = parseKafkaVersion(viper.GetString(configRoot + ".kafka-version"))
+	saramaConfig.ClientID = config.GetString(configRoot + ".client-id")
+	saramaConfig.Version = version
 	saramaConfig.Consumer.Return.Errors = true
 
 	// Configure TLS if enabled
-	if viper.IsSet(configRoot + ".tls") {
-		tlsName := viper.GetString(configRoot + ".tls")
+	if config.IsSet(configRoot + ".tls") {
+		tlsName := config.GetString(configRoot + ".tls")
 
 		saramaConfig.Net.TLS.Enable = true
-		certFile := viper.GetString("tls." + tlsName + ".certfile")
-		keyFile := viper.GetString("tls." + tlsName + ".keyfile")
-		caFile := viper.GetString("tls." + tlsName + ".cafile")
-
-		if caFile == "" {
-			saramaConfig.Net.TLS.Config = &tls.Config{}
-		} else {
-			caCert, err := os.ReadFile(caFile)
-			if err != nil {
-				panic("cannot read TLS CA file: " + err.Error())
-			}
-			caCertPool := x509.NewCertPool()
-			caCertPool.AppendCertsFromPEM(caCert)
-			saramaConfig.Net.TLS.Config = &tls.Config{
-				RootCAs: caCertPool,
-			}
-
-			if certFile != "" && keyFile != "" {
-				cert, err := tls.LoadX509KeyPair(certFile, keyFile)
-				if err != nil {
-					panic("cannot read TLS certificate or key file: " + err.Error())
-				}
-				saramaConfig.Net.TLS.Config.Certificates = []tls.Certificate{cert}
-			}
+		tlsConfig, err := NewTLSProfileConfig(config, tlsName)
+		if err != nil {
+			return nil, err
+		}
+		saramaConfig.Net.TLS.Config = tlsConfig
+		// The client profile can override the verification setting of the shared tls profile
+		if config.IsSet(configRoot + ".tls-noverify") {
+			saramaConfig.Net.TLS.Config.InsecureSkipVerify = config.GetBool(configRoot + ".tls-noverify")
 		}
-		saramaConfig.Net.TLS.Config.InsecureSkipVerify = viper.GetBool("tls." + tlsName + ".noverify")
 	}
 
 	// Configure SASL if enabled
-	if viper.IsSet(configRoot + ".sasl") {
-		saslName := viper.GetString(configRoot + ".sasl")
+	if config.IsSet(configRoot + ".sasl") {
+		saslName := config.GetString(configRoot + ".sasl")
 
 		saramaConfig.Net.SASL.Enable = true
-		mechanism := viper.GetString("sasl." + saslName + ".mechanism")
+		mechanism := config.GetString("sasl." + saslName + ".mechanism")
+		iterations := config.GetInt("sasl." + saslName + ".scram-iterations")
 		if mechanism == "SCRAM-SHA-256" {
 			saramaConfig.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
 			saramaConfig.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
-				return &XDGSCRAMClient{HashGeneratorFcn: SHA256}
+				return &XDGSCRAMClient{HashGeneratorFcn: SHA256, MinIterations: iterations}
 			}
 		} else if mechanism == "SCRAM-SHA-512" {
 			saramaConfig.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
 			saramaConfig.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
-				return &XDGSCRAMClient{HashGeneratorFcn: SHA512}
+				return &XDGSCRAMClient{HashGeneratorFcn: SHA512, MinIterations: iterations}
+			}
+		} else if mechanism == "OAUTHBEARER" {
+			saslRoot := "sasl." + saslName
+			tokenURL := config.GetString(saslRoot + ".token-url")
+			if tokenURL == "" {
+				return nil, fmt.Errorf("sasl.%s: token-url is required for OAUTHBEARER", saslName)
+			}
+			config.SetDefault(saslRoot+".refresh-skew", 60)
+			saramaConfig.Net.SASL.Mechanism = sarama.SASLTypeOAuth
+			saramaConfig.Net.SASL.TokenProvider = &oauthTokenProvider{
+				tokenURL:     tokenURL,
+				clientID:     config.GetString(saslRoot + ".client-id"),
+				clientSecret: config.GetString(saslRoot + ".client-secret"),
+				scopes:       config.GetStringSlice(saslRoot + ".scopes"),
+				refreshSkew:  time.Duration(config.GetInt(saslRoot+".refresh-skew")) * time.Second,
+				httpClient:   &http.Client{Timeout: 10 * time.Second},
+			}
+		} else if mechanism == "GSSAPI" {
+			gssapiConfig, err := getGSSAPIConfig(config, saslName)
+			if err != nil {
+				return nil, err
+			}
+			saramaConfig.Net.SASL.Mechanism = sarama.SASLTypeGSSAPI
+			saramaConfig.Net.SASL.GSSAPI = *gssapiConfig
+		}
+		saramaConfig.Net.SASL.Handshake = config.GetBool("sasl." + saslName + ".handshake-first")
+		saramaConfig.Net.SASL.User = config.GetString("sasl." + saslName + ".username")
+		saramaConfig.Net.SASL.Password = config.GetString("sasl." + saslName + ".password")
+
+		// The handshake version is left at the Sarama default unless explicitly configured
+		if config.IsSet("sasl." + saslName + ".version") {
+			switch version := config.GetInt("sasl." + saslName + ".version"); version {
+			case 0:
+				saramaConfig.Net.SASL.Version = sarama.SASLHandshakeV0
+			case 1:
+				saramaConfig.Net.SASL.Version = sarama.SASLHandshakeV1
+			default:
+				return nil, fmt.Errorf("sasl.%s: version must be 0 or 1, not %d", saslName, version)
 			}
 		}
-		saramaConfig.Net.SASL.Handshake = viper.GetBool("sasl." + saslName + ".handshake-first")
-		saramaConfig.Net.SASL.User = viper.GetString("sasl." + saslName + ".username")
-		saramaConfig.Net.SASL.Password = viper.GetString("sasl." + saslName + ".password")
 	}
 
-	if iamName := viper.GetString(configRoot + ".iam"); iamName != "" {
+	if iamName := config.GetString(configRoot + ".iam"); iamName != "" {
 		iamRoot := "iam." + iamName
-		region := viper.GetString(iamRoot + ".region")
+		region := config.GetString(iamRoot + ".region")
 		if region == "" {
-			panic(fmt.Sprintf("iam.%s: region is required", iamName))
+			return nil, fmt.Errorf("iam.%s: region is required", iamName)
 		}
 
 		// IAM auth *requires* TLS
 		if !saramaConfig.Net.TLS.Enable {
-			panic(fmt.Sprintf("client-profile %s uses iam.%s but has no tls profile",
-				profileName, iamName))
+			return nil, fmt.Errorf("client-profile %s uses iam.%s but has no tls profile",
+				profileName, iamName)
+		}
+
+		config.SetDefault(iamRoot+".credentials-source", "default")
+		config.SetDefault(iamRoot+".refresh-skew", 60)
+		provider := &iamTokenProvider{
+			region:            region,
+			roleArn:           config.GetString(iamRoot + ".role-arn"),
+			profile:           config.GetString(iamRoot + ".profile"),
+			credentialsSource: config.GetString(iamRoot + ".credentials-source"),
+			refreshSkew:       time.Duration(config.GetInt(iamRoot+".refresh-skew")) * time.Second,
+		}
+		switch provider.credentialsSource {
+		case "default":
+		case "static":
+			provider.accessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+			provider.secretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+			provider.sessionToken = os.Getenv("AWS_SESSION_TOKEN")
+			if provider.accessKeyID == "" || provider.secretAccessKey == "" {
+				return nil, fmt.Errorf("iam.%s: credentials-source static requires the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables", iamName)
+			}
+		case "profile":
+			if provider.profile == "" {
+				return nil, fmt.Errorf("iam.%s: credentials-source profile requires profile to be set", iamName)
+			}
+		case "role":
+			if provider.roleArn == "" {
+				return nil, fmt.Errorf("iam.%s: credentials-source role requires role-arn to be set", iamName)
+			}
+		default:
+			return nil, fmt.Errorf("iam.%s: unknown credentials-source '%s'", iamName, provider.credentialsSource)
 		}
 
 		saramaConfig.Net.SASL.Enable = true
 		saramaConfig.Net.SASL.Handshake = true
 		saramaConfig.Net.SASL.Mechanism = sarama.SASLTypeOAuth
-		saramaConfig.Net.SASL.TokenProvider = &iamTokenProvider{
-			region:  region,
-			roleArn: viper.GetString(iamRoot + ".role-arn"),
-			profile: viper.GetString(iamRoot + ".profile"),
+		saramaConfig.Net.SASL.TokenProvider = provider
+	}
+
+	// Timeouts for the initial connection, a request's response, and writing a request
+	for _, timeout := range []struct {
+		key   string
+		value *time.Duration
+	}{
+		{"dial-timeout", &saramaConfig.Net.DialTimeout},
+		{"read-timeout", &saramaConfig.Net.ReadTimeout},
+		{"write-timeout", &saramaConfig.Net.WriteTimeout},
+	} {
+		if config.IsSet(configRoot + "." + timeout.key) {
+			*timeout.value, err = getTimeout(config, configRoot, profileName, timeout.key)
+			if err != nil {
+				return nil, err
+			}
 		}
 	}
 
-	// Timeout for the initial connection
-	if viper.IsSet(configRoot + ".dial-timeout") {
-		saramaConfig.Net.DialTimeout = time.Duration(viper.GetInt(configRoot+".dial-timeout")) * time.Second
+	// Number of requests that may be in flight on a single broker connection
+	if config.IsSet(configRoot + ".max-open-requests") {
+		saramaConfig.Net.MaxOpenRequests = config.GetInt(configRoot + ".max-open-requests")
 	}
 
-	// Timeout for a request's response
-	if viper.IsSet(configRoot + ".read-timeout") {
-		saramaConfig.Net.ReadTimeout = time.Duration(viper.GetInt(configRoot+".read-timeout")) * time.Second
+	// TCP keepalive period for broker connections
+	if config.IsSet(configRoot + ".keepalive") {
+		saramaConfig.Net.KeepAlive = time.Duration(config.GetInt(configRoot+".keepalive")) * time.Second
 	}
 
-	return saramaConfig
+	// Number of times, and how long to wait between attempts, to retry a failed metadata request
+	if config.IsSet(configRoot + ".metadata-retry-max") {
+		saramaConfig.Metadata.Retry.Max = config.GetInt(configRoot + ".metadata-retry-max")
+	}
+	if config.IsSet(configRoot + ".metadata-retry-backoff") {
+		saramaConfig.Metadata.Retry.Backoff = time.Duration(config.GetInt(configRoot+".metadata-retry-backoff")) * time.Millisecond
+	}
+
+	// How long a partition consumer waits before reading a partition again after it fails, such as when its leader is
+	// unavailable. This does not affect the dial-timeout, or the metadata requests that find the new leader. It can also
+	// be set as connect-backoff, but consumer-retry-backoff takes precedence if both are set
+	for _, key := range []string{"connect-backoff", "consumer-retry-backoff"} {
+		if config.IsSet(configRoot + "." + key) {
+			saramaConfig.Consumer.Retry.Backoff = time.Duration(config.GetInt(configRoot+"."+key)) * time.Millisecond
+		}
+	}
+
+	// How often the client refreshes cluster metadata in the background
+	if config.IsSet(configRoot + ".metadata-refresh") {
+		saramaConfig.Metadata.RefreshFrequency = time.Duration(config.GetInt(configRoot+".metadata-refresh")) * time.Second
+	}
+
+	// Whether to refresh metadata for all topics, or only the ones the client has used
+	if config.IsSet(configRoot + ".metadata-full") {
+		saramaConfig.Metadata.Full = config.GetBool(configRoot + ".metadata-full")
+	}
+
+	// Producer settings, for modules that write to Kafka
+	if err := applyProducerSettings(config, saramaConfig, configRoot, profileName); err != nil {
+		return nil, err
+	}
+
+	// Overrides are applied last, so they take precedence over everything above
+	if err := applySaramaOverrides(saramaConfig, profileName, config.GetStringMap(configRoot+".sarama-overrides")); err != nil {
+		return nil, err
+	}
+
+	return saramaConfig, nil
+}
+
+var producerAcks = map[string]sarama.RequiredAcks{
+	"none":   sarama.NoResponse,
+	"0":      sarama.NoResponse,
+	"leader": sarama.WaitForLocal,
+	"1":      sarama.WaitForLocal,
+	"all":    sarama.WaitForAll,
+	"-1":     sarama.WaitForAll,
+}
+
+// applyProducerSettings sets the producer-acks, producer-idempotent, and producer-compression keys of the client
+// profile on the sarama.Config. An idempotent producer defaults to waiting for all replicas and a single open request
+// per broker, as Sarama requires.
+func applyProducerSettings(config *viper.Viper, saramaConfig *sarama.Config, configRoot, profileName string) error {
+	if config.IsSet(configRoot + ".producer-acks") {
+		acks, ok := producerAcks[config.GetString(configRoot+".producer-acks")]
+		if !ok {
+			return errors.New("client-profile '" + profileName + "' has an unknown producer-acks '" + config.GetString(configRoot+".producer-acks") + "' (must be none, leader, or all)")
+		}
+		saramaConfig.Producer.RequiredAcks = acks
+	}
+
+	if config.IsSet(configRoot + ".producer-compression") {
+		codecName := config.GetString(configRoot + ".producer-compression")
+		if err := saramaConfig.Producer.Compression.UnmarshalText([]byte(codecName)); err != nil {
+			return errors.New("client-profile '" + profileName + "' has an unknown producer-compression '" + codecName + "'")
+		}
+	}
+
+	if config.GetBool(configRoot + ".producer-idempotent") {
+		saramaConfig.Producer.Idempotent = true
+		if !config.IsSet(configRoot + ".producer-acks") {
+			saramaConfig.Producer.RequiredAcks = sarama.WaitForAll
+		} else if saramaConfig.Producer.RequiredAcks != sarama.WaitForAll {
+			return errors.New("client-profile '" + profileName + "' has producer-idempotent set, which requires producer-acks to be all")
+		}
+		if !config.IsSet(configRoot + ".max-open-requests") {
+			saramaConfig.Net.MaxOpenRequests = 1
+		}
+	}
+	return nil
+}
+
+// flattenOverrides turns a nested map of overrides, as viper returns them, into a map of dotted field paths to values
+func flattenOverrides(prefix string, overrides map[string]interface{}, flat map[string]interface{}) {
+	for key, value := range overrides {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			flattenOverrides(path, nested, flat)
+		} else {
+			flat[path] = value
+		}
+	}
+}
+
+// applySaramaOverrides sets each dotted field path in overrides (such as "Net.MaxOpenRequests") on the sarama.Config.
+// Field names are matched without regard to case, as viper lowercases all keys. Only fields with a boolean, numeric,
+// string, or time.Duration type can be set.
+func applySaramaOverrides(saramaConfig *sarama.Config, profileName string, overrides map[string]interface{}) error {
+	flat := make(map[string]interface{})
+	flattenOverrides("", overrides, flat)
+
+	for path, value := range flat {
+		field := reflect.ValueOf(saramaConfig).Elem()
+		for _, name := range strings.Split(path, ".") {
+			if field.Kind() != reflect.Struct {
+				field = reflect.Value{}
+				break
+			}
+			field = field.FieldByNameFunc(func(fieldName string) bool {
+				return strings.EqualFold(fieldName, name)
+			})
+			if !field.IsValid() {
+				break
+			}
+		}
+		if !field.IsValid() || !field.CanSet() {
+			return errors.New("client-profile '" + profileName + "' has an unknown sarama-overrides path '" + path + "'")
+		}
+
+		var err error
+		switch {
+		case field.Type() == reflect.TypeOf(time.Duration(0)):
+			var duration time.Duration
+			if duration, err = cast.ToDurationE(value); err == nil {
+				field.SetInt(int64(duration))
+			}
+		case field.Kind() == reflect.Bool:
+			var b bool
+			if b, err = cast.ToBoolE(value); err == nil {
+				field.SetBool(b)
+			}
+		case field.Kind() >= reflect.Int && field.Kind() <= reflect.Int64:
+			var i int64
+			if i, err = cast.ToInt64E(value); err == nil {
+				field.SetInt(i)
+			}
+		case field.Kind() >= reflect.Uint && field.Kind() <= reflect.Uint64:
+			var u uint64
+			if u, err = cast.ToUint64E(value); err == nil {
+				field.SetUint(u)
+			}
+		case field.Kind() == reflect.Float32 || field.Kind() == reflect.Float64:
+			var f float64
+			if f, err = cast.ToFloat64E(value); err == nil {
+				field.SetFloat(f)
+			}
+		case field.Kind() == reflect.String:
+			var str string
+			if str, err = cast.ToStringE(value); err == nil {
+				field.SetString(str)
+			}
+		default:
+			return errors.New("client-profile '" + profileName + "' sarama-overrides path '" + path + "' is not a settable value")
+		}
+		if err != nil {
+			return errors.New("client-profile '" + profileName + "' has a bad value for sarama-overrides path '" + path + "': " + err.Error())
+		}
+	}
+	return nil
 }
 
 // SaramaClient is an internal interface to the sarama.Client. We use our own interface because while sarama.Client is
@@ -211,6 +643,16 @@ type SaramaClient interface {
 	// offset of the message that will be produced next, or a time.
 	GetOffset(topic string, partitionID int32, timestamp int64) (int64, error)
 
+	// GetOffsets is a batch version of GetOffset. It takes a map of topic to partition ID to timestamp, and returns a
+	// map of topic to partition ID to offset. The partitions are grouped by their leader broker, and a single
+	// OffsetRequest is sent to each broker. Partitions that could not be fetched are left out of the result, and the
+	// last error encountered is returned along with all the offsets that were fetched.
+	GetOffsets(requests map[string]map[int32]int64) (map[string]map[int32]int64, error)
+
+	// Controller returns the broker that is the active controller for the cluster, as determined by querying the cluster
+	// metadata. An error is returned if the cluster has no controller.
+	Controller() (SaramaBroker, error)
+
 	// Coordinator returns the coordinating broker for a consumer group. It will return a locally cached value if it's
 	// available. You can call RefreshCoordinator to update the cached value. This function only works on Kafka 0.8.2 and
 	// higher.
@@ -235,8 +677,31 @@ type SaramaClient interface {
 	// List the consumer groups available in the cluster.
 	// Returns a Map with the consumer group and consumer group type, this is
 	// used in the code as a Set, the consumer group type is not relevant, we
-	// decided to not convert it to a map[string]struct returned by Sarama
+	// decided to not convert it to a map[string]struct returned by Sarama.
+	// Each broker is asked for the groups it coordinates. If some brokers fail,
+	// the groups from the others are returned along with a *ListConsumerGroupsError.
 	ListConsumerGroups() (map[string]string, error)
+
+	// DescribeConsumerGroups asks the coordinators of the given consumer groups to describe them, and returns the
+	// number of members in each group. Groups that the coordinator returned an error for are left out of the result.
+	DescribeConsumerGroups(groups []string) (map[string]int32, error)
+
+	// DescribeConfigs returns the configuration entries for the named resource, where resourceType is one of "topic",
+	// "broker", or "broker_logger". If keys is empty, all configuration entries for the resource are returned.
+	DescribeConfigs(resourceType, name string, keys []string) (map[string]string, error)
+
+	// Healthcheck verifies that at least one broker is reachable by refreshing the cluster metadata. An error is
+	// returned if the refresh fails, or if it does not complete within a short timeout.
+	Healthcheck() error
+
+	// GetNewestOffsets returns the newest offset for every partition of the topic. Only partitions that have a leader
+	// are queried, and partitions that are offline are returned with the offset OffsetPartitionOffline.
+	GetNewestOffsets(topic string) (map[int32]int64, error)
+
+	// FetchConsumerOffsets sends an OffsetFetchRequest for the given topics and partitions to the coordinator of the
+	// consumer group, and returns the committed offsets as a map of topic to partition ID to offset. Partitions that
+	// have no committed offset are left out of the result.
+	FetchConsumerOffsets(group string, topicPartitions map[string][]int32) (map[string]map[int32]int64, error)
 }
 
 // BurrowSaramaClient is an implementation of the SaramaClient interface for use in Burrow modules
@@ -254,7 +719,7 @@ func (c *BurrowSaramaClient) Brokers() []SaramaBroker {
 	brokers := c.Client.Brokers()
 	shimBrokers := make([]SaramaBroker, len(brokers))
 	for i, broker := range brokers {
-		shimBrokers[i] = &BurrowSaramaBroker{broker}
+		shimBrokers[i] = &BurrowSaramaBroker{broker: broker, config: c.Client.Config()}
 	}
 	return shimBrokers
 }
@@ -281,7 +746,7 @@ func (c *BurrowSaramaClient) Leader(topic string, partitionID int32) (SaramaBrok
 	broker, err := c.Client.Leader(topic, partitionID)
 	var shimBroker *BurrowSaramaBroker
 	if broker != nil {
-		shimBroker = &BurrowSaramaBroker{broker}
+		shimBroker = &BurrowSaramaBroker{broker: broker, config: c.Client.Config()}
 	}
 	return shimBroker, err
 }
@@ -310,6 +775,215 @@ func (c *BurrowSaramaClient) GetOffset(topic string, partitionID int32, timestam
 	return c.Client.GetOffset(topic, partitionID, timestamp)
 }
 
+// GetOffsets is a batch version of GetOffset. It takes a map of topic to partition ID to timestamp, and returns a
+// map of topic to partition ID to offset. The partitions are grouped by their leader broker, and a single
+// OffsetRequest is sent to each broker. Partitions that could not be fetched are left out of the result, and the
+// last error encountered is returned along with all the offsets that were fetched.
+func (c *BurrowSaramaClient) GetOffsets(requests map[string]map[int32]int64) (map[string]map[int32]int64, error) {
+	return getOffsetsByLeader(c, requests)
+}
+
+// FetchConsumerOffsets sends an OffsetFetchRequest for the given topics and partitions to the coordinator of the
+// consumer group, and returns the committed offsets as a map of topic to partition ID to offset. Partitions that have
+// no committed offset are left out of the result.
+func (c *BurrowSaramaClient) FetchConsumerOffsets(group string, topicPartitions map[string][]int32) (map[string]map[int32]int64, error) {
+	return fetchConsumerOffsetsFromCoordinator(c, group, topicPartitions)
+}
+
+// fetchConsumerOffsetsFromCoordinator implements SaramaClient.FetchConsumerOffsets for any client. If any partition
+// returns an error, the last one is returned along with all the offsets that were fetched.
+func fetchConsumerOffsetsFromCoordinator(client SaramaClient, group string, topicPartitions map[string][]int32) (map[string]map[int32]int64, error) {
+	coordinator, err := client.Coordinator(group)
+	if err != nil {
+		return nil, err
+	}
+
+	request := sarama.NewOffsetFetchRequest(client.Config().Version, group, topicPartitions)
+	response, err := coordinator.FetchOffset(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.Err != sarama.ErrNoError {
+		return nil, response.Err
+	}
+
+	var lastErr error
+	results := make(map[string]map[int32]int64)
+	for topic, partitions := range response.Blocks {
+		for partitionID, block := range partitions {
+			if block.Err != sarama.ErrNoError {
+				lastErr = block.Err
+				continue
+			}
+			if block.Offset < 0 {
+				continue
+			}
+			if _, ok := results[topic]; !ok {
+				results[topic] = make(map[int32]int64)
+			}
+			results[topic][partitionID] = block.Offset
+		}
+	}
+	return results, lastErr
+}
+
+// OffsetPartitionOffline is the offset returned by GetNewestOffsets for a partition that has no leader
+const OffsetPartitionOffline int64 = -3
+
+// GetNewestOffsets returns the newest offset for every partition of the topic. Only partitions that have a leader are
+// queried, and partitions that are offline are returned with the offset OffsetPartitionOffline.
+func (c *BurrowSaramaClient) GetNewestOffsets(topic string) (map[int32]int64, error) {
+	return getNewestOffsetsForTopic(c, topic)
+}
+
+// getNewestOffsetsForTopic implements SaramaClient.GetNewestOffsets for any client.
+func getNewestOffsetsForTopic(client SaramaClient, topic string) (map[int32]int64, error) {
+	partitions, err := client.Partitions(topic)
+	if err != nil {
+		return nil, err
+	}
+	writable, err := client.WritablePartitions(topic)
+	if err != nil {
+		return nil, err
+	}
+
+	requests := make(map[int32]int64, len(writable))
+	for _, partitionID := range writable {
+		requests[partitionID] = sarama.OffsetNewest
+	}
+	offsets, err := getOffsetsByLeader(client, map[string]map[int32]int64{topic: requests})
+
+	results := make(map[int32]int64, len(partitions))
+	for _, partitionID := range partitions {
+		if _, ok := requests[partitionID]; !ok {
+			results[partitionID] = OffsetPartitionOffline
+		}
+	}
+	for partitionID, offset := range offsets[topic] {
+		results[partitionID] = offset
+	}
+	return results, err
+}
+
+// healthcheckTimeout is how long Healthcheck waits for the metadata refresh to complete
+var healthcheckTimeout = 10 * time.Second
+
+// Healthcheck verifies that at least one broker is reachable by refreshing the cluster metadata. An error is returned
+// if the refresh fails, or if it does not complete within healthcheckTimeout.
+func (c *BurrowSaramaClient) Healthcheck() error {
+	return healthcheckClient(c, healthcheckTimeout)
+}
+
+// healthcheckClient implements SaramaClient.Healthcheck for any client.
+func healthcheckClient(client SaramaClient, timeout time.Duration) error {
+	result := make(chan error, 1)
+	go func() {
+		result <- client.RefreshMetadata()
+	}()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			return errors.New("no broker responded to a metadata request: " + err.Error())
+		}
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("timed out after %v waiting for a broker to respond to a metadata request", timeout)
+	}
+}
+
+// OffsetRequestVersion returns the OffsetRequest version to use for the given Kafka version, matching what Sarama's
+// own GetOffset does (https://github.com/IBM/sarama/blob/main/client.go#L863-L876)
+func OffsetRequestVersion(version sarama.KafkaVersion) int16 {
+	switch {
+	case version.IsAtLeast(sarama.V2_1_0_0):
+		// Version 4 adds the current leader epoch, which is used for fencing.
+		return 4
+	case version.IsAtLeast(sarama.V2_0_0_0):
+		// Version 3 is the same as version 2.
+		return 3
+	case version.IsAtLeast(sarama.V0_11_0_0):
+		// Version 2 adds the isolation level, which is used for transactional reads.
+		return 2
+	case version.IsAtLeast(sarama.V0_10_1_0):
+		// Version 1 removes MaxNumOffsets. From this version forward, only a single offset can be returned.
+		return 1
+	}
+	return 0
+}
+
+// getOffsetsByLeader implements SaramaClient.GetOffsets for any client, by bucketing the requested partitions to
+// their leader brokers and sending each broker its OffsetRequest in parallel.
+func getOffsetsByLeader(client SaramaClient, requests map[string]map[int32]int64) (map[string]map[int32]int64, error) {
+	var lastErr error
+	brokerRequests := make(map[int32]*sarama.OffsetRequest)
+	brokers := make(map[int32]SaramaBroker)
+	version := OffsetRequestVersion(client.Config().Version)
+
+	for topic, partitions := range requests {
+		for partitionID, timestamp := range partitions {
+			broker, err := client.Leader(topic, partitionID)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			if _, ok := brokerRequests[broker.ID()]; !ok {
+				brokerRequests[broker.ID()] = &sarama.OffsetRequest{Version: version}
+				brokers[broker.ID()] = broker
+			}
+			brokerRequests[broker.ID()].AddBlock(topic, partitionID, timestamp, 1)
+		}
+	}
+
+	results := make(map[string]map[int32]int64)
+	resultLock := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for brokerID, request := range brokerRequests {
+		wg.Add(1)
+		go func(broker SaramaBroker, request *sarama.OffsetRequest) {
+			defer wg.Done()
+			response, err := broker.GetAvailableOffsets(request)
+
+			resultLock.Lock()
+			defer resultLock.Unlock()
+			if err != nil {
+				lastErr = err
+				return
+			}
+			for topic, partitions := range response.Blocks {
+				for partitionID, block := range partitions {
+					if block.Err != sarama.ErrNoError {
+						lastErr = block.Err
+						continue
+					}
+					if len(block.Offsets) == 0 {
+						lastErr = sarama.ErrIncompleteResponse
+						continue
+					}
+					if _, ok := results[topic]; !ok {
+						results[topic] = make(map[int32]int64)
+					}
+					results[topic][partitionID] = block.Offsets[0]
+				}
+			}
+		}(brokers[brokerID], request)
+	}
+	wg.Wait()
+
+	return results, lastErr
+}
+
+// Controller returns the broker that is the active controller for the cluster, as determined by querying the cluster
+// metadata. An error is returned if the cluster has no controller. This function only works on Kafka 0.10.0 and higher.
+func (c *BurrowSaramaClient) Controller() (SaramaBroker, error) {
+	broker, err := c.Client.Controller()
+	var shimBroker *BurrowSaramaBroker
+	if broker != nil {
+		shimBroker = &BurrowSaramaBroker{broker: broker, config: c.Client.Config()}
+	}
+	return shimBroker, err
+}
+
 // Coordinator returns the coordinating broker for a consumer group. It will return a locally cached value if it's
 // available. You can call RefreshCoordinator to update the cached value. This function only works on Kafka 0.8.2 and
 // higher.
@@ -317,7 +991,7 @@ func (c *BurrowSaramaClient) Coordinator(consumerGroup string) (SaramaBroker, er
 	broker, err := c.Client.Coordinator(consumerGroup)
 	var shimBroker *BurrowSaramaBroker
 	if broker != nil {
-		shimBroker = &BurrowSaramaBroker{broker}
+		shimBroker = &BurrowSaramaBroker{broker: broker, config: c.Client.Config()}
 	}
 	return shimBroker, err
 }
@@ -359,11 +1033,46 @@ type SaramaBroker interface {
 
 	// GetAvailableOffsets sends an OffsetRequest to the broker and returns the OffsetResponse that was received
 	GetAvailableOffsets(*sarama.OffsetRequest) (*sarama.OffsetResponse, error)
+
+	// OffsetForLeaderEpoch asks the broker for the end offset of the given leader epoch for each requested partition.
+	// This can be used to detect a log that was truncated (such as after an unclean leader election) below a committed
+	// offset.
+	OffsetForLeaderEpoch(*OffsetForLeaderEpochRequest) (*OffsetForLeaderEpochResponse, error)
+
+	// FetchOffset sends an OffsetFetchRequest to the broker and returns the OffsetFetchResponse that was received
+	FetchOffset(*sarama.OffsetFetchRequest) (*sarama.OffsetFetchResponse, error)
+
+	// ListGroups sends a ListGroupsRequest to the broker and returns the ListGroupsResponse that was received
+	ListGroups(*sarama.ListGroupsRequest) (*sarama.ListGroupsResponse, error)
+
+	// Fetch sends a FetchRequest to the broker and returns the FetchResponse that was received
+	Fetch(*sarama.FetchRequest) (*sarama.FetchResponse, error)
+}
+
+// OffsetForLeaderEpochRequest holds the leader epoch to look up, for each partition of each topic
+type OffsetForLeaderEpochRequest struct {
+	LeaderEpochs map[string]map[int32]int32
+}
+
+// EpochEndOffset is the end offset of a leader epoch for a single partition, as reported by the broker
+type EpochEndOffset struct {
+	Err         sarama.KError
+	LeaderEpoch int32
+	EndOffset   int64
+}
+
+// OffsetForLeaderEpochResponse holds the end offset for each partition of each topic in the request
+type OffsetForLeaderEpochResponse struct {
+	EndOffsets map[string]map[int32]*EpochEndOffset
 }
 
 // BurrowSaramaBroker is an implementation of the SaramaBroker interface that is used with SaramaClient
 type BurrowSaramaBroker struct {
 	broker *sarama.Broker
+
+	// The config of the client that the broker belongs to, which is used to connect for requests that Sarama does not
+	// implement
+	config *sarama.Config
 }
 
 // ID returns the broker ID retrieved from Kafka's metadata, or -1 if that is not known.
@@ -381,13 +1090,154 @@ func (b *BurrowSaramaBroker) GetAvailableOffsets(request *sarama.OffsetRequest)
 	return b.broker.GetAvailableOffsets(request)
 }
 
+// FetchOffset sends an OffsetFetchRequest to the broker and returns the OffsetFetchResponse that was received
+func (b *BurrowSaramaBroker) FetchOffset(request *sarama.OffsetFetchRequest) (*sarama.OffsetFetchResponse, error) {
+	return b.broker.FetchOffset(request)
+}
+
+// ListGroups sends a ListGroupsRequest to the broker and returns the ListGroupsResponse that was received
+func (b *BurrowSaramaBroker) ListGroups(request *sarama.ListGroupsRequest) (*sarama.ListGroupsResponse, error) {
+	return b.broker.ListGroups(request)
+}
+
+// Fetch sends a FetchRequest to the broker and returns the FetchResponse that was received
+func (b *BurrowSaramaBroker) Fetch(request *sarama.FetchRequest) (*sarama.FetchResponse, error) {
+	return b.broker.Fetch(request)
+}
+
+// ListConsumerGroupsError is returned by ListConsumerGroups when one or more brokers could not be asked for their
+// consumer groups. The groups from the brokers that did respond are still returned.
+type ListConsumerGroupsError struct {
+	// BrokerErrors is the error for each broker ID that failed
+	BrokerErrors map[int32]error
+}
+
+func (e *ListConsumerGroupsError) Error() string {
+	brokerIDs := make([]int, 0, len(e.BrokerErrors))
+	for brokerID := range e.BrokerErrors {
+		brokerIDs = append(brokerIDs, int(brokerID))
+	}
+	sort.Ints(brokerIDs)
+
+	messages := make([]string, len(brokerIDs))
+	for i, brokerID := range brokerIDs {
+		messages[i] = fmt.Sprintf("broker %d: %v", brokerID, e.BrokerErrors[int32(brokerID)])
+	}
+	return "failed to list consumer groups on " + strings.Join(messages, ", ")
+}
+
+// Unwrap returns the individual broker errors, so that they can be checked with errors.Is and errors.As
+func (e *ListConsumerGroupsError) Unwrap() []error {
+	errs := make([]error, 0, len(e.BrokerErrors))
+	for _, err := range e.BrokerErrors {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
 // ListConsumerGroups List the consumer groups available in the cluster.
 func (c *BurrowSaramaClient) ListConsumerGroups() (map[string]string, error) {
+	// Make sure the brokers are connected, as the cluster admin does
+	for _, broker := range c.Client.Brokers() {
+		_ = broker.Open(c.Client.Config())
+	}
+	return listConsumerGroupsFromBrokers(c)
+}
+
+// listConsumerGroupsFromBrokers implements SaramaClient.ListConsumerGroups for any client, by sending a
+// ListGroupsRequest to every broker in parallel and merging the results.
+func listConsumerGroupsFromBrokers(client SaramaClient) (map[string]string, error) {
+	brokers := client.Brokers()
+	if len(brokers) == 0 {
+		return nil, sarama.ErrOutOfBrokers
+	}
+
+	groups := make(map[string]string)
+	brokerErrors := make(map[int32]error)
+	resultLock := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for _, broker := range brokers {
+		wg.Add(1)
+		go func(broker SaramaBroker) {
+			defer wg.Done()
+			response, err := broker.ListGroups(&sarama.ListGroupsRequest{})
+			if err == nil && response.Err != sarama.ErrNoError {
+				err = response.Err
+			}
+
+			resultLock.Lock()
+			defer resultLock.Unlock()
+			if err != nil {
+				brokerErrors[broker.ID()] = err
+				return
+			}
+			for group, groupType := range response.Groups {
+				groups[group] = groupType
+			}
+		}(broker)
+	}
+	wg.Wait()
+
+	if len(brokerErrors) > 0 {
+		return groups, &ListConsumerGroupsError{BrokerErrors: brokerErrors}
+	}
+	return groups, nil
+}
+
+// DescribeConsumerGroups asks the coordinators of the given consumer groups to describe them, and returns the number
+// of members in each group. Groups that the coordinator returned an error for are left out of the result.
+func (c *BurrowSaramaClient) DescribeConsumerGroups(groups []string) (map[string]int32, error) {
+	admin, err := sarama.NewClusterAdminFromClient(c.Client)
+	if err != nil {
+		return nil, err
+	}
+	descriptions, err := admin.DescribeConsumerGroups(groups)
+	if err != nil {
+		return nil, err
+	}
+
+	memberCounts := make(map[string]int32, len(descriptions))
+	for _, description := range descriptions {
+		if description.Err != sarama.ErrNoError {
+			continue
+		}
+		memberCounts[description.GroupId] = int32(len(description.Members))
+	}
+	return memberCounts, nil
+}
+
+var configResourceTypes = map[string]sarama.ConfigResourceType{
+	"topic":         sarama.TopicResource,
+	"broker":        sarama.BrokerResource,
+	"broker_logger": sarama.BrokerLoggerResource,
+}
+
+// DescribeConfigs returns the configuration entries for the named resource, where resourceType is one of "topic",
+// "broker", or "broker_logger". If keys is empty, all configuration entries for the resource are returned.
+func (c *BurrowSaramaClient) DescribeConfigs(resourceType, name string, keys []string) (map[string]string, error) {
+	configResourceType, ok := configResourceTypes[resourceType]
+	if !ok {
+		return nil, errors.New("unknown config resource type: " + resourceType)
+	}
+
 	admin, err := sarama.NewClusterAdminFromClient(c.Client)
 	if err != nil {
 		return nil, err
 	}
-	return admin.ListConsumerGroups()
+	entries, err := admin.DescribeConfig(sarama.ConfigResource{
+		Type:        configResourceType,
+		Name:        name,
+		ConfigNames: keys,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	configs := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		configs[entry.Name] = entry.Value
+	}
+	return configs, nil
 }
 
 // MockSaramaClient is a mock of SaramaClient. It is used in tests by multiple packages. It should never be used in the
@@ -461,6 +1311,36 @@ func (m *MockSaramaClient) GetOffset(topic string, partitionID int32, timestamp
 	return args.Get(0).(int64), args.Error(1)
 }
 
+// GetOffsets mocks SaramaClient.GetOffsets
+func (m *MockSaramaClient) GetOffsets(requests map[string]map[int32]int64) (map[string]map[int32]int64, error) {
+	args := m.Called(requests)
+	return args.Get(0).(map[string]map[int32]int64), args.Error(1)
+}
+
+// FetchConsumerOffsets mocks SaramaClient.FetchConsumerOffsets
+func (m *MockSaramaClient) FetchConsumerOffsets(group string, topicPartitions map[string][]int32) (map[string]map[int32]int64, error) {
+	args := m.Called(group, topicPartitions)
+	return args.Get(0).(map[string]map[int32]int64), args.Error(1)
+}
+
+// GetNewestOffsets mocks SaramaClient.GetNewestOffsets
+func (m *MockSaramaClient) GetNewestOffsets(topic string) (map[int32]int64, error) {
+	args := m.Called(topic)
+	return args.Get(0).(map[int32]int64), args.Error(1)
+}
+
+// Healthcheck mocks SaramaClient.Healthcheck
+func (m *MockSaramaClient) Healthcheck() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+// Controller mocks SaramaClient.Controller
+func (m *MockSaramaClient) Controller() (SaramaBroker, error) {
+	args := m.Called()
+	return args.Get(0).(SaramaBroker), args.Error(1)
+}
+
 // Coordinator mocks SaramaClient.Coordinator
 func (m *MockSaramaClient) Coordinator(consumerGroup string) (SaramaBroker, error) {
 	args := m.Called(consumerGroup)
@@ -496,6 +1376,18 @@ func (m *MockSaramaClient) ListConsumerGroups() (map[string]string, error) {
 	return args.Get(0).(map[string]string), args.Error(1)
 }
 
+// DescribeConsumerGroups mocks SaramaClient.DescribeConsumerGroups
+func (m *MockSaramaClient) DescribeConsumerGroups(groups []string) (map[string]int32, error) {
+	args := m.Called(groups)
+	return args.Get(0).(map[string]int32), args.Error(1)
+}
+
+// DescribeConfigs mocks SaramaClient.DescribeConfigs
+func (m *MockSaramaClient) DescribeConfigs(resourceType, name string, keys []string) (map[string]string, error) {
+	args := m.Called(resourceType, name, keys)
+	return args.Get(0).(map[string]string), args.Error(1)
+}
+
 // MockSaramaBroker is a mock of SaramaBroker. It is used in tests by multiple packages. It should never be used in the
 // normal code.
 type MockSaramaBroker struct {
@@ -520,6 +1412,30 @@ func (m *MockSaramaBroker) GetAvailableOffsets(request *sarama.OffsetRequest) (*
 	return args.Get(0).(*sarama.OffsetResponse), args.Error(1)
 }
 
+// FetchOffset mocks SaramaBroker.FetchOffset
+func (m *MockSaramaBroker) FetchOffset(request *sarama.OffsetFetchRequest) (*sarama.OffsetFetchResponse, error) {
+	args := m.Called(request)
+	return args.Get(0).(*sarama.OffsetFetchResponse), args.Error(1)
+}
+
+// ListGroups mocks SaramaBroker.ListGroups
+func (m *MockSaramaBroker) ListGroups(request *sarama.ListGroupsRequest) (*sarama.ListGroupsResponse, error) {
+	args := m.Called(request)
+	return args.Get(0).(*sarama.ListGroupsResponse), args.Error(1)
+}
+
+// Fetch mocks SaramaBroker.Fetch
+func (m *MockSaramaBroker) Fetch(request *sarama.FetchRequest) (*sarama.FetchResponse, error) {
+	args := m.Called(request)
+	return args.Get(0).(*sarama.FetchResponse), args.Error(1)
+}
+
+// OffsetForLeaderEpoch mocks SaramaBroker.OffsetForLeaderEpoch
+func (m *MockSaramaBroker) OffsetForLeaderEpoch(request *OffsetForLeaderEpochRequest) (*OffsetForLeaderEpochResponse, error) {
+	args := m.Called(request)
+	return args.Get(0).(*OffsetForLeaderEpochResponse), args.Error(1)
+}
+
 // MockSaramaConsumer is a mock of sarama.Consumer. It is used in tests by multiple packages. It should never be used
 // in the normal code.
 type MockSaramaConsumer struct {
@@ -627,13 +1543,200 @@ func (m *MockSaramaPartitionConsumer) Resume() {
 	m.Called()
 }
 
-func newSaramaZapLogger(logger *zap.Logger) sarama.StdLogger {
-	sl, _ := zap.NewStdLogAt(logger.With(zap.String("name", "sarama")), zapcore.DebugLevel)
+func newSaramaZapLogger(logger *zap.Logger, level zapcore.Level) sarama.StdLogger {
+	sl, _ := zap.NewStdLogAt(logger.With(zap.String("name", "sarama")), level)
 	return sl
 }
 
 // InitSaramaLogging assigns a new logger to sarama.Logger, which
 // will send messages to given zap logger at debug level
 func InitSaramaLogging(logger *zap.Logger) {
-	sarama.Logger = newSaramaZapLogger(logger)
+	InitSaramaLoggingAt(logger, zapcore.DebugLevel)
+}
+
+// InitSaramaLoggingAt assigns a new logger to sarama.Logger, which
+// will send messages to given zap logger at the given level
+func InitSaramaLoggingAt(logger *zap.Logger, level zapcore.Level) {
+	sarama.Logger = newSaramaZapLogger(logger, level)
+}
+
+// getGSSAPIConfig returns the Kerberos settings for a sasl profile with the GSSAPI mechanism, which authenticates with
+// the keytab for the principal. The realm defaults to the one in the principal (as in "burrow@EXAMPLE.COM"), the
+// service-name to "kafka", and the kerberos-config to /etc/krb5.conf. An error is returned if the keytab cannot be read.
+func getGSSAPIConfig(config *viper.Viper, saslName string) (*sarama.GSSAPIConfig, error) {
+	saslRoot := "sasl." + saslName
+	config.SetDefault(saslRoot+".service-name", "kafka")
+	config.SetDefault(saslRoot+".kerberos-config", "/etc/krb5.conf")
+
+	username, realm, _ := strings.Cut(config.GetString(saslRoot+".principal"), "@")
+	if config.IsSet(saslRoot + ".realm") {
+		realm = config.GetString(saslRoot + ".realm")
+	}
+	if username == "" {
+		return nil, fmt.Errorf("sasl.%s: principal is required for GSSAPI", saslName)
+	}
+	if realm == "" {
+		return nil, fmt.Errorf("sasl.%s: realm is required for GSSAPI, in the principal or on its own", saslName)
+	}
+
+	keytab := config.GetString(saslRoot + ".keytab")
+	if keytab == "" {
+		return nil, fmt.Errorf("sasl.%s: keytab is required for GSSAPI", saslName)
+	}
+	file, err := os.Open(keytab)
+	if err != nil {
+		return nil, fmt.Errorf("sasl.%s: cannot read keytab: %v", saslName, err)
+	}
+	file.Close()
+
+	return &sarama.GSSAPIConfig{
+		AuthType:           sarama.KRB5_KEYTAB_AUTH,
+		KeyTabPath:         keytab,
+		KerberosConfigPath: config.GetString(saslRoot + ".kerberos-config"),
+		ServiceName:        config.GetString(saslRoot + ".service-name"),
+		Username:           username,
+		Realm:              realm,
+		DisablePAFXFAST:    config.GetBool(saslRoot + ".disable-pafx-fast"),
+	}, nil
+}
+
+// LogSaramaConfig logs a summary of the settings that were applied to a sarama.Config for the named client profile, at
+// debug level. Secrets, such as the SASL password, are never logged.
+func LogSaramaConfig(logger *zap.Logger, profileName string, saramaConfig *sarama.Config) {
+	fields := []zap.Field{
+		zap.String("client_profile", profileName),
+		zap.String("kafka_version", saramaConfig.Version.String()),
+		zap.String("client_id", saramaConfig.ClientID),
+		zap.Bool("tls", saramaConfig.Net.TLS.Enable),
+	}
+	if saramaConfig.Net.TLS.Enable && saramaConfig.Net.TLS.Config != nil {
+		fields = append(fields, zap.Bool("tls_noverify", saramaConfig.Net.TLS.Config.InsecureSkipVerify))
+	}
+
+	fields = append(fields, zap.Bool("sasl", saramaConfig.Net.SASL.Enable))
+	if saramaConfig.Net.SASL.Enable {
+		fields = append(fields,
+			zap.String("sasl_mechanism", string(saramaConfig.Net.SASL.Mechanism)),
+			zap.Bool("sasl_handshake", saramaConfig.Net.SASL.Handshake),
+			zap.Int16("sasl_version", saramaConfig.Net.SASL.Version),
+		)
+		if saramaConfig.Net.SASL.User != "" {
+			fields = append(fields, zap.String("sasl_username", saramaConfig.Net.SASL.User))
+		}
+		if saramaConfig.Net.SASL.Password != "" {
+			fields = append(fields, zap.String("sasl_password", "[redacted]"))
+		}
+		if saramaConfig.Net.SASL.Mechanism == sarama.SASLTypeGSSAPI {
+			fields = append(fields,
+				zap.String("sasl_principal", saramaConfig.Net.SASL.GSSAPI.Username+"@"+saramaConfig.Net.SASL.GSSAPI.Realm),
+				zap.String("sasl_keytab", saramaConfig.Net.SASL.GSSAPI.KeyTabPath),
+			)
+		}
+	}
+
+	if provider, ok := saramaConfig.Net.SASL.TokenProvider.(*iamTokenProvider); ok {
+		fields = append(fields,
+			zap.String("iam_region", provider.region),
+			zap.String("iam_credentials_source", provider.credentialsSource),
+		)
+		if provider.roleArn != "" {
+			fields = append(fields, zap.String("iam_role_arn", provider.roleArn))
+		}
+		if provider.profile != "" {
+			fields = append(fields, zap.String("iam_profile", provider.profile))
+		}
+	}
+
+	logger.Debug("resolved client profile", fields...)
+}
+
+// apiVersionMarkers maps the presence of an API key at a minimum max version to the Kafka release that introduced it,
+// newest first. Brokers newer than the first entry are detected as that version, which is the same as the default.
+var apiVersionMarkers = []struct {
+	apiKey     int16
+	maxVersion int16
+	version    sarama.KafkaVersion
+}{
+	{60, 0, sarama.V2_8_0_0},  // DescribeCluster
+	{57, 0, sarama.V2_7_0_0},  // UpdateFeatures
+	{48, 0, sarama.V2_6_0_0},  // DescribeClientQuotas
+	{29, 2, sarama.V2_5_0_0},  // DescribeAcls v2
+	{0, 8, sarama.V2_4_0_0},   // Produce v8
+	{1, 11, sarama.V2_3_0_0},  // Fetch v11
+	{2, 5, sarama.V2_2_0_0},   // ListOffsets v5
+	{1, 10, sarama.V2_1_0_0},  // Fetch v10
+	{1, 8, sarama.V2_0_0_0},   // Fetch v8
+	{1, 7, sarama.V1_1_0_0},   // Fetch v7
+	{3, 5, sarama.V1_0_0_0},   // Metadata v5
+	{3, 4, sarama.V0_11_0_0},  // Metadata v4
+	{9, 2, sarama.V0_10_2_0},  // OffsetFetch v2
+	{3, 2, sarama.V0_10_1_0},  // Metadata v2
+	{18, 0, sarama.V0_10_0_0}, // ApiVersions
+}
+
+// kafkaVersionFromAPIVersions returns the newest Kafka version that the ApiVersions response shows the broker to be
+// running, or false if it could not be determined.
+func kafkaVersionFromAPIVersions(apiKeys []sarama.ApiVersionsResponseKey) (sarama.KafkaVersion, bool) {
+	maxVersions := make(map[int16]int16, len(apiKeys))
+	for _, key := range apiKeys {
+		maxVersions[key.ApiKey] = key.MaxVersion
+	}
+	for _, marker := range apiVersionMarkers {
+		if maxVersion, ok := maxVersions[marker.apiKey]; ok && maxVersion >= marker.maxVersion {
+			return marker.version, true
+		}
+	}
+	return sarama.KafkaVersion{}, false
+}
+
+// DetectKafkaVersion sets the Kafka version of the sarama.Config from an ApiVersionsRequest sent to the first broker
+// in servers that responds, if the client profile has kafka-version set to "auto". If the version cannot be detected,
+// the default version already set in the config is kept. It is called before creating a client, as the brokers need
+// to be reachable.
+func DetectKafkaVersion(logger *zap.Logger, profileName string, servers []string, saramaConfig *sarama.Config) {
+	if viper.GetString("client-profile."+profileName+".kafka-version") != "auto" {
+		return
+	}
+
+	for _, server := range servers {
+		broker := sarama.NewBroker(server)
+		if err := broker.Open(saramaConfig); err != nil && err != sarama.ErrAlreadyConnected {
+			logger.Warn("cannot connect to broker to detect kafka version", zap.String("server", server), zap.Error(err))
+			continue
+		}
+		response, err := broker.ApiVersions(&sarama.ApiVersionsRequest{})
+		broker.Close()
+		if err != nil {
+			logger.Warn("failed to detect kafka version", zap.String("server", server), zap.Error(err))
+			continue
+		}
+		if version, ok := kafkaVersionFromAPIVersions(response.ApiKeys); ok {
+			logger.Info("detected kafka version", zap.String("server", server), zap.String("kafka_version", version.String()))
+			saramaConfig.Version = version
+			return
+		}
+	}
+	logger.Warn("could not detect kafka version, using default", zap.String("kafka_version", saramaConfig.Version.String()))
+}
+
+// CheckKafkaConnection connects a client to the servers with the sarama.Config, the same way that the modules do when
+// they are started, and fetches the metadata for the cluster. Each of the topics given must exist in the cluster. The
+// client is closed before returning, and any error connecting, fetching the metadata, or finding a topic is returned.
+func CheckKafkaConnection(logger *zap.Logger, profileName string, servers []string, saramaConfig *sarama.Config, topics ...string) error {
+	DetectKafkaVersion(logger, profileName, servers, saramaConfig)
+	client, err := sarama.NewClient(servers, saramaConfig)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := client.RefreshMetadata(); err != nil {
+		return err
+	}
+	for _, topic := range topics {
+		if _, err := client.Partitions(topic); err != nil {
+			return errors.New("cannot get partitions for topic '" + topic + "': " + err.Error())
+		}
+	}
+	return nil
 }
@@ -10,13 +10,34 @@
 package helpers
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 
 	"github.com/IBM/sarama"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestBurrowSaramaClient_ImplementsSaramaClient(t *testing.T) {
@@ -62,34 +83,963 @@ func TestInitSaramaLogging(t *testing.T) {
 	assert.Equal(t, entries[0].Level, zap.DebugLevel)
 }
 
-func shouldPanicForVersion(t *testing.T, v string) {
-	defer func() { recover() }()
-	out := parseKafkaVersion(v)
-	t.Errorf("Kafka version %s should have panicked, but got: %s", v, out.String())
+func TestInitSaramaLoggingAt(t *testing.T) {
+	// given
+	core, logs := observer.New(zapcore.DebugLevel)
+	InitSaramaLoggingAt(zap.New(core), zapcore.WarnLevel)
+
+	// when
+	sarama.Logger.Printf("reconnecting")
+
+	// then
+	assert.Equal(t, 1, logs.Len())
+	assert.Equal(t, "reconnecting", logs.All()[0].Message)
+	assert.Equal(t, zapcore.WarnLevel, logs.All()[0].Level)
+}
+
+func mustParseKafkaVersion(t *testing.T, v string) sarama.KafkaVersion {
+	version, err := parseKafkaVersion(v)
+	assert.NoError(t, err, "Kafka version %s should have parsed", v)
+	return version
+}
+
+func shouldFailForVersion(t *testing.T, v string) {
+	out, err := parseKafkaVersion(v)
+	assert.Error(t, err, "Kafka version %s should have failed, but got: %s", v, out.String())
 }
 
 func TestVersionMapping(t *testing.T) {
-	assert.Equal(t, parseKafkaVersion(""), sarama.V0_10_2_0)
-	assert.Equal(t, parseKafkaVersion("0.10"), sarama.V0_10_0_0)
-	assert.Equal(t, parseKafkaVersion("0.10.2"), sarama.V0_10_2_0)
-	assert.Equal(t, parseKafkaVersion("0.10.2.0"), sarama.V0_10_2_0)
+	assert.Equal(t, mustParseKafkaVersion(t, ""), sarama.V0_10_2_0)
+	assert.Equal(t, mustParseKafkaVersion(t, "0.10"), sarama.V0_10_0_0)
+	assert.Equal(t, mustParseKafkaVersion(t, "0.10.2"), sarama.V0_10_2_0)
+	assert.Equal(t, mustParseKafkaVersion(t, "0.10.2.0"), sarama.V0_10_2_0)
 	// some other legacy cases
-	assert.Equal(t, parseKafkaVersion("0.8.0"), sarama.V0_8_2_0)
-	assert.Equal(t, parseKafkaVersion("0.8.1"), sarama.V0_8_2_1)
-	assert.Equal(t, parseKafkaVersion("0.8.2"), sarama.V0_8_2_2)
+	assert.Equal(t, mustParseKafkaVersion(t, "0.8.0"), sarama.V0_8_2_0)
+	assert.Equal(t, mustParseKafkaVersion(t, "0.8.1"), sarama.V0_8_2_1)
+	assert.Equal(t, mustParseKafkaVersion(t, "0.8.2"), sarama.V0_8_2_2)
 	// and older versions that want to use the 4-part version
-	assert.Equal(t, parseKafkaVersion("0.8.2.2"), sarama.V0_8_2_2)
-	assert.Equal(t, parseKafkaVersion("0.10.2.1"), sarama.V0_10_2_1)
-	assert.Equal(t, parseKafkaVersion("0.11.0.1"), sarama.V0_11_0_1)
+	assert.Equal(t, mustParseKafkaVersion(t, "0.8.2.2"), sarama.V0_8_2_2)
+	assert.Equal(t, mustParseKafkaVersion(t, "0.10.2.1"), sarama.V0_10_2_1)
+	assert.Equal(t, mustParseKafkaVersion(t, "0.11.0.1"), sarama.V0_11_0_1)
 	// check some of the newer versions
-	assert.Equal(t, parseKafkaVersion("1.0.0"), sarama.V1_0_0_0)
-	assert.Equal(t, parseKafkaVersion("1.0.2"), sarama.V1_0_2_0)
-	assert.Equal(t, parseKafkaVersion("2.1.0"), sarama.V2_1_0_0)
-	assert.Equal(t, parseKafkaVersion("2.2.0"), sarama.V2_2_0_0)
-	assert.Equal(t, parseKafkaVersion("3.0.0"), sarama.V3_0_0_0)
+	assert.Equal(t, mustParseKafkaVersion(t, "1.0.0"), sarama.V1_0_0_0)
+	assert.Equal(t, mustParseKafkaVersion(t, "1.0.2"), sarama.V1_0_2_0)
+	assert.Equal(t, mustParseKafkaVersion(t, "2.1.0"), sarama.V2_1_0_0)
+	assert.Equal(t, mustParseKafkaVersion(t, "2.2.0"), sarama.V2_2_0_0)
+	assert.Equal(t, mustParseKafkaVersion(t, "3.0.0"), sarama.V3_0_0_0)
 
 	// check that we fail a 4-part version for newer versions
-	shouldPanicForVersion(t, "3.0.0.0")
+	shouldFailForVersion(t, "3.0.0.0")
 	// or for other unknown/unsupported versions
-	shouldPanicForVersion(t, "foo")
+	shouldFailForVersion(t, "foo")
+}
+
+func TestGetSaramaConfigFromClientProfileE_Errors(t *testing.T) {
+	viper.Reset()
+	saramaConfig, err := GetSaramaConfigFromClientProfileE("nonexistent")
+	assert.Nil(t, saramaConfig)
+	assert.EqualError(t, err, "unknown client-profile 'nonexistent'")
+
+	viper.Set("client-profile.test.kafka-version", "foo")
+	saramaConfig, err = GetSaramaConfigFromClientProfileE("test")
+	assert.Nil(t, saramaConfig)
+	assert.EqualError(t, err, "Unknown Kafka Version: foo")
+
+	assert.PanicsWithValue(t, "Unknown Kafka Version: foo", func() { GetSaramaConfigFromClientProfile("test") })
+}
+
+func TestGetSaramaConfigFromClientProfileE(t *testing.T) {
+	viper.Reset()
+	viper.Set("client-profile.test.client-id", "testid")
+	saramaConfig, err := GetSaramaConfigFromClientProfileE("test")
+	assert.NoError(t, err)
+	assert.Equal(t, "testid", saramaConfig.ClientID)
+	assert.Equal(t, sarama.V2_8_0_0, saramaConfig.Version)
+}
+
+// generateTestKeyPair returns a PEM-encoded self-signed certificate and its private key
+func generateTestKeyPair(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "burrow-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return string(certPEM), string(keyPEM)
+}
+
+func TestGetSaramaConfigFromClientProfile_TLSInlinePEM(t *testing.T) {
+	certPEM, keyPEM := generateTestKeyPair(t)
+
+	viper.Reset()
+	viper.Set("client-profile.test.tls", "tlsprofile")
+	viper.Set("tls.tlsprofile.certpem", certPEM)
+	viper.Set("tls.tlsprofile.keypem", keyPEM)
+	viper.Set("tls.tlsprofile.capem", certPEM)
+
+	saramaConfig := GetSaramaConfigFromClientProfile("test")
+	assert.True(t, saramaConfig.Net.TLS.Enable)
+	assert.Len(t, saramaConfig.Net.TLS.Config.Certificates, 1)
+	assert.NotNil(t, saramaConfig.Net.TLS.Config.RootCAs)
+}
+
+func TestGetSaramaConfigFromClientProfile_TLSEnvPEM(t *testing.T) {
+	certPEM, keyPEM := generateTestKeyPair(t)
+	t.Setenv("BURROW_TEST_CERT", certPEM)
+	t.Setenv("BURROW_TEST_KEY", keyPEM)
+
+	viper.Reset()
+	viper.Set("client-profile.test.tls", "tlsprofile")
+	viper.Set("tls.tlsprofile.certpem", "env:BURROW_TEST_CERT")
+	viper.Set("tls.tlsprofile.keypem", "env:BURROW_TEST_KEY")
+
+	saramaConfig := GetSaramaConfigFromClientProfile("test")
+	assert.Len(t, saramaConfig.Net.TLS.Config.Certificates, 1)
+	assert.Nil(t, saramaConfig.Net.TLS.Config.RootCAs)
+}
+
+func TestGetSaramaConfigFromClientProfile_TLSInlineOverridesFile(t *testing.T) {
+	certPEM, keyPEM := generateTestKeyPair(t)
+
+	viper.Reset()
+	viper.Set("client-profile.test.tls", "tlsprofile")
+	viper.Set("tls.tlsprofile.certfile", filepath.Join(t.TempDir(), "missing.crt"))
+	viper.Set("tls.tlsprofile.keyfile", filepath.Join(t.TempDir(), "missing.key"))
+	viper.Set("tls.tlsprofile.certpem", certPEM)
+	viper.Set("tls.tlsprofile.keypem", keyPEM)
+
+	saramaConfig := GetSaramaConfigFromClientProfile("test")
+	assert.Len(t, saramaConfig.Net.TLS.Config.Certificates, 1)
+}
+
+func TestGetSaramaConfigFromClientProfile_TLSFiles(t *testing.T) {
+	certPEM, keyPEM := generateTestKeyPair(t)
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "client.crt")
+	keyFile := filepath.Join(dir, "client.key")
+	assert.NoError(t, os.WriteFile(certFile, []byte(certPEM), 0600))
+	assert.NoError(t, os.WriteFile(keyFile, []byte(keyPEM), 0600))
+
+	viper.Reset()
+	viper.Set("client-profile.test.tls", "tlsprofile")
+	viper.Set("tls.tlsprofile.cafile", certFile)
+	viper.Set("tls.tlsprofile.certfile", certFile)
+	viper.Set("tls.tlsprofile.keyfile", keyFile)
+
+	saramaConfig := GetSaramaConfigFromClientProfile("test")
+	assert.Len(t, saramaConfig.Net.TLS.Config.Certificates, 1)
+	assert.NotNil(t, saramaConfig.Net.TLS.Config.RootCAs)
+}
+
+func TestGetSaramaConfigFromClientProfile_TLSInvalidInlinePEM(t *testing.T) {
+	viper.Reset()
+	viper.Set("client-profile.test.tls", "tlsprofile")
+	viper.Set("tls.tlsprofile.certpem", "not a certificate")
+	viper.Set("tls.tlsprofile.keypem", "not a key")
+	assert.PanicsWithValue(t, "tls profile 'tlsprofile' has an invalid inline certificate or key PEM: tls: failed to find any PEM data in certificate input",
+		func() { GetSaramaConfigFromClientProfile("test") })
+
+	viper.Reset()
+	viper.Set("client-profile.test.tls", "tlsprofile")
+	viper.Set("tls.tlsprofile.capem", "not a certificate")
+	assert.PanicsWithValue(t, "tls profile 'tlsprofile' has an invalid inline CA PEM",
+		func() { GetSaramaConfigFromClientProfile("test") })
+}
+
+func TestGetSaramaConfigFromClientProfile_SASLVersion(t *testing.T) {
+	viper.Reset()
+	viper.Set("client-profile.test.sasl", "saslprofile")
+	viper.Set("sasl.saslprofile.mechanism", "SCRAM-SHA-256")
+	saramaConfig := GetSaramaConfigFromClientProfile("test")
+	assert.Equal(t, sarama.NewConfig().Net.SASL.Version, saramaConfig.Net.SASL.Version)
+
+	viper.Set("sasl.saslprofile.version", 0)
+	saramaConfig = GetSaramaConfigFromClientProfile("test")
+	assert.Equal(t, sarama.SASLHandshakeV0, saramaConfig.Net.SASL.Version)
+
+	viper.Set("sasl.saslprofile.version", 1)
+	saramaConfig = GetSaramaConfigFromClientProfile("test")
+	assert.Equal(t, sarama.SASLHandshakeV1, saramaConfig.Net.SASL.Version)
+
+	viper.Set("sasl.saslprofile.version", 2)
+	assert.PanicsWithValue(t, "sasl.saslprofile: version must be 0 or 1, not 2",
+		func() { GetSaramaConfigFromClientProfile("test") })
+}
+
+func TestGetSaramaConfigFromClientProfile_GSSAPI(t *testing.T) {
+	keytab := filepath.Join(t.TempDir(), "burrow.keytab")
+	assert.NoError(t, os.WriteFile(keytab, []byte("keytab"), 0o600))
+
+	viper.Reset()
+	viper.Set("client-profile.test.sasl", "kerberos")
+	viper.Set("sasl.kerberos.mechanism", "GSSAPI")
+	viper.Set("sasl.kerberos.principal", "burrow@EXAMPLE.COM")
+	viper.Set("sasl.kerberos.keytab", keytab)
+	saramaConfig := GetSaramaConfigFromClientProfile("test")
+	assert.NoError(t, saramaConfig.Validate())
+	assert.Equal(t, sarama.SASLMechanism(sarama.SASLTypeGSSAPI), saramaConfig.Net.SASL.Mechanism)
+	assert.Equal(t, sarama.KRB5_KEYTAB_AUTH, saramaConfig.Net.SASL.GSSAPI.AuthType)
+	assert.Equal(t, keytab, saramaConfig.Net.SASL.GSSAPI.KeyTabPath)
+	assert.Equal(t, "burrow", saramaConfig.Net.SASL.GSSAPI.Username)
+	assert.Equal(t, "EXAMPLE.COM", saramaConfig.Net.SASL.GSSAPI.Realm)
+	assert.Equal(t, "kafka", saramaConfig.Net.SASL.GSSAPI.ServiceName)
+	assert.Equal(t, "/etc/krb5.conf", saramaConfig.Net.SASL.GSSAPI.KerberosConfigPath)
+
+	viper.Set("sasl.kerberos.realm", "OTHER.EXAMPLE.COM")
+	viper.Set("sasl.kerberos.service-name", "kafka-prod")
+	saramaConfig = GetSaramaConfigFromClientProfile("test")
+	assert.Equal(t, "OTHER.EXAMPLE.COM", saramaConfig.Net.SASL.GSSAPI.Realm)
+	assert.Equal(t, "kafka-prod", saramaConfig.Net.SASL.GSSAPI.ServiceName)
+}
+
+func TestGetSaramaConfigFromClientProfile_GSSAPIErrors(t *testing.T) {
+	viper.Reset()
+	viper.Set("client-profile.test.sasl", "kerberos")
+	viper.Set("sasl.kerberos.mechanism", "GSSAPI")
+	assert.PanicsWithValue(t, "sasl.kerberos: principal is required for GSSAPI",
+		func() { GetSaramaConfigFromClientProfile("test") })
+
+	viper.Set("sasl.kerberos.principal", "burrow")
+	assert.PanicsWithValue(t, "sasl.kerberos: realm is required for GSSAPI, in the principal or on its own",
+		func() { GetSaramaConfigFromClientProfile("test") })
+
+	viper.Set("sasl.kerberos.realm", "EXAMPLE.COM")
+	assert.PanicsWithValue(t, "sasl.kerberos: keytab is required for GSSAPI",
+		func() { GetSaramaConfigFromClientProfile("test") })
+
+	viper.Set("sasl.kerberos.keytab", filepath.Join(t.TempDir(), "missing.keytab"))
+	_, err := GetSaramaConfigFromClientProfileE("test")
+	assert.ErrorContains(t, err, "sasl.kerberos: cannot read keytab")
+}
+
+func TestGetSaramaConfigFromClientProfile_NetSettings(t *testing.T) {
+	viper.Reset()
+	viper.Set("client-profile.test.client-id", "testid")
+	saramaConfig := GetSaramaConfigFromClientProfile("test")
+	defaults := sarama.NewConfig()
+	assert.Equal(t, defaults.Net.MaxOpenRequests, saramaConfig.Net.MaxOpenRequests)
+	assert.Equal(t, defaults.Net.KeepAlive, saramaConfig.Net.KeepAlive)
+
+	viper.Set("client-profile.test.max-open-requests", 20)
+	viper.Set("client-profile.test.keepalive", 30)
+	saramaConfig = GetSaramaConfigFromClientProfile("test")
+	assert.Equal(t, 20, saramaConfig.Net.MaxOpenRequests)
+	assert.Equal(t, 30*time.Second, saramaConfig.Net.KeepAlive)
+}
+
+func TestGetSaramaConfigFromClientProfile_Timeouts(t *testing.T) {
+	viper.Reset()
+	viper.Set("client-profile.test.client-id", "testid")
+	viper.Set("client-profile.test.dial-timeout", 5)
+	viper.Set("client-profile.test.read-timeout", "3")
+	viper.Set("client-profile.test.write-timeout", "500ms")
+	saramaConfig := GetSaramaConfigFromClientProfile("test")
+	assert.Equal(t, 5*time.Second, saramaConfig.Net.DialTimeout, "Expected an integer to be seconds")
+	assert.Equal(t, 3*time.Second, saramaConfig.Net.ReadTimeout, "Expected an integer string to be seconds")
+	assert.Equal(t, 500*time.Millisecond, saramaConfig.Net.WriteTimeout)
+
+	viper.Set("client-profile.test.read-timeout", "3 seconds")
+	_, err := GetSaramaConfigFromClientProfileE("test")
+	assert.ErrorContains(t, err, "client-profile 'test' has an invalid read-timeout")
+}
+
+func TestGetSaramaConfigFromClientProfile_MetadataSettings(t *testing.T) {
+	viper.Reset()
+	viper.Set("client-profile.test.client-id", "testid")
+	saramaConfig := GetSaramaConfigFromClientProfile("test")
+	defaults := sarama.NewConfig()
+	assert.Equal(t, defaults.Metadata.RefreshFrequency, saramaConfig.Metadata.RefreshFrequency)
+	assert.Equal(t, defaults.Metadata.Full, saramaConfig.Metadata.Full)
+
+	viper.Set("client-profile.test.metadata-refresh", 120)
+	viper.Set("client-profile.test.metadata-full", false)
+	saramaConfig = GetSaramaConfigFromClientProfile("test")
+	assert.Equal(t, 120*time.Second, saramaConfig.Metadata.RefreshFrequency)
+	assert.False(t, saramaConfig.Metadata.Full)
+}
+
+func setupIAMClientProfile() {
+	viper.Reset()
+	viper.Set("client-profile.test.tls", "tlsprofile")
+	viper.Set("tls.tlsprofile.noverify", true)
+	viper.Set("client-profile.test.iam", "iamprofile")
+	viper.Set("iam.iamprofile.region", "us-east-1")
+}
+
+func TestGetSaramaConfigFromClientProfile_IAMCredentialsSource(t *testing.T) {
+	setupIAMClientProfile()
+	saramaConfig := GetSaramaConfigFromClientProfile("test")
+	provider := saramaConfig.Net.SASL.TokenProvider.(*iamTokenProvider)
+	assert.Equal(t, "default", provider.credentialsSource)
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "SECRET")
+	viper.Set("iam.iamprofile.credentials-source", "static")
+	saramaConfig = GetSaramaConfigFromClientProfile("test")
+	provider = saramaConfig.Net.SASL.TokenProvider.(*iamTokenProvider)
+	assert.Equal(t, "static", provider.credentialsSource)
+	assert.Equal(t, "AKID", provider.accessKeyID)
+	assert.Equal(t, "SECRET", provider.secretAccessKey)
+
+	viper.Set("iam.iamprofile.credentials-source", "role")
+	viper.Set("iam.iamprofile.role-arn", "arn:aws:iam::123456789012:role/test")
+	saramaConfig = GetSaramaConfigFromClientProfile("test")
+	provider = saramaConfig.Net.SASL.TokenProvider.(*iamTokenProvider)
+	assert.Equal(t, "role", provider.credentialsSource)
+}
+
+func TestGetSaramaConfigFromClientProfile_IAMCredentialsSourceErrors(t *testing.T) {
+	setupIAMClientProfile()
+	viper.Set("iam.iamprofile.credentials-source", "bogus")
+	assert.PanicsWithValue(t, "iam.iamprofile: unknown credentials-source 'bogus'",
+		func() { GetSaramaConfigFromClientProfile("test") })
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	viper.Set("iam.iamprofile.credentials-source", "static")
+	assert.PanicsWithValue(t, "iam.iamprofile: credentials-source static requires the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables",
+		func() { GetSaramaConfigFromClientProfile("test") })
+
+	viper.Set("iam.iamprofile.credentials-source", "profile")
+	assert.PanicsWithValue(t, "iam.iamprofile: credentials-source profile requires profile to be set",
+		func() { GetSaramaConfigFromClientProfile("test") })
+
+	viper.Set("iam.iamprofile.credentials-source", "role")
+	assert.PanicsWithValue(t, "iam.iamprofile: credentials-source role requires role-arn to be set",
+		func() { GetSaramaConfigFromClientProfile("test") })
+}
+
+func TestBurrowSaramaClient_DescribeConfigsUnknownResource(t *testing.T) {
+	client := &BurrowSaramaClient{}
+	configs, err := client.DescribeConfigs("group", "testgroup", nil)
+	assert.Nil(t, configs)
+	assert.EqualError(t, err, "unknown config resource type: group")
+}
+
+func TestBurrowSaramaBroker_OffsetForLeaderEpochUnsupported(t *testing.T) {
+	oldConfig := sarama.NewConfig()
+	oldConfig.Version = sarama.V0_10_2_0
+	saslConfig := sarama.NewConfig()
+	saslConfig.Version = sarama.V2_1_0_0
+	saslConfig.Net.SASL.Enable = true
+
+	for i, config := range []*sarama.Config{oldConfig, saslConfig} {
+		broker := &BurrowSaramaBroker{broker: sarama.NewBroker("127.0.0.1:9092"), config: config}
+		response, err := broker.OffsetForLeaderEpoch(&OffsetForLeaderEpochRequest{})
+		assert.Nilf(t, response, "TEST %v: Expected no response", i)
+		assert.Equalf(t, ErrOffsetForLeaderEpochUnsupported, err, "TEST %v: Expected the request to be unsupported", i)
+	}
+}
+
+// fakeOffsetForLeaderEpochBroker accepts one connection, checks that it gets a version 2 OffsetForLeaderEpoch request
+// for partition 0 and 1 of testtopic, and replies with an end offset for partition 0 and an error for partition 1
+func fakeOffsetForLeaderEpochBroker(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err, "Expected listener setup to return no error")
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var size int32
+		if binary.Read(conn, binary.BigEndian, &size) != nil {
+			return
+		}
+		request := make([]byte, size)
+		if _, err := io.ReadFull(conn, request); err != nil {
+			return
+		}
+		expected := &bytes.Buffer{}
+		for _, value := range []interface{}{int16(23), int16(2), int32(1), int16(8), []byte("testid12"),
+			int32(1), int16(9), []byte("testtopic"), int32(2), int32(0), int32(-1), int32(4), int32(1), int32(-1), int32(5)} {
+			binary.Write(expected, binary.BigEndian, value)
+		}
+		assert.Equal(t, expected.Bytes(), request, "Expected a version 2 OffsetForLeaderEpoch request")
+
+		response := &bytes.Buffer{}
+		for _, value := range []interface{}{int32(1), int32(0), int32(1), int16(9), []byte("testtopic"), int32(2),
+			int16(0), int32(0), int32(4), int64(1200), int16(6), int32(1), int32(-1), int64(-1)} {
+			binary.Write(response, binary.BigEndian, value)
+		}
+		binary.Write(conn, binary.BigEndian, int32(response.Len()))
+		conn.Write(response.Bytes())
+	}()
+	return listener.Addr().String()
+}
+
+func TestBurrowSaramaBroker_OffsetForLeaderEpoch(t *testing.T) {
+	config := sarama.NewConfig()
+	config.Version = sarama.V2_1_0_0
+	config.ClientID = "testid12"
+	broker := &BurrowSaramaBroker{broker: sarama.NewBroker(fakeOffsetForLeaderEpochBroker(t)), config: config}
+
+	response, err := broker.OffsetForLeaderEpoch(&OffsetForLeaderEpochRequest{
+		LeaderEpochs: map[string]map[int32]int32{"testtopic": {1: 5, 0: 4}},
+	})
+	assert.NoError(t, err, "Expected no error")
+	assert.Equal(t, &OffsetForLeaderEpochResponse{
+		EndOffsets: map[string]map[int32]*EpochEndOffset{
+			"testtopic": {
+				0: {Err: sarama.ErrNoError, LeaderEpoch: 4, EndOffset: 1200},
+				1: {Err: sarama.ErrNotLeaderForPartition, LeaderEpoch: -1, EndOffset: -1},
+			},
+		},
+	}, response)
+}
+
+func TestDecodeOffsetForLeaderEpochResponse_V0(t *testing.T) {
+	// Version 0 has no throttle time, and no leader epoch for each partition
+	buf := &bytes.Buffer{}
+	for _, value := range []interface{}{int32(1), int16(9), []byte("testtopic"), int32(1), int16(0), int32(3), int64(42)} {
+		binary.Write(buf, binary.BigEndian, value)
+	}
+	response, err := decodeOffsetForLeaderEpochResponse(0, buf)
+	assert.NoError(t, err, "Expected no error")
+	assert.Equal(t, &EpochEndOffset{Err: sarama.ErrNoError, LeaderEpoch: -1, EndOffset: 42}, response.EndOffsets["testtopic"][3])
+
+	// A truncated response is an error
+	_, err = decodeOffsetForLeaderEpochResponse(0, bytes.NewBuffer([]byte{0, 0, 0, 1, 0, 9}))
+	assert.Error(t, err, "Expected an error for a truncated response")
+}
+
+func TestGetOffsetsByLeader(t *testing.T) {
+	// Partitions for testtopic1 are split across two brokers, and testtopic2 shares broker 1
+	expected1 := &sarama.OffsetRequest{Version: 4}
+	expected1.AddBlock("testtopic1", 0, sarama.OffsetNewest, 1)
+	expected1.AddBlock("testtopic2", 0, sarama.OffsetOldest, 1)
+	response1 := &sarama.OffsetResponse{Version: 4}
+	response1.AddTopicPartition("testtopic1", 0, 100)
+	response1.AddTopicPartition("testtopic2", 0, 300)
+
+	expected2 := &sarama.OffsetRequest{Version: 4}
+	expected2.AddBlock("testtopic1", 1, sarama.OffsetNewest, 1)
+	response2 := &sarama.OffsetResponse{Version: 4}
+	response2.AddTopicPartition("testtopic1", 1, 200)
+
+	broker1 := &MockSaramaBroker{}
+	broker1.On("ID").Return(int32(1))
+	broker1.On("GetAvailableOffsets", expected1).Return(response1, nil).Once()
+	broker2 := &MockSaramaBroker{}
+	broker2.On("ID").Return(int32(2))
+	broker2.On("GetAvailableOffsets", expected2).Return(response2, nil).Once()
+
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.Version = sarama.V2_8_0_0
+	client := &MockSaramaClient{}
+	client.On("Config").Return(saramaConfig)
+	client.On("Leader", "testtopic1", int32(0)).Return(broker1, nil)
+	client.On("Leader", "testtopic1", int32(1)).Return(broker2, nil)
+	client.On("Leader", "testtopic2", int32(0)).Return(broker1, nil)
+
+	offsets, err := getOffsetsByLeader(client, map[string]map[int32]int64{
+		"testtopic1": {0: sarama.OffsetNewest, 1: sarama.OffsetNewest},
+		"testtopic2": {0: sarama.OffsetOldest},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]map[int32]int64{
+		"testtopic1": {0: 100, 1: 200},
+		"testtopic2": {0: 300},
+	}, offsets)
+	broker1.AssertExpectations(t)
+	broker2.AssertExpectations(t)
+	client.AssertExpectations(t)
+}
+
+func TestGetOffsetsByLeader_Errors(t *testing.T) {
+	response := &sarama.OffsetResponse{Version: 4}
+	response.AddTopicPartition("testtopic", 0, 100)
+	response.AddTopicPartition("testtopic", 1, 0)
+	response.Blocks["testtopic"][1].Err = sarama.ErrNotLeaderForPartition
+
+	broker := &MockSaramaBroker{}
+	broker.On("ID").Return(int32(1))
+	broker.On("GetAvailableOffsets", mock.Anything).Return(response, nil)
+
+	var nilBroker *BurrowSaramaBroker
+	client := &MockSaramaClient{}
+	client.On("Config").Return(sarama.NewConfig())
+	client.On("Leader", "testtopic", int32(0)).Return(broker, nil)
+	client.On("Leader", "testtopic", int32(1)).Return(broker, nil)
+	client.On("Leader", "testtopic", int32(2)).Return(nilBroker, errors.New("no leader error"))
+
+	offsets, err := getOffsetsByLeader(client, map[string]map[int32]int64{
+		"testtopic": {0: sarama.OffsetNewest, 1: sarama.OffsetNewest, 2: sarama.OffsetNewest},
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, map[string]map[int32]int64{"testtopic": {0: 100}}, offsets)
+}
+
+func TestOffsetRequestVersion(t *testing.T) {
+	assert.Equal(t, int16(0), OffsetRequestVersion(sarama.V0_10_0_0))
+	assert.Equal(t, int16(1), OffsetRequestVersion(sarama.V0_10_1_0))
+	assert.Equal(t, int16(2), OffsetRequestVersion(sarama.V0_11_0_0))
+	assert.Equal(t, int16(3), OffsetRequestVersion(sarama.V2_0_0_0))
+	assert.Equal(t, int16(4), OffsetRequestVersion(sarama.V2_8_0_0))
+}
+
+func TestGetSaramaConfigFromClientProfile_KafkaVersionOverride(t *testing.T) {
+	viper.Reset()
+	viper.Set("client-profile.test.kafka-version", "foo")
+	viper.Set("client-profile.test.kafka-version-override", "0.10.2.1")
+	saramaConfig := GetSaramaConfigFromClientProfile("test")
+	assert.Equal(t, sarama.V0_10_2_1, saramaConfig.Version)
+
+	// Short forms from the legacy fallback map are not accepted as an override
+	viper.Set("client-profile.test.kafka-version-override", "0.10")
+	_, err := GetSaramaConfigFromClientProfileE("test")
+	assert.ErrorContains(t, err, "invalid kafka-version-override for client-profile 'test'")
+}
+
+func TestGetSaramaConfigFromClientProfile_KafkaVersionAuto(t *testing.T) {
+	viper.Reset()
+	viper.Set("client-profile.test.kafka-version", "auto")
+	saramaConfig := GetSaramaConfigFromClientProfile("test")
+	assert.Equal(t, sarama.V2_8_0_0, saramaConfig.Version, "Expected the default version until detection runs")
+}
+
+func TestKafkaVersionFromAPIVersions(t *testing.T) {
+	tests := []struct {
+		apiKeys  []sarama.ApiVersionsResponseKey
+		expected sarama.KafkaVersion
+	}{
+		{[]sarama.ApiVersionsResponseKey{{ApiKey: 18, MaxVersion: 3}, {ApiKey: 60, MaxVersion: 0}}, sarama.V2_8_0_0},
+		{[]sarama.ApiVersionsResponseKey{{ApiKey: 18, MaxVersion: 3}, {ApiKey: 48, MaxVersion: 0}}, sarama.V2_6_0_0},
+		{[]sarama.ApiVersionsResponseKey{{ApiKey: 0, MaxVersion: 8}, {ApiKey: 1, MaxVersion: 11}}, sarama.V2_4_0_0},
+		{[]sarama.ApiVersionsResponseKey{{ApiKey: 1, MaxVersion: 8}, {ApiKey: 3, MaxVersion: 6}}, sarama.V2_0_0_0},
+		{[]sarama.ApiVersionsResponseKey{{ApiKey: 3, MaxVersion: 4}, {ApiKey: 9, MaxVersion: 3}}, sarama.V0_11_0_0},
+		{[]sarama.ApiVersionsResponseKey{{ApiKey: 18, MaxVersion: 0}}, sarama.V0_10_0_0},
+	}
+
+	for i, testSet := range tests {
+		version, ok := kafkaVersionFromAPIVersions(testSet.apiKeys)
+		assert.True(t, ok, "Expected a version to be detected for test %v", i)
+		assert.Equal(t, testSet.expected, version, "Unexpected version for test %v", i)
+	}
+
+	_, ok := kafkaVersionFromAPIVersions(nil)
+	assert.False(t, ok, "Expected no version for an empty response")
+}
+
+func TestGetSaramaConfigFromClientProfile_RetrySettings(t *testing.T) {
+	viper.Reset()
+	viper.Set("client-profile.test.client-id", "testid")
+	saramaConfig := GetSaramaConfigFromClientProfile("test")
+	defaults := sarama.NewConfig()
+	assert.Equal(t, defaults.Metadata.Retry.Max, saramaConfig.Metadata.Retry.Max)
+	assert.Equal(t, defaults.Metadata.Retry.Backoff, saramaConfig.Metadata.Retry.Backoff)
+	assert.Equal(t, defaults.Consumer.Retry.Backoff, saramaConfig.Consumer.Retry.Backoff)
+
+	viper.Set("client-profile.test.metadata-retry-max", 10)
+	viper.Set("client-profile.test.metadata-retry-backoff", 500)
+	viper.Set("client-profile.test.consumer-retry-backoff", 5000)
+	saramaConfig = GetSaramaConfigFromClientProfile("test")
+	assert.Equal(t, 10, saramaConfig.Metadata.Retry.Max)
+	assert.Equal(t, 500*time.Millisecond, saramaConfig.Metadata.Retry.Backoff)
+	assert.Equal(t, 5*time.Second, saramaConfig.Consumer.Retry.Backoff)
+
+	// connect-backoff is accepted for the consumer retry backoff, unless consumer-retry-backoff is also set
+	viper.Set("client-profile.test.connect-backoff", 3000)
+	saramaConfig = GetSaramaConfigFromClientProfile("test")
+	assert.Equal(t, 5*time.Second, saramaConfig.Consumer.Retry.Backoff)
+
+	viper.Reset()
+	viper.Set("client-profile.test.client-id", "testid")
+	viper.Set("client-profile.test.connect-backoff", 3000)
+	saramaConfig = GetSaramaConfigFromClientProfile("test")
+	assert.Equal(t, 3*time.Second, saramaConfig.Consumer.Retry.Backoff)
+}
+
+func TestGetSaramaConfigFromClientProfile_SCRAMIterations(t *testing.T) {
+	viper.Reset()
+	viper.Set("client-profile.test.sasl", "saslprofile")
+	viper.Set("sasl.saslprofile.mechanism", "SCRAM-SHA-512")
+	saramaConfig := GetSaramaConfigFromClientProfile("test")
+	scramClient := saramaConfig.Net.SASL.SCRAMClientGeneratorFunc().(*XDGSCRAMClient)
+	assert.Equal(t, 0, scramClient.MinIterations)
+
+	viper.Set("sasl.saslprofile.scram-iterations", 8192)
+	saramaConfig = GetSaramaConfigFromClientProfile("test")
+	scramClient = saramaConfig.Net.SASL.SCRAMClientGeneratorFunc().(*XDGSCRAMClient)
+	assert.Equal(t, 8192, scramClient.MinIterations)
+
+	// The server asking for fewer iterations than configured must fail the conversation
+	assert.NoError(t, scramClient.Begin("user", "pencil", ""))
+	clientFirst, err := scramClient.Step("")
+	assert.NoError(t, err)
+	nonce := strings.TrimPrefix(clientFirst, "n,,n=user,r=")
+	_, err = scramClient.Step("r=" + nonce + "server,s=c2FsdA==,i=4096")
+	assert.EqualError(t, err, "server requested too few iterations (4096)")
+}
+
+func TestHealthcheckClient(t *testing.T) {
+	client := &MockSaramaClient{}
+	client.On("RefreshMetadata").Return(nil).Once()
+	assert.NoError(t, healthcheckClient(client, time.Second))
+	client.AssertExpectations(t)
+}
+
+func TestHealthcheckClient_Error(t *testing.T) {
+	client := &MockSaramaClient{}
+	client.On("RefreshMetadata").Return(sarama.ErrOutOfBrokers).Once()
+	err := healthcheckClient(client, time.Second)
+	assert.EqualError(t, err, "no broker responded to a metadata request: "+sarama.ErrOutOfBrokers.Error())
+	client.AssertExpectations(t)
+}
+
+func TestHealthcheckClient_Timeout(t *testing.T) {
+	client := &MockSaramaClient{}
+	client.On("RefreshMetadata").Return(nil).After(time.Second)
+	err := healthcheckClient(client, 10*time.Millisecond)
+	assert.EqualError(t, err, "timed out after 10ms waiting for a broker to respond to a metadata request")
+}
+
+func TestGetSaramaConfigFromClientProfile_TLSNoverifyOverride(t *testing.T) {
+	viper.Reset()
+	viper.Set("client-profile.test.tls", "tlsprofile")
+	viper.Set("tls.tlsprofile.noverify", true)
+	saramaConfig := GetSaramaConfigFromClientProfile("test")
+	assert.True(t, saramaConfig.Net.TLS.Config.InsecureSkipVerify)
+
+	viper.Set("client-profile.test.tls-noverify", false)
+	saramaConfig = GetSaramaConfigFromClientProfile("test")
+	assert.False(t, saramaConfig.Net.TLS.Config.InsecureSkipVerify)
+
+	viper.Set("tls.tlsprofile.noverify", false)
+	viper.Set("client-profile.test.tls-noverify", true)
+	saramaConfig = GetSaramaConfigFromClientProfile("test")
+	assert.True(t, saramaConfig.Net.TLS.Config.InsecureSkipVerify)
+}
+
+func TestGetSaramaConfigFromClientProfile_TLSConstraints(t *testing.T) {
+	viper.Reset()
+	viper.Set("client-profile.test.tls", "tlsprofile")
+	saramaConfig := GetSaramaConfigFromClientProfile("test")
+	assert.Equal(t, uint16(0), saramaConfig.Net.TLS.Config.MinVersion)
+	assert.Equal(t, uint16(0), saramaConfig.Net.TLS.Config.MaxVersion)
+	assert.Nil(t, saramaConfig.Net.TLS.Config.CipherSuites)
+
+	viper.Set("tls.tlsprofile.min-version", "1.2")
+	viper.Set("tls.tlsprofile.max-version", "1.3")
+	viper.Set("tls.tlsprofile.cipher-suites", []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"})
+	saramaConfig = GetSaramaConfigFromClientProfile("test")
+	assert.Equal(t, uint16(tls.VersionTLS12), saramaConfig.Net.TLS.Config.MinVersion)
+	assert.Equal(t, uint16(tls.VersionTLS13), saramaConfig.Net.TLS.Config.MaxVersion)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384}, saramaConfig.Net.TLS.Config.CipherSuites)
+}
+
+func TestGetSaramaConfigFromClientProfile_TLSConstraintErrors(t *testing.T) {
+	viper.Reset()
+	viper.Set("client-profile.test.tls", "tlsprofile")
+	viper.Set("tls.tlsprofile.min-version", "1.4")
+	assert.PanicsWithValue(t, "tls profile 'tlsprofile' has an unknown min-version '1.4'",
+		func() { GetSaramaConfigFromClientProfile("test") })
+
+	viper.Set("tls.tlsprofile.min-version", "1.3")
+	viper.Set("tls.tlsprofile.max-version", "1.2")
+	assert.PanicsWithValue(t, "tls profile 'tlsprofile' has a min-version greater than its max-version",
+		func() { GetSaramaConfigFromClientProfile("test") })
+
+	viper.Set("tls.tlsprofile.max-version", "1.3")
+	viper.Set("tls.tlsprofile.cipher-suites", []string{"TLS_NOT_A_CIPHER"})
+	assert.PanicsWithValue(t, "tls profile 'tlsprofile' has an unknown cipher suite 'TLS_NOT_A_CIPHER'",
+		func() { GetSaramaConfigFromClientProfile("test") })
+}
+
+func TestGetSaramaConfigFromClientProfile_TLSSystemRoots(t *testing.T) {
+	certPEM, _ := generateTestKeyPair(t)
+
+	viper.Reset()
+	viper.Set("client-profile.test.tls", "tlsprofile")
+	viper.Set("tls.tlsprofile.capem", certPEM)
+	saramaConfig := GetSaramaConfigFromClientProfile("test")
+	caOnly := saramaConfig.Net.TLS.Config.RootCAs
+
+	viper.Set("tls.tlsprofile.use-system-roots", true)
+	saramaConfig = GetSaramaConfigFromClientProfile("test")
+	assert.NotNil(t, saramaConfig.Net.TLS.Config.RootCAs)
+	systemPool, err := x509.SystemCertPool()
+	if err == nil {
+		assert.False(t, caOnly.Equal(saramaConfig.Net.TLS.Config.RootCAs), "Expected system roots in the CA pool")
+		assert.True(t, systemPool.AppendCertsFromPEM([]byte(certPEM)))
+		assert.True(t, systemPool.Equal(saramaConfig.Net.TLS.Config.RootCAs), "Expected system roots plus the configured CA")
+	}
+}
+
+func TestGetSaramaConfigFromClientProfile_SaramaOverrides(t *testing.T) {
+	viper.Reset()
+	viper.SetConfigType("toml")
+	assert.NoError(t, viper.ReadConfig(strings.NewReader(`
+[client-profile.test]
+client-id="testid"
+max-open-requests=20
+
+[client-profile.test.sarama-overrides]
+"Net.MaxOpenRequests"=10
+"Consumer.Fetch.Default"=2097152
+"Consumer.MaxWaitTime"="750ms"
+"Metadata.AllowAutoTopicCreation"=false
+"Net.SASL.AuthIdentity"="burrow"
+`)))
+
+	saramaConfig := GetSaramaConfigFromClientProfile("test")
+	assert.Equal(t, 10, saramaConfig.Net.MaxOpenRequests)
+	assert.Equal(t, int32(2097152), saramaConfig.Consumer.Fetch.Default)
+	assert.Equal(t, 750*time.Millisecond, saramaConfig.Consumer.MaxWaitTime)
+	assert.False(t, saramaConfig.Metadata.AllowAutoTopicCreation)
+	assert.Equal(t, "burrow", saramaConfig.Net.SASL.AuthIdentity)
+}
+
+func TestGetSaramaConfigFromClientProfile_SaramaOverridesErrors(t *testing.T) {
+	viper.Reset()
+	viper.Set("client-profile.test.sarama-overrides", map[string]interface{}{"Net.NoSuchField": 1})
+	assert.PanicsWithValue(t, "client-profile 'test' has an unknown sarama-overrides path 'net.nosuchfield'",
+		func() { GetSaramaConfigFromClientProfile("test") })
+
+	viper.Set("client-profile.test.sarama-overrides", map[string]interface{}{"Net.MaxOpenRequests.Foo": 1})
+	assert.PanicsWithValue(t, "client-profile 'test' has an unknown sarama-overrides path 'net.maxopenrequests.foo'",
+		func() { GetSaramaConfigFromClientProfile("test") })
+
+	viper.Set("client-profile.test.sarama-overrides", map[string]interface{}{"Net.TLS.Config": "foo"})
+	assert.PanicsWithValue(t, "client-profile 'test' sarama-overrides path 'net.tls.config' is not a settable value",
+		func() { GetSaramaConfigFromClientProfile("test") })
+
+	viper.Set("client-profile.test.sarama-overrides", map[string]interface{}{"Net.MaxOpenRequests": "lots"})
+	_, err := GetSaramaConfigFromClientProfileE("test")
+	assert.ErrorContains(t, err, "client-profile 'test' has a bad value for sarama-overrides path 'net.maxopenrequests'")
+}
+
+func TestFetchConsumerOffsetsFromCoordinator(t *testing.T) {
+	topicPartitions := map[string][]int32{
+		"testtopic1": {0, 1},
+		"testtopic2": {0},
+	}
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.Version = sarama.V2_8_0_0
+	expected := sarama.NewOffsetFetchRequest(sarama.V2_8_0_0, "testgroup", topicPartitions)
+
+	response := &sarama.OffsetFetchResponse{Version: expected.Version}
+	response.AddBlock("testtopic1", 0, &sarama.OffsetFetchResponseBlock{Offset: 100})
+	response.AddBlock("testtopic1", 1, &sarama.OffsetFetchResponseBlock{Offset: -1})
+	response.AddBlock("testtopic2", 0, &sarama.OffsetFetchResponseBlock{Offset: 300})
+
+	coordinator := &MockSaramaBroker{}
+	coordinator.On("FetchOffset", expected).Return(response, nil).Once()
+	client := &MockSaramaClient{}
+	client.On("Config").Return(saramaConfig)
+	client.On("Coordinator", "testgroup").Return(coordinator, nil)
+
+	offsets, err := fetchConsumerOffsetsFromCoordinator(client, "testgroup", topicPartitions)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]map[int32]int64{
+		"testtopic1": {0: 100},
+		"testtopic2": {0: 300},
+	}, offsets)
+	coordinator.AssertExpectations(t)
+	client.AssertExpectations(t)
+}
+
+func TestFetchConsumerOffsetsFromCoordinator_Errors(t *testing.T) {
+	var nilBroker *BurrowSaramaBroker
+	client := &MockSaramaClient{}
+	client.On("Coordinator", "nocoordinator").Return(nilBroker, sarama.ErrConsumerCoordinatorNotAvailable)
+	_, err := fetchConsumerOffsetsFromCoordinator(client, "nocoordinator", map[string][]int32{"testtopic": {0}})
+	assert.Equal(t, sarama.ErrConsumerCoordinatorNotAvailable, err)
+
+	response := &sarama.OffsetFetchResponse{Err: sarama.ErrNotCoordinatorForConsumer}
+	coordinator := &MockSaramaBroker{}
+	coordinator.On("FetchOffset", mock.Anything).Return(response, nil)
+	client.On("Config").Return(sarama.NewConfig())
+	client.On("Coordinator", "testgroup").Return(coordinator, nil)
+	offsets, err := fetchConsumerOffsetsFromCoordinator(client, "testgroup", map[string][]int32{"testtopic": {0}})
+	assert.Nil(t, offsets)
+	assert.Equal(t, sarama.ErrNotCoordinatorForConsumer, err)
+}
+
+func TestListConsumerGroupsFromBrokers(t *testing.T) {
+	broker1 := &MockSaramaBroker{}
+	broker1.On("ListGroups", &sarama.ListGroupsRequest{}).Return(&sarama.ListGroupsResponse{
+		Groups: map[string]string{"group1": "consumer"},
+	}, nil)
+	broker2 := &MockSaramaBroker{}
+	broker2.On("ListGroups", &sarama.ListGroupsRequest{}).Return(&sarama.ListGroupsResponse{
+		Groups: map[string]string{"group2": "consumer", "group3": "connect"},
+	}, nil)
+
+	client := &MockSaramaClient{}
+	client.On("Brokers").Return([]SaramaBroker{broker1, broker2})
+
+	groups, err := listConsumerGroupsFromBrokers(client)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"group1": "consumer", "group2": "consumer", "group3": "connect"}, groups)
+	broker1.AssertExpectations(t)
+	broker2.AssertExpectations(t)
+}
+
+func TestListConsumerGroupsFromBrokers_PartialFailure(t *testing.T) {
+	broker1 := &MockSaramaBroker{}
+	broker1.On("ID").Return(int32(1))
+	broker1.On("ListGroups", mock.Anything).Return(&sarama.ListGroupsResponse{
+		Groups: map[string]string{"group1": "consumer"},
+	}, nil)
+	broker2 := &MockSaramaBroker{}
+	broker2.On("ID").Return(int32(2))
+	broker2.On("ListGroups", mock.Anything).Return(&sarama.ListGroupsResponse{}, sarama.ErrNotConnected)
+	broker3 := &MockSaramaBroker{}
+	broker3.On("ID").Return(int32(3))
+	broker3.On("ListGroups", mock.Anything).Return(&sarama.ListGroupsResponse{Err: sarama.ErrOffsetsLoadInProgress}, nil)
+
+	client := &MockSaramaClient{}
+	client.On("Brokers").Return([]SaramaBroker{broker1, broker2, broker3})
+
+	groups, err := listConsumerGroupsFromBrokers(client)
+	assert.Equal(t, map[string]string{"group1": "consumer"}, groups)
+
+	var listErr *ListConsumerGroupsError
+	assert.True(t, errors.As(err, &listErr))
+	assert.Len(t, listErr.BrokerErrors, 2)
+	assert.True(t, errors.Is(err, sarama.ErrNotConnected))
+	assert.True(t, errors.Is(err, sarama.ErrOffsetsLoadInProgress))
+	assert.Equal(t, "failed to list consumer groups on broker 2: "+sarama.ErrNotConnected.Error()+", broker 3: "+sarama.ErrOffsetsLoadInProgress.Error(), err.Error())
+}
+
+func TestListConsumerGroupsFromBrokers_NoBrokers(t *testing.T) {
+	client := &MockSaramaClient{}
+	client.On("Brokers").Return([]SaramaBroker{})
+
+	groups, err := listConsumerGroupsFromBrokers(client)
+	assert.Nil(t, groups)
+	assert.Equal(t, sarama.ErrOutOfBrokers, err)
+}
+
+func TestLogSaramaConfig(t *testing.T) {
+	viper.Reset()
+	viper.Set("client-profile.test.client-id", "testid")
+	viper.Set("client-profile.test.tls", "tlsprofile")
+	viper.Set("tls.tlsprofile.noverify", true)
+	viper.Set("client-profile.test.sasl", "saslprofile")
+	viper.Set("sasl.saslprofile.mechanism", "SCRAM-SHA-256")
+	viper.Set("sasl.saslprofile.handshake-first", true)
+	viper.Set("sasl.saslprofile.username", "burrow")
+	viper.Set("sasl.saslprofile.password", "hunter2")
+	saramaConfig := GetSaramaConfigFromClientProfile("test")
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	LogSaramaConfig(zap.New(core), "test", saramaConfig)
+
+	assert.Equal(t, 1, logs.Len())
+	entry := logs.All()[0]
+	assert.Equal(t, zapcore.DebugLevel, entry.Level)
+	fields := entry.ContextMap()
+	assert.Equal(t, "test", fields["client_profile"])
+	assert.Equal(t, "2.8.0", fields["kafka_version"])
+	assert.Equal(t, "testid", fields["client_id"])
+	assert.Equal(t, true, fields["tls_noverify"])
+	assert.Equal(t, "SCRAM-SHA-256", fields["sasl_mechanism"])
+	assert.Equal(t, true, fields["sasl_handshake"])
+	assert.Equal(t, "burrow", fields["sasl_username"])
+	assert.Equal(t, "[redacted]", fields["sasl_password"])
+	assert.NotContains(t, entry.Message+fmt.Sprint(fields), "hunter2")
+}
+
+func TestGetSaramaConfigFromClientProfile_ProducerSettings(t *testing.T) {
+	viper.Reset()
+	viper.Set("client-profile.test.client-id", "testid")
+	saramaConfig := GetSaramaConfigFromClientProfile("test")
+	defaults := sarama.NewConfig()
+	assert.Equal(t, defaults.Producer.RequiredAcks, saramaConfig.Producer.RequiredAcks)
+	assert.Equal(t, defaults.Producer.Compression, saramaConfig.Producer.Compression)
+	assert.False(t, saramaConfig.Producer.Idempotent)
+
+	viper.Set("client-profile.test.producer-acks", "leader")
+	viper.Set("client-profile.test.producer-compression", "zstd")
+	saramaConfig = GetSaramaConfigFromClientProfile("test")
+	assert.Equal(t, sarama.WaitForLocal, saramaConfig.Producer.RequiredAcks)
+	assert.Equal(t, sarama.CompressionZSTD, saramaConfig.Producer.Compression)
+
+	viper.Set("client-profile.test.producer-acks", "all")
+	viper.Set("client-profile.test.producer-compression", "lz4")
+	viper.Set("client-profile.test.producer-idempotent", true)
+	saramaConfig = GetSaramaConfigFromClientProfile("test")
+	assert.Equal(t, sarama.WaitForAll, saramaConfig.Producer.RequiredAcks)
+	assert.Equal(t, sarama.CompressionLZ4, saramaConfig.Producer.Compression)
+	assert.True(t, saramaConfig.Producer.Idempotent)
+	assert.Equal(t, 1, saramaConfig.Net.MaxOpenRequests)
+	assert.NoError(t, saramaConfig.Validate())
+}
+
+func TestGetSaramaConfigFromClientProfile_ProducerSettingsErrors(t *testing.T) {
+	viper.Reset()
+	viper.Set("client-profile.test.producer-acks", "some")
+	assert.PanicsWithValue(t, "client-profile 'test' has an unknown producer-acks 'some' (must be none, leader, or all)",
+		func() { GetSaramaConfigFromClientProfile("test") })
+
+	viper.Set("client-profile.test.producer-acks", "leader")
+	viper.Set("client-profile.test.producer-compression", "brotli")
+	assert.PanicsWithValue(t, "client-profile 'test' has an unknown producer-compression 'brotli'",
+		func() { GetSaramaConfigFromClientProfile("test") })
+
+	viper.Set("client-profile.test.producer-compression", "gzip")
+	viper.Set("client-profile.test.producer-idempotent", true)
+	assert.PanicsWithValue(t, "client-profile 'test' has producer-idempotent set, which requires producer-acks to be all",
+		func() { GetSaramaConfigFromClientProfile("test") })
+}
+
+func TestGetNewestOffsetsForTopic(t *testing.T) {
+	expected := &sarama.OffsetRequest{Version: 0}
+	expected.AddBlock("testtopic", 0, sarama.OffsetNewest, 1)
+	expected.AddBlock("testtopic", 2, sarama.OffsetNewest, 1)
+	response := &sarama.OffsetResponse{}
+	response.AddTopicPartition("testtopic", 0, 100)
+	response.AddTopicPartition("testtopic", 2, 300)
+
+	broker := &MockSaramaBroker{}
+	broker.On("ID").Return(int32(1))
+	broker.On("GetAvailableOffsets", expected).Return(response, nil).Once()
+
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.Version = sarama.V0_10_0_0
+	client := &MockSaramaClient{}
+	client.On("Config").Return(saramaConfig)
+	client.On("Partitions", "testtopic").Return([]int32{0, 1, 2}, nil)
+	client.On("WritablePartitions", "testtopic").Return([]int32{0, 2}, nil)
+	client.On("Leader", "testtopic", int32(0)).Return(broker, nil)
+	client.On("Leader", "testtopic", int32(2)).Return(broker, nil)
+
+	offsets, err := getNewestOffsetsForTopic(client, "testtopic")
+	assert.NoError(t, err)
+	assert.Equal(t, map[int32]int64{0: 100, 1: OffsetPartitionOffline, 2: 300}, offsets)
+	broker.AssertExpectations(t)
+	client.AssertExpectations(t)
+}
+
+func TestCheckKafkaConnection_Unreachable(t *testing.T) {
+	// Get an address that nothing is listening on
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err, "Expected listener setup to return no error")
+	address := listener.Addr().String()
+	listener.Close()
+
+	viper.Reset()
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.Metadata.Retry.Max = 0
+	saramaConfig.Net.DialTimeout = time.Second
+	err = CheckKafkaConnection(zap.NewNop(), "", []string{address}, saramaConfig, "__consumer_offsets")
+	assert.Error(t, err, "Expected an error for a cluster that cannot be reached")
 }
@@ -14,6 +14,9 @@ type XDGSCRAMClient struct {
 	*scram.Client
 	*scram.ClientConversation
 	scram.HashGeneratorFcn
+
+	// MinIterations is the lowest iteration count the server may request. If zero, the library default is used.
+	MinIterations int
 }
 
 func (x *XDGSCRAMClient) Begin(userName, password, authzID string) (err error) {
@@ -21,6 +24,9 @@ func (x *XDGSCRAMClient) Begin(userName, password, authzID string) (err error) {
 	if err != nil {
 		return err
 	}
+	if x.MinIterations > 0 {
+		x.Client = x.Client.WithMinIterations(x.MinIterations)
+	}
 	x.ClientConversation = x.Client.NewConversation()
 	return nil
 }
@@ -11,6 +11,7 @@ package helpers
 
 import (
 	"net"
+	"net/netip"
 	"net/url"
 	"regexp"
 	"strconv"
@@ -110,13 +111,21 @@ func ValidateHostList(hosts []string) bool {
 }
 
 // ValidateHostPort returns true if the provided string is of the form "hostname:port", where hostname is a valid
-// hostname or IP address (as parsed by ValidateIP or ValidateHostname), and port is a valid integer.
+// hostname or IP address (as parsed by ValidateIP or ValidateHostname), and port is a valid integer. An IPv6 address
+// must be in brackets, as in "[2001:db8::1]:9092", and may have a zone, as in "[fe80::1%eth0]:9092". Only IPv6
+// addresses may be in brackets.
 func ValidateHostPort(host string, allowBlankHost bool) bool {
 	// Must be hostname:port, ipv4:port, or [ipv6]:port. Optionally allow blank hostname
 	hostname, portString, err := net.SplitHostPort(host)
 	if err != nil {
 		return false
 	}
+	if strings.HasPrefix(host, "[") {
+		addr, err := netip.ParseAddr(hostname)
+		if (err != nil) || (!addr.Is6()) {
+			return false
+		}
+	}
 
 	// Validate the port is a numeric (yeah, strings are valid in some places, but we don't support it)
 	_, err = strconv.Atoi(portString)
@@ -129,9 +138,9 @@ func ValidateHostPort(host string, allowBlankHost bool) bool {
 		return true
 	}
 
-	// Only IPv6 can contain :
-	if strings.Contains(hostname, ":") && (!ValidateIP(hostname)) {
-		return false
+	// Only IPv6 can contain :, and SplitHostPort only allows that when it is in brackets, which is checked above
+	if strings.Contains(hostname, ":") {
+		return true
 	}
 
 	// If all the parts of the hostname are numbers, validate as IP. Otherwise, it's a hostname
@@ -174,6 +174,13 @@ var testHostPorts = []TestSet{
 	{"[2001:db8::1]:3453", true},
 	{"2001:db8:0:1:1:1:1:1:3453", false},
 	{"[2001:0db8:0a0b:12f0:0000:0000:0000:0001:0004]:4533", false},
+	{"[::1]:9092", true},
+	{"[::ffff:10.0.0.1]:9092", true},
+	{"[fe80::1%eth0]:9092", true},
+	{"::1:9092", false},
+	{"[2001:db8::1]", false},
+	{"[1.2.3.4]:9092", false},
+	{"[host.example.com]:9092", false},
 	{"hostname:3432", true},
 	{"host0:4234", true},
 	{"host.example.com:23", true},
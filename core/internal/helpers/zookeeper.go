@@ -39,25 +39,71 @@ func ZookeeperConnect(servers []string, sessionTimeout time.Duration, logger *za
 	return &BurrowZookeeperClient{client: zkconn}, connEventChan, err
 }
 
-// ZookeeperConnectTLS establishes a new TLS connection to a pool of Zookeeper servers. The provided session timeout sets the
-// amount of time for which a session is considered valid after losing connection to a server. Within the session
-// timeout it's possible to reestablish a connection to a different server and keep the same session. This is means any
-// ephemeral nodes and watches are maintained. The certificates are read from the configured zookeeper.tls profile.
-func ZookeeperConnectTLS(servers []string, sessionTimeout time.Duration, logger *zap.Logger) (protocol.ZookeeperClient, <-chan zk.Event, error) {
-	tlsName := viper.GetString("zookeeper.tls")
-	caFile := viper.GetString("tls." + tlsName + ".cafile")
-	certFile := viper.GetString("tls." + tlsName + ".certfile")
-	keyFile := viper.GetString("tls." + tlsName + ".keyfile")
+// ZookeeperConnectSecure establishes a new connection to the pool of Zookeeper servers for Burrow itself, using the
+// profiles referenced by the zookeeper.tls and zookeeper.digest-auth configs. See ZookeeperConnectWithProfiles.
+func ZookeeperConnectSecure(servers []string, sessionTimeout time.Duration, logger *zap.Logger) (protocol.ZookeeperClient, <-chan zk.Event, error) {
+	return ZookeeperConnectWithProfiles(servers, sessionTimeout, viper.GetString("zookeeper.tls"), viper.GetString("zookeeper.digest-auth"), logger)
+}
 
-	logger.Info("starting zookeeper (TLS)", zap.String("caFile", caFile), zap.String("certFile", certFile), zap.String("keyFile", keyFile))
+// ZookeeperConnectWithProfiles establishes a new connection to a pool of Zookeeper servers, using the named tls and
+// sasl profiles. The provided session timeout sets the amount of time for which a session is considered valid after
+// losing connection to a server. If tlsProfile is not empty, the connection uses TLS with the certificates from that
+// tls profile. If digestAuthProfile is not empty, the username and password from that sasl profile are added to the
+// session with Zookeeper digest authentication (the same as "addauth digest" in the Zookeeper CLI), so that nodes
+// with a digest ACL for that user can be read. This is not SASL authentication, which the Zookeeper client does not
+// support. The credentials are sent again whenever the client reconnects.
+func ZookeeperConnectWithProfiles(servers []string, sessionTimeout time.Duration, tlsProfile, digestAuthProfile string, logger *zap.Logger) (protocol.ZookeeperClient, <-chan zk.Event, error) {
+	var options []zk.ConnOption
+	if tlsProfile != "" {
+		caFile := viper.GetString("tls." + tlsProfile + ".cafile")
+		certFile := viper.GetString("tls." + tlsProfile + ".certfile")
+		keyFile := viper.GetString("tls." + tlsProfile + ".keyfile")
+
+		logger.Info("starting zookeeper (TLS)", zap.String("caFile", caFile), zap.String("certFile", certFile), zap.String("keyFile", keyFile))
+
+		dialer, err := newTLSDialer(caFile, certFile, keyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		options = append(options, zk.WithDialer(dialer))
+	}
 
-	dialer, err := newTLSDialer(caFile, certFile, keyFile)
+	zkconn, connEventChan, err := zk.Connect(servers, sessionTimeout, options...)
 	if err != nil {
-		return nil, nil, err
+		return &BurrowZookeeperClient{client: zkconn}, connEventChan, err
 	}
 
-	zkconn, connEventChan, err := zk.Connect(servers, sessionTimeout, zk.WithDialer(dialer))
-	return &BurrowZookeeperClient{client: zkconn}, connEventChan, err
+	if digestAuthProfile != "" {
+		username := viper.GetString("sasl." + digestAuthProfile + ".username")
+		password := viper.GetString("sasl." + digestAuthProfile + ".password")
+
+		logger.Info("adding digest auth to zookeeper session", zap.String("sasl", digestAuthProfile), zap.String("username", username))
+		if err := zkconn.AddAuth("digest", []byte(username+":"+password)); err != nil {
+			zkconn.Close()
+			return nil, nil, errors.New("failed to add digest auth to zookeeper with sasl profile '" + digestAuthProfile + "': " + err.Error())
+		}
+	}
+	return &BurrowZookeeperClient{client: zkconn}, connEventChan, nil
+}
+
+// ValidateZookeeperProfiles checks the tls and sasl profiles that a Zookeeper connection is set to use, which are
+// named in the error as referenced by owner (such as "zookeeper"). An error is returned if either profile does not
+// exist, or if the sasl profile for digest auth does not have a username. The mechanism of the sasl profile is not
+// used.
+func ValidateZookeeperProfiles(owner, tlsProfile, digestAuthProfile string) error {
+	if (tlsProfile != "") && (!viper.IsSet("tls." + tlsProfile)) {
+		return errors.New(owner + " references an unknown tls profile '" + tlsProfile + "'")
+	}
+
+	if digestAuthProfile != "" {
+		if !viper.IsSet("sasl." + digestAuthProfile) {
+			return errors.New(owner + " references an unknown sasl profile '" + digestAuthProfile + "'")
+		}
+		if viper.GetString("sasl."+digestAuthProfile+".username") == "" {
+			return errors.New("sasl profile '" + digestAuthProfile + "' must have a username to be used for zookeeper digest auth")
+		}
+	}
+	return nil
 }
 
 // newTLSDialer creates a dialer with TLS configured. It will install caFile as root CA and if both certFile and keyFile are
@@ -12,6 +12,7 @@ package helpers
 import (
 	"testing"
 
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/linkedin/Burrow/core/protocol"
@@ -24,3 +25,30 @@ func TestBurrowZookeeperClient_ImplementsZookeeperClient(t *testing.T) {
 func TestMockZookeeperClient_ImplementsZookeeperClient(t *testing.T) {
 	assert.Implements(t, (*protocol.ZookeeperClient)(nil), new(MockZookeeperClient))
 }
+
+var testZookeeperProfiles = []struct {
+	settings          map[string]interface{}
+	tlsProfile        string
+	digestAuthProfile string
+	isValid           bool
+}{
+	{map[string]interface{}{}, "", "", true},
+	{map[string]interface{}{"tls.zktls.cafile": "/etc/ca.pem"}, "zktls", "", true},
+	{map[string]interface{}{}, "notls", "", false},
+	{map[string]interface{}{"sasl.zkauth.username": "burrow", "sasl.zkauth.password": "secret"}, "", "zkauth", true},
+	{map[string]interface{}{"sasl.zkauth.username": "burrow", "sasl.zkauth.mechanism": "SCRAM-SHA-512"}, "", "zkauth", true},
+	{map[string]interface{}{}, "", "noauth", false},
+	{map[string]interface{}{"sasl.zkauth.password": "secret"}, "", "zkauth", false},
+}
+
+func TestValidateZookeeperProfiles(t *testing.T) {
+	for i, testSet := range testZookeeperProfiles {
+		viper.Reset()
+		for key, value := range testSet.settings {
+			viper.Set(key, value)
+		}
+
+		err := ValidateZookeeperProfiles("zookeeper", testSet.tlsProfile, testSet.digestAuthProfile)
+		assert.Equalf(t, testSet.isValid, err == nil, "Test %v - Expected valid to be %v, got error %v", i, testSet.isValid, err)
+	}
+}
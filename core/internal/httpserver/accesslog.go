@@ -0,0 +1,94 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package httpserver
+
+import (
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// accessLogHandler wraps the handler for a listener that has access-log or slow-request-threshold set, and logs each
+// request with its method, path, status, response size, and duration. With access-log, the access-log-sample-rate
+// fraction of the requests are logged at info. Requests that take longer than the slow threshold are always logged,
+// at warn.
+type accessLogHandler struct {
+	handler       http.Handler
+	log           *zap.Logger
+	enabled       bool
+	sampleRate    float64
+	slowThreshold time.Duration
+	randomFunc    func() float64
+}
+
+func (h *accessLogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	lw := &accessLogResponseWriter{ResponseWriter: w}
+	h.handler.ServeHTTP(lw, r)
+	duration := time.Since(start)
+
+	// Streamed responses are expected to stay open, so they are never slow
+	slow := (h.slowThreshold > 0) && (duration > h.slowThreshold) && (lw.Header().Get("Content-Type") != "application/x-ndjson")
+	if !slow && (!h.enabled || (h.randomFunc() >= h.sampleRate)) {
+		return
+	}
+
+	status := lw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	fields := []zap.Field{
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+		zap.Int("status", status),
+		zap.Int64("bytes", lw.bytes),
+		zap.Duration("duration", duration),
+		zap.String("remote", r.RemoteAddr),
+	}
+	if slow {
+		h.log.Warn("slow request", fields...)
+	} else {
+		h.log.Info("request", fields...)
+	}
+}
+
+// accessLogResponseWriter records the status and the number of bytes written for the response
+type accessLogResponseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (lw *accessLogResponseWriter) WriteHeader(statusCode int) {
+	if lw.status == 0 {
+		lw.status = statusCode
+	}
+	lw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (lw *accessLogResponseWriter) Write(b []byte) (int, error) {
+	if lw.status == 0 {
+		lw.status = http.StatusOK
+	}
+	n, err := lw.ResponseWriter.Write(b)
+	lw.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap returns the original writer, so that http.ResponseController can reach it
+func (lw *accessLogResponseWriter) Unwrap() http.ResponseWriter {
+	return lw.ResponseWriter
+}
+
+// Flush sends the data that has been written so far to the client, for responses that are streamed
+func (lw *accessLogResponseWriter) Flush() {
+	http.NewResponseController(lw.ResponseWriter).Flush()
+}
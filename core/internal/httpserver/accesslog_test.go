@@ -0,0 +1,110 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestHttpServer_Configure_AccessLog(t *testing.T) {
+	coordinator := fixtureConfiguredCoordinator()
+	_, ok := coordinator.servers["default"].Handler.(*accessLogHandler)
+	assert.False(t, ok, "Expected the access log to be off by default")
+
+	viper.Set("httpserver.default.access-log", true)
+	viper.Set("httpserver.default.access-log-sample-rate", 0.25)
+	viper.Set("httpserver.default.cors-allowed-origins", []string{"*"})
+	coordinator.Configure()
+	handler, ok := coordinator.servers["default"].Handler.(*accessLogHandler)
+	assert.True(t, ok, "Expected the handler to be wrapped for the access log")
+	assert.True(t, handler.enabled, "Expected the access log to be enabled")
+	assert.Equalf(t, 0.25, handler.sampleRate, "Expected sample rate to be 0.25, not %v", handler.sampleRate)
+	_, ok = handler.handler.(*corsHandler)
+	assert.True(t, ok, "Expected the access log to wrap the CORS handler")
+}
+
+func TestHttpServer_Configure_AccessLogBadConfig(t *testing.T) {
+	coordinator := fixtureConfiguredCoordinator()
+	viper.Set("httpserver.default.access-log-sample-rate", 1.5)
+	assert.Panics(t, coordinator.Configure, "The code did not panic")
+
+	coordinator = fixtureConfiguredCoordinator()
+	viper.Set("httpserver.default.slow-request-threshold", -1)
+	assert.Panics(t, coordinator.Configure, "The code did not panic")
+}
+
+func fixtureAccessLogHandler(handler http.HandlerFunc, enabled bool, slowThreshold time.Duration) (*accessLogHandler, *observer.ObservedLogs) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	return &accessLogHandler{
+		handler:       handler,
+		log:           zap.New(core),
+		enabled:       enabled,
+		sampleRate:    0.5,
+		slowThreshold: slowThreshold,
+		randomFunc:    func() float64 { return 0.25 },
+	}, logs
+}
+
+func TestHttpServer_accessLogHandler(t *testing.T) {
+	handler, logs := fixtureAccessLogHandler(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("not here"))
+	}, true, 0)
+
+	req, err := http.NewRequest("GET", "/v3/kafka/testcluster/consumer", http.NoBody)
+	assert.NoError(t, err, "Expected request setup to return no error")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := logs.TakeAll()
+	assert.Lenf(t, entries, 1, "Expected 1 log entry, not %v", len(entries))
+	assert.Equal(t, zapcore.InfoLevel, entries[0].Level, "Expected the request to be logged at info")
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "GET", fields["method"], "Expected the method to be logged")
+	assert.Equal(t, "/v3/kafka/testcluster/consumer", fields["path"], "Expected the path to be logged")
+	assert.Equal(t, int64(http.StatusNotFound), fields["status"], "Expected the status to be logged")
+	assert.Equal(t, int64(8), fields["bytes"], "Expected the response size to be logged")
+	assert.Contains(t, fields, "duration", "Expected the duration to be logged")
+
+	// A request that is not in the sample is not logged
+	handler.randomFunc = func() float64 { return 0.75 }
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, 0, logs.Len(), "Expected the request outside of the sample to not be logged")
+}
+
+func TestHttpServer_accessLogHandler_Slow(t *testing.T) {
+	handler, logs := fixtureAccessLogHandler(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("done"))
+	}, false, 10*time.Millisecond)
+	handler.randomFunc = func() float64 { return 0.75 }
+
+	req, err := http.NewRequest("GET", "/v3/kafka", http.NoBody)
+	assert.NoError(t, err, "Expected request setup to return no error")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := logs.TakeAll()
+	assert.Lenf(t, entries, 1, "Expected 1 log entry, not %v", len(entries))
+	assert.Equal(t, zapcore.WarnLevel, entries[0].Level, "Expected the slow request to be logged at warn")
+	assert.Equal(t, int64(http.StatusOK), entries[0].ContextMap()["status"], "Expected the implicit status to be logged")
+
+	// A fast request is not logged when only the slow threshold is set
+	handler.slowThreshold = time.Minute
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, 0, logs.Len(), "Expected the fast request to not be logged")
+}
@@ -0,0 +1,52 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package httpserver
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// authHandler wraps the handler for a listener that has an auth-token or client-tls set. A request must present the
+// token as a bearer token in the Authorization header, or it gets a 401 response. If client certificates are required,
+// a request over a connection without a verified client certificate gets a 403 response. The healthcheck URLs are not
+// authenticated, so that load balancers can still check the listener.
+type authHandler struct {
+	handler           http.Handler
+	token             string
+	requireClientCert bool
+}
+
+func (h *authHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if (r.URL.Path == "/burrow/admin") || (r.URL.Path == "/burrow/admin/ready") {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+
+	if h.requireClientCert && ((r.TLS == nil) || (len(r.TLS.PeerCertificates) == 0)) {
+		http.Error(w, "{\"error\":true,\"message\":\"client certificate required\",\"result\":{}}", http.StatusForbidden)
+		return
+	}
+	if (h.token != "") && (!h.checkToken(r.Header.Get("Authorization"))) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "{\"error\":true,\"message\":\"invalid or missing bearer token\",\"result\":{}}", http.StatusUnauthorized)
+		return
+	}
+	h.handler.ServeHTTP(w, r)
+}
+
+// checkToken compares the bearer token in an Authorization header to the configured token in constant time
+func (h *authHandler) checkToken(header string) bool {
+	if (len(header) < 7) || (!strings.EqualFold(header[:7], "Bearer ")) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimSpace(header[7:])), []byte(h.token)) == 1
+}
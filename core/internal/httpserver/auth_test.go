@@ -0,0 +1,101 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package httpserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHttpServer_Configure_AuthToken(t *testing.T) {
+	coordinator := fixtureConfiguredCoordinator()
+	_, ok := coordinator.servers["default"].Handler.(*authHandler)
+	assert.False(t, ok, "Expected auth to be off by default")
+
+	viper.Set("httpserver.default.auth-token", "testtoken")
+	viper.Set("httpserver.default.compress", true)
+	coordinator.Configure()
+	handler, ok := coordinator.servers["default"].Handler.(*authHandler)
+	assert.True(t, ok, "Expected the handler to be wrapped for auth")
+	assert.Equal(t, "testtoken", handler.token, "Expected token to be set")
+	assert.False(t, handler.requireClientCert, "Expected client certificates to not be required")
+	_, ok = handler.handler.(*compressHandler)
+	assert.True(t, ok, "Expected auth to wrap the compressed handler")
+}
+
+func TestHttpServer_Configure_ClientTLSWithoutTLS(t *testing.T) {
+	coordinator := fixtureConfiguredCoordinator()
+	viper.Set("tls.clienttls.cafile", "/etc/ca.pem")
+	viper.Set("httpserver.default.client-tls", "clienttls")
+	assert.Panics(t, coordinator.Configure, "The code did not panic")
+}
+
+var authTests = []struct {
+	path          string
+	authorization string
+	clientCert    bool
+	status        int
+}{
+	/*0*/ {"/v3/admin/loglevel", "", true, http.StatusUnauthorized},
+	/*1*/ {"/v3/admin/loglevel", "Bearer wrongtoken", true, http.StatusUnauthorized},
+	/*2*/ {"/v3/admin/loglevel", "Basic testtoken", true, http.StatusUnauthorized},
+	/*3*/ {"/v3/admin/loglevel", "Bearer testtoken", true, http.StatusOK},
+	/*4*/ {"/v3/admin/loglevel", "bearer testtoken", true, http.StatusOK},
+	/*5*/ {"/v3/admin/loglevel", "Bearer testtoken", false, http.StatusForbidden},
+	/*6*/ {"/burrow/admin", "", false, http.StatusOK},
+}
+
+func TestHttpServer_authHandler(t *testing.T) {
+	coordinator := fixtureConfiguredCoordinator()
+	handler := &authHandler{
+		handler:           coordinator.router,
+		token:             "testtoken",
+		requireClientCert: true,
+	}
+
+	for i, testSet := range authTests {
+		req, err := http.NewRequest("GET", testSet.path, http.NoBody)
+		assert.NoError(t, err, "Expected request setup to return no error")
+		if testSet.authorization != "" {
+			req.Header.Set("Authorization", testSet.authorization)
+		}
+		if testSet.clientCert {
+			req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{}}}
+		}
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equalf(t, testSet.status, rr.Code, "TEST %v: Expected response code to be %v, not %v", i, testSet.status, rr.Code)
+		if testSet.status == http.StatusUnauthorized {
+			assert.Equalf(t, "Bearer", rr.Header().Get("WWW-Authenticate"), "TEST %v: Expected WWW-Authenticate header", i)
+		}
+	}
+}
+
+func TestHttpServer_authHandler_TokenOnly(t *testing.T) {
+	coordinator := fixtureConfiguredCoordinator()
+	handler := &authHandler{
+		handler: coordinator.router,
+		token:   "testtoken",
+	}
+
+	req, err := http.NewRequest("GET", "/v3/admin/loglevel", http.NoBody)
+	assert.NoError(t, err, "Expected request setup to return no error")
+	req.Header.Set("Authorization", "Bearer testtoken")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equalf(t, http.StatusOK, rr.Code, "Expected response code to be 200, not %v", rr.Code)
+}
@@ -0,0 +1,133 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package httpserver
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// compressHandler wraps the router for a listener that has compress set. Responses are compressed with gzip or
+// deflate, if the client accepts either of them, preferring gzip.
+type compressHandler struct {
+	handler http.Handler
+}
+
+func (h *compressHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	encoding := getAcceptedEncoding(r.Header.Get("Accept-Encoding"))
+	if (encoding == "") || (r.Method == http.MethodHead) {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+
+	cw := &compressResponseWriter{
+		ResponseWriter: w,
+		encoding:       encoding,
+	}
+	defer cw.Close()
+	h.handler.ServeHTTP(cw, r)
+}
+
+// getAcceptedEncoding returns "gzip" or "deflate" if the Accept-Encoding header allows it, or an empty string if the
+// response should not be compressed. An encoding with a quality of zero is not accepted.
+func getAcceptedEncoding(header string) string {
+	accepted := ""
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		refused := false
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				quality, err := strconv.ParseFloat(param[2:], 64)
+				refused = (err == nil) && (quality == 0)
+			}
+		}
+		if refused {
+			continue
+		}
+		switch name {
+		case "gzip":
+			return "gzip"
+		case "deflate":
+			accepted = "deflate"
+		}
+	}
+	return accepted
+}
+
+// compressResponseWriter compresses the body of the response. The compressor is created when the status is written,
+// so responses that cannot have a body (such as 204 and 304) are left alone.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding    string
+	writer      io.WriteCloser
+	wroteHeader bool
+}
+
+func (cw *compressResponseWriter) WriteHeader(statusCode int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+
+	if (statusCode >= http.StatusOK) && (statusCode != http.StatusNoContent) && (statusCode != http.StatusNotModified) {
+		header := cw.Header()
+		header.Set("Content-Encoding", cw.encoding)
+		header.Del("Content-Length")
+		if cw.encoding == "gzip" {
+			cw.writer = gzip.NewWriter(cw.ResponseWriter)
+		} else {
+			// This can only fail for an invalid compression level
+			cw.writer, _ = flate.NewWriter(cw.ResponseWriter, flate.DefaultCompression)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (cw *compressResponseWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.writer == nil {
+		return cw.ResponseWriter.Write(b)
+	}
+	return cw.writer.Write(b)
+}
+
+// Flush sends the compressed data that has been written so far to the client, for responses that are streamed
+func (cw *compressResponseWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if flusher, ok := cw.writer.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Unwrap returns the original writer, so that http.ResponseController can reach it
+func (cw *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Close flushes the rest of the compressed body, if there is one
+func (cw *compressResponseWriter) Close() error {
+	if cw.writer == nil {
+		return nil
+	}
+	return cw.writer.Close()
+}
@@ -0,0 +1,108 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package httpserver
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+var acceptedEncodingTests = []struct {
+	header   string
+	encoding string
+}{
+	/*0*/ {"", ""},
+	/*1*/ {"gzip", "gzip"},
+	/*2*/ {"deflate, gzip;q=1.0, *;q=0.5", "gzip"},
+	/*3*/ {"deflate", "deflate"},
+	/*4*/ {"gzip;q=0, deflate", "deflate"},
+	/*5*/ {"GZIP; q=0.000", ""},
+	/*6*/ {"br, identity", ""},
+}
+
+func TestHttpServer_getAcceptedEncoding(t *testing.T) {
+	for i, testSet := range acceptedEncodingTests {
+		result := getAcceptedEncoding(testSet.header)
+		assert.Equalf(t, testSet.encoding, result, "TEST %v: Expected encoding '%v', not '%v'", i, testSet.encoding, result)
+	}
+}
+
+func TestHttpServer_Configure_Compress(t *testing.T) {
+	coordinator := fixtureConfiguredCoordinator()
+	_, ok := coordinator.servers["default"].Handler.(*compressHandler)
+	assert.False(t, ok, "Expected compression to be off by default")
+
+	viper.Set("httpserver.default.compress", true)
+	coordinator.Configure()
+	_, ok = coordinator.servers["default"].Handler.(*compressHandler)
+	assert.True(t, ok, "Expected the router to be wrapped for compression")
+}
+
+func TestHttpServer_compressHandler(t *testing.T) {
+	coordinator := fixtureConfiguredCoordinator()
+	handler := &compressHandler{handler: coordinator.router}
+
+	for _, encoding := range []string{"gzip", "deflate"} {
+		req, err := http.NewRequest("GET", "/v3/admin/loglevel", http.NoBody)
+		assert.NoError(t, err, "Expected request setup to return no error")
+		req.Header.Set("Accept-Encoding", encoding)
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equalf(t, http.StatusOK, rr.Code, "Expected response code to be 200, not %v", rr.Code)
+		assert.Equalf(t, encoding, rr.Header().Get("Content-Encoding"), "Expected Content-Encoding to be %v, not %v", encoding, rr.Header().Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", rr.Header().Get("Vary"), "Expected Vary header to be set")
+
+		var reader io.Reader
+		if encoding == "gzip" {
+			reader, err = gzip.NewReader(rr.Body)
+			assert.NoError(t, err, "Expected gzip reader to return no error")
+		} else {
+			reader = flate.NewReader(rr.Body)
+		}
+		var resp httpResponseLogLevel
+		err = json.NewDecoder(reader).Decode(&resp)
+		assert.NoErrorf(t, err, "Expected %v body decode to return no error", encoding)
+		assert.Equalf(t, "info", resp.Level, "Expected Level to be info, not %v", resp.Level)
+	}
+
+	// Without Accept-Encoding, the response is not compressed
+	req, err := http.NewRequest("GET", "/v3/admin/loglevel", http.NoBody)
+	assert.NoError(t, err, "Expected request setup to return no error")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, "", rr.Header().Get("Content-Encoding"), "Expected no Content-Encoding")
+	var resp httpResponseLogLevel
+	err = json.NewDecoder(rr.Body).Decode(&resp)
+	assert.NoError(t, err, "Expected body decode to return no error")
+}
+
+func TestHttpServer_compressHandler_NoBody(t *testing.T) {
+	handler := &compressHandler{handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})}
+
+	req, err := http.NewRequest("GET", "/", http.NoBody)
+	assert.NoError(t, err, "Expected request setup to return no error")
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equalf(t, http.StatusNoContent, rr.Code, "Expected response code to be 204, not %v", rr.Code)
+	assert.Equal(t, "", rr.Header().Get("Content-Encoding"), "Expected no Content-Encoding")
+	assert.Equal(t, 0, rr.Body.Len(), "Expected no body")
+}
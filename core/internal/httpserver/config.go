@@ -85,23 +85,21 @@ func (hc *Coordinator) configStorageList(w http.ResponseWriter, r *http.Request,
 
 func (hc *Coordinator) configConsumerList(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	modules := viper.GetStringMap("consumer")
-	moduleList := make([]string, len(modules))
-	i := 0
+	moduleList := make([]string, 0, len(modules))
 	for name := range modules {
-		moduleList[i] = name
-		i++
+		moduleList = append(moduleList, name)
 	}
+	moduleList = append(moduleList, hc.App.DiscoveredConsumers.Names()...)
 	hc.writeModuleListResponse(w, r, "consumer", moduleList)
 }
 
 func (hc *Coordinator) configClusterList(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	modules := viper.GetStringMap("cluster")
-	moduleList := make([]string, len(modules))
-	i := 0
+	moduleList := make([]string, 0, len(modules))
 	for name := range modules {
-		moduleList[i] = name
-		i++
+		moduleList = append(moduleList, name)
 	}
+	moduleList = append(moduleList, hc.App.DiscoveredClusters.Names()...)
 	hc.writeModuleListResponse(w, r, "cluster", moduleList)
 }
 
@@ -149,8 +147,13 @@ func (hc *Coordinator) configStorageDetail(w http.ResponseWriter, r *http.Reques
 }
 
 func (hc *Coordinator) configConsumerDetail(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	// A consumer for a discovered cluster is not in the global configuration, and has its own
+	config, ok := hc.App.DiscoveredConsumers.Get(params.ByName("name"))
+	if !ok {
+		config = viper.GetViper()
+	}
 	configRoot := "consumer." + params.ByName("name")
-	if !viper.IsSet(configRoot) {
+	if !config.IsSet(configRoot) {
 		hc.writeErrorResponse(w, r, http.StatusNotFound, "consumer module not found")
 	} else {
 		requestInfo := makeRequestInfo(r)
@@ -158,15 +161,15 @@ func (hc *Coordinator) configConsumerDetail(w http.ResponseWriter, r *http.Reque
 			Error:   false,
 			Message: "consumer module detail returned",
 			Module: httpResponseConfigModuleConsumer{
-				ClassName:        viper.GetString(configRoot + ".class-name"),
-				Cluster:          viper.GetString(configRoot + ".cluster"),
-				Servers:          viper.GetStringSlice(configRoot + ".servers"),
-				GroupAllowlist:   viper.GetString(configRoot + ".group-allowlist"),
-				ZookeeperPath:    viper.GetString(configRoot + ".zookeeper-path"),
-				ZookeeperTimeout: int32(viper.GetInt64(configRoot + ".zookeeper-timeout")),
-				ClientProfile:    getClientProfile(viper.GetString(configRoot + ".client-profile")),
-				OffsetsTopic:     viper.GetString(configRoot + ".offsets-topic"),
-				StartLatest:      viper.GetBool(configRoot + ".start-latest"),
+				ClassName:        config.GetString(configRoot + ".class-name"),
+				Cluster:          config.GetString(configRoot + ".cluster"),
+				Servers:          config.GetStringSlice(configRoot + ".servers"),
+				GroupAllowlist:   config.GetString(configRoot + ".group-allowlist"),
+				ZookeeperPath:    config.GetString(configRoot + ".zookeeper-path"),
+				ZookeeperTimeout: int32(config.GetInt64(configRoot + ".zookeeper-timeout")),
+				ClientProfile:    getClientProfile(config, config.GetString(configRoot+".client-profile")),
+				OffsetsTopic:     config.GetString(configRoot + ".offsets-topic"),
+				StartLatest:      config.GetBool(configRoot + ".start-latest"),
 			},
 			Request: requestInfo,
 		})
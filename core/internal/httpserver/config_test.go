@@ -18,6 +18,8 @@ import (
 
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/linkedin/Burrow/core/protocol"
 )
 
 func setupConfiguration() {
@@ -126,6 +128,54 @@ func TestHttpServer_configClusterList(t *testing.T) {
 	assert.Equalf(t, []string{"testcluster"}, resp.Modules, "Expected Modules to be [testcluster], not %v", resp.Modules)
 }
 
+func TestHttpServer_configDiscovered(t *testing.T) {
+	coordinator := fixtureConfiguredCoordinator()
+	setupConfiguration()
+	config := viper.New()
+	config.Set("cluster.east.class-name", "kafka")
+	config.Set("cluster.east.servers", []string{"broker1.example.com:1234"})
+	config.Set("consumer.east.class-name", "kafka")
+	config.Set("consumer.east.cluster", "east")
+	coordinator.App.DiscoveredClusters = &protocol.DiscoveredClusters{}
+	coordinator.App.DiscoveredClusters.Add("east", config)
+	coordinator.App.DiscoveredConsumers = &protocol.DiscoveredConsumers{}
+	coordinator.App.DiscoveredConsumers.Add("east", config)
+
+	// The discovered cluster and consumer are listed after the configured ones
+	for _, module := range []string{"cluster", "consumer"} {
+		req, err := http.NewRequest("GET", "/v3/config/"+module, http.NoBody)
+		assert.NoError(t, err, "Expected request setup to return no error")
+		rr := httptest.NewRecorder()
+		coordinator.router.ServeHTTP(rr, req)
+		assert.Equalf(t, http.StatusOK, rr.Code, "Expected response code to be 200, not %v", rr.Code)
+
+		var resp httpResponseConfigModuleList
+		err = json.NewDecoder(rr.Body).Decode(&resp)
+		assert.NoError(t, err, "Expected body decode to return no error")
+		assert.Containsf(t, resp.Modules, "east", "Expected the discovered %v to be listed", module)
+	}
+
+	// The details come from the configuration of the discovered cluster
+	req, err := http.NewRequest("GET", "/v3/config/consumer/east", http.NoBody)
+	assert.NoError(t, err, "Expected request setup to return no error")
+	rr := httptest.NewRecorder()
+	coordinator.router.ServeHTTP(rr, req)
+	assert.Equalf(t, http.StatusOK, rr.Code, "Expected response code to be 200, not %v", rr.Code)
+
+	var resp struct {
+		Module httpResponseConfigModuleConsumer `json:"module"`
+	}
+	err = json.NewDecoder(rr.Body).Decode(&resp)
+	assert.NoError(t, err, "Expected body decode to return no error")
+	assert.Equalf(t, "east", resp.Module.Cluster, "Expected Cluster to be east, not %v", resp.Module.Cluster)
+
+	req, err = http.NewRequest("GET", "/v3/kafka/east", http.NoBody)
+	assert.NoError(t, err, "Expected request setup to return no error")
+	rr = httptest.NewRecorder()
+	coordinator.router.ServeHTTP(rr, req)
+	assert.Equalf(t, http.StatusOK, rr.Code, "Expected response code to be 200, not %v", rr.Code)
+}
+
 func TestHttpServer_configEvaluatorList(t *testing.T) {
 	coordinator := fixtureConfiguredCoordinator()
 	setupConfiguration()
@@ -18,6 +18,7 @@ import (
 	"crypto/x509"
 	"encoding/json"
 	"errors"
+	"math/rand"
 	"net"
 	"net/http"
 	"os"
@@ -54,6 +55,19 @@ type Coordinator struct {
 //
 // If no listener has been configured, the coordinator will set up a default listener on a random port greater than
 // 1024, as selected by the net.Listener call. This listener will be logged so that the port chosen will be known.
+//
+// If compress is set for a listener, responses are compressed with gzip (or deflate) for clients that send an
+// Accept-Encoding header that allows it.
+//
+// Access to a listener can be restricted with auth-token, a bearer token that must be sent in the Authorization header
+// of each request, and with client-tls, the name of a tls profile whose cafile is used to verify client certificates.
+// Requests that fail either check get a 401 or 403 response. The healthcheck URLs are not authenticated.
+//
+// The timeout for a listener (300 seconds by default) applies to reading requests, writing responses, and idle
+// keep-alive connections. Each of them can be set separately with read-timeout (which also covers the headers),
+// write-timeout, and idle-timeout. The size of the request headers is limited by max-header-bytes (1 MB by default), and
+// the size of the request body by max-body-bytes (unlimited by default). A negative value for any of these will cause
+// the func to panic.
 func (hc *Coordinator) Configure() {
 	hc.Log.Info("configuring")
 	hc.router = httprouter.New()
@@ -82,10 +96,30 @@ func (hc *Coordinator) Configure() {
 
 		viper.SetDefault(configRoot+".timeout", 300)
 		timeout := viper.GetInt(configRoot + ".timeout")
-		server.ReadTimeout = time.Duration(timeout) * time.Second
-		server.ReadHeaderTimeout = time.Duration(timeout) * time.Second
-		server.WriteTimeout = time.Duration(timeout) * time.Second
-		server.IdleTimeout = time.Duration(timeout) * time.Second
+		viper.SetDefault(configRoot+".read-timeout", timeout)
+		viper.SetDefault(configRoot+".write-timeout", timeout)
+		viper.SetDefault(configRoot+".idle-timeout", timeout)
+		readTimeout := viper.GetInt(configRoot + ".read-timeout")
+		writeTimeout := viper.GetInt(configRoot + ".write-timeout")
+		idleTimeout := viper.GetInt(configRoot + ".idle-timeout")
+		if (timeout < 0) || (readTimeout < 0) || (writeTimeout < 0) || (idleTimeout < 0) {
+			panic("HTTP server timeouts must not be negative")
+		}
+		server.ReadTimeout = time.Duration(readTimeout) * time.Second
+		server.ReadHeaderTimeout = time.Duration(readTimeout) * time.Second
+		server.WriteTimeout = time.Duration(writeTimeout) * time.Second
+		server.IdleTimeout = time.Duration(idleTimeout) * time.Second
+
+		server.MaxHeaderBytes = viper.GetInt(configRoot + ".max-header-bytes")
+		if server.MaxHeaderBytes < 0 {
+			panic("HTTP server max-header-bytes must not be negative")
+		}
+		maxBodyBytes := viper.GetInt64(configRoot + ".max-body-bytes")
+		if maxBodyBytes < 0 {
+			panic("HTTP server max-body-bytes must not be negative")
+		} else if maxBodyBytes > 0 {
+			server.Handler = http.MaxBytesHandler(server.Handler, maxBodyBytes)
+		}
 		keyFile := ""
 		certFile := ""
 		if viper.IsSet(configRoot + ".tls") {
@@ -114,6 +148,66 @@ func (hc *Coordinator) Configure() {
 			}
 			server.TLSConfig.Certificates = []tls.Certificate{cert}
 		}
+		requireClientCert := false
+		if viper.IsSet(configRoot + ".client-tls") {
+			if server.TLSConfig == nil {
+				panic("HTTP server client-tls specified without tls for the listener")
+			}
+			clientCAFile := viper.GetString("tls." + viper.GetString(configRoot+".client-tls") + ".cafile")
+			if clientCAFile == "" {
+				panic("HTTP server client-tls specified with missing CA file")
+			}
+			clientCACert, err := os.ReadFile(clientCAFile)
+			if err != nil {
+				panic("cannot read client TLS CA file: " + err.Error())
+			}
+			server.TLSConfig.ClientCAs = x509.NewCertPool()
+			if !server.TLSConfig.ClientCAs.AppendCertsFromPEM(clientCACert) {
+				panic("no certificates found in client TLS CA file")
+			}
+
+			// Certificates that are presented are verified in the handshake. A connection without one is still accepted,
+			// so that the request gets a 403 response (and the healthcheck URLs can be used)
+			server.TLSConfig.ClientAuth = tls.VerifyClientCertIfGiven
+			requireClientCert = true
+		}
+
+		if viper.GetBool(configRoot + ".compress") {
+			server.Handler = &compressHandler{handler: server.Handler}
+		}
+		authToken := viper.GetString(configRoot + ".auth-token")
+		if (authToken != "") || requireClientCert {
+			server.Handler = &authHandler{
+				handler:           server.Handler,
+				token:             authToken,
+				requireClientCert: requireClientCert,
+			}
+		}
+		// CORS is handled outside of the auth check, so that preflight requests are answered
+		if len(viper.GetStringSlice(configRoot+".cors-allowed-origins")) > 0 {
+			server.Handler = newCORSHandler(server.Handler, configRoot)
+		}
+
+		// The access log is outermost, so that it times requests that are answered here as well as by the router
+		viper.SetDefault(configRoot+".access-log-sample-rate", 1.0)
+		sampleRate := viper.GetFloat64(configRoot + ".access-log-sample-rate")
+		if (sampleRate <= 0) || (sampleRate > 1) {
+			panic("HTTP server access-log-sample-rate must be greater than 0 and no more than 1")
+		}
+		slowThreshold := viper.GetInt(configRoot + ".slow-request-threshold")
+		if slowThreshold < 0 {
+			panic("HTTP server slow-request-threshold must not be negative")
+		}
+		if viper.GetBool(configRoot+".access-log") || (slowThreshold > 0) {
+			server.Handler = &accessLogHandler{
+				handler:       server.Handler,
+				log:           hc.Log.With(zap.String("listener", name)),
+				enabled:       viper.GetBool(configRoot + ".access-log"),
+				sampleRate:    sampleRate,
+				slowThreshold: time.Duration(slowThreshold) * time.Millisecond,
+				randomFunc:    rand.Float64,
+			}
+		}
 		hc.servers[name] = server
 		hc.theCert[name] = certFile
 		hc.theKey[name] = keyFile
@@ -127,19 +221,28 @@ func (hc *Coordinator) Configure() {
 	// This is a healthcheck and readiness URLs. Please don't change it
 	hc.router.GET("/burrow/admin", hc.handleAdmin)
 	hc.router.GET("/burrow/admin/ready", hc.handleReady)
+	hc.router.GET("/v3/healthz", hc.handleHealth)
 
 	hc.router.Handler(http.MethodGet, "/metrics", hc.handlePrometheusMetrics())
 
 	// All valid paths go here
+	hc.router.GET("/v3/summary", hc.handleSummary)
 	hc.router.GET("/v3/kafka", hc.handleClusterList)
 	hc.router.GET("/v3/kafka/:cluster", hc.handleClusterDetail)
+	hc.router.GET("/v3/kafka/:cluster/broker", hc.handleClusterBrokers)
+	hc.router.GET("/v3/kafka/:cluster/evaluator", hc.handleClusterEvaluator)
 	hc.router.GET("/v3/kafka/:cluster/topic", hc.handleTopicList)
 	hc.router.GET("/v3/kafka/:cluster/topic/:topic", hc.handleTopicDetail)
 	hc.router.GET("/v3/kafka/:cluster/topic/:topic/consumers", hc.handleTopicConsumerList)
+	hc.router.GET("/v3/kafka/:cluster/topic/:topic/offsets", hc.handleTopicTimestampOffsets)
 	hc.router.GET("/v3/kafka/:cluster/consumer", hc.handleConsumerList)
+	// The status stream is not at /v3/kafka/:cluster/consumer/stream, as that is the detail of a group named stream
+	hc.router.GET("/v3/kafka/:cluster/consumer-stream", hc.handleConsumerStream)
+	hc.router.GET("/v3/kafka/:cluster/consumer-stream/:consumer", hc.handleConsumerStream)
 	hc.router.GET("/v3/kafka/:cluster/consumer/:consumer", hc.handleConsumerDetail)
 	hc.router.GET("/v3/kafka/:cluster/consumer/:consumer/status", hc.handleConsumerStatus)
 	hc.router.GET("/v3/kafka/:cluster/consumer/:consumer/lag", hc.handleConsumerStatusComplete)
+	hc.router.GET("/v3/kafka/:cluster/consumer/:consumer/topic/:topic/partition/:partition", hc.handleConsumerPartitionDetail)
 
 	hc.router.GET("/v3/config", hc.configMain)
 	hc.router.GET("/v3/config/storage", hc.configStorageList)
@@ -153,11 +256,16 @@ func (hc *Coordinator) Configure() {
 	hc.router.GET("/v3/config/notifier", hc.configNotifierList)
 	hc.router.GET("/v3/config/notifier/:name", hc.configNotifierDetail)
 
-	// TODO: This should really have authentication protecting it
+	// These change state, so set auth-token or client-tls for any listener that is not on a trusted network
 	hc.router.DELETE("/v3/kafka/:cluster/consumer/:consumer", hc.handleConsumerDelete)
 	hc.router.DELETE("/v3/kafka/:cluster/consumer/:consumer/topic/:topic", hc.handleConsumerDelete)
+	hc.router.POST("/v3/kafka/:cluster/consumer/:consumer/evaluate", hc.handleConsumerEvaluate)
 	hc.router.GET("/v3/admin/loglevel", hc.getLogLevel)
 	hc.router.POST("/v3/admin/loglevel", hc.setLogLevel)
+	hc.router.GET("/v3/admin/storage-stats", hc.getStorageStats)
+	hc.router.GET("/v3/admin/offsets-topic-lag", hc.getOffsetsTopicLag)
+	hc.router.POST("/v3/admin/notifiers/:name/pause", hc.pauseNotifier)
+	hc.router.POST("/v3/admin/notifiers/:name/resume", hc.resumeNotifier)
 }
 
 // Start is responsible for starting the listener on each configured address. If any listener fails to start, the error
@@ -254,10 +362,7 @@ func makeRequestInfo(r *http.Request) httpResponseRequestInfo {
 
 func (hc *Coordinator) writeResponse(w http.ResponseWriter, r *http.Request, statusCode int, jsonObj interface{}) {
 	// Add CORS header, if configured
-	corsHeader := viper.GetString("general.access-control-allow-origin")
-	if corsHeader != "" {
-		w.Header().Set("Access-Control-Allow-Origin", corsHeader)
-	}
+	setCORSHeader(w)
 
 	w.Header().Set("Content-Type", "application/json")
 
@@ -287,10 +392,7 @@ func (handler *defaultHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 
 func (hc *Coordinator) handleAdmin(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	// Add CORS header, if configured
-	corsHeader := viper.GetString("general.access-control-allow-origin")
-	if corsHeader != "" {
-		w.Header().Set("Access-Control-Allow-Origin", corsHeader)
-	}
+	setCORSHeader(w)
 
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("GOOD"))
@@ -300,10 +402,7 @@ func (hc *Coordinator) handleAdmin(w http.ResponseWriter, r *http.Request, _ htt
 // whether Burrow is ready to serve requests
 func (hc *Coordinator) handleReady(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	// Add CORS header, if configured
-	corsHeader := viper.GetString("general.access-control-allow-origin")
-	if corsHeader != "" {
-		w.Header().Set("Access-Control-Allow-Origin", corsHeader)
-	}
+	setCORSHeader(w)
 
 	if hc.App.AppReady {
 		w.WriteHeader(http.StatusOK)
@@ -314,6 +413,37 @@ func (hc *Coordinator) handleReady(w http.ResponseWriter, r *http.Request, _ htt
 	}
 }
 
+// handleHealth runs the health checks that the running modules have registered, such as whether the consumers are
+// reading offsets, whether the clusters are fetching broker offsets, and whether storage is keeping up with requests.
+// If Burrow is not ready yet, or any check fails, it responds with 503, so it can be used as a liveness probe.
+func (hc *Coordinator) handleHealth(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	response := httpResponseHealth{
+		Message: "healthy",
+		Ready:   hc.App.AppReady,
+		Checks:  make(map[string]*httpResponseHealthCheck),
+		Request: makeRequestInfo(r),
+	}
+	for name, err := range hc.App.Health.Run() {
+		check := &httpResponseHealthCheck{Healthy: err == nil}
+		if err != nil {
+			check.Message = err.Error()
+			response.Error = true
+			response.Message = "unhealthy"
+		}
+		response.Checks[name] = check
+	}
+	if !response.Ready {
+		response.Error = true
+		response.Message = "starting"
+	}
+
+	if response.Error {
+		hc.writeResponse(w, r, http.StatusServiceUnavailable, response)
+	} else {
+		hc.writeResponse(w, r, http.StatusOK, response)
+	}
+}
+
 func (hc *Coordinator) getLogLevel(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	requestInfo := makeRequestInfo(r)
 	hc.writeResponse(w, r, http.StatusOK, httpResponseLogLevel{
@@ -324,6 +454,99 @@ func (hc *Coordinator) getLogLevel(w http.ResponseWriter, r *http.Request, _ htt
 	})
 }
 
+// getStorageStats returns the number of topics, partitions, groups, consumers, and offset ring buffers that storage
+// holds for each cluster, with an estimate of the bytes used. Each buffer holds intervals offsets, so bufferSlots shows
+// how memory use changes when intervals is raised or lowered for a cluster.
+func (hc *Coordinator) getStorageStats(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	request := &protocol.StorageRequest{
+		RequestType: protocol.StorageFetchStats,
+		Reply:       make(chan interface{}),
+	}
+	hc.App.StorageChannel <- request
+	response := <-request.Reply
+
+	stats, ok := response.(map[string]*protocol.StorageClusterStats)
+	if !ok {
+		hc.writeErrorResponse(w, r, http.StatusInternalServerError, "could not fetch storage stats")
+		return
+	}
+
+	requestInfo := makeRequestInfo(r)
+	hc.writeResponse(w, r, http.StatusOK, httpResponseStorageStats{
+		Error:    false,
+		Message:  "storage stats returned",
+		Clusters: stats,
+		Request:  requestInfo,
+	})
+}
+
+// getOffsetsTopicLag returns how far each consumer module is behind on the offsets topic that it reads, for each
+// partition and in total. If this lag grows, the consumer offsets in storage for the cluster are stale.
+func (hc *Coordinator) getOffsetsTopicLag(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	requestInfo := makeRequestInfo(r)
+	hc.writeResponse(w, r, http.StatusOK, httpResponseOffsetsTopicLags{
+		Error:   false,
+		Message: "offsets topic lag returned",
+		Modules: offsetsTopicLag.snapshot(),
+		Request: requestInfo,
+	})
+}
+
+// pauseNotifier stops the notifier module from sending anything for the duration given in the query (such as
+// "?duration=2h"), while groups continue to be evaluated. The pause ends by itself after the duration, or when the
+// notifier is resumed. Pausing a notifier that is already paused replaces the end of the pause.
+func (hc *Coordinator) pauseNotifier(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	name := params.ByName("name")
+	if !viper.IsSet("notifier." + name) {
+		hc.writeErrorResponse(w, r, http.StatusNotFound, "notifier module not found")
+		return
+	}
+
+	duration, err := time.ParseDuration(r.URL.Query().Get("duration"))
+	if (err != nil) || (duration <= 0) {
+		hc.writeErrorResponse(w, r, http.StatusBadRequest, "duration must be a positive duration, such as 30m or 2h")
+		return
+	}
+
+	until := time.Now().Add(duration)
+	hc.App.NotifierPauses.Pause(name, until)
+	hc.Log.Info("paused notifier", zap.String("notifier", name), zap.Time("until", until))
+
+	requestInfo := makeRequestInfo(r)
+	hc.writeResponse(w, r, http.StatusOK, httpResponseNotifierPause{
+		Error:       false,
+		Message:     "notifier paused",
+		Notifier:    name,
+		Paused:      true,
+		PausedUntil: until.UnixNano() / int64(time.Millisecond),
+		Request:     requestInfo,
+	})
+}
+
+// resumeNotifier ends the pause of the notifier module, if it is paused, so that it sends notifications again
+func (hc *Coordinator) resumeNotifier(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	name := params.ByName("name")
+	if !viper.IsSet("notifier." + name) {
+		hc.writeErrorResponse(w, r, http.StatusNotFound, "notifier module not found")
+		return
+	}
+
+	message := "notifier was not paused"
+	if hc.App.NotifierPauses.Resume(name) {
+		message = "notifier resumed"
+		hc.Log.Info("resumed notifier", zap.String("notifier", name))
+	}
+
+	requestInfo := makeRequestInfo(r)
+	hc.writeResponse(w, r, http.StatusOK, httpResponseNotifierPause{
+		Error:    false,
+		Message:  message,
+		Notifier: name,
+		Paused:   false,
+		Request:  requestInfo,
+	})
+}
+
 func (hc *Coordinator) setLogLevel(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	// Decode the JSON body
 	decoder := json.NewDecoder(r.Body)
@@ -11,6 +11,7 @@ package httpserver
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -43,6 +44,44 @@ func fixtureConfiguredCoordinator() *Coordinator {
 	return &coordinator
 }
 
+func TestHttpServer_Configure_Timeouts(t *testing.T) {
+	coordinator := fixtureConfiguredCoordinator()
+	server := coordinator.servers["default"]
+	assert.Equalf(t, 300*time.Second, server.ReadTimeout, "Expected the default read timeout to be 300s, not %v", server.ReadTimeout)
+	assert.Equalf(t, 300*time.Second, server.IdleTimeout, "Expected the default idle timeout to be 300s, not %v", server.IdleTimeout)
+	assert.Equalf(t, 0, server.MaxHeaderBytes, "Expected the default header limit, not %v", server.MaxHeaderBytes)
+
+	viper.Set("httpserver.default.timeout", 60)
+	viper.Set("httpserver.default.read-timeout", 10)
+	viper.Set("httpserver.default.idle-timeout", 120)
+	viper.Set("httpserver.default.max-header-bytes", 8192)
+	viper.Set("httpserver.default.max-body-bytes", 4)
+	coordinator.Configure()
+	server = coordinator.servers["default"]
+	assert.Equalf(t, 10*time.Second, server.ReadTimeout, "Expected the read timeout to be 10s, not %v", server.ReadTimeout)
+	assert.Equalf(t, 10*time.Second, server.ReadHeaderTimeout, "Expected the read header timeout to be 10s, not %v", server.ReadHeaderTimeout)
+	assert.Equalf(t, 60*time.Second, server.WriteTimeout, "Expected the write timeout to default to the timeout, not %v", server.WriteTimeout)
+	assert.Equalf(t, 120*time.Second, server.IdleTimeout, "Expected the idle timeout to be 120s, not %v", server.IdleTimeout)
+	assert.Equalf(t, 8192, server.MaxHeaderBytes, "Expected the header limit to be 8192, not %v", server.MaxHeaderBytes)
+
+	// A body over the limit cannot be read
+	req, err := http.NewRequest("POST", "/v3/admin/loglevel", strings.NewReader(`{"level": "debug"}`))
+	assert.NoError(t, err, "Expected request setup to return no error")
+	rr := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rr, req)
+	assert.Equalf(t, http.StatusBadRequest, rr.Code, "Expected response code to be 400, not %v", rr.Code)
+}
+
+func TestHttpServer_Configure_BadTimeouts(t *testing.T) {
+	coordinator := fixtureConfiguredCoordinator()
+	viper.Set("httpserver.default.write-timeout", -1)
+	assert.Panics(t, coordinator.Configure, "The code did not panic")
+
+	coordinator = fixtureConfiguredCoordinator()
+	viper.Set("httpserver.default.max-body-bytes", -1)
+	assert.Panics(t, coordinator.Configure, "The code did not panic")
+}
+
 func TestHttpServer_handleAdmin(t *testing.T) {
 	coordinator := fixtureConfiguredCoordinator()
 
@@ -79,6 +118,58 @@ func TestHttpServer_handleReady(t *testing.T) {
 	assert.Equalf(t, "READY", rr.Body.String(), "Expected response body to be 'READY', not '%v'", rr.Body.String())
 }
 
+func TestHttpServer_handleHealth(t *testing.T) {
+	coordinator := fixtureConfiguredCoordinator()
+	coordinator.App.AppReady = true
+	coordinator.App.Health = &protocol.HealthChecks{}
+	coordinator.App.Health.Register("cluster.test", func() error { return nil })
+
+	req, err := http.NewRequest("GET", "/v3/healthz", http.NoBody)
+	assert.NoError(t, err, "Expected request setup to return no error")
+	rr := httptest.NewRecorder()
+	coordinator.router.ServeHTTP(rr, req)
+	assert.Equalf(t, http.StatusOK, rr.Code, "Expected response code to be 200, not %v", rr.Code)
+
+	var resp httpResponseHealth
+	err = json.NewDecoder(rr.Body).Decode(&resp)
+	assert.NoError(t, err, "Expected body decode to return no error")
+	assert.False(t, resp.Error, "Expected response Error to be false")
+	assert.Lenf(t, resp.Checks, 1, "Expected 1 check, not %v", len(resp.Checks))
+	assert.True(t, resp.Checks["cluster.test"].Healthy, "Expected cluster.test to be healthy")
+
+	// A failing check makes the response a 503, with the reason
+	coordinator.App.Health.Register("consumer.test", func() error { return errors.New("no heartbeat yet") })
+	rr = httptest.NewRecorder()
+	coordinator.router.ServeHTTP(rr, req)
+	assert.Equalf(t, http.StatusServiceUnavailable, rr.Code, "Expected response code to be 503, not %v", rr.Code)
+
+	resp = httpResponseHealth{}
+	err = json.NewDecoder(rr.Body).Decode(&resp)
+	assert.NoError(t, err, "Expected body decode to return no error")
+	assert.True(t, resp.Error, "Expected response Error to be true")
+	assert.Equalf(t, "unhealthy", resp.Message, "Expected Message to be unhealthy, not %v", resp.Message)
+	assert.False(t, resp.Checks["consumer.test"].Healthy, "Expected consumer.test to be unhealthy")
+	assert.Equalf(t, "no heartbeat yet", resp.Checks["consumer.test"].Message, "Unexpected check message: %v", resp.Checks["consumer.test"].Message)
+
+	// Removing the check, but not being ready, is still a 503
+	coordinator.App.Health.Unregister("consumer.test")
+	coordinator.App.AppReady = false
+	rr = httptest.NewRecorder()
+	coordinator.router.ServeHTTP(rr, req)
+	assert.Equalf(t, http.StatusServiceUnavailable, rr.Code, "Expected response code to be 503, not %v", rr.Code)
+}
+
+func TestHttpServer_handleHealth_Heartbeat(t *testing.T) {
+	heartbeat := &protocol.Heartbeat{}
+	check := heartbeat.Check(time.Minute)
+	assert.Error(t, check(), "Expected an error before the first beat")
+
+	heartbeat.Beat()
+	assert.NoError(t, check(), "Expected no error after a beat")
+	time.Sleep(2 * time.Millisecond)
+	assert.Error(t, heartbeat.Check(time.Millisecond)(), "Expected an error once the threshold has passed")
+}
+
 func TestHttpServer_getClusterList(t *testing.T) {
 	coordinator := fixtureConfiguredCoordinator()
 
@@ -134,6 +225,94 @@ func TestHttpServer_setLogLevel(t *testing.T) {
 	assert.Equalf(t, zap.DebugLevel, coordinator.App.LogLevel.Level(), "Expected log level to be set to Debug, not %v", coordinator.App.LogLevel.Level().String())
 }
 
+func TestHttpServer_pauseNotifier(t *testing.T) {
+	coordinator := fixtureConfiguredCoordinator()
+	coordinator.App.NotifierPauses = &protocol.NotifierPauses{}
+	viper.Set("notifier.test.class-name", "null")
+
+	req, err := http.NewRequest("POST", "/v3/admin/notifiers/test/pause?duration=2h", http.NoBody)
+	assert.NoError(t, err, "Expected request setup to return no error")
+	rr := httptest.NewRecorder()
+	coordinator.router.ServeHTTP(rr, req)
+	assert.Equalf(t, http.StatusOK, rr.Code, "Expected response code to be 200, not %v", rr.Code)
+
+	var resp httpResponseNotifierPause
+	err = json.NewDecoder(rr.Body).Decode(&resp)
+	assert.NoError(t, err, "Expected body decode to return no error")
+	assert.False(t, resp.Error, "Expected response Error to be false")
+	assert.True(t, resp.Paused, "Expected response Paused to be true")
+	until, paused := coordinator.App.NotifierPauses.PausedUntil("test")
+	assert.True(t, paused, "Expected the notifier to be paused")
+	assert.Equalf(t, until.UnixNano()/int64(time.Millisecond), resp.PausedUntil, "Expected PausedUntil to be %v, not %v", until.UnixNano()/int64(time.Millisecond), resp.PausedUntil)
+	assert.WithinDuration(t, time.Now().Add(2*time.Hour), until, time.Minute, "Expected the pause to end in 2 hours")
+
+	req, err = http.NewRequest("POST", "/v3/admin/notifiers/test/resume", http.NoBody)
+	assert.NoError(t, err, "Expected request setup to return no error")
+	rr = httptest.NewRecorder()
+	coordinator.router.ServeHTTP(rr, req)
+	assert.Equalf(t, http.StatusOK, rr.Code, "Expected response code to be 200, not %v", rr.Code)
+	err = json.NewDecoder(rr.Body).Decode(&resp)
+	assert.NoError(t, err, "Expected body decode to return no error")
+	assert.Equalf(t, "notifier resumed", resp.Message, "Expected message to be 'notifier resumed', not '%v'", resp.Message)
+	_, paused = coordinator.App.NotifierPauses.PausedUntil("test")
+	assert.False(t, paused, "Expected the notifier to not be paused")
+}
+
+func TestHttpServer_pauseNotifier_BadRequest(t *testing.T) {
+	coordinator := fixtureConfiguredCoordinator()
+	coordinator.App.NotifierPauses = &protocol.NotifierPauses{}
+	viper.Set("notifier.test.class-name", "null")
+
+	for _, path := range []string{"/v3/admin/notifiers/test/pause", "/v3/admin/notifiers/test/pause?duration=-1h", "/v3/admin/notifiers/test/pause?duration=soon"} {
+		req, err := http.NewRequest("POST", path, http.NoBody)
+		assert.NoError(t, err, "Expected request setup to return no error")
+		rr := httptest.NewRecorder()
+		coordinator.router.ServeHTTP(rr, req)
+		assert.Equalf(t, http.StatusBadRequest, rr.Code, "Expected response code to be 400 for %v, not %v", path, rr.Code)
+	}
+
+	req, err := http.NewRequest("POST", "/v3/admin/notifiers/nosuchnotifier/pause?duration=1h", http.NoBody)
+	assert.NoError(t, err, "Expected request setup to return no error")
+	rr := httptest.NewRecorder()
+	coordinator.router.ServeHTTP(rr, req)
+	assert.Equalf(t, http.StatusNotFound, rr.Code, "Expected response code to be 404, not %v", rr.Code)
+	_, paused := coordinator.App.NotifierPauses.PausedUntil("test")
+	assert.False(t, paused, "Expected the notifier to not be paused")
+}
+
+func TestHttpServer_getStorageStats(t *testing.T) {
+	coordinator := fixtureConfiguredCoordinator()
+
+	// Respond to the expected storage request
+	go func() {
+		request := <-coordinator.App.StorageChannel
+		assert.Equalf(t, protocol.StorageFetchStats, request.RequestType, "Expected request of type StorageFetchStats, not %v", request.RequestType)
+		request.Reply <- map[string]*protocol.StorageClusterStats{
+			"testcluster": {Intervals: 10, Topics: 1, Partitions: 2, BrokerBuffers: 2, Groups: 1, Consumers: 1, ConsumerBuffers: 2, BufferSlots: 40, EstimatedBytes: 4096},
+		}
+		close(request.Reply)
+	}()
+
+	// Set up a request
+	req, err := http.NewRequest("GET", "/v3/admin/storage-stats", http.NoBody)
+	assert.NoError(t, err, "Expected request setup to return no error")
+
+	// Call the handler via httprouter
+	rr := httptest.NewRecorder()
+	coordinator.router.ServeHTTP(rr, req)
+	assert.Equalf(t, http.StatusOK, rr.Code, "Expected response code to be 200, not %v", rr.Code)
+
+	// Parse response body
+	decoder := json.NewDecoder(rr.Body)
+	var resp httpResponseStorageStats
+	err = decoder.Decode(&resp)
+	assert.NoError(t, err, "Expected body decode to return no error")
+	assert.False(t, resp.Error, "Expected response Error to be false")
+	assert.Contains(t, resp.Clusters, "testcluster", "Expected stats for testcluster")
+	assert.Equalf(t, 40, resp.Clusters["testcluster"].BufferSlots, "Expected BufferSlots to be 40, not %v", resp.Clusters["testcluster"].BufferSlots)
+	assert.Equalf(t, int64(4096), resp.Clusters["testcluster"].EstimatedBytes, "Expected EstimatedBytes to be 4096, not %v", resp.Clusters["testcluster"].EstimatedBytes)
+}
+
 func TestHttpServer_DefaultHandler(t *testing.T) {
 	coordinator := fixtureConfiguredCoordinator()
 
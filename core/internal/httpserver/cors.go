@@ -0,0 +1,100 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package httpserver
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// corsHandler wraps the handler for a listener that has cors-allowed-origins set. A request with an Origin in the
+// allowlist (or any Origin, if the allowlist has "*") gets the Access-Control-Allow-Origin header in the response. A
+// preflight request (an OPTIONS request with Access-Control-Request-Method) from an allowed Origin is answered here,
+// without calling the wrapped handler, as browsers do not send credentials with a preflight.
+type corsHandler struct {
+	handler      http.Handler
+	origins      map[string]bool
+	anyOrigin    bool
+	allowMethods string
+	allowHeaders string
+	maxAge       string
+}
+
+// newCORSHandler sets up a corsHandler from the CORS configurations for the listener. The methods default to GET, POST,
+// and DELETE, the headers to Authorization and Content-Type, and the preflight response is cached for cors-max-age
+// seconds (default 600).
+func newCORSHandler(handler http.Handler, configRoot string) *corsHandler {
+	viper.SetDefault(configRoot+".cors-allowed-methods", []string{http.MethodGet, http.MethodPost, http.MethodDelete})
+	viper.SetDefault(configRoot+".cors-allowed-headers", []string{"Authorization", "Content-Type"})
+	viper.SetDefault(configRoot+".cors-max-age", 600)
+
+	h := &corsHandler{
+		handler: handler,
+		origins: make(map[string]bool),
+		maxAge:  strconv.Itoa(viper.GetInt(configRoot + ".cors-max-age")),
+	}
+	for _, origin := range viper.GetStringSlice(configRoot + ".cors-allowed-origins") {
+		if origin == "*" {
+			h.anyOrigin = true
+		} else {
+			h.origins[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+		}
+	}
+
+	methods := viper.GetStringSlice(configRoot + ".cors-allowed-methods")
+	for i, method := range methods {
+		methods[i] = strings.ToUpper(method)
+	}
+	h.allowMethods = strings.Join(methods, ", ")
+	h.allowHeaders = strings.Join(viper.GetStringSlice(configRoot+".cors-allowed-headers"), ", ")
+	return h
+}
+
+func (h *corsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+
+	// The response depends on the Origin, so caches must not share it between origins
+	w.Header().Add("Vary", "Origin")
+	if !h.anyOrigin && !h.origins[strings.ToLower(origin)] {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+
+	if h.anyOrigin {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+
+	if (r.Method == http.MethodOptions) && (r.Header.Get("Access-Control-Request-Method") != "") {
+		w.Header().Set("Access-Control-Allow-Methods", h.allowMethods)
+		w.Header().Set("Access-Control-Allow-Headers", h.allowHeaders)
+		w.Header().Set("Access-Control-Max-Age", h.maxAge)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	h.handler.ServeHTTP(w, r)
+}
+
+// setCORSHeader sets the Access-Control-Allow-Origin header from general.access-control-allow-origin, if it is
+// configured and the listener has not already set the header for the request's Origin.
+func setCORSHeader(w http.ResponseWriter) {
+	corsHeader := viper.GetString("general.access-control-allow-origin")
+	if (corsHeader != "") && (w.Header().Get("Access-Control-Allow-Origin") == "") {
+		w.Header().Set("Access-Control-Allow-Origin", corsHeader)
+	}
+}
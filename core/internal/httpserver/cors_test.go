@@ -0,0 +1,99 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHttpServer_Configure_CORS(t *testing.T) {
+	coordinator := fixtureConfiguredCoordinator()
+	_, ok := coordinator.servers["default"].Handler.(*corsHandler)
+	assert.False(t, ok, "Expected CORS to be off by default")
+
+	viper.Set("httpserver.default.cors-allowed-origins", []string{"https://dashboard.example.com"})
+	viper.Set("httpserver.default.auth-token", "testtoken")
+	coordinator.Configure()
+	handler, ok := coordinator.servers["default"].Handler.(*corsHandler)
+	assert.True(t, ok, "Expected the handler to be wrapped for CORS")
+	assert.Equal(t, "GET, POST, DELETE", handler.allowMethods, "Expected the default methods to be allowed")
+	assert.Equal(t, "Authorization, Content-Type", handler.allowHeaders, "Expected the default headers to be allowed")
+	assert.Equal(t, "600", handler.maxAge, "Expected the default max age to be 600")
+	_, ok = handler.handler.(*authHandler)
+	assert.True(t, ok, "Expected CORS to wrap the auth handler")
+}
+
+var corsTests = []struct {
+	method        string
+	origin        string
+	preflight     bool
+	status        int
+	allowedOrigin string
+}{
+	/*0*/ {"GET", "", false, http.StatusUnauthorized, ""},
+	/*1*/ {"GET", "https://dashboard.example.com", false, http.StatusUnauthorized, "https://dashboard.example.com"},
+	/*2*/ {"GET", "https://DASHBOARD.example.com", false, http.StatusUnauthorized, "https://DASHBOARD.example.com"},
+	/*3*/ {"GET", "https://other.example.com", false, http.StatusUnauthorized, ""},
+	/*4*/ {"OPTIONS", "https://dashboard.example.com", true, http.StatusNoContent, "https://dashboard.example.com"},
+	/*5*/ {"OPTIONS", "https://other.example.com", true, http.StatusUnauthorized, ""},
+}
+
+func TestHttpServer_corsHandler(t *testing.T) {
+	coordinator := fixtureConfiguredCoordinator()
+	viper.Set("httpserver.default.cors-allowed-origins", []string{"https://dashboard.example.com/"})
+	viper.Set("httpserver.default.cors-allowed-methods", []string{"get", "post"})
+	handler := newCORSHandler(&authHandler{handler: coordinator.router, token: "testtoken"}, "httpserver.default")
+
+	for i, testSet := range corsTests {
+		req, err := http.NewRequest(testSet.method, "/v3/kafka", http.NoBody)
+		assert.NoError(t, err, "Expected request setup to return no error")
+		if testSet.origin != "" {
+			req.Header.Set("Origin", testSet.origin)
+		}
+		if testSet.preflight {
+			req.Header.Set("Access-Control-Request-Method", "GET")
+		}
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equalf(t, testSet.status, rr.Code, "TEST %v: Expected response code to be %v, not %v", i, testSet.status, rr.Code)
+		assert.Equalf(t, testSet.allowedOrigin, rr.Header().Get("Access-Control-Allow-Origin"), "TEST %v: Expected Access-Control-Allow-Origin to be '%v', not '%v'", i, testSet.allowedOrigin, rr.Header().Get("Access-Control-Allow-Origin"))
+		if testSet.status == http.StatusNoContent {
+			assert.Equalf(t, "GET, POST", rr.Header().Get("Access-Control-Allow-Methods"), "TEST %v: Expected the configured methods", i)
+			assert.Equalf(t, "Authorization, Content-Type", rr.Header().Get("Access-Control-Allow-Headers"), "TEST %v: Expected the default headers", i)
+		}
+	}
+}
+
+func TestHttpServer_corsHandler_AnyOrigin(t *testing.T) {
+	coordinator := fixtureConfiguredCoordinator()
+	viper.Set("general.access-control-allow-origin", "legacy.example.com")
+	viper.Set("httpserver.default.cors-allowed-origins", []string{"*"})
+	handler := newCORSHandler(coordinator.router, "httpserver.default")
+
+	req, err := http.NewRequest("GET", "/burrow/admin", http.NoBody)
+	assert.NoError(t, err, "Expected request setup to return no error")
+	req.Header.Set("Origin", "https://anywhere.example.com")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equalf(t, http.StatusOK, rr.Code, "Expected response code to be 200, not %v", rr.Code)
+	assert.Equalf(t, "*", rr.Header().Get("Access-Control-Allow-Origin"), "Expected the listener's CORS header to be kept, not '%v'", rr.Header().Get("Access-Control-Allow-Origin"))
+
+	// Without an Origin, the general setting is still used
+	req.Header.Del("Origin")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equalf(t, "legacy.example.com", rr.Header().Get("Access-Control-Allow-Origin"), "Expected the general CORS header, not '%v'", rr.Header().Get("Access-Control-Allow-Origin"))
+}
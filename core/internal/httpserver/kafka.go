@@ -10,10 +10,15 @@
 package httpserver
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/spf13/viper"
+	"go.uber.org/zap"
 
 	"github.com/linkedin/Burrow/core/protocol"
 )
@@ -36,49 +41,159 @@ func (hc *Coordinator) handleClusterList(w http.ResponseWriter, r *http.Request,
 	})
 }
 
-func getTLSProfile(name string) *httpResponseTLSProfile {
+// handleSummary returns the number of consumer groups in each status for every cluster, and the worst status of any
+// cluster. The last status that the evaluator has for each group is used, and groups are only evaluated for this if
+// the evaluator has not evaluated them yet. Groups that have a partition that is stopped, stalled, or rewound are in
+// the ERR status, and the groups that have a stalled partition are also counted on their own.
+func (hc *Coordinator) handleSummary(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	request := &protocol.StorageRequest{
+		RequestType: protocol.StorageFetchClusters,
+		Reply:       make(chan interface{}),
+	}
+	hc.App.StorageChannel <- request
+	clusters, _ := (<-request.Reply).([]string)
+
+	summaries := make(map[string]*httpResponseClusterSummary, len(clusters))
+	worstStatus := protocol.StatusOK
+	for _, cluster := range clusters {
+		summary := hc.summarizeCluster(cluster)
+		if summary.Status > worstStatus {
+			worstStatus = summary.Status
+		}
+		summaries[cluster] = summary
+	}
+
+	requestInfo := makeRequestInfo(r)
+	hc.writeResponse(w, r, http.StatusOK, httpResponseSummary{
+		Error:    false,
+		Message:  "status summary returned",
+		Status:   worstStatus,
+		Clusters: summaries,
+		Request:  requestInfo,
+	})
+}
+
+// summarizeCluster fetches the last status of every consumer group in the cluster from the evaluator, with a single
+// request, and counts the groups in each status. Only the groups that the evaluator has no status for are requested
+// separately, which evaluates them. The status of the cluster is the worst status of any of its groups.
+func (hc *Coordinator) summarizeCluster(cluster string) *httpResponseClusterSummary {
+	request := &protocol.StorageRequest{
+		RequestType: protocol.StorageFetchConsumers,
+		Cluster:     cluster,
+		Reply:       make(chan interface{}),
+	}
+	hc.App.StorageChannel <- request
+	groups, _ := (<-request.Reply).([]string)
+
+	summary := &httpResponseClusterSummary{
+		Status: protocol.StatusOK,
+		Total:  len(groups),
+		Counts: map[string]int{
+			protocol.StatusOK.String():      0,
+			protocol.StatusWarning.String(): 0,
+			protocol.StatusError.String():   0,
+		},
+	}
+
+	summaryRequest := &protocol.EvaluatorRequest{
+		Cluster:      cluster,
+		SummaryReply: make(chan map[string]*protocol.GroupStatusSummary),
+	}
+	hc.App.EvaluatorChannel <- summaryRequest
+	lastStatus := <-summaryRequest.SummaryReply
+
+	// The evaluator replies to each request separately, so they all share one channel that can hold every reply
+	replies := make(chan *protocol.ConsumerGroupStatus, len(groups))
+	unevaluated := 0
+	for _, group := range groups {
+		if last, ok := lastStatus[group]; ok {
+			summary.add(last)
+			continue
+		}
+		hc.App.EvaluatorChannel <- &protocol.EvaluatorRequest{
+			Cluster: cluster,
+			Group:   group,
+			Reply:   replies,
+		}
+		unevaluated++
+	}
+	for i := 0; i < unevaluated; i++ {
+		status := <-replies
+		last := &protocol.GroupStatusSummary{Status: status.Status}
+		for _, partition := range status.Partitions {
+			if partition.Status == protocol.StatusStall {
+				last.Stalled = true
+			}
+		}
+		summary.add(last)
+	}
+	return summary
+}
+
+// add counts the group in the summary of its cluster
+func (summary *httpResponseClusterSummary) add(group *protocol.GroupStatusSummary) {
+	summary.Counts[group.Status.String()]++
+	if group.Stalled {
+		summary.Stalled++
+	}
+	if group.Status > summary.Status {
+		summary.Status = group.Status
+	}
+}
+
+// clusterConfig returns the configuration that holds the settings of the cluster. A discovered cluster is not in the
+// global configuration, and has its own.
+func (hc *Coordinator) clusterConfig(cluster string) *viper.Viper {
+	if config, ok := hc.App.DiscoveredClusters.Get(cluster); ok {
+		return config
+	}
+	return viper.GetViper()
+}
+
+func getTLSProfile(config *viper.Viper, name string) *httpResponseTLSProfile {
 	configRoot := "tls." + name
-	if !viper.IsSet(configRoot) {
+	if !config.IsSet(configRoot) {
 		return nil
 	}
 
 	return &httpResponseTLSProfile{
 		Name:     name,
-		CertFile: viper.GetString(configRoot + ".certfile"),
-		KeyFile:  viper.GetString(configRoot + ".keyfile"),
-		CAFile:   viper.GetString(configRoot + ".cafile"),
-		NoVerify: viper.GetBool(configRoot + ".noverify"),
+		CertFile: config.GetString(configRoot + ".certfile"),
+		KeyFile:  config.GetString(configRoot + ".keyfile"),
+		CAFile:   config.GetString(configRoot + ".cafile"),
+		NoVerify: config.GetBool(configRoot + ".noverify"),
 	}
 }
 
-func getSASLProfile(name string) *httpResponseSASLProfile {
+func getSASLProfile(config *viper.Viper, name string) *httpResponseSASLProfile {
 	configRoot := "sasl." + name
-	if !viper.IsSet(configRoot) {
+	if !config.IsSet(configRoot) {
 		return nil
 	}
 
 	return &httpResponseSASLProfile{
 		Name:           name,
-		HandshakeFirst: viper.GetBool(configRoot + ".handshake-first"),
-		Username:       viper.GetString(configRoot + ".username"),
+		HandshakeFirst: config.GetBool(configRoot + ".handshake-first"),
+		Username:       config.GetString(configRoot + ".username"),
 	}
 }
 
-func getClientProfile(name string) httpResponseClientProfile {
+func getClientProfile(config *viper.Viper, name string) httpResponseClientProfile {
 	configRoot := "client-profile." + name
 	return httpResponseClientProfile{
 		Name:         name,
-		ClientID:     viper.GetString(configRoot + ".client-id"),
-		KafkaVersion: viper.GetString(configRoot + ".kafka-version"),
-		TLS:          getTLSProfile(viper.GetString(configRoot + ".tls")),
-		SASL:         getSASLProfile(viper.GetString(configRoot + ".sasl")),
+		ClientID:     config.GetString(configRoot + ".client-id"),
+		KafkaVersion: config.GetString(configRoot + ".kafka-version"),
+		TLS:          getTLSProfile(config, config.GetString(configRoot+".tls")),
+		SASL:         getSASLProfile(config, config.GetString(configRoot+".sasl")),
 	}
 }
 
 func (hc *Coordinator) handleClusterDetail(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
-	// Get cluster config
+	// Get cluster config, which is its own for a discovered cluster
+	config := hc.clusterConfig(params.ByName("cluster"))
 	configRoot := "cluster." + params.ByName("cluster")
-	if !viper.IsSet(configRoot) {
+	if !config.IsSet(configRoot) {
 		hc.writeErrorResponse(w, r, http.StatusNotFound, "cluster module not found")
 	} else {
 		requestInfo := makeRequestInfo(r)
@@ -86,17 +201,83 @@ func (hc *Coordinator) handleClusterDetail(w http.ResponseWriter, r *http.Reques
 			Error:   false,
 			Message: "cluster module detail returned",
 			Module: httpResponseConfigModuleCluster{
-				ClassName:     viper.GetString(configRoot + ".class-name"),
-				Servers:       viper.GetStringSlice(configRoot + ".servers"),
-				TopicRefresh:  viper.GetInt64(configRoot + ".topic-refresh"),
-				OffsetRefresh: viper.GetInt64(configRoot + ".offset-refresh"),
-				ClientProfile: getClientProfile(viper.GetString(configRoot + ".client-profile")),
+				ClassName:     config.GetString(configRoot + ".class-name"),
+				Servers:       config.GetStringSlice(configRoot + ".servers"),
+				TopicRefresh:  config.GetInt64(configRoot + ".topic-refresh"),
+				OffsetRefresh: config.GetInt64(configRoot + ".offset-refresh"),
+				ClientProfile: getClientProfile(config, config.GetString(configRoot+".client-profile")),
 			},
 			Request: requestInfo,
 		})
 	}
 }
 
+// handleClusterEvaluator returns the parameters that the evaluator uses for the groups in the cluster, and the number
+// of offsets (intervals) that storage keeps for each partition, which is the window that the evaluation rules look at.
+// If the group query parameter is set, the parameters are resolved for that group, including any override it has.
+func (hc *Coordinator) handleClusterEvaluator(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	storageRequest := &protocol.StorageRequest{
+		RequestType: protocol.StorageFetchStats,
+		Reply:       make(chan interface{}),
+	}
+	hc.App.StorageChannel <- storageRequest
+	response := <-storageRequest.Reply
+
+	stats, _ := response.(map[string]*protocol.StorageClusterStats)
+	clusterStats, ok := stats[params.ByName("cluster")]
+	if !ok {
+		hc.writeErrorResponse(w, r, http.StatusNotFound, "cluster not found")
+		return
+	}
+
+	request := &protocol.EvaluatorRequest{
+		Cluster:         params.ByName("cluster"),
+		Group:           r.URL.Query().Get("group"),
+		ParametersReply: make(chan *protocol.EvaluatorParameters),
+	}
+	hc.App.EvaluatorChannel <- request
+	parameters := <-request.ParametersReply
+
+	requestInfo := makeRequestInfo(r)
+	hc.writeResponse(w, r, http.StatusOK, httpResponseEvaluatorParameters{
+		Error:      false,
+		Message:    "evaluator parameters returned",
+		Intervals:  clusterStats.Intervals,
+		Parameters: parameters,
+		Request:    requestInfo,
+	})
+}
+
+// handleClusterBrokers returns the controller and the number of brokers from the last metadata refresh of the cluster.
+// If the cluster has no controller, controller is -1 and hasController is false. The lastUpdate is the timestamp of
+// the newest broker offset fetched for the cluster, which shows whether the offsets are still being refreshed.
+func (hc *Coordinator) handleClusterBrokers(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	request := &protocol.StorageRequest{
+		RequestType: protocol.StorageFetchClusterBrokers,
+		Cluster:     params.ByName("cluster"),
+		Reply:       make(chan interface{}),
+	}
+	hc.App.StorageChannel <- request
+	response := <-request.Reply
+
+	if response == nil {
+		hc.writeErrorResponse(w, r, http.StatusNotFound, "cluster not found")
+		return
+	}
+
+	brokers := response.(*protocol.ClusterBrokers)
+	hc.writeResponse(w, r, http.StatusOK, httpResponseClusterBrokers{
+		Error:         false,
+		Message:       "cluster brokers returned",
+		Controller:    brokers.ControllerID,
+		HasController: brokers.ControllerID >= 0,
+		BrokerCount:   brokers.BrokerCount,
+		Timestamp:     brokers.Timestamp,
+		LastUpdate:    brokers.LastUpdate,
+		Request:       makeRequestInfo(r),
+	})
+}
+
 func (hc *Coordinator) handleTopicList(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	// Fetch topic list from the storage module
 	request := &protocol.StorageRequest{
@@ -110,12 +291,30 @@ func (hc *Coordinator) handleTopicList(w http.ResponseWriter, r *http.Request, p
 	if response == nil {
 		hc.writeErrorResponse(w, r, http.StatusNotFound, "cluster not found")
 	} else {
+		// Fetch the stored offsets for all topics. If the cluster has just been removed, there are none
+		offsetsRequest := &protocol.StorageRequest{
+			RequestType: protocol.StorageFetchTopicOffsets,
+			Cluster:     params.ByName("cluster"),
+			Reply:       make(chan interface{}),
+		}
+		hc.App.StorageChannel <- offsetsRequest
+		topicOffsets, _ := (<-offsetsRequest.Reply).(map[string][]*protocol.TopicPartitionOffsets)
+
+		offsets := make(map[string]*httpResponseTopicOffsets, len(topicOffsets))
+		for topic, partitions := range topicOffsets {
+			offsets[topic] = &httpResponseTopicOffsets{
+				PartitionCount: len(partitions),
+				Partitions:     partitions,
+			}
+		}
+
 		requestInfo := makeRequestInfo(r)
 		hc.writeResponse(w, r, http.StatusOK, httpResponseTopicList{
-			Error:   false,
-			Message: "topic list returned",
-			Topics:  response.([]string),
-			Request: requestInfo,
+			Error:        false,
+			Message:      "topic list returned",
+			Topics:       response.([]string),
+			TopicOffsets: offsets,
+			Request:      requestInfo,
 		})
 	}
 }
@@ -134,16 +333,126 @@ func (hc *Coordinator) handleTopicDetail(w http.ResponseWriter, r *http.Request,
 	if response == nil {
 		hc.writeErrorResponse(w, r, http.StatusNotFound, "cluster or topic not found")
 	} else {
+		offsets := response.([]int64)
+
+		// Fetch the regression flags for the same offsets. If the topic has just been deleted, none are regressed
+		regressedRequest := &protocol.StorageRequest{
+			RequestType: protocol.StorageFetchTopicRegressed,
+			Cluster:     params.ByName("cluster"),
+			Topic:       params.ByName("topic"),
+			Reply:       make(chan interface{}),
+		}
+		hc.App.StorageChannel <- regressedRequest
+		regressed, ok := (<-regressedRequest.Reply).([]bool)
+		if !ok || (len(regressed) != len(offsets)) {
+			regressed = make([]bool, len(offsets))
+		}
+
+		// Fetch the range of stored offsets for each partition
+		offsetsRequest := &protocol.StorageRequest{
+			RequestType: protocol.StorageFetchTopicOffsets,
+			Cluster:     params.ByName("cluster"),
+			Topic:       params.ByName("topic"),
+			Reply:       make(chan interface{}),
+		}
+		hc.App.StorageChannel <- offsetsRequest
+		topicOffsets, _ := (<-offsetsRequest.Reply).(map[string][]*protocol.TopicPartitionOffsets)
+		partitions := topicOffsets[params.ByName("topic")]
+		if partitions == nil {
+			partitions = make([]*protocol.TopicPartitionOffsets, 0)
+		}
+
 		requestInfo := makeRequestInfo(r)
 		hc.writeResponse(w, r, http.StatusOK, httpResponseTopicDetail{
-			Error:   false,
-			Message: "topic offsets returned",
-			Offsets: response.([]int64),
-			Request: requestInfo,
+			Error:      false,
+			Message:    "topic offsets returned",
+			Offsets:    offsets,
+			Regressed:  regressed,
+			Partitions: partitions,
+			Request:    requestInfo,
 		})
 	}
 }
 
+// handleTopicTimestampOffsets returns the offset of each partition of the topic at the time in the timestamp query
+// parameter, in milliseconds, as looked up from the cluster. This is the offset of the earliest message with a timestamp
+// at or after the time, so the difference between the offsets at two times is the number of messages produced between
+// them. If a partition has no message at or after the time, the current broker offset is returned for it instead, and
+// it is marked as newest. If the offsets of only some partitions could be fetched, such as when one leader is down,
+// those are returned, and the others have an offset of -1 and are marked as failed. If the kafka-version of the cluster
+// is before 0.10.1.0, which cannot look up offsets by timestamp, a 501 is returned.
+func (hc *Coordinator) handleTopicTimestampOffsets(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	timestamp, err := strconv.ParseInt(r.URL.Query().Get("timestamp"), 10, 64)
+	if (err != nil) || (timestamp < 0) {
+		hc.writeErrorResponse(w, r, http.StatusBadRequest, "timestamp must be a number of milliseconds since the epoch")
+		return
+	}
+
+	// Fetch the current broker offsets from the storage module, to make sure the topic is known
+	request := &protocol.StorageRequest{
+		RequestType: protocol.StorageFetchTopic,
+		Cluster:     params.ByName("cluster"),
+		Topic:       params.ByName("topic"),
+		Reply:       make(chan interface{}),
+	}
+	hc.App.StorageChannel <- request
+	response := <-request.Reply
+	if response == nil {
+		hc.writeErrorResponse(w, r, http.StatusNotFound, "cluster or topic not found")
+		return
+	}
+	brokerOffsets := response.([]int64)
+
+	lookup, ok := hc.App.OffsetLookups.Get(params.ByName("cluster"))
+	if !ok {
+		hc.writeErrorResponse(w, r, http.StatusServiceUnavailable, "cluster is not connected")
+		return
+	}
+	offsets, err := lookup(params.ByName("topic"), timestamp)
+	if errors.Is(err, protocol.ErrOffsetLookupUnsupported) {
+		hc.writeErrorResponse(w, r, http.StatusNotImplemented, err.Error())
+		return
+	}
+	message := "topic offsets at timestamp returned"
+	if err != nil {
+		hc.Log.Warn("failed to look up offsets",
+			zap.String("cluster", params.ByName("cluster")),
+			zap.String("topic", params.ByName("topic")),
+			zap.Int("partitions", len(offsets)),
+			zap.Error(err),
+		)
+		if len(offsets) == 0 {
+			hc.writeErrorResponse(w, r, http.StatusInternalServerError, "could not fetch offsets from the cluster")
+			return
+		}
+		message = "some topic offsets at timestamp could not be fetched: " + err.Error()
+	}
+
+	partitions := make([]httpResponseTimestampOffset, len(brokerOffsets))
+	for i, brokerOffset := range brokerOffsets {
+		partitions[i].Partition = int32(i)
+		offset, ok := offsets[int32(i)]
+		switch {
+		case !ok:
+			partitions[i].Offset = -1
+			partitions[i].Failed = true
+		case offset >= 0:
+			partitions[i].Offset = offset
+		default:
+			partitions[i].Offset = brokerOffset
+			partitions[i].Newest = true
+		}
+	}
+
+	hc.writeResponse(w, r, http.StatusOK, httpResponseTopicTimestampOffsets{
+		Error:     false,
+		Message:   message,
+		Timestamp: timestamp,
+		Offsets:   partitions,
+		Request:   makeRequestInfo(r),
+	})
+}
+
 func (hc *Coordinator) handleTopicConsumerList(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	// Fetch topic offsets from the storage module
 	request := &protocol.StorageRequest{
@@ -168,11 +477,28 @@ func (hc *Coordinator) handleTopicConsumerList(w http.ResponseWriter, r *http.Re
 	}
 }
 
+// handleConsumerList returns the consumer groups in the cluster, sorted by name. The list can be paged with the limit
+// and offset query parameters, and filtered with the prefix parameter. Without a limit, all groups are returned.
 func (hc *Coordinator) handleConsumerList(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
-	// Fetch consumer list from the storage module
+	query := r.URL.Query()
+	limit, err := getQueryInt(query.Get("limit"))
+	if err != nil {
+		hc.writeErrorResponse(w, r, http.StatusBadRequest, "invalid limit")
+		return
+	}
+	offset, err := getQueryInt(query.Get("offset"))
+	if err != nil {
+		hc.writeErrorResponse(w, r, http.StatusBadRequest, "invalid offset")
+		return
+	}
+
+	// Fetch the page of the consumer list from the storage module
 	request := &protocol.StorageRequest{
-		RequestType: protocol.StorageFetchConsumers,
+		RequestType: protocol.StorageFetchConsumersPage,
 		Cluster:     params.ByName("cluster"),
+		Prefix:      query.Get("prefix"),
+		Limit:       limit,
+		Skip:        offset,
 		Reply:       make(chan interface{}),
 	}
 	hc.App.StorageChannel <- request
@@ -181,16 +507,30 @@ func (hc *Coordinator) handleConsumerList(w http.ResponseWriter, r *http.Request
 	if response == nil {
 		hc.writeErrorResponse(w, r, http.StatusNotFound, "cluster not found")
 	} else {
+		page := response.(*protocol.ConsumerListPage)
 		requestInfo := makeRequestInfo(r)
 		hc.writeResponse(w, r, http.StatusOK, httpResponseConsumerList{
 			Error:     false,
 			Message:   "consumer list returned",
-			Consumers: response.([]string),
+			Consumers: page.Consumers,
+			Total:     page.Total,
 			Request:   requestInfo,
 		})
 	}
 }
 
+// getQueryInt parses a query parameter that must be a non-negative integer. A missing parameter is zero.
+func getQueryInt(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	result, err := strconv.Atoi(value)
+	if err == nil && result < 0 {
+		err = strconv.ErrRange
+	}
+	return result, err
+}
+
 func (hc *Coordinator) handleConsumerDetail(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	// Fetch consumer data from the storage module
 	request := &protocol.StorageRequest{
@@ -215,6 +555,94 @@ func (hc *Coordinator) handleConsumerDetail(w http.ResponseWriter, r *http.Reque
 	}
 }
 
+// The number of status changes that are held for a stream client before it starts missing them
+const streamBufferSize = 100
+
+// handleConsumerStream holds the connection open, and writes a line of JSON for each change of the evaluated status of
+// a consumer group in the cluster, until the client disconnects. Changes are only seen when the groups are evaluated,
+// such as by the notifier. A client that does not read the changes quickly enough misses some of them. If the consumer
+// is in the path, only the changes for that group are written. The stream is served at
+// /v3/kafka/:cluster/consumer-stream rather than /v3/kafka/:cluster/consumer/stream, so that a group named "stream" can
+// still be fetched with the consumer detail request.
+func (hc *Coordinator) handleConsumerStream(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	cluster := params.ByName("cluster")
+	group := params.ByName("consumer")
+	if !hc.clusterConfig(cluster).IsSet("cluster." + cluster) {
+		hc.writeErrorResponse(w, r, http.StatusNotFound, "cluster module not found")
+		return
+	}
+
+	changes := hc.App.StatusFeed.Subscribe(streamBufferSize)
+	defer hc.App.StatusFeed.Unsubscribe(changes)
+
+	// The stream is expected to stay open for longer than the server timeout. Any error here means there is no deadline
+	controller := http.NewResponseController(w)
+	controller.SetReadDeadline(time.Time{})
+	controller.SetWriteDeadline(time.Time{})
+
+	setCORSHeader(w)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	if err := controller.Flush(); err != nil {
+		hc.Log.Warn("cannot stream response", zap.Error(err))
+		return
+	}
+
+	encoder := json.NewEncoder(w)
+	for {
+		select {
+		case change := <-changes:
+			if (change.Cluster != cluster) || ((group != "") && (change.Group != group)) {
+				continue
+			}
+			if err := encoder.Encode(change); err != nil {
+				return
+			}
+			if err := controller.Flush(); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (hc *Coordinator) handleConsumerPartitionDetail(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	partitionID, err := strconv.ParseInt(params.ByName("partition"), 10, 32)
+	if err != nil || partitionID < 0 {
+		hc.writeErrorResponse(w, r, http.StatusBadRequest, "invalid partition")
+		return
+	}
+
+	// Fetch consumer data from the storage module, which includes the full offset ring for each partition
+	request := &protocol.StorageRequest{
+		RequestType: protocol.StorageFetchConsumer,
+		Cluster:     params.ByName("cluster"),
+		Group:       params.ByName("consumer"),
+		Reply:       make(chan interface{}),
+	}
+	hc.App.StorageChannel <- request
+	response := <-request.Reply
+
+	if response == nil {
+		hc.writeErrorResponse(w, r, http.StatusNotFound, "cluster or consumer not found")
+		return
+	}
+	partitions, ok := response.(protocol.ConsumerTopics)[params.ByName("topic")]
+	if !ok || int(partitionID) >= len(partitions) || partitions[partitionID] == nil {
+		hc.writeErrorResponse(w, r, http.StatusNotFound, "topic or partition not found")
+		return
+	}
+
+	requestInfo := makeRequestInfo(r)
+	hc.writeResponse(w, r, http.StatusOK, httpResponseConsumerPartitionDetail{
+		Error:     false,
+		Message:   "consumer partition detail returned",
+		Partition: partitions[partitionID],
+		Request:   requestInfo,
+	})
+}
+
 func (hc *Coordinator) handleConsumerStatus(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	// Fetch consumer data from the storage module
 	request := &protocol.EvaluatorRequest{
@@ -265,16 +693,54 @@ func (hc *Coordinator) handleConsumerStatusComplete(w http.ResponseWriter, r *ht
 	})
 }
 
+func (hc *Coordinator) handleConsumerEvaluate(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	// Have the evaluator check the group now, using the current offsets in the storage module
+	request := &protocol.EvaluatorRequest{
+		Cluster: params.ByName("cluster"),
+		Group:   params.ByName("consumer"),
+		ShowAll: true,
+		Refresh: true,
+		Reply:   make(chan *protocol.ConsumerGroupStatus),
+	}
+	hc.App.EvaluatorChannel <- request
+	response := <-request.Reply
+
+	responseCode := http.StatusOK
+	if response.Status == protocol.StatusNotFound {
+		responseCode = http.StatusNotFound
+	}
+
+	requestInfo := makeRequestInfo(r)
+	hc.writeResponse(w, r, responseCode, httpResponseConsumerStatus{
+		Error:   false,
+		Message: "consumer status evaluated",
+		Status:  *response,
+		Request: requestInfo,
+	})
+}
+
+// handleConsumerDelete removes a consumer group, or one topic of the group, from storage, so that a group that has been
+// decommissioned stops being listed and evaluated. If the group, or the topic, is not stored, a 404 is returned.
 func (hc *Coordinator) handleConsumerDelete(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	// Delete consumer from the storage module
 	request := &protocol.StorageRequest{
-		RequestType: protocol.StorageSetDeleteGroup,
+		RequestType: protocol.StorageSetEvictGroup,
 		Cluster:     params.ByName("cluster"),
 		Group:       params.ByName("consumer"),
 		Topic:       params.ByName("topic"),
+		Reply:       make(chan interface{}),
 	}
 	hc.App.StorageChannel <- request
 
+	if removed, _ := (<-request.Reply).(bool); !removed {
+		if request.Topic != "" {
+			hc.writeErrorResponse(w, r, http.StatusNotFound, "cluster, consumer, or topic not found")
+		} else {
+			hc.writeErrorResponse(w, r, http.StatusNotFound, "cluster or consumer not found")
+		}
+		return
+	}
+
 	requestInfo := makeRequestInfo(r)
 	hc.writeResponse(w, r, http.StatusOK, httpResponseError{
 		Error:   false,
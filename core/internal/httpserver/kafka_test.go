@@ -10,7 +10,9 @@
 package httpserver
 
 import (
+	"bufio"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
@@ -53,6 +55,92 @@ func TestHttpServer_handleClusterList(t *testing.T) {
 	assert.Equalf(t, []string{"testcluster"}, resp.Clusters, "Expected Clusters list to contain just testcluster, not %v", resp.Clusters)
 }
 
+func TestHttpServer_handleSummary(t *testing.T) {
+	coordinator := fixtureConfiguredCoordinator()
+
+	// Respond to the expected storage and evaluator requests
+	groupStatus := map[string]protocol.StatusConstant{
+		"okgroup":    protocol.StatusOK,
+		"warngroup":  protocol.StatusWarning,
+		"errgroup":   protocol.StatusError,
+		"othergroup": protocol.StatusOK,
+	}
+	evaluated := make(chan string, 10)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case request := <-coordinator.App.StorageChannel:
+				switch request.RequestType {
+				case protocol.StorageFetchClusters:
+					request.Reply <- []string{"testcluster", "othercluster"}
+				case protocol.StorageFetchConsumers:
+					if request.Cluster == "testcluster" {
+						request.Reply <- []string{"okgroup", "warngroup", "errgroup"}
+					} else {
+						request.Reply <- []string{"othergroup"}
+					}
+				default:
+					assert.Failf(t, "unexpected storage request", "Request type %v", request.RequestType)
+				}
+				close(request.Reply)
+			case request := <-coordinator.App.EvaluatorChannel:
+				// The last status is known for the groups in testcluster, one of which has a stalled partition
+				if request.SummaryReply != nil {
+					if request.Cluster == "testcluster" {
+						request.SummaryReply <- map[string]*protocol.GroupStatusSummary{
+							"okgroup":   {Status: protocol.StatusOK},
+							"warngroup": {Status: protocol.StatusWarning},
+							"errgroup":  {Status: protocol.StatusError, Stalled: true},
+						}
+					} else {
+						request.SummaryReply <- map[string]*protocol.GroupStatusSummary{}
+					}
+					continue
+				}
+				evaluated <- request.Group
+				request.Reply <- &protocol.ConsumerGroupStatus{
+					Cluster: request.Cluster,
+					Group:   request.Group,
+					Status:  groupStatus[request.Group],
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	req, err := http.NewRequest("GET", "/v3/summary", http.NoBody)
+	assert.NoError(t, err, "Expected request setup to return no error")
+	rr := httptest.NewRecorder()
+	coordinator.router.ServeHTTP(rr, req)
+	assert.Equalf(t, http.StatusOK, rr.Code, "Expected response code to be 200, not %v", rr.Code)
+
+	// Parse response body - the status is decoded as its string
+	decoder := json.NewDecoder(rr.Body)
+	var resp map[string]interface{}
+	err = decoder.Decode(&resp)
+	assert.NoError(t, err, "Expected body decode to return no error")
+	assert.Equal(t, false, resp["error"], "Expected response Error to be false")
+	assert.Equalf(t, "ERR", resp["status"], "Expected the worst status to be ERR, not %v", resp["status"])
+
+	clusters := resp["clusters"].(map[string]interface{})
+	testcluster := clusters["testcluster"].(map[string]interface{})
+	assert.Equalf(t, "ERR", testcluster["status"], "Expected testcluster status to be ERR, not %v", testcluster["status"])
+	assert.Equalf(t, float64(3), testcluster["total"], "Expected testcluster to have 3 groups, not %v", testcluster["total"])
+	assert.Equal(t, map[string]interface{}{"OK": float64(1), "WARN": float64(1), "ERR": float64(1)}, testcluster["counts"], "Expected one group in each status")
+	assert.Equalf(t, float64(1), testcluster["stalled"], "Expected testcluster to have 1 stalled group, not %v", testcluster["stalled"])
+	othercluster := clusters["othercluster"].(map[string]interface{})
+	assert.Equalf(t, "OK", othercluster["status"], "Expected othercluster status to be OK, not %v", othercluster["status"])
+	assert.Equal(t, map[string]interface{}{"OK": float64(1), "WARN": float64(0), "ERR": float64(0)}, othercluster["counts"], "Expected one OK group")
+	assert.Equalf(t, float64(0), othercluster["stalled"], "Expected othercluster to have no stalled groups, not %v", othercluster["stalled"])
+
+	// Only the group that the evaluator had no status for is evaluated
+	assert.Lenf(t, evaluated, 1, "Expected 1 group to be evaluated, not %v", len(evaluated))
+	assert.Equal(t, "othergroup", <-evaluated, "Expected the group without a last status to be evaluated")
+}
+
 func TestHttpServer_handleClusterDetail(t *testing.T) {
 	coordinator := fixtureConfiguredCoordinator()
 	viper.Set("client-profile.test.client-id", "testid")
@@ -93,6 +181,103 @@ func TestHttpServer_handleClusterDetail(t *testing.T) {
 	assert.Equalf(t, http.StatusNotFound, rr.Code, "Expected response code to be 404, not %v", rr.Code)
 }
 
+func TestHttpServer_handleClusterEvaluator(t *testing.T) {
+	coordinator := fixtureConfiguredCoordinator()
+
+	// Respond to the expected storage and evaluator requests
+	go func() {
+		for range 2 {
+			request := <-coordinator.App.StorageChannel
+			assert.Equalf(t, protocol.StorageFetchStats, request.RequestType, "Expected request of type StorageFetchStats, not %v", request.RequestType)
+			request.Reply <- map[string]*protocol.StorageClusterStats{"testcluster": {Intervals: 15}}
+			close(request.Reply)
+		}
+	}()
+	go func() {
+		request := <-coordinator.App.EvaluatorChannel
+		assert.Equalf(t, "testcluster", request.Cluster, "Expected request Cluster to be testcluster, not %v", request.Cluster)
+		assert.Equalf(t, "testgroup", request.Group, "Expected request Group to be testgroup, not %v", request.Group)
+		request.ParametersReply <- &protocol.EvaluatorParameters{
+			Cluster:      request.Cluster,
+			Group:        request.Group,
+			MaxLag:       3000,
+			MaxLagStatus: protocol.StatusWarning,
+			MaxLagSource: "group",
+		}
+	}()
+
+	req, err := http.NewRequest("GET", "/v3/kafka/testcluster/evaluator?group=testgroup", http.NoBody)
+	assert.NoError(t, err, "Expected request setup to return no error")
+	rr := httptest.NewRecorder()
+	coordinator.router.ServeHTTP(rr, req)
+	assert.Equalf(t, http.StatusOK, rr.Code, "Expected response code to be 200, not %v", rr.Code)
+
+	var resp map[string]interface{}
+	err = json.NewDecoder(rr.Body).Decode(&resp)
+	assert.NoError(t, err, "Expected body decode to return no error")
+	assert.Equalf(t, false, resp["error"], "Expected response Error to be false")
+	assert.Equalf(t, float64(15), resp["intervals"], "Expected Intervals to be 15, not %v", resp["intervals"])
+	parameters := resp["parameters"].(map[string]interface{})
+	assert.Equalf(t, float64(3000), parameters["max_lag"], "Expected max_lag to be 3000, not %v", parameters["max_lag"])
+	assert.Equalf(t, "WARN", parameters["max_lag_status"], "Expected max_lag_status to be WARN, not %v", parameters["max_lag_status"])
+	assert.Equalf(t, "group", parameters["max_lag_source"], "Expected max_lag_source to be group, not %v", parameters["max_lag_source"])
+
+	// A cluster that storage does not have is a 404
+	req, err = http.NewRequest("GET", "/v3/kafka/nocluster/evaluator", http.NoBody)
+	assert.NoError(t, err, "Expected request setup to return no error")
+	rr = httptest.NewRecorder()
+	coordinator.router.ServeHTTP(rr, req)
+	assert.Equalf(t, http.StatusNotFound, rr.Code, "Expected response code to be 404, not %v", rr.Code)
+}
+
+func TestHttpServer_handleClusterBrokers(t *testing.T) {
+	coordinator := fixtureConfiguredCoordinator()
+
+	// Respond to the expected storage requests
+	go func() {
+		request := <-coordinator.App.StorageChannel
+		assert.Equalf(t, protocol.StorageFetchClusterBrokers, request.RequestType, "Expected request of type StorageFetchClusterBrokers, not %v", request.RequestType)
+		assert.Equalf(t, "testcluster", request.Cluster, "Expected request Cluster to be testcluster, not %v", request.Cluster)
+		request.Reply <- &protocol.ClusterBrokers{ControllerID: 2, BrokerCount: 3, Timestamp: 9876, LastUpdate: 8765}
+		close(request.Reply)
+
+		// Second request has no controller
+		request = <-coordinator.App.StorageChannel
+		request.Reply <- &protocol.ClusterBrokers{ControllerID: -1, BrokerCount: 3, Timestamp: 9876, LastUpdate: 8765}
+		close(request.Reply)
+
+		// Third request is a 404
+		request = <-coordinator.App.StorageChannel
+		assert.Equalf(t, "nocluster", request.Cluster, "Expected request Cluster to be nocluster, not %v", request.Cluster)
+		close(request.Reply)
+	}()
+
+	for _, controller := range []int32{2, -1} {
+		req, err := http.NewRequest("GET", "/v3/kafka/testcluster/broker", http.NoBody)
+		assert.NoError(t, err, "Expected request setup to return no error")
+		rr := httptest.NewRecorder()
+		coordinator.router.ServeHTTP(rr, req)
+		assert.Equalf(t, http.StatusOK, rr.Code, "Expected response code to be 200, not %v", rr.Code)
+
+		decoder := json.NewDecoder(rr.Body)
+		var resp httpResponseClusterBrokers
+		err = decoder.Decode(&resp)
+		assert.NoError(t, err, "Expected body decode to return no error")
+		assert.False(t, resp.Error, "Expected response Error to be false")
+		assert.Equalf(t, controller, resp.Controller, "Expected Controller to be %v, not %v", controller, resp.Controller)
+		assert.Equalf(t, controller >= 0, resp.HasController, "Expected HasController to be %v, not %v", controller >= 0, resp.HasController)
+		assert.Equalf(t, int32(3), resp.BrokerCount, "Expected BrokerCount to be 3, not %v", resp.BrokerCount)
+		assert.Equalf(t, int64(8765), resp.LastUpdate, "Expected LastUpdate to be 8765, not %v", resp.LastUpdate)
+	}
+
+	// Call again for a 404
+	req, err := http.NewRequest("GET", "/v3/kafka/nocluster/broker", http.NoBody)
+	assert.NoError(t, err, "Expected request setup to return no error")
+	rr := httptest.NewRecorder()
+	coordinator.router.ServeHTTP(rr, req)
+	assert.Equalf(t, http.StatusNotFound, rr.Code, "Expected response code to be 404, not %v", rr.Code)
+}
+
 func TestHttpServer_handleTopicList(t *testing.T) {
 	coordinator := fixtureConfiguredCoordinator()
 
@@ -104,6 +289,19 @@ func TestHttpServer_handleTopicList(t *testing.T) {
 		request.Reply <- []string{"testtopic"}
 		close(request.Reply)
 
+		// The offsets are fetched for all topics
+		request = <-coordinator.App.StorageChannel
+		assert.Equalf(t, protocol.StorageFetchTopicOffsets, request.RequestType, "Expected request of type StorageFetchTopicOffsets, not %v", request.RequestType)
+		assert.Equalf(t, "testcluster", request.Cluster, "Expected request Cluster to be testcluster, not %v", request.Cluster)
+		assert.Equalf(t, "", request.Topic, "Expected request Topic to be empty, not %v", request.Topic)
+		request.Reply <- map[string][]*protocol.TopicPartitionOffsets{
+			"testtopic": {
+				{Partition: 0, Oldest: 100, Newest: 345, Timestamp: 9876},
+				{Partition: 1, Oldest: 800, Newest: 921, Timestamp: 9876},
+			},
+		}
+		close(request.Reply)
+
 		// Second request is a 404
 		request = <-coordinator.App.StorageChannel
 		assert.Equalf(t, protocol.StorageFetchTopics, request.RequestType, "Expected request of type StorageFetchTopics, not %v", request.RequestType)
@@ -128,6 +326,9 @@ func TestHttpServer_handleTopicList(t *testing.T) {
 	assert.NoError(t, err, "Expected body decode to return no error")
 	assert.False(t, resp.Error, "Expected response Error to be false")
 	assert.Equalf(t, []string{"testtopic"}, resp.Topics, "Expected Topics list to contain just testtopic, not %v", resp.Topics)
+	assert.Contains(t, resp.TopicOffsets, "testtopic", "Expected TopicOffsets to contain testtopic")
+	assert.Equalf(t, 2, resp.TopicOffsets["testtopic"].PartitionCount, "Expected PartitionCount to be 2, not %v", resp.TopicOffsets["testtopic"].PartitionCount)
+	assert.Equalf(t, int64(800), resp.TopicOffsets["testtopic"].Partitions[1].Oldest, "Expected partition 1 Oldest to be 800, not %v", resp.TopicOffsets["testtopic"].Partitions[1].Oldest)
 
 	// Call again for a 404
 	req, err = http.NewRequest("GET", "/v3/kafka/nocluster/topic", http.NoBody)
@@ -143,14 +344,15 @@ func TestHttpServer_handleConsumerList(t *testing.T) {
 	// Respond to the expected storage request
 	go func() {
 		request := <-coordinator.App.StorageChannel
-		assert.Equalf(t, protocol.StorageFetchConsumers, request.RequestType, "Expected request of type StorageFetchConsumers, not %v", request.RequestType)
+		assert.Equalf(t, protocol.StorageFetchConsumersPage, request.RequestType, "Expected request of type StorageFetchConsumersPage, not %v", request.RequestType)
 		assert.Equalf(t, "testcluster", request.Cluster, "Expected request Cluster to be testcluster, not %v", request.Cluster)
-		request.Reply <- []string{"testgroup"}
+		assert.Equalf(t, 0, request.Limit, "Expected request Limit to be 0, not %v", request.Limit)
+		request.Reply <- &protocol.ConsumerListPage{Consumers: []string{"testgroup"}, Total: 1}
 		close(request.Reply)
 
 		// Second request is a 404
 		request = <-coordinator.App.StorageChannel
-		assert.Equalf(t, protocol.StorageFetchConsumers, request.RequestType, "Expected request of type StorageFetchConsumers, not %v", request.RequestType)
+		assert.Equalf(t, protocol.StorageFetchConsumersPage, request.RequestType, "Expected request of type StorageFetchConsumersPage, not %v", request.RequestType)
 		assert.Equalf(t, "nocluster", request.Cluster, "Expected request Cluster to be nocluster, not %v", request.Cluster)
 		close(request.Reply)
 	}()
@@ -172,6 +374,7 @@ func TestHttpServer_handleConsumerList(t *testing.T) {
 	assert.NoError(t, err, "Expected body decode to return no error")
 	assert.False(t, resp.Error, "Expected response Error to be false")
 	assert.Equalf(t, []string{"testgroup"}, resp.Consumers, "Expected Consumers list to contain just testgroup, not %v", resp.Consumers)
+	assert.Equalf(t, 1, resp.Total, "Expected Total to be 1, not %v", resp.Total)
 
 	// Call again for a 404
 	req, err = http.NewRequest("GET", "/v3/kafka/nocluster/consumer", http.NoBody)
@@ -181,6 +384,43 @@ func TestHttpServer_handleConsumerList(t *testing.T) {
 	assert.Equalf(t, http.StatusNotFound, rr.Code, "Expected response code to be 404, not %v", rr.Code)
 }
 
+func TestHttpServer_handleConsumerList_Page(t *testing.T) {
+	coordinator := fixtureConfiguredCoordinator()
+
+	// Respond to the expected storage request
+	go func() {
+		request := <-coordinator.App.StorageChannel
+		assert.Equalf(t, protocol.StorageFetchConsumersPage, request.RequestType, "Expected request of type StorageFetchConsumersPage, not %v", request.RequestType)
+		assert.Equalf(t, "test", request.Prefix, "Expected request Prefix to be test, not %v", request.Prefix)
+		assert.Equalf(t, 2, request.Limit, "Expected request Limit to be 2, not %v", request.Limit)
+		assert.Equalf(t, 4, request.Skip, "Expected request Skip to be 4, not %v", request.Skip)
+		request.Reply <- &protocol.ConsumerListPage{Consumers: []string{"testgroup4", "testgroup5"}, Total: 10}
+		close(request.Reply)
+	}()
+
+	req, err := http.NewRequest("GET", "/v3/kafka/testcluster/consumer?prefix=test&limit=2&offset=4", http.NoBody)
+	assert.NoError(t, err, "Expected request setup to return no error")
+	rr := httptest.NewRecorder()
+	coordinator.router.ServeHTTP(rr, req)
+	assert.Equalf(t, http.StatusOK, rr.Code, "Expected response code to be 200, not %v", rr.Code)
+
+	decoder := json.NewDecoder(rr.Body)
+	var resp httpResponseConsumerList
+	err = decoder.Decode(&resp)
+	assert.NoError(t, err, "Expected body decode to return no error")
+	assert.Equalf(t, []string{"testgroup4", "testgroup5"}, resp.Consumers, "Expected Consumers list to contain the page, not %v", resp.Consumers)
+	assert.Equalf(t, 10, resp.Total, "Expected Total to be 10, not %v", resp.Total)
+
+	// Bad parameters are rejected without a storage request
+	for _, query := range []string{"limit=-1", "limit=lots", "offset=-5"} {
+		req, err = http.NewRequest("GET", "/v3/kafka/testcluster/consumer?"+query, http.NoBody)
+		assert.NoError(t, err, "Expected request setup to return no error")
+		rr = httptest.NewRecorder()
+		coordinator.router.ServeHTTP(rr, req)
+		assert.Equalf(t, http.StatusBadRequest, rr.Code, "Expected response code to be 400 for %v, not %v", query, rr.Code)
+	}
+}
+
 func TestHttpServer_handleTopicDetail(t *testing.T) {
 	coordinator := fixtureConfiguredCoordinator()
 
@@ -193,6 +433,25 @@ func TestHttpServer_handleTopicDetail(t *testing.T) {
 		request.Reply <- []int64{345, 921}
 		close(request.Reply)
 
+		// The regression flags are fetched for the same topic
+		request = <-coordinator.App.StorageChannel
+		assert.Equalf(t, protocol.StorageFetchTopicRegressed, request.RequestType, "Expected request of type StorageFetchTopicRegressed, not %v", request.RequestType)
+		assert.Equalf(t, "testtopic", request.Topic, "Expected request Topic to be testtopic, not %v", request.Topic)
+		request.Reply <- []bool{false, true}
+		close(request.Reply)
+
+		// And the range of stored offsets
+		request = <-coordinator.App.StorageChannel
+		assert.Equalf(t, protocol.StorageFetchTopicOffsets, request.RequestType, "Expected request of type StorageFetchTopicOffsets, not %v", request.RequestType)
+		assert.Equalf(t, "testtopic", request.Topic, "Expected request Topic to be testtopic, not %v", request.Topic)
+		request.Reply <- map[string][]*protocol.TopicPartitionOffsets{
+			"testtopic": {
+				{Partition: 0, Oldest: 100, Newest: 345, Timestamp: 9876},
+				{Partition: 1, Oldest: 800, Newest: 921, Timestamp: 9876},
+			},
+		}
+		close(request.Reply)
+
 		// Second request is a 404
 		request = <-coordinator.App.StorageChannel
 		assert.Equalf(t, protocol.StorageFetchTopic, request.RequestType, "Expected request of type StorageFetchTopic, not %v", request.RequestType)
@@ -225,6 +484,11 @@ func TestHttpServer_handleTopicDetail(t *testing.T) {
 	assert.NoError(t, err, "Expected body decode to return no error")
 	assert.False(t, resp.Error, "Expected response Error to be false")
 	assert.Equalf(t, []int64{345, 921}, resp.Offsets, "Expected Offsets list to contain [345, 921], not %v", resp.Offsets)
+	assert.Equalf(t, []bool{false, true}, resp.Regressed, "Expected Regressed list to contain [false, true], not %v", resp.Regressed)
+	assert.Equal(t, []*protocol.TopicPartitionOffsets{
+		{Partition: 0, Oldest: 100, Newest: 345, Timestamp: 9876},
+		{Partition: 1, Oldest: 800, Newest: 921, Timestamp: 9876},
+	}, resp.Partitions, "Expected Partitions to contain the range of offsets for each partition")
 
 	// Call again for a 404
 	req, err = http.NewRequest("GET", "/v3/kafka/nocluster/topic/testtopic", http.NoBody)
@@ -241,6 +505,138 @@ func TestHttpServer_handleTopicDetail(t *testing.T) {
 	assert.Equalf(t, http.StatusNotFound, rr.Code, "Expected response code to be 404, not %v", rr.Code)
 }
 
+func TestHttpServer_handleTopicTimestampOffsets(t *testing.T) {
+	coordinator := fixtureConfiguredCoordinator()
+	coordinator.App.OffsetLookups = &protocol.OffsetLookups{}
+	coordinator.App.OffsetLookups.Register("testcluster", func(topic string, timestamp int64) (map[int32]int64, error) {
+		assert.Equalf(t, int64(1636859338280), timestamp, "Expected lookup timestamp to be 1636859338280, not %v", timestamp)
+		switch topic {
+		case "failtopic":
+			// The leader of partition 1 fails
+			return map[int32]int64{0: 120, 2: 300}, errors.New("broker not available")
+		case "downtopic":
+			return map[int32]int64{}, errors.New("broker not available")
+		}
+		// Partition 1 has no message at or after the time
+		return map[int32]int64{0: 120, 1: -1}, nil
+	})
+
+	// Respond to the expected storage requests
+	go func() {
+		request := <-coordinator.App.StorageChannel
+		assert.Equalf(t, protocol.StorageFetchTopic, request.RequestType, "Expected request of type StorageFetchTopic, not %v", request.RequestType)
+		assert.Equalf(t, "testcluster", request.Cluster, "Expected request Cluster to be testcluster, not %v", request.Cluster)
+		assert.Equalf(t, "testtopic", request.Topic, "Expected request Topic to be testtopic, not %v", request.Topic)
+		request.Reply <- []int64{345, 921}
+		close(request.Reply)
+
+		// Second request is a 404
+		request = <-coordinator.App.StorageChannel
+		assert.Equalf(t, "notopic", request.Topic, "Expected request Topic to be notopic, not %v", request.Topic)
+		close(request.Reply)
+
+		// Third request is for a cluster with no lookup
+		request = <-coordinator.App.StorageChannel
+		assert.Equalf(t, "othercluster", request.Cluster, "Expected request Cluster to be othercluster, not %v", request.Cluster)
+		request.Reply <- []int64{345}
+		close(request.Reply)
+
+		// Fourth request is for a topic where one leader fails
+		request = <-coordinator.App.StorageChannel
+		assert.Equalf(t, "failtopic", request.Topic, "Expected request Topic to be failtopic, not %v", request.Topic)
+		request.Reply <- []int64{345, 921, 400}
+		close(request.Reply)
+
+		// Fifth request is for a topic where every leader fails
+		request = <-coordinator.App.StorageChannel
+		assert.Equalf(t, "downtopic", request.Topic, "Expected request Topic to be downtopic, not %v", request.Topic)
+		request.Reply <- []int64{345}
+		close(request.Reply)
+	}()
+
+	req, err := http.NewRequest("GET", "/v3/kafka/testcluster/topic/testtopic/offsets?timestamp=1636859338280", http.NoBody)
+	assert.NoError(t, err, "Expected request setup to return no error")
+	rr := httptest.NewRecorder()
+	coordinator.router.ServeHTTP(rr, req)
+	assert.Equalf(t, http.StatusOK, rr.Code, "Expected response code to be 200, not %v", rr.Code)
+
+	decoder := json.NewDecoder(rr.Body)
+	var resp httpResponseTopicTimestampOffsets
+	err = decoder.Decode(&resp)
+	assert.NoError(t, err, "Expected body decode to return no error")
+	assert.False(t, resp.Error, "Expected response Error to be false")
+	assert.Equal(t, int64(1636859338280), resp.Timestamp, "Expected the timestamp to be returned")
+	assert.Equal(t, []httpResponseTimestampOffset{
+		{Partition: 0, Offset: 120, Newest: false},
+		{Partition: 1, Offset: 921, Newest: true},
+	}, resp.Offsets, "Expected the broker offset for the partition with no message at the time")
+
+	req, err = http.NewRequest("GET", "/v3/kafka/testcluster/topic/notopic/offsets?timestamp=1636859338280", http.NoBody)
+	assert.NoError(t, err, "Expected request setup to return no error")
+	rr = httptest.NewRecorder()
+	coordinator.router.ServeHTTP(rr, req)
+	assert.Equalf(t, http.StatusNotFound, rr.Code, "Expected response code to be 404, not %v", rr.Code)
+
+	req, err = http.NewRequest("GET", "/v3/kafka/othercluster/topic/testtopic/offsets?timestamp=1636859338280", http.NoBody)
+	assert.NoError(t, err, "Expected request setup to return no error")
+	rr = httptest.NewRecorder()
+	coordinator.router.ServeHTTP(rr, req)
+	assert.Equalf(t, http.StatusServiceUnavailable, rr.Code, "Expected response code to be 503, not %v", rr.Code)
+
+	// The offsets from the leaders that responded are returned, and the others are marked as failed
+	req, err = http.NewRequest("GET", "/v3/kafka/testcluster/topic/failtopic/offsets?timestamp=1636859338280", http.NoBody)
+	assert.NoError(t, err, "Expected request setup to return no error")
+	rr = httptest.NewRecorder()
+	coordinator.router.ServeHTTP(rr, req)
+	assert.Equalf(t, http.StatusOK, rr.Code, "Expected response code to be 200, not %v", rr.Code)
+	resp = httpResponseTopicTimestampOffsets{}
+	err = json.NewDecoder(rr.Body).Decode(&resp)
+	assert.NoError(t, err, "Expected body decode to return no error")
+	assert.Contains(t, resp.Message, "broker not available", "Expected the message to include the error")
+	assert.Equal(t, []httpResponseTimestampOffset{
+		{Partition: 0, Offset: 120},
+		{Partition: 1, Offset: -1, Failed: true},
+		{Partition: 2, Offset: 300},
+	}, resp.Offsets, "Expected the partition of the failed leader to be marked as failed")
+
+	// If no offsets could be fetched, it is an error
+	req, err = http.NewRequest("GET", "/v3/kafka/testcluster/topic/downtopic/offsets?timestamp=1636859338280", http.NoBody)
+	assert.NoError(t, err, "Expected request setup to return no error")
+	rr = httptest.NewRecorder()
+	coordinator.router.ServeHTTP(rr, req)
+	assert.Equalf(t, http.StatusInternalServerError, rr.Code, "Expected response code to be 500, not %v", rr.Code)
+
+	// Bad timestamps are rejected before anything is fetched
+	for _, query := range []string{"", "?timestamp=yesterday", "?timestamp=-1"} {
+		req, err = http.NewRequest("GET", "/v3/kafka/testcluster/topic/testtopic/offsets"+query, http.NoBody)
+		assert.NoError(t, err, "Expected request setup to return no error")
+		rr = httptest.NewRecorder()
+		coordinator.router.ServeHTTP(rr, req)
+		assert.Equalf(t, http.StatusBadRequest, rr.Code, "Expected response code to be 400 for '%v', not %v", query, rr.Code)
+	}
+}
+
+func TestHttpServer_handleTopicTimestampOffsets_Unsupported(t *testing.T) {
+	coordinator := fixtureConfiguredCoordinator()
+	coordinator.App.OffsetLookups = &protocol.OffsetLookups{}
+	coordinator.App.OffsetLookups.Register("testcluster", func(topic string, timestamp int64) (map[int32]int64, error) {
+		return nil, protocol.ErrOffsetLookupUnsupported
+	})
+
+	go func() {
+		request := <-coordinator.App.StorageChannel
+		request.Reply <- []int64{345, 921}
+		close(request.Reply)
+	}()
+
+	// The cluster has a kafka-version before 0.10.1.0
+	req, err := http.NewRequest("GET", "/v3/kafka/testcluster/topic/testtopic/offsets?timestamp=1636859338280", http.NoBody)
+	assert.NoError(t, err, "Expected request setup to return no error")
+	rr := httptest.NewRecorder()
+	coordinator.router.ServeHTTP(rr, req)
+	assert.Equalf(t, http.StatusNotImplemented, rr.Code, "Expected response code to be 501, not %v", rr.Code)
+}
+
 func TestHttpServer_handleConsumerDetail(t *testing.T) {
 	coordinator := fixtureConfiguredCoordinator()
 
@@ -322,6 +718,73 @@ func TestHttpServer_handleConsumerDetail(t *testing.T) {
 	assert.Equalf(t, http.StatusNotFound, rr.Code, "Expected response code to be 404, not %v", rr.Code)
 }
 
+func TestHttpServer_handleConsumerPartitionDetail(t *testing.T) {
+	coordinator := fixtureConfiguredCoordinator()
+
+	// Respond to the expected storage requests
+	go func() {
+		for i := 0; i < 3; i++ {
+			request := <-coordinator.App.StorageChannel
+			assert.Equalf(t, protocol.StorageFetchConsumer, request.RequestType, "Expected request of type StorageFetchConsumer, not %v", request.RequestType)
+			assert.Equalf(t, "testcluster", request.Cluster, "Expected request Cluster to be testcluster, not %v", request.Cluster)
+			assert.Equalf(t, "testgroup", request.Group, "Expected request Group to be testgroup, not %v", request.Group)
+			response := make(protocol.ConsumerTopics)
+			response["testtopic"] = []*protocol.ConsumerPartition{
+				{Owner: "somehost"},
+				{
+					Offsets: []*protocol.ConsumerOffset{
+						nil,
+						{Offset: 9837458, Timestamp: 12837487, Lag: &protocol.Lag{Value: 2355}},
+						{Offset: 9837500, Timestamp: 12847487, Lag: &protocol.Lag{Value: 2313}},
+					},
+					Owner:      "otherhost",
+					CurrentLag: 2345,
+				},
+			}
+			request.Reply <- response
+			close(request.Reply)
+		}
+	}()
+
+	// Set up a request
+	req, err := http.NewRequest("GET", "/v3/kafka/testcluster/consumer/testgroup/topic/testtopic/partition/1", http.NoBody)
+	assert.NoError(t, err, "Expected request setup to return no error")
+
+	// Call the handler via httprouter
+	rr := httptest.NewRecorder()
+	coordinator.router.ServeHTTP(rr, req)
+
+	assert.Equalf(t, http.StatusOK, rr.Code, "Expected response code to be 200, not %v", rr.Code)
+
+	// Parse response body
+	decoder := json.NewDecoder(rr.Body)
+	var resp httpResponseConsumerPartitionDetail
+	err = decoder.Decode(&resp)
+	assert.NoError(t, err, "Expected body decode to return no error")
+	assert.False(t, resp.Error, "Expected response Error to be false")
+	assert.Equalf(t, "otherhost", resp.Partition.Owner, "Expected partition Owner to be otherhost, not %v", resp.Partition.Owner)
+	assert.Lenf(t, resp.Partition.Offsets, 3, "Expected partition to have the full offset ring, not %v", resp.Partition.Offsets)
+	assert.Nil(t, resp.Partition.Offsets[0], "Expected empty ring slots to be returned as null")
+	assert.Equalf(t, int64(9837500), resp.Partition.Offsets[2].Offset, "Expected Offset to be 9837500, not %v", resp.Partition.Offsets[2].Offset)
+	assert.Equalf(t, int64(12847487), resp.Partition.Offsets[2].Timestamp, "Expected Timestamp to be 12847487, not %v", resp.Partition.Offsets[2].Timestamp)
+
+	// Call again for a 404 on the partition, and then the topic
+	for _, path := range []string{"/v3/kafka/testcluster/consumer/testgroup/topic/testtopic/partition/2", "/v3/kafka/testcluster/consumer/testgroup/topic/notopic/partition/0"} {
+		req, err = http.NewRequest("GET", path, http.NoBody)
+		assert.NoError(t, err, "Expected request setup to return no error")
+		rr = httptest.NewRecorder()
+		coordinator.router.ServeHTTP(rr, req)
+		assert.Equalf(t, http.StatusNotFound, rr.Code, "Expected response code to be 404, not %v", rr.Code)
+	}
+
+	// An invalid partition is rejected without a storage request
+	req, err = http.NewRequest("GET", "/v3/kafka/testcluster/consumer/testgroup/topic/testtopic/partition/foo", http.NoBody)
+	assert.NoError(t, err, "Expected request setup to return no error")
+	rr = httptest.NewRecorder()
+	coordinator.router.ServeHTTP(rr, req)
+	assert.Equalf(t, http.StatusBadRequest, rr.Code, "Expected response code to be 400, not %v", rr.Code)
+}
+
 // Custom response types for consumer status, as the status field will be a string
 type ResponsePartition struct {
 	Topic      string                   `json:"topic"`
@@ -349,6 +812,68 @@ type ResponseType struct {
 	Request httpResponseRequestInfo `json:"request"`
 }
 
+func TestHttpServer_handleConsumerEvaluate(t *testing.T) {
+	coordinator := fixtureConfiguredCoordinator()
+
+	// Respond to the expected evaluator requests
+	go func() {
+		request := <-coordinator.App.EvaluatorChannel
+		assert.Equalf(t, "testcluster", request.Cluster, "Expected request Cluster to be testcluster, not %v", request.Cluster)
+		assert.Equalf(t, "testgroup", request.Group, "Expected request Group to be testgroup, not %v", request.Group)
+		assert.True(t, request.ShowAll, "Expected request ShowAll to be True")
+		assert.True(t, request.Refresh, "Expected request Refresh to be True")
+		request.Reply <- &protocol.ConsumerGroupStatus{
+			Cluster:         request.Cluster,
+			Group:           request.Group,
+			Status:          protocol.StatusWarning,
+			Complete:        1.0,
+			Partitions:      make([]*protocol.PartitionStatus, 0),
+			TotalPartitions: 2134,
+			TotalLag:        2345,
+		}
+		close(request.Reply)
+
+		// Second request is a 404
+		request = <-coordinator.App.EvaluatorChannel
+		assert.Equalf(t, "nogroup", request.Group, "Expected request Group to be nogroup, not %v", request.Group)
+		request.Reply <- &protocol.ConsumerGroupStatus{
+			Cluster:    request.Cluster,
+			Group:      request.Group,
+			Status:     protocol.StatusNotFound,
+			Complete:   1.0,
+			Partitions: make([]*protocol.PartitionStatus, 0),
+		}
+		close(request.Reply)
+	}()
+
+	// Set up a request
+	req, err := http.NewRequest("POST", "/v3/kafka/testcluster/consumer/testgroup/evaluate", http.NoBody)
+	assert.NoError(t, err, "Expected request setup to return no error")
+
+	// Call the handler via httprouter
+	rr := httptest.NewRecorder()
+	coordinator.router.ServeHTTP(rr, req)
+
+	assert.Equalf(t, http.StatusOK, rr.Code, "Expected response code to be 200, not %v", rr.Code)
+
+	// Parse response body
+	decoder := json.NewDecoder(rr.Body)
+	var resp ResponseType
+	err = decoder.Decode(&resp)
+	assert.NoError(t, err, "Expected body decode to return no error")
+	assert.False(t, resp.Error, "Expected response Error to be false")
+	assert.NotNil(t, resp.Status, "Expected Status to not be nil")
+	assert.Equalf(t, "WARN", resp.Status.Status, "Expected Status to be WARN, not %v", resp.Status.Status)
+	assert.Equalf(t, uint64(2345), resp.Status.TotalLag, "Expected TotalLag to be 2345, not %v", resp.Status.TotalLag)
+
+	// Call again for a 404
+	req, err = http.NewRequest("POST", "/v3/kafka/testcluster/consumer/nogroup/evaluate", http.NoBody)
+	assert.NoError(t, err, "Expected request setup to return no error")
+	rr = httptest.NewRecorder()
+	coordinator.router.ServeHTTP(rr, req)
+	assert.Equalf(t, http.StatusNotFound, rr.Code, "Expected response code to be 404, not %v", rr.Code)
+}
+
 func TestHttpServer_handleConsumerStatus(t *testing.T) {
 	coordinator := fixtureConfiguredCoordinator()
 
@@ -535,10 +1060,11 @@ func TestHttpServer_handleConsumerDelete(t *testing.T) {
 	// Respond to the expected storage request
 	go func() {
 		request := <-coordinator.App.StorageChannel
-		assert.Equalf(t, protocol.StorageSetDeleteGroup, request.RequestType, "Expected request of type StorageFetchConsumer, not %v", request.RequestType)
+		assert.Equalf(t, protocol.StorageSetEvictGroup, request.RequestType, "Expected request of type StorageSetEvictGroup, not %v", request.RequestType)
 		assert.Equalf(t, "testcluster", request.Cluster, "Expected request Cluster to be testcluster, not %v", request.Cluster)
 		assert.Equalf(t, "testgroup", request.Group, "Expected request Group to be testgroup, not %v", request.Group)
-		// No response expected
+		request.Reply <- true
+		close(request.Reply)
 	}()
 
 	// Set up a request
@@ -561,3 +1087,135 @@ func TestHttpServer_handleConsumerDelete(t *testing.T) {
 	assert.NoError(t, err, "Expected body decode to return no error")
 	assert.False(t, resp.Error, "Expected response Error to be false")
 }
+
+func TestHttpServer_handleConsumerDelete_NotFound(t *testing.T) {
+	coordinator := fixtureConfiguredCoordinator()
+
+	// Respond to the expected storage request
+	go func() {
+		request := <-coordinator.App.StorageChannel
+		assert.Equalf(t, protocol.StorageSetEvictGroup, request.RequestType, "Expected request of type StorageSetEvictGroup, not %v", request.RequestType)
+		assert.Equalf(t, "testtopic", request.Topic, "Expected request Topic to be testtopic, not %v", request.Topic)
+		request.Reply <- false
+		close(request.Reply)
+	}()
+
+	req, err := http.NewRequest("DELETE", "/v3/kafka/testcluster/consumer/nogroup/topic/testtopic", http.NoBody)
+	assert.NoError(t, err, "Expected request setup to return no error")
+	rr := httptest.NewRecorder()
+	coordinator.router.ServeHTTP(rr, req)
+	assert.Equalf(t, http.StatusNotFound, rr.Code, "Expected response code to be 404, not %v", rr.Code)
+
+	decoder := json.NewDecoder(rr.Body)
+	var resp httpResponseError
+	err = decoder.Decode(&resp)
+	assert.NoError(t, err, "Expected body decode to return no error")
+	assert.True(t, resp.Error, "Expected response Error to be true")
+}
+
+func TestHttpServer_handleConsumerStream(t *testing.T) {
+	coordinator := fixtureConfiguredCoordinator()
+	coordinator.App.StatusFeed = &protocol.StatusFeed{}
+	viper.Set("cluster.testcluster.class-name", "kafka")
+
+	server := httptest.NewServer(coordinator.router)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/v3/kafka/testcluster/consumer-stream")
+	assert.NoError(t, err, "Expected request to return no error")
+	defer resp.Body.Close()
+	assert.Equalf(t, http.StatusOK, resp.StatusCode, "Expected response code to be 200, not %v", resp.StatusCode)
+	assert.Equalf(t, "application/x-ndjson", resp.Header.Get("Content-Type"), "Unexpected Content-Type header: %v", resp.Header.Get("Content-Type"))
+
+	// The headers are only sent once the stream has subscribed, so changes published now are seen
+	coordinator.App.StatusFeed.Publish(&protocol.ConsumerStatusChange{
+		Cluster: "othercluster",
+		Group:   "testgroup",
+		Status:  protocol.StatusError,
+	})
+	coordinator.App.StatusFeed.Publish(&protocol.ConsumerStatusChange{
+		Cluster:        "testcluster",
+		Group:          "testgroup",
+		Status:         protocol.StatusWarning,
+		PreviousStatus: protocol.StatusOK,
+		TotalLag:       100,
+		Timestamp:      1000,
+	})
+
+	line, err := bufio.NewReader(resp.Body).ReadBytes('\n')
+	assert.NoError(t, err, "Expected reading a line to return no error")
+
+	// The status constants do not unmarshal, so the line is checked as a map
+	var change map[string]interface{}
+	err = json.Unmarshal(line, &change)
+	assert.NoError(t, err, "Failed to parse output line as JSON: %v", string(line))
+	assert.Equalf(t, "testcluster", change["cluster"], "Expected the change for testcluster, not %v", change["cluster"])
+	assert.Equalf(t, "testgroup", change["group"], "Expected group to be testgroup, not %v", change["group"])
+	assert.Equalf(t, "WARN", change["status"], "Expected status to be WARN, not %v", change["status"])
+	assert.Equalf(t, "OK", change["previous_status"], "Expected previous_status to be OK, not %v", change["previous_status"])
+	assert.Equalf(t, float64(100), change["totallag"], "Expected totallag to be 100, not %v", change["totallag"])
+}
+
+func TestHttpServer_handleConsumerStream_Group(t *testing.T) {
+	coordinator := fixtureConfiguredCoordinator()
+	coordinator.App.StatusFeed = &protocol.StatusFeed{}
+	viper.Set("cluster.testcluster.class-name", "kafka")
+
+	server := httptest.NewServer(coordinator.router)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/v3/kafka/testcluster/consumer-stream/testgroup")
+	assert.NoError(t, err, "Expected request to return no error")
+	defer resp.Body.Close()
+	assert.Equalf(t, http.StatusOK, resp.StatusCode, "Expected response code to be 200, not %v", resp.StatusCode)
+
+	// Only the changes for the group in the path are written
+	coordinator.App.StatusFeed.Publish(&protocol.ConsumerStatusChange{
+		Cluster: "testcluster",
+		Group:   "othergroup",
+		Status:  protocol.StatusError,
+	})
+	coordinator.App.StatusFeed.Publish(&protocol.ConsumerStatusChange{
+		Cluster: "testcluster",
+		Group:   "testgroup",
+		Status:  protocol.StatusWarning,
+	})
+
+	line, err := bufio.NewReader(resp.Body).ReadBytes('\n')
+	assert.NoError(t, err, "Expected reading a line to return no error")
+	var change map[string]interface{}
+	err = json.Unmarshal(line, &change)
+	assert.NoError(t, err, "Failed to parse output line as JSON: %v", string(line))
+	assert.Equalf(t, "testgroup", change["group"], "Expected group to be testgroup, not %v", change["group"])
+}
+
+func TestHttpServer_handleConsumerDetail_GroupNamedStream(t *testing.T) {
+	coordinator := fixtureConfiguredCoordinator()
+
+	// A group named stream is fetched from storage, rather than being streamed, as the stream is at /consumer-stream
+	go func() {
+		request := <-coordinator.App.StorageChannel
+		assert.Equalf(t, protocol.StorageFetchConsumer, request.RequestType, "Expected request of type StorageFetchConsumer, not %v", request.RequestType)
+		assert.Equalf(t, "stream", request.Group, "Expected request Group to be stream, not %v", request.Group)
+		request.Reply <- protocol.ConsumerTopics{}
+		close(request.Reply)
+	}()
+
+	req, err := http.NewRequest("GET", "/v3/kafka/testcluster/consumer/stream", http.NoBody)
+	assert.NoError(t, err, "Expected request setup to return no error")
+	rr := httptest.NewRecorder()
+	coordinator.router.ServeHTTP(rr, req)
+	assert.Equalf(t, http.StatusOK, rr.Code, "Expected response code to be 200, not %v", rr.Code)
+	assert.Containsf(t, rr.Body.String(), "consumer detail returned", "Expected the consumer detail, not %v", rr.Body.String())
+}
+
+func TestHttpServer_handleConsumerStream_NoCluster(t *testing.T) {
+	coordinator := fixtureConfiguredCoordinator()
+	coordinator.App.StatusFeed = &protocol.StatusFeed{}
+
+	req, err := http.NewRequest("GET", "/v3/kafka/nocluster/consumer-stream", http.NoBody)
+	assert.NoError(t, err, "Expected request setup to return no error")
+	rr := httptest.NewRecorder()
+	coordinator.router.ServeHTTP(rr, req)
+	assert.Equalf(t, http.StatusNotFound, rr.Code, "Expected response code to be 404, not %v", rr.Code)
+}
@@ -0,0 +1,99 @@
+package httpserver
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type offsetsTopicLagKey struct {
+	cluster string
+	module  string
+}
+
+// offsetsTopicLagCollector exports how far each consumer module is behind on the offsets topic that it reads, which
+// is the high-water mark of each partition minus the position the module has consumed up to. The lag is read from the
+// modules when the metrics are scraped.
+type offsetsTopicLagCollector struct {
+	lock    sync.RWMutex
+	modules map[offsetsTopicLagKey]func() []int64
+
+	lag *prometheus.Desc
+}
+
+var offsetsTopicLag = newOffsetsTopicLagCollector()
+
+func init() {
+	prometheus.MustRegister(offsetsTopicLag)
+}
+
+func newOffsetsTopicLagCollector() *offsetsTopicLagCollector {
+	return &offsetsTopicLagCollector{
+		modules: make(map[offsetsTopicLagKey]func() []int64),
+		lag: prometheus.NewDesc("burrow_consumer_offsets_topic_lag",
+			"Number of messages in the offsets topic that the consumer module has not yet read", []string{"cluster", "module", "partition"}, nil),
+	}
+}
+
+// RegisterOffsetsTopicLag exports the lag of a consumer module on its offsets topic, labeled with the cluster and the
+// name of the module. The func returns the lag for each partition, indexed by partition ID. Registering again for the
+// same cluster and module replaces the previous func.
+func RegisterOffsetsTopicLag(cluster, module string, lag func() []int64) {
+	if lag == nil {
+		return
+	}
+
+	offsetsTopicLag.lock.Lock()
+	defer offsetsTopicLag.lock.Unlock()
+	offsetsTopicLag.modules[offsetsTopicLagKey{cluster: cluster, module: module}] = lag
+}
+
+// UnregisterOffsetsTopicLag stops exporting the offsets topic lag for the cluster and module
+func UnregisterOffsetsTopicLag(cluster, module string) {
+	offsetsTopicLag.lock.Lock()
+	defer offsetsTopicLag.lock.Unlock()
+	delete(offsetsTopicLag.modules, offsetsTopicLagKey{cluster: cluster, module: module})
+}
+
+// snapshot returns the current lag of every registered module, keyed by module name
+func (c *offsetsTopicLagCollector) snapshot() map[string]*httpResponseOffsetsTopicLag {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	modules := make(map[string]*httpResponseOffsetsTopicLag, len(c.modules))
+	for key, lagFunc := range c.modules {
+		lag := &httpResponseOffsetsTopicLag{
+			Cluster:    key.cluster,
+			Partitions: lagFunc(),
+		}
+		for _, partitionLag := range lag.Partitions {
+			lag.TotalLag += partitionLag
+		}
+		modules[key.module] = lag
+	}
+	return modules
+}
+
+// Describe implements prometheus.Collector
+func (c *offsetsTopicLagCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.lag
+}
+
+// Collect implements prometheus.Collector
+func (c *offsetsTopicLagCollector) Collect(ch chan<- prometheus.Metric) {
+	modules := c.snapshot()
+	names := make([]string, 0, len(modules))
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for partition, lag := range modules[name].Partitions {
+			ch <- prometheus.MustNewConstMetric(c.lag, prometheus.GaugeValue, float64(lag),
+				modules[name].Cluster, name, strconv.Itoa(partition))
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterOffsetsTopicLag(t *testing.T) {
+	RegisterOffsetsTopicLag("testcluster", "consumer.test", func() []int64 { return []int64{5, 0} })
+
+	expected := `
+# HELP burrow_consumer_offsets_topic_lag Number of messages in the offsets topic that the consumer module has not yet read
+# TYPE burrow_consumer_offsets_topic_lag gauge
+burrow_consumer_offsets_topic_lag{cluster="testcluster",module="consumer.test",partition="0"} 5
+burrow_consumer_offsets_topic_lag{cluster="testcluster",module="consumer.test",partition="1"} 0
+`
+	assert.NoError(t, testutil.CollectAndCompare(offsetsTopicLag, strings.NewReader(expected)))
+
+	UnregisterOffsetsTopicLag("testcluster", "consumer.test")
+	assert.Equal(t, 0, testutil.CollectAndCount(offsetsTopicLag), "Expected no metrics after unregistering")
+}
+
+func TestHttpServer_getOffsetsTopicLag(t *testing.T) {
+	coordinator := fixtureConfiguredCoordinator()
+	RegisterOffsetsTopicLag("testcluster", "consumer.test", func() []int64 { return []int64{5, 7} })
+	defer UnregisterOffsetsTopicLag("testcluster", "consumer.test")
+
+	// Set up a request
+	req, err := http.NewRequest("GET", "/v3/admin/offsets-topic-lag", http.NoBody)
+	assert.NoError(t, err, "Expected request setup to return no error")
+
+	// Call the handler via httprouter
+	rr := httptest.NewRecorder()
+	coordinator.router.ServeHTTP(rr, req)
+	assert.Equalf(t, http.StatusOK, rr.Code, "Expected response code to be 200, not %v", rr.Code)
+
+	// Parse response body
+	decoder := json.NewDecoder(rr.Body)
+	var resp httpResponseOffsetsTopicLags
+	err = decoder.Decode(&resp)
+	assert.NoError(t, err, "Expected body decode to return no error")
+	assert.False(t, resp.Error, "Expected response Error to be false")
+	assert.Contains(t, resp.Modules, "consumer.test", "Expected lag for consumer.test")
+	assert.Equal(t, "testcluster", resp.Modules["consumer.test"].Cluster)
+	assert.Equal(t, []int64{5, 7}, resp.Modules["consumer.test"].Partitions)
+	assert.Equalf(t, int64(12), resp.Modules["consumer.test"].TotalLag, "Expected TotalLag to be 12, not %v", resp.Modules["consumer.test"].TotalLag)
+}
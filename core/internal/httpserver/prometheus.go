@@ -3,6 +3,7 @@ package httpserver
 import (
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 
@@ -60,8 +61,36 @@ var (
 		},
 		[]string{"cluster", "topic", "partition"},
 	)
+
+	clusterOffsetFetchDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "burrow_cluster_offset_fetch_duration_seconds",
+			Help:    "Time taken by the cluster module to fetch the broker offsets for all partitions, for each refresh",
+			Buckets: prometheus.ExponentialBuckets(0.01, 2, 14),
+		},
+		[]string{"cluster"},
+	)
+
+	evaluationDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "burrow_evaluator_evaluation_duration_seconds",
+			Help:    "Time taken by the evaluator to fetch the offsets for a consumer group from storage and evaluate its status",
+			Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+		},
+		[]string{"cluster"},
+	)
 )
 
+// ObserveOffsetFetchDuration records the time taken by one refresh of the broker offsets for the cluster
+func ObserveOffsetFetchDuration(cluster string, duration time.Duration) {
+	clusterOffsetFetchDuration.WithLabelValues(cluster).Observe(duration.Seconds())
+}
+
+// ObserveEvaluationDuration records the time taken by one evaluation of a consumer group in the cluster
+func ObserveEvaluationDuration(cluster string, duration time.Duration) {
+	evaluationDuration.WithLabelValues(cluster).Observe(duration.Seconds())
+}
+
 // DeleteConsumerMetrics deletes all metrics that are labeled with a consumer group
 func DeleteConsumerMetrics(cluster, consumer string) {
 	labels := map[string]string{
@@ -4,7 +4,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/linkedin/Burrow/core/protocol"
@@ -170,3 +172,19 @@ func TestHttpServer_handlePrometheusMetrics(t *testing.T) {
 	assert.Contains(t, promExp, `burrow_kafka_consumer_partition_lag{cluster="testcluster",consumer_group="testgroup",partition="0",topic="incomplete"} 0`)
 	assert.NotContains(t, promExp, "testgroup2")
 }
+
+func TestHttpServer_ObserveDurations(t *testing.T) {
+	ObserveOffsetFetchDuration("durationcluster", 150*time.Millisecond)
+	ObserveOffsetFetchDuration("durationcluster", 3*time.Second)
+	ObserveEvaluationDuration("durationcluster", 2*time.Millisecond)
+
+	rr := httptest.NewRecorder()
+	promhttp.Handler().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", http.NoBody))
+
+	promExp := rr.Body.String()
+	assert.Contains(t, promExp, `burrow_cluster_offset_fetch_duration_seconds_count{cluster="durationcluster"} 2`)
+	assert.Contains(t, promExp, `burrow_cluster_offset_fetch_duration_seconds_sum{cluster="durationcluster"} 3.15`)
+	assert.Contains(t, promExp, `burrow_cluster_offset_fetch_duration_seconds_bucket{cluster="durationcluster",le="0.16"} 1`)
+	assert.Contains(t, promExp, `burrow_evaluator_evaluation_duration_seconds_count{cluster="durationcluster"} 1`)
+	assert.Contains(t, promExp, `burrow_evaluator_evaluation_duration_seconds_bucket{cluster="durationcluster",le="0.004"} 1`)
+}
@@ -0,0 +1,104 @@
+package httpserver
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rcrowley/go-metrics"
+)
+
+// saramaLatencyQuantiles are the quantiles of the Sarama request latency histogram that are exported
+var saramaLatencyQuantiles = []float64{0.5, 0.75, 0.95, 0.99}
+
+type saramaMetricsKey struct {
+	cluster string
+	module  string
+}
+
+// saramaMetricsCollector exports a selection of the metrics that Sarama records in the MetricRegistry of each Kafka
+// client. The values are read from the registries when the metrics are scraped.
+type saramaMetricsCollector struct {
+	lock       sync.RWMutex
+	registries map[saramaMetricsKey]metrics.Registry
+
+	requestLatency   *prometheus.Desc
+	requestRate      *prometheus.Desc
+	incomingByteRate *prometheus.Desc
+	outgoingByteRate *prometheus.Desc
+}
+
+var saramaMetrics = newSaramaMetricsCollector()
+
+func init() {
+	prometheus.MustRegister(saramaMetrics)
+}
+
+func newSaramaMetricsCollector() *saramaMetricsCollector {
+	labels := []string{"cluster", "module"}
+	return &saramaMetricsCollector{
+		registries: make(map[saramaMetricsKey]metrics.Registry),
+		requestLatency: prometheus.NewDesc("burrow_kafka_client_request_latency_ms",
+			"Round-trip time of requests from the Kafka client to the brokers, in milliseconds", labels, nil),
+		requestRate: prometheus.NewDesc("burrow_kafka_client_request_rate",
+			"One-minute rate of requests sent by the Kafka client to the brokers, per second", labels, nil),
+		incomingByteRate: prometheus.NewDesc("burrow_kafka_client_incoming_byte_rate",
+			"One-minute rate of bytes received by the Kafka client from the brokers, per second", labels, nil),
+		outgoingByteRate: prometheus.NewDesc("burrow_kafka_client_outgoing_byte_rate",
+			"One-minute rate of bytes sent by the Kafka client to the brokers, per second", labels, nil),
+	}
+}
+
+// RegisterSaramaMetrics exports the metrics in the Sarama MetricRegistry of a Kafka client, labeled with the cluster
+// and the name of the module that owns the client. Registering again for the same cluster and module replaces the
+// previous registry.
+func RegisterSaramaMetrics(cluster, module string, registry metrics.Registry) {
+	if registry == nil {
+		return
+	}
+
+	saramaMetrics.lock.Lock()
+	defer saramaMetrics.lock.Unlock()
+	saramaMetrics.registries[saramaMetricsKey{cluster: cluster, module: module}] = registry
+}
+
+// UnregisterSaramaMetrics stops exporting the Sarama metrics for the cluster and module
+func UnregisterSaramaMetrics(cluster, module string) {
+	saramaMetrics.lock.Lock()
+	defer saramaMetrics.lock.Unlock()
+	delete(saramaMetrics.registries, saramaMetricsKey{cluster: cluster, module: module})
+}
+
+// Describe implements prometheus.Collector
+func (c *saramaMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.requestLatency
+	ch <- c.requestRate
+	ch <- c.incomingByteRate
+	ch <- c.outgoingByteRate
+}
+
+// Collect implements prometheus.Collector
+func (c *saramaMetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	for key, registry := range c.registries {
+		if histogram, ok := registry.Get("request-latency-in-ms").(metrics.Histogram); ok {
+			snapshot := histogram.Snapshot()
+			quantiles := make(map[float64]float64, len(saramaLatencyQuantiles))
+			for i, value := range snapshot.Percentiles(saramaLatencyQuantiles) {
+				quantiles[saramaLatencyQuantiles[i]] = value
+			}
+			ch <- prometheus.MustNewConstSummary(c.requestLatency, uint64(snapshot.Count()), float64(snapshot.Sum()),
+				quantiles, key.cluster, key.module)
+		}
+		c.collectMeter(ch, registry, "request-rate", c.requestRate, key)
+		c.collectMeter(ch, registry, "incoming-byte-rate", c.incomingByteRate, key)
+		c.collectMeter(ch, registry, "outgoing-byte-rate", c.outgoingByteRate, key)
+	}
+}
+
+func (c *saramaMetricsCollector) collectMeter(ch chan<- prometheus.Metric, registry metrics.Registry, name string, desc *prometheus.Desc, key saramaMetricsKey) {
+	if meter, ok := registry.Get(name).(metrics.Meter); ok {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, meter.Snapshot().Rate1(), key.cluster, key.module)
+	}
+}
@@ -0,0 +1,36 @@
+package httpserver
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterSaramaMetrics(t *testing.T) {
+	registry := metrics.NewRegistry()
+	metrics.GetOrRegisterMeter("request-rate", registry).Mark(1)
+	metrics.GetOrRegisterMeter("incoming-byte-rate", registry).Mark(100)
+	metrics.GetOrRegisterMeter("outgoing-byte-rate", registry).Mark(50)
+	metrics.GetOrRegisterHistogram("request-latency-in-ms", registry, metrics.NewUniformSample(10)).Update(12)
+
+	RegisterSaramaMetrics("testcluster", "cluster", registry)
+	assert.Equal(t, 4, testutil.CollectAndCount(saramaMetrics), "Expected one of each metric")
+
+	expected := `
+# HELP burrow_kafka_client_request_latency_ms Round-trip time of requests from the Kafka client to the brokers, in milliseconds
+# TYPE burrow_kafka_client_request_latency_ms summary
+burrow_kafka_client_request_latency_ms{cluster="testcluster",module="cluster",quantile="0.5"} 12
+burrow_kafka_client_request_latency_ms{cluster="testcluster",module="cluster",quantile="0.75"} 12
+burrow_kafka_client_request_latency_ms{cluster="testcluster",module="cluster",quantile="0.95"} 12
+burrow_kafka_client_request_latency_ms{cluster="testcluster",module="cluster",quantile="0.99"} 12
+burrow_kafka_client_request_latency_ms_sum{cluster="testcluster",module="cluster"} 12
+burrow_kafka_client_request_latency_ms_count{cluster="testcluster",module="cluster"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(saramaMetrics, strings.NewReader(expected), "burrow_kafka_client_request_latency_ms"))
+
+	UnregisterSaramaMetrics("testcluster", "cluster")
+	assert.Equal(t, 0, testutil.CollectAndCount(saramaMetrics), "Expected no metrics after unregistering")
+}
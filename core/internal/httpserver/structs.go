@@ -22,11 +22,76 @@ type httpResponseLogLevel struct {
 	Request httpResponseRequestInfo `json:"request"`
 }
 
+type httpResponseHealth struct {
+	Error   bool                                `json:"error"`
+	Message string                              `json:"message"`
+	Ready   bool                                `json:"ready"`
+	Checks  map[string]*httpResponseHealthCheck `json:"checks"`
+	Request httpResponseRequestInfo             `json:"request"`
+}
+
+type httpResponseHealthCheck struct {
+	Healthy bool   `json:"healthy"`
+	Message string `json:"message,omitempty"`
+}
+
+type httpResponseStorageStats struct {
+	Error    bool                                     `json:"error"`
+	Message  string                                   `json:"message"`
+	Clusters map[string]*protocol.StorageClusterStats `json:"clusters"`
+	Request  httpResponseRequestInfo                  `json:"request"`
+}
+
+type httpResponseOffsetsTopicLag struct {
+	Cluster    string  `json:"cluster"`
+	Partitions []int64 `json:"partitions"`
+	TotalLag   int64   `json:"total_lag"`
+}
+
+type httpResponseOffsetsTopicLags struct {
+	Error   bool                                    `json:"error"`
+	Message string                                  `json:"message"`
+	Modules map[string]*httpResponseOffsetsTopicLag `json:"modules"`
+	Request httpResponseRequestInfo                 `json:"request"`
+}
+
+type httpResponseEvaluatorParameters struct {
+	Error      bool                          `json:"error"`
+	Message    string                        `json:"message"`
+	Intervals  int                           `json:"intervals"`
+	Parameters *protocol.EvaluatorParameters `json:"parameters"`
+	Request    httpResponseRequestInfo       `json:"request"`
+}
+
+type httpResponseSummary struct {
+	Error    bool                                   `json:"error"`
+	Message  string                                 `json:"message"`
+	Status   protocol.StatusConstant                `json:"status"`
+	Clusters map[string]*httpResponseClusterSummary `json:"clusters"`
+	Request  httpResponseRequestInfo                `json:"request"`
+}
+
+type httpResponseClusterSummary struct {
+	Status  protocol.StatusConstant `json:"status"`
+	Total   int                     `json:"total"`
+	Counts  map[string]int          `json:"counts"`
+	Stalled int                     `json:"stalled"`
+}
+
 type httpResponseRequestInfo struct {
 	URI  string `json:"url"`
 	Host string `json:"host"`
 }
 
+type httpResponseNotifierPause struct {
+	Error       bool                    `json:"error"`
+	Message     string                  `json:"message"`
+	Notifier    string                  `json:"notifier"`
+	Paused      bool                    `json:"paused"`
+	PausedUntil int64                   `json:"paused_until"`
+	Request     httpResponseRequestInfo `json:"request"`
+}
+
 type httpResponseError struct {
 	Error   bool                    `json:"error"`
 	Message string                  `json:"message"`
@@ -62,18 +127,52 @@ type httpResponseClusterList struct {
 	Request  httpResponseRequestInfo `json:"request"`
 }
 
+type httpResponseClusterBrokers struct {
+	Error         bool                    `json:"error"`
+	Message       string                  `json:"message"`
+	Controller    int32                   `json:"controller"`
+	HasController bool                    `json:"hasController"`
+	BrokerCount   int32                   `json:"brokerCount"`
+	Timestamp     int64                   `json:"timestamp"`
+	LastUpdate    int64                   `json:"lastUpdate"`
+	Request       httpResponseRequestInfo `json:"request"`
+}
+
 type httpResponseTopicList struct {
-	Error   bool                    `json:"error"`
-	Message string                  `json:"message"`
-	Topics  []string                `json:"topics"`
-	Request httpResponseRequestInfo `json:"request"`
+	Error        bool                                 `json:"error"`
+	Message      string                               `json:"message"`
+	Topics       []string                             `json:"topics"`
+	TopicOffsets map[string]*httpResponseTopicOffsets `json:"topicOffsets"`
+	Request      httpResponseRequestInfo              `json:"request"`
+}
+
+type httpResponseTopicOffsets struct {
+	PartitionCount int                               `json:"partitionCount"`
+	Partitions     []*protocol.TopicPartitionOffsets `json:"partitions"`
 }
 
 type httpResponseTopicDetail struct {
-	Error   bool                    `json:"error"`
-	Message string                  `json:"message"`
-	Offsets []int64                 `json:"offsets"`
-	Request httpResponseRequestInfo `json:"request"`
+	Error      bool                              `json:"error"`
+	Message    string                            `json:"message"`
+	Offsets    []int64                           `json:"offsets"`
+	Regressed  []bool                            `json:"brokerOffsetRegressed"`
+	Partitions []*protocol.TopicPartitionOffsets `json:"partitions"`
+	Request    httpResponseRequestInfo           `json:"request"`
+}
+
+type httpResponseTopicTimestampOffsets struct {
+	Error     bool                          `json:"error"`
+	Message   string                        `json:"message"`
+	Timestamp int64                         `json:"timestamp"`
+	Offsets   []httpResponseTimestampOffset `json:"offsets"`
+	Request   httpResponseRequestInfo       `json:"request"`
+}
+
+type httpResponseTimestampOffset struct {
+	Partition int32 `json:"partition"`
+	Offset    int64 `json:"offset"`
+	Newest    bool  `json:"newest"`
+	Failed    bool  `json:"failed"`
 }
 
 type httpResponseTopicConsumerDetail struct {
@@ -87,6 +186,7 @@ type httpResponseConsumerList struct {
 	Error     bool                    `json:"error"`
 	Message   string                  `json:"message"`
 	Consumers []string                `json:"consumers"`
+	Total     int                     `json:"total"`
 	Request   httpResponseRequestInfo `json:"request"`
 }
 
@@ -97,6 +197,13 @@ type httpResponseConsumerDetail struct {
 	Request httpResponseRequestInfo `json:"request"`
 }
 
+type httpResponseConsumerPartitionDetail struct {
+	Error     bool                        `json:"error"`
+	Message   string                      `json:"message"`
+	Partition *protocol.ConsumerPartition `json:"partition"`
+	Request   httpResponseRequestInfo     `json:"request"`
+}
+
 type httpResponseConsumerStatus struct {
 	Error   bool                         `json:"error"`
 	Message string                       `json:"message"`
@@ -21,6 +21,16 @@
 //
 // * http - Call a remote HTTP endpoint
 //
+// * teams - Post an Adaptive Card to a Microsoft Teams incoming webhook or workflow
+//
+// * kafka - Produce a JSON event to a Kafka topic when the status of a group changes
+//
+// * pagerduty - Trigger and resolve incidents with the PagerDuty Events API v2
+//
+// * opsgenie - Create and close alerts with the OpsGenie Alert API
+//
+// * datadog - Send events and lag metrics to Datadog, with the API or a DogStatsD server
+//
 // * null - This is a no-op notifier that is used for testing only
 package notifier
 
@@ -55,11 +65,33 @@ type Module interface {
 	Notify(*protocol.ConsumerGroupStatus, string, time.Time, bool)
 }
 
+// groupIncidentModule is an optional interface for notifier modules that identify an incident by its cluster and group,
+// rather than by the event ID. The throttle summaries are only sent to these modules if throttle-summary is set, as a
+// summary does not have a group of its own.
+type groupIncidentModule interface {
+	identifiesIncidentsByGroup() bool
+}
+
+// templateModule is an optional interface for notifier modules that do not send the template-open and template-close
+// templates. The templates are not compiled for a module that returns false, so they do not need to be set.
+type templateModule interface {
+	usesTemplates() bool
+}
+
 type consumerGroup struct {
 	ID         string
 	Start      time.Time
 	LastNotify map[string]time.Time
 	LastEval   time.Time
+	Cooldown   map[string]*notifyCooldown
+}
+
+// notifyCooldown records the last open notification sent by a module for a group. Unlike LastNotify, it is kept when
+// an incident closes, so that a group that goes back and forth across the threshold is not notified every time.
+type notifyCooldown struct {
+	Sent       time.Time
+	Status     protocol.StatusConstant
+	Suppressed bool
 }
 
 type clusterGroups struct {
@@ -81,16 +113,24 @@ type Coordinator struct {
 
 	minInterval       int64
 	groupRefresh      helpers.Ticker
+	throttleFlush     helpers.Ticker
 	doEvaluations     bool
 	evaluatorResponse chan *protocol.ConsumerGroupStatus
 	running           sync.WaitGroup
 	quitChannel       chan struct{}
+	shutdownTimeout   time.Duration
 
 	templateParseFunc func(...string) (*template.Template, error)
 	notifyModuleFunc  func(Module, *protocol.ConsumerGroupStatus, time.Time, string)
 
 	clusters    map[string]*clusterGroups
 	clusterLock *sync.RWMutex
+
+	// Token buckets for the modules that have max-notifications-per-interval configured
+	throttles map[string]*notifyThrottle
+
+	// The topics that each module with a topic-allowlist notifies for
+	topicAllowlists map[string]*regexp.Regexp
 }
 
 // getModuleForClass returns the correct module based on the passed className. As part of the Configure steps, if there
@@ -124,6 +164,56 @@ func getModuleForClass(app *protocol.ApplicationContext, moduleName, className s
 			templateOpen:   templateOpen,
 			templateClose:  templateClose,
 		}
+	case "teams":
+		return &TeamsNotifier{
+			App:            app,
+			Log:            logger,
+			groupAllowlist: groupAllowlist,
+			groupDenylist:  groupDenylist,
+			extras:         extras,
+			templateOpen:   templateOpen,
+			templateClose:  templateClose,
+		}
+	case "kafka":
+		return &KafkaNotifier{
+			App:            app,
+			Log:            logger,
+			groupAllowlist: groupAllowlist,
+			groupDenylist:  groupDenylist,
+			extras:         extras,
+			templateOpen:   templateOpen,
+			templateClose:  templateClose,
+		}
+	case "pagerduty":
+		return &PagerDutyNotifier{
+			App:            app,
+			Log:            logger,
+			groupAllowlist: groupAllowlist,
+			groupDenylist:  groupDenylist,
+			extras:         extras,
+			templateOpen:   templateOpen,
+			templateClose:  templateClose,
+		}
+	case "opsgenie":
+		return &OpsGenieNotifier{
+			App:            app,
+			Log:            logger,
+			groupAllowlist: groupAllowlist,
+			groupDenylist:  groupDenylist,
+			extras:         extras,
+			templateOpen:   templateOpen,
+			templateClose:  templateClose,
+		}
+	case "datadog":
+		return &DatadogNotifier{
+			App:            app,
+			Log:            logger,
+			groupAllowlist: groupAllowlist,
+			groupDenylist:  groupDenylist,
+			extras:         extras,
+			templateOpen:   templateOpen,
+			templateClose:  templateClose,
+		}
 	case "null":
 		return &NullNotifier{
 			App:            app,
@@ -145,6 +235,9 @@ func getModuleForClass(app *protocol.ApplicationContext, moduleName, className s
 func (nc *Coordinator) Configure() {
 	nc.Log.Info("configuring")
 	nc.modules = make(map[string]protocol.Module)
+	nc.throttles = make(map[string]*notifyThrottle)
+	nc.topicAllowlists = make(map[string]*regexp.Regexp)
+	minThrottleInterval := int64(math.MaxInt64)
 
 	nc.clusters = make(map[string]*clusterGroups)
 	nc.clusterLock = &sync.RWMutex{}
@@ -175,6 +268,7 @@ func (nc *Coordinator) Configure() {
 		viper.SetDefault(configRoot+".interval", 60)
 		viper.SetDefault(configRoot+".send-interval", viper.GetInt64(configRoot+".interval"))
 		viper.SetDefault(configRoot+".threshold", 2)
+		viper.SetDefault(configRoot+".throttle-interval", viper.GetInt64(configRoot+".interval"))
 
 		// Check for disallowed config values
 		if viper.IsSet(configRoot+".group-whitelist") || viper.IsSet(configRoot+".group-blacklist") {
@@ -206,34 +300,85 @@ func (nc *Coordinator) Configure() {
 			groupDenylist = re
 		}
 
+		// Compile the allowlist for the topics to notify for. This needs the status of each topic from the evaluator
+		topicAllowlist := viper.GetString(configRoot + ".topic-allowlist")
+		if topicAllowlist != "" {
+			if !evaluatorHasTopicStatus() {
+				panic("Notifier '" + name + "' has a topic-allowlist, but no evaluator has topic-status enabled")
+			}
+			re, err := regexp.Compile(topicAllowlist)
+			if err != nil {
+				nc.Log.Panic("Failed to compile topic allowlist", zap.String("module", name))
+				panic(err)
+			}
+			nc.topicAllowlists[name] = re
+		}
+
 		// Set up extra fields for the templates
 		extras := viper.GetStringMapString(configRoot + ".extras")
 
-		// Compile the templates
-		var templateOpen, templateClose *template.Template
-		tmpl, err := nc.templateParseFunc(viper.GetString(configRoot + ".template-open"))
-		if err != nil {
-			nc.Log.Panic("Failed to compile TemplateOpen", zap.Error(err), zap.String("module", name))
-			panic(err)
-		}
-		templateOpen = tmpl.Templates()[0]
-
-		if viper.GetBool(configRoot + ".send-close") {
-			tmpl, err = nc.templateParseFunc(viper.GetString(configRoot + ".template-close"))
+		// Compile the templates, if the module uses them, and set the module up with them
+		className := viper.GetString(configRoot + ".class-name")
+		module := getModuleForClass(nc.App, name, className, groupAllowlist, groupDenylist, extras, nil, nil)
+		if templates, ok := module.(templateModule); !ok || templates.usesTemplates() {
+			var templateOpen, templateClose *template.Template
+			tmpl, err := nc.templateParseFunc(viper.GetString(configRoot + ".template-open"))
 			if err != nil {
-				nc.Log.Panic("Failed to compile TemplateClose", zap.Error(err), zap.String("module", name))
+				nc.Log.Panic("Failed to compile TemplateOpen", zap.Error(err), zap.String("module", name))
 				panic(err)
 			}
-			templateClose = tmpl.Templates()[0]
-		}
+			templateOpen = tmpl.Templates()[0]
 
-		module := getModuleForClass(nc.App, name, viper.GetString(configRoot+".class-name"), groupAllowlist, groupDenylist, extras, templateOpen, templateClose)
+			if viper.GetBool(configRoot + ".send-close") {
+				tmpl, err = nc.templateParseFunc(viper.GetString(configRoot + ".template-close"))
+				if err != nil {
+					nc.Log.Panic("Failed to compile TemplateClose", zap.Error(err), zap.String("module", name))
+					panic(err)
+				}
+				templateClose = tmpl.Templates()[0]
+			}
+			module = getModuleForClass(nc.App, name, className, groupAllowlist, groupDenylist, extras, templateOpen, templateClose)
+		}
 		module.Configure(name, configRoot)
 		nc.modules[name] = module
 		interval := viper.GetInt64(configRoot + ".interval")
 		if interval < nc.minInterval {
 			nc.minInterval = interval
 		}
+
+		// Limit the total number of notifications the module sends, if configured
+		maxNotifications := viper.GetInt(configRoot + ".max-notifications-per-interval")
+		if maxNotifications < 0 {
+			panic("Notifier '" + name + "' has a negative max-notifications-per-interval")
+		}
+		if maxNotifications > 0 {
+			throttleInterval := viper.GetInt64(configRoot + ".throttle-interval")
+			if throttleInterval <= 0 {
+				panic("Notifier '" + name + "' must have a throttle-interval greater than zero")
+			}
+			nc.throttles[name] = newNotifyThrottle(maxNotifications, time.Duration(throttleInterval)*time.Second)
+			groupIncidents, ok := module.(groupIncidentModule)
+			viper.SetDefault(configRoot+".throttle-summary", !(ok && groupIncidents.identifiesIncidentsByGroup()))
+			nc.throttles[name].summarize = viper.GetBool(configRoot + ".throttle-summary")
+			if throttleInterval < minThrottleInterval {
+				minThrottleInterval = throttleInterval
+			}
+		}
+	}
+
+	// On shutdown, wait this long for the notifications that are being sent to finish
+	viper.SetDefault("general.shutdown-timeout", 30)
+	shutdownTimeout := viper.GetInt("general.shutdown-timeout")
+	if shutdownTimeout < 0 {
+		panic("shutdown-timeout must not be negative")
+	}
+	nc.shutdownTimeout = time.Duration(shutdownTimeout) * time.Second
+
+	// Load the group owners for the owner template helper
+	err := groupOwnership.load(viper.GetString("general.ownership-file"), viper.GetString("general.default-owner"))
+	if err != nil {
+		nc.Log.Panic("Failed to load ownership-file", zap.Error(err))
+		panic(err)
 	}
 
 	// If there are no modules specified, the minInterval will still be MaxInt64. Set it to a large number of seconds
@@ -244,6 +389,9 @@ func (nc *Coordinator) Configure() {
 	// Set up the tickers but do not start them
 	// TODO - should probably be configurable
 	nc.groupRefresh = helpers.NewPausableTicker(60 * time.Second)
+	if len(nc.throttles) > 0 {
+		nc.throttleFlush = helpers.NewPausableTicker(time.Duration(minThrottleInterval) * time.Second)
+	}
 }
 
 // Start calls each of the configured notifier modules' underlying Start funcs. If any module Start returns an error,
@@ -267,6 +415,9 @@ func (nc *Coordinator) Start() error {
 
 	// Run the group refresh regardless of whether or not we're sending notifications
 	nc.groupRefresh.Start()
+	if nc.throttleFlush != nil {
+		nc.throttleFlush.Start()
+	}
 
 	// Run a goroutine to manage whether or not we're performing evaluations
 	go nc.manageEvalLoop()
@@ -278,23 +429,57 @@ func (nc *Coordinator) Start() error {
 	return nil
 }
 
+// ReloadFilters loads the general ownership-file from the provided configuration again, so that changes to the group
+// owners used in the notification templates are picked up without restarting Burrow. If the file cannot be loaded,
+// the current owners are kept.
+func (nc *Coordinator) ReloadFilters(config *viper.Viper) error {
+	nc.Log.Info("reloading group owners")
+	return groupOwnership.load(config.GetString("general.ownership-file"), config.GetString("general.default-owner"))
+}
+
 // Stop stops the group refresh ticker, and causes the evaluation response handler and the evaluation request manager to
-// both stop. It then calls each of the configured notifier modules' underlying Stop funcs. It is expected that the
-// module Stop will not return until the module has been completely stopped. While an error can be returned, this func
-// always returns no error, as a failure during stopping is not a critical failure
+// both stop, so no new evaluation results are accepted. It then waits, for up to the general shutdown-timeout, for
+// the notifications that are already being sent to finish, and sends the pending throttle summaries, before calling
+// each of the configured notifier modules' underlying Stop funcs. It is expected that the module Stop will not return
+// until the module has been completely stopped. While an error can be returned, this func always returns no error, as
+// a failure during stopping is not a critical failure
 func (nc *Coordinator) Stop() error {
 	nc.Log.Info("stopping")
 
 	nc.groupRefresh.Stop()
+	if nc.throttleFlush != nil {
+		nc.throttleFlush.Stop()
+	}
 	nc.doEvaluations = false
 
 	close(nc.quitChannel)
+	if nc.drain() {
+		nc.sendThrottleSummaries()
+	}
 
 	// The individual notifier modules can choose whether or not to implement a wait in the Stop routine
 	helpers.StopCoordinatorModules(nc.modules)
 	return nil
 }
 
+// drain waits for the running goroutines, including any that are sending notifications, to finish. It returns false if
+// they did not finish within the shutdown timeout, in which case the notifications that are still being sent are lost
+func (nc *Coordinator) drain() bool {
+	drained := make(chan struct{})
+	go func() {
+		nc.running.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return true
+	case <-time.After(nc.shutdownTimeout):
+		nc.Log.Warn("timed out waiting for notifications to be sent", zap.Duration("timeout", nc.shutdownTimeout))
+		return false
+	}
+}
+
 func (nc *Coordinator) manageEvalLoop() {
 	lock := nc.App.Zookeeper.NewLock(nc.App.ZookeeperRoot + "/notifier")
 
@@ -334,16 +519,65 @@ func (nc *Coordinator) manageEvalLoop() {
 func (nc *Coordinator) tickerLoop() {
 	defer nc.running.Done()
 
+	var throttleChannel <-chan time.Time
+	if nc.throttleFlush != nil {
+		throttleChannel = nc.throttleFlush.GetChannel()
+	}
+
 	for {
 		select {
 		case <-nc.groupRefresh.GetChannel():
 			nc.sendClusterRequest()
+		case <-throttleChannel:
+			nc.sendThrottleSummaries()
 		case <-nc.quitChannel:
 			return
 		}
 	}
 }
 
+// sendThrottleSummaries sends a single summary notification for each cluster and status, for the groups whose
+// notifications were throttled by a module since the last summary, and that pass the group filters of the module. The
+// summaries for a module, cluster and status share an event ID and start time until there are no throttled groups for
+// them, when a close summary is sent if send-close is set. A summary is skipped if AcceptConsumerGroup rejects it.
+func (nc *Coordinator) sendThrottleSummaries() {
+	now := time.Now()
+	for name, throttle := range nc.throttles {
+		module := nc.modules[name].(Module)
+		acceptGroup := func(group string) bool {
+			return moduleAcceptsGroup(module, group)
+		}
+		for _, summary := range throttle.summaries(now, acceptGroup) {
+			if (summary.close && (!viper.GetBool("notifier." + name + ".send-close"))) || (!module.AcceptConsumerGroup(summary.status)) {
+				continue
+			}
+			module.GetLogger().Info("sending throttled notification summary",
+				zap.String("cluster", summary.status.Cluster),
+				zap.String("status", summary.status.Status.String()),
+				zap.String("summary", summary.status.Group),
+			)
+			module.Notify(summary.status, throttleEventID(name, summary.status.Cluster, summary.throttled), summary.start, summary.close)
+		}
+	}
+}
+
+// throttleEventID returns the event ID for the throttle summaries of a module for a cluster and status, which is the
+// same every time, so that the summaries update a single incident
+func throttleEventID(name, cluster string, status protocol.StatusConstant) string {
+	return uuid.NewSHA1(uuid.NameSpace_OID, []byte("burrow-throttle/"+name+"/"+cluster+"/"+status.String())).String()
+}
+
+// moduleAcceptsGroup returns true if the group passes the group-allowlist and group-denylist of the module. No
+// allowlist means everything passes
+func moduleAcceptsGroup(module Module, group string) bool {
+	groupAllowlist := module.GetGroupAllowlist()
+	groupDenylist := module.GetGroupDenylist()
+	if (groupAllowlist != nil) && (!groupAllowlist.MatchString(group)) {
+		return false
+	}
+	return (groupDenylist == nil) || (!groupDenylist.MatchString(group))
+}
+
 func (nc *Coordinator) sendClusterRequest() {
 	// Send a request to the storage module for a list of clusters, and spawn a goroutine to process it
 	request := &protocol.StorageRequest{
@@ -435,17 +669,7 @@ func (nc *Coordinator) checkAndSendResponseToModules(response *protocol.Consumer
 
 	for _, genericModule := range nc.modules {
 		module := genericModule.(Module)
-
-		// No allowlist means everything passes
-		groupAllowlist := module.GetGroupAllowlist()
-		groupDenylist := module.GetGroupDenylist()
-		if (groupAllowlist != nil) && (!groupAllowlist.MatchString(response.Group)) {
-			continue
-		}
-		if (groupDenylist != nil) && groupDenylist.MatchString(response.Group) {
-			continue
-		}
-		if module.AcceptConsumerGroup(response) {
+		if moduleAcceptsGroup(module, response.Group) && module.AcceptConsumerGroup(response) {
 			nc.running.Add(1)
 			nc.notifyModuleFunc(module, response, cgroup.Start, cgroup.ID)
 		}
@@ -516,6 +740,7 @@ func (nc *Coordinator) processConsumerList(cluster string, replyChan chan interf
 			if _, ok := nc.clusters[cluster].Groups[group]; !ok {
 				nc.clusters[cluster].Groups[group] = &consumerGroup{
 					LastNotify: make(map[string]time.Time),
+					Cooldown:   make(map[string]*notifyCooldown),
 					LastEval:   time.Now().Add(-time.Duration(rand.Int63n(nc.minInterval*1000)) * time.Millisecond),
 				}
 			}
@@ -541,10 +766,45 @@ func (nc *Coordinator) notifyModule(module Module, status *protocol.ConsumerGrou
 		return
 	}
 
-	// Closed incidents get sent regardless of the threshold for the module
 	moduleName := module.GetName()
+	if cgroup.Cooldown == nil {
+		cgroup.Cooldown = make(map[string]*notifyCooldown)
+	}
+	cooldown := cgroup.Cooldown[moduleName]
+
+	// A module with a topic allowlist only looks at the status of those topics
+	topicAllowlist, scoped := nc.topicAllowlists[moduleName]
+	if scoped {
+		status = scopeStatusToTopics(status, topicAllowlist)
+	}
+
+	// Nothing is sent while the module is paused. An incident that closes during the pause is forgotten, so that the
+	// next one is notified as usual after the pause ends
+	if _, paused := nc.App.NotifierPauses.PausedUntil(moduleName); paused {
+		if (!startTime.IsZero()) && (status.Status == protocol.StatusOK) {
+			cgroup.LastNotify[moduleName] = time.Time{}
+			if cooldown != nil {
+				cooldown.Suppressed = false
+			}
+		}
+		return
+	}
+
+	// Closed incidents get sent regardless of the threshold for the module, unless the cooldown suppressed the open
+	// notification for the incident. As the topics of a module with a topic allowlist can be OK while the group is not,
+	// the close is only sent for those if the open was
 	if (!startTime.IsZero()) && (status.Status == protocol.StatusOK) && viper.GetBool("notifier."+moduleName+".send-close") {
-		module.Notify(status, eventID, startTime, true)
+		if scoped && cgroup.LastNotify[moduleName].IsZero() {
+			if cooldown != nil {
+				cooldown.Suppressed = false
+			}
+			return
+		}
+		if (cooldown != nil) && cooldown.Suppressed {
+			cooldown.Suppressed = false
+		} else {
+			module.Notify(status, eventID, startTime, true)
+		}
 		cgroup.LastNotify[module.GetName()] = time.Time{}
 		return
 	}
@@ -559,10 +819,97 @@ func (nc *Coordinator) notifyModule(module Module, status *protocol.ConsumerGrou
 		return
 	}
 
-	// Only send the notification if it's been at least our Interval since the last one for this group
+	// If a cooldown is configured, don't notify again for the same (or a better) status until it has elapsed, even if
+	// an incident closed in the meantime
 	currentTime := time.Now()
+	cooldownPeriod := time.Duration(viper.GetInt("notifier."+moduleName+".cooldown")) * time.Second
+	if (cooldownPeriod > 0) && (cooldown != nil) && (currentTime.Sub(cooldown.Sent) < cooldownPeriod) && (status.Status <= cooldown.Status) {
+		if cgroup.LastNotify[moduleName].IsZero() {
+			cooldown.Suppressed = true
+		}
+		return
+	}
+
+	// Only send the notification if it's been at least our Interval since the last one for this group
 	if currentTime.Sub(cgroup.LastNotify[module.GetName()]) > (time.Duration(viper.GetInt("notifier."+moduleName+".send-interval")) * time.Second) {
+		// If the module has sent too many notifications recently, this one goes in the next summary instead. As with the
+		// cooldown, the close notification is not sent if the open notification for the incident was throttled
+		if throttle, ok := nc.throttles[moduleName]; ok && (!throttle.allow(status, currentTime)) {
+			if cgroup.LastNotify[moduleName].IsZero() {
+				if cooldown == nil {
+					cooldown = &notifyCooldown{}
+					cgroup.Cooldown[moduleName] = cooldown
+				}
+				cooldown.Suppressed = true
+			}
+			return
+		}
 		module.Notify(status, eventID, startTime, false)
 		cgroup.LastNotify[module.GetName()] = currentTime
+		cgroup.Cooldown[moduleName] = &notifyCooldown{
+			Sent:   currentTime,
+			Status: status.Status,
+		}
+	}
+}
+
+// evaluatorHasTopicStatus returns true if any evaluator module is configured to calculate the status of each topic
+func evaluatorHasTopicStatus() bool {
+	for name := range viper.GetStringMap("evaluator") {
+		if viper.GetBool("evaluator." + name + ".topic-status") {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeStatusToTopics returns a copy of the group status that only covers the topics that match the allowlist. The
+// status, lag, and partition counts are calculated from the status of those topics, and only their partitions are
+// included. If no topic matches, the status is OK.
+func scopeStatusToTopics(status *protocol.ConsumerGroupStatus, topicAllowlist *regexp.Regexp) *protocol.ConsumerGroupStatus {
+	scoped := &protocol.ConsumerGroupStatus{
+		Cluster:     status.Cluster,
+		Group:       status.Group,
+		Status:      protocol.StatusOK,
+		Complete:    1.0,
+		Partitions:  make([]*protocol.PartitionStatus, 0),
+		Rebalancing: status.Rebalancing,
+		Rebalances:  status.Rebalances,
+		Active:      status.Active,
+		MemberCount: status.MemberCount,
+		Topics:      make(map[string]*protocol.TopicStatus),
+	}
+
+	completePartitions := float32(0)
+	for topic, topicStatus := range status.Topics {
+		if !topicAllowlist.MatchString(topic) {
+			continue
+		}
+		scoped.Topics[topic] = topicStatus
+		scoped.TotalPartitions += topicStatus.TotalPartitions
+		scoped.TotalLag += topicStatus.TotalLag
+		completePartitions += topicStatus.Complete * float32(topicStatus.TotalPartitions)
+		if topicStatus.Status > scoped.Status {
+			scoped.Status = topicStatus.Status
+		}
+		if topicStatus.MaxTimeLag > scoped.MaxTimeLag {
+			scoped.MaxTimeLag = topicStatus.MaxTimeLag
+		}
+		if (topicStatus.Maxlag != nil) && ((scoped.Maxlag == nil) || (topicStatus.Maxlag.CurrentLag > scoped.Maxlag.CurrentLag)) {
+			scoped.Maxlag = topicStatus.Maxlag
+		}
+	}
+	if scoped.TotalPartitions > 0 {
+		scoped.Complete = completePartitions / float32(scoped.TotalPartitions)
+	}
+
+	for _, partition := range status.Partitions {
+		if topicAllowlist.MatchString(partition.Topic) {
+			scoped.Partitions = append(scoped.Partitions, partition)
+			if partition.OffsetReset {
+				scoped.OffsetReset = true
+			}
+		}
 	}
+	return scoped
 }
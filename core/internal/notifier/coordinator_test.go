@@ -13,6 +13,7 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"strconv"
 	"sync"
 	"testing"
 	"text/template"
@@ -81,6 +82,35 @@ func TestCoordinator_Configure(t *testing.T) {
 	assert.Equalf(t, "bar", val, "Expected value of extras 'foo' to be 'bar', not %v", val)
 }
 
+func TestCoordinator_Configure_KafkaNoTemplates(t *testing.T) {
+	coordinator := fixtureCoordinator()
+	coordinator.templateParseFunc = func(filenames ...string) (*template.Template, error) {
+		return nil, errors.New("templates should not be parsed")
+	}
+	viper.Set("notifier.test.class-name", "kafka")
+	viper.Set("notifier.test.servers", []string{"broker1.example.com:1234"})
+	viper.Set("notifier.test.topic", "burrow-events")
+	coordinator.Configure()
+
+	module := coordinator.modules["test"].(*KafkaNotifier)
+	assert.Nil(t, module.templateOpen, "Expected templateOpen to not be set")
+	assert.Nil(t, module.templateClose, "Expected templateClose to not be set")
+}
+
+func TestCoordinator_Configure_PagerDutyNoTemplates(t *testing.T) {
+	coordinator := fixtureCoordinator()
+	coordinator.templateParseFunc = func(filenames ...string) (*template.Template, error) {
+		return nil, errors.New("templates should not be parsed")
+	}
+	viper.Set("notifier.test.class-name", "pagerduty")
+	viper.Set("notifier.test.routing-key", "testroutingkey")
+	coordinator.Configure()
+
+	module := coordinator.modules["test"].(*PagerDutyNotifier)
+	assert.Nil(t, module.templateOpen, "Expected templateOpen to not be set")
+	assert.Nil(t, module.templateClose, "Expected templateClose to not be set")
+}
+
 func TestCoordinator_Configure_NoModules(t *testing.T) {
 	coordinator := fixtureCoordinator()
 	viper.Reset()
@@ -160,6 +190,55 @@ func TestCoordinator_StartStop(t *testing.T) {
 	mockModule.AssertCalled(t, "Stop")
 }
 
+func TestCoordinator_Stop_Drain(t *testing.T) {
+	coordinator := fixtureCoordinator()
+	coordinator.Configure()
+	mockModule := &helpers.MockModule{}
+	mockModule.On("Stop").Return(nil)
+	coordinator.modules["test"] = mockModule
+
+	// A notification that is being sent when Stop is called is finished before the modules are stopped
+	sent := false
+	coordinator.running.Add(1)
+	go func() {
+		defer coordinator.running.Done()
+		time.Sleep(50 * time.Millisecond)
+		sent = true
+		mockModule.AssertNotCalled(t, "Stop")
+	}()
+
+	coordinator.Stop()
+	assert.True(t, sent, "Expected the notification to be sent before Stop returned")
+	mockModule.AssertCalled(t, "Stop")
+}
+
+func TestCoordinator_Stop_DrainTimeout(t *testing.T) {
+	coordinator := fixtureCoordinator()
+	viper.Set("general.shutdown-timeout", 0)
+	coordinator.Configure()
+	mockModule := &helpers.MockModule{}
+	mockModule.On("Stop").Return(nil)
+	coordinator.modules["test"] = mockModule
+
+	// A notification that does not finish does not hold up the shutdown
+	release := make(chan struct{})
+	defer close(release)
+	coordinator.running.Add(1)
+	go func() {
+		defer coordinator.running.Done()
+		<-release
+	}()
+
+	coordinator.Stop()
+	mockModule.AssertCalled(t, "Stop")
+}
+
+func TestCoordinator_Configure_BadShutdownTimeout(t *testing.T) {
+	coordinator := fixtureCoordinator()
+	viper.Set("general.shutdown-timeout", -1)
+	assert.Panics(t, func() { coordinator.Configure() }, "The code did not panic")
+}
+
 // This tests the full set of calls to send and process storage requests
 func TestCoordinator_sendClusterRequest(t *testing.T) {
 	coordinator := fixtureCoordinator()
@@ -593,6 +672,383 @@ func TestCoordinator_checkAndSendResponseToModules(t *testing.T) {
 	}
 }
 
+func TestCoordinator_checkAndSendResponseToModules_GroupRouting(t *testing.T) {
+	coordinator := fixtureCoordinator()
+	coordinator.modules = make(map[string]protocol.Module)
+	coordinator.clusters = make(map[string]*clusterGroups)
+	coordinator.clusterLock = &sync.RWMutex{}
+	coordinator.clusters["testcluster"] = &clusterGroups{
+		Lock:   &sync.RWMutex{},
+		Groups: make(map[string]*consumerGroup),
+	}
+
+	// Record which modules would have been notified
+	notified := make(map[string]bool)
+	coordinator.notifyModuleFunc = func(module Module, status *protocol.ConsumerGroupStatus, startTime time.Time, eventID string) {
+		defer coordinator.running.Done()
+		notified[module.GetName()] = true
+	}
+
+	// One module for the team's groups, and one for everything else
+	teamModule := &helpers.MockModule{}
+	teamModule.On("GetName").Return("team")
+	teamModule.On("GetGroupAllowlist").Return(regexp.MustCompile("^team-"))
+	teamModule.On("GetGroupDenylist").Return((*regexp.Regexp)(nil))
+	teamModule.On("AcceptConsumerGroup", mock.Anything).Return(true)
+	coordinator.modules["team"] = teamModule
+
+	restModule := &helpers.MockModule{}
+	restModule.On("GetName").Return("rest")
+	restModule.On("GetGroupAllowlist").Return((*regexp.Regexp)(nil))
+	restModule.On("GetGroupDenylist").Return(regexp.MustCompile("^team-"))
+	restModule.On("AcceptConsumerGroup", mock.Anything).Return(true)
+	coordinator.modules["rest"] = restModule
+
+	for group, expected := range map[string]string{"team-orders": "team", "othergroup": "rest"} {
+		coordinator.clusters["testcluster"].Groups[group] = &consumerGroup{
+			LastNotify: make(map[string]time.Time),
+		}
+		notified = make(map[string]bool)
+
+		coordinator.running.Add(1)
+		coordinator.checkAndSendResponseToModules(&protocol.ConsumerGroupStatus{
+			Cluster: "testcluster",
+			Group:   group,
+			Status:  protocol.StatusWarning,
+		})
+		assert.Equalf(t, map[string]bool{expected: true}, notified, "Expected group %v to only be sent to module %v", group, expected)
+	}
+}
+
+func TestCoordinator_notifyModule_Cooldown(t *testing.T) {
+	coordinator := fixtureCoordinator()
+	coordinator.clusters = make(map[string]*clusterGroups)
+	coordinator.clusters["testcluster"] = &clusterGroups{
+		Lock:   &sync.RWMutex{},
+		Groups: make(map[string]*consumerGroup),
+	}
+	group := &consumerGroup{
+		LastNotify: make(map[string]time.Time),
+	}
+	coordinator.clusters["testcluster"].Groups["testgroup"] = group
+
+	viper.Reset()
+	viper.Set("notifier.test.threshold", 2)
+	viper.Set("notifier.test.send-close", true)
+	viper.Set("notifier.test.send-interval", 0)
+	viper.Set("notifier.test.cooldown", 600)
+
+	mockStartTime, _ := time.Parse(time.RFC3339, "2012-11-01T22:08:41+00:00")
+	mockModule := &helpers.MockModule{}
+	mockModule.On("GetName").Return("test")
+
+	// The group flaps between WARN and OK, then gets worse. TotalPartitions just tells the statuses apart
+	steps := []struct {
+		status     protocol.StatusConstant
+		expectSend bool
+	}{
+		{protocol.StatusWarning, true},
+		{protocol.StatusOK, true},
+		{protocol.StatusWarning, false},
+		{protocol.StatusOK, false},
+		{protocol.StatusError, true},
+	}
+	for i, step := range steps {
+		response := &protocol.ConsumerGroupStatus{
+			Cluster:         "testcluster",
+			Group:           "testgroup",
+			Status:          step.status,
+			TotalPartitions: i,
+		}
+		if step.expectSend {
+			mockModule.On("Notify", response, "testid", mockStartTime, step.status == protocol.StatusOK).Return().Once()
+		}
+
+		coordinator.running.Add(1)
+		coordinator.notifyModule(mockModule, response, mockStartTime, "testid")
+	}
+
+	mockModule.AssertExpectations(t)
+	mockModule.AssertNumberOfCalls(t, "Notify", 3)
+}
+
+func TestCoordinator_Configure_TopicAllowlist(t *testing.T) {
+	coordinator := fixtureCoordinator()
+	viper.Set("notifier.test.topic-allowlist", "^important")
+	assert.Panics(t, func() { coordinator.Configure() }, "Expected a panic without an evaluator with topic-status")
+
+	coordinator = fixtureCoordinator()
+	viper.Set("notifier.test.topic-allowlist", "^important")
+	viper.Set("evaluator.test.topic-status", true)
+	coordinator.Configure()
+	assert.Contains(t, coordinator.topicAllowlists, "test", "Expected a topic allowlist to be configured for the module")
+
+	coordinator = fixtureCoordinator()
+	viper.Set("notifier.test.topic-allowlist", "[")
+	viper.Set("evaluator.test.topic-status", true)
+	assert.Panics(t, func() { coordinator.Configure() }, "Expected a panic for a bad regular expression")
+}
+
+func TestCoordinator_scopeStatusToTopics(t *testing.T) {
+	maxlag := &protocol.PartitionStatus{Topic: "important2", Partition: 1, Status: protocol.StatusWarning, CurrentLag: 300}
+	status := &protocol.ConsumerGroupStatus{
+		Cluster: "testcluster",
+		Group:   "testgroup",
+		Status:  protocol.StatusError,
+		Partitions: []*protocol.PartitionStatus{
+			maxlag,
+			{Topic: "other", Partition: 0, Status: protocol.StatusStop, CurrentLag: 1000},
+		},
+		Topics: map[string]*protocol.TopicStatus{
+			"important1": {Status: protocol.StatusOK, Complete: 1.0, TotalPartitions: 2, TotalLag: 20},
+			"important2": {Status: protocol.StatusWarning, Complete: 0.5, TotalPartitions: 2, TotalLag: 400, Maxlag: maxlag},
+			"other":      {Status: protocol.StatusError, Complete: 1.0, TotalPartitions: 1, TotalLag: 1000},
+		},
+	}
+
+	scoped := scopeStatusToTopics(status, regexp.MustCompile("^important"))
+	assert.Equalf(t, protocol.StatusWarning, scoped.Status, "Expected status to be WARN, not %v", scoped.Status.String())
+	assert.Equalf(t, 4, scoped.TotalPartitions, "Expected 4 partitions, not %v", scoped.TotalPartitions)
+	assert.Equalf(t, uint64(420), scoped.TotalLag, "Expected total lag to be 420, not %v", scoped.TotalLag)
+	assert.Equalf(t, float32(0.75), scoped.Complete, "Expected complete to be 0.75, not %v", scoped.Complete)
+	assert.Equal(t, maxlag, scoped.Maxlag, "Expected maxlag to be from important2")
+	assert.Equal(t, []*protocol.PartitionStatus{maxlag}, scoped.Partitions, "Expected only the partitions of matching topics")
+	assert.Lenf(t, scoped.Topics, 2, "Expected 2 topics, not %v", len(scoped.Topics))
+	assert.Equalf(t, protocol.StatusError, status.Status, "Expected the original status to be unchanged, not %v", status.Status.String())
+}
+
+func TestCoordinator_notifyModule_TopicAllowlist(t *testing.T) {
+	coordinator := fixtureCoordinator()
+	coordinator.clusters = make(map[string]*clusterGroups)
+	coordinator.clusters["testcluster"] = &clusterGroups{
+		Lock:   &sync.RWMutex{},
+		Groups: make(map[string]*consumerGroup),
+	}
+	coordinator.clusters["testcluster"].Groups["testgroup"] = &consumerGroup{
+		LastNotify: make(map[string]time.Time),
+	}
+	coordinator.topicAllowlists = map[string]*regexp.Regexp{"test": regexp.MustCompile("^important$")}
+
+	viper.Reset()
+	viper.Set("notifier.test.threshold", 2)
+	viper.Set("notifier.test.send-close", true)
+	viper.Set("notifier.test.send-interval", 0)
+
+	mockStartTime, _ := time.Parse(time.RFC3339, "2012-11-01T22:08:41+00:00")
+	mockModule := &helpers.MockModule{}
+	mockModule.On("GetName").Return("test")
+
+	// The group stays in WARN because of the other topic, and only the important topic is notified for
+	steps := []struct {
+		important  protocol.StatusConstant
+		expectSend bool
+	}{
+		{protocol.StatusOK, false},
+		{protocol.StatusWarning, true},
+		{protocol.StatusOK, true},
+		{protocol.StatusOK, false},
+	}
+	sent := 0
+	for _, step := range steps {
+		response := &protocol.ConsumerGroupStatus{
+			Cluster: "testcluster",
+			Group:   "testgroup",
+			Status:  protocol.StatusWarning,
+			Topics: map[string]*protocol.TopicStatus{
+				"important": {Status: step.important, TotalPartitions: 1},
+				"other":     {Status: protocol.StatusWarning, TotalPartitions: 1},
+			},
+		}
+		if step.expectSend {
+			stepStatus := step.important
+			mockModule.On("Notify", mock.MatchedBy(func(status *protocol.ConsumerGroupStatus) bool {
+				return (status.Status == stepStatus) && (len(status.Topics) == 1)
+			}), "testid", mockStartTime, stepStatus == protocol.StatusOK).Return().Once()
+			sent++
+		}
+
+		coordinator.running.Add(1)
+		coordinator.notifyModule(mockModule, response, mockStartTime, "testid")
+		mockModule.AssertNumberOfCalls(t, "Notify", sent)
+	}
+
+	mockModule.AssertExpectations(t)
+}
+
+func TestCoordinator_notifyModule_Paused(t *testing.T) {
+	coordinator := fixtureCoordinator()
+	coordinator.App.NotifierPauses = &protocol.NotifierPauses{}
+	coordinator.clusters = make(map[string]*clusterGroups)
+	coordinator.clusters["testcluster"] = &clusterGroups{
+		Lock:   &sync.RWMutex{},
+		Groups: make(map[string]*consumerGroup),
+	}
+	coordinator.clusters["testcluster"].Groups["testgroup"] = &consumerGroup{
+		LastNotify: map[string]time.Time{"test": time.Now().Add(-time.Hour)},
+	}
+
+	viper.Reset()
+	viper.Set("notifier.test.threshold", 1)
+	viper.Set("notifier.test.send-close", true)
+	viper.Set("notifier.test.send-interval", 0)
+
+	mockStartTime, _ := time.Parse(time.RFC3339, "2012-11-01T22:08:41+00:00")
+	mockModule := &helpers.MockModule{}
+	mockModule.On("GetName").Return("test")
+	response := &protocol.ConsumerGroupStatus{
+		Cluster: "testcluster",
+		Group:   "testgroup",
+		Status:  protocol.StatusWarning,
+	}
+
+	// Nothing is sent while paused, and the incident closing during the pause is forgotten
+	coordinator.App.NotifierPauses.Pause("test", time.Now().Add(time.Minute))
+	coordinator.running.Add(1)
+	coordinator.notifyModule(mockModule, response, mockStartTime, "testid")
+	response.Status = protocol.StatusOK
+	coordinator.running.Add(1)
+	coordinator.notifyModule(mockModule, response, mockStartTime, "testid")
+	mockModule.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	assert.True(t, coordinator.clusters["testcluster"].Groups["testgroup"].LastNotify["test"].IsZero(), "Expected LastNotify to be cleared by the close")
+
+	// Once the pause has expired, notifications are sent again
+	coordinator.App.NotifierPauses.Pause("test", time.Now().Add(-time.Second))
+	response.Status = protocol.StatusWarning
+	mockModule.On("Notify", response, "testid", mockStartTime, false).Return().Once()
+	coordinator.running.Add(1)
+	coordinator.notifyModule(mockModule, response, mockStartTime, "testid")
+	mockModule.AssertExpectations(t)
+}
+
+func TestCoordinator_Configure_Throttle(t *testing.T) {
+	coordinator := fixtureCoordinator()
+	viper.Set("notifier.test.max-notifications-per-interval", 10)
+	coordinator.Configure()
+
+	assert.Contains(t, coordinator.throttles, "test", "Expected a throttle to be configured for the module")
+	assert.Equalf(t, 5*time.Second, coordinator.throttles["test"].interval, "Expected throttle interval to default to the module interval, not %v", coordinator.throttles["test"].interval)
+	assert.NotNil(t, coordinator.throttleFlush, "Expected the throttle summary ticker to be set up")
+}
+
+func TestCoordinator_Configure_BadThrottle(t *testing.T) {
+	coordinator := fixtureCoordinator()
+	viper.Set("notifier.test.max-notifications-per-interval", -1)
+	assert.Panics(t, func() { coordinator.Configure() }, "The code did not panic")
+
+	coordinator = fixtureCoordinator()
+	viper.Set("notifier.test.max-notifications-per-interval", 10)
+	viper.Set("notifier.test.throttle-interval", 0)
+	assert.Panics(t, func() { coordinator.Configure() }, "The code did not panic")
+}
+
+func TestCoordinator_notifyModule_Throttle(t *testing.T) {
+	coordinator := fixtureCoordinator()
+	coordinator.clusters = make(map[string]*clusterGroups)
+	coordinator.clusters["testcluster"] = &clusterGroups{
+		Lock:   &sync.RWMutex{},
+		Groups: make(map[string]*consumerGroup),
+	}
+	coordinator.throttles = map[string]*notifyThrottle{"test": newNotifyThrottle(2, time.Hour)}
+
+	viper.Reset()
+	viper.Set("notifier.test.threshold", 2)
+	viper.Set("notifier.test.send-close", true)
+	viper.Set("notifier.test.send-interval", 0)
+
+	mockStartTime, _ := time.Parse(time.RFC3339, "2012-11-01T22:08:41+00:00")
+	mockModule := &helpers.MockModule{}
+	mockModule.On("GetName").Return("test")
+	coordinator.modules = map[string]protocol.Module{"test": mockModule}
+
+	// Only the first two groups are notified individually
+	for i := 0; i < 5; i++ {
+		group := "testgroup" + strconv.Itoa(i)
+		coordinator.clusters["testcluster"].Groups[group] = &consumerGroup{
+			LastNotify: make(map[string]time.Time),
+		}
+		response := &protocol.ConsumerGroupStatus{
+			Cluster: "testcluster",
+			Group:   group,
+			Status:  protocol.StatusError,
+		}
+		if i < 2 {
+			mockModule.On("Notify", response, "testid", mockStartTime, false).Return().Once()
+		}
+
+		coordinator.running.Add(1)
+		coordinator.notifyModule(mockModule, response, mockStartTime, "testid")
+	}
+	mockModule.AssertNumberOfCalls(t, "Notify", 2)
+
+	// The close for a throttled group is not sent
+	coordinator.running.Add(1)
+	coordinator.notifyModule(mockModule, &protocol.ConsumerGroupStatus{
+		Cluster: "testcluster",
+		Group:   "testgroup4",
+		Status:  protocol.StatusOK,
+	}, mockStartTime, "testid")
+	mockModule.AssertNumberOfCalls(t, "Notify", 2)
+
+	// The rest are sent as one summary, with an event ID that is the same for every summary of the cluster and status
+	eventID := throttleEventID("test", "testcluster", protocol.StatusError)
+	mockModule.On("GetLogger").Return(zap.NewNop())
+	mockModule.On("GetGroupAllowlist").Return((*regexp.Regexp)(nil))
+	mockModule.On("GetGroupDenylist").Return(regexp.MustCompile("^testgroup2$"))
+	mockModule.On("AcceptConsumerGroup", mock.Anything).Return(true)
+	mockModule.On("Notify", mock.MatchedBy(func(status *protocol.ConsumerGroupStatus) bool {
+		return (status.Cluster == "testcluster") && (status.Group == "2 groups in ERR on cluster testcluster") && (status.Status == protocol.StatusError)
+	}), eventID, mock.Anything, false).Return().Once()
+	coordinator.sendThrottleSummaries()
+	mockModule.AssertNumberOfCalls(t, "Notify", 3)
+
+	// With nothing throttled since, the summary is closed
+	mockModule.On("Notify", mock.MatchedBy(func(status *protocol.ConsumerGroupStatus) bool {
+		return (status.Cluster == "testcluster") && (status.Status == protocol.StatusOK)
+	}), eventID, mock.Anything, true).Return().Once()
+	coordinator.sendThrottleSummaries()
+
+	mockModule.AssertExpectations(t)
+	mockModule.AssertNumberOfCalls(t, "Notify", 4)
+	assert.NotEqual(t, eventID, throttleEventID("test", "testcluster", protocol.StatusWarning), "Expected a different event ID for another status")
+}
+
+func TestCoordinator_sendThrottleSummaries_NotAccepted(t *testing.T) {
+	coordinator := fixtureCoordinator()
+	throttle := newNotifyThrottle(1, time.Hour)
+	throttle.allow(&protocol.ConsumerGroupStatus{Cluster: "testcluster", Group: "testgroup1", Status: protocol.StatusError}, time.Now())
+	throttle.allow(&protocol.ConsumerGroupStatus{Cluster: "testcluster", Group: "testgroup2", Status: protocol.StatusError}, time.Now())
+	coordinator.throttles = map[string]*notifyThrottle{"test": throttle}
+
+	mockModule := &helpers.MockModule{}
+	mockModule.On("GetGroupAllowlist").Return((*regexp.Regexp)(nil))
+	mockModule.On("GetGroupDenylist").Return((*regexp.Regexp)(nil))
+	mockModule.On("AcceptConsumerGroup", mock.Anything).Return(false)
+	coordinator.modules = map[string]protocol.Module{"test": mockModule}
+
+	coordinator.sendThrottleSummaries()
+	mockModule.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCoordinator_Configure_ThrottleSummary(t *testing.T) {
+	coordinator := fixtureCoordinator()
+	viper.Set("notifier.test.max-notifications-per-interval", 10)
+	viper.Set("notifier.pd.class-name", "pagerduty")
+	viper.Set("notifier.pd.routing-key", "testkey")
+	viper.Set("notifier.pd.max-notifications-per-interval", 10)
+	coordinator.Configure()
+
+	assert.True(t, coordinator.throttles["test"].summarize, "Expected summaries to be sent by default")
+	assert.False(t, coordinator.throttles["pd"].summarize, "Expected no summaries by default for pagerduty")
+
+	coordinator = fixtureCoordinator()
+	viper.Set("notifier.pd.class-name", "pagerduty")
+	viper.Set("notifier.pd.routing-key", "testkey")
+	viper.Set("notifier.pd.max-notifications-per-interval", 10)
+	viper.Set("notifier.pd.throttle-summary", true)
+	coordinator.Configure()
+	assert.True(t, coordinator.throttles["pd"].summarize, "Expected summaries to be sent when enabled")
+}
+
 func TestCoordinator_ExecuteTemplate(t *testing.T) {
 	tmpl, _ := template.New("test").Parse("{{.ID}} {{.Cluster}} {{.Group}} {{.Result.Status}}")
 
@@ -608,3 +1064,51 @@ func TestCoordinator_ExecuteTemplate(t *testing.T) {
 	assert.Nil(t, err, "Expected no error to be returned")
 	assert.Equalf(t, "testidstring testcluster testgroup OK", bytesToSend.String(), "Unexpected, got: %v", bytesToSend.String())
 }
+
+func fixturePartitionStatus(startLag, endLag uint64, elapsed int64, currentLag uint64) *protocol.PartitionStatus {
+	return &protocol.PartitionStatus{
+		Topic:      "testtopic",
+		Partition:  0,
+		Start:      &protocol.ConsumerOffset{Offset: 1000, Timestamp: 100000, Lag: &protocol.Lag{Value: startLag}},
+		End:        &protocol.ConsumerOffset{Offset: 2000, Timestamp: 100000 + elapsed, Lag: &protocol.Lag{Value: endLag}},
+		CurrentLag: currentLag,
+	}
+}
+
+var etaToCatchUpTests = []struct {
+	partition *protocol.PartitionStatus
+	eta       string
+}{
+	/*0*/ {nil, "unknown"},
+	/*1*/ {&protocol.PartitionStatus{CurrentLag: 100}, "unknown"},
+	/*2*/ {fixturePartitionStatus(1000, 500, 0, 500), "unknown"},
+	/*3*/ {fixturePartitionStatus(500, 500, 60000, 500), "never"},
+	/*4*/ {fixturePartitionStatus(500, 1000, 60000, 1000), "never"},
+	/*5*/ {fixturePartitionStatus(1000, 500, 60000, 0), "0s"},
+	/*6*/ {fixturePartitionStatus(1000, 500, 10000, 500), "10s"},
+	/*7*/ {fixturePartitionStatus(1000, 500, 60000, 1250), "2m30s"},
+	/*8*/ {fixturePartitionStatus(1000, 900, 60000, 9000), "1h30m"},
+	/*9*/ {fixturePartitionStatus(1000, 999, 60000, 3000), "2d2h"},
+}
+
+func TestCoordinator_etaToCatchUp(t *testing.T) {
+	for i, testSet := range etaToCatchUpTests {
+		result := etaToCatchUp(testSet.partition)
+		assert.Equalf(t, testSet.eta, result, "TEST %v: Expected ETA to be %v, not %v", i, testSet.eta, result)
+	}
+}
+
+func TestCoordinator_ExecuteTemplate_etaToCatchUp(t *testing.T) {
+	tmpl, err := template.New("test").Funcs(helperFunctionMap).Parse("{{range .Result.Partitions}}{{.Topic}}:{{etaToCatchUp .}}{{end}}")
+	assert.Nil(t, err, "Expected no error parsing the template")
+
+	status := &protocol.ConsumerGroupStatus{
+		Status:     protocol.StatusWarning,
+		Cluster:    "testcluster",
+		Group:      "testgroup",
+		Partitions: []*protocol.PartitionStatus{fixturePartitionStatus(1000, 500, 60000, 1250)},
+	}
+	bytesToSend, err := executeTemplate(tmpl, map[string]string{}, status, "testidstring", time.Now())
+	assert.Nil(t, err, "Expected no error to be returned")
+	assert.Equalf(t, "testtopic:2m30s", bytesToSend.String(), "Unexpected, got: %v", bytesToSend.String())
+}
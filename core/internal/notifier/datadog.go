@@ -0,0 +1,382 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/linkedin/Burrow/core/protocol"
+)
+
+// DatadogNotifier is a module which sends an event to Datadog for each notification of consumer group status, and
+// submits the lag of the group as gauges. The events and metrics are either posted to the Datadog API, or sent to the
+// DogStatsD server of a local Datadog Agent. The events for a group share an aggregation key, so that Datadog groups
+// them together. The tags of the events and metrics are templates, which are executed with the same data as the
+// templates of the other notifiers. The open and close templates are not used by this notifier.
+type DatadogNotifier struct {
+	// App is a pointer to the application context. This stores the channel to the storage subsystem
+	App *protocol.ApplicationContext
+
+	// Log is a logger that has been configured for this module to use. Normally, this means it has been set up with
+	// fields that are appropriate to identify this coordinator
+	Log *zap.Logger
+
+	name           string
+	groupAllowlist *regexp.Regexp
+	groupDenylist  *regexp.Regexp
+	extras         map[string]string
+	templateOpen   *template.Template
+	templateClose  *template.Template
+
+	transport    string
+	metricPrefix string
+	tags         []*template.Template
+
+	// For the api transport
+	url        string
+	apiKey     string
+	httpClient *http.Client
+	retry      *httpRetry
+
+	// For the dogstatsd transport
+	address string
+	conn    net.Conn
+}
+
+// datadogEvent is the JSON object that is posted to the Events API
+type datadogEvent struct {
+	Title          string   `json:"title"`
+	Text           string   `json:"text"`
+	AlertType      string   `json:"alert_type"`
+	AggregationKey string   `json:"aggregation_key"`
+	SourceTypeName string   `json:"source_type_name"`
+	DateHappened   int64    `json:"date_happened"`
+	Tags           []string `json:"tags"`
+}
+
+// datadogSeries is the JSON object that is posted to the metrics API, with one entry for each gauge
+type datadogSeries struct {
+	Series []*datadogMetric `json:"series"`
+}
+
+type datadogMetric struct {
+	Metric string       `json:"metric"`
+	Type   string       `json:"type"`
+	Points [][2]float64 `json:"points"`
+	Tags   []string     `json:"tags"`
+}
+
+// Configure validates the configuration of the datadog notifier. The transport is either "api" (the default), which
+// posts to the Datadog API, or "dogstatsd", which sends to the DogStatsD server at address (localhost:8125 by default).
+// The api transport requires an api-key, and posts to the API for the site (datadoghq.com by default), unless a url is
+// set. As with the http notifier, a timeout and keepalive for the HTTP client can be configured, as well as an extra
+// CA, noverify, and retries. The metric names start with metric-prefix (burrow.consumer by default), and the tags
+// default to the cluster and the group. If the transport is unknown, the api-key is missing, or a tag template cannot
+// be parsed, this func will panic with an explanatory message. Unlike most notifiers, send-close defaults to true,
+// so that the recovery of a group is sent as well.
+func (module *DatadogNotifier) Configure(name, configRoot string) {
+	module.name = name
+
+	// Set defaults for module-specific configs if needed
+	viper.SetDefault(configRoot+".transport", "api")
+	viper.SetDefault(configRoot+".metric-prefix", "burrow.consumer")
+	viper.SetDefault(configRoot+".tags", []string{"cluster:{{.Cluster}}", "group:{{.Group}}"})
+	viper.SetDefault(configRoot+".send-close", true)
+	module.transport = viper.GetString(configRoot + ".transport")
+	module.metricPrefix = strings.TrimSuffix(viper.GetString(configRoot+".metric-prefix"), ".")
+
+	module.tags = make([]*template.Template, 0)
+	for _, tag := range viper.GetStringSlice(configRoot + ".tags") {
+		tmpl, err := template.New("tag").Funcs(helperFunctionMap).Parse(tag)
+		if err != nil {
+			module.Log.Panic("failed to compile tag", zap.String("tag", tag), zap.Error(err))
+			panic(err)
+		}
+		module.tags = append(module.tags, tmpl)
+	}
+
+	switch module.transport {
+	case "api":
+		module.apiKey = viper.GetString(configRoot + ".api-key")
+		if module.apiKey == "" {
+			module.Log.Panic("no api-key specified")
+			panic(errors.New("configuration error"))
+		}
+
+		viper.SetDefault(configRoot+".site", "datadoghq.com")
+		viper.SetDefault(configRoot+".url", "https://api."+viper.GetString(configRoot+".site"))
+		viper.SetDefault(configRoot+".timeout", 5)
+		viper.SetDefault(configRoot+".keepalive", 300)
+		module.url = strings.TrimSuffix(viper.GetString(configRoot+".url"), "/")
+
+		module.retry = newHTTPRetry(name, configRoot)
+		module.httpClient = &http.Client{
+			Timeout: viper.GetDuration(configRoot+".timeout") * time.Second,
+			Transport: &http.Transport{
+				Dial: (&net.Dialer{
+					KeepAlive: viper.GetDuration(configRoot+".keepalive") * time.Second,
+				}).Dial,
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: buildHTTPTLSConfig(viper.GetString(configRoot+".extra-ca"), viper.GetBool(configRoot+".noverify")),
+			},
+		}
+	case "dogstatsd":
+		viper.SetDefault(configRoot+".address", "localhost:8125")
+		module.address = viper.GetString(configRoot + ".address")
+		if _, _, err := net.SplitHostPort(module.address); err != nil {
+			module.Log.Panic("invalid address (must be host:port)", zap.String("address", module.address))
+			panic(errors.New("configuration error"))
+		}
+	default:
+		module.Log.Panic("unknown transport (must be api or dogstatsd)", zap.String("transport", module.transport))
+		panic(errors.New("configuration error"))
+	}
+}
+
+// Start opens the socket to the DogStatsD server, if that is the transport. As this is UDP, no data is sent, and an
+// error is only returned if the address cannot be resolved.
+func (module *DatadogNotifier) Start() error {
+	if module.transport != "dogstatsd" {
+		return nil
+	}
+	conn, err := net.Dial("udp", module.address)
+	if err != nil {
+		module.Log.Error("failed to open socket", zap.String("address", module.address), zap.Error(err))
+		return err
+	}
+	module.conn = conn
+	return nil
+}
+
+// Stop closes the socket to the DogStatsD server, if there is one. It always returns no error
+func (module *DatadogNotifier) Stop() error {
+	if module.conn != nil {
+		module.conn.Close()
+	}
+	return nil
+}
+
+// GetName returns the configured name of this module
+func (module *DatadogNotifier) GetName() string {
+	return module.name
+}
+
+// GetGroupAllowlist returns the compiled group allowlist (or nil, if there is not one)
+func (module *DatadogNotifier) GetGroupAllowlist() *regexp.Regexp {
+	return module.groupAllowlist
+}
+
+// GetGroupDenylist returns the compiled group denylist (or nil, if there is not one)
+func (module *DatadogNotifier) GetGroupDenylist() *regexp.Regexp {
+	return module.groupDenylist
+}
+
+// GetLogger returns the configured zap.Logger for this notifier
+func (module *DatadogNotifier) GetLogger() *zap.Logger {
+	return module.Log
+}
+
+// AcceptConsumerGroup has no additional function for the datadog notifier, and so always returns true
+func (module *DatadogNotifier) AcceptConsumerGroup(status *protocol.ConsumerGroupStatus) bool {
+	return true
+}
+
+// usesTemplates returns false, as the events are built from the status, with only the tags as templates
+func (module *DatadogNotifier) usesTemplates() bool {
+	return false
+}
+
+// datadogAlertType returns the Datadog alert type for the group status. A group that has recovered is a success
+func datadogAlertType(status protocol.StatusConstant, stateGood bool) string {
+	if stateGood {
+		return "success"
+	}
+	switch status {
+	case protocol.StatusWarning:
+		return "warning"
+	case protocol.StatusError, protocol.StatusStop, protocol.StatusStall, protocol.StatusRewind:
+		return "error"
+	default:
+		return "info"
+	}
+}
+
+// buildTags executes the tag templates for the status. A tag that fails to execute, or is empty, is skipped
+func (module *DatadogNotifier) buildTags(status *protocol.ConsumerGroupStatus, eventID string, startTime time.Time, logger *zap.Logger) []string {
+	tags := make([]string, 0, len(module.tags))
+	for _, tmpl := range module.tags {
+		tag, err := executeTemplate(tmpl, module.extras, status, eventID, startTime)
+		if err != nil {
+			logger.Error("failed to assemble tag", zap.Error(err))
+			continue
+		}
+		if tag.Len() > 0 {
+			tags = append(tags, tag.String())
+		}
+	}
+	return tags
+}
+
+// buildDatadogEvent assembles the event for the status
+func buildDatadogEvent(status *protocol.ConsumerGroupStatus, eventID string, startTime time.Time, stateGood bool, tags []string) *datadogEvent {
+	title := "Consumer group " + status.Group + " on cluster " + status.Cluster + " is " + status.Status.String()
+	if stateGood {
+		title = "Consumer group " + status.Group + " on cluster " + status.Cluster + " has recovered"
+	}
+	text := "Total lag is " + strconv.FormatUint(status.TotalLag, 10) + " across " + strconv.Itoa(status.TotalPartitions) +
+		" partitions."
+	if status.Maxlag != nil {
+		text += " The worst partition is " + status.Maxlag.Topic + ":" + strconv.FormatInt(int64(status.Maxlag.Partition), 10) +
+			" (" + status.Maxlag.Status.String() + ", lag " + strconv.FormatUint(status.Maxlag.CurrentLag, 10) + ")."
+	}
+	text += "\nIncident " + eventID + " started at " + startTime.UTC().Format(time.RFC3339) + "."
+
+	return &datadogEvent{
+		Title:          title,
+		Text:           text,
+		AlertType:      datadogAlertType(status.Status, stateGood),
+		AggregationKey: status.Cluster + "/" + status.Group,
+		SourceTypeName: "burrow",
+		DateHappened:   time.Now().Unix(),
+		Tags:           tags,
+	}
+}
+
+// buildDatadogMetrics returns the gauges for the status, by metric name. The max lag is only set if there is a
+// partition with lag, and the status is the numeric value of the status constant (0 is NOTFOUND, 1 is OK).
+func (module *DatadogNotifier) buildDatadogMetrics(status *protocol.ConsumerGroupStatus) map[string]float64 {
+	metrics := map[string]float64{
+		module.metricPrefix + ".total_lag":       float64(status.TotalLag),
+		module.metricPrefix + ".status":          float64(status.Status),
+		module.metricPrefix + ".partition_count": float64(status.TotalPartitions),
+	}
+	if status.Maxlag != nil {
+		metrics[module.metricPrefix+".max_lag"] = float64(status.Maxlag.CurrentLag)
+	}
+	return metrics
+}
+
+// Notify sends an event for the group, with an alert type for the status (or success, if stateGood is true), and the
+// gauges for the lag of the group. Each is sent with the configured tags.
+func (module *DatadogNotifier) Notify(status *protocol.ConsumerGroupStatus, eventID string, startTime time.Time, stateGood bool) {
+	logger := module.Log.With(
+		zap.String("cluster", status.Cluster),
+		zap.String("group", status.Group),
+		zap.String("id", eventID),
+		zap.String("status", status.Status.String()),
+	)
+
+	tags := module.buildTags(status, eventID, startTime, logger)
+	event := buildDatadogEvent(status, eventID, startTime, stateGood, tags)
+	metrics := module.buildDatadogMetrics(status)
+
+	if module.transport == "dogstatsd" {
+		module.sendDogStatsD(event, metrics, tags, logger)
+		return
+	}
+
+	module.postAPI("/api/v1/events", event, logger)
+
+	series := &datadogSeries{Series: make([]*datadogMetric, 0, len(metrics))}
+	for name, value := range metrics {
+		series.Series = append(series.Series, &datadogMetric{
+			Metric: name,
+			Type:   "gauge",
+			Points: [][2]float64{{float64(event.DateHappened), value}},
+			Tags:   tags,
+		})
+	}
+	module.postAPI("/api/v1/series", series, logger)
+}
+
+// postAPI posts the message to the path of the Datadog API, logging any failure
+func (module *DatadogNotifier) postAPI(path string, message interface{}, logger *zap.Logger) {
+	logger = logger.With(zap.String("path", path))
+
+	body, err := json.Marshal(message)
+	if err != nil {
+		logger.Error("failed to encode message", zap.Error(err))
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, module.url+path, bytes.NewReader(body))
+	if err != nil {
+		logger.Error("failed to create request", zap.Error(err))
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("DD-API-KEY", module.apiKey)
+
+	statusCode, err := module.retry.send(module.httpClient, req, logger)
+	if err != nil {
+		logger.Error("failed to send", zap.Error(err))
+		return
+	}
+
+	// The API responds with 202 when the event or metrics are accepted
+	if (statusCode >= 200) && (statusCode <= 299) {
+		logger.Debug("sent")
+	} else {
+		logger.Error("failed to send", zap.Int("response", statusCode))
+	}
+}
+
+// sendDogStatsD sends the event and each of the gauges to the DogStatsD server as separate datagrams
+func (module *DatadogNotifier) sendDogStatsD(event *datadogEvent, metrics map[string]float64, tags []string, logger *zap.Logger) {
+	if module.conn == nil {
+		logger.Error("failed to send", zap.Error(errors.New("the notifier has not been started")))
+		return
+	}
+
+	datagrams := []string{formatDogStatsDEvent(event)}
+	for name, value := range metrics {
+		datagrams = append(datagrams, formatDogStatsDGauge(name, value, tags))
+	}
+	for _, datagram := range datagrams {
+		if _, err := module.conn.Write([]byte(datagram)); err != nil {
+			logger.Error("failed to send", zap.Error(err))
+			return
+		}
+	}
+	logger.Debug("sent")
+}
+
+// formatDogStatsDEvent formats the event as a DogStatsD datagram. Newlines in the text must be escaped
+func formatDogStatsDEvent(event *datadogEvent) string {
+	text := strings.ReplaceAll(event.Text, "\n", "\\n")
+	datagram := "_e{" + strconv.Itoa(len(event.Title)) + "," + strconv.Itoa(len(text)) + "}:" + event.Title + "|" + text +
+		"|d:" + strconv.FormatInt(event.DateHappened, 10) + "|k:" + event.AggregationKey + "|s:" + event.SourceTypeName +
+		"|t:" + event.AlertType
+	return datagram + formatDogStatsDTags(event.Tags)
+}
+
+// formatDogStatsDGauge formats a gauge as a DogStatsD datagram
+func formatDogStatsDGauge(name string, value float64, tags []string) string {
+	return name + ":" + strconv.FormatFloat(value, 'f', -1, 64) + "|g" + formatDogStatsDTags(tags)
+}
+
+func formatDogStatsDTags(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	return "|#" + strings.Join(tags, ",")
+}
@@ -0,0 +1,200 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package notifier
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/linkedin/Burrow/core/protocol"
+)
+
+func fixtureDatadogNotifier() *DatadogNotifier {
+	module := DatadogNotifier{
+		Log: zap.NewNop(),
+	}
+	module.App = &protocol.ApplicationContext{}
+
+	viper.Reset()
+	viper.Set("notifier.test.class-name", "datadog")
+	viper.Set("notifier.test.api-key", "testapikey")
+
+	return &module
+}
+
+func fixtureDatadogStatus() *protocol.ConsumerGroupStatus {
+	return &protocol.ConsumerGroupStatus{
+		Status:          protocol.StatusError,
+		Cluster:         "testcluster",
+		Group:           "testgroup",
+		TotalLag:        100,
+		TotalPartitions: 4,
+		Maxlag: &protocol.PartitionStatus{
+			Topic:      "testtopic",
+			Partition:  3,
+			Status:     protocol.StatusStall,
+			CurrentLag: 80,
+		},
+	}
+}
+
+func TestDatadogNotifier_ImplementsModule(t *testing.T) {
+	assert.Implements(t, (*protocol.Module)(nil), new(DatadogNotifier))
+	assert.Implements(t, (*Module)(nil), new(DatadogNotifier))
+	assert.Implements(t, (*templateModule)(nil), new(DatadogNotifier))
+	assert.False(t, new(DatadogNotifier).usesTemplates(), "Expected the templates not to be used")
+}
+
+func TestDatadogNotifier_Configure(t *testing.T) {
+	module := fixtureDatadogNotifier()
+
+	module.Configure("test", "notifier.test")
+	assert.NotNil(t, module.httpClient, "Expected httpClient to be set with a client object")
+	assert.Equal(t, "api", module.transport)
+	assert.Equal(t, "https://api.datadoghq.com", module.url)
+	assert.Equal(t, "burrow.consumer", module.metricPrefix)
+	assert.Len(t, module.tags, 2, "Expected the default tags for the cluster and group")
+	assert.True(t, viper.GetBool("notifier.test.send-close"), "Expected send-close to default to true")
+
+	module = fixtureDatadogNotifier()
+	viper.Set("notifier.test.site", "datadoghq.eu")
+	module.Configure("test", "notifier.test")
+	assert.Equal(t, "https://api.datadoghq.eu", module.url)
+}
+
+func TestDatadogNotifier_Configure_DogStatsD(t *testing.T) {
+	module := fixtureDatadogNotifier()
+	viper.Set("notifier.test.api-key", "")
+	viper.Set("notifier.test.transport", "dogstatsd")
+
+	module.Configure("test", "notifier.test")
+	assert.Equal(t, "localhost:8125", module.address)
+	assert.Nil(t, module.httpClient, "Expected no httpClient for dogstatsd")
+}
+
+func TestDatadogNotifier_Bad_Configuration(t *testing.T) {
+	module := fixtureDatadogNotifier()
+	viper.Set("notifier.test.api-key", "")
+	assert.Panics(t, func() { module.Configure("test", "notifier.test") }, "Datadog notifier needs a supplied api-key")
+
+	module = fixtureDatadogNotifier()
+	viper.Set("notifier.test.transport", "carrierpigeon")
+	assert.Panics(t, func() { module.Configure("test", "notifier.test") }, "Datadog notifier needs a known transport")
+
+	module = fixtureDatadogNotifier()
+	viper.Set("notifier.test.transport", "dogstatsd")
+	viper.Set("notifier.test.address", "nohost")
+	assert.Panics(t, func() { module.Configure("test", "notifier.test") }, "Datadog notifier needs a host:port address")
+
+	module = fixtureDatadogNotifier()
+	viper.Set("notifier.test.tags", []string{"cluster:{{.Cluster"})
+	assert.Panics(t, func() { module.Configure("test", "notifier.test") }, "Datadog notifier needs valid tag templates")
+}
+
+func TestDatadogNotifier_Notify(t *testing.T) {
+	var event datadogEvent
+	var series datadogSeries
+	requestHandler := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "testapikey", r.Header.Get("DD-API-KEY"))
+
+		switch r.URL.Path {
+		case "/api/v1/events":
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		case "/api/v1/series":
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&series))
+		default:
+			t.Errorf("Unexpected path %v", r.URL.Path)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(requestHandler))
+	defer ts.Close()
+
+	module := fixtureDatadogNotifier()
+	viper.Set("notifier.test.url", ts.URL+"/")
+	viper.Set("notifier.test.tags", []string{"cluster:{{.Cluster}}", "group:{{.Group}}", "team:{{index .Extras \"team\"}}"})
+	module.extras = map[string]string{"team": "data-platform"}
+	module.Configure("test", "notifier.test")
+
+	module.Notify(fixtureDatadogStatus(), "testidstring", time.Unix(1637, 0), false)
+
+	tags := []string{"cluster:testcluster", "group:testgroup", "team:data-platform"}
+	assert.Equal(t, "Consumer group testgroup on cluster testcluster is ERR", event.Title)
+	assert.Equal(t, "error", event.AlertType)
+	assert.Equal(t, "testcluster/testgroup", event.AggregationKey)
+	assert.Equal(t, tags, event.Tags)
+	assert.Contains(t, event.Text, "The worst partition is testtopic:3 (STALL, lag 80).")
+
+	values := make(map[string]float64)
+	for _, metric := range series.Series {
+		assert.Equal(t, "gauge", metric.Type)
+		assert.Equal(t, tags, metric.Tags)
+		assert.Len(t, metric.Points, 1)
+		values[metric.Metric] = metric.Points[0][1]
+	}
+	assert.Equal(t, map[string]float64{
+		"burrow.consumer.total_lag":       100,
+		"burrow.consumer.max_lag":         80,
+		"burrow.consumer.partition_count": 4,
+		"burrow.consumer.status":          float64(protocol.StatusError),
+	}, values)
+}
+
+func TestDatadogNotifier_Notify_DogStatsD(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err, "Expected UDP listener to start")
+	defer listener.Close()
+
+	module := fixtureDatadogNotifier()
+	viper.Set("notifier.test.transport", "dogstatsd")
+	viper.Set("notifier.test.address", listener.LocalAddr().String())
+	module.Configure("test", "notifier.test")
+	assert.NoError(t, module.Start(), "Expected Start to return no error")
+	defer module.Stop()
+
+	status := fixtureDatadogStatus()
+	status.Status = protocol.StatusOK
+	status.Maxlag = nil
+	module.Notify(status, "testidstring", time.Unix(1637, 0), true)
+
+	// The event is sent first, followed by a datagram for each gauge
+	datagrams := make([]string, 0)
+	buffer := make([]byte, 4096)
+	listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for i := 0; i < 4; i++ {
+		n, _, err := listener.ReadFrom(buffer)
+		assert.NoError(t, err, "Expected to read a datagram")
+		datagrams = append(datagrams, string(buffer[:n]))
+	}
+
+	assert.True(t, strings.HasPrefix(datagrams[0], "_e{61,93}:Consumer group testgroup on cluster testcluster has recovered|"), "Unexpected event datagram: %v", datagrams[0])
+	assert.Contains(t, datagrams[0], "|k:testcluster/testgroup|s:burrow|t:success|#cluster:testcluster,group:testgroup")
+	assert.NotContains(t, datagrams[0], "\n", "Expected newlines in the event to be escaped")
+
+	gauges := datagrams[1:]
+	sort.Strings(gauges)
+	assert.Equal(t, []string{
+		"burrow.consumer.partition_count:4|g|#cluster:testcluster,group:testgroup",
+		"burrow.consumer.status:1|g|#cluster:testcluster,group:testgroup",
+		"burrow.consumer.total_lag:100|g|#cluster:testcluster,group:testgroup",
+	}, gauges)
+}
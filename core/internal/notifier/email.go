@@ -12,9 +12,10 @@ package notifier
 import (
 	"crypto/tls"
 	"errors"
-	"fmt"
+	"net"
 	"net/smtp"
 	"regexp"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
@@ -65,7 +66,7 @@ func (module *EmailNotifier) Configure(name, configRoot string) {
 	host := viper.GetString(configRoot + ".server")
 	port := viper.GetInt(configRoot + ".port")
 
-	serverWithPort := fmt.Sprintf("%s:%v", host, port)
+	serverWithPort := net.JoinHostPort(host, strconv.Itoa(port))
 
 	if !helpers.ValidateHostList([]string{serverWithPort}) {
 		module.Log.Panic("bad server or port")
@@ -88,8 +89,13 @@ func (module *EmailNotifier) Configure(name, configRoot string) {
 	extraCa := viper.GetString(configRoot + ".extra-ca")
 	noVerify := viper.GetBool(configRoot + ".noverify")
 
-	d := gomail.NewDialer(host, port, "", "")
-	d.Auth = module.getSMTPAuth(configRoot)
+	// The dialer joins the host and port without brackets, so an IPv6 address needs to have them already
+	dialHost := host
+	if strings.Contains(host, ":") {
+		dialHost = "[" + host + "]"
+	}
+	d := gomail.NewDialer(dialHost, port, "", "")
+	d.Auth = module.getSMTPAuth(configRoot, dialHost)
 	d.TLSConfig = buildEmailTLSConfig(extraCa, noVerify, host)
 
 	module.smtpDialer = d
@@ -105,13 +111,13 @@ func buildEmailTLSConfig(extraCaFile string, noVerify bool, smtpHost string) *tl
 	}
 }
 
-// Builds authentication profile for smtp client
-func (module *EmailNotifier) getSMTPAuth(configRoot string) smtp.Auth {
+// Builds authentication profile for smtp client. The host must be the same as the one that the dialer uses
+func (module *EmailNotifier) getSMTPAuth(configRoot, host string) smtp.Auth {
 	var auth smtp.Auth
 	// Set up SMTP authentication
 	switch strings.ToLower(viper.GetString(configRoot + ".auth-type")) {
 	case "plain":
-		auth = smtp.PlainAuth("", viper.GetString(configRoot+".username"), viper.GetString(configRoot+".password"), viper.GetString(configRoot+".server"))
+		auth = smtp.PlainAuth("", viper.GetString(configRoot+".username"), viper.GetString(configRoot+".password"), host)
 	case "crammd5":
 		auth = smtp.CRAMMD5Auth(viper.GetString(configRoot+".username"), viper.GetString(configRoot+".password"))
 	case "":
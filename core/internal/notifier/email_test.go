@@ -96,6 +96,15 @@ func TestEmailNotifier_AcceptConsumerGroup(t *testing.T) {
 	assert.True(t, module.AcceptConsumerGroup(&protocol.ConsumerGroupStatus{}), "Expected any status to return True")
 }
 
+func TestEmailNotifier_Configure_IPv6(t *testing.T) {
+	module := fixtureEmailNotifier()
+	viper.Set("notifier.test.server", "2001:db8::25")
+
+	module.Configure("test", "notifier.test")
+	assert.Equal(t, "[2001:db8::25]", module.smtpDialer.Host, "Expected the dialer host to be in brackets")
+	assert.Equal(t, "2001:db8::25", module.smtpDialer.TLSConfig.ServerName, "Expected the TLS server name to not be in brackets")
+}
+
 func TestEmailNotifier_Notify_Open(t *testing.T) {
 	module := fixtureEmailNotifier()
 	viper.Set("notifier.test.auth-type", "plain")
@@ -14,6 +14,7 @@ import (
 	"encoding/json"
 	"log"
 	"os"
+	"strconv"
 	"text/template"
 	"time"
 
@@ -58,6 +59,8 @@ var helperFunctionMap = template.FuncMap{
 	"divide":          templateDivide,
 	"maxlag":          maxLagHelper,
 	"formattimestamp": formatTimestamp,
+	"etaToCatchUp":    etaToCatchUp,
+	"owner":           templateOwner,
 }
 
 // Helper function for the templates to encode an object into a JSON string
@@ -165,3 +168,75 @@ func maxLagHelper(a *protocol.PartitionStatus) uint64 {
 func formatTimestamp(timestamp int64, formatString string) string {
 	return time.Unix(0, timestamp*int64(time.Millisecond)).Format(formatString)
 }
+
+// Template Helper - estimate how long the consumer will take to consume the current lag for a partition, using the
+// change in lag between the first and last offsets that are stored for it. This returns a duration like "2h15m", or
+// "never" if the lag is not falling. If the partition does not have enough offsets stored, "unknown" is returned
+func etaToCatchUp(partition *protocol.PartitionStatus) string {
+	eta, ok := estimateCatchUp(partition)
+	if !ok {
+		return "unknown"
+	}
+	if eta < 0 {
+		return "never"
+	}
+	return humanizeDuration(eta)
+}
+
+// estimateCatchUp returns the time that the current lag for the partition will take to drain at the rate the lag fell
+// over the stored offsets, or a negative duration if the lag did not fall. False is returned if there is not enough
+// information to tell
+func estimateCatchUp(partition *protocol.PartitionStatus) (time.Duration, bool) {
+	if (partition == nil) || (partition.Start == nil) || (partition.End == nil) ||
+		(partition.Start.Lag == nil) || (partition.End.Lag == nil) {
+		return 0, false
+	}
+	elapsed := partition.End.Timestamp - partition.Start.Timestamp
+	if elapsed <= 0 {
+		return 0, false
+	}
+	if partition.CurrentLag == 0 {
+		return 0, true
+	}
+
+	// The slope is in messages per millisecond, as the timestamps are in milliseconds
+	slope := (float64(partition.End.Lag.Value) - float64(partition.Start.Lag.Value)) / float64(elapsed)
+	if slope >= 0 {
+		return -1, true
+	}
+	return time.Duration(float64(partition.CurrentLag) / -slope * float64(time.Millisecond)), true
+}
+
+// humanizeDuration formats a duration as its two largest units of days, hours, minutes, and seconds, such as "1d4h"
+// or "3m20s"
+func humanizeDuration(duration time.Duration) string {
+	seconds := int64(duration.Round(time.Second) / time.Second)
+	units := []struct {
+		suffix  string
+		seconds int64
+	}{
+		{"d", 86400},
+		{"h", 3600},
+		{"m", 60},
+		{"s", 1},
+	}
+
+	rv := ""
+	shown := 0
+	for _, unit := range units {
+		count := seconds / unit.seconds
+		seconds %= unit.seconds
+		if (count == 0) && (shown == 0) {
+			continue
+		}
+		rv += strconv.FormatInt(count, 10) + unit.suffix
+		shown++
+		if shown == 2 {
+			break
+		}
+	}
+	if rv == "" {
+		return "0s"
+	}
+	return rv
+}
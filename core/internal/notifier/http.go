@@ -10,8 +10,10 @@
 package notifier
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
-	"io"
 	"net"
 	"net/http"
 	"regexp"
@@ -49,14 +51,24 @@ type HTTPNotifier struct {
 	templateOpen   *template.Template
 	templateClose  *template.Template
 	sendClose      bool
+	hmacSecret     []byte
+	hmacHeader     string
 
 	httpClient *http.Client
+	auth       *httpAuth
+	retry      *httpRetry
 }
 
 // Configure validates the configuration of the http notifier. At minimum, there must be a url-open specified, and if
 // send-close is set to true there must also be a url-close. If these are missing or incorrect, this func will panic
 // with an explanatory message. It is also possible to configure a specific method (such as POST or DELETE) to be used
-// with these URLs, as well as a timeout and keepalive for the HTTP smtpClient.
+// with these URLs, as well as a timeout and keepalive for the HTTP smtpClient. A request that fails with an error, or
+// with a 429 or 5xx response, is retried up to retry-max times (3 by default), waiting retry-backoff seconds (1 by
+// default) before the first retry and doubling the wait for each one after it. If hmac-secret is set, each request is
+// signed with an HMAC-SHA256 of the body, using the secret as the key, which is sent in the hmac-header header
+// (X-Burrow-Signature by default) as "sha256=" followed by the hex digest, so that the receiver can verify it. Requests
+// are sent with HTTP Basic Auth if username and password are set, or with the value of authorization (such as
+// "Bearer <token>") as the Authorization header.
 func (module *HTTPNotifier) Configure(name, configRoot string) {
 	module.name = name
 
@@ -81,12 +93,24 @@ func (module *HTTPNotifier) Configure(name, configRoot string) {
 		module.methodClose = viper.GetString(configRoot + ".method-close")
 	}
 
+	if secret := viper.GetString(configRoot + ".hmac-secret"); secret != "" {
+		viper.SetDefault(configRoot+".hmac-header", "X-Burrow-Signature")
+		module.hmacSecret = []byte(secret)
+		module.hmacHeader = viper.GetString(configRoot + ".hmac-header")
+		if module.hmacHeader == "" {
+			module.Log.Panic("empty hmac-header specified")
+			panic(errors.New("configuration error"))
+		}
+	}
+
 	// Set defaults for module-specific configs if needed
 	viper.SetDefault(configRoot+".timeout", 5)
 	viper.SetDefault(configRoot+".keepalive", 300)
 
 	tlsConfig := buildHTTPTLSConfig(viper.GetString(configRoot+".extra-ca"), viper.GetBool(configRoot+".noverify"))
 
+	module.retry = newHTTPRetry(name, configRoot)
+	module.auth = newHTTPAuth(name, configRoot)
 	module.httpClient = &http.Client{
 		Timeout: viper.GetDuration(configRoot+".timeout") * time.Second,
 		Transport: &http.Transport{
@@ -100,6 +124,54 @@ func (module *HTTPNotifier) Configure(name, configRoot string) {
 	}
 }
 
+// signHMAC returns the signature of the body for the hmac-header, which is "sha256=" and the hex HMAC-SHA256 digest
+func signHMAC(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// httpAuth holds the credentials that the HTTP-based notifiers send with each request. They are only set on the
+// requests, and are never logged.
+type httpAuth struct {
+	username      string
+	password      string
+	authorization string
+}
+
+// newHTTPAuth returns the credentials of the notifier, from either username and password, for HTTP Basic Auth, or
+// authorization, for the value of the Authorization header. If none are set, nil is returned. If authorization is set
+// along with a username, or a password is set without a username, this func will panic.
+func newHTTPAuth(name, configRoot string) *httpAuth {
+	auth := &httpAuth{
+		username:      viper.GetString(configRoot + ".username"),
+		password:      viper.GetString(configRoot + ".password"),
+		authorization: viper.GetString(configRoot + ".authorization"),
+	}
+	if (auth.authorization != "") && (auth.username != "") {
+		panic("Notifier '" + name + "' must not have both a username and an authorization")
+	}
+	if (auth.password != "") && (auth.username == "") {
+		panic("Notifier '" + name + "' has a password, but no username")
+	}
+	if (auth.username == "") && (auth.authorization == "") {
+		return nil
+	}
+	return auth
+}
+
+// apply sets the credentials on the request. A nil httpAuth leaves the request as it is
+func (auth *httpAuth) apply(req *http.Request) {
+	if auth == nil {
+		return
+	}
+	if auth.username != "" {
+		req.SetBasicAuth(auth.username, auth.password)
+	} else {
+		req.Header.Set("Authorization", auth.authorization)
+	}
+}
+
 func buildHTTPTLSConfig(extraCaFile string, noVerify bool) *tls.Config {
 	rootCAs := buildRootCAs(extraCaFile, noVerify)
 
@@ -193,28 +265,25 @@ func (module *HTTPNotifier) Notify(status *protocol.ConsumerGroupStatus, eventID
 		logger.Error("failed to create request", zap.Error(err))
 		return
 	}
-	username := viper.GetString("notifier." + module.name + ".username")
-	if username != "" {
-		// Add basic auth using the provided username and password
-		req.SetBasicAuth(viper.GetString("notifier."+module.name+".username"), viper.GetString("notifier."+module.name+".password"))
-	}
 	req.Header.Set("Content-Type", "application/json")
 
 	for header, value := range viper.GetStringMapString("notifier." + module.name + ".headers") {
 		req.Header.Set(header, value)
 	}
+	module.auth.apply(req)
+	if module.hmacSecret != nil {
+		req.Header.Set(module.hmacHeader, signHMAC(module.hmacSecret, bytesToSend.Bytes()))
+	}
 
-	resp, err := module.httpClient.Do(req)
+	statusCode, err := module.retry.send(module.httpClient, req, logger)
 	if err != nil {
 		logger.Error("failed to send", zap.Error(err))
 		return
 	}
-	io.Copy(io.Discard, resp.Body)
-	resp.Body.Close()
 
-	if (resp.StatusCode >= 200) && (resp.StatusCode <= 299) {
+	if (statusCode >= 200) && (statusCode <= 299) {
 		logger.Debug("sent")
 	} else {
-		logger.Error("failed to send", zap.Int("response", resp.StatusCode))
+		logger.Error("failed to send", zap.Int("response", statusCode))
 	}
 }
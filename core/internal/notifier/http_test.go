@@ -10,8 +10,12 @@
 package notifier
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"text/template"
 	"time"
@@ -197,3 +201,99 @@ func TestHttpNotifier_Notify_Close(t *testing.T) {
 
 	module.Notify(status, "testidstring", time.Now(), true)
 }
+
+func TestHttpNotifier_Notify_HMAC(t *testing.T) {
+	// handler that checks the signature against the exact bytes received
+	received := false
+	requestHandler := func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err, "Expected to read the body")
+
+		mac := hmac.New(sha256.New, []byte("testsecret"))
+		mac.Write(body)
+		expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		assert.Equalf(t, expected, r.Header.Get("X-Test-Signature"), "Expected signature to be %v, not %v", expected, r.Header.Get("X-Test-Signature"))
+		assert.Empty(t, r.Header.Get("X-Burrow-Signature"), "Expected the default header to not be used")
+		received = true
+		fmt.Fprint(w, "ok")
+	}
+	ts := httptest.NewServer(http.HandlerFunc(requestHandler))
+	defer ts.Close()
+
+	module := fixtureHTTPNotifier()
+	viper.Set("notifier.test.url-open", ts.URL)
+	viper.Set("notifier.test.hmac-secret", "testsecret")
+	viper.Set("notifier.test.hmac-header", "X-Test-Signature")
+	module.templateOpen, _ = template.New("test").Parse("{\"id\":\"{{.ID}}\",\"group\":\"{{.Group}}\"}")
+	module.Configure("test", "notifier.test")
+
+	status := &protocol.ConsumerGroupStatus{
+		Status:  protocol.StatusWarning,
+		Cluster: "testcluster",
+		Group:   "testgroup",
+	}
+	module.Notify(status, "testidstring", time.Now(), false)
+	assert.True(t, received, "Expected the request to be received")
+}
+
+func TestHttpNotifier_Configure_HMAC(t *testing.T) {
+	module := fixtureHTTPNotifier()
+	module.Configure("test", "notifier.test")
+	assert.Nil(t, module.hmacSecret, "Expected no signing by default")
+
+	module = fixtureHTTPNotifier()
+	viper.Set("notifier.test.hmac-secret", "testsecret")
+	module.Configure("test", "notifier.test")
+	assert.Equal(t, "X-Burrow-Signature", module.hmacHeader, "Expected the default signature header")
+
+	module = fixtureHTTPNotifier()
+	viper.Set("notifier.test.hmac-secret", "testsecret")
+	viper.Set("notifier.test.hmac-header", "")
+	assert.Panics(t, func() { module.Configure("test", "notifier.test") }, "The code did not panic")
+}
+
+var httpAuthTests = []struct {
+	username      string
+	password      string
+	authorization string
+	header        string
+}{
+	/*0*/ {"", "", "", ""},
+	/*1*/ {"testuser", "testpass", "", "Basic dGVzdHVzZXI6dGVzdHBhc3M="},
+	/*2*/ {"testuser", "", "", "Basic dGVzdHVzZXI6"},
+	/*3*/ {"", "", "Bearer testtoken", "Bearer testtoken"},
+}
+
+func TestHttpNotifier_Notify_Auth(t *testing.T) {
+	for i, testSet := range httpAuthTests {
+		var header string
+		requestHandler := func(w http.ResponseWriter, r *http.Request) {
+			header = r.Header.Get("Authorization")
+			fmt.Fprint(w, "ok")
+		}
+		ts := httptest.NewServer(http.HandlerFunc(requestHandler))
+
+		module := fixtureHTTPNotifier()
+		viper.Set("notifier.test.url-open", ts.URL)
+		viper.Set("notifier.test.username", testSet.username)
+		viper.Set("notifier.test.password", testSet.password)
+		viper.Set("notifier.test.authorization", testSet.authorization)
+		module.templateOpen, _ = template.New("test").Parse("{\"id\":\"{{.ID}}\"}")
+		module.Configure("test", "notifier.test")
+
+		module.Notify(&protocol.ConsumerGroupStatus{Status: protocol.StatusWarning, Cluster: "testcluster", Group: "testgroup"}, "testidstring", time.Now(), false)
+		ts.Close()
+		assert.Equalf(t, testSet.header, header, "TEST %v: Expected Authorization header to be '%v', not '%v'", i, testSet.header, header)
+	}
+}
+
+func TestHttpNotifier_Configure_BadAuth(t *testing.T) {
+	module := fixtureHTTPNotifier()
+	viper.Set("notifier.test.username", "testuser")
+	viper.Set("notifier.test.authorization", "Bearer testtoken")
+	assert.Panics(t, func() { module.Configure("test", "notifier.test") }, "The code did not panic")
+
+	module = fixtureHTTPNotifier()
+	viper.Set("notifier.test.password", "testpass")
+	assert.Panics(t, func() { module.Configure("test", "notifier.test") }, "The code did not panic")
+}
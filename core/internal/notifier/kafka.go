@@ -0,0 +1,215 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package notifier
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/linkedin/Burrow/core/internal/helpers"
+	"github.com/linkedin/Burrow/core/protocol"
+)
+
+// KafkaNotifier is a module which produces notifications of consumer group status to a Kafka topic, as JSON events.
+// An event is only produced when the status of a group changes, so a group that stays in the same state does not
+// produce another event on every interval. The producer is configured using a client-profile, including the producer
+// settings of the profile. Templates are not used by this notifier.
+type KafkaNotifier struct {
+	// App is a pointer to the application context. This stores the channel to the storage subsystem
+	App *protocol.ApplicationContext
+
+	// Log is a logger that has been configured for this module to use. Normally, this means it has been set up with
+	// fields that are appropriate to identify this coordinator
+	Log *zap.Logger
+
+	name           string
+	groupAllowlist *regexp.Regexp
+	groupDenylist  *regexp.Regexp
+	extras         map[string]string
+	templateOpen   *template.Template
+	templateClose  *template.Template
+
+	clientProfile string
+	servers       []string
+	topic         string
+	saramaConfig  *sarama.Config
+	producer      sarama.SyncProducer
+	producerFunc  func([]string, *sarama.Config) (sarama.SyncProducer, error)
+
+	lastStatus     map[string]protocol.StatusConstant
+	lastStatusLock sync.Mutex
+}
+
+// kafkaEvent is the JSON object that is produced to the topic for each status change
+type kafkaEvent struct {
+	Cluster        string                    `json:"cluster"`
+	Group          string                    `json:"group"`
+	Status         protocol.StatusConstant   `json:"status"`
+	PreviousStatus protocol.StatusConstant   `json:"previous_status"`
+	TotalLag       uint64                    `json:"total_lag"`
+	Maxlag         *protocol.PartitionStatus `json:"maxlag"`
+	EventID        string                    `json:"id"`
+	Start          int64                     `json:"start"`
+	Timestamp      int64                     `json:"timestamp"`
+	Extras         map[string]string         `json:"extras,omitempty"`
+}
+
+// Configure validates the configuration of the kafka notifier. At minimum, there must be a list of servers for the
+// Kafka cluster to produce to, of the form host:port, and a topic. If either of these is missing or invalid, or if the
+// client-profile is invalid, this func will panic with an explanatory message. Unlike other notifiers, send-close
+// defaults to true, so that the recovery of a group is produced as well.
+func (module *KafkaNotifier) Configure(name, configRoot string) {
+	module.name = name
+	module.lastStatus = make(map[string]protocol.StatusConstant)
+	if module.producerFunc == nil {
+		module.producerFunc = sarama.NewSyncProducer
+	}
+
+	// Closing events are needed to record the transition back to OK
+	viper.SetDefault(configRoot+".send-close", true)
+
+	module.servers = viper.GetStringSlice(configRoot + ".servers")
+	if len(module.servers) == 0 {
+		module.Log.Panic("no Kafka brokers specified")
+		panic(errors.New("configuration error"))
+	} else if !helpers.ValidateHostList(module.servers) {
+		module.Log.Panic("one or more improperly formatted servers (must be host:port)")
+		panic(errors.New("configuration error"))
+	}
+
+	module.topic = viper.GetString(configRoot + ".topic")
+	if module.topic == "" {
+		module.Log.Panic("no topic specified")
+		panic(errors.New("configuration error"))
+	}
+
+	module.clientProfile = viper.GetString(configRoot + ".client-profile")
+	module.saramaConfig = helpers.GetSaramaConfigFromClientProfile(module.clientProfile)
+	module.saramaConfig.Producer.Return.Successes = true
+	helpers.LogSaramaConfig(module.Log, module.clientProfile, module.saramaConfig)
+}
+
+// Start creates the producer for the Kafka cluster. Any error connecting to the cluster is returned to the caller.
+func (module *KafkaNotifier) Start() error {
+	helpers.DetectKafkaVersion(module.Log, module.clientProfile, module.servers, module.saramaConfig)
+	producer, err := module.producerFunc(module.servers, module.saramaConfig)
+	if err != nil {
+		module.Log.Error("failed to start producer", zap.Error(err))
+		return err
+	}
+	module.producer = producer
+	return nil
+}
+
+// Stop closes the producer
+func (module *KafkaNotifier) Stop() error {
+	if module.producer != nil {
+		return module.producer.Close()
+	}
+	return nil
+}
+
+// GetName returns the configured name of this module
+func (module *KafkaNotifier) GetName() string {
+	return module.name
+}
+
+// GetGroupAllowlist returns the compiled group allowlist (or nil, if there is not one)
+func (module *KafkaNotifier) GetGroupAllowlist() *regexp.Regexp {
+	return module.groupAllowlist
+}
+
+// GetGroupDenylist returns the compiled group denylist (or nil, if there is not one)
+func (module *KafkaNotifier) GetGroupDenylist() *regexp.Regexp {
+	return module.groupDenylist
+}
+
+// GetLogger returns the configured zap.Logger for this notifier
+func (module *KafkaNotifier) GetLogger() *zap.Logger {
+	return module.Log
+}
+
+// AcceptConsumerGroup has no additional function for the kafka notifier, and so always returns true
+func (module *KafkaNotifier) AcceptConsumerGroup(status *protocol.ConsumerGroupStatus) bool {
+	return true
+}
+
+// usesTemplates returns false, as the messages are built from the status
+func (module *KafkaNotifier) usesTemplates() bool {
+	return false
+}
+
+// Notify produces a single event for the group to the configured topic, if the status of the group is different from
+// the status in the last event produced for it. A group that has not had an event produced, or for which the last
+// event closed the incident (stateGood is true), is considered to have been OK. The event is keyed by the cluster and
+// group, so all of the events for a group are in order in the same partition.
+func (module *KafkaNotifier) Notify(status *protocol.ConsumerGroupStatus, eventID string, startTime time.Time, stateGood bool) {
+	logger := module.Log.With(
+		zap.String("cluster", status.Cluster),
+		zap.String("group", status.Group),
+		zap.String("id", eventID),
+		zap.String("status", status.Status.String()),
+	)
+
+	key := status.Cluster + "/" + status.Group
+	module.lastStatusLock.Lock()
+	defer module.lastStatusLock.Unlock()
+
+	previousStatus, ok := module.lastStatus[key]
+	if !ok {
+		previousStatus = protocol.StatusOK
+	}
+	if status.Status == previousStatus {
+		logger.Debug("skipped", zap.String("reason", "no status change"))
+		return
+	}
+
+	body, err := json.Marshal(&kafkaEvent{
+		Cluster:        status.Cluster,
+		Group:          status.Group,
+		Status:         status.Status,
+		PreviousStatus: previousStatus,
+		TotalLag:       status.TotalLag,
+		Maxlag:         status.Maxlag,
+		EventID:        eventID,
+		Start:          startTime.Unix() * 1000,
+		Timestamp:      time.Now().Unix() * 1000,
+		Extras:         module.extras,
+	})
+	if err != nil {
+		logger.Error("failed to encode event", zap.Error(err))
+		return
+	}
+
+	partition, offset, err := module.producer.SendMessage(&sarama.ProducerMessage{
+		Topic: module.topic,
+		Key:   sarama.StringEncoder(key),
+		Value: sarama.ByteEncoder(body),
+	})
+	if err != nil {
+		logger.Error("failed to send", zap.Error(err))
+		return
+	}
+	logger.Debug("sent", zap.Int32("partition", partition), zap.Int64("offset", offset))
+
+	if stateGood || (status.Status == protocol.StatusOK) {
+		delete(module.lastStatus, key)
+	} else {
+		module.lastStatus[key] = status.Status
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package notifier
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/linkedin/Burrow/core/protocol"
+)
+
+func fixtureKafkaNotifier() *KafkaNotifier {
+	module := KafkaNotifier{
+		Log: zap.NewNop(),
+	}
+	module.App = &protocol.ApplicationContext{}
+
+	viper.Reset()
+	viper.Set("notifier.test.class-name", "kafka")
+	viper.Set("notifier.test.servers", []string{"broker1.example.com:1234"})
+	viper.Set("notifier.test.topic", "burrow-events")
+
+	return &module
+}
+
+func TestKafkaNotifier_ImplementsModule(t *testing.T) {
+	assert.Implements(t, (*protocol.Module)(nil), new(KafkaNotifier))
+	assert.Implements(t, (*Module)(nil), new(KafkaNotifier))
+	assert.Implements(t, (*templateModule)(nil), new(KafkaNotifier))
+	assert.False(t, new(KafkaNotifier).usesTemplates(), "Expected the templates not to be used")
+}
+
+func TestKafkaNotifier_Configure(t *testing.T) {
+	module := fixtureKafkaNotifier()
+
+	module.Configure("test", "notifier.test")
+	assert.Equal(t, "burrow-events", module.topic, "Expected topic to be set")
+	assert.True(t, module.saramaConfig.Producer.Return.Successes, "Expected Producer.Return.Successes to be true")
+	assert.True(t, viper.GetBool("notifier.test.send-close"), "Expected send-close to default to true")
+	assert.NotNil(t, module.producerFunc, "Expected producerFunc to be set")
+}
+
+func TestKafkaNotifier_Configure_NoServers(t *testing.T) {
+	module := fixtureKafkaNotifier()
+	viper.Set("notifier.test.servers", []string{})
+
+	assert.Panics(t, func() { module.Configure("test", "notifier.test") }, "Kafka notifier needs a supplied server list")
+}
+
+func TestKafkaNotifier_Configure_BadServers(t *testing.T) {
+	module := fixtureKafkaNotifier()
+	viper.Set("notifier.test.servers", []string{"broker1.example.com"})
+
+	assert.Panics(t, func() { module.Configure("test", "notifier.test") }, "Kafka notifier needs servers of the form host:port")
+}
+
+func TestKafkaNotifier_Configure_NoTopic(t *testing.T) {
+	module := fixtureKafkaNotifier()
+	viper.Set("notifier.test.topic", "")
+
+	assert.Panics(t, func() { module.Configure("test", "notifier.test") }, "Kafka notifier needs a supplied topic")
+}
+
+func TestKafkaNotifier_StartStop(t *testing.T) {
+	module := fixtureKafkaNotifier()
+	producer := mocks.NewSyncProducer(t, nil)
+	module.producerFunc = func(servers []string, config *sarama.Config) (sarama.SyncProducer, error) {
+		assert.Equal(t, []string{"broker1.example.com:1234"}, servers, "Expected producer to be created with the server list")
+		return producer, nil
+	}
+	module.Configure("test", "notifier.test")
+
+	assert.Nil(t, module.Start(), "Expected Start to return no error")
+	assert.Equal(t, producer, module.producer, "Expected producer to be set")
+	assert.Nil(t, module.Stop(), "Expected Stop to return no error")
+}
+
+func TestKafkaNotifier_Start_Error(t *testing.T) {
+	module := fixtureKafkaNotifier()
+	module.producerFunc = func(servers []string, config *sarama.Config) (sarama.SyncProducer, error) {
+		return nil, errors.New("no brokers")
+	}
+	module.Configure("test", "notifier.test")
+
+	assert.NotNil(t, module.Start(), "Expected Start to return an error")
+	assert.Nil(t, module.Stop(), "Expected Stop to return no error")
+}
+
+func kafkaEventChecker(t *testing.T, status, previousStatus string) mocks.ValueChecker {
+	return func(val []byte) error {
+		event := make(map[string]interface{})
+		if err := json.Unmarshal(val, &event); err != nil {
+			return err
+		}
+		assert.Equalf(t, "testcluster", event["cluster"], "Expected cluster to be testcluster, not %v", event["cluster"])
+		assert.Equalf(t, "testgroup", event["group"], "Expected group to be testgroup, not %v", event["group"])
+		assert.Equalf(t, status, event["status"], "Expected status to be %v, not %v", status, event["status"])
+		assert.Equalf(t, previousStatus, event["previous_status"], "Expected previous_status to be %v, not %v", previousStatus, event["previous_status"])
+		assert.Equalf(t, "testidstring", event["id"], "Expected id to be testidstring, not %v", event["id"])
+		assert.NotNil(t, event["maxlag"], "Expected maxlag to be set")
+		return nil
+	}
+}
+
+func TestKafkaNotifier_Notify(t *testing.T) {
+	module := fixtureKafkaNotifier()
+	producer := mocks.NewSyncProducer(t, nil)
+	module.producerFunc = func(servers []string, config *sarama.Config) (sarama.SyncProducer, error) {
+		return producer, nil
+	}
+	module.Configure("test", "notifier.test")
+	module.Start()
+
+	// Only the status changes are produced, in order
+	producer.ExpectSendMessageWithCheckerFunctionAndSucceed(kafkaEventChecker(t, "WARN", "OK"))
+	producer.ExpectSendMessageWithCheckerFunctionAndSucceed(kafkaEventChecker(t, "ERR", "WARN"))
+	producer.ExpectSendMessageWithCheckerFunctionAndSucceed(kafkaEventChecker(t, "OK", "ERR"))
+
+	status := &protocol.ConsumerGroupStatus{
+		Status:  protocol.StatusWarning,
+		Cluster: "testcluster",
+		Group:   "testgroup",
+		Maxlag: &protocol.PartitionStatus{
+			Topic:      "testtopic",
+			Partition:  0,
+			Status:     protocol.StatusWarning,
+			CurrentLag: 2345,
+		},
+	}
+	startTime := time.Now()
+	module.Notify(status, "testidstring", startTime, false)
+	module.Notify(status, "testidstring", startTime, false)
+
+	status.Status = protocol.StatusError
+	module.Notify(status, "testidstring", startTime, false)
+	module.Notify(status, "testidstring", startTime, false)
+
+	status.Status = protocol.StatusOK
+	module.Notify(status, "testidstring", startTime, true)
+	module.Notify(status, "testidstring", startTime, true)
+
+	// Close will fail the test if any expected messages were not sent
+	assert.Nil(t, module.Stop(), "Expected Stop to return no error")
+	assert.Empty(t, module.lastStatus, "Expected the closed group to be removed")
+}
+
+func TestKafkaNotifier_Notify_Failed(t *testing.T) {
+	module := fixtureKafkaNotifier()
+	producer := mocks.NewSyncProducer(t, nil)
+	module.producerFunc = func(servers []string, config *sarama.Config) (sarama.SyncProducer, error) {
+		return producer, nil
+	}
+	module.Configure("test", "notifier.test")
+	module.Start()
+
+	// A failed event is produced again on the next notification
+	producer.ExpectSendMessageAndFail(sarama.ErrOutOfBrokers)
+	producer.ExpectSendMessageWithCheckerFunctionAndSucceed(kafkaEventChecker(t, "WARN", "OK"))
+
+	status := &protocol.ConsumerGroupStatus{
+		Status:  protocol.StatusWarning,
+		Cluster: "testcluster",
+		Group:   "testgroup",
+		Maxlag:  &protocol.PartitionStatus{},
+	}
+	module.Notify(status, "testidstring", time.Now(), false)
+	module.Notify(status, "testidstring", time.Now(), false)
+
+	assert.Nil(t, module.Stop(), "Expected Stop to return no error")
+}
@@ -0,0 +1,264 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/linkedin/Burrow/core/protocol"
+)
+
+// OpsGenieNotifier is a module which creates and closes alerts for consumer group status with the OpsGenie Alert API.
+// An alert is created for a group that is at or over the threshold, and closed when it goes back to OK. The alerts use
+// the cluster and group as the alias, so that OpsGenie deduplicates the repeated notifications for a group into a
+// single alert. Templates are not used by this notifier.
+type OpsGenieNotifier struct {
+	// App is a pointer to the application context. This stores the channel to the storage subsystem
+	App *protocol.ApplicationContext
+
+	// Log is a logger that has been configured for this module to use. Normally, this means it has been set up with
+	// fields that are appropriate to identify this coordinator
+	Log *zap.Logger
+
+	name           string
+	groupAllowlist *regexp.Regexp
+	groupDenylist  *regexp.Regexp
+	extras         map[string]string
+	templateOpen   *template.Template
+	templateClose  *template.Template
+
+	url           string
+	apiKey        string
+	priority      string
+	responderTeam string
+	tags          []string
+
+	httpClient *http.Client
+	retry      *httpRetry
+}
+
+type opsGenieResponder struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// opsGenieAlert is the JSON object that is sent to create an alert
+type opsGenieAlert struct {
+	Message     string              `json:"message"`
+	Alias       string              `json:"alias"`
+	Description string              `json:"description"`
+	Responders  []opsGenieResponder `json:"responders,omitempty"`
+	Tags        []string            `json:"tags,omitempty"`
+	Details     map[string]string   `json:"details"`
+	Entity      string              `json:"entity"`
+	Source      string              `json:"source"`
+	Priority    string              `json:"priority"`
+}
+
+// opsGenieClose is the JSON object that is sent to close an alert
+type opsGenieClose struct {
+	Source string `json:"source"`
+	Note   string `json:"note"`
+}
+
+var opsGeniePriorityRegexp = regexp.MustCompile("^P[1-5]$")
+
+// Configure validates the configuration of the opsgenie notifier. At minimum, there must be an api-key specified for
+// the API integration. If this is missing, or if the priority is not one of P1 to P5, this func will panic with an
+// explanatory message. The url defaults to the OpsGenie API in the US (use https://api.eu.opsgenie.com for the EU),
+// and the priority of the alerts to P3. If a responder-team is configured, the alerts are assigned to that team. As
+// with the http notifier, a timeout and keepalive for the HTTP client can be configured, as well as an extra CA,
+// noverify, and retries. Unlike most notifiers, send-close defaults to true, so that the recovery of a group closes
+// the alert.
+func (module *OpsGenieNotifier) Configure(name, configRoot string) {
+	module.name = name
+
+	module.apiKey = viper.GetString(configRoot + ".api-key")
+	if module.apiKey == "" {
+		module.Log.Panic("no api-key specified")
+		panic(errors.New("configuration error"))
+	}
+
+	// Set defaults for module-specific configs if needed
+	viper.SetDefault(configRoot+".url", "https://api.opsgenie.com")
+	viper.SetDefault(configRoot+".priority", "P3")
+	viper.SetDefault(configRoot+".send-close", true)
+	viper.SetDefault(configRoot+".timeout", 5)
+	viper.SetDefault(configRoot+".keepalive", 300)
+	module.url = strings.TrimSuffix(viper.GetString(configRoot+".url"), "/")
+	module.responderTeam = viper.GetString(configRoot + ".responder-team")
+	module.tags = viper.GetStringSlice(configRoot + ".tags")
+
+	module.priority = viper.GetString(configRoot + ".priority")
+	if !opsGeniePriorityRegexp.MatchString(module.priority) {
+		module.Log.Panic("priority must be one of P1 to P5", zap.String("priority", module.priority))
+		panic(errors.New("configuration error"))
+	}
+
+	module.retry = newHTTPRetry(name, configRoot)
+	module.httpClient = &http.Client{
+		Timeout: viper.GetDuration(configRoot+".timeout") * time.Second,
+		Transport: &http.Transport{
+			Dial: (&net.Dialer{
+				KeepAlive: viper.GetDuration(configRoot+".keepalive") * time.Second,
+			}).Dial,
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: buildHTTPTLSConfig(viper.GetString(configRoot+".extra-ca"), viper.GetBool(configRoot+".noverify")),
+		},
+	}
+}
+
+// Start is a no-op for the opsgenie notifier. It always returns no error
+func (module *OpsGenieNotifier) Start() error {
+	return nil
+}
+
+// Stop is a no-op for the opsgenie notifier. It always returns no error
+func (module *OpsGenieNotifier) Stop() error {
+	return nil
+}
+
+// GetName returns the configured name of this module
+func (module *OpsGenieNotifier) GetName() string {
+	return module.name
+}
+
+// GetGroupAllowlist returns the compiled group allowlist (or nil, if there is not one)
+func (module *OpsGenieNotifier) GetGroupAllowlist() *regexp.Regexp {
+	return module.groupAllowlist
+}
+
+// GetGroupDenylist returns the compiled group denylist (or nil, if there is not one)
+func (module *OpsGenieNotifier) GetGroupDenylist() *regexp.Regexp {
+	return module.groupDenylist
+}
+
+// GetLogger returns the configured zap.Logger for this notifier
+func (module *OpsGenieNotifier) GetLogger() *zap.Logger {
+	return module.Log
+}
+
+// AcceptConsumerGroup has no additional function for the opsgenie notifier, and so always returns true
+func (module *OpsGenieNotifier) AcceptConsumerGroup(status *protocol.ConsumerGroupStatus) bool {
+	return true
+}
+
+// usesTemplates returns false, as the alerts are built from the status
+func (module *OpsGenieNotifier) usesTemplates() bool {
+	return false
+}
+
+// identifiesIncidentsByGroup returns true, as the alias of an event is the cluster and group
+func (module *OpsGenieNotifier) identifiesIncidentsByGroup() bool {
+	return true
+}
+
+// buildOpsGenieAlert assembles the alert to create for the status
+func (module *OpsGenieNotifier) buildOpsGenieAlert(status *protocol.ConsumerGroupStatus, eventID string, startTime time.Time) *opsGenieAlert {
+	details := map[string]string{
+		"cluster":         status.Cluster,
+		"group":           status.Group,
+		"status":          status.Status.String(),
+		"total_lag":       strconv.FormatUint(status.TotalLag, 10),
+		"partition_count": strconv.Itoa(status.TotalPartitions),
+		"start":           startTime.UTC().Format(time.RFC3339),
+		"id":              eventID,
+	}
+	description := "Consumer group " + status.Group + " on cluster " + status.Cluster + " has a total lag of " +
+		strconv.FormatUint(status.TotalLag, 10) + " across " + strconv.Itoa(status.TotalPartitions) + " partitions."
+	if status.Maxlag != nil {
+		details["maxlag"] = status.Maxlag.Topic + ":" + strconv.FormatInt(int64(status.Maxlag.Partition), 10)
+		description += " The worst partition is " + details["maxlag"] + " (" + status.Maxlag.Status.String() +
+			", lag " + strconv.FormatUint(status.Maxlag.CurrentLag, 10) + ")."
+	}
+	for key, value := range module.extras {
+		details[key] = value
+	}
+
+	alert := &opsGenieAlert{
+		Message:     "Consumer group " + status.Group + " on cluster " + status.Cluster + " is " + status.Status.String(),
+		Alias:       status.Cluster + "/" + status.Group,
+		Description: description,
+		Tags:        module.tags,
+		Details:     details,
+		Entity:      status.Cluster,
+		Source:      "Burrow",
+		Priority:    module.priority,
+	}
+	if module.responderTeam != "" {
+		alert.Responders = []opsGenieResponder{{Name: module.responderTeam, Type: "team"}}
+	}
+	return alert
+}
+
+// Notify creates an alert for the group, or, if stateGood is true, closes the alert for the group. OpsGenie accepts
+// the requests asynchronously, so a failure to process them (such as an unknown responder team) is only logged by
+// OpsGenie, and not here.
+func (module *OpsGenieNotifier) Notify(status *protocol.ConsumerGroupStatus, eventID string, startTime time.Time, stateGood bool) {
+	logger := module.Log.With(
+		zap.String("cluster", status.Cluster),
+		zap.String("group", status.Group),
+		zap.String("id", eventID),
+		zap.String("status", status.Status.String()),
+	)
+
+	var requestURL string
+	var message interface{}
+	if stateGood {
+		requestURL = module.url + "/v2/alerts/" + url.PathEscape(status.Cluster+"/"+status.Group) + "/close?identifierType=alias"
+		message = &opsGenieClose{
+			Source: "Burrow",
+			Note:   "Consumer group " + status.Group + " on cluster " + status.Cluster + " has recovered",
+		}
+	} else {
+		requestURL = module.url + "/v2/alerts"
+		message = module.buildOpsGenieAlert(status, eventID, startTime)
+	}
+
+	body, err := json.Marshal(message)
+	if err != nil {
+		logger.Error("failed to encode alert", zap.Error(err))
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, requestURL, bytes.NewReader(body))
+	if err != nil {
+		logger.Error("failed to create request", zap.Error(err))
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "GenieKey "+module.apiKey)
+
+	statusCode, err := module.retry.send(module.httpClient, req, logger)
+	if err != nil {
+		logger.Error("failed to send", zap.Error(err))
+		return
+	}
+
+	// The Alert API responds with 202 when the request is accepted
+	if (statusCode >= 200) && (statusCode <= 299) {
+		logger.Debug("sent")
+	} else {
+		logger.Error("failed to send", zap.Int("response", statusCode))
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package notifier
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/linkedin/Burrow/core/protocol"
+)
+
+func fixtureOpsGenieNotifier() *OpsGenieNotifier {
+	module := OpsGenieNotifier{
+		Log: zap.NewNop(),
+	}
+	module.App = &protocol.ApplicationContext{}
+
+	viper.Reset()
+	viper.Set("notifier.test.class-name", "opsgenie")
+	viper.Set("notifier.test.api-key", "testapikey")
+
+	return &module
+}
+
+func TestOpsGenieNotifier_ImplementsModule(t *testing.T) {
+	assert.Implements(t, (*protocol.Module)(nil), new(OpsGenieNotifier))
+	assert.Implements(t, (*Module)(nil), new(OpsGenieNotifier))
+	assert.Implements(t, (*templateModule)(nil), new(OpsGenieNotifier))
+	assert.False(t, new(OpsGenieNotifier).usesTemplates(), "Expected the templates not to be used")
+}
+
+func TestOpsGenieNotifier_Configure(t *testing.T) {
+	module := fixtureOpsGenieNotifier()
+
+	module.Configure("test", "notifier.test")
+	assert.NotNil(t, module.httpClient, "Expected httpClient to be set with a client object")
+	assert.Equal(t, "https://api.opsgenie.com", module.url)
+	assert.Equal(t, "P3", module.priority)
+	assert.True(t, viper.GetBool("notifier.test.send-close"), "Expected send-close to default to true")
+}
+
+func TestOpsGenieNotifier_Bad_Configuration(t *testing.T) {
+	module := fixtureOpsGenieNotifier()
+	viper.Set("notifier.test.api-key", "")
+	assert.Panics(t, func() { module.Configure("test", "notifier.test") }, "OpsGenie notifier needs a supplied api-key")
+
+	module = fixtureOpsGenieNotifier()
+	viper.Set("notifier.test.priority", "high")
+	assert.Panics(t, func() { module.Configure("test", "notifier.test") }, "OpsGenie notifier needs a valid priority")
+}
+
+func TestOpsGenieNotifier_Notify(t *testing.T) {
+	var paths []string
+	var alert opsGenieAlert
+	var closeRequest opsGenieClose
+	requestHandler := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "GenieKey testapikey", r.Header.Get("Authorization"))
+
+		paths = append(paths, r.URL.EscapedPath()+"?"+r.URL.RawQuery)
+		if len(paths) == 1 {
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&alert))
+		} else {
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&closeRequest))
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(requestHandler))
+	defer ts.Close()
+
+	module := fixtureOpsGenieNotifier()
+	viper.Set("notifier.test.url", ts.URL+"/")
+	viper.Set("notifier.test.priority", "P2")
+	viper.Set("notifier.test.responder-team", "data-platform")
+	module.extras = map[string]string{"runbook": "https://wiki.example.com"}
+	module.Configure("test", "notifier.test")
+
+	status := &protocol.ConsumerGroupStatus{
+		Status:          protocol.StatusError,
+		Cluster:         "testcluster",
+		Group:           "testgroup",
+		TotalLag:        100,
+		TotalPartitions: 4,
+		Maxlag: &protocol.PartitionStatus{
+			Topic:      "testtopic",
+			Partition:  3,
+			Status:     protocol.StatusStall,
+			CurrentLag: 80,
+		},
+	}
+	module.Notify(status, "testidstring", time.Unix(1637, 0), false)
+	status.Status = protocol.StatusOK
+	module.Notify(status, "testidstring", time.Unix(1637, 0), true)
+
+	assert.Equal(t, []string{"/v2/alerts?", "/v2/alerts/testcluster%2Ftestgroup/close?identifierType=alias"}, paths)
+
+	assert.Equal(t, "Consumer group testgroup on cluster testcluster is ERR", alert.Message)
+	assert.Equal(t, "testcluster/testgroup", alert.Alias)
+	assert.Equal(t, "P2", alert.Priority)
+	assert.Equal(t, []opsGenieResponder{{Name: "data-platform", Type: "team"}}, alert.Responders)
+	assert.Equal(t, "testtopic:3", alert.Details["maxlag"])
+	assert.Equal(t, "100", alert.Details["total_lag"])
+	assert.Equal(t, "https://wiki.example.com", alert.Details["runbook"])
+	assert.Contains(t, alert.Description, "The worst partition is testtopic:3 (STALL, lag 80).")
+
+	assert.Equal(t, "Burrow", closeRequest.Source)
+	assert.Equal(t, "Consumer group testgroup on cluster testcluster has recovered", closeRequest.Note)
+}
@@ -0,0 +1,123 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package notifier
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ownershipEntry maps a consumer group to the team that owns it. An entry with an empty cluster matches the group in
+// every cluster.
+type ownershipEntry struct {
+	Cluster string `json:"cluster"`
+	Group   string `json:"group"`
+	Owner   string `json:"owner"`
+}
+
+// ownershipMap holds the group owners loaded from the general ownership-file, for the owner template helper. The
+// owners are replaced as a whole when the file is loaded again, so a template never sees a partially loaded file.
+type ownershipMap struct {
+	lock         sync.RWMutex
+	defaultOwner string
+
+	// Owners by cluster, then group. Entries for every cluster are stored under the empty cluster name
+	owners map[string]map[string]string
+}
+
+// The owners used by the owner template helper. This is shared, like the rest of the template helpers, and is loaded
+// by the coordinator
+var groupOwnership = &ownershipMap{}
+
+// lookup returns the owner of the group in the cluster. An entry for the cluster is preferred over one for every
+// cluster, and the default owner is returned if neither exists.
+func (ownership *ownershipMap) lookup(cluster, group string) string {
+	ownership.lock.RLock()
+	defer ownership.lock.RUnlock()
+
+	if owner, ok := ownership.owners[cluster][group]; ok {
+		return owner
+	}
+	if owner, ok := ownership.owners[""][group]; ok {
+		return owner
+	}
+	return ownership.defaultOwner
+}
+
+// load reads the owners from the file, if one is given, and replaces the current owners and default owner with them.
+// If the file cannot be read or parsed, the current owners are kept and the error is returned.
+func (ownership *ownershipMap) load(filename, defaultOwner string) error {
+	owners := make(map[string]map[string]string)
+	if filename != "" {
+		entries, err := readOwnershipFile(filename)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if entry.Group == "" || entry.Owner == "" {
+				return errors.New("ownership entry is missing a group or owner in " + filename)
+			}
+			if _, ok := owners[entry.Cluster]; !ok {
+				owners[entry.Cluster] = make(map[string]string)
+			}
+			owners[entry.Cluster][entry.Group] = entry.Owner
+		}
+	}
+
+	ownership.lock.Lock()
+	defer ownership.lock.Unlock()
+	ownership.owners = owners
+	ownership.defaultOwner = defaultOwner
+	return nil
+}
+
+// readOwnershipFile parses the entries from a JSON file (a list of objects with cluster, group, and owner fields) if the
+// filename ends with .json. Otherwise, it is parsed as a CSV file with cluster, group, and owner on each line. Lines in
+// the CSV file that begin with # are ignored.
+func readOwnershipFile(filename string) ([]ownershipEntry, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []ownershipEntry
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		err = json.NewDecoder(file).Decode(&entries)
+		return entries, err
+	}
+
+	reader := csv.NewReader(file)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		entries = append(entries, ownershipEntry{
+			Cluster: strings.TrimSpace(record[0]),
+			Group:   strings.TrimSpace(record[1]),
+			Owner:   strings.TrimSpace(record[2]),
+		})
+	}
+	return entries, nil
+}
+
+// Helper function for the templates to return the team that owns the consumer group
+func templateOwner(cluster, group string) string {
+	return groupOwnership.lookup(cluster, group)
+}
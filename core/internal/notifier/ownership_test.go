@@ -0,0 +1,135 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package notifier
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/linkedin/Burrow/core/protocol"
+)
+
+func writeOwnershipFile(t *testing.T, name, contents string) string {
+	filename := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(filename, []byte(contents), 0600)
+	assert.NoError(t, err, "Expected ownership file setup to return no error")
+	return filename
+}
+
+var ownershipLookupTests = []struct {
+	cluster string
+	group   string
+	owner   string
+}{
+	/*0*/ {"testcluster", "testgroup", "team-cluster"},
+	/*1*/ {"othercluster", "testgroup", "team-any"},
+	/*2*/ {"othercluster", "othergroup", "team-default"},
+	/*3*/ {"testcluster", "onlyany", "team-any"},
+}
+
+func TestOwnershipMap_CSV(t *testing.T) {
+	filename := writeOwnershipFile(t, "owners.csv", `# cluster,group,owner
+testcluster,testgroup,team-cluster
+,testgroup,team-any
+, onlyany , team-any
+`)
+
+	ownership := &ownershipMap{}
+	assert.NoError(t, ownership.load(filename, "team-default"), "Expected load to return no error")
+	for i, testSet := range ownershipLookupTests {
+		result := ownership.lookup(testSet.cluster, testSet.group)
+		assert.Equalf(t, testSet.owner, result, "TEST %v: Expected owner to be %v, not %v", i, testSet.owner, result)
+	}
+}
+
+func TestOwnershipMap_JSON(t *testing.T) {
+	filename := writeOwnershipFile(t, "owners.json", `[
+  {"cluster": "testcluster", "group": "testgroup", "owner": "team-cluster"},
+  {"group": "testgroup", "owner": "team-any"},
+  {"group": "onlyany", "owner": "team-any"}
+]`)
+
+	ownership := &ownershipMap{}
+	assert.NoError(t, ownership.load(filename, "team-default"), "Expected load to return no error")
+	for i, testSet := range ownershipLookupTests {
+		result := ownership.lookup(testSet.cluster, testSet.group)
+		assert.Equalf(t, testSet.owner, result, "TEST %v: Expected owner to be %v, not %v", i, testSet.owner, result)
+	}
+}
+
+func TestOwnershipMap_NoFile(t *testing.T) {
+	ownership := &ownershipMap{}
+	assert.NoError(t, ownership.load("", "team-default"), "Expected load to return no error")
+	assert.Equal(t, "team-default", ownership.lookup("testcluster", "testgroup"), "Expected the default owner")
+}
+
+func TestOwnershipMap_BadFileKeepsOwners(t *testing.T) {
+	ownership := &ownershipMap{}
+	good := writeOwnershipFile(t, "owners.csv", "testcluster,testgroup,team-cluster\n")
+	assert.NoError(t, ownership.load(good, "team-default"), "Expected load to return no error")
+
+	badFiles := []string{
+		writeOwnershipFile(t, "short.csv", "testcluster,testgroup\n"),
+		writeOwnershipFile(t, "noowner.csv", "testcluster,testgroup,\n"),
+		writeOwnershipFile(t, "bad.json", "{not json"),
+		filepath.Join(t.TempDir(), "missing.csv"),
+	}
+	for i, filename := range badFiles {
+		assert.Errorf(t, ownership.load(filename, "other-default"), "TEST %v: Expected load to return an error", i)
+		assert.Equalf(t, "team-cluster", ownership.lookup("testcluster", "testgroup"), "TEST %v: Expected owners to be kept", i)
+		assert.Equalf(t, "team-default", ownership.lookup("testcluster", "othergroup"), "TEST %v: Expected default owner to be kept", i)
+	}
+}
+
+func TestCoordinator_ExecuteTemplate_owner(t *testing.T) {
+	coordinator := fixtureCoordinator()
+	viper.Set("general.ownership-file", writeOwnershipFile(t, "owners.csv", ",testgroup,team-one\n"))
+	viper.Set("general.default-owner", "team-default")
+	coordinator.Configure()
+
+	tmpl, _ := template.New("test").Funcs(helperFunctionMap).Parse("{{ owner .Cluster .Group }}")
+	status := &protocol.ConsumerGroupStatus{
+		Status:  protocol.StatusError,
+		Cluster: "testcluster",
+		Group:   "testgroup",
+	}
+	bytesToSend, err := executeTemplate(tmpl, nil, status, "testidstring", time.Now())
+	assert.Nil(t, err, "Expected no error to be returned")
+	assert.Equal(t, "team-one", bytesToSend.String(), "Expected the owner from the file")
+
+	// Reloading picks up the changed file
+	config := viper.New()
+	config.Set("general.ownership-file", writeOwnershipFile(t, "owners.csv", ",testgroup,team-two\n"))
+	config.Set("general.default-owner", "team-default")
+	assert.NoError(t, coordinator.ReloadFilters(config), "Expected ReloadFilters to return no error")
+	bytesToSend, _ = executeTemplate(tmpl, nil, status, "testidstring", time.Now())
+	assert.Equal(t, "team-two", bytesToSend.String(), "Expected the owner from the reloaded file")
+
+	// A bad file keeps the current owners
+	config.Set("general.ownership-file", filepath.Join(t.TempDir(), "missing.csv"))
+	assert.Error(t, coordinator.ReloadFilters(config), "Expected ReloadFilters to return an error")
+	bytesToSend, _ = executeTemplate(tmpl, nil, status, "testidstring", time.Now())
+	assert.Equal(t, "team-two", bytesToSend.String(), "Expected the owner to be kept")
+
+	// Reset the owners so that other tests are not affected
+	assert.NoError(t, groupOwnership.load("", ""), "Expected load to return no error")
+}
+
+func TestCoordinator_Configure_BadOwnershipFile(t *testing.T) {
+	coordinator := fixtureCoordinator()
+	viper.Set("general.ownership-file", filepath.Join(t.TempDir(), "missing.csv"))
+	assert.Panics(t, coordinator.Configure, "The code did not panic")
+}
@@ -0,0 +1,250 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"text/template"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/linkedin/Burrow/core/protocol"
+)
+
+// PagerDutyNotifier is a module which sends events for consumer group status to the PagerDuty Events API v2. A
+// trigger event is sent for a group that is at or over the threshold, and a resolve event when it goes back to OK.
+// The events use the cluster and group as the dedup key, so that the notifications for a group update a single
+// incident, and the recovery of the group resolves it. Templates are not used by this notifier.
+type PagerDutyNotifier struct {
+	// App is a pointer to the application context. This stores the channel to the storage subsystem
+	App *protocol.ApplicationContext
+
+	// Log is a logger that has been configured for this module to use. Normally, this means it has been set up with
+	// fields that are appropriate to identify this coordinator
+	Log *zap.Logger
+
+	name           string
+	groupAllowlist *regexp.Regexp
+	groupDenylist  *regexp.Regexp
+	extras         map[string]string
+	templateOpen   *template.Template
+	templateClose  *template.Template
+
+	url        string
+	routingKey string
+	source     string
+
+	httpClient *http.Client
+	retry      *httpRetry
+}
+
+// pagerDutyEvent is the JSON object that is sent to the Events API v2. The payload is only set for trigger events
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Client      string            `json:"client,omitempty"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string                 `json:"summary"`
+	Source        string                 `json:"source"`
+	Severity      string                 `json:"severity"`
+	Timestamp     string                 `json:"timestamp"`
+	Component     string                 `json:"component"`
+	Group         string                 `json:"group"`
+	Class         string                 `json:"class"`
+	CustomDetails map[string]interface{} `json:"custom_details"`
+}
+
+// Configure validates the configuration of the pagerduty notifier. At minimum, there must be a routing-key specified
+// for the integration of the PagerDuty service. If this is missing, this func will panic with an explanatory message.
+// The url defaults to the PagerDuty Events API v2 endpoint, and the source of the events to the hostname. As with the
+// http notifier, a timeout and keepalive for the HTTP client can be configured, as well as an extra CA, noverify, and
+// retries. Unlike most notifiers, send-close defaults to true, so that the recovery of a group resolves the incident.
+func (module *PagerDutyNotifier) Configure(name, configRoot string) {
+	module.name = name
+
+	module.routingKey = viper.GetString(configRoot + ".routing-key")
+	if module.routingKey == "" {
+		module.Log.Panic("no routing-key specified")
+		panic(errors.New("configuration error"))
+	}
+
+	// Set defaults for module-specific configs if needed
+	hostname, _ := os.Hostname()
+	viper.SetDefault(configRoot+".url", "https://events.pagerduty.com/v2/enqueue")
+	viper.SetDefault(configRoot+".source", hostname)
+	viper.SetDefault(configRoot+".send-close", true)
+	viper.SetDefault(configRoot+".timeout", 5)
+	viper.SetDefault(configRoot+".keepalive", 300)
+	module.url = viper.GetString(configRoot + ".url")
+	module.source = viper.GetString(configRoot + ".source")
+
+	module.retry = newHTTPRetry(name, configRoot)
+	module.httpClient = &http.Client{
+		Timeout: viper.GetDuration(configRoot+".timeout") * time.Second,
+		Transport: &http.Transport{
+			Dial: (&net.Dialer{
+				KeepAlive: viper.GetDuration(configRoot+".keepalive") * time.Second,
+			}).Dial,
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: buildHTTPTLSConfig(viper.GetString(configRoot+".extra-ca"), viper.GetBool(configRoot+".noverify")),
+		},
+	}
+}
+
+// Start is a no-op for the pagerduty notifier. It always returns no error
+func (module *PagerDutyNotifier) Start() error {
+	return nil
+}
+
+// Stop is a no-op for the pagerduty notifier. It always returns no error
+func (module *PagerDutyNotifier) Stop() error {
+	return nil
+}
+
+// GetName returns the configured name of this module
+func (module *PagerDutyNotifier) GetName() string {
+	return module.name
+}
+
+// GetGroupAllowlist returns the compiled group allowlist (or nil, if there is not one)
+func (module *PagerDutyNotifier) GetGroupAllowlist() *regexp.Regexp {
+	return module.groupAllowlist
+}
+
+// GetGroupDenylist returns the compiled group denylist (or nil, if there is not one)
+func (module *PagerDutyNotifier) GetGroupDenylist() *regexp.Regexp {
+	return module.groupDenylist
+}
+
+// GetLogger returns the configured zap.Logger for this notifier
+func (module *PagerDutyNotifier) GetLogger() *zap.Logger {
+	return module.Log
+}
+
+// AcceptConsumerGroup has no additional function for the pagerduty notifier, and so always returns true
+func (module *PagerDutyNotifier) AcceptConsumerGroup(status *protocol.ConsumerGroupStatus) bool {
+	return true
+}
+
+// usesTemplates returns false, as the events are built from the status
+func (module *PagerDutyNotifier) usesTemplates() bool {
+	return false
+}
+
+// identifiesIncidentsByGroup returns true, as the dedup key of an event is the cluster and group
+func (module *PagerDutyNotifier) identifiesIncidentsByGroup() bool {
+	return true
+}
+
+// pagerDutySeverity returns the PagerDuty severity for the group status. A group that has stopped or stalled is
+// critical, as it is not making any progress
+func pagerDutySeverity(status protocol.StatusConstant) string {
+	switch status {
+	case protocol.StatusWarning:
+		return "warning"
+	case protocol.StatusError, protocol.StatusRewind:
+		return "error"
+	case protocol.StatusStop, protocol.StatusStall:
+		return "critical"
+	default:
+		return "info"
+	}
+}
+
+// buildPagerDutyEvent assembles the event for the status. A resolve event only carries the dedup key
+func (module *PagerDutyNotifier) buildPagerDutyEvent(status *protocol.ConsumerGroupStatus, eventID string, startTime time.Time, stateGood bool) *pagerDutyEvent {
+	event := &pagerDutyEvent{
+		RoutingKey:  module.routingKey,
+		EventAction: "resolve",
+		DedupKey:    status.Cluster + "/" + status.Group,
+		Client:      "Burrow",
+	}
+	if stateGood {
+		return event
+	}
+
+	details := map[string]interface{}{
+		"status":          status.Status.String(),
+		"complete":        status.Complete,
+		"total_lag":       status.TotalLag,
+		"partition_count": status.TotalPartitions,
+		"start":           startTime.UTC().Format(time.RFC3339),
+		"id":              eventID,
+	}
+	if status.Maxlag != nil {
+		details["maxlag"] = status.Maxlag
+	}
+	for key, value := range module.extras {
+		details[key] = value
+	}
+
+	event.EventAction = "trigger"
+	event.Payload = &pagerDutyPayload{
+		Summary:       "Consumer group " + status.Group + " on cluster " + status.Cluster + " is " + status.Status.String(),
+		Source:        module.source,
+		Severity:      pagerDutySeverity(status.Status),
+		Timestamp:     time.Now().UTC().Format(time.RFC3339),
+		Component:     status.Group,
+		Group:         status.Cluster,
+		Class:         "consumer lag",
+		CustomDetails: details,
+	}
+	return event
+}
+
+// Notify sends a single event to the configured URL. If stateGood is true, a resolve event is sent for the group.
+// Otherwise, a trigger event is sent with the severity for the group status, and the details of the status and the
+// configured extras.
+func (module *PagerDutyNotifier) Notify(status *protocol.ConsumerGroupStatus, eventID string, startTime time.Time, stateGood bool) {
+	logger := module.Log.With(
+		zap.String("cluster", status.Cluster),
+		zap.String("group", status.Group),
+		zap.String("id", eventID),
+		zap.String("status", status.Status.String()),
+	)
+
+	body, err := json.Marshal(module.buildPagerDutyEvent(status, eventID, startTime, stateGood))
+	if err != nil {
+		logger.Error("failed to encode event", zap.Error(err))
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, module.url, bytes.NewReader(body))
+	if err != nil {
+		logger.Error("failed to create request", zap.Error(err))
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	statusCode, err := module.retry.send(module.httpClient, req, logger)
+	if err != nil {
+		logger.Error("failed to send", zap.Error(err))
+		return
+	}
+
+	// The Events API responds with 202 when the event is accepted
+	if (statusCode >= 200) && (statusCode <= 299) {
+		logger.Debug("sent")
+	} else {
+		logger.Error("failed to send", zap.Int("response", statusCode))
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package notifier
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/linkedin/Burrow/core/protocol"
+)
+
+func fixturePagerDutyNotifier() *PagerDutyNotifier {
+	module := PagerDutyNotifier{
+		Log: zap.NewNop(),
+	}
+	module.App = &protocol.ApplicationContext{}
+
+	viper.Reset()
+	viper.Set("notifier.test.class-name", "pagerduty")
+	viper.Set("notifier.test.routing-key", "testroutingkey")
+
+	return &module
+}
+
+func TestPagerDutyNotifier_ImplementsModule(t *testing.T) {
+	assert.Implements(t, (*protocol.Module)(nil), new(PagerDutyNotifier))
+	assert.Implements(t, (*Module)(nil), new(PagerDutyNotifier))
+	assert.Implements(t, (*templateModule)(nil), new(PagerDutyNotifier))
+	assert.False(t, new(PagerDutyNotifier).usesTemplates(), "Expected the templates not to be used")
+}
+
+func TestPagerDutyNotifier_Configure(t *testing.T) {
+	module := fixturePagerDutyNotifier()
+
+	module.Configure("test", "notifier.test")
+	assert.NotNil(t, module.httpClient, "Expected httpClient to be set with a client object")
+	assert.Equal(t, "https://events.pagerduty.com/v2/enqueue", module.url)
+	assert.True(t, viper.GetBool("notifier.test.send-close"), "Expected send-close to default to true")
+}
+
+func TestPagerDutyNotifier_Bad_Configuration(t *testing.T) {
+	module := fixturePagerDutyNotifier()
+	viper.Set("notifier.test.routing-key", "")
+
+	assert.Panics(t, func() { module.Configure("test", "notifier.test") }, "PagerDuty notifier needs a supplied routing-key")
+}
+
+func TestPagerDutyNotifier_pagerDutySeverity(t *testing.T) {
+	assert.Equal(t, "info", pagerDutySeverity(protocol.StatusOK))
+	assert.Equal(t, "warning", pagerDutySeverity(protocol.StatusWarning))
+	assert.Equal(t, "error", pagerDutySeverity(protocol.StatusError))
+	assert.Equal(t, "error", pagerDutySeverity(protocol.StatusRewind))
+	assert.Equal(t, "critical", pagerDutySeverity(protocol.StatusStop))
+	assert.Equal(t, "critical", pagerDutySeverity(protocol.StatusStall))
+}
+
+func TestPagerDutyNotifier_Notify(t *testing.T) {
+	var events []map[string]interface{}
+	requestHandler := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		var event map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		events = append(events, event)
+		w.WriteHeader(http.StatusAccepted)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(requestHandler))
+	defer ts.Close()
+
+	module := fixturePagerDutyNotifier()
+	viper.Set("notifier.test.url", ts.URL)
+	viper.Set("notifier.test.source", "burrow01")
+	module.extras = map[string]string{"team": "data"}
+	module.Configure("test", "notifier.test")
+
+	status := &protocol.ConsumerGroupStatus{
+		Status:   protocol.StatusStall,
+		Cluster:  "testcluster",
+		Group:    "testgroup",
+		TotalLag: 100,
+	}
+	module.Notify(status, "testidstring", time.Unix(1637, 0), false)
+	status.Status = protocol.StatusOK
+	module.Notify(status, "testidstring", time.Unix(1637, 0), true)
+
+	if !assert.Len(t, events, 2, "Expected a trigger and a resolve event") {
+		return
+	}
+	assert.Equal(t, "testroutingkey", events[0]["routing_key"])
+	assert.Equal(t, "trigger", events[0]["event_action"])
+	assert.Equal(t, "testcluster/testgroup", events[0]["dedup_key"])
+	payload := events[0]["payload"].(map[string]interface{})
+	assert.Equal(t, "Consumer group testgroup on cluster testcluster is STALL", payload["summary"])
+	assert.Equal(t, "critical", payload["severity"])
+	assert.Equal(t, "burrow01", payload["source"])
+	details := payload["custom_details"].(map[string]interface{})
+	assert.Equal(t, "testidstring", details["id"])
+	assert.Equal(t, float64(100), details["total_lag"])
+	assert.Equal(t, "data", details["team"])
+
+	assert.Equal(t, "resolve", events[1]["event_action"])
+	assert.Equal(t, "testcluster/testgroup", events[1]["dedup_key"])
+	assert.NotContains(t, events[1], "payload", "Expected no payload for a resolve event")
+}
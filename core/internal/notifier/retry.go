@@ -0,0 +1,112 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package notifier
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// The longest time to wait between two attempts to send a notification, no matter how many retries are configured
+const maxRetryBackoff = 60 * time.Second
+
+// httpRetry retries the requests of the HTTP-based notifiers that fail with an error, or with a 429 or 5xx response,
+// up to max times. The wait before each retry doubles, starting at backoff, and is randomized so that the retries for
+// many groups do not all arrive at the same time.
+type httpRetry struct {
+	max     int
+	backoff time.Duration
+	sleep   func(time.Duration)
+}
+
+// newHTTPRetry returns the retry configuration of the notifier. Unless configured, a request is retried up to 3 times,
+// waiting 1 second before the first retry. If retry-max or retry-backoff is negative, this func will panic.
+func newHTTPRetry(name, configRoot string) *httpRetry {
+	viper.SetDefault(configRoot+".retry-max", 3)
+	viper.SetDefault(configRoot+".retry-backoff", 1)
+
+	retry := &httpRetry{
+		max:     viper.GetInt(configRoot + ".retry-max"),
+		backoff: time.Duration(viper.GetInt64(configRoot+".retry-backoff")) * time.Second,
+		sleep:   time.Sleep,
+	}
+	if (retry.max < 0) || (retry.backoff < 0) {
+		panic("Notifier '" + name + "' must not have a negative retry-max or retry-backoff")
+	}
+	return retry
+}
+
+// delay returns the randomized wait before the numbered retry, starting at 1
+func (retry *httpRetry) delay(attempt int) time.Duration {
+	delay := retry.backoff
+	for i := 1; (i < attempt) && (delay < maxRetryBackoff); i++ {
+		delay *= 2
+	}
+	if delay > maxRetryBackoff {
+		delay = maxRetryBackoff
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+func retryableStatus(statusCode int) bool {
+	return (statusCode == http.StatusTooManyRequests) || (statusCode >= 500)
+}
+
+// send makes the request with the client, retrying it as configured, and returns the status code of the last
+// response. The response body is discarded. If the last attempt failed without a response, the error is returned
+// instead. The request must have been created with a body that can be read again (such as a bytes.Buffer).
+func (retry *httpRetry) send(client *http.Client, req *http.Request, logger *zap.Logger) (int, error) {
+	maxRetries := 0
+	if retry != nil {
+		maxRetries = retry.max
+	}
+
+	var statusCode int
+	var err error
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			if req.GetBody != nil {
+				req.Body, err = req.GetBody()
+				if err != nil {
+					return 0, err
+				}
+			} else if req.Body != nil {
+				return 0, errors.New("request body cannot be sent again")
+			}
+			retry.sleep(retry.delay(attempt))
+		}
+
+		var resp *http.Response
+		statusCode = 0
+		resp, err = client.Do(req)
+		if err == nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			statusCode = resp.StatusCode
+			if !retryableStatus(statusCode) {
+				return statusCode, nil
+			}
+		}
+
+		if attempt >= maxRetries {
+			return statusCode, err
+		}
+		logger.Warn("failed to send, retrying", zap.Int("attempt", attempt+1), zap.Int("response", statusCode), zap.Error(err))
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package notifier
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestHTTPRetry_newHTTPRetry(t *testing.T) {
+	viper.Reset()
+	retry := newHTTPRetry("test", "notifier.test")
+	assert.Equal(t, 3, retry.max, "Expected retry-max to default to 3")
+	assert.Equal(t, time.Second, retry.backoff, "Expected retry-backoff to default to 1 second")
+
+	viper.Set("notifier.test.retry-max", -1)
+	assert.Panics(t, func() { newHTTPRetry("test", "notifier.test") }, "The code did not panic")
+}
+
+func TestHTTPRetry_delay(t *testing.T) {
+	retry := &httpRetry{max: 10, backoff: 2 * time.Second}
+
+	for attempt, expected := range map[int]time.Duration{1: 2 * time.Second, 2: 4 * time.Second, 3: 8 * time.Second, 10: maxRetryBackoff} {
+		for i := 0; i < 20; i++ {
+			delay := retry.delay(attempt)
+			assert.GreaterOrEqualf(t, delay, expected/2, "Expected delay for retry %v to be at least %v, not %v", attempt, expected/2, delay)
+			assert.LessOrEqualf(t, delay, expected, "Expected delay for retry %v to be at most %v, not %v", attempt, expected, delay)
+		}
+	}
+}
+
+func TestHTTPRetry_send(t *testing.T) {
+	tests := []struct {
+		responses      []int
+		expectAttempts int
+		expectStatus   int
+	}{
+		{[]int{http.StatusOK}, 1, http.StatusOK},
+		{[]int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusAccepted}, 3, http.StatusAccepted},
+		{[]int{http.StatusBadRequest}, 1, http.StatusBadRequest},
+		{[]int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway, http.StatusOK}, 4, http.StatusBadGateway},
+	}
+
+	for i, testSet := range tests {
+		var bodies []string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			bodies = append(bodies, string(body))
+			w.WriteHeader(testSet.responses[len(bodies)-1])
+		}))
+
+		var sleeps []time.Duration
+		retry := &httpRetry{
+			max:     3,
+			backoff: time.Second,
+			sleep:   func(d time.Duration) { sleeps = append(sleeps, d) },
+		}
+		req, _ := http.NewRequest(http.MethodPost, ts.URL, bytes.NewBufferString("testbody"))
+		statusCode, err := retry.send(http.DefaultClient, req, zap.NewNop())
+		ts.Close()
+
+		assert.NoErrorf(t, err, "Test %v: Expected no error", i)
+		assert.Equalf(t, testSet.expectStatus, statusCode, "Test %v: Expected status %v, not %v", i, testSet.expectStatus, statusCode)
+		assert.Lenf(t, bodies, testSet.expectAttempts, "Test %v: Expected %v attempts", i, testSet.expectAttempts)
+		assert.Lenf(t, sleeps, testSet.expectAttempts-1, "Test %v: Expected a wait before each retry", i)
+		for _, body := range bodies {
+			assert.Equalf(t, "testbody", body, "Test %v: Expected the body to be sent on every attempt", i)
+		}
+	}
+}
+
+func TestHTTPRetry_send_Error(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.Close()
+
+	attempts := 0
+	retry := &httpRetry{
+		max:     2,
+		backoff: time.Second,
+		sleep:   func(d time.Duration) { attempts++ },
+	}
+	req, _ := http.NewRequest(http.MethodPost, ts.URL, bytes.NewBufferString("testbody"))
+	statusCode, err := retry.send(http.DefaultClient, req, zap.NewNop())
+
+	assert.Error(t, err, "Expected an error when the server is down")
+	assert.Equal(t, 0, statusCode)
+	assert.Equal(t, 2, attempts, "Expected the request to be retried twice")
+}
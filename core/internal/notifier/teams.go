@@ -0,0 +1,282 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/linkedin/Burrow/core/protocol"
+)
+
+// TeamsNotifier is a module which sends notifications of consumer group status to a Microsoft Teams channel as an
+// Adaptive Card. The card is colored by the group status, and lists the group, cluster, and the partition with the
+// most lag. The output of the configured template is included as the body of the card. The same message is accepted
+// by both classic incoming webhooks and Workflows ("Post to a channel when a webhook request is received") URLs.
+type TeamsNotifier struct {
+	// App is a pointer to the application context. This stores the channel to the storage subsystem
+	App *protocol.ApplicationContext
+
+	// Log is a logger that has been configured for this module to use. Normally, this means it has been set up with
+	// fields that are appropriate to identify this coordinator
+	Log *zap.Logger
+
+	name           string
+	groupAllowlist *regexp.Regexp
+	groupDenylist  *regexp.Regexp
+	extras         map[string]string
+	url            string
+	templateOpen   *template.Template
+	templateClose  *template.Template
+
+	httpClient *http.Client
+	retry      *httpRetry
+	auth       *httpAuth
+}
+
+type teamsMessage struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+type teamsAttachment struct {
+	ContentType string            `json:"contentType"`
+	ContentURL  *string           `json:"contentUrl"`
+	Content     teamsAdaptiveCard `json:"content"`
+}
+
+type teamsAdaptiveCard struct {
+	Schema  string        `json:"$schema"`
+	Type    string        `json:"type"`
+	Version string        `json:"version"`
+	Body    []interface{} `json:"body"`
+	MSTeams struct {
+		Width string `json:"width"`
+	} `json:"msteams"`
+}
+
+type teamsContainer struct {
+	Type  string        `json:"type"`
+	Style string        `json:"style"`
+	Bleed bool          `json:"bleed"`
+	Items []interface{} `json:"items"`
+}
+
+type teamsTextBlock struct {
+	Type   string `json:"type"`
+	Text   string `json:"text"`
+	Size   string `json:"size,omitempty"`
+	Weight string `json:"weight,omitempty"`
+	Wrap   bool   `json:"wrap"`
+}
+
+type teamsFactSet struct {
+	Type  string      `json:"type"`
+	Facts []teamsFact `json:"facts"`
+}
+
+type teamsFact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+// Configure validates the configuration of the teams notifier. At minimum, there must be a url specified for the
+// incoming webhook or workflow. If this is missing, this func will panic with an explanatory message. As with the http
+// notifier, a timeout and keepalive for the HTTP client can be configured, as well as an extra CA, noverify, retries,
+// and the username and password, or authorization, to send with each request.
+func (module *TeamsNotifier) Configure(name, configRoot string) {
+	module.name = name
+
+	module.url = viper.GetString(configRoot + ".url")
+	if module.url == "" {
+		module.Log.Panic("no url specified")
+		panic(errors.New("configuration error"))
+	}
+
+	// Set defaults for module-specific configs if needed
+	viper.SetDefault(configRoot+".timeout", 5)
+	viper.SetDefault(configRoot+".keepalive", 300)
+
+	module.retry = newHTTPRetry(name, configRoot)
+	module.auth = newHTTPAuth(name, configRoot)
+	module.httpClient = &http.Client{
+		Timeout: viper.GetDuration(configRoot+".timeout") * time.Second,
+		Transport: &http.Transport{
+			Dial: (&net.Dialer{
+				KeepAlive: viper.GetDuration(configRoot+".keepalive") * time.Second,
+			}).Dial,
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: buildHTTPTLSConfig(viper.GetString(configRoot+".extra-ca"), viper.GetBool(configRoot+".noverify")),
+		},
+	}
+}
+
+// Start is a no-op for the teams notifier. It always returns no error
+func (module *TeamsNotifier) Start() error {
+	return nil
+}
+
+// Stop is a no-op for the teams notifier. It always returns no error
+func (module *TeamsNotifier) Stop() error {
+	return nil
+}
+
+// GetName returns the configured name of this module
+func (module *TeamsNotifier) GetName() string {
+	return module.name
+}
+
+// GetGroupAllowlist returns the compiled group allowlist (or nil, if there is not one)
+func (module *TeamsNotifier) GetGroupAllowlist() *regexp.Regexp {
+	return module.groupAllowlist
+}
+
+// GetGroupDenylist returns the compiled group denylist (or nil, if there is not one)
+func (module *TeamsNotifier) GetGroupDenylist() *regexp.Regexp {
+	return module.groupDenylist
+}
+
+// GetLogger returns the configured zap.Logger for this notifier
+func (module *TeamsNotifier) GetLogger() *zap.Logger {
+	return module.Log
+}
+
+// AcceptConsumerGroup has no additional function for the teams notifier, and so always returns true
+func (module *TeamsNotifier) AcceptConsumerGroup(status *protocol.ConsumerGroupStatus) bool {
+	return true
+}
+
+// teamsStyle returns the Adaptive Card container style used to color the card for the group status
+func teamsStyle(status protocol.StatusConstant, stateGood bool) string {
+	switch {
+	case stateGood || status == protocol.StatusOK:
+		return "good"
+	case status == protocol.StatusWarning:
+		return "warning"
+	default:
+		return "attention"
+	}
+}
+
+// buildTeamsMessage assembles the Adaptive Card for the status, with text (the template output) as the body
+func buildTeamsMessage(status *protocol.ConsumerGroupStatus, eventID string, startTime time.Time, stateGood bool, text string) *teamsMessage {
+	title := "Consumer group " + status.Group + " is " + status.Status.String()
+	if stateGood {
+		title = "Consumer group " + status.Group + " has recovered"
+	}
+
+	facts := []teamsFact{
+		{Title: "Cluster", Value: status.Cluster},
+		{Title: "Group", Value: status.Group},
+		{Title: "Status", Value: status.Status.String()},
+		{Title: "Total lag", Value: strconv.FormatUint(status.TotalLag, 10)},
+	}
+	if status.Maxlag != nil {
+		facts = append(facts, teamsFact{
+			Title: "Worst partition",
+			Value: status.Maxlag.Topic + ":" + strconv.FormatInt(int64(status.Maxlag.Partition), 10) +
+				" (" + status.Maxlag.Status.String() + ", lag " + strconv.FormatUint(status.Maxlag.CurrentLag, 10) + ")",
+		})
+	}
+	facts = append(facts,
+		teamsFact{Title: "Started", Value: startTime.UTC().Format(time.RFC3339)},
+		teamsFact{Title: "Event ID", Value: eventID},
+	)
+
+	items := []interface{}{
+		teamsTextBlock{Type: "TextBlock", Text: title, Size: "Medium", Weight: "Bolder", Wrap: true},
+	}
+	if text != "" {
+		items = append(items, teamsTextBlock{Type: "TextBlock", Text: text, Wrap: true})
+	}
+	items = append(items, teamsFactSet{Type: "FactSet", Facts: facts})
+
+	card := teamsAdaptiveCard{
+		Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+		Type:    "AdaptiveCard",
+		Version: "1.4",
+		Body: []interface{}{
+			teamsContainer{Type: "Container", Style: teamsStyle(status.Status, stateGood), Bleed: true, Items: items},
+		},
+	}
+	card.MSTeams.Width = "Full"
+
+	return &teamsMessage{
+		Type: "message",
+		Attachments: []teamsAttachment{
+			{ContentType: "application/vnd.microsoft.card.adaptive", Content: card},
+		},
+	}
+}
+
+// Notify posts a single Adaptive Card to the configured URL. The status, eventID, and startTime are all passed to the
+// template for compiling the text of the card. If stateGood is true, the "close" template is used, if configured.
+// Otherwise, the "open" template is used.
+func (module *TeamsNotifier) Notify(status *protocol.ConsumerGroupStatus, eventID string, startTime time.Time, stateGood bool) {
+	logger := module.Log.With(
+		zap.String("cluster", status.Cluster),
+		zap.String("group", status.Group),
+		zap.String("id", eventID),
+		zap.String("status", status.Status.String()),
+	)
+
+	tmpl := module.templateOpen
+	if stateGood {
+		tmpl = module.templateClose
+	}
+
+	var text string
+	if tmpl != nil {
+		bytesToSend, err := executeTemplate(tmpl, module.extras, status, eventID, startTime)
+		if err != nil {
+			logger.Error("failed to assemble message", zap.Error(err))
+			return
+		}
+		text = strings.TrimSpace(bytesToSend.String())
+	}
+
+	body, err := json.Marshal(buildTeamsMessage(status, eventID, startTime, stateGood, text))
+	if err != nil {
+		logger.Error("failed to encode message", zap.Error(err))
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, module.url, bytes.NewReader(body))
+	if err != nil {
+		logger.Error("failed to create request", zap.Error(err))
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	module.auth.apply(req)
+
+	statusCode, err := module.retry.send(module.httpClient, req, logger)
+	if err != nil {
+		logger.Error("failed to send", zap.Error(err))
+		return
+	}
+
+	// Classic webhooks respond with 200, and Workflows with 202
+	if (statusCode >= 200) && (statusCode <= 299) {
+		logger.Debug("sent")
+	} else {
+		logger.Error("failed to send", zap.Int("response", statusCode))
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package notifier
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/linkedin/Burrow/core/protocol"
+)
+
+func fixtureTeamsNotifier() *TeamsNotifier {
+	module := TeamsNotifier{
+		Log: zap.NewNop(),
+	}
+	module.App = &protocol.ApplicationContext{}
+
+	viper.Reset()
+	viper.Set("notifier.test.class-name", "teams")
+	viper.Set("notifier.test.url", "url")
+	viper.Set("notifier.test.template-open", "template_open")
+
+	return &module
+}
+
+func TestTeamsNotifier_ImplementsModule(t *testing.T) {
+	assert.Implements(t, (*protocol.Module)(nil), new(TeamsNotifier))
+	assert.Implements(t, (*Module)(nil), new(TeamsNotifier))
+}
+
+func TestTeamsNotifier_Configure(t *testing.T) {
+	module := fixtureTeamsNotifier()
+
+	module.Configure("test", "notifier.test")
+	assert.NotNil(t, module.httpClient, "Expected httpClient to be set with a client object")
+}
+
+func TestTeamsNotifier_Bad_Configuration(t *testing.T) {
+	module := fixtureTeamsNotifier()
+	viper.Set("notifier.test.url", "")
+
+	assert.Panics(t, func() { module.Configure("test", "notifier.test") }, "Teams notifier needs a supplied url")
+}
+
+func TestTeamsNotifier_Configure_Auth(t *testing.T) {
+	module := fixtureTeamsNotifier()
+	viper.Set("notifier.test.authorization", "Bearer testtoken")
+	module.Configure("test", "notifier.test")
+
+	req, _ := http.NewRequest(http.MethodPost, "http://example.com", http.NoBody)
+	module.auth.apply(req)
+	assert.Equal(t, "Bearer testtoken", req.Header.Get("Authorization"), "Expected the authorization to be set on the request")
+}
+
+func TestTeamsNotifier_teamsStyle(t *testing.T) {
+	assert.Equal(t, "good", teamsStyle(protocol.StatusOK, false))
+	assert.Equal(t, "warning", teamsStyle(protocol.StatusWarning, false))
+	assert.Equal(t, "attention", teamsStyle(protocol.StatusError, false))
+	assert.Equal(t, "good", teamsStyle(protocol.StatusError, true), "Expected a closed event to be good")
+}
+
+func TestTeamsNotifier_Notify(t *testing.T) {
+	var messageType string
+	var content map[string]interface{}
+	requestHandler := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		var raw struct {
+			Type        string `json:"type"`
+			Attachments []struct {
+				ContentType string                 `json:"contentType"`
+				Content     map[string]interface{} `json:"content"`
+			} `json:"attachments"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&raw))
+		messageType = raw.Type
+		if assert.Len(t, raw.Attachments, 1) {
+			assert.Equal(t, "application/vnd.microsoft.card.adaptive", raw.Attachments[0].ContentType)
+			content = raw.Attachments[0].Content
+		}
+
+		// Workflows URLs accept the message with a 202
+		w.WriteHeader(http.StatusAccepted)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(requestHandler))
+	defer ts.Close()
+
+	module := fixtureTeamsNotifier()
+	viper.Set("notifier.test.url", ts.URL)
+	module.templateOpen, _ = template.New("test").Parse("{{.Group}} needs attention")
+	module.Configure("test", "notifier.test")
+
+	status := &protocol.ConsumerGroupStatus{
+		Status:   protocol.StatusError,
+		Cluster:  "testcluster",
+		Group:    "testgroup",
+		TotalLag: 100,
+		Maxlag: &protocol.PartitionStatus{
+			Topic:      "testtopic",
+			Partition:  3,
+			Status:     protocol.StatusStall,
+			CurrentLag: 80,
+		},
+	}
+	module.Notify(status, "testidstring", time.Unix(1637, 0), false)
+
+	assert.Equal(t, "message", messageType)
+	assert.Equal(t, "AdaptiveCard", content["type"])
+	container := content["body"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "attention", container["style"])
+
+	items := container["items"].([]interface{})
+	assert.Len(t, items, 3, "Expected a title, the template text, and the facts")
+	assert.Equal(t, "Consumer group testgroup is ERR", items[0].(map[string]interface{})["text"])
+	assert.Equal(t, "testgroup needs attention", items[1].(map[string]interface{})["text"])
+
+	facts := make(map[string]string)
+	for _, fact := range items[2].(map[string]interface{})["facts"].([]interface{}) {
+		facts[fact.(map[string]interface{})["title"].(string)] = fact.(map[string]interface{})["value"].(string)
+	}
+	assert.Equal(t, "testcluster", facts["Cluster"])
+	assert.Equal(t, "testgroup", facts["Group"])
+	assert.Equal(t, "100", facts["Total lag"])
+	assert.Equal(t, "testtopic:3 (STALL, lag 80)", facts["Worst partition"])
+	assert.Equal(t, "testidstring", facts["Event ID"])
+}
+
+func TestTeamsNotifier_buildTeamsMessage_Close(t *testing.T) {
+	status := &protocol.ConsumerGroupStatus{
+		Status:  protocol.StatusOK,
+		Cluster: "testcluster",
+		Group:   "testgroup",
+	}
+	message := buildTeamsMessage(status, "testidstring", time.Now(), true, "")
+
+	container := message.Attachments[0].Content.Body[0].(teamsContainer)
+	assert.Equal(t, "good", container.Style)
+	assert.Len(t, container.Items, 2, "Expected no text block when the template is not set")
+	assert.Equal(t, "Consumer group testgroup has recovered", container.Items[0].(teamsTextBlock).Text)
+}
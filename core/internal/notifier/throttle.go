@@ -0,0 +1,181 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package notifier
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/linkedin/Burrow/core/protocol"
+)
+
+// notifyThrottle limits the total number of open notifications that a module sends, across all groups, with a token
+// bucket that holds up to capacity tokens and is refilled at capacity tokens per interval. Notifications that are
+// throttled are recorded, and sent as a single summary per cluster and status at the end of the interval. Once there
+// are no throttled groups for a cluster and status that a summary was sent for, a close summary is sent for it.
+type notifyThrottle struct {
+	lock       sync.Mutex
+	capacity   float64
+	interval   time.Duration
+	tokens     float64
+	lastRefill time.Time
+
+	// If false, the throttled notifications are dropped, and no summaries are sent
+	summarize bool
+
+	// The worst status of each throttled group, by cluster
+	pending map[string]map[string]protocol.StatusConstant
+
+	// The time that the first summary was sent for each cluster and status that has not been closed yet
+	open map[string]map[protocol.StatusConstant]time.Time
+}
+
+// throttleSummary is a summary of the groups that were throttled in one cluster and status. The status of a close
+// summary is OK, and the status that the groups were throttled in is in throttled.
+type throttleSummary struct {
+	status    *protocol.ConsumerGroupStatus
+	throttled protocol.StatusConstant
+	start     time.Time
+	close     bool
+}
+
+func newNotifyThrottle(capacity int, interval time.Duration) *notifyThrottle {
+	return &notifyThrottle{
+		capacity:  float64(capacity),
+		interval:  interval,
+		tokens:    float64(capacity),
+		summarize: true,
+		pending:   make(map[string]map[string]protocol.StatusConstant),
+		open:      make(map[string]map[protocol.StatusConstant]time.Time),
+	}
+}
+
+// allow takes a token from the bucket, refilling it first for the time since the last call. If there are no tokens
+// left, the notification for the group is recorded for the next summary, and false is returned.
+func (throttle *notifyThrottle) allow(status *protocol.ConsumerGroupStatus, now time.Time) bool {
+	throttle.lock.Lock()
+	defer throttle.lock.Unlock()
+
+	if !throttle.lastRefill.IsZero() {
+		throttle.tokens += throttle.capacity * float64(now.Sub(throttle.lastRefill)) / float64(throttle.interval)
+		if throttle.tokens > throttle.capacity {
+			throttle.tokens = throttle.capacity
+		}
+	}
+	throttle.lastRefill = now
+
+	if throttle.tokens >= 1 {
+		throttle.tokens--
+		return true
+	}
+	if !throttle.summarize {
+		return false
+	}
+
+	groups, ok := throttle.pending[status.Cluster]
+	if !ok {
+		groups = make(map[string]protocol.StatusConstant)
+		throttle.pending[status.Cluster] = groups
+	}
+	if status.Status > groups[status.Group] {
+		groups[status.Group] = status.Status
+	}
+	return false
+}
+
+// summaries returns a summary for each cluster and status of the groups that were throttled since the last call, worst
+// status first, and clears them. Only the groups that acceptGroup returns true for are counted. The Group of each
+// summary describes the groups, such as "12 groups in ERR on cluster local". A close summary, with a count of zero, is
+// returned for each cluster and status that had a summary, but has no throttled groups now.
+func (throttle *notifyThrottle) summaries(now time.Time, acceptGroup func(string) bool) []*throttleSummary {
+	throttle.lock.Lock()
+	defer throttle.lock.Unlock()
+
+	counts := make(map[string]map[protocol.StatusConstant]int)
+	for cluster, groups := range throttle.pending {
+		for group, status := range groups {
+			if !acceptGroup(group) {
+				continue
+			}
+			if _, ok := counts[cluster]; !ok {
+				counts[cluster] = make(map[protocol.StatusConstant]int)
+			}
+			counts[cluster][status]++
+		}
+	}
+	throttle.pending = make(map[string]map[string]protocol.StatusConstant)
+
+	// Every cluster that has throttled groups, or an open summary that may need to be closed
+	clusterSet := make(map[string]bool)
+	for cluster := range counts {
+		clusterSet[cluster] = true
+	}
+	for cluster := range throttle.open {
+		clusterSet[cluster] = true
+	}
+	clusters := make([]string, 0, len(clusterSet))
+	for cluster := range clusterSet {
+		clusters = append(clusters, cluster)
+	}
+	sort.Strings(clusters)
+
+	summaries := make([]*throttleSummary, 0)
+	for _, cluster := range clusters {
+		if _, ok := throttle.open[cluster]; !ok {
+			throttle.open[cluster] = make(map[protocol.StatusConstant]time.Time)
+		}
+		open := throttle.open[cluster]
+
+		statusSet := make(map[protocol.StatusConstant]bool)
+		for status := range counts[cluster] {
+			statusSet[status] = true
+		}
+		for status := range open {
+			statusSet[status] = true
+		}
+		statuses := make([]protocol.StatusConstant, 0, len(statusSet))
+		for status := range statusSet {
+			statuses = append(statuses, status)
+		}
+		sort.Slice(statuses, func(i, j int) bool { return statuses[i] > statuses[j] })
+
+		for _, status := range statuses {
+			count := counts[cluster][status]
+			summary := &throttleSummary{
+				status: &protocol.ConsumerGroupStatus{
+					Cluster:    cluster,
+					Group:      strconv.Itoa(count) + " groups in " + status.String() + " on cluster " + cluster,
+					Status:     status,
+					Complete:   1.0,
+					Partitions: make([]*protocol.PartitionStatus, 0),
+				},
+				throttled: status,
+			}
+			if count == 0 {
+				summary.status.Status = protocol.StatusOK
+				summary.start = open[status]
+				summary.close = true
+				delete(open, status)
+			} else {
+				if _, ok := open[status]; !ok {
+					open[status] = now
+				}
+				summary.start = open[status]
+			}
+			summaries = append(summaries, summary)
+		}
+		if len(open) == 0 {
+			delete(throttle.open, cluster)
+		}
+	}
+	return summaries
+}
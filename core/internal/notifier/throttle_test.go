@@ -0,0 +1,113 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package notifier
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/linkedin/Burrow/core/protocol"
+)
+
+func TestNotifyThrottle_allow(t *testing.T) {
+	throttle := newNotifyThrottle(2, 60*time.Second)
+	now := time.Now()
+	status := &protocol.ConsumerGroupStatus{Cluster: "testcluster", Group: "testgroup", Status: protocol.StatusError}
+
+	assert.True(t, throttle.allow(status, now), "Expected first notification to be allowed")
+	assert.True(t, throttle.allow(status, now), "Expected second notification to be allowed")
+	assert.False(t, throttle.allow(status, now), "Expected third notification to be throttled")
+
+	// Half the interval refills one token
+	assert.True(t, throttle.allow(status, now.Add(30*time.Second)), "Expected notification to be allowed after refill")
+	assert.False(t, throttle.allow(status, now.Add(30*time.Second)), "Expected notification to be throttled again")
+
+	// The bucket never holds more than its capacity
+	later := now.Add(time.Hour)
+	assert.True(t, throttle.allow(status, later), "Expected notification to be allowed after a long time")
+	assert.True(t, throttle.allow(status, later), "Expected notification to be allowed after a long time")
+	assert.False(t, throttle.allow(status, later), "Expected bucket to be capped at its capacity")
+}
+
+func TestNotifyThrottle_summaries(t *testing.T) {
+	throttle := newNotifyThrottle(1, 60*time.Second)
+	now := time.Now()
+	assert.True(t, throttle.allow(&protocol.ConsumerGroupStatus{Cluster: "cluster1", Group: "sent", Status: protocol.StatusError}, now))
+
+	throttled := []*protocol.ConsumerGroupStatus{
+		{Cluster: "cluster1", Group: "group1", Status: protocol.StatusError},
+		{Cluster: "cluster1", Group: "group2", Status: protocol.StatusWarning},
+		{Cluster: "cluster1", Group: "group3", Status: protocol.StatusError},
+		{Cluster: "cluster2", Group: "group1", Status: protocol.StatusWarning},
+		// The same group again is counted once, at its worst status
+		{Cluster: "cluster1", Group: "group2", Status: protocol.StatusError},
+		{Cluster: "cluster1", Group: "group1", Status: protocol.StatusWarning},
+	}
+	for _, status := range throttled {
+		assert.False(t, throttle.allow(status, now), "Expected notification for %v to be throttled", status.Group)
+	}
+
+	acceptAll := func(string) bool { return true }
+	summaries := throttle.summaries(now, acceptAll)
+	assert.Len(t, summaries, 2, "Expected one summary for each cluster and status")
+	assert.Equal(t, "cluster1", summaries[0].status.Cluster)
+	assert.Equal(t, protocol.StatusError, summaries[0].status.Status)
+	assert.Equal(t, "3 groups in ERR on cluster cluster1", summaries[0].status.Group)
+	assert.Equal(t, now, summaries[0].start)
+	assert.False(t, summaries[0].close)
+	assert.Equal(t, "cluster2", summaries[1].status.Cluster)
+	assert.Equal(t, protocol.StatusWarning, summaries[1].status.Status)
+	assert.Equal(t, "1 groups in WARN on cluster cluster2", summaries[1].status.Group)
+
+	// The next summary for the same cluster and status keeps the start time, and the others are closed
+	later := now.Add(time.Minute)
+	assert.False(t, throttle.allow(&protocol.ConsumerGroupStatus{Cluster: "cluster1", Group: "group1", Status: protocol.StatusError}, now))
+	summaries = throttle.summaries(later, acceptAll)
+	assert.Len(t, summaries, 2, "Expected a summary and a close")
+	assert.Equal(t, "1 groups in ERR on cluster cluster1", summaries[0].status.Group)
+	assert.Equal(t, now, summaries[0].start, "Expected the start time of the first summary")
+	assert.False(t, summaries[0].close)
+	assert.Equal(t, "cluster2", summaries[1].status.Cluster)
+	assert.Equal(t, protocol.StatusOK, summaries[1].status.Status)
+	assert.Equal(t, protocol.StatusWarning, summaries[1].throttled)
+	assert.Equal(t, "0 groups in WARN on cluster cluster2", summaries[1].status.Group)
+	assert.Equal(t, now, summaries[1].start)
+	assert.True(t, summaries[1].close, "Expected the summary with no throttled groups to be closed")
+
+	summaries = throttle.summaries(later, acceptAll)
+	assert.Len(t, summaries, 1, "Expected only the close for cluster1")
+	assert.True(t, summaries[0].close)
+	assert.Empty(t, throttle.summaries(later, acceptAll), "Expected nothing once all the summaries are closed")
+}
+
+func TestNotifyThrottle_summaries_AcceptGroup(t *testing.T) {
+	throttle := newNotifyThrottle(1, time.Hour)
+	now := time.Now()
+	throttle.allow(&protocol.ConsumerGroupStatus{Cluster: "cluster1", Group: "sent", Status: protocol.StatusError}, now)
+	throttle.allow(&protocol.ConsumerGroupStatus{Cluster: "cluster1", Group: "group1", Status: protocol.StatusError}, now)
+	throttle.allow(&protocol.ConsumerGroupStatus{Cluster: "cluster1", Group: "dropped", Status: protocol.StatusError}, now)
+
+	summaries := throttle.summaries(now, func(group string) bool { return group != "dropped" })
+	if assert.Len(t, summaries, 1) {
+		assert.Equal(t, "1 groups in ERR on cluster cluster1", summaries[0].status.Group, "Expected the rejected group not to be counted")
+	}
+}
+
+func TestNotifyThrottle_NoSummaries(t *testing.T) {
+	throttle := newNotifyThrottle(1, time.Hour)
+	throttle.summarize = false
+	now := time.Now()
+	status := &protocol.ConsumerGroupStatus{Cluster: "cluster1", Group: "group1", Status: protocol.StatusError}
+	assert.True(t, throttle.allow(status, now))
+	assert.False(t, throttle.allow(status, now), "Expected the notification to be throttled")
+	assert.Empty(t, throttle.summaries(now, func(string) bool { return true }), "Expected no summaries")
+}
@@ -13,9 +13,11 @@
 //
 // # Modules
 //
-// Currently, only one module is provided:
+// Currently, the following modules are provided:
 //
 // * inmemory - Store all information in a set of in-memory maps
+//
+// * redis - Store all information in memory, as inmemory does, and persist it to Redis so it survives a restart
 package storage
 
 import (
@@ -69,6 +71,16 @@ func getModuleForClass(app *protocol.ApplicationContext, moduleName, className s
 				zap.String("name", moduleName),
 			),
 		}
+	case "redis":
+		return &RedisStorage{
+			App: app,
+			Log: app.Logger.With(
+				zap.String("type", "module"),
+				zap.String("coordinator", "storage"),
+				zap.String("class", className),
+				zap.String("name", moduleName),
+			),
+		}
 	default:
 		panic("Unknown storage className provided: " + className)
 	}
@@ -126,6 +138,13 @@ func (sc *Coordinator) Start() error {
 	return nil
 }
 
+// ReloadFilters calls the configured storage module's underlying ReloadFilters func with the provided configuration,
+// if the module supports it, and returns any error to the caller.
+func (sc *Coordinator) ReloadFilters(config *viper.Viper) error {
+	sc.Log.Info("reloading filters")
+	return helpers.ReloadCoordinatorModuleFilters(sc.modules, config, nil)
+}
+
 // Stop calls the configured storage module's underlying Stop func. It is expected that the module Stop will not return
 // until the module has been completely stopped. While an error can be returned, this func always returns no error, as
 // a failure during stopping is not a critical failure
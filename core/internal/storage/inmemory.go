@@ -10,16 +10,24 @@
 package storage
 
 import (
+	"container/heap"
 	"container/ring"
+	"errors"
 	"math/rand"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
+	"unsafe"
 
 	"github.com/OneOfOne/xxhash"
+	"github.com/spf13/cast"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 
+	"github.com/linkedin/Burrow/core/internal/helpers"
 	"github.com/linkedin/Burrow/core/internal/httpserver"
 	"github.com/linkedin/Burrow/core/protocol"
 )
@@ -37,30 +45,55 @@ type InMemoryStorage struct {
 	Log *zap.Logger
 
 	name        string
+	configRoot  string
 	intervals   int
 	numWorkers  int
 	expireGroup int64
 	minDistance int64
 	queueDepth  int
 
+	// How often to remove the groups that have expired, in seconds (0 to only remove them when they are fetched)
+	expireGroupRefresh int64
+
+	// The number of intervals for clusters that do not use the default
+	clusterIntervals map[string]int
+
+	// How long a queue can have requests waiting without one being handled before storage is unhealthy, in seconds.
+	// The main loop and each worker beat when they receive a request, and when they have handled it
+	healthThreshold int64
+	mainBeat        protocol.Heartbeat
+	workerBeats     []protocol.Heartbeat
+
 	requestChannel chan *protocol.StorageRequest
+	quitChannel    chan struct{}
 	workersRunning sync.WaitGroup
 	mainRunning    sync.WaitGroup
 	offsets        map[string]clusterOffsets
+	offsetsLock    sync.RWMutex
 	groupAllowlist *regexp.Regexp
 	groupDenylist  *regexp.Regexp
+	neverExpire    *regexp.Regexp
+	filterLock     sync.RWMutex
 	workers        []chan *protocol.StorageRequest
+
+	// If set, this is called by the worker after each request has been handled. The redis module uses it to find the
+	// groups and clusters that have changed since they were last persisted
+	requestHandled func(*protocol.StorageRequest)
 }
 
 type brokerOffset struct {
 	Offset    int64
 	Timestamp int64
+	Regressed bool
 }
 
 type consumerPartition struct {
 	offsets  *ring.Ring
 	owner    string
 	clientID string
+
+	// The time lag for the partition in milliseconds, as last set by the cluster module
+	timeLag int64
 }
 
 type consumerGroup struct {
@@ -68,12 +101,42 @@ type consumerGroup struct {
 	lock       *sync.RWMutex
 	topics     map[string][]*consumerPartition
 	lastCommit int64
+
+	// The group generation from the metadata, the time it was set (zero if it has never been seen), and the number of
+	// generation changes seen since then
+	generation     int32
+	generationTime int64
+	rebalances     uint64
+
+	// The number of members in the group when it was last described, and the time it was described (zero if it has
+	// never been described)
+	memberCount int32
+	membersTime int64
+}
+
+type clusterBrokers struct {
+	controllerID int32
+	brokerCount  int32
+	timestamp    int64
+
+	// The newest timestamp of a broker offset for any partition
+	lastUpdate int64
 }
 
 type clusterOffsets struct {
 	broker   map[string][]*ring.Ring
 	consumer map[string]*consumerGroup
 
+	// The size of the offset rings for this cluster
+	intervals int
+
+	// The controller and number of brokers from the cluster metadata. This is held under the brokerLock
+	brokers *clusterBrokers
+
+	// The oldest offset retained by the brokers for each partition, by topic, if the cluster module fetches them. This
+	// is held under the brokerLock
+	logStart map[string]map[int32]int64
+
 	// This lock is used when modifying broker topics or offsets
 	brokerLock *sync.RWMutex
 
@@ -114,6 +177,18 @@ func (destination *offsetRingDestination) isShift() bool {
 // Configure validates the configuration for the module, creates a channel to receive requests on, and sets up the
 // storage map. If no expiration time for groups is set, a default value of 7 days is used. If no interval count is
 // set, a default of 10 intervals is used. If no worker count is set, a default of 20 workers is used.
+//
+// Groups that have not committed an offset within expire-group seconds are not returned in the group lists, and are
+// removed from storage every expire-group-refresh seconds (10 minutes by default, or 0 to only remove a group when it
+// is fetched). A group that is removed is added again if it commits an offset later. Groups that match the
+// never-expire-groups regular expression, such as batch consumers that only commit once a day, are never expired.
+//
+// The interval count can be overridden for a cluster in the intervals-clusters table, which maps cluster names to the
+// number of offsets to keep for each partition in that cluster. Fewer intervals use less memory, while more intervals
+// give the evaluator a longer history. If any of these values is not a positive integer, this func panics.
+//
+// Storage reports as unhealthy if the request queue, or the queue for a worker, has requests waiting and none has been
+// handled for health-threshold seconds (60 by default, or 0 to disable).
 func (module *InMemoryStorage) Configure(name, configRoot string) {
 	module.Log.Info("configuring")
 
@@ -124,13 +199,34 @@ func (module *InMemoryStorage) Configure(name, configRoot string) {
 	viper.SetDefault(configRoot+".expire-group", 604800)
 	viper.SetDefault(configRoot+".workers", 20)
 	viper.SetDefault(configRoot+".queue-depth", 1)
+	viper.SetDefault(configRoot+".expire-group-refresh", 600)
+	viper.SetDefault(configRoot+".health-threshold", 60)
 	module.intervals = viper.GetInt(configRoot + ".intervals")
 	module.expireGroup = viper.GetInt64(configRoot + ".expire-group")
 	module.numWorkers = viper.GetInt(configRoot + ".workers")
 	module.minDistance = viper.GetInt64(configRoot + ".min-distance")
 	module.queueDepth = viper.GetInt(configRoot + ".queue-depth")
+	module.expireGroupRefresh = viper.GetInt64(configRoot + ".expire-group-refresh")
+	if module.expireGroupRefresh < 0 {
+		panic("Storage '" + name + "' must not have a negative expire-group-refresh")
+	}
+	module.healthThreshold = viper.GetInt64(configRoot + ".health-threshold")
+	if module.healthThreshold < 0 {
+		panic("Storage '" + name + "' must not have a negative health-threshold")
+	}
+
+	module.clusterIntervals = make(map[string]int)
+	for cluster, value := range viper.GetStringMap(configRoot + ".intervals-clusters") {
+		intervals, err := cast.ToIntE(value)
+		if (err != nil) || (intervals < 1) {
+			module.Log.Panic("invalid intervals", zap.String("cluster", cluster))
+			panic("Storage '" + name + "' has invalid intervals for '" + cluster + "' in " + configRoot + ".intervals-clusters")
+		}
+		module.clusterIntervals[strings.ToLower(cluster)] = intervals
+	}
 
 	module.requestChannel = make(chan *protocol.StorageRequest, module.queueDepth)
+	module.quitChannel = make(chan struct{})
 	module.workersRunning = sync.WaitGroup{}
 	module.mainRunning = sync.WaitGroup{}
 	module.offsets = make(map[string]clusterOffsets)
@@ -141,25 +237,20 @@ func (module *InMemoryStorage) Configure(name, configRoot string) {
 		panic("Please change configurations to allowlist and denylist")
 	}
 
-	allowlist := viper.GetString(configRoot + ".group-allowlist")
-	if allowlist != "" {
-		re, err := regexp.Compile(allowlist)
-		if err != nil {
-			module.Log.Panic("Failed to compile group allowlist")
-			panic(err)
-		}
-		module.groupAllowlist = re
+	module.configRoot = configRoot
+	if err := module.ReloadFilters(viper.GetViper()); err != nil {
+		module.Log.Panic(err.Error())
+		panic(err)
 	}
+}
 
-	denylist := viper.GetString(configRoot + ".group-denylist")
-	if denylist != "" {
-		re, err := regexp.Compile(denylist)
-		if err != nil {
-			module.Log.Panic("Failed to compile group denylist")
-			panic(err)
-		}
-		module.groupDenylist = re
+// getClusterIntervals returns the number of intervals to keep for partitions in the cluster, which is the setting in
+// intervals-clusters if there is one, or the module default
+func (module *InMemoryStorage) getClusterIntervals(cluster string) int {
+	if intervals, ok := module.clusterIntervals[strings.ToLower(cluster)]; ok {
+		return intervals
 	}
+	return module.intervals
 }
 
 // GetCommunicationChannel returns the RequestChannel that has been setup for this module.
@@ -174,33 +265,74 @@ func (module *InMemoryStorage) Start() error {
 	module.Log.Info("starting")
 
 	for cluster := range viper.GetStringMap("cluster") {
-		module.
-			offsets[cluster] = clusterOffsets{
-			broker:       make(map[string][]*ring.Ring),
-			consumer:     make(map[string]*consumerGroup),
-			brokerLock:   &sync.RWMutex{},
-			consumerLock: &sync.RWMutex{},
-		}
+		module.offsets[cluster] = module.newClusterOffsets(cluster)
 	}
 
 	// Start the appropriate number of workers, with a channel for each
 	module.workers = make([]chan *protocol.StorageRequest, module.numWorkers)
+	module.workerBeats = make([]protocol.Heartbeat, module.numWorkers)
 	for i := 0; i < module.numWorkers; i++ {
 		module.workers[i] = make(chan *protocol.StorageRequest, module.queueDepth)
+		module.workerBeats[i].Beat()
 		module.workersRunning.Add(1)
 		go module.requestWorker(i, module.workers[i])
 	}
 
+	module.mainBeat.Beat()
 	module.mainRunning.Add(1)
 	go module.mainLoop()
+
+	if module.healthThreshold != 0 {
+		module.App.Health.Register("storage."+module.name, module.checkHealth)
+	}
+
+	if module.expireGroupRefresh > 0 {
+		module.mainRunning.Add(1)
+		go module.expireGroupsLoop()
+	}
 	return nil
 }
 
+// newClusterOffsets returns empty storage for a cluster, with the number of intervals configured for it
+func (module *InMemoryStorage) newClusterOffsets(cluster string) clusterOffsets {
+	return clusterOffsets{
+		broker:       make(map[string][]*ring.Ring),
+		consumer:     make(map[string]*consumerGroup),
+		intervals:    module.getClusterIntervals(cluster),
+		brokers:      &clusterBrokers{controllerID: -1},
+		logStart:     make(map[string]map[int32]int64),
+		brokerLock:   &sync.RWMutex{},
+		consumerLock: &sync.RWMutex{},
+	}
+}
+
+// getClusterOffsets returns the storage for a single cluster. Clusters can be added and removed while requests are
+// being handled, so the map is only read under the offsetsLock
+func (module *InMemoryStorage) getClusterOffsets(cluster string) (clusterOffsets, bool) {
+	module.offsetsLock.RLock()
+	defer module.offsetsLock.RUnlock()
+	clusterMap, ok := module.offsets[cluster]
+	return clusterMap, ok
+}
+
+// getAllClusterOffsets returns a copy of the map of storage for all clusters, which is safe to iterate over
+func (module *InMemoryStorage) getAllClusterOffsets() map[string]clusterOffsets {
+	module.offsetsLock.RLock()
+	defer module.offsetsLock.RUnlock()
+	clusters := make(map[string]clusterOffsets, len(module.offsets))
+	for cluster, clusterMap := range module.offsets {
+		clusters[cluster] = clusterMap
+	}
+	return clusters
+}
+
 // Stop closes the incoming request channel, which will close the main loop. It then closes each of the worker
 // channels, to close the workers, and waits for all goroutines to exit before returning.
 func (module *InMemoryStorage) Stop() error {
 	module.Log.Info("stopping")
 
+	module.App.Health.Unregister("storage." + module.name)
+	close(module.quitChannel)
 	close(module.requestChannel)
 	module.mainRunning.Wait()
 
@@ -217,22 +349,39 @@ func (module *InMemoryStorage) requestWorker(workerNum int, requestChannel chan
 
 	// Using a map for the request types avoids a bit of complexity below
 	var requestTypeMap = map[protocol.StorageRequestConstant]func(*protocol.StorageRequest, *zap.Logger){
-		protocol.StorageSetBrokerOffset:        module.addBrokerOffset,
-		protocol.StorageSetConsumerOffset:      module.addConsumerOffset,
-		protocol.StorageSetConsumerOwner:       module.addConsumerOwner,
-		protocol.StorageSetDeleteTopic:         module.deleteTopic,
-		protocol.StorageSetDeleteGroup:         module.deleteGroup,
-		protocol.StorageFetchClusters:          module.fetchClusterList,
-		protocol.StorageFetchConsumers:         module.fetchConsumerList,
-		protocol.StorageFetchTopics:            module.fetchTopicList,
-		protocol.StorageFetchConsumer:          module.fetchConsumer,
-		protocol.StorageFetchTopic:             module.fetchTopic,
-		protocol.StorageClearConsumerOwners:    module.clearConsumerOwners,
-		protocol.StorageFetchConsumersForTopic: module.fetchConsumersForTopicList,
+		protocol.StorageSetBrokerOffset:         module.addBrokerOffset,
+		protocol.StorageSetConsumerOffset:       module.addConsumerOffset,
+		protocol.StorageSetConsumerOwner:        module.addConsumerOwner,
+		protocol.StorageSetDeleteTopic:          module.deleteTopic,
+		protocol.StorageSetDeleteGroup:          module.deleteGroup,
+		protocol.StorageFetchClusters:           module.fetchClusterList,
+		protocol.StorageFetchConsumers:          module.fetchConsumerList,
+		protocol.StorageFetchTopics:             module.fetchTopicList,
+		protocol.StorageFetchConsumer:           module.fetchConsumer,
+		protocol.StorageFetchTopic:              module.fetchTopic,
+		protocol.StorageClearConsumerOwners:     module.clearConsumerOwners,
+		protocol.StorageFetchConsumersForTopic:  module.fetchConsumersForTopicList,
+		protocol.StorageSetConsumerGeneration:   module.setConsumerGeneration,
+		protocol.StorageFetchConsumerGeneration: module.fetchConsumerGeneration,
+		protocol.StorageSetConsumerMembers:      module.setConsumerMembers,
+		protocol.StorageFetchConsumerMembers:    module.fetchConsumerMembers,
+		protocol.StorageFetchTopicRegressed:     module.fetchTopicRegressed,
+		protocol.StorageFetchTopicOffsets:       module.fetchTopicOffsets,
+		protocol.StorageFetchStats:              module.fetchStats,
+		protocol.StorageFetchConsumersPage:      module.fetchConsumerPage,
+		protocol.StorageSetConsumerTimeLag:      module.setConsumerTimeLag,
+		protocol.StorageSetClusterBrokers:       module.setClusterBrokers,
+		protocol.StorageSetBrokerLogStart:       module.setBrokerLogStart,
+		protocol.StorageSetAddCluster:           module.addCluster,
+		protocol.StorageSetDeleteCluster:        module.deleteCluster,
+		protocol.StorageFetchClusterBrokers:     module.fetchClusterBrokers,
+		protocol.StorageSetEvictGroup:           module.evictGroup,
 	}
 
 	workerLogger := module.Log.With(zap.Int("worker", workerNum))
 	for r := range requestChannel {
+		// Beating when the request is received, as well as when it is done, means an idle worker is not seen as stuck
+		module.workerBeats[workerNum].Beat()
 		if requestFunc, ok := requestTypeMap[r.RequestType]; ok {
 			requestFunc(r, workerLogger.With(
 				zap.String("cluster", r.Cluster),
@@ -246,6 +395,10 @@ func (module *InMemoryStorage) requestWorker(workerNum int, requestChannel chan
 				zap.String("client_id", r.ClientID),
 				zap.String("request", r.RequestType.String())))
 		}
+		if module.requestHandled != nil {
+			module.requestHandled(r)
+		}
+		module.workerBeats[workerNum].Beat()
 	}
 }
 
@@ -253,11 +406,12 @@ func (module *InMemoryStorage) mainLoop() {
 	defer module.mainRunning.Done()
 
 	for r := range module.requestChannel {
+		module.mainBeat.Beat()
 		switch r.RequestType {
-		case protocol.StorageSetBrokerOffset, protocol.StorageSetDeleteTopic, protocol.StorageFetchClusters, protocol.StorageFetchConsumers, protocol.StorageFetchTopics, protocol.StorageFetchTopic, protocol.StorageFetchConsumersForTopic:
+		case protocol.StorageSetBrokerOffset, protocol.StorageSetDeleteTopic, protocol.StorageFetchClusters, protocol.StorageFetchConsumers, protocol.StorageFetchTopics, protocol.StorageFetchTopic, protocol.StorageFetchConsumersForTopic, protocol.StorageFetchTopicRegressed, protocol.StorageFetchTopicOffsets, protocol.StorageFetchStats, protocol.StorageFetchConsumersPage, protocol.StorageSetClusterBrokers, protocol.StorageFetchClusterBrokers, protocol.StorageSetBrokerLogStart, protocol.StorageSetAddCluster, protocol.StorageSetDeleteCluster:
 			// Send to any worker
 			module.workers[int(rand.Int31n(int32(module.numWorkers)))] <- r
-		case protocol.StorageSetConsumerOffset, protocol.StorageSetConsumerOwner, protocol.StorageSetDeleteGroup, protocol.StorageClearConsumerOwners, protocol.StorageFetchConsumer:
+		case protocol.StorageSetConsumerOffset, protocol.StorageSetConsumerOwner, protocol.StorageSetDeleteGroup, protocol.StorageClearConsumerOwners, protocol.StorageFetchConsumer, protocol.StorageSetConsumerGeneration, protocol.StorageFetchConsumerGeneration, protocol.StorageSetConsumerMembers, protocol.StorageFetchConsumerMembers, protocol.StorageSetConsumerTimeLag, protocol.StorageSetEvictGroup:
 			// Hash to a consistent worker
 			module.workers[int(xxhash.ChecksumString64(r.Cluster+r.Group)%uint64(module.numWorkers))] <- r
 		default:
@@ -268,11 +422,112 @@ func (module *InMemoryStorage) mainLoop() {
 				close(r.Reply)
 			}
 		}
+		module.mainBeat.Beat()
+	}
+}
+
+// checkHealth returns an error if the request queue, or the queue for a worker, has requests waiting, but no request
+// has been handled from it within the health threshold. This means that the main loop or the worker is stuck.
+func (module *InMemoryStorage) checkHealth() error {
+	threshold := time.Duration(module.healthThreshold) * time.Second
+	if len(module.requestChannel) > 0 {
+		if err := module.mainBeat.Check(threshold)(); err != nil {
+			return errors.New("request queue is backed up: " + err.Error())
+		}
+	}
+	for i, worker := range module.workers {
+		if len(worker) > 0 {
+			if err := module.workerBeats[i].Check(threshold)(); err != nil {
+				return errors.New("queue for worker " + strconv.Itoa(i) + " is backed up: " + err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+func (module *InMemoryStorage) expireGroupsLoop() {
+	defer module.mainRunning.Done()
+
+	ticker := time.NewTicker(time.Duration(module.expireGroupRefresh) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			module.expireGroups()
+		case <-module.quitChannel:
+			return
+		}
+	}
+}
+
+// neverExpires returns true if the group matches the never-expire-groups regular expression
+func (module *InMemoryStorage) neverExpires(group string) bool {
+	module.filterLock.RLock()
+	defer module.filterLock.RUnlock()
+	return (module.neverExpire != nil) && module.neverExpire.MatchString(group)
+}
+
+// isGroupExpired returns true if the group has not committed an offset within expire-group seconds, and is not
+// exempt from expiration
+func (module *InMemoryStorage) isGroupExpired(group string, consumerMap *consumerGroup) bool {
+	if module.neverExpires(group) {
+		return false
+	}
+
+	consumerMap.lock.RLock()
+	defer consumerMap.lock.RUnlock()
+	return ((time.Now().Unix() - module.expireGroup) * 1000) > consumerMap.lastCommit
+}
+
+// expireGroups removes the groups that have expired from every cluster
+func (module *InMemoryStorage) expireGroups() {
+	for cluster, clusterMap := range module.getAllClusterOffsets() {
+		expired := 0
+		clusterMap.consumerLock.Lock()
+		for group, consumerMap := range clusterMap.consumer {
+			if module.isGroupExpired(group, consumerMap) {
+				delete(clusterMap.consumer, group)
+				expired++
+			}
+		}
+		clusterMap.consumerLock.Unlock()
+
+		if expired > 0 {
+			module.Log.Info("purged expired consumers", zap.String("cluster", cluster), zap.Int("count", expired))
+		}
+	}
+}
+
+// addCluster sets up the storage for a cluster that was added while Burrow is running, such as by cluster discovery.
+// If the cluster already exists, its stored offsets are kept.
+func (module *InMemoryStorage) addCluster(request *protocol.StorageRequest, requestLogger *zap.Logger) {
+	module.offsetsLock.Lock()
+	defer module.offsetsLock.Unlock()
+
+	if _, ok := module.offsets[request.Cluster]; ok {
+		requestLogger.Debug("cluster exists")
+		return
 	}
+	module.offsets[request.Cluster] = module.newClusterOffsets(request.Cluster)
+	requestLogger.Debug("ok")
+}
+
+// deleteCluster removes a cluster and everything stored for it
+func (module *InMemoryStorage) deleteCluster(request *protocol.StorageRequest, requestLogger *zap.Logger) {
+	module.offsetsLock.Lock()
+	defer module.offsetsLock.Unlock()
+
+	if _, ok := module.offsets[request.Cluster]; !ok {
+		requestLogger.Warn("unknown cluster")
+		return
+	}
+	delete(module.offsets, request.Cluster)
+	requestLogger.Debug("ok")
 }
 
 func (module *InMemoryStorage) addBrokerOffset(request *protocol.StorageRequest, requestLogger *zap.Logger) {
-	clusterMap, ok := module.offsets[request.Cluster]
+	clusterMap, ok := module.getClusterOffsets(request.Cluster)
 	if !ok {
 		// Ignore offsets for clusters that we don't know about - should never happen anyways
 		requestLogger.Warn("unknown cluster")
@@ -290,7 +545,7 @@ func (module *InMemoryStorage) addBrokerOffset(request *protocol.StorageRequest,
 	if request.TopicPartitionCount >= int32(len(topicList)) {
 		// The partition count has increased. Append enough extra partitions, with offset rings, to our slice
 		for i := int32(len(topicList)); i < request.TopicPartitionCount; i++ {
-			topicList = append(topicList, ring.New(module.intervals))
+			topicList = append(topicList, ring.New(clusterMap.intervals))
 		}
 	}
 
@@ -303,17 +558,64 @@ func (module *InMemoryStorage) addBrokerOffset(request *protocol.StorageRequest,
 		partitionEntry.Value = &brokerOffset{
 			Offset:    request.Offset,
 			Timestamp: request.Timestamp,
+			Regressed: request.Regressed,
 		}
 	} else {
 		ringval, _ := partitionEntry.Value.(*brokerOffset)
 		ringval.Offset = request.Offset
 		ringval.Timestamp = request.Timestamp
+		ringval.Regressed = request.Regressed
+	}
+
+	if request.Timestamp > clusterMap.brokers.lastUpdate {
+		clusterMap.brokers.lastUpdate = request.Timestamp
 	}
 
 	requestLogger.Debug("ok")
 	clusterMap.broker[request.Topic] = topicList
 }
 
+func (module *InMemoryStorage) setClusterBrokers(request *protocol.StorageRequest, requestLogger *zap.Logger) {
+	clusterMap, ok := module.getClusterOffsets(request.Cluster)
+	if !ok {
+		requestLogger.Warn("unknown cluster")
+		return
+	}
+
+	clusterMap.brokerLock.Lock()
+	defer clusterMap.brokerLock.Unlock()
+
+	requestLogger.Debug("ok", zap.Int32("controller_id", request.ControllerID), zap.Int32("broker_count", request.BrokerCount))
+	clusterMap.brokers.controllerID = request.ControllerID
+	clusterMap.brokers.brokerCount = request.BrokerCount
+	clusterMap.brokers.timestamp = request.Timestamp
+}
+
+// setBrokerLogStart stores the oldest offset that the brokers retain for the partition. This is only done for topics
+// that have broker offsets, so that a log start that arrives after the topic was deleted does not bring it back.
+func (module *InMemoryStorage) setBrokerLogStart(request *protocol.StorageRequest, requestLogger *zap.Logger) {
+	clusterMap, ok := module.getClusterOffsets(request.Cluster)
+	if !ok {
+		requestLogger.Warn("unknown cluster")
+		return
+	}
+
+	clusterMap.brokerLock.Lock()
+	defer clusterMap.brokerLock.Unlock()
+
+	if _, ok := clusterMap.broker[request.Topic]; !ok {
+		requestLogger.Debug("unknown topic")
+		return
+	}
+	partitions, ok := clusterMap.logStart[request.Topic]
+	if !ok {
+		partitions = make(map[int32]int64)
+		clusterMap.logStart[request.Topic] = partitions
+	}
+	partitions[request.Partition] = request.Offset
+	requestLogger.Debug("ok")
+}
+
 func (module *InMemoryStorage) getBrokerOffset(clusterMap *clusterOffsets, topic string, partition int32, requestLogger *zap.Logger) (int64, int32) {
 	clusterMap.brokerLock.RLock()
 	defer clusterMap.brokerLock.RUnlock()
@@ -342,7 +644,7 @@ func (module *InMemoryStorage) getBrokerOffset(clusterMap *clusterOffsets, topic
 	return topicPartitionList[partition].Value.(*brokerOffset).Offset, int32(len(topicPartitionList))
 }
 
-func (module *InMemoryStorage) getConsumerPartition(consumerMap *consumerGroup, topic string, partition, partitionCount int32, requestLogger *zap.Logger) *consumerPartition {
+func (module *InMemoryStorage) getConsumerPartition(consumerMap *consumerGroup, intervals int, topic string, partition, partitionCount int32, requestLogger *zap.Logger) *consumerPartition {
 	// Get or create the topic for the consumer
 	consumerTopicMap, ok := consumerMap.topics[topic]
 	if !ok {
@@ -361,13 +663,41 @@ func (module *InMemoryStorage) getConsumerPartition(consumerMap *consumerGroup,
 
 	// Get or create the offsets ring for this partition
 	if consumerTopicMap[partition].offsets == nil {
-		consumerTopicMap[partition].offsets = ring.New(module.intervals)
+		consumerTopicMap[partition].offsets = ring.New(intervals)
 	}
 
 	return consumerTopicMap[partition]
 }
 
+// ReloadFilters compiles the group-allowlist, group-denylist, and never-expire-groups configurations for the module, and
+// replaces the current filters with them. If any of them is invalid, an error is returned and the current filters are
+// kept. Groups that are already stored are not removed if they no longer pass, and will expire as usual.
+func (module *InMemoryStorage) ReloadFilters(config *viper.Viper) error {
+	groupAllowlist, err := helpers.CompileConfigRegexp(config, module.configRoot+".group-allowlist")
+	if err != nil {
+		return err
+	}
+	groupDenylist, err := helpers.CompileConfigRegexp(config, module.configRoot+".group-denylist")
+	if err != nil {
+		return err
+	}
+	neverExpire, err := helpers.CompileConfigRegexp(config, module.configRoot+".never-expire-groups")
+	if err != nil {
+		return err
+	}
+
+	module.filterLock.Lock()
+	defer module.filterLock.Unlock()
+	module.groupAllowlist = groupAllowlist
+	module.groupDenylist = groupDenylist
+	module.neverExpire = neverExpire
+	return nil
+}
+
 func (module *InMemoryStorage) acceptConsumerGroup(group string) bool {
+	module.filterLock.RLock()
+	defer module.filterLock.RUnlock()
+
 	if (module.groupAllowlist != nil) && (!module.groupAllowlist.MatchString(group)) {
 		return false
 	}
@@ -378,14 +708,14 @@ func (module *InMemoryStorage) acceptConsumerGroup(group string) bool {
 }
 
 func (module *InMemoryStorage) addConsumerOffset(request *protocol.StorageRequest, requestLogger *zap.Logger) {
-	clusterMap, ok := module.offsets[request.Cluster]
+	clusterMap, ok := module.getClusterOffsets(request.Cluster)
 	if !ok {
 		// Ignore offsets for clusters that we don't know about - should never happen anyways
 		requestLogger.Warn("unknown cluster")
 		return
 	}
 
-	if request.Timestamp < ((time.Now().Unix() - module.expireGroup) * 1000) {
+	if (request.Timestamp < ((time.Now().Unix() - module.expireGroup) * 1000)) && (!module.neverExpires(request.Group)) {
 		requestLogger.Debug("dropped", zap.String("reason", "old offset"))
 		return
 	}
@@ -419,7 +749,7 @@ func (module *InMemoryStorage) addConsumerOffset(request *protocol.StorageReques
 	defer consumerMap.lock.Unlock()
 
 	// Get the offset ring for this partition - it always points to the earliest offset (or where to insert a new value)
-	consumerPartition := module.getConsumerPartition(consumerMap, request.Topic, request.Partition, partitionCount, requestLogger)
+	consumerPartition := module.getConsumerPartition(consumerMap, clusterMap.intervals, request.Topic, request.Partition, partitionCount, requestLogger)
 	consumerPartitionRing := consumerPartition.offsets
 
 	destination := findConsumerOffsetDestination(consumerPartitionRing, request, requestLogger)
@@ -550,7 +880,7 @@ func (module *InMemoryStorage) storeConsumerOffset(consumerPartition *consumerPa
 }
 
 func (module *InMemoryStorage) addConsumerOwner(request *protocol.StorageRequest, requestLogger *zap.Logger) {
-	clusterMap, ok := module.offsets[request.Cluster]
+	clusterMap, ok := module.getClusterOffsets(request.Cluster)
 	if !ok {
 		// Ignore offsets for clusters that we don't know about - should never happen anyways
 		requestLogger.Warn("unknown cluster")
@@ -586,7 +916,7 @@ func (module *InMemoryStorage) addConsumerOwner(request *protocol.StorageRequest
 	defer consumerMap.lock.Unlock()
 
 	// Get the consumer partition state for this partition - we don't need it, but it will properly create the topic and partitions for us
-	module.getConsumerPartition(consumerMap, request.Topic, request.Partition, partitionCount, requestLogger)
+	module.getConsumerPartition(consumerMap, clusterMap.intervals, request.Topic, request.Partition, partitionCount, requestLogger)
 
 	if topic, ok := consumerMap.topics[request.Topic]; !ok || (int32(len(topic)) <= request.Partition) {
 		requestLogger.Debug("dropped", zap.String("reason", "no partition"))
@@ -600,7 +930,7 @@ func (module *InMemoryStorage) addConsumerOwner(request *protocol.StorageRequest
 }
 
 func (module *InMemoryStorage) clearConsumerOwners(request *protocol.StorageRequest, requestLogger *zap.Logger) {
-	clusterMap, ok := module.offsets[request.Cluster]
+	clusterMap, ok := module.getClusterOffsets(request.Cluster)
 	if !ok {
 		// Ignore metadata for clusters that we don't know about - should never happen anyways
 		requestLogger.Warn("unknown cluster")
@@ -636,8 +966,104 @@ func (module *InMemoryStorage) clearConsumerOwners(request *protocol.StorageRequ
 	requestLogger.Debug("ok")
 }
 
+func (module *InMemoryStorage) setConsumerGeneration(request *protocol.StorageRequest, requestLogger *zap.Logger) {
+	clusterMap, ok := module.getClusterOffsets(request.Cluster)
+	if !ok {
+		// Ignore metadata for clusters that we don't know about - should never happen anyways
+		requestLogger.Warn("unknown cluster")
+		return
+	}
+
+	if !module.acceptConsumerGroup(request.Group) {
+		requestLogger.Debug("dropped", zap.String("reason", "group not allowlisted"))
+		return
+	}
+
+	// Make the consumer group if it does not yet exist
+	clusterMap.consumerLock.Lock()
+	consumerMap, ok := clusterMap.consumer[request.Group]
+	if !ok {
+		clusterMap.consumer[request.Group] = &consumerGroup{
+			lock:   &sync.RWMutex{},
+			topics: make(map[string][]*consumerPartition),
+		}
+		consumerMap = clusterMap.consumer[request.Group]
+	}
+	clusterMap.consumerLock.Unlock()
+
+	consumerMap.lock.Lock()
+	defer consumerMap.lock.Unlock()
+
+	if consumerMap.generationTime != 0 {
+		if consumerMap.generation == request.Generation {
+			requestLogger.Debug("dropped", zap.String("reason", "same generation"))
+			return
+		}
+
+		// The first generation seen is not counted, as we do not know when the group got to it
+		consumerMap.rebalances++
+	}
+
+	requestLogger.Debug("ok", zap.Int32("generation", request.Generation))
+	consumerMap.generation = request.Generation
+	consumerMap.generationTime = request.Timestamp
+}
+
+func (module *InMemoryStorage) setConsumerMembers(request *protocol.StorageRequest, requestLogger *zap.Logger) {
+	clusterMap, ok := module.getClusterOffsets(request.Cluster)
+	if !ok {
+		requestLogger.Warn("unknown cluster")
+		return
+	}
+
+	// The group is not created here, so that a group that was just deleted is not added back
+	clusterMap.consumerLock.RLock()
+	consumerMap, ok := clusterMap.consumer[request.Group]
+	clusterMap.consumerLock.RUnlock()
+	if !ok {
+		requestLogger.Debug("dropped", zap.String("reason", "unknown consumer"))
+		return
+	}
+
+	consumerMap.lock.Lock()
+	defer consumerMap.lock.Unlock()
+
+	requestLogger.Debug("ok", zap.Int32("member_count", request.MemberCount))
+	consumerMap.memberCount = request.MemberCount
+	consumerMap.membersTime = request.Timestamp
+}
+
+func (module *InMemoryStorage) setConsumerTimeLag(request *protocol.StorageRequest, requestLogger *zap.Logger) {
+	clusterMap, ok := module.getClusterOffsets(request.Cluster)
+	if !ok {
+		requestLogger.Warn("unknown cluster")
+		return
+	}
+
+	// As with the group members, the group and partition are not created here
+	clusterMap.consumerLock.RLock()
+	consumerMap, ok := clusterMap.consumer[request.Group]
+	clusterMap.consumerLock.RUnlock()
+	if !ok {
+		requestLogger.Debug("dropped", zap.String("reason", "unknown consumer"))
+		return
+	}
+
+	consumerMap.lock.Lock()
+	defer consumerMap.lock.Unlock()
+
+	partitions, ok := consumerMap.topics[request.Topic]
+	if !ok || (request.Partition < 0) || (int32(len(partitions)) <= request.Partition) {
+		requestLogger.Debug("dropped", zap.String("reason", "no partition"))
+		return
+	}
+
+	requestLogger.Debug("ok", zap.Int64("time_lag", request.TimeLag))
+	partitions[request.Partition].timeLag = request.TimeLag
+}
+
 func (module *InMemoryStorage) deleteTopic(request *protocol.StorageRequest, requestLogger *zap.Logger) {
-	clusterMap, ok := module.offsets[request.Cluster]
+	clusterMap, ok := module.getClusterOffsets(request.Cluster)
 	if !ok {
 		requestLogger.Warn("unknown cluster")
 		return
@@ -654,21 +1080,46 @@ func (module *InMemoryStorage) deleteTopic(request *protocol.StorageRequest, req
 	// Now remove the topic from the broker list
 	clusterMap.brokerLock.Lock()
 	delete(clusterMap.broker, request.Topic)
+	delete(clusterMap.logStart, request.Topic)
 	clusterMap.brokerLock.Unlock()
 
 	requestLogger.Debug("ok")
 }
 
 func (module *InMemoryStorage) deleteGroup(request *protocol.StorageRequest, requestLogger *zap.Logger) {
-	clusterMap, ok := module.offsets[request.Cluster]
+	module.removeGroup(request, requestLogger, false)
+}
+
+// evictGroup removes the group, or the topic of the group, like deleteGroup, but only if it is stored, and replies
+// with whether it was
+func (module *InMemoryStorage) evictGroup(request *protocol.StorageRequest, requestLogger *zap.Logger) {
+	defer close(request.Reply)
+	request.Reply <- module.removeGroup(request, requestLogger, true)
+}
+
+// removeGroup removes the group from the cluster, or only the topic from the group if the request has a Topic, and
+// deletes the metrics for what was removed. It returns whether the group, and the topic if one was given, was stored.
+// If mustExist is true, nothing is removed when that is not the case.
+func (module *InMemoryStorage) removeGroup(request *protocol.StorageRequest, requestLogger *zap.Logger, mustExist bool) bool {
+	clusterMap, ok := module.getClusterOffsets(request.Cluster)
 	if !ok {
 		requestLogger.Warn("unknown cluster")
-		return
+		return false
 	}
 
 	clusterMap.consumerLock.Lock()
+	group, exists := clusterMap.consumer[request.Group]
+	if exists && (request.Topic != "") {
+		_, exists = group.topics[request.Topic]
+	}
+	if mustExist && !exists {
+		clusterMap.consumerLock.Unlock()
+		requestLogger.Debug("not found")
+		return false
+	}
+
 	deleteAllGroupMetrics := true
-	if group, ok := clusterMap.consumer[request.Group]; ok && request.Topic != "" {
+	if group != nil && request.Topic != "" {
 		delete(group.topics, request.Topic)
 		if len(group.topics) == 0 {
 			delete(clusterMap.consumer, request.Group)
@@ -689,13 +1140,15 @@ func (module *InMemoryStorage) deleteGroup(request *protocol.StorageRequest, req
 	}
 
 	requestLogger.Debug("ok")
+	return exists
 }
 
 func (module *InMemoryStorage) fetchClusterList(request *protocol.StorageRequest, requestLogger *zap.Logger) {
 	defer close(request.Reply)
 
-	clusterList := make([]string, 0, len(module.offsets))
-	for cluster := range module.offsets {
+	clusters := module.getAllClusterOffsets()
+	clusterList := make([]string, 0, len(clusters))
+	for cluster := range clusters {
 		clusterList = append(clusterList, cluster)
 	}
 
@@ -703,10 +1156,99 @@ func (module *InMemoryStorage) fetchClusterList(request *protocol.StorageRequest
 	request.Reply <- clusterList
 }
 
+// The sizes used to estimate the memory used by storage. A slot in a broker ring holds a *brokerOffset, and a slot in a
+// consumer ring holds a *protocol.ConsumerOffset with its *protocol.Lag
+const (
+	brokerSlotBytes        = int64(unsafe.Sizeof(ring.Ring{}) + unsafe.Sizeof(brokerOffset{}))
+	consumerSlotBytes      = int64(unsafe.Sizeof(ring.Ring{}) + unsafe.Sizeof(protocol.ConsumerOffset{}) + unsafe.Sizeof(protocol.Lag{}))
+	consumerPartitionBytes = int64(unsafe.Sizeof(consumerPartition{}))
+	consumerGroupBytes     = int64(unsafe.Sizeof(consumerGroup{}) + unsafe.Sizeof(sync.RWMutex{}))
+)
+
+func (module *InMemoryStorage) fetchStats(request *protocol.StorageRequest, requestLogger *zap.Logger) {
+	defer close(request.Reply)
+
+	clusters := module.getAllClusterOffsets()
+	stats := make(map[string]*protocol.StorageClusterStats, len(clusters))
+	for cluster, clusterMap := range clusters {
+		clusterStats := &protocol.StorageClusterStats{
+			Intervals: clusterMap.intervals,
+		}
+
+		var stringBytes int64
+		clusterMap.brokerLock.RLock()
+		clusterStats.Topics = len(clusterMap.broker)
+		for topic, partitions := range clusterMap.broker {
+			clusterStats.Partitions += len(partitions)
+			clusterStats.BrokerBuffers += len(partitions)
+			stringBytes += int64(len(topic))
+		}
+		clusterMap.brokerLock.RUnlock()
+
+		var consumerPartitions int
+		clusterMap.consumerLock.RLock()
+		clusterStats.Groups = len(clusterMap.consumer)
+		for group, consumerMap := range clusterMap.consumer {
+			stringBytes += int64(len(group))
+			consumers := make(map[string]struct{})
+			consumerMap.lock.RLock()
+			for topic, partitions := range consumerMap.topics {
+				stringBytes += int64(len(topic))
+				consumerPartitions += len(partitions)
+				for _, partition := range partitions {
+					if partition.offsets != nil {
+						clusterStats.ConsumerBuffers++
+					}
+					if (partition.owner != "") || (partition.clientID != "") {
+						consumers[partition.owner+"/"+partition.clientID] = struct{}{}
+						stringBytes += int64(len(partition.owner) + len(partition.clientID))
+					}
+				}
+			}
+			consumerMap.lock.RUnlock()
+			clusterStats.Consumers += len(consumers)
+		}
+		clusterMap.consumerLock.RUnlock()
+
+		clusterStats.BufferSlots = (clusterStats.BrokerBuffers + clusterStats.ConsumerBuffers) * clusterStats.Intervals
+		clusterStats.EstimatedBytes = stringBytes +
+			int64(clusterStats.BrokerBuffers*clusterStats.Intervals)*brokerSlotBytes +
+			int64(clusterStats.ConsumerBuffers*clusterStats.Intervals)*consumerSlotBytes +
+			int64(consumerPartitions)*consumerPartitionBytes +
+			int64(clusterStats.Groups)*consumerGroupBytes
+		stats[cluster] = clusterStats
+	}
+
+	requestLogger.Debug("ok")
+	request.Reply <- stats
+}
+
+func (module *InMemoryStorage) fetchClusterBrokers(request *protocol.StorageRequest, requestLogger *zap.Logger) {
+	defer close(request.Reply)
+
+	clusterMap, ok := module.getClusterOffsets(request.Cluster)
+	if !ok {
+		requestLogger.Warn("unknown cluster")
+		return
+	}
+
+	clusterMap.brokerLock.RLock()
+	brokers := &protocol.ClusterBrokers{
+		ControllerID: clusterMap.brokers.controllerID,
+		BrokerCount:  clusterMap.brokers.brokerCount,
+		Timestamp:    clusterMap.brokers.timestamp,
+		LastUpdate:   clusterMap.brokers.lastUpdate,
+	}
+	clusterMap.brokerLock.RUnlock()
+
+	requestLogger.Debug("ok")
+	request.Reply <- brokers
+}
+
 func (module *InMemoryStorage) fetchTopicList(request *protocol.StorageRequest, requestLogger *zap.Logger) {
 	defer close(request.Reply)
 
-	clusterMap, ok := module.offsets[request.Cluster]
+	clusterMap, ok := module.getClusterOffsets(request.Cluster)
 	if !ok {
 		requestLogger.Warn("unknown cluster")
 		return
@@ -726,7 +1268,7 @@ func (module *InMemoryStorage) fetchTopicList(request *protocol.StorageRequest,
 func (module *InMemoryStorage) fetchConsumerList(request *protocol.StorageRequest, requestLogger *zap.Logger) {
 	defer close(request.Reply)
 
-	clusterMap, ok := module.offsets[request.Cluster]
+	clusterMap, ok := module.getClusterOffsets(request.Cluster)
 	if !ok {
 		requestLogger.Warn("unknown cluster")
 		return
@@ -734,8 +1276,10 @@ func (module *InMemoryStorage) fetchConsumerList(request *protocol.StorageReques
 
 	clusterMap.consumerLock.RLock()
 	consumerList := make([]string, 0, len(clusterMap.consumer))
-	for consumer := range clusterMap.consumer {
-		consumerList = append(consumerList, consumer)
+	for consumer, consumerMap := range clusterMap.consumer {
+		if !module.isGroupExpired(consumer, consumerMap) {
+			consumerList = append(consumerList, consumer)
+		}
 	}
 	clusterMap.consumerLock.RUnlock()
 
@@ -743,10 +1287,69 @@ func (module *InMemoryStorage) fetchConsumerList(request *protocol.StorageReques
 	request.Reply <- consumerList
 }
 
+// groupNameHeap is a max-heap of group names. It is used to keep only the first names (in sorted order) that are needed
+// for a page, rather than collecting every group in the cluster
+type groupNameHeap []string
+
+func (h groupNameHeap) Len() int            { return len(h) }
+func (h groupNameHeap) Less(i, j int) bool  { return h[i] > h[j] }
+func (h groupNameHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *groupNameHeap) Push(x interface{}) { *h = append(*h, x.(string)) }
+func (h *groupNameHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[0 : n-1]
+	return x
+}
+
+func (module *InMemoryStorage) fetchConsumerPage(request *protocol.StorageRequest, requestLogger *zap.Logger) {
+	defer close(request.Reply)
+
+	clusterMap, ok := module.getClusterOffsets(request.Cluster)
+	if !ok {
+		requestLogger.Warn("unknown cluster")
+		return
+	}
+
+	// Only the groups up to the end of the page need to be held. If there is no limit, every group is
+	bound := 0
+	if request.Limit > 0 {
+		bound = request.Skip + request.Limit
+	}
+
+	page := &protocol.ConsumerListPage{}
+	names := make(groupNameHeap, 0, bound)
+	clusterMap.consumerLock.RLock()
+	for consumer, consumerMap := range clusterMap.consumer {
+		if (!strings.HasPrefix(consumer, request.Prefix)) || module.isGroupExpired(consumer, consumerMap) {
+			continue
+		}
+		page.Total++
+		if (bound == 0) || (len(names) < bound) {
+			heap.Push(&names, consumer)
+		} else if consumer < names[0] {
+			names[0] = consumer
+			heap.Fix(&names, 0)
+		}
+	}
+	clusterMap.consumerLock.RUnlock()
+
+	sort.Strings(names)
+	if request.Skip >= len(names) {
+		page.Consumers = make([]string, 0)
+	} else {
+		page.Consumers = names[request.Skip:]
+	}
+
+	requestLogger.Debug("ok")
+	request.Reply <- page
+}
+
 func (module *InMemoryStorage) fetchTopic(request *protocol.StorageRequest, requestLogger *zap.Logger) {
 	defer close(request.Reply)
 
-	clusterMap, ok := module.offsets[request.Cluster]
+	clusterMap, ok := module.getClusterOffsets(request.Cluster)
 	if !ok {
 		requestLogger.Warn("unknown cluster")
 		return
@@ -772,6 +1375,92 @@ func (module *InMemoryStorage) fetchTopic(request *protocol.StorageRequest, requ
 	request.Reply <- offsetList
 }
 
+func (module *InMemoryStorage) fetchTopicRegressed(request *protocol.StorageRequest, requestLogger *zap.Logger) {
+	defer close(request.Reply)
+
+	clusterMap, ok := module.getClusterOffsets(request.Cluster)
+	if !ok {
+		requestLogger.Warn("unknown cluster")
+		return
+	}
+
+	clusterMap.brokerLock.RLock()
+	topicList, ok := clusterMap.broker[request.Topic]
+	if !ok {
+		requestLogger.Warn("unknown topic")
+		clusterMap.brokerLock.RUnlock()
+		return
+	}
+
+	regressedList := make([]bool, 0, len(topicList))
+	for _, partition := range topicList {
+		if partition.Value != nil {
+			regressedList = append(regressedList, partition.Value.(*brokerOffset).Regressed)
+		}
+	}
+	clusterMap.brokerLock.RUnlock()
+
+	requestLogger.Debug("ok")
+	request.Reply <- regressedList
+}
+
+func (module *InMemoryStorage) fetchTopicOffsets(request *protocol.StorageRequest, requestLogger *zap.Logger) {
+	defer close(request.Reply)
+
+	clusterMap, ok := module.getClusterOffsets(request.Cluster)
+	if !ok {
+		requestLogger.Warn("unknown cluster")
+		return
+	}
+
+	clusterMap.brokerLock.RLock()
+	topicOffsets := make(map[string][]*protocol.TopicPartitionOffsets)
+	if request.Topic != "" {
+		topicList, ok := clusterMap.broker[request.Topic]
+		if !ok {
+			requestLogger.Warn("unknown topic")
+			clusterMap.brokerLock.RUnlock()
+			return
+		}
+		topicOffsets[request.Topic] = getTopicPartitionOffsets(topicList)
+	} else {
+		for topic, topicList := range clusterMap.broker {
+			topicOffsets[topic] = getTopicPartitionOffsets(topicList)
+		}
+	}
+	clusterMap.brokerLock.RUnlock()
+
+	requestLogger.Debug("ok")
+	request.Reply <- topicOffsets
+}
+
+// getTopicPartitionOffsets returns the oldest and newest broker offsets stored for each partition of a topic. The ring
+// for each partition points at the newest offset, so the oldest is the first entry after it that has been set.
+// Partitions that have no offsets stored yet are skipped. The caller must hold the broker lock.
+func getTopicPartitionOffsets(topicList []*ring.Ring) []*protocol.TopicPartitionOffsets {
+	partitions := make([]*protocol.TopicPartitionOffsets, 0, len(topicList))
+	for partitionID, partition := range topicList {
+		if partition.Value == nil {
+			continue
+		}
+		newest := partition.Value.(*brokerOffset)
+		offsets := &protocol.TopicPartitionOffsets{
+			Partition: int32(partitionID),
+			Oldest:    newest.Offset,
+			Newest:    newest.Offset,
+			Timestamp: newest.Timestamp,
+		}
+		for entry := partition.Next(); entry != partition; entry = entry.Next() {
+			if entry.Value != nil {
+				offsets.Oldest = entry.Value.(*brokerOffset).Offset
+				break
+			}
+		}
+		partitions = append(partitions, offsets)
+	}
+	return partitions
+}
+
 func getConsumerTopicList(consumerMap *consumerGroup) protocol.ConsumerTopics {
 	topicList := make(protocol.ConsumerTopics)
 	consumerMap.lock.RLock()
@@ -781,7 +1470,7 @@ func getConsumerTopicList(consumerMap *consumerGroup) protocol.ConsumerTopics {
 		topicList[topic] = make(protocol.ConsumerPartitions, len(partitions))
 
 		for partitionID, partition := range partitions {
-			consumerPartition := &protocol.ConsumerPartition{Owner: partition.owner, ClientID: partition.clientID}
+			consumerPartition := &protocol.ConsumerPartition{Owner: partition.owner, ClientID: partition.clientID, TimeLag: partition.timeLag}
 			if partition.offsets != nil {
 				offsetRing := partition.offsets
 				consumerPartition.Offsets = make([]*protocol.ConsumerOffset, offsetRing.Len())
@@ -816,7 +1505,7 @@ func getConsumerTopicList(consumerMap *consumerGroup) protocol.ConsumerTopics {
 func (module *InMemoryStorage) fetchConsumer(request *protocol.StorageRequest, requestLogger *zap.Logger) {
 	defer close(request.Reply)
 
-	clusterMap, ok := module.offsets[request.Cluster]
+	clusterMap, ok := module.getClusterOffsets(request.Cluster)
 	if !ok {
 		requestLogger.Warn("unknown cluster")
 		return
@@ -831,7 +1520,7 @@ func (module *InMemoryStorage) fetchConsumer(request *protocol.StorageRequest, r
 	}
 
 	// Lazily purge consumers that haven't committed in longer than the defined interval. Return as a 404
-	if ((time.Now().Unix() - module.expireGroup) * 1000) > consumerMap.lastCommit {
+	if module.isGroupExpired(request.Group, consumerMap) {
 		// Swap for a write lock
 		clusterMap.consumerLock.RUnlock()
 
@@ -857,13 +1546,16 @@ func (module *InMemoryStorage) fetchConsumer(request *protocol.StorageRequest, r
 
 		for p, partition := range partitions {
 			// Build the slice of broker offsets to return
-			partition.BrokerOffsets = make([]int64, 0, module.intervals)
+			partition.BrokerOffsets = make([]int64, 0, clusterMap.intervals)
 			brokerOffsetPtr := topicMap[p].Next()
 			brokerOffsetPtr.Do(func(item interface{}) {
 				if item != nil {
 					partition.BrokerOffsets = append(partition.BrokerOffsets, item.(*brokerOffset).Offset) // nolint:scopelint
 				}
 			})
+			if newest, ok := topicMap[p].Value.(*brokerOffset); ok {
+				partition.LastBrokerOffsetTimestamp = newest.Timestamp
+			}
 
 			if len(partition.Offsets) > 0 {
 				brokerOffset := partition.BrokerOffsets[len(partition.BrokerOffsets)-1]
@@ -876,6 +1568,15 @@ func (module *InMemoryStorage) fetchConsumer(request *protocol.StorageRequest, r
 					} else {
 						partition.CurrentLag = uint64(brokerOffset - lastOffset.Offset)
 					}
+
+					// A consumer that has caught up has no time lag, even if it was lagging when the time lag was set
+					if partition.CurrentLag == 0 {
+						partition.TimeLag = 0
+					}
+
+					if logStart, ok := clusterMap.logStart[topic][int32(p)]; ok {
+						partition.BelowLogStart = lastOffset.Offset < logStart
+					}
 				}
 			}
 		}
@@ -886,10 +1587,73 @@ func (module *InMemoryStorage) fetchConsumer(request *protocol.StorageRequest, r
 	request.Reply <- topicList
 }
 
+func (module *InMemoryStorage) fetchConsumerGeneration(request *protocol.StorageRequest, requestLogger *zap.Logger) {
+	defer close(request.Reply)
+
+	clusterMap, ok := module.getClusterOffsets(request.Cluster)
+	if !ok {
+		requestLogger.Warn("unknown cluster")
+		return
+	}
+
+	clusterMap.consumerLock.RLock()
+	consumerMap, ok := clusterMap.consumer[request.Group]
+	clusterMap.consumerLock.RUnlock()
+	if !ok {
+		requestLogger.Warn("unknown consumer")
+		return
+	}
+
+	consumerMap.lock.RLock()
+	defer consumerMap.lock.RUnlock()
+	if consumerMap.generationTime == 0 {
+		requestLogger.Debug("no generation")
+		return
+	}
+
+	requestLogger.Debug("ok")
+	request.Reply <- &protocol.ConsumerGeneration{
+		Generation: consumerMap.generation,
+		Timestamp:  consumerMap.generationTime,
+		Rebalances: consumerMap.rebalances,
+	}
+}
+
+func (module *InMemoryStorage) fetchConsumerMembers(request *protocol.StorageRequest, requestLogger *zap.Logger) {
+	defer close(request.Reply)
+
+	clusterMap, ok := module.getClusterOffsets(request.Cluster)
+	if !ok {
+		requestLogger.Warn("unknown cluster")
+		return
+	}
+
+	clusterMap.consumerLock.RLock()
+	consumerMap, ok := clusterMap.consumer[request.Group]
+	clusterMap.consumerLock.RUnlock()
+	if !ok {
+		requestLogger.Warn("unknown consumer")
+		return
+	}
+
+	consumerMap.lock.RLock()
+	defer consumerMap.lock.RUnlock()
+	if consumerMap.membersTime == 0 {
+		requestLogger.Debug("no members")
+		return
+	}
+
+	requestLogger.Debug("ok")
+	request.Reply <- &protocol.ConsumerMembers{
+		MemberCount: consumerMap.memberCount,
+		Timestamp:   consumerMap.membersTime,
+	}
+}
+
 func (module *InMemoryStorage) fetchConsumersForTopicList(request *protocol.StorageRequest, requestLogger *zap.Logger) {
 	defer close(request.Reply)
 
-	clusterMap, ok := module.offsets[request.Cluster]
+	clusterMap, ok := module.getClusterOffsets(request.Cluster)
 	if !ok {
 		requestLogger.Warn("unknown cluster")
 		return
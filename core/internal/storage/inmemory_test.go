@@ -110,6 +110,42 @@ func TestInMemoryStorage_Configure_DefaultIntervals(t *testing.T) {
 	assert.Equal(t, 10, module.intervals, "Default Intervals value of 10 did not get set")
 }
 
+func TestInMemoryStorage_checkHealth(t *testing.T) {
+	module := fixtureModule("", "")
+	module.Configure("test", "storage.test")
+	assert.Equal(t, int64(60), module.healthThreshold, "Default HealthThreshold value of 60 did not get set")
+
+	// The worker has a request waiting, and has not handled one since it started
+	module.workers = []chan *protocol.StorageRequest{make(chan *protocol.StorageRequest, 1)}
+	module.workerBeats = make([]protocol.Heartbeat, 1)
+	module.workerBeats[0].Beat()
+	assert.NoError(t, module.checkHealth(), "Expected no error for an empty queue")
+
+	module.workers[0] <- &protocol.StorageRequest{RequestType: protocol.StorageFetchClusters}
+	assert.NoError(t, module.checkHealth(), "Expected no error for a worker that beat recently")
+
+	module.healthThreshold = 0
+	time.Sleep(2 * time.Millisecond)
+	assert.Error(t, module.checkHealth(), "Expected an error for a worker that has not handled the waiting request")
+}
+
+func TestInMemoryStorage_Configure_BadHealthThreshold(t *testing.T) {
+	module := fixtureModule("", "")
+	viper.Set("storage.test.health-threshold", -1)
+	assert.Panics(t, func() { module.Configure("test", "storage.test") }, "The code did not panic")
+}
+
+func TestInMemoryStorage_Configure_ClusterIntervals(t *testing.T) {
+	module := fixtureModule("", "")
+	viper.Set("storage.test.intervals-clusters", map[string]interface{}{"TestCluster": 3})
+	module.Configure("test", "storage.test")
+	assert.Equal(t, 3, module.getClusterIntervals("testcluster"), "Expected the cluster intervals to be used")
+	assert.Equal(t, 10, module.getClusterIntervals("othercluster"), "Expected the default intervals to be used")
+
+	viper.Set("storage.test.intervals-clusters", map[string]interface{}{"testcluster": 0})
+	assert.Panics(t, func() { module.Configure("test", "storage.test") }, "The code did not panic")
+}
+
 func TestInMemoryStorage_Configure_BadAllowlistRegexp(t *testing.T) {
 	module := fixtureModule("", "")
 	viper.Set("storage.test.group-allowlist", "[")
@@ -124,6 +160,24 @@ func TestInMemoryStorage_Configure_BadDenylistRegexp(t *testing.T) {
 	assert.Panics(t, func() { module.Configure("test", "storage.test") }, "The code did not panic")
 }
 
+func TestInMemoryStorage_ReloadFilters(t *testing.T) {
+	module := fixtureModule("test.*", "")
+	module.Configure("test", "storage.test")
+	assert.False(t, module.acceptConsumerGroup("othergroup"), "Expected othergroup to be dropped by the allowlist")
+
+	// The filters come from the provided configuration, not the global one
+	config := viper.New()
+	config.Set("storage.test.group-denylist", "^test")
+	assert.NoError(t, module.ReloadFilters(config))
+	assert.True(t, module.acceptConsumerGroup("othergroup"), "Expected othergroup to pass with no allowlist")
+	assert.False(t, module.acceptConsumerGroup("testgroup"), "Expected testgroup to be dropped by the reloaded denylist")
+
+	// An invalid filter keeps the current ones
+	config.Set("storage.test.group-denylist", "[")
+	assert.Error(t, module.ReloadFilters(config))
+	assert.False(t, module.acceptConsumerGroup("testgroup"), "Expected the previous denylist to be kept")
+}
+
 func TestInMemoryStorage_Start(t *testing.T) {
 	module := startWithTestCluster("")
 	assert.Len(t, module.offsets, 1, "Module start did not define 1 cluster")
@@ -774,6 +828,210 @@ func TestInMemoryStorage_addConsumerOwner(t *testing.T) {
 	assert.Equal(t, "test_client_id", partitions[0].clientID, "Expected clientID to be test_client_id, not %v", partitions[0].clientID)
 }
 
+func TestInMemoryStorage_setConsumerGeneration(t *testing.T) {
+	startTime := (time.Now().Unix() * 1000) - 100000
+	module := startWithTestConsumerOffsets("", startTime)
+
+	request := protocol.StorageRequest{
+		RequestType: protocol.StorageSetConsumerGeneration,
+		Cluster:     "testcluster",
+		Group:       "testgroup",
+		Generation:  4,
+		Timestamp:   startTime,
+	}
+	module.setConsumerGeneration(&request, module.Log)
+
+	consumerMap := module.offsets["testcluster"].consumer["testgroup"]
+	assert.Equal(t, int32(4), consumerMap.generation, "Expected generation to be 4, not %v", consumerMap.generation)
+	assert.Equal(t, startTime, consumerMap.generationTime, "Expected generation time to be set")
+	assert.Equal(t, uint64(0), consumerMap.rebalances, "Expected the first generation seen not to count as a rebalance")
+
+	// The same generation again does not count either
+	request.Timestamp = startTime + 10000
+	module.setConsumerGeneration(&request, module.Log)
+	assert.Equal(t, startTime, consumerMap.generationTime, "Expected generation time to be unchanged")
+	assert.Equal(t, uint64(0), consumerMap.rebalances, "Expected the same generation not to count as a rebalance")
+
+	request.Generation = 5
+	request.Timestamp = startTime + 20000
+	module.setConsumerGeneration(&request, module.Log)
+	assert.Equal(t, int32(5), consumerMap.generation, "Expected generation to be 5, not %v", consumerMap.generation)
+	assert.Equal(t, startTime+20000, consumerMap.generationTime, "Expected generation time to be updated")
+	assert.Equal(t, uint64(1), consumerMap.rebalances, "Expected one rebalance, not %v", consumerMap.rebalances)
+}
+
+func TestInMemoryStorage_setConsumerGeneration_NewGroup(t *testing.T) {
+	module := startWithTestCluster("")
+
+	request := protocol.StorageRequest{
+		RequestType: protocol.StorageSetConsumerGeneration,
+		Cluster:     "testcluster",
+		Group:       "testgroup",
+		Generation:  1,
+		Timestamp:   1000,
+	}
+	module.setConsumerGeneration(&request, module.Log)
+
+	consumerMap, ok := module.offsets["testcluster"].consumer["testgroup"]
+	assert.True(t, ok, "Group not created")
+	assert.Equal(t, int32(1), consumerMap.generation, "Expected generation to be 1, not %v", consumerMap.generation)
+}
+
+func TestInMemoryStorage_setConsumerGeneration_Allowlist(t *testing.T) {
+	module := startWithTestCluster("allowlistedgroup")
+
+	request := protocol.StorageRequest{
+		RequestType: protocol.StorageSetConsumerGeneration,
+		Cluster:     "testcluster",
+		Group:       "testgroup",
+		Generation:  1,
+		Timestamp:   1000,
+	}
+	module.setConsumerGeneration(&request, module.Log)
+
+	_, ok := module.offsets["testcluster"].consumer["testgroup"]
+	assert.False(t, ok, "Group testgroup created when not allowlisted")
+}
+
+func TestInMemoryStorage_setConsumerMembers(t *testing.T) {
+	startTime := (time.Now().Unix() * 1000) - 100000
+	module := startWithTestConsumerOffsets("", startTime)
+
+	request := protocol.StorageRequest{
+		RequestType: protocol.StorageSetConsumerMembers,
+		Cluster:     "testcluster",
+		Group:       "testgroup",
+		MemberCount: 3,
+		Timestamp:   startTime,
+	}
+	module.setConsumerMembers(&request, module.Log)
+
+	consumerMap := module.offsets["testcluster"].consumer["testgroup"]
+	assert.Equal(t, int32(3), consumerMap.memberCount, "Expected member count to be 3, not %v", consumerMap.memberCount)
+	assert.Equal(t, startTime, consumerMap.membersTime, "Expected members time to be set")
+
+	// A group that has no members any more is stored as well
+	request.MemberCount = 0
+	request.Timestamp = startTime + 10000
+	module.setConsumerMembers(&request, module.Log)
+	assert.Equal(t, int32(0), consumerMap.memberCount, "Expected member count to be 0, not %v", consumerMap.memberCount)
+	assert.Equal(t, startTime+10000, consumerMap.membersTime, "Expected members time to be updated")
+}
+
+func TestInMemoryStorage_setConsumerMembers_NewGroup(t *testing.T) {
+	module := startWithTestCluster("")
+
+	request := protocol.StorageRequest{
+		RequestType: protocol.StorageSetConsumerMembers,
+		Cluster:     "testcluster",
+		Group:       "testgroup",
+		MemberCount: 1,
+		Timestamp:   1000,
+	}
+	module.setConsumerMembers(&request, module.Log)
+
+	_, ok := module.offsets["testcluster"].consumer["testgroup"]
+	assert.False(t, ok, "Group testgroup created by members request")
+}
+
+func TestInMemoryStorage_setConsumerTimeLag(t *testing.T) {
+	startTime := (time.Now().Unix() * 1000) - 100000
+	module := startWithTestConsumerOffsets("", startTime)
+
+	request := protocol.StorageRequest{
+		RequestType: protocol.StorageSetConsumerTimeLag,
+		Cluster:     "testcluster",
+		Group:       "testgroup",
+		Topic:       "testtopic",
+		Partition:   0,
+		Offset:      1900,
+		TimeLag:     45000,
+	}
+	module.setConsumerTimeLag(&request, module.Log)
+
+	consumerMap := module.offsets["testcluster"].consumer["testgroup"]
+	assert.Equal(t, int64(45000), consumerMap.topics["testtopic"][0].timeLag, "Expected time lag to be 45000")
+	topics := getConsumerTopicList(consumerMap)
+	assert.Equal(t, int64(45000), topics["testtopic"][0].TimeLag, "Expected time lag to be returned for the partition")
+
+	// Requests for partitions that are not stored are dropped
+	request.Partition = 1
+	request.TimeLag = 1000
+	module.setConsumerTimeLag(&request, module.Log)
+	assert.Len(t, consumerMap.topics["testtopic"], 1, "Expected no partition to be added")
+
+	request.Partition = 0
+	request.Group = "othergroup"
+	module.setConsumerTimeLag(&request, module.Log)
+	_, ok := module.offsets["testcluster"].consumer["othergroup"]
+	assert.False(t, ok, "Group othergroup created by time lag request")
+}
+
+func TestInMemoryStorage_setClusterBrokers(t *testing.T) {
+	module := startWithTestCluster("")
+
+	// Before the brokers are set, there is no controller
+	request := protocol.StorageRequest{
+		RequestType: protocol.StorageFetchClusterBrokers,
+		Cluster:     "testcluster",
+		Reply:       make(chan interface{}),
+	}
+	go module.fetchClusterBrokers(&request, module.Log)
+	brokers := (<-request.Reply).(*protocol.ClusterBrokers)
+	assert.Equal(t, &protocol.ClusterBrokers{ControllerID: -1}, brokers, "Expected no controller before the brokers are set")
+
+	module.setClusterBrokers(&protocol.StorageRequest{
+		RequestType:  protocol.StorageSetClusterBrokers,
+		Cluster:      "testcluster",
+		ControllerID: 2,
+		BrokerCount:  3,
+		Timestamp:    9876,
+	}, module.Log)
+
+	request.Reply = make(chan interface{})
+	go module.fetchClusterBrokers(&request, module.Log)
+	brokers = (<-request.Reply).(*protocol.ClusterBrokers)
+	assert.Equal(t, &protocol.ClusterBrokers{ControllerID: 2, BrokerCount: 3, Timestamp: 9876}, brokers, "Expected the brokers that were set")
+}
+
+func TestInMemoryStorage_fetchClusterBrokers_LastUpdate(t *testing.T) {
+	module := startWithTestBrokerOffsets("")
+
+	// An offset with an older timestamp does not move the last update back
+	module.addBrokerOffset(&protocol.StorageRequest{
+		RequestType:         protocol.StorageSetBrokerOffset,
+		Cluster:             "testcluster",
+		Topic:               "testtopic",
+		Partition:           0,
+		TopicPartitionCount: 1,
+		Offset:              4322,
+		Timestamp:           1000,
+	}, module.Log)
+
+	request := protocol.StorageRequest{
+		RequestType: protocol.StorageFetchClusterBrokers,
+		Cluster:     "testcluster",
+		Reply:       make(chan interface{}),
+	}
+	go module.fetchClusterBrokers(&request, module.Log)
+	brokers := (<-request.Reply).(*protocol.ClusterBrokers)
+	assert.Equalf(t, int64(9876), brokers.LastUpdate, "Expected last update to be 9876, not %v", brokers.LastUpdate)
+}
+
+func TestInMemoryStorage_fetchClusterBrokers_BadCluster(t *testing.T) {
+	module := startWithTestCluster("")
+
+	request := protocol.StorageRequest{
+		RequestType: protocol.StorageFetchClusterBrokers,
+		Cluster:     "nocluster",
+		Reply:       make(chan interface{}),
+	}
+	go module.fetchClusterBrokers(&request, module.Log)
+	response, ok := <-request.Reply
+	assert.Nil(t, response, "Expected response to be nil")
+	assert.False(t, ok, "Expected channel to be closed")
+}
+
 func TestInMemoryStorage_deleteTopic(t *testing.T) {
 	startTime := (time.Now().Unix() * 1000) - 100000
 	module := startWithTestConsumerOffsets("", startTime)
@@ -783,10 +1041,13 @@ func TestInMemoryStorage_deleteTopic(t *testing.T) {
 		Cluster:     "testcluster",
 		Topic:       "testtopic",
 	}
+	module.offsets["testcluster"].logStart["testtopic"] = map[int32]int64{0: 100}
 	module.deleteTopic(&request, module.Log)
 
 	_, ok := module.offsets["testcluster"].broker["testtopic"]
 	assert.False(t, ok, "Topic not deleted from broker offsets")
+	_, ok = module.offsets["testcluster"].logStart["testtopic"]
+	assert.False(t, ok, "Topic not deleted from log start offsets")
 	consumerMap := module.offsets["testcluster"].consumer["testgroup"]
 	_, ok = consumerMap.topics["testtopic"]
 	assert.False(t, ok, "Topic not deleted from group offsets")
@@ -872,6 +1133,96 @@ func TestInMemoryStorage_deleteGroup_NoGroup(t *testing.T) {
 	assert.True(t, ok, "Wrong group deleted from consumer offsets")
 }
 
+func TestInMemoryStorage_evictGroup(t *testing.T) {
+	startTime := (time.Now().Unix() * 1000) - 100000
+	module := startWithTestConsumerOffsets("", startTime)
+
+	// A topic that the group does not consume leaves the group in place
+	request := protocol.StorageRequest{
+		RequestType: protocol.StorageSetEvictGroup,
+		Reply:       make(chan interface{}),
+		Cluster:     "testcluster",
+		Group:       "testgroup",
+		Topic:       "notopic",
+	}
+	go module.evictGroup(&request, module.Log)
+	assert.Equal(t, false, <-request.Reply, "Expected the topic to not be found")
+	_, ok := module.offsets["testcluster"].consumer["testgroup"]
+	assert.True(t, ok, "Group deleted from consumer offsets")
+
+	request = protocol.StorageRequest{
+		RequestType: protocol.StorageSetEvictGroup,
+		Reply:       make(chan interface{}),
+		Cluster:     "testcluster",
+		Group:       "testgroup",
+	}
+	go module.evictGroup(&request, module.Log)
+	assert.Equal(t, true, <-request.Reply, "Expected the group to be found")
+	_, ok = module.offsets["testcluster"].consumer["testgroup"]
+	assert.False(t, ok, "Group not deleted from consumer offsets")
+
+	// Once it is gone, it is not found again
+	request.Reply = make(chan interface{})
+	go module.evictGroup(&request, module.Log)
+	assert.Equal(t, false, <-request.Reply, "Expected the group to not be found")
+}
+
+func TestInMemoryStorage_evictGroup_BadCluster(t *testing.T) {
+	startTime := (time.Now().Unix() * 1000) - 100000
+	module := startWithTestConsumerOffsets("", startTime)
+
+	request := protocol.StorageRequest{
+		RequestType: protocol.StorageSetEvictGroup,
+		Reply:       make(chan interface{}),
+		Cluster:     "nocluster",
+		Group:       "testgroup",
+	}
+	go module.evictGroup(&request, module.Log)
+	assert.Equal(t, false, <-request.Reply, "Expected the cluster to not be found")
+	_, ok := module.offsets["testcluster"].consumer["testgroup"]
+	assert.True(t, ok, "Group deleted in wrong cluster")
+}
+
+func TestInMemoryStorage_addCluster(t *testing.T) {
+	startTime := (time.Now().Unix() * 1000) - 100000
+	module := startWithTestConsumerOffsets("", startTime)
+
+	module.addCluster(&protocol.StorageRequest{
+		RequestType: protocol.StorageSetAddCluster,
+		Cluster:     "newcluster",
+	}, module.Log)
+	clusterMap, ok := module.getClusterOffsets("newcluster")
+	assert.True(t, ok, "Expected newcluster to be added")
+	assert.Lenf(t, clusterMap.consumer, 0, "Expected newcluster to have no groups, not %v", len(clusterMap.consumer))
+
+	// Adding a cluster that exists keeps its offsets
+	module.addCluster(&protocol.StorageRequest{
+		RequestType: protocol.StorageSetAddCluster,
+		Cluster:     "testcluster",
+	}, module.Log)
+	clusterMap, _ = module.getClusterOffsets("testcluster")
+	assert.Contains(t, clusterMap.consumer, "testgroup", "Expected testcluster to keep its groups")
+}
+
+func TestInMemoryStorage_deleteCluster(t *testing.T) {
+	startTime := (time.Now().Unix() * 1000) - 100000
+	module := startWithTestConsumerOffsets("", startTime)
+
+	module.deleteCluster(&protocol.StorageRequest{
+		RequestType: protocol.StorageSetDeleteCluster,
+		Cluster:     "testcluster",
+	}, module.Log)
+	_, ok := module.getClusterOffsets("testcluster")
+	assert.False(t, ok, "Expected testcluster to be deleted")
+
+	request := protocol.StorageRequest{
+		RequestType: protocol.StorageFetchClusters,
+		Reply:       make(chan interface{}),
+	}
+	go module.fetchClusterList(&request, module.Log)
+	assert.Len(t, (<-request.Reply).([]string), 0, "Expected no clusters to be returned")
+}
+
 func TestInMemoryStorage_fetchClusterList(t *testing.T) {
 	startTime := (time.Now().Unix() * 1000) - 100000
 	module := startWithTestConsumerOffsets("", startTime)
@@ -958,6 +1309,101 @@ func TestInMemoryStorage_fetchConsumerList(t *testing.T) {
 	assert.False(t, ok, "Expected channel to be closed")
 }
 
+func TestInMemoryStorage_fetchConsumerList_Expired(t *testing.T) {
+	startTime := (time.Now().Unix() * 1000) - 100000
+	module := startWithTestConsumerOffsets("", startTime)
+
+	clusterMap := module.offsets["testcluster"]
+	clusterMap.consumer["expiredgroup"] = &consumerGroup{
+		lock:       &sync.RWMutex{},
+		topics:     make(map[string][]*consumerPartition),
+		lastCommit: (time.Now().Unix() - module.expireGroup - 60) * 1000,
+	}
+
+	request := protocol.StorageRequest{
+		RequestType: protocol.StorageFetchConsumers,
+		Cluster:     "testcluster",
+		Reply:       make(chan interface{}),
+	}
+	go module.fetchConsumerList(&request, module.Log)
+	response := <-request.Reply
+
+	assert.Equal(t, []string{"testgroup"}, response, "Expected the expired group to not be listed")
+}
+
+func TestInMemoryStorage_expireGroups(t *testing.T) {
+	startTime := (time.Now().Unix() * 1000) - 100000
+	module := startWithTestConsumerOffsets("", startTime)
+
+	clusterMap := module.offsets["testcluster"]
+	clusterMap.consumer["expiredgroup"] = &consumerGroup{
+		lock:       &sync.RWMutex{},
+		topics:     make(map[string][]*consumerPartition),
+		lastCommit: (time.Now().Unix() - module.expireGroup - 60) * 1000,
+	}
+
+	module.expireGroups()
+	assert.Contains(t, clusterMap.consumer, "testgroup", "Expected the active group to be kept")
+	assert.NotContains(t, clusterMap.consumer, "expiredgroup", "Expected the expired group to be removed")
+
+	// The group comes back when it commits again
+	module.addConsumerOffset(&protocol.StorageRequest{
+		RequestType: protocol.StorageSetConsumerOffset,
+		Cluster:     "testcluster",
+		Topic:       "testtopic",
+		Group:       "expiredgroup",
+		Partition:   0,
+		Offset:      2000,
+		Order:       1,
+		Timestamp:   time.Now().Unix() * 1000,
+	}, module.Log)
+	assert.Contains(t, clusterMap.consumer, "expiredgroup", "Expected the group to be added again after a commit")
+}
+
+func TestInMemoryStorage_expireGroups_NeverExpire(t *testing.T) {
+	module := fixtureModule("", "")
+	viper.Set("storage.test.never-expire-groups", "^nightly-")
+	viper.Set("cluster.testcluster.class-name", "kafka")
+	viper.Set("cluster.testcluster.servers", []string{"broker1.example.com:1234"})
+	module.Configure("test", "storage.test")
+	module.Start()
+
+	clusterMap := module.offsets["testcluster"]
+	for _, group := range []string{"nightly-etl", "expiredgroup"} {
+		clusterMap.consumer[group] = &consumerGroup{
+			lock:       &sync.RWMutex{},
+			topics:     make(map[string][]*consumerPartition),
+			lastCommit: (time.Now().Unix() - module.expireGroup - 60) * 1000,
+		}
+	}
+
+	module.expireGroups()
+	assert.Contains(t, clusterMap.consumer, "nightly-etl", "Expected the exempt group to be kept")
+	assert.NotContains(t, clusterMap.consumer, "expiredgroup", "Expected the expired group to be removed")
+
+	request := protocol.StorageRequest{
+		RequestType: protocol.StorageFetchConsumers,
+		Cluster:     "testcluster",
+		Reply:       make(chan interface{}),
+	}
+	go module.fetchConsumerList(&request, module.Log)
+	assert.Equal(t, []string{"nightly-etl"}, <-request.Reply, "Expected the exempt group to be listed")
+}
+
+func TestInMemoryStorage_Configure_BadNeverExpireGroups(t *testing.T) {
+	module := fixtureModule("", "")
+	viper.Set("storage.test.never-expire-groups", "[")
+
+	assert.Panics(t, func() { module.Configure("test", "storage.test") }, "The code did not panic")
+}
+
+func TestInMemoryStorage_Configure_BadExpireGroupRefresh(t *testing.T) {
+	module := fixtureModule("", "")
+	viper.Set("storage.test.expire-group-refresh", -1)
+
+	assert.Panics(t, func() { module.Configure("test", "storage.test") }, "The code did not panic")
+}
+
 func TestInMemoryStorage_fetchConsumerList_BadCluster(t *testing.T) {
 	startTime := (time.Now().Unix() * 1000) - 100000
 	module := startWithTestConsumerOffsets("", startTime)
@@ -1000,6 +1446,251 @@ func TestInMemoryStorage_fetchTopic(t *testing.T) {
 	assert.False(t, ok, "Expected channel to be closed")
 }
 
+func TestInMemoryStorage_fetchTopicRegressed(t *testing.T) {
+	module := startWithTestBrokerOffsets("")
+
+	request := protocol.StorageRequest{
+		RequestType: protocol.StorageFetchTopicRegressed,
+		Cluster:     "testcluster",
+		Topic:       "testtopic",
+		Reply:       make(chan interface{}),
+	}
+	go module.fetchTopicRegressed(&request, module.Log)
+	response := <-request.Reply
+	assert.Equalf(t, []bool{false}, response, "Expected partition not to be regressed, not %v", response)
+
+	// A regressed offset is flagged until the next offset replaces it
+	module.addBrokerOffset(&protocol.StorageRequest{
+		RequestType:         protocol.StorageSetBrokerOffset,
+		Cluster:             "testcluster",
+		Topic:               "testtopic",
+		Partition:           0,
+		TopicPartitionCount: 1,
+		Offset:              1234,
+		Timestamp:           9877,
+		Regressed:           true,
+	}, module.Log)
+
+	request.Reply = make(chan interface{})
+	go module.fetchTopicRegressed(&request, module.Log)
+	response = <-request.Reply
+	assert.Equalf(t, []bool{true}, response, "Expected partition to be regressed, not %v", response)
+
+	request.Topic = "notopic"
+	request.Reply = make(chan interface{})
+	go module.fetchTopicRegressed(&request, module.Log)
+	response, ok := <-request.Reply
+	assert.Nil(t, response, "Expected response to be nil")
+	assert.False(t, ok, "Expected channel to be closed")
+}
+
+func TestInMemoryStorage_fetchTopicOffsets(t *testing.T) {
+	module := startWithTestBrokerOffsets("")
+
+	// Add a second offset for the partition, and a second topic with a single offset
+	module.addBrokerOffset(&protocol.StorageRequest{
+		RequestType:         protocol.StorageSetBrokerOffset,
+		Cluster:             "testcluster",
+		Topic:               "testtopic",
+		Partition:           0,
+		TopicPartitionCount: 1,
+		Offset:              5432,
+		Timestamp:           19876,
+	}, module.Log)
+	module.addBrokerOffset(&protocol.StorageRequest{
+		RequestType:         protocol.StorageSetBrokerOffset,
+		Cluster:             "testcluster",
+		Topic:               "othertopic",
+		Partition:           1,
+		TopicPartitionCount: 2,
+		Offset:              100,
+		Timestamp:           9876,
+	}, module.Log)
+
+	request := protocol.StorageRequest{
+		RequestType: protocol.StorageFetchTopicOffsets,
+		Cluster:     "testcluster",
+		Reply:       make(chan interface{}),
+	}
+	go module.fetchTopicOffsets(&request, module.Log)
+	response := <-request.Reply
+
+	topicOffsets, ok := response.(map[string][]*protocol.TopicPartitionOffsets)
+	assert.True(t, ok, "Expected response to be of type map[string][]*protocol.TopicPartitionOffsets")
+	assert.Lenf(t, topicOffsets, 2, "Expected 2 topics, not %v", len(topicOffsets))
+	assert.Equal(t, []*protocol.TopicPartitionOffsets{{Partition: 0, Oldest: 4321, Newest: 5432, Timestamp: 19876}}, topicOffsets["testtopic"], "Expected the range of stored offsets for testtopic")
+
+	// The partition without offsets is skipped
+	assert.Equal(t, []*protocol.TopicPartitionOffsets{{Partition: 1, Oldest: 100, Newest: 100, Timestamp: 9876}}, topicOffsets["othertopic"], "Expected only partition 1 for othertopic")
+
+	// With a topic, only that topic is returned
+	request.Topic = "othertopic"
+	request.Reply = make(chan interface{})
+	go module.fetchTopicOffsets(&request, module.Log)
+	response = <-request.Reply
+	topicOffsets = response.(map[string][]*protocol.TopicPartitionOffsets)
+	assert.Lenf(t, topicOffsets, 1, "Expected 1 topic, not %v", len(topicOffsets))
+	assert.Contains(t, topicOffsets, "othertopic", "Expected othertopic to be returned")
+
+	request.Topic = "notopic"
+	request.Reply = make(chan interface{})
+	go module.fetchTopicOffsets(&request, module.Log)
+	response, ok = <-request.Reply
+	assert.Nil(t, response, "Expected response to be nil")
+	assert.False(t, ok, "Expected channel to be closed")
+
+	request.Cluster = "nocluster"
+	request.Topic = ""
+	request.Reply = make(chan interface{})
+	go module.fetchTopicOffsets(&request, module.Log)
+	response, ok = <-request.Reply
+	assert.Nil(t, response, "Expected response to be nil")
+	assert.False(t, ok, "Expected channel to be closed")
+}
+
+func TestInMemoryStorage_ClusterIntervals(t *testing.T) {
+	module := fixtureModule("", "")
+	viper.Set("cluster.testcluster.class-name", "kafka")
+	viper.Set("cluster.testcluster.servers", []string{"broker1.example.com:1234"})
+	viper.Set("storage.test.intervals-clusters", map[string]interface{}{"testcluster": 3})
+	module.Configure("test", "storage.test")
+	module.Start()
+	defer module.Stop()
+
+	startTime := (time.Now().Unix() * 1000) - 100000
+	module.addBrokerOffset(&protocol.StorageRequest{
+		RequestType:         protocol.StorageSetBrokerOffset,
+		Cluster:             "testcluster",
+		Topic:               "testtopic",
+		Partition:           0,
+		TopicPartitionCount: 1,
+		Offset:              4321,
+		Timestamp:           9876,
+	}, module.Log)
+	module.addConsumerOffset(&protocol.StorageRequest{
+		RequestType: protocol.StorageSetConsumerOffset,
+		Cluster:     "testcluster",
+		Topic:       "testtopic",
+		Group:       "testgroup",
+		Partition:   0,
+		Offset:      1000,
+		Order:       500,
+		Timestamp:   startTime,
+	}, module.Log)
+
+	clusterMap := module.offsets["testcluster"]
+	assert.Equal(t, 3, clusterMap.intervals, "Expected the cluster to use 3 intervals")
+	assert.Equal(t, 3, clusterMap.broker["testtopic"][0].Len(), "Expected the broker ring to have 3 entries")
+	assert.Equal(t, 3, clusterMap.consumer["testgroup"].topics["testtopic"][0].offsets.Len(), "Expected the consumer ring to have 3 entries")
+}
+
+func TestInMemoryStorage_fetchStats(t *testing.T) {
+	startTime := (time.Now().Unix() * 1000) - 100000
+	module := startWithTestConsumerOffsets("", startTime)
+
+	request := protocol.StorageRequest{
+		RequestType: protocol.StorageFetchStats,
+		Reply:       make(chan interface{}),
+	}
+	go module.fetchStats(&request, module.Log)
+	response := <-request.Reply
+
+	stats, ok := response.(map[string]*protocol.StorageClusterStats)
+	assert.True(t, ok, "Expected response to be of type map[string]*protocol.StorageClusterStats")
+	clusterStats := stats["testcluster"]
+	assert.Equalf(t, 10, clusterStats.Intervals, "Expected Intervals to be 10, not %v", clusterStats.Intervals)
+	assert.Equalf(t, 1, clusterStats.Topics, "Expected Topics to be 1, not %v", clusterStats.Topics)
+	assert.Equalf(t, 1, clusterStats.Partitions, "Expected Partitions to be 1, not %v", clusterStats.Partitions)
+	assert.Equalf(t, 1, clusterStats.BrokerBuffers, "Expected BrokerBuffers to be 1, not %v", clusterStats.BrokerBuffers)
+	assert.Equalf(t, 1, clusterStats.Groups, "Expected Groups to be 1, not %v", clusterStats.Groups)
+	assert.Equalf(t, 0, clusterStats.Consumers, "Expected Consumers to be 0, not %v", clusterStats.Consumers)
+	assert.Equalf(t, 1, clusterStats.ConsumerBuffers, "Expected ConsumerBuffers to be 1, not %v", clusterStats.ConsumerBuffers)
+	assert.Equalf(t, 20, clusterStats.BufferSlots, "Expected BufferSlots to be 20, not %v", clusterStats.BufferSlots)
+	estimate := clusterStats.EstimatedBytes
+	assert.True(t, estimate > 10*(brokerSlotBytes+consumerSlotBytes), "Expected EstimatedBytes to include the buffer slots, not %v", estimate)
+
+	// An owned partition adds a consumer, and grows the estimate
+	module.addConsumerOwner(&protocol.StorageRequest{
+		RequestType: protocol.StorageSetConsumerOwner,
+		Cluster:     "testcluster",
+		Topic:       "testtopic",
+		Group:       "testgroup",
+		Partition:   0,
+		Owner:       "testhost.example.com",
+		ClientID:    "test_client_id",
+	}, module.Log)
+
+	request.Reply = make(chan interface{})
+	go module.fetchStats(&request, module.Log)
+	stats = (<-request.Reply).(map[string]*protocol.StorageClusterStats)
+	assert.Equalf(t, 1, stats["testcluster"].Consumers, "Expected Consumers to be 1, not %v", stats["testcluster"].Consumers)
+	assert.True(t, stats["testcluster"].EstimatedBytes > estimate, "Expected EstimatedBytes to grow")
+}
+
+func TestInMemoryStorage_fetchConsumerPage(t *testing.T) {
+	module := startWithTestCluster("")
+	clusterMap := module.offsets["testcluster"]
+	for _, group := range []string{"testgroup3", "othergroup", "testgroup1", "testgroup4", "testgroup2"} {
+		clusterMap.consumer[group] = &consumerGroup{
+			lock:       &sync.RWMutex{},
+			topics:     make(map[string][]*consumerPartition),
+			lastCommit: time.Now().Unix() * 1000,
+		}
+	}
+	// Expired groups are not listed
+	clusterMap.consumer["testgroup5"] = &consumerGroup{
+		lock:   &sync.RWMutex{},
+		topics: make(map[string][]*consumerPartition),
+	}
+
+	var pageTests = []struct {
+		prefix    string
+		limit     int
+		skip      int
+		consumers []string
+		total     int
+	}{
+		/*0*/ {"", 0, 0, []string{"othergroup", "testgroup1", "testgroup2", "testgroup3", "testgroup4"}, 5},
+		/*1*/ {"", 2, 0, []string{"othergroup", "testgroup1"}, 5},
+		/*2*/ {"", 2, 2, []string{"testgroup2", "testgroup3"}, 5},
+		/*3*/ {"", 2, 4, []string{"testgroup4"}, 5},
+		/*4*/ {"", 2, 6, []string{}, 5},
+		/*5*/ {"test", 3, 1, []string{"testgroup2", "testgroup3", "testgroup4"}, 4},
+		/*6*/ {"none", 0, 0, []string{}, 0},
+	}
+	for i, testSet := range pageTests {
+		request := protocol.StorageRequest{
+			RequestType: protocol.StorageFetchConsumersPage,
+			Cluster:     "testcluster",
+			Prefix:      testSet.prefix,
+			Limit:       testSet.limit,
+			Skip:        testSet.skip,
+			Reply:       make(chan interface{}),
+		}
+		go module.fetchConsumerPage(&request, module.Log)
+		response := <-request.Reply
+
+		page, ok := response.(*protocol.ConsumerListPage)
+		assert.Truef(t, ok, "TEST %v: Expected response to be of type *protocol.ConsumerListPage", i)
+		assert.Equalf(t, testSet.consumers, page.Consumers, "TEST %v: Expected consumers %v, not %v", i, testSet.consumers, page.Consumers)
+		assert.Equalf(t, testSet.total, page.Total, "TEST %v: Expected total %v, not %v", i, testSet.total, page.Total)
+	}
+}
+
+func TestInMemoryStorage_fetchConsumerPage_BadCluster(t *testing.T) {
+	module := startWithTestCluster("")
+
+	request := protocol.StorageRequest{
+		RequestType: protocol.StorageFetchConsumersPage,
+		Cluster:     "nocluster",
+		Reply:       make(chan interface{}),
+	}
+	go module.fetchConsumerPage(&request, module.Log)
+	response, ok := <-request.Reply
+	assert.Nil(t, response, "Expected response to be nil")
+	assert.False(t, ok, "Expected channel to be closed")
+}
+
 func TestInMemoryStorage_fetchTopic_BadCluster(t *testing.T) {
 	startTime := (time.Now().Unix() * 1000) - 100000
 	module := startWithTestConsumerOffsets("", startTime)
@@ -1076,6 +1767,8 @@ func TestInMemoryStorage_fetchConsumer(t *testing.T) {
 	assert.Equalf(t, uint64(2421), val["testtopic"][0].CurrentLag, "Expected current lag to be 2421, not %v", val["testtopic"][0].CurrentLag)
 	assert.Equalf(t, "testhost.example.com", val["testtopic"][0].Owner, "Expected owner to be testhost.example.com, not %v", val["testtopic"][0].Owner)
 	assert.Equalf(t, "test_client_id", val["testtopic"][0].ClientID, "Expected client_id to be test_client_id, not %v", val["testtopic"][0].ClientID)
+	assert.Equalf(t, int64(9876), val["testtopic"][0].LastBrokerOffsetTimestamp, "Expected last broker offset timestamp to be 9876, not %v", val["testtopic"][0].LastBrokerOffsetTimestamp)
+	assert.False(t, val["testtopic"][0].BelowLogStart, "Expected BelowLogStart to be false without a log start")
 
 	offsets := val["testtopic"][0].Offsets
 	assert.Lenf(t, offsets, 10, "Expected to get 10 offsets for the partition, not %v", len(offsets))
@@ -1097,6 +1790,47 @@ func TestInMemoryStorage_fetchConsumer(t *testing.T) {
 	assert.False(t, ok, "Expected channel to be closed")
 }
 
+func TestInMemoryStorage_fetchConsumer_BelowLogStart(t *testing.T) {
+	startTime := (time.Now().Unix() * 1000) - 100000
+	module := startWithTestConsumerOffsets("", startTime)
+
+	// The last committed offset is 1900, so a log start above that means the consumer missed messages
+	for _, logStart := range []int64{1900, 2000} {
+		module.setBrokerLogStart(&protocol.StorageRequest{
+			RequestType: protocol.StorageSetBrokerLogStart,
+			Cluster:     "testcluster",
+			Topic:       "testtopic",
+			Partition:   0,
+			Offset:      logStart,
+		}, module.Log)
+
+		request := protocol.StorageRequest{
+			RequestType: protocol.StorageFetchConsumer,
+			Cluster:     "testcluster",
+			Group:       "testgroup",
+			Reply:       make(chan interface{}),
+		}
+		go module.fetchConsumer(&request, module.Log)
+		val := (<-request.Reply).(protocol.ConsumerTopics)
+		assert.Equalf(t, logStart > 1900, val["testtopic"][0].BelowLogStart, "Log start %v: Expected BelowLogStart to be %v", logStart, logStart > 1900)
+	}
+}
+
+func TestInMemoryStorage_setBrokerLogStart_NoTopic(t *testing.T) {
+	module := startWithTestBrokerOffsets("")
+
+	module.setBrokerLogStart(&protocol.StorageRequest{
+		RequestType: protocol.StorageSetBrokerLogStart,
+		Cluster:     "testcluster",
+		Topic:       "notopic",
+		Partition:   0,
+		Offset:      100,
+	}, module.Log)
+
+	_, ok := module.offsets["testcluster"].logStart["notopic"]
+	assert.False(t, ok, "Expected log start for an unknown topic to be dropped")
+}
+
 func TestInMemoryStorage_fetchConsumer_BadCluster(t *testing.T) {
 	startTime := (time.Now().Unix() * 1000) - 100000
 	module := startWithTestConsumerOffsets("", startTime)
@@ -1184,6 +1918,117 @@ func TestInMemoryStorage_fetchConsumer_Expired(t *testing.T) {
 
 // TODO: Test for clear consumer offsets, including clear for missing group
 
+func TestInMemoryStorage_fetchConsumerGeneration(t *testing.T) {
+	startTime := (time.Now().Unix() * 1000) - 100000
+	module := startWithTestConsumerOffsets("", startTime)
+
+	request := protocol.StorageRequest{
+		RequestType: protocol.StorageFetchConsumerGeneration,
+		Cluster:     "testcluster",
+		Group:       "testgroup",
+		Reply:       make(chan interface{}),
+	}
+
+	// No generation has been seen for the group yet
+	go module.fetchConsumerGeneration(&request, module.Log)
+	response, ok := <-request.Reply
+	assert.Nil(t, response, "Expected response to be nil")
+	assert.False(t, ok, "Expected channel to be closed")
+
+	for generation := int32(1); generation <= 3; generation++ {
+		module.setConsumerGeneration(&protocol.StorageRequest{
+			RequestType: protocol.StorageSetConsumerGeneration,
+			Cluster:     "testcluster",
+			Group:       "testgroup",
+			Generation:  generation,
+			Timestamp:   startTime + int64(generation)*1000,
+		}, module.Log)
+	}
+
+	request.Reply = make(chan interface{})
+	go module.fetchConsumerGeneration(&request, module.Log)
+	response = <-request.Reply
+
+	generation, ok := response.(*protocol.ConsumerGeneration)
+	assert.True(t, ok, "Expected response to be of type *protocol.ConsumerGeneration")
+	assert.Equal(t, int32(3), generation.Generation, "Expected generation to be 3, not %v", generation.Generation)
+	assert.Equal(t, startTime+3000, generation.Timestamp, "Expected timestamp of the last generation change")
+	assert.Equal(t, uint64(2), generation.Rebalances, "Expected 2 rebalances, not %v", generation.Rebalances)
+}
+
+func TestInMemoryStorage_fetchConsumerGeneration_BadGroup(t *testing.T) {
+	startTime := (time.Now().Unix() * 1000) - 100000
+	module := startWithTestConsumerOffsets("", startTime)
+
+	request := protocol.StorageRequest{
+		RequestType: protocol.StorageFetchConsumerGeneration,
+		Cluster:     "testcluster",
+		Group:       "nogroup",
+		Reply:       make(chan interface{}),
+	}
+
+	// Can't read a reply without concurrency
+	go module.fetchConsumerGeneration(&request, module.Log)
+	response, ok := <-request.Reply
+
+	assert.Nil(t, response, "Expected response to be nil")
+	assert.False(t, ok, "Expected channel to be closed")
+}
+
+func TestInMemoryStorage_fetchConsumerMembers(t *testing.T) {
+	startTime := (time.Now().Unix() * 1000) - 100000
+	module := startWithTestConsumerOffsets("", startTime)
+
+	request := protocol.StorageRequest{
+		RequestType: protocol.StorageFetchConsumerMembers,
+		Cluster:     "testcluster",
+		Group:       "testgroup",
+		Reply:       make(chan interface{}),
+	}
+
+	// The group has not been described yet
+	go module.fetchConsumerMembers(&request, module.Log)
+	response, ok := <-request.Reply
+	assert.Nil(t, response, "Expected response to be nil")
+	assert.False(t, ok, "Expected channel to be closed")
+
+	module.setConsumerMembers(&protocol.StorageRequest{
+		RequestType: protocol.StorageSetConsumerMembers,
+		Cluster:     "testcluster",
+		Group:       "testgroup",
+		MemberCount: 2,
+		Timestamp:   startTime,
+	}, module.Log)
+
+	request.Reply = make(chan interface{})
+	go module.fetchConsumerMembers(&request, module.Log)
+	response = <-request.Reply
+
+	members, ok := response.(*protocol.ConsumerMembers)
+	assert.True(t, ok, "Expected response to be of type *protocol.ConsumerMembers")
+	assert.Equal(t, int32(2), members.MemberCount, "Expected member count to be 2, not %v", members.MemberCount)
+	assert.Equal(t, startTime, members.Timestamp, "Expected timestamp of the description")
+}
+
+func TestInMemoryStorage_fetchConsumerMembers_BadGroup(t *testing.T) {
+	startTime := (time.Now().Unix() * 1000) - 100000
+	module := startWithTestConsumerOffsets("", startTime)
+
+	request := protocol.StorageRequest{
+		RequestType: protocol.StorageFetchConsumerMembers,
+		Cluster:     "testcluster",
+		Group:       "nogroup",
+		Reply:       make(chan interface{}),
+	}
+
+	// Can't read a reply without concurrency
+	go module.fetchConsumerMembers(&request, module.Log)
+	response, ok := <-request.Reply
+
+	assert.Nil(t, response, "Expected response to be nil")
+	assert.False(t, ok, "Expected channel to be closed")
+}
+
 func TestInMemoryStorage_fetchConsumersForTopic(t *testing.T) {
 	startTime := (time.Now().Unix() * 1000) - 100000
 	module := startWithTestConsumerOffsets("", startTime)
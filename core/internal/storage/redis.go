@@ -0,0 +1,502 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package storage
+
+import (
+	"container/ring"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/linkedin/Burrow/core/protocol"
+)
+
+// RedisStorage is a storage module that keeps the data set in memory, exactly as the inmemory module does, and also
+// persists it to Redis so that it survives a restart. All requests are served by an InMemoryStorage. Requests that
+// change a consumer group or a cluster's broker offsets mark it as changed, and the changed groups and clusters are
+// written to Redis in a single pipeline every persist-interval seconds, so writes never block the storage workers. On
+// start, the stored state is loaded back before any requests are processed.
+//
+// Several Burrow instances can point at the same Redis to share state across restarts, but each instance writes its
+// own view, so the last instance to write a group wins.
+type RedisStorage struct {
+	// App is a pointer to the application context. This stores the channel to the storage subsystem
+	App *protocol.ApplicationContext
+
+	// Log is a logger that has been configured for this module to use. Normally, this means it has been set up with
+	// fields that are appropriate to identify this coordinator
+	Log *zap.Logger
+
+	name            string
+	keyPrefix       string
+	persistInterval time.Duration
+	expireGroup     time.Duration
+
+	memory         *InMemoryStorage
+	client         redis.UniversalClient
+	requestChannel chan *protocol.StorageRequest
+	quitChannel    chan struct{}
+	running        sync.WaitGroup
+
+	dirtyLock    sync.Mutex
+	dirtyGroups  map[string]map[string]bool
+	dirtyBrokers map[string]bool
+}
+
+// redisOffset is how a consumer or broker offset is stored in Redis. Unlike protocol.ConsumerOffset, it keeps the
+// commit order, which is needed to place later commits correctly in the ring
+type redisOffset struct {
+	Offset            int64   `json:"o"`
+	Order             int64   `json:"r,omitempty"`
+	Timestamp         int64   `json:"t"`
+	ObservedTimestamp int64   `json:"ot,omitempty"`
+	Lag               *uint64 `json:"l,omitempty"`
+}
+
+type redisPartition struct {
+	Owner    string         `json:"owner,omitempty"`
+	ClientID string         `json:"client_id,omitempty"`
+	Offsets  []*redisOffset `json:"offsets"`
+}
+
+type redisGroup struct {
+	LastCommit     int64                        `json:"last_commit"`
+	Generation     int32                        `json:"generation,omitempty"`
+	GenerationTime int64                        `json:"generation_time,omitempty"`
+	Rebalances     uint64                       `json:"rebalances,omitempty"`
+	Topics         map[string][]*redisPartition `json:"topics"`
+}
+
+// Configure validates the configuration for the module, and configures the in-memory storage that serves requests
+// with the same configuration, so all inmemory settings apply. If no address is set, localhost:6379 is used. The Redis
+// keys are prefixed with key-prefix (default "burrow"), and changes are written every persist-interval seconds (default
+// 10).
+func (module *RedisStorage) Configure(name, configRoot string) {
+	module.Log.Info("configuring")
+
+	module.name = name
+	module.memory = &InMemoryStorage{
+		App: module.App,
+		Log: module.Log,
+	}
+	module.memory.Configure(name, configRoot)
+	module.memory.requestHandled = module.markRequest
+
+	viper.SetDefault(configRoot+".address", "localhost:6379")
+	viper.SetDefault(configRoot+".key-prefix", "burrow")
+	viper.SetDefault(configRoot+".persist-interval", 10)
+	module.keyPrefix = viper.GetString(configRoot + ".key-prefix")
+	module.persistInterval = time.Duration(viper.GetInt(configRoot+".persist-interval")) * time.Second
+	module.expireGroup = time.Duration(module.memory.expireGroup) * time.Second
+	if module.persistInterval <= 0 {
+		panic("Storage module '" + name + "' must have a persist-interval greater than zero")
+	}
+
+	module.client = redis.NewUniversalClient(&redis.UniversalOptions{
+		Addrs:    viper.GetStringSlice(configRoot + ".address"),
+		Username: viper.GetString(configRoot + ".username"),
+		Password: viper.GetString(configRoot + ".password"),
+		DB:       viper.GetInt(configRoot + ".db"),
+	})
+
+	module.requestChannel = make(chan *protocol.StorageRequest, module.memory.queueDepth)
+	module.quitChannel = make(chan struct{})
+	module.running = sync.WaitGroup{}
+	module.dirtyGroups = make(map[string]map[string]bool)
+	module.dirtyBrokers = make(map[string]bool)
+}
+
+// GetCommunicationChannel returns the RequestChannel that has been setup for this module.
+func (module *RedisStorage) GetCommunicationChannel() chan *protocol.StorageRequest {
+	return module.requestChannel
+}
+
+// Start starts the in-memory storage and loads the state stored in Redis into it. If Redis cannot be read, the module
+// starts with no stored state, as the inmemory module does. It then starts forwarding requests, and writing changes to
+// Redis periodically.
+func (module *RedisStorage) Start() error {
+	module.Log.Info("starting")
+
+	err := module.memory.Start()
+	if err != nil {
+		return err
+	}
+	module.load()
+
+	module.running.Add(2)
+	go module.mainLoop()
+	go module.persistLoop()
+	return nil
+}
+
+// Stop stops forwarding requests and stops the in-memory storage. The remaining changes are written to Redis before
+// the client is closed.
+func (module *RedisStorage) Stop() error {
+	module.Log.Info("stopping")
+
+	close(module.requestChannel)
+	close(module.quitChannel)
+	module.running.Wait()
+
+	err := module.memory.Stop()
+	module.persist()
+	module.client.Close()
+	return err
+}
+
+// ReloadFilters replaces the group filters of the in-memory storage with the ones in the provided configuration
+func (module *RedisStorage) ReloadFilters(config *viper.Viper) error {
+	return module.memory.ReloadFilters(config)
+}
+
+func (module *RedisStorage) groupsKey(cluster string) string {
+	return module.keyPrefix + ":" + cluster + ":groups"
+}
+
+func (module *RedisStorage) groupKey(cluster, group string) string {
+	return module.keyPrefix + ":" + cluster + ":group:" + group
+}
+
+func (module *RedisStorage) brokerKey(cluster string) string {
+	return module.keyPrefix + ":" + cluster + ":broker"
+}
+
+func (module *RedisStorage) mainLoop() {
+	defer module.running.Done()
+
+	memoryChannel := module.memory.GetCommunicationChannel()
+	for r := range module.requestChannel {
+		memoryChannel <- r
+	}
+}
+
+// markRequest marks the group or cluster that a request changed as needing to be persisted. It is called by the
+// in-memory storage worker once the request has been applied, so that a persist that runs in between cannot clear the
+// mark before the change is there to be written.
+func (module *RedisStorage) markRequest(r *protocol.StorageRequest) {
+	switch r.RequestType {
+	case protocol.StorageSetConsumerOffset, protocol.StorageSetConsumerOwner, protocol.StorageSetDeleteGroup, protocol.StorageSetEvictGroup, protocol.StorageClearConsumerOwners, protocol.StorageSetConsumerGeneration:
+		module.markGroup(r.Cluster, r.Group)
+	case protocol.StorageSetBrokerOffset:
+		module.markBroker(r.Cluster)
+	case protocol.StorageSetDeleteTopic:
+		// Deleting a topic changes every group that consumes it
+		module.markBroker(r.Cluster)
+		module.markAllGroups(r.Cluster)
+	}
+}
+
+func (module *RedisStorage) markGroup(cluster, group string) {
+	module.dirtyLock.Lock()
+	defer module.dirtyLock.Unlock()
+
+	if _, ok := module.dirtyGroups[cluster]; !ok {
+		module.dirtyGroups[cluster] = make(map[string]bool)
+	}
+	module.dirtyGroups[cluster][group] = true
+}
+
+func (module *RedisStorage) markBroker(cluster string) {
+	module.dirtyLock.Lock()
+	defer module.dirtyLock.Unlock()
+	module.dirtyBrokers[cluster] = true
+}
+
+func (module *RedisStorage) markAllGroups(cluster string) {
+	clusterMap, ok := module.memory.getClusterOffsets(cluster)
+	if !ok {
+		return
+	}
+	clusterMap.consumerLock.RLock()
+	defer clusterMap.consumerLock.RUnlock()
+	for group := range clusterMap.consumer {
+		module.markGroup(cluster, group)
+	}
+}
+
+func (module *RedisStorage) persistLoop() {
+	defer module.running.Done()
+
+	ticker := time.NewTicker(module.persistInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			module.persist()
+		case <-module.quitChannel:
+			return
+		}
+	}
+}
+
+// persist writes all groups and broker offsets that have changed since the last call to Redis in one pipeline. If the
+// write fails, they are marked as changed again so that they are retried on the next call.
+func (module *RedisStorage) persist() {
+	module.dirtyLock.Lock()
+	dirtyGroups := module.dirtyGroups
+	dirtyBrokers := module.dirtyBrokers
+	module.dirtyGroups = make(map[string]map[string]bool)
+	module.dirtyBrokers = make(map[string]bool)
+	module.dirtyLock.Unlock()
+
+	if len(dirtyGroups) == 0 && len(dirtyBrokers) == 0 {
+		return
+	}
+
+	ctx := context.Background()
+	pipe := module.client.Pipeline()
+	for cluster, groups := range dirtyGroups {
+		for group := range groups {
+			snapshot := module.snapshotGroup(cluster, group)
+			if snapshot == nil {
+				pipe.Del(ctx, module.groupKey(cluster, group))
+				pipe.SRem(ctx, module.groupsKey(cluster), group)
+				continue
+			}
+			data, err := json.Marshal(snapshot)
+			if err != nil {
+				module.Log.Error("failed to encode group", zap.String("cluster", cluster), zap.String("consumer", group), zap.Error(err))
+				continue
+			}
+			expiration := module.expireGroup
+			if module.memory.neverExpires(group) {
+				expiration = 0
+			}
+			pipe.Set(ctx, module.groupKey(cluster, group), data, expiration)
+			pipe.SAdd(ctx, module.groupsKey(cluster), group)
+		}
+	}
+	for cluster := range dirtyBrokers {
+		data, err := json.Marshal(module.snapshotBroker(cluster))
+		if err != nil {
+			module.Log.Error("failed to encode broker offsets", zap.String("cluster", cluster), zap.Error(err))
+			continue
+		}
+		pipe.Set(ctx, module.brokerKey(cluster), data, 0)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		module.Log.Error("failed to persist to redis", zap.Error(err))
+		for cluster, groups := range dirtyGroups {
+			for group := range groups {
+				module.markGroup(cluster, group)
+			}
+		}
+		for cluster := range dirtyBrokers {
+			module.markBroker(cluster)
+		}
+		return
+	}
+	module.Log.Debug("persisted to redis", zap.Int("clusters", len(dirtyGroups)), zap.Int("broker_clusters", len(dirtyBrokers)))
+}
+
+// load reads the groups and broker offsets for every configured cluster from Redis into the in-memory storage
+func (module *RedisStorage) load() {
+	ctx := context.Background()
+	for cluster, clusterMap := range module.memory.getAllClusterOffsets() {
+		groups, err := module.client.SMembers(ctx, module.groupsKey(cluster)).Result()
+		if err != nil {
+			module.Log.Warn("failed to load from redis", zap.String("cluster", cluster), zap.Error(err))
+			continue
+		}
+
+		loaded := 0
+		for _, group := range groups {
+			data, err := module.client.Get(ctx, module.groupKey(cluster, group)).Bytes()
+			if err == redis.Nil {
+				// The group expired
+				module.client.SRem(ctx, module.groupsKey(cluster), group)
+				continue
+			}
+			var snapshot redisGroup
+			if err == nil {
+				err = json.Unmarshal(data, &snapshot)
+			}
+			if err != nil {
+				module.Log.Warn("failed to load group from redis", zap.String("cluster", cluster), zap.String("consumer", group), zap.Error(err))
+				continue
+			}
+			clusterMap.consumerLock.Lock()
+			clusterMap.consumer[group] = module.restoreGroup(&snapshot, clusterMap.intervals)
+			clusterMap.consumerLock.Unlock()
+			loaded++
+		}
+
+		data, err := module.client.Get(ctx, module.brokerKey(cluster)).Bytes()
+		if err == nil {
+			var snapshot map[string][][]*redisOffset
+			if err = json.Unmarshal(data, &snapshot); err == nil {
+				clusterMap.brokerLock.Lock()
+				for topic, partitions := range snapshot {
+					clusterMap.broker[topic] = module.restoreBrokerTopic(partitions, clusterMap.intervals)
+				}
+				clusterMap.brokerLock.Unlock()
+			}
+		}
+		if err != nil && err != redis.Nil {
+			module.Log.Warn("failed to load broker offsets from redis", zap.String("cluster", cluster), zap.Error(err))
+		}
+
+		module.Log.Info("loaded from redis", zap.String("cluster", cluster), zap.Int("groups", loaded))
+	}
+}
+
+// snapshotGroup returns the stored form of a consumer group, or nil if the group does not exist
+func (module *RedisStorage) snapshotGroup(cluster, group string) *redisGroup {
+	clusterMap, ok := module.memory.getClusterOffsets(cluster)
+	if !ok {
+		return nil
+	}
+	clusterMap.consumerLock.RLock()
+	consumerMap, ok := clusterMap.consumer[group]
+	clusterMap.consumerLock.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	consumerMap.lock.RLock()
+	defer consumerMap.lock.RUnlock()
+	snapshot := &redisGroup{
+		LastCommit:     consumerMap.lastCommit,
+		Generation:     consumerMap.generation,
+		GenerationTime: consumerMap.generationTime,
+		Rebalances:     consumerMap.rebalances,
+		Topics:         make(map[string][]*redisPartition, len(consumerMap.topics)),
+	}
+	for topic, partitions := range consumerMap.topics {
+		snapshot.Topics[topic] = make([]*redisPartition, len(partitions))
+		for i, partition := range partitions {
+			snapshot.Topics[topic][i] = &redisPartition{
+				Owner:    partition.owner,
+				ClientID: partition.clientID,
+				Offsets:  ringToRedisOffsets(partition.offsets),
+			}
+		}
+	}
+	return snapshot
+}
+
+// snapshotBroker returns the stored form of the broker offsets for a cluster. The broker rings point at the most
+// recent entry, so they are stored starting from the entry after it to keep the offsets in order
+func (module *RedisStorage) snapshotBroker(cluster string) map[string][][]*redisOffset {
+	snapshot := make(map[string][][]*redisOffset)
+	clusterMap, ok := module.memory.getClusterOffsets(cluster)
+	if !ok {
+		return snapshot
+	}
+	clusterMap.brokerLock.RLock()
+	defer clusterMap.brokerLock.RUnlock()
+	for topic, partitions := range clusterMap.broker {
+		snapshot[topic] = make([][]*redisOffset, len(partitions))
+		for i, partition := range partitions {
+			snapshot[topic][i] = ringToRedisOffsets(partition.Next())
+		}
+	}
+	return snapshot
+}
+
+func (module *RedisStorage) restoreGroup(snapshot *redisGroup, intervals int) *consumerGroup {
+	group := &consumerGroup{
+		lock:           &sync.RWMutex{},
+		topics:         make(map[string][]*consumerPartition, len(snapshot.Topics)),
+		lastCommit:     snapshot.LastCommit,
+		generation:     snapshot.Generation,
+		generationTime: snapshot.GenerationTime,
+		rebalances:     snapshot.Rebalances,
+	}
+	for topic, partitions := range snapshot.Topics {
+		group.topics[topic] = make([]*consumerPartition, len(partitions))
+		for i, partition := range partitions {
+			restored := &consumerPartition{}
+			if partition != nil {
+				restored.owner = partition.Owner
+				restored.clientID = partition.ClientID
+				if partition.Offsets != nil {
+					restored.offsets = redisOffsetsToRing(partition.Offsets, intervals, false)
+				}
+			}
+			group.topics[topic][i] = restored
+		}
+	}
+	return group
+}
+
+func (module *RedisStorage) restoreBrokerTopic(partitions [][]*redisOffset, intervals int) []*ring.Ring {
+	rings := make([]*ring.Ring, len(partitions))
+	for i, offsets := range partitions {
+		// The ring must point at the most recent entry, which is the last one stored
+		rings[i] = redisOffsetsToRing(offsets, intervals, true).Prev()
+	}
+	return rings
+}
+
+// ringToRedisOffsets returns the values of the ring, starting at r, in the form they are stored in Redis. Empty slots
+// are kept as nil so the ring can be restored exactly. The consumer rings point at the oldest entry, so the offsets are
+// in order from oldest to newest.
+func ringToRedisOffsets(r *ring.Ring) []*redisOffset {
+	if r == nil {
+		return nil
+	}
+	offsets := make([]*redisOffset, 0, r.Len())
+	r.Do(func(value interface{}) {
+		switch v := value.(type) {
+		case *protocol.ConsumerOffset:
+			offset := &redisOffset{Offset: v.Offset, Order: v.Order, Timestamp: v.Timestamp, ObservedTimestamp: v.ObservedTimestamp}
+			if v.Lag != nil {
+				lag := v.Lag.Value
+				offset.Lag = &lag
+			}
+			offsets = append(offsets, offset)
+		case *brokerOffset:
+			offsets = append(offsets, &redisOffset{Offset: v.Offset, Timestamp: v.Timestamp})
+		default:
+			offsets = append(offsets, nil)
+		}
+	})
+	return offsets
+}
+
+// redisOffsetsToRing builds a ring of the given size from offsets stored from oldest to newest, returning the ring at
+// the oldest entry. If the number of intervals has changed since the offsets were stored, the newest offsets are kept.
+func redisOffsetsToRing(offsets []*redisOffset, size int, broker bool) *ring.Ring {
+	if len(offsets) > size {
+		offsets = offsets[len(offsets)-size:]
+	}
+	r := ring.New(size)
+	start := r
+	for i := 0; i < size-len(offsets); i++ {
+		r = r.Next()
+	}
+	for _, offset := range offsets {
+		if offset != nil {
+			if broker {
+				r.Value = &brokerOffset{Offset: offset.Offset, Timestamp: offset.Timestamp}
+			} else {
+				consumerOffset := &protocol.ConsumerOffset{
+					Offset:            offset.Offset,
+					Order:             offset.Order,
+					Timestamp:         offset.Timestamp,
+					ObservedTimestamp: offset.ObservedTimestamp,
+				}
+				if offset.Lag != nil {
+					consumerOffset.Lag = &protocol.Lag{Value: *offset.Lag}
+				}
+				r.Value = consumerOffset
+			}
+		}
+		r = r.Next()
+	}
+	return start
+}
@@ -0,0 +1,284 @@
+// Copyright 2017 LinkedIn Corp. Licensed under the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+
+package storage
+
+import (
+	"container/ring"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/linkedin/Burrow/core/protocol"
+)
+
+func fixtureRedisModule(address string) *RedisStorage {
+	module := RedisStorage{
+		Log: zap.NewNop(),
+	}
+	module.App = &protocol.ApplicationContext{
+		StorageChannel: make(chan *protocol.StorageRequest),
+	}
+
+	viper.Reset()
+	viper.Set("storage.test.class-name", "redis")
+	viper.Set("storage.test.address", address)
+	viper.Set("storage.test.min-distance", 1)
+
+	// A single worker processes the requests in order, so consumer offsets are never handled before the broker offset
+	viper.Set("storage.test.workers", 1)
+	viper.Set("cluster.testcluster.class-name", "kafka")
+	viper.Set("cluster.testcluster.servers", []string{"broker1.example.com:1234"})
+
+	return &module
+}
+
+func sendRedisRequest(module *RedisStorage, request *protocol.StorageRequest) interface{} {
+	module.GetCommunicationChannel() <- request
+	if request.Reply == nil {
+		return nil
+	}
+	return <-request.Reply
+}
+
+func TestRedisStorage_ImplementsStorageModule(t *testing.T) {
+	assert.Implements(t, (*protocol.Module)(nil), new(RedisStorage))
+	assert.Implements(t, (*Module)(nil), new(RedisStorage))
+}
+
+func TestRedisStorage_Configure(t *testing.T) {
+	module := fixtureRedisModule("localhost:6379")
+	module.Configure("test", "storage.test")
+
+	assert.Equal(t, "burrow", module.keyPrefix, "Default key prefix of burrow did not get set")
+	assert.Equal(t, 10*time.Second, module.persistInterval, "Default persist interval of 10 seconds did not get set")
+	assert.Equal(t, 604800*time.Second, module.expireGroup, "Expected the group TTL to follow expire-group")
+	assert.Equal(t, "burrow:testcluster:group:testgroup", module.groupKey("testcluster", "testgroup"))
+}
+
+func TestRedisStorage_Configure_BadPersistInterval(t *testing.T) {
+	module := fixtureRedisModule("localhost:6379")
+	viper.Set("storage.test.persist-interval", 0)
+
+	assert.Panics(t, func() { module.Configure("test", "storage.test") }, "The code did not panic")
+}
+
+func TestRedisStorage_ringConversion(t *testing.T) {
+	r := ring.New(4)
+	r.Value = &protocol.ConsumerOffset{Offset: 10, Order: 3, Timestamp: 1000, Lag: &protocol.Lag{Value: 5}}
+	r.Next().Value = &protocol.ConsumerOffset{Offset: 20, Order: 4, Timestamp: 2000}
+
+	offsets := ringToRedisOffsets(r)
+	assert.Len(t, offsets, 4, "Expected empty slots to be kept")
+	assert.Nil(t, offsets[2])
+
+	// Shrinking the ring keeps the newest entries, which are last
+	restored := redisOffsetsToRing(offsets[:2], 3, false)
+	assert.Nil(t, restored.Value, "Expected the ring to be padded at the oldest end")
+	first := restored.Next().Value.(*protocol.ConsumerOffset)
+	assert.Equal(t, int64(10), first.Offset)
+	assert.Equal(t, int64(3), first.Order)
+	assert.Equal(t, uint64(5), first.Lag.Value)
+	assert.Equal(t, int64(20), restored.Prev().Value.(*protocol.ConsumerOffset).Offset)
+
+	restored = redisOffsetsToRing(offsets, 2, false)
+	assert.Nil(t, restored.Value)
+	assert.Nil(t, restored.Next().Value)
+}
+
+func TestRedisStorage_PersistAndLoad(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	module := fixtureRedisModule(server.Addr())
+	module.Configure("test", "storage.test")
+	assert.Nil(t, module.Start(), "Expected Start to return no error")
+
+	startTime := time.Now().Unix()*1000 - 100000
+	sendRedisRequest(module, &protocol.StorageRequest{
+		RequestType:         protocol.StorageSetBrokerOffset,
+		Cluster:             "testcluster",
+		Topic:               "testtopic",
+		Partition:           0,
+		TopicPartitionCount: 1,
+		Offset:              4321,
+		Timestamp:           startTime,
+	})
+	for i := int64(0); i < 3; i++ {
+		sendRedisRequest(module, &protocol.StorageRequest{
+			RequestType: protocol.StorageSetConsumerOffset,
+			Cluster:     "testcluster",
+			Topic:       "testtopic",
+			Partition:   0,
+			Group:       "testgroup",
+			Offset:      1000 + i*100,
+			Order:       i,
+			Timestamp:   startTime + i*10000,
+		})
+	}
+	sendRedisRequest(module, &protocol.StorageRequest{
+		RequestType: protocol.StorageSetConsumerOwner,
+		Cluster:     "testcluster",
+		Topic:       "testtopic",
+		Partition:   0,
+		Group:       "testgroup",
+		Owner:       "testhost.example.com",
+		ClientID:    "test_client_id",
+	})
+
+	for generation := int32(1); generation <= 2; generation++ {
+		sendRedisRequest(module, &protocol.StorageRequest{
+			RequestType: protocol.StorageSetConsumerGeneration,
+			Cluster:     "testcluster",
+			Group:       "testgroup",
+			Generation:  generation,
+			Timestamp:   startTime,
+		})
+	}
+
+	// Make sure the requests have been processed before stopping, which writes everything to redis
+	sendRedisRequest(module, &protocol.StorageRequest{
+		RequestType: protocol.StorageFetchConsumer,
+		Cluster:     "testcluster",
+		Group:       "testgroup",
+		Reply:       make(chan interface{}),
+	})
+	assert.Nil(t, module.Stop(), "Expected Stop to return no error")
+
+	members, err := server.SMembers("burrow:testcluster:groups")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"testgroup"}, members)
+	assert.True(t, server.Exists("burrow:testcluster:broker"))
+	assert.True(t, server.TTL("burrow:testcluster:group:testgroup") > 0, "Expected the group to expire")
+
+	// A new module loads the same state
+	module = fixtureRedisModule(server.Addr())
+	module.Configure("test", "storage.test")
+	assert.Nil(t, module.Start(), "Expected Start to return no error")
+	defer module.Stop()
+
+	response := sendRedisRequest(module, &protocol.StorageRequest{
+		RequestType: protocol.StorageFetchConsumer,
+		Cluster:     "testcluster",
+		Group:       "testgroup",
+		Reply:       make(chan interface{}),
+	})
+	topics, ok := response.(protocol.ConsumerTopics)
+	if !assert.True(t, ok, "Expected the group to be loaded") {
+		return
+	}
+	partition := topics["testtopic"][0]
+	assert.Equal(t, "testhost.example.com", partition.Owner)
+	assert.Equal(t, "test_client_id", partition.ClientID)
+	assert.Equal(t, int64(4321), partition.BrokerOffsets[0], "Expected the broker offset to be loaded")
+
+	var offsets []*protocol.ConsumerOffset
+	for _, offset := range partition.Offsets {
+		if offset != nil {
+			offsets = append(offsets, offset)
+		}
+	}
+	if assert.Len(t, offsets, 3) {
+		assert.Equal(t, int64(1000), offsets[0].Offset)
+		assert.Equal(t, int64(1200), offsets[2].Offset)
+		assert.Equal(t, int64(2), offsets[2].Order)
+	}
+
+	response = sendRedisRequest(module, &protocol.StorageRequest{
+		RequestType: protocol.StorageFetchConsumerGeneration,
+		Cluster:     "testcluster",
+		Group:       "testgroup",
+		Reply:       make(chan interface{}),
+	})
+	if generation, ok := response.(*protocol.ConsumerGeneration); assert.True(t, ok, "Expected the generation to be loaded") {
+		assert.Equal(t, int32(2), generation.Generation)
+		assert.Equal(t, uint64(1), generation.Rebalances)
+	}
+
+	// Offsets committed after the reload are ordered after the stored ones
+	sendRedisRequest(module, &protocol.StorageRequest{
+		RequestType: protocol.StorageSetConsumerOffset,
+		Cluster:     "testcluster",
+		Topic:       "testtopic",
+		Partition:   0,
+		Group:       "testgroup",
+		Offset:      1300,
+		Order:       3,
+		Timestamp:   startTime + 30000,
+	})
+	response = sendRedisRequest(module, &protocol.StorageRequest{
+		RequestType: protocol.StorageFetchConsumer,
+		Cluster:     "testcluster",
+		Group:       "testgroup",
+		Reply:       make(chan interface{}),
+	})
+	stored := response.(protocol.ConsumerTopics)["testtopic"][0].Offsets
+	assert.Equal(t, int64(1300), stored[len(stored)-1].Offset)
+}
+
+func TestRedisStorage_markRequest(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	module := fixtureRedisModule(server.Addr())
+	module.Configure("test", "storage.test")
+
+	// The group is only marked once the offset is in memory, so a persist in between cannot write the group without it
+	inMemory := make(chan bool, 1)
+	module.memory.requestHandled = func(r *protocol.StorageRequest) {
+		if r.RequestType == protocol.StorageSetConsumerOffset {
+			clusterMap, _ := module.memory.getClusterOffsets(r.Cluster)
+			clusterMap.consumerLock.RLock()
+			_, ok := clusterMap.consumer[r.Group]
+			clusterMap.consumerLock.RUnlock()
+			inMemory <- ok
+		}
+		module.markRequest(r)
+	}
+	assert.Nil(t, module.Start(), "Expected Start to return no error")
+	defer module.Stop()
+
+	sendRedisRequest(module, &protocol.StorageRequest{
+		RequestType:         protocol.StorageSetBrokerOffset,
+		Cluster:             "testcluster",
+		Topic:               "testtopic",
+		Partition:           0,
+		TopicPartitionCount: 1,
+		Offset:              4321,
+		Timestamp:           time.Now().Unix() * 1000,
+	})
+	sendRedisRequest(module, &protocol.StorageRequest{
+		RequestType: protocol.StorageSetConsumerOffset,
+		Cluster:     "testcluster",
+		Topic:       "testtopic",
+		Partition:   0,
+		Group:       "testgroup",
+		Offset:      1000,
+		Timestamp:   time.Now().Unix() * 1000,
+	})
+	assert.True(t, <-inMemory, "Expected the offset to be applied before the group is marked")
+
+	module.dirtyLock.Lock()
+	defer module.dirtyLock.Unlock()
+	assert.True(t, module.dirtyGroups["testcluster"]["testgroup"], "Expected the group to be marked")
+	assert.True(t, module.dirtyBrokers["testcluster"], "Expected the cluster to be marked")
+}
+
+func TestRedisStorage_Start_Unreachable(t *testing.T) {
+	server := miniredis.RunT(t)
+	address := server.Addr()
+	server.Close()
+
+	module := fixtureRedisModule(address)
+	module.Configure("test", "storage.test")
+	assert.Nil(t, module.Start(), "Expected Start to succeed without redis")
+	assert.Nil(t, module.Stop(), "Expected Stop to return no error")
+}
@@ -44,13 +44,22 @@ type Coordinator struct {
 }
 
 // Configure validates that the configuration has a list of servers provided for the Zookeeper ensemble, of the form
-// host:port. It also checks the provided root path, using a default of "/burrow" if none has been provided.
+// host:port. It also checks the provided root path, using a default of "/burrow" if none has been provided, and that
+// the tls and sasl profiles referenced by zookeeper.tls and zookeeper.digest-auth exist. As the Zookeeper client does
+// not support SASL, zookeeper.sasl is not accepted.
 func (zc *Coordinator) Configure() {
 	zc.Log.Info("configuring")
 
-	// if zookeeper.tls has been set, use the TLS connect function otherwise use default connect
-	if zc.connectFunc == nil && viper.IsSet("zookeeper.tls") {
-		zc.connectFunc = helpers.ZookeeperConnectTLS
+	if viper.IsSet("zookeeper.sasl") {
+		panic("zookeeper.sasl is not supported, as the Zookeeper client cannot use SASL. Use zookeeper.digest-auth for digest auth")
+	}
+	if err := helpers.ValidateZookeeperProfiles("zookeeper", viper.GetString("zookeeper.tls"), viper.GetString("zookeeper.digest-auth")); err != nil {
+		panic(err.Error())
+	}
+
+	// if zookeeper.tls or zookeeper.digest-auth has been set, use the secure connect function otherwise use default connect
+	if zc.connectFunc == nil && (viper.IsSet("zookeeper.tls") || viper.IsSet("zookeeper.digest-auth")) {
+		zc.connectFunc = helpers.ZookeeperConnectSecure
 	} else if zc.connectFunc == nil {
 		zc.connectFunc = helpers.ZookeeperConnect
 	}
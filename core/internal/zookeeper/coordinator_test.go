@@ -55,6 +55,30 @@ func TestCoordinator_Configure(t *testing.T) {
 	assert.NotNil(t, coordinator.connectFunc, "Expected connectFunc to get set")
 }
 
+func TestCoordinator_Configure_Profiles(t *testing.T) {
+	coordinator := fixtureCoordinator()
+	viper.Set("zookeeper.tls", "zktls")
+	viper.Set("tls.zktls.cafile", "/etc/ca.pem")
+	viper.Set("zookeeper.digest-auth", "zkauth")
+	viper.Set("sasl.zkauth.username", "burrow")
+	coordinator.Configure()
+
+	assert.NotNil(t, coordinator.connectFunc, "Expected connectFunc to get set")
+}
+
+func TestCoordinator_Configure_UnknownProfile(t *testing.T) {
+	coordinator := fixtureCoordinator()
+	viper.Set("zookeeper.digest-auth", "noauth")
+	assert.Panics(t, func() { coordinator.Configure() }, "The code did not panic")
+}
+
+func TestCoordinator_Configure_SASL(t *testing.T) {
+	coordinator := fixtureCoordinator()
+	viper.Set("zookeeper.sasl", "zkauth")
+	viper.Set("sasl.zkauth.username", "burrow")
+	assert.Panics(t, func() { coordinator.Configure() }, "The code did not panic")
+}
+
 func TestCoordinator_StartStop(t *testing.T) {
 	coordinator := fixtureCoordinator()
 
@@ -92,6 +92,8 @@ func RemovePidFile(filename string) {
 //
 // logging.level = info
 //
+// Messages from the Sarama Kafka client are logged at the level given by logging.sarama-level, which defaults to debug.
+//
 // If logging.filename (path to the log file) is provided, a rolling log file is set up using lumberjack. The
 // configuration for that log file is read from viper, with the following defaults:
 //
@@ -9,7 +9,10 @@
 
 package protocol
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"sync"
+)
 
 // EvaluatorRequest is sent over the EvaluatorChannel that is stored in the application context. It is a query for the
 // status of a group in a cluster. The response to this query is sent over the reply channel. This request is typically
@@ -30,6 +33,70 @@ type EvaluatorRequest struct {
 	// regardless of the state of that partition. If false (the default), only partitions that have a status of WARN
 	// or above are returned in the status object.
 	ShowAll bool
+
+	// If Refresh is true, any cached status for the group is ignored, and the group is evaluated using the offsets that
+	// are currently in storage. The result replaces the cached status.
+	Refresh bool
+
+	// If ParametersReply is set, the group is not evaluated. Instead, the evaluator sends the parameters that it uses
+	// to evaluate the group in the cluster over this channel, and nothing is sent over Reply. The Group can be empty,
+	// for the parameters that apply to any group in the cluster.
+	ParametersReply chan *EvaluatorParameters
+
+	// If SummaryReply is set, no group is evaluated. Instead, the evaluator sends the result of the last evaluation of
+	// every group in the cluster that it has evaluated, keyed by group name, over this channel, and nothing is sent
+	// over Reply. The Group is ignored.
+	SummaryReply chan map[string]*GroupStatusSummary
+}
+
+// GroupStatusSummary is the result of the last evaluation of a consumer group, without the details of its partitions
+type GroupStatusSummary struct {
+	// The status of the group
+	Status StatusConstant `json:"status"`
+
+	// True if any partition of the group was stalled (StatusStall)
+	Stalled bool `json:"stalled"`
+}
+
+// EvaluatorParameters are the settings that the evaluator uses for a group in a cluster, after any overrides for the
+// cluster or the group have been applied. The sliding window that the rules look at is the number of offsets (the
+// intervals) that storage keeps for each partition.
+type EvaluatorParameters struct {
+	// The cluster and group that the parameters are for. The group is empty if none was requested
+	Cluster string `json:"cluster"`
+	Group   string `json:"group,omitempty"`
+
+	// The number of seconds that an evaluated status is cached for
+	ExpireCache int `json:"expire_cache"`
+
+	// The fraction of the window (between 0.0 and 1.0) that must have offsets before a partition can be evaluated
+	MinimumComplete float32 `json:"minimum_complete"`
+
+	// The lag that is treated as zero by the sliding window rules
+	AllowedLag uint64 `json:"allowed_lag"`
+
+	// The number of seconds without a commit after which a partition with no lag is stalled (zero if disabled)
+	StaleCommit int64 `json:"stale_commit"`
+
+	// The number of seconds after a generation change that the group is reported as rebalancing
+	RebalanceWindow int64 `json:"rebalance_window"`
+
+	// The absolute lag threshold for the group (zero if there is none), the status a partition over it is given, and
+	// where the threshold came from: "group", "cluster", or "default"
+	MaxLag       uint64         `json:"max_lag"`
+	MaxLagStatus StatusConstant `json:"max_lag_status"`
+	MaxLagSource string         `json:"max_lag_source"`
+
+	// The time lag threshold, in seconds, for the partitions of the group (zero if there is none)
+	MaxTimeLag int64 `json:"max_time_lag"`
+
+	// The settings for flagging partitions whose offsets were reset
+	OffsetResetWindow    int64 `json:"offset_reset_window"`
+	OffsetResetThreshold int64 `json:"offset_reset_threshold"`
+	OffsetResetSuppress  bool  `json:"offset_reset_suppress"`
+
+	// True if the status of each topic in the group is also calculated
+	TopicStatus bool `json:"topic_status"`
 }
 
 // PartitionStatus represents the state of a single consumed partition
@@ -59,10 +126,29 @@ type PartitionStatus struct {
 	// last committed offset and the current broker end offset
 	CurrentLag uint64 `json:"current_lag"`
 
+	// The number of milliseconds that the consumer is behind for this partition, if the time lag is available. This is
+	// calculated using the timestamps of the message at the last committed offset and the newest message
+	TimeLag int64 `json:"time_lag"`
+
+	// The timestamp at which the newest broker offset for this partition was fetched, or zero if there is none
+	LastBrokerOffsetTimestamp int64 `json:"lastBrokerOffsetTimestamp"`
+
+	// True if the last committed offset is lower than the oldest offset that the brokers retain for the partition
+	BelowLogStart bool `json:"belowLogStart"`
+
 	// A number between 0.0 and 1.0 that describes the percentage complete the offset information is for this partition.
 	// For example, if Burrow has been configured to store 10 offsets, and Burrow has only stored 7 commits for this
 	// partition, Complete will be 0.7
 	Complete float32 `json:"complete"`
+
+	// The evaluation rule that set the status of the partition, if it is not OK. This is either "sliding-window", for
+	// the rules that look at the stored offsets, "max-lag", if the current lag is over the configured threshold, or
+	// "max-time-lag", if the time lag is over the configured threshold
+	Rule string `json:"rule,omitempty"`
+
+	// True if the committed offset for the partition was moved backwards recently, such as by an operator resetting
+	// the offsets for the group. This is only set if the evaluator is configured to detect offset resets
+	OffsetReset bool `json:"offsetReset"`
 }
 
 // ConsumerGroupStatus is the response object that is sent in reply to an EvaluatorRequest. It describes the current
@@ -97,6 +183,54 @@ type ConsumerGroupStatus struct {
 
 	// The sum of all partition CurrentLag values for the group
 	TotalLag uint64 `json:"totallag"`
+
+	// The highest TimeLag value for any partition of the group, in milliseconds
+	MaxTimeLag int64 `json:"maxtimelag"`
+
+	// True if the group generation has changed recently, which means the group is rebalancing or has just finished a
+	// rebalance
+	Rebalancing bool `json:"rebalancing"`
+
+	// The number of times Burrow has seen the group generation change
+	Rebalances uint64 `json:"rebalances"`
+
+	// True if the group had members connected when it was last described by the group coordinator. A group with no
+	// members has been stopped (or abandoned), even if it has committed offsets. If the group has not been described,
+	// this is false
+	Active bool `json:"active"`
+
+	// The number of members in the group when it was last described by the group coordinator
+	MemberCount int32 `json:"memberCount"`
+
+	// True if the offsets for one or more partitions were reset recently. The lag for the group may have jumped as a
+	// result of the reset
+	OffsetReset bool `json:"offsetReset"`
+
+	// The status of each topic that the group consumes, keyed by the topic name. This is only set if the evaluator is
+	// configured to evaluate topics, and it always covers every topic, even if the request ShowAll field was false
+	Topics map[string]*TopicStatus `json:"topics,omitempty"`
+}
+
+// TopicStatus describes the status of the partitions of a single topic within a consumer group. It is calculated in
+// the same way as the ConsumerGroupStatus, but only from the partitions of the topic.
+type TopicStatus struct {
+	// The status of the topic. This is either OK, WARN, or ERR, from the highest Status for its partitions
+	Status StatusConstant `json:"status"`
+
+	// A number between 0.0 and 1.0 that describes the percentage complete the partition information is for this topic
+	Complete float32 `json:"complete"`
+
+	// The number of partitions of the topic that the group has committed offsets for
+	TotalPartitions int `json:"partition_count"`
+
+	// A PartitionStatus object for the partition of the topic with the highest CurrentLag value
+	Maxlag *PartitionStatus `json:"maxlag"`
+
+	// The sum of the CurrentLag values for the partitions of the topic
+	TotalLag uint64 `json:"totallag"`
+
+	// The highest TimeLag value for any partition of the topic, in milliseconds
+	MaxTimeLag int64 `json:"maxtimelag"`
 }
 
 // StatusConstant describes the state of a partition or group as a single value. These values are ordered from least
@@ -152,3 +286,82 @@ func (c StatusConstant) MarshalText() ([]byte, error) {
 func (c StatusConstant) MarshalJSON() ([]byte, error) {
 	return json.Marshal(c.String())
 }
+
+// ConsumerStatusChange is sent to the subscribers of the StatusFeed when the evaluated status of a consumer group is
+// different from the status of its last evaluation. A group that has not been evaluated before, or that has been
+// removed, has a status of NOTFOUND.
+type ConsumerStatusChange struct {
+	// The name of the cluster in which the group exists
+	Cluster string `json:"cluster"`
+
+	// The name of the consumer group
+	Group string `json:"group"`
+
+	// The status of the group after, and before, the change
+	Status         StatusConstant `json:"status"`
+	PreviousStatus StatusConstant `json:"previous_status"`
+
+	// The Complete, TotalLag, and Maxlag of the evaluation that changed the status
+	Complete float32          `json:"complete"`
+	TotalLag uint64           `json:"totallag"`
+	Maxlag   *PartitionStatus `json:"maxlag"`
+
+	// The time of the evaluation, in milliseconds
+	Timestamp int64 `json:"timestamp"`
+}
+
+// StatusFeed broadcasts consumer group status changes from the evaluator to any number of subscribers, such as a
+// streaming HTTP request. A subscriber that is not keeping up misses changes, rather than holding up the evaluator.
+// The zero value is ready to use, and all funcs are no-ops on a nil StatusFeed.
+type StatusFeed struct {
+	lock        sync.Mutex
+	subscribers map[chan *ConsumerStatusChange]struct{}
+}
+
+// Subscribe returns a new channel, with the provided buffer size, that receives every change that is published until
+// Unsubscribe is called for it
+func (feed *StatusFeed) Subscribe(buffer int) chan *ConsumerStatusChange {
+	subscriber := make(chan *ConsumerStatusChange, buffer)
+	if feed == nil {
+		return subscriber
+	}
+
+	feed.lock.Lock()
+	defer feed.lock.Unlock()
+	if feed.subscribers == nil {
+		feed.subscribers = make(map[chan *ConsumerStatusChange]struct{})
+	}
+	feed.subscribers[subscriber] = struct{}{}
+	return subscriber
+}
+
+// Unsubscribe stops sending changes to the channel. The channel is not closed
+func (feed *StatusFeed) Unsubscribe(subscriber chan *ConsumerStatusChange) {
+	if feed == nil {
+		return
+	}
+
+	feed.lock.Lock()
+	defer feed.lock.Unlock()
+	delete(feed.subscribers, subscriber)
+}
+
+// Publish sends the change to every subscriber that has room for it in its channel, and returns the number of
+// subscribers that were skipped because they did not
+func (feed *StatusFeed) Publish(change *ConsumerStatusChange) int {
+	if feed == nil {
+		return 0
+	}
+
+	feed.lock.Lock()
+	defer feed.lock.Unlock()
+	skipped := 0
+	for subscriber := range feed.subscribers {
+		select {
+		case subscriber <- change:
+		default:
+			skipped++
+		}
+	}
+	return skipped
+}
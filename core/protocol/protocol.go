@@ -14,9 +14,14 @@
 package protocol
 
 import (
+	"errors"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/linkedin/go-zk"
+	"github.com/spf13/viper"
 	"go.uber.org/zap"
 )
 
@@ -60,6 +65,31 @@ type ApplicationContext struct {
 	// information, or to fetch the same information. It is serviced by the storage Coordinator.
 	StorageChannel chan *StorageRequest
 
+	// This is the feed that the evaluator publishes consumer group status changes to. Any module can subscribe to it in
+	// order to be told about the changes as they are evaluated, rather than polling the EvaluatorChannel.
+	StatusFeed *StatusFeed
+
+	// These are the checks of the health of the running modules, which the HTTP server reports for liveness probes.
+	// Modules register a check when they start, and remove it when they stop.
+	Health *HealthChecks
+
+	// These are the notifier modules that have been paused, such as for a maintenance window, by the HTTP server. The
+	// notifier coordinator does not send anything for a paused module, while groups continue to be evaluated.
+	NotifierPauses *NotifierPauses
+
+	// These are the lookups of topic offsets at a point in time, which the cluster modules register when they start,
+	// and remove when they stop. The HTTP server uses them to answer requests for the offsets at a time.
+	OffsetLookups *OffsetLookups
+
+	// These are the configurations of the clusters from the cluster-discovery source, which the cluster coordinator adds
+	// as the clusters appear, and removes as they go. They are kept apart from the global configuration, which is not
+	// changed once Burrow is running. The HTTP server uses them to report on the discovered clusters.
+	DiscoveredClusters *DiscoveredClusters
+
+	// These are the consumer modules for clusters from the cluster-discovery source, which the cluster coordinator adds
+	// as the clusters appear, and removes as they go. The consumer coordinator starts and stops them.
+	DiscoveredConsumers *DiscoveredConsumers
+
 	// This is a boolean flag which is set by the last subsystem, the consumer, in order to signal when Burrow is ready
 	AppReady bool
 }
@@ -123,6 +153,27 @@ type Coordinator interface {
 	Stop() error
 }
 
+// FilterReloader is an optional interface for coordinators and modules that have group or topic filters that can be
+// replaced while running. It is used to pick up filter changes in the configuration without restarting Burrow, and
+// losing the offset state that has been built up.
+type FilterReloader interface {
+	// ReloadFilters is called with the configuration after it has been read again, which is a separate instance from
+	// the global configuration. In this func, the filters must be compiled again from the provided configuration and
+	// swapped in for the old ones, without interrupting the operation of the coordinator or module, and without
+	// changing the provided configuration. If any filter is invalid, the current filters must be kept and an error
+	// returned.
+	ReloadFilters(config *viper.Viper) error
+}
+
+// ConnectionChecker is an optional interface for coordinators and modules that connect to Kafka clusters. It is used
+// to validate a configuration, including whether the clusters are reachable with it, without starting Burrow.
+type ConnectionChecker interface {
+	// CheckConnection is called after Configure, instead of Start. In this func, a client must be connected with the
+	// configuration that Start would use, the cluster metadata fetched, and the client closed again. Any error
+	// connecting or fetching the metadata must be returned.
+	CheckConnection() error
+}
+
 // ZookeeperClient is a minimal interface for working with a Zookeeper connection. We provide this interface, rather
 // than using the underlying library directly, as it makes it easier to test code that uses Zookeeper. This interface
 // should be expanded with additional methods as needed.
@@ -174,3 +225,353 @@ type ZookeeperLock interface {
 	// error is returned instead.
 	Unlock() error
 }
+
+// HealthCheck returns an error that describes why a subsystem is not healthy, or nil if it is healthy. It is called
+// from the HTTP server, so it must be safe to call at any time, and must return quickly.
+type HealthCheck func() error
+
+// HealthChecks holds the health checks of the running modules, by name (such as "cluster.local"). The zero value is
+// ready to use, and a nil *HealthChecks ignores registrations and has no checks.
+type HealthChecks struct {
+	lock   sync.RWMutex
+	checks map[string]HealthCheck
+}
+
+// Register adds the check under the name, replacing any check that is already registered with it
+func (health *HealthChecks) Register(name string, check HealthCheck) {
+	if health == nil {
+		return
+	}
+	health.lock.Lock()
+	defer health.lock.Unlock()
+	if health.checks == nil {
+		health.checks = make(map[string]HealthCheck)
+	}
+	health.checks[name] = check
+}
+
+// Unregister removes the check with the name, if there is one
+func (health *HealthChecks) Unregister(name string) {
+	if health == nil {
+		return
+	}
+	health.lock.Lock()
+	defer health.lock.Unlock()
+	delete(health.checks, name)
+}
+
+// Run calls every registered check, and returns the result of each by name. A nil result means the check passed.
+func (health *HealthChecks) Run() map[string]error {
+	results := make(map[string]error)
+	if health == nil {
+		return results
+	}
+
+	// The checks are copied so that a slow check does not hold up modules that are starting or stopping
+	health.lock.RLock()
+	checks := make(map[string]HealthCheck, len(health.checks))
+	for name, check := range health.checks {
+		checks[name] = check
+	}
+	health.lock.RUnlock()
+
+	for name, check := range checks {
+		results[name] = check()
+	}
+	return results
+}
+
+// Heartbeat records the last time that a subsystem did its work, such as processing a message. The zero value has
+// never had a beat. It is safe to use concurrently.
+type Heartbeat struct {
+	last atomic.Int64
+}
+
+// Beat records that the subsystem did its work now
+func (heartbeat *Heartbeat) Beat() {
+	heartbeat.last.Store(time.Now().UnixNano())
+}
+
+// Last returns the time of the last beat, or the zero time if there has not been one
+func (heartbeat *Heartbeat) Last() time.Time {
+	last := heartbeat.last.Load()
+	if last == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, last)
+}
+
+// Check returns a HealthCheck that fails if there has not been a beat within the threshold
+func (heartbeat *Heartbeat) Check(threshold time.Duration) HealthCheck {
+	return func() error {
+		last := heartbeat.Last()
+		if last.IsZero() {
+			return errors.New("no heartbeat yet")
+		}
+		if since := time.Since(last); since > threshold {
+			return errors.New("no heartbeat for " + since.Round(time.Second).String() + " (threshold " + threshold.String() + ")")
+		}
+		return nil
+	}
+}
+
+// NotifierPauses holds the notifier modules that are paused, by name, with the time that each pause ends. A pause
+// expires by itself at that time. The zero value is ready to use, and a nil *NotifierPauses ignores pauses and has
+// none. It is safe to use concurrently.
+type NotifierPauses struct {
+	lock  sync.RWMutex
+	until map[string]time.Time
+}
+
+// Pause pauses the module with the name until the time provided, replacing any pause it already has
+func (pauses *NotifierPauses) Pause(name string, until time.Time) {
+	if pauses == nil {
+		return
+	}
+	pauses.lock.Lock()
+	defer pauses.lock.Unlock()
+	if pauses.until == nil {
+		pauses.until = make(map[string]time.Time)
+	}
+	pauses.until[name] = until
+}
+
+// Resume ends the pause of the module with the name, and returns true if it was paused
+func (pauses *NotifierPauses) Resume(name string) bool {
+	if pauses == nil {
+		return false
+	}
+	pauses.lock.Lock()
+	defer pauses.lock.Unlock()
+	until, ok := pauses.until[name]
+	delete(pauses.until, name)
+	return ok && time.Now().Before(until)
+}
+
+// PausedUntil returns the time that the pause of the module with the name ends, and false if it is not paused
+func (pauses *NotifierPauses) PausedUntil(name string) (time.Time, bool) {
+	if pauses == nil {
+		return time.Time{}, false
+	}
+	pauses.lock.RLock()
+	defer pauses.lock.RUnlock()
+	until, ok := pauses.until[name]
+	if !ok || !time.Now().Before(until) {
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+// OffsetLookup returns the offset of each partition of the topic at the time provided, in milliseconds. This is the
+// offset of the earliest message in the partition with a timestamp that is the same as or later than the time, or -1
+// if the partition has no such message. If only some partitions could be looked up, their offsets are returned along
+// with the error, and the others are left out. It is called from the HTTP server, so it must be safe to call at any time.
+type OffsetLookup func(topic string, timestamp int64) (map[int32]int64, error)
+
+// ErrOffsetLookupUnsupported is returned by an OffsetLookup for a cluster that cannot look up offsets by timestamp. Kafka
+// versions before 0.10.1.0 only return the offsets of the log segments from before a time, and not the offset of the
+// first message at or after it.
+var ErrOffsetLookupUnsupported = errors.New("the kafka-version of the cluster must be at least 0.10.1.0 to look up offsets by timestamp")
+
+// OffsetLookups holds the offset lookups of the running cluster modules, by cluster name. The zero value is ready to
+// use, and a nil *OffsetLookups ignores registrations and has no lookups. It is safe to use concurrently.
+type OffsetLookups struct {
+	lock    sync.RWMutex
+	lookups map[string]OffsetLookup
+}
+
+// Register adds the lookup for the cluster, replacing any lookup that is already registered for it
+func (offsetLookups *OffsetLookups) Register(cluster string, lookup OffsetLookup) {
+	if offsetLookups == nil {
+		return
+	}
+	offsetLookups.lock.Lock()
+	defer offsetLookups.lock.Unlock()
+	if offsetLookups.lookups == nil {
+		offsetLookups.lookups = make(map[string]OffsetLookup)
+	}
+	offsetLookups.lookups[cluster] = lookup
+}
+
+// Unregister removes the lookup for the cluster, if there is one
+func (offsetLookups *OffsetLookups) Unregister(cluster string) {
+	if offsetLookups == nil {
+		return
+	}
+	offsetLookups.lock.Lock()
+	defer offsetLookups.lock.Unlock()
+	delete(offsetLookups.lookups, cluster)
+}
+
+// Get returns the lookup for the cluster, and false if there is none
+func (offsetLookups *OffsetLookups) Get(cluster string) (OffsetLookup, bool) {
+	if offsetLookups == nil {
+		return nil, false
+	}
+	offsetLookups.lock.RLock()
+	defer offsetLookups.lock.RUnlock()
+	lookup, ok := offsetLookups.lookups[cluster]
+	return lookup, ok
+}
+
+// DiscoveredClusters holds the configurations of the discovered clusters, by name. Each configuration is a viper
+// instance that has the settings of the cluster, and of its consumer module if it has one, under the same keys as they
+// would have in the global configuration. The zero value is ready to use, and a nil *DiscoveredClusters has no clusters.
+// It is safe to use concurrently.
+type DiscoveredClusters struct {
+	lock    sync.RWMutex
+	configs map[string]*viper.Viper
+}
+
+// Add records the configuration for the cluster, replacing any that is already recorded
+func (discovered *DiscoveredClusters) Add(name string, config *viper.Viper) {
+	if discovered == nil {
+		return
+	}
+	discovered.lock.Lock()
+	defer discovered.lock.Unlock()
+	if discovered.configs == nil {
+		discovered.configs = make(map[string]*viper.Viper)
+	}
+	discovered.configs[name] = config
+}
+
+// Remove forgets the configuration for the cluster, if there is one
+func (discovered *DiscoveredClusters) Remove(name string) {
+	if discovered == nil {
+		return
+	}
+	discovered.lock.Lock()
+	defer discovered.lock.Unlock()
+	delete(discovered.configs, name)
+}
+
+// Get returns the configuration for the cluster, and false if it is not a discovered cluster
+func (discovered *DiscoveredClusters) Get(name string) (*viper.Viper, bool) {
+	if discovered == nil {
+		return nil, false
+	}
+	discovered.lock.RLock()
+	defer discovered.lock.RUnlock()
+	config, ok := discovered.configs[name]
+	return config, ok
+}
+
+// Names returns the names of the discovered clusters, in order
+func (discovered *DiscoveredClusters) Names() []string {
+	if discovered == nil {
+		return nil
+	}
+	discovered.lock.RLock()
+	defer discovered.lock.RUnlock()
+	names := make([]string, 0, len(discovered.configs))
+	for name := range discovered.configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DiscoveredConsumerWatch is called when a consumer module for a discovered cluster is added, with the configuration
+// that the module is to be configured from, or is about to be removed, with a nil config. When a consumer is removed,
+// the watcher must stop the module before returning, as its cluster is stopped next. The watch must not call back into
+// the DiscoveredConsumers that it is registered with.
+type DiscoveredConsumerWatch func(name string, config *viper.Viper)
+
+// DiscoveredConsumers holds the consumer modules that are set up for discovered clusters, with their configurations,
+// and the watch that is told when they change. Each configuration is the same one that the discovered cluster has in
+// the DiscoveredClusters. The zero value is ready to use, and a nil *DiscoveredConsumers ignores changes and has no
+// consumers. It is safe to use concurrently.
+type DiscoveredConsumers struct {
+	lock      sync.Mutex
+	consumers map[string]*viper.Viper
+	watch     DiscoveredConsumerWatch
+}
+
+// Add records the consumer module and its configuration, and tells the watch about it if there is one
+func (discovered *DiscoveredConsumers) Add(name string, config *viper.Viper) {
+	if discovered == nil {
+		return
+	}
+	discovered.lock.Lock()
+	defer discovered.lock.Unlock()
+	if discovered.consumers == nil {
+		discovered.consumers = make(map[string]*viper.Viper)
+	}
+	discovered.consumers[name] = config
+	if discovered.watch != nil {
+		discovered.watch(name, config)
+	}
+}
+
+// Remove tells the watch, if there is one, that the consumer module is going away, and forgets it
+func (discovered *DiscoveredConsumers) Remove(name string) {
+	if discovered == nil {
+		return
+	}
+	discovered.lock.Lock()
+	defer discovered.lock.Unlock()
+	if _, ok := discovered.consumers[name]; !ok {
+		return
+	}
+	if discovered.watch != nil {
+		discovered.watch(name, nil)
+	}
+	delete(discovered.consumers, name)
+}
+
+// Get returns the configuration for the consumer module, and false if it is not one for a discovered cluster
+func (discovered *DiscoveredConsumers) Get(name string) (*viper.Viper, bool) {
+	if discovered == nil {
+		return nil, false
+	}
+	discovered.lock.Lock()
+	defer discovered.lock.Unlock()
+	config, ok := discovered.consumers[name]
+	return config, ok
+}
+
+// Names returns the names of the consumer modules for discovered clusters, in order
+func (discovered *DiscoveredConsumers) Names() []string {
+	if discovered == nil {
+		return nil
+	}
+	discovered.lock.Lock()
+	defer discovered.lock.Unlock()
+	names := make([]string, 0, len(discovered.consumers))
+	for name := range discovered.consumers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Watch sets the watch that is told about consumer modules as they are added and removed, replacing any that is set.
+// The watch is called for each of the consumer modules that have already been added, in order of name, before this
+// returns.
+func (discovered *DiscoveredConsumers) Watch(watch DiscoveredConsumerWatch) {
+	if discovered == nil {
+		return
+	}
+	discovered.lock.Lock()
+	defer discovered.lock.Unlock()
+	discovered.watch = watch
+	names := make([]string, 0, len(discovered.consumers))
+	for name := range discovered.consumers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		watch(name, discovered.consumers[name])
+	}
+}
+
+// Unwatch removes the watch. Once this returns, the watch is not called again.
+func (discovered *DiscoveredConsumers) Unwatch() {
+	if discovered == nil {
+		return
+	}
+	discovered.lock.Lock()
+	defer discovered.lock.Unlock()
+	discovered.watch = nil
+}
@@ -61,6 +61,71 @@ const (
 	// StorageFetchConsumersForTopic is the request type to obtain a list of all consumer groups consuming from a topic.
 	// Returns a []string
 	StorageFetchConsumersForTopic StorageRequestConstant = 11
+
+	// StorageSetConsumerGeneration is the request type to store the generation of a consumer group, as seen in the
+	// group metadata. Requires Cluster, Group, Generation, and Timestamp fields
+	StorageSetConsumerGeneration StorageRequestConstant = 12
+
+	// StorageFetchConsumerGeneration is the request type to retrieve the generation and rebalance history for a single
+	// consumer group. Requires Reply, Cluster, and Group fields. Returns a *ConsumerGeneration
+	StorageFetchConsumerGeneration StorageRequestConstant = 13
+
+	// StorageFetchTopicRegressed is the request type to retrieve whether the current broker offset (one per partition)
+	// for a topic is lower than an offset seen before. Requires Reply, Cluster, and Topic fields.
+	// Returns a []bool
+	StorageFetchTopicRegressed StorageRequestConstant = 14
+
+	// StorageSetConsumerMembers is the request type to store the number of members of a consumer group, as described by
+	// the group coordinator. Requires Cluster, Group, MemberCount, and Timestamp fields
+	StorageSetConsumerMembers StorageRequestConstant = 15
+
+	// StorageFetchConsumerMembers is the request type to retrieve the number of members of a single consumer group.
+	// Requires Reply, Cluster, and Group fields. Returns a *ConsumerMembers
+	StorageFetchConsumerMembers StorageRequestConstant = 16
+
+	// StorageFetchTopicOffsets is the request type to retrieve the range of broker offsets that is stored for each
+	// partition of a topic. Requires Reply and Cluster fields. If the Topic field is set, only that topic is returned,
+	// otherwise all topics in the cluster are returned. Returns a map[string][]*TopicPartitionOffsets
+	StorageFetchTopicOffsets StorageRequestConstant = 17
+
+	// StorageFetchStats is the request type to retrieve the number of topics, groups, and offset ring buffers that are
+	// held for each cluster, with an estimate of their memory use. Requires the Reply field. Returns a
+	// map[string]*StorageClusterStats
+	StorageFetchStats StorageRequestConstant = 18
+
+	// StorageFetchConsumersPage is the request type to retrieve one page of the consumer group names for a cluster, in
+	// sorted order. Requires Reply and Cluster fields. The Prefix, Limit, and Skip fields select the page. Returns a
+	// *ConsumerListPage
+	StorageFetchConsumersPage StorageRequestConstant = 19
+
+	// StorageSetConsumerTimeLag is the request type to store the time lag of a consumer partition, which is the
+	// difference between the timestamps of the newest message in the partition and the message at the committed
+	// offset. Requires Cluster, Group, Topic, Partition, and TimeLag fields
+	StorageSetConsumerTimeLag StorageRequestConstant = 20
+
+	// StorageSetClusterBrokers is the request type to store the controller and the number of brokers of a cluster, as
+	// seen in the cluster metadata. Requires Cluster, ControllerID, BrokerCount, and Timestamp fields
+	StorageSetClusterBrokers StorageRequestConstant = 21
+
+	// StorageFetchClusterBrokers is the request type to retrieve the controller and the number of brokers of a cluster.
+	// Requires Reply and Cluster fields. Returns a *ClusterBrokers
+	StorageFetchClusterBrokers StorageRequestConstant = 22
+
+	// StorageSetBrokerLogStart is the request type to store the oldest offset that the brokers retain for a partition,
+	// which is the start of the log. Requires Cluster, Topic, Partition, and Offset fields
+	StorageSetBrokerLogStart StorageRequestConstant = 23
+
+	// StorageSetAddCluster is the request type to start storing offsets for a cluster that was added while Burrow is
+	// running. Requires the Cluster field
+	StorageSetAddCluster StorageRequestConstant = 24
+
+	// StorageSetDeleteCluster is the request type to remove a cluster and all of its offsets. Requires the Cluster field
+	StorageSetDeleteCluster StorageRequestConstant = 25
+
+	// StorageSetEvictGroup is the request type to remove a consumer group, and report whether it was stored. Requires
+	// Reply, Cluster, and Group fields. If the Topic field is set, only that topic is removed from the group. Returns a
+	// bool, which is false if the group (or the topic of the group) was not stored
+	StorageSetEvictGroup StorageRequestConstant = 26
 )
 
 var storageRequestStrings = [...]string{
@@ -76,6 +141,21 @@ var storageRequestStrings = [...]string{
 	"StorageFetchTopic",
 	"StorageClearConsumerOwners",
 	"StorageFetchConsumersForTopic",
+	"StorageSetConsumerGeneration",
+	"StorageFetchConsumerGeneration",
+	"StorageFetchTopicRegressed",
+	"StorageSetConsumerMembers",
+	"StorageFetchConsumerMembers",
+	"StorageFetchTopicOffsets",
+	"StorageFetchStats",
+	"StorageFetchConsumersPage",
+	"StorageSetConsumerTimeLag",
+	"StorageSetClusterBrokers",
+	"StorageFetchClusterBrokers",
+	"StorageSetBrokerLogStart",
+	"StorageSetAddCluster",
+	"StorageSetDeleteCluster",
+	"StorageSetEvictGroup",
 }
 
 // String returns a string representation of a StorageRequestConstant for logging
@@ -124,13 +204,15 @@ type StorageRequest struct {
 	// For StorageSetBrokerOffset requests, TopicPartitionCount indicates the total number of partitions for the topic
 	TopicPartitionCount int32
 
-	// For StorageSetBrokerOffset and StorageSetConsumerOffset requests, the offset to store
+	// For StorageSetBrokerOffset, StorageSetBrokerLogStart, and StorageSetConsumerOffset requests, the offset to store
 	Offset int64
 
 	// For StorageSetConsumerOffset requests, the offset of the offset commit itself (i.e. the __consumer_offsets offset)
 	Order int64
 
-	// For StorageSetConsumerOffset requests, the timestamp of the offset being stored
+	// For StorageSetConsumerOffset requests, the timestamp of the offset being stored. For StorageSetConsumerGeneration
+	// requests, the time at which the group moved to the generation. For StorageSetConsumerMembers requests, the time
+	// at which the group was described
 	Timestamp int64
 
 	// For StorageSetConsumerOwner requests, a string describing the consumer host that owns the partition
@@ -138,6 +220,34 @@ type StorageRequest struct {
 
 	// For StorageSetConsumerOwner requests, a string containing the client_id set by the consumer
 	ClientID string
+
+	// For StorageSetConsumerGeneration requests, the generation ID of the consumer group
+	Generation int32
+
+	// For StorageSetConsumerMembers requests, the number of members in the consumer group
+	MemberCount int32
+
+	// For StorageSetBrokerOffset requests, true if the offset is lower than an offset the cluster module fetched for
+	// the partition before
+	Regressed bool
+
+	// For StorageFetchConsumersPage requests, only groups with names that start with Prefix are returned
+	Prefix string
+
+	// For StorageFetchConsumersPage requests, the maximum number of groups to return. Zero means there is no limit
+	Limit int
+
+	// For StorageFetchConsumersPage requests, the number of groups (in sorted order) to skip before the page starts
+	Skip int
+
+	// For StorageSetConsumerTimeLag requests, the time lag of the partition in milliseconds
+	TimeLag int64
+
+	// For StorageSetClusterBrokers requests, the ID of the controller broker, or -1 if the cluster has no controller
+	ControllerID int32
+
+	// For StorageSetClusterBrokers requests, the number of brokers in the cluster
+	BrokerCount int32
 }
 
 // ConsumerPartition represents the information stored for a group for a single partition. It is used as part of the
@@ -162,6 +272,118 @@ type ConsumerPartition struct {
 	// The current number of messages that the consumer is behind for this partition. This is calculated using the
 	// last committed offset and the current broker end offset
 	CurrentLag uint64 `json:"current-lag"`
+
+	// The number of milliseconds that the consumer is behind for this partition, which is the difference between the
+	// timestamps of the newest message and the message at the committed offset. This is only available if the cluster
+	// module is configured to fetch time lag, and is zero otherwise (or if the consumer has no lag)
+	TimeLag int64 `json:"time-lag"`
+
+	// The timestamp at which the newest broker offset for this partition was fetched, or zero if there is none. The
+	// current lag is only as fresh as this
+	LastBrokerOffsetTimestamp int64 `json:"lastBrokerOffsetTimestamp"`
+
+	// True if the last committed offset is lower than the oldest offset that the brokers retain for the partition, which
+	// means the messages in between were deleted before the consumer read them. This is only known if the cluster
+	// module is configured to fetch the oldest offsets
+	BelowLogStart bool `json:"belowLogStart"`
+}
+
+// ConsumerGeneration represents the generation information stored for a group. It is the response to a
+// StorageFetchConsumerGeneration request
+type ConsumerGeneration struct {
+	// The most recent generation ID seen in the group metadata
+	Generation int32 `json:"generation"`
+
+	// The timestamp at which the group moved to the current generation
+	Timestamp int64 `json:"timestamp"`
+
+	// The number of times Burrow has seen the generation of the group change
+	Rebalances uint64 `json:"rebalances"`
+}
+
+// ConsumerMembers represents the membership information stored for a group. It is the response to a
+// StorageFetchConsumerMembers request
+type ConsumerMembers struct {
+	// The number of members in the group when it was last described
+	MemberCount int32 `json:"member_count"`
+
+	// The timestamp at which the group was last described
+	Timestamp int64 `json:"timestamp"`
+}
+
+// ClusterBrokers represents the broker information stored for a cluster. It is the response to a
+// StorageFetchClusterBrokers request
+type ClusterBrokers struct {
+	// The ID of the controller broker, or -1 if the cluster had no controller (or it has not been seen yet)
+	ControllerID int32 `json:"controller_id"`
+
+	// The number of brokers in the cluster
+	BrokerCount int32 `json:"broker_count"`
+
+	// The timestamp at which the brokers were last seen, or zero if they have not been seen yet
+	Timestamp int64 `json:"timestamp"`
+
+	// The timestamp of the newest broker offset stored for any partition of the cluster, or zero if there is none. If
+	// this is far behind the current time, the cluster module is not fetching offsets
+	LastUpdate int64 `json:"last_update"`
+}
+
+// ConsumerListPage is the response to a StorageFetchConsumersPage request. It contains a single page of group names
+type ConsumerListPage struct {
+	// The group names in the page, in sorted order
+	Consumers []string
+
+	// The total number of groups that match the prefix, across all pages
+	Total int
+}
+
+// TopicPartitionOffsets describes the broker offsets that are stored for a single partition of a topic. It is part of
+// the response to a StorageFetchTopicOffsets request
+type TopicPartitionOffsets struct {
+	// The partition ID
+	Partition int32 `json:"partition"`
+
+	// The oldest broker offset that is stored for the partition
+	Oldest int64 `json:"oldest"`
+
+	// The newest broker offset that is stored for the partition
+	Newest int64 `json:"newest"`
+
+	// The timestamp at which the newest broker offset was fetched
+	Timestamp int64 `json:"timestamp"`
+}
+
+// StorageClusterStats describes the offset ring buffers that storage holds for a single cluster. It is part of the
+// response to a StorageFetchStats request
+type StorageClusterStats struct {
+	// The number of offsets that each buffer holds for the cluster
+	Intervals int `json:"intervals"`
+
+	// The number of topics that broker offsets are stored for
+	Topics int `json:"topics"`
+
+	// The number of partitions of all of the topics
+	Partitions int `json:"partitions"`
+
+	// The number of broker offset buffers, one for each partition of each topic
+	BrokerBuffers int `json:"brokerBuffers"`
+
+	// The number of consumer groups that are stored
+	Groups int `json:"groups"`
+
+	// The number of distinct consumers (owner and client ID in a group) that currently own partitions
+	Consumers int `json:"consumers"`
+
+	// The number of consumer offset buffers, one for each partition that a group has committed offsets for
+	ConsumerBuffers int `json:"consumerBuffers"`
+
+	// The total number of offset slots in all of the buffers, which is the number of buffers multiplied by Intervals.
+	// Memory use grows with this number
+	BufferSlots int `json:"bufferSlots"`
+
+	// An estimate of the number of bytes used by the storage for the cluster. This counts every buffer slot as full,
+	// and does not include the overhead of the maps, so it is only suitable for watching growth
+	EstimatedBytes int64 `json:"estimatedBytes"`
 }
 
 // Lag is just a wrapper for a uint64, but it can be `nil`
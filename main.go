@@ -68,8 +68,8 @@ func main() {
 	// This makes sure that we panic and run defers correctly
 	defer handleExit()
 
-	// The only command line arg is the config file
 	configPath := flag.String("config-dir", ".", "Directory that contains the configuration file")
+	validate := flag.Bool("validate", false, "Check the configuration and the connections to the clusters, then exit")
 	flag.Parse()
 
 	// Load the configuration from the file
@@ -81,6 +81,11 @@ func main() {
 		fmt.Fprintln(os.Stderr, "Failed reading configuration:", err.Error())
 		panic(exitCode{1})
 	}
+	err = core.ExpandConfigEnv()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed expanding environment variables in configuration:", err.Error())
+		panic(exitCode{1})
+	}
 
 	// setup viper to be able to read env variables with a configured prefix
 	viper.SetDefault("general.env-var-prefix", "burrow")
@@ -89,6 +94,22 @@ func main() {
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
 	viper.AutomaticEnv()
 
+	// Report the problems with the configuration and exit, without starting Burrow
+	if *validate {
+		problems := core.Validate(nil)
+		for _, problem := range problems {
+			// The problems of a coordinator are joined together, one on each line
+			for _, line := range strings.Split(problem.Error(), "\n") {
+				fmt.Fprintln(os.Stderr, "Configuration problem:", line)
+			}
+		}
+		if len(problems) > 0 {
+			panic(exitCode{1})
+		}
+		fmt.Fprintln(os.Stderr, "Configuration is valid")
+		panic(exitCode{0})
+	}
+
 	// Create the PID file to lock out other processes
 	viper.SetDefault("general.pidfile", "burrow.pid")
 	pidFile := viper.GetString("general.pidfile")
@@ -104,9 +125,9 @@ func main() {
 		core.OpenOutLog(stdoutLogfile)
 	}
 
-	// Register signal handlers for exiting
+	// Register signal handlers for exiting, as well as SIGHUP for reloading the group and topic filters
 	exitChannel := make(chan os.Signal, 1)
-	signal.Notify(exitChannel, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM)
+	signal.Notify(exitChannel, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGHUP)
 
 	// This triggers handleExit (after other defers), which will then call os.Exit properly
 	panic(exitCode{core.Start(nil, exitChannel)})